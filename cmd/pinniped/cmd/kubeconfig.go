@@ -215,12 +215,16 @@ func runGetKubeconfig(ctx context.Context, out io.Writer, deps kubeconfigDeps, f
 			clientset,
 			flags.concierge.authenticatorType,
 			flags.concierge.authenticatorName,
+			func(authenticator metav1.Object) bool {
+				_, err := getConciergeFrontend(credentialIssuer, flags.concierge.mode, authenticator)
+				return err == nil
+			},
 			deps.log,
 		)
 		if err != nil {
 			return err
 		}
-		if err := discoverConciergeParams(credentialIssuer, &flags, cluster, deps.log); err != nil {
+		if err := discoverConciergeParams(credentialIssuer, authenticator, &flags, cluster, deps.log); err != nil {
 			return err
 		}
 		if err := discoverAuthenticatorParams(authenticator, &flags, deps.log); err != nil {
@@ -401,9 +405,9 @@ func waitForCredentialIssuer(ctx context.Context, clientset conciergeclientset.I
 	return credentialIssuer, nil
 }
 
-func discoverConciergeParams(credentialIssuer *configv1alpha1.CredentialIssuer, flags *getKubeconfigParams, v1Cluster *clientcmdapi.Cluster, log plog.MinLogger) error {
+func discoverConciergeParams(credentialIssuer *configv1alpha1.CredentialIssuer, authenticator metav1.Object, flags *getKubeconfigParams, v1Cluster *clientcmdapi.Cluster, log plog.MinLogger) error {
 	// Autodiscover the --concierge-mode.
-	frontend, err := getConciergeFrontend(credentialIssuer, flags.concierge.mode)
+	frontend, err := getConciergeFrontend(credentialIssuer, flags.concierge.mode, authenticator)
 	if err != nil {
 		logStrategies(credentialIssuer, log)
 		return err
@@ -505,7 +509,10 @@ func discoverAuthenticatorParams(authenticator metav1.Object, flags *getKubeconf
 	return nil
 }
 
-func getConciergeFrontend(credentialIssuer *configv1alpha1.CredentialIssuer, mode conciergeModeFlag) (*configv1alpha1.CredentialIssuerFrontend, error) {
+// getConciergeFrontend returns the first healthy frontend which matches the mode and which allows the authenticator
+// to be used through it. When the authenticator is nil, any frontend matching the mode is returned.
+func getConciergeFrontend(credentialIssuer *configv1alpha1.CredentialIssuer, mode conciergeModeFlag, authenticator metav1.Object) (*configv1alpha1.CredentialIssuerFrontend, error) {
+	foundDisallowedFrontend := false
 	for _, strategy := range credentialIssuer.Status.Strategies {
 		// Skip unhealthy strategies.
 		if strategy.Status != configv1alpha1.SuccessStrategyStatus {
@@ -539,15 +546,57 @@ func getConciergeFrontend(credentialIssuer *configv1alpha1.CredentialIssuer, mod
 		if !mode.MatchesFrontend(strategy.Frontend) {
 			continue
 		}
+		// Skip strategies whose frontend does not allow the authenticator per the CredentialIssuer spec.
+		if authenticator != nil && !frontendAllowsAuthenticator(credentialIssuer, strategy.Frontend.Type, authenticator) {
+			foundDisallowedFrontend = true
+			continue
+		}
 		return strategy.Frontend, nil
 	}
 
+	if foundDisallowedFrontend {
+		kind, name := authenticatorKindAndName(authenticator)
+		if mode == modeUnknown {
+			return nil, fmt.Errorf("could not find successful Concierge strategy which allows %s %q", kind, name)
+		}
+		return nil, fmt.Errorf("could not find successful Concierge strategy matching --concierge-mode=%s which allows %s %q", mode.String(), kind, name)
+	}
 	if mode == modeUnknown {
 		return nil, fmt.Errorf("could not autodiscover --concierge-mode")
 	}
 	return nil, fmt.Errorf("could not find successful Concierge strategy matching --concierge-mode=%s", mode.String())
 }
 
+// frontendAllowsAuthenticator returns true iff the spec of the CredentialIssuer allows the authenticator to be used
+// through the frontend. Frontends which are not configured in the spec allow any authenticator.
+func frontendAllowsAuthenticator(credentialIssuer *configv1alpha1.CredentialIssuer, frontendType configv1alpha1.FrontendType, authenticator metav1.Object) bool {
+	kind, name := authenticatorKindAndName(authenticator)
+	restricted := false
+	for _, frontend := range credentialIssuer.Spec.Frontends {
+		if frontend.Type != frontendType {
+			continue
+		}
+		for _, ref := range frontend.AllowedAuthenticators {
+			restricted = true
+			if ref.Kind == kind && ref.Name == name {
+				return true
+			}
+		}
+	}
+	return !restricted
+}
+
+func authenticatorKindAndName(authenticator metav1.Object) (string, string) {
+	switch authenticator.(type) {
+	case *conciergev1alpha1.JWTAuthenticator:
+		return "JWTAuthenticator", authenticator.GetName()
+	case *conciergev1alpha1.WebhookAuthenticator:
+		return "WebhookAuthenticator", authenticator.GetName()
+	default:
+		return "", authenticator.GetName()
+	}
+}
+
 func newExecKubeconfig(cluster *clientcmdapi.Cluster, execConfig *clientcmdapi.ExecConfig, newNames *kubeconfigNames) clientcmdapi.Config {
 	return clientcmdapi.Config{
 		Kind:           "Config",
@@ -585,7 +634,7 @@ func lookupCredentialIssuer(clientset conciergeclientset.Interface, name string,
 	return result, nil
 }
 
-func lookupAuthenticator(clientset conciergeclientset.Interface, authType, authName string, log plog.MinLogger) (metav1.Object, error) {
+func lookupAuthenticator(clientset conciergeclientset.Interface, authType, authName string, allowed func(metav1.Object) bool, log plog.MinLogger) (metav1.Object, error) {
 	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*20)
 	defer cancelFunc()
 
@@ -623,6 +672,17 @@ func lookupAuthenticator(clientset conciergeclientset.Interface, authType, authN
 		return nil, fmt.Errorf("no authenticators were found")
 	}
 	if len(results) > 1 {
+		// If the CredentialIssuer only allows one of the authenticators to be used through the Concierge, then use it.
+		var allowedResults []metav1.Object
+		for _, result := range results {
+			if allowed(result) {
+				allowedResults = append(allowedResults, result)
+			}
+		}
+		if len(allowedResults) == 1 {
+			return allowedResults[0], nil
+		}
+
 		for _, jwtAuth := range jwtAuths.Items {
 			log.Info("found JWTAuthenticator", "name", jwtAuth.Name)
 		}
@@ -1817,6 +1817,187 @@ func TestGetKubeconfig(t *testing.T) {
 					base64.StdEncoding.EncodeToString([]byte(issuerCABundle)))
 			},
 		},
+		{
+			name: "autodetect impersonation proxy and JWT authenticator allowed by the credential issuer frontends",
+			args: func(issuerCABundle string, issuerURL string) []string {
+				return []string{
+					"--kubeconfig", "./testdata/kubeconfig.yaml",
+					"--skip-validation",
+				}
+			},
+			conciergeObjects: func(issuerCABundle string, issuerURL string) []runtime.Object {
+				return []runtime.Object{
+					&configv1alpha1.CredentialIssuer{
+						ObjectMeta: metav1.ObjectMeta{Name: "test-credential-issuer"},
+						Spec: configv1alpha1.CredentialIssuerSpec{
+							Frontends: []configv1alpha1.CredentialIssuerFrontendSpec{
+								{
+									Type:                  configv1alpha1.TokenCredentialRequestAPIFrontendType,
+									AllowedAuthenticators: []configv1alpha1.AuthenticatorReference{{Kind: "WebhookAuthenticator", Name: "internal-authenticator"}},
+								},
+								{
+									Type:                  configv1alpha1.ImpersonationProxyFrontendType,
+									AllowedAuthenticators: []configv1alpha1.AuthenticatorReference{{Kind: "JWTAuthenticator", Name: "test-authenticator"}},
+								},
+							},
+						},
+						Status: configv1alpha1.CredentialIssuerStatus{
+							Strategies: []configv1alpha1.CredentialIssuerStrategy{
+								{
+									Type:           configv1alpha1.KubeClusterSigningCertificateStrategyType,
+									Status:         configv1alpha1.SuccessStrategyStatus,
+									Reason:         configv1alpha1.FetchedKeyStrategyReason,
+									Message:        "Successfully fetched key",
+									LastUpdateTime: metav1.Now(),
+									Frontend: &configv1alpha1.CredentialIssuerFrontend{
+										Type: configv1alpha1.TokenCredentialRequestAPIFrontendType,
+										TokenCredentialRequestAPIInfo: &configv1alpha1.TokenCredentialRequestAPIInfo{
+											Server:                   "https://concierge-endpoint",
+											CertificateAuthorityData: "ZmFrZS1jZXJ0aWZpY2F0ZS1hdXRob3JpdHktZGF0YS12YWx1ZQ==",
+										},
+									},
+								},
+								{
+									Type:           "SomeType",
+									Status:         configv1alpha1.SuccessStrategyStatus,
+									Reason:         "SomeReason",
+									Message:        "Some message",
+									LastUpdateTime: metav1.Now(),
+									Frontend: &configv1alpha1.CredentialIssuerFrontend{
+										Type: configv1alpha1.ImpersonationProxyFrontendType,
+										ImpersonationProxyInfo: &configv1alpha1.ImpersonationProxyInfo{
+											Endpoint:                 "https://impersonation-proxy-endpoint.test",
+											CertificateAuthorityData: "dGVzdC1jb25jaWVyZ2UtY2E=",
+										},
+									},
+								},
+								{
+									Type:           "SomeOtherType",
+									Status:         configv1alpha1.SuccessStrategyStatus,
+									Reason:         "SomeOtherReason",
+									Message:        "Some other message",
+									LastUpdateTime: metav1.Now(),
+									Frontend: &configv1alpha1.CredentialIssuerFrontend{
+										Type: configv1alpha1.ImpersonationProxyFrontendType,
+										ImpersonationProxyInfo: &configv1alpha1.ImpersonationProxyInfo{
+											Endpoint:                 "https://some-other-impersonation-endpoint",
+											CertificateAuthorityData: "dGVzdC1jb25jaWVyZ2UtY2E=",
+										},
+									},
+								},
+							},
+						},
+					},
+					jwtAuthenticator(issuerCABundle, issuerURL),
+					&conciergev1alpha1.WebhookAuthenticator{ObjectMeta: metav1.ObjectMeta{Name: "unlisted-authenticator"}},
+				}
+			},
+			oidcDiscoveryResponse: onlyIssuerOIDCDiscoveryResponse,
+			wantLogs: func(issuerCABundle string, issuerURL string) []string {
+				return []string{
+					`"level"=0 "msg"="discovered CredentialIssuer"  "name"="test-credential-issuer"`,
+					`"level"=0 "msg"="discovered Concierge operating in impersonation proxy mode"`,
+					`"level"=0 "msg"="discovered Concierge endpoint"  "endpoint"="https://impersonation-proxy-endpoint.test"`,
+					`"level"=0 "msg"="discovered Concierge certificate authority bundle"  "roots"=0`,
+					`"level"=0 "msg"="discovered JWTAuthenticator"  "name"="test-authenticator"`,
+					fmt.Sprintf(`"level"=0 "msg"="discovered OIDC issuer"  "issuer"="%s"`, issuerURL),
+					`"level"=0 "msg"="discovered OIDC audience"  "audience"="test-audience"`,
+					`"level"=0 "msg"="discovered OIDC CA bundle"  "roots"=1`,
+				}
+			},
+			wantStdout: func(issuerCABundle string, issuerURL string) string {
+				return here.Docf(`
+					apiVersion: v1
+					clusters:
+					- cluster:
+						certificate-authority-data: dGVzdC1jb25jaWVyZ2UtY2E=
+						server: https://impersonation-proxy-endpoint.test
+					  name: kind-cluster-pinniped
+					contexts:
+					- context:
+						cluster: kind-cluster-pinniped
+						user: kind-user-pinniped
+					  name: kind-context-pinniped
+					current-context: kind-context-pinniped
+					kind: Config
+					preferences: {}
+					users:
+					- name: kind-user-pinniped
+					  user:
+						exec:
+						  apiVersion: client.authentication.k8s.io/v1beta1
+						  args:
+						  - login
+						  - oidc
+						  - --enable-concierge
+						  - --concierge-api-group-suffix=pinniped.dev
+						  - --concierge-authenticator-name=test-authenticator
+						  - --concierge-authenticator-type=jwt
+						  - --concierge-endpoint=https://impersonation-proxy-endpoint.test
+						  - --concierge-ca-bundle-data=dGVzdC1jb25jaWVyZ2UtY2E=
+						  - --issuer=%s
+						  - --client-id=pinniped-cli
+						  - --scopes=offline_access,openid,pinniped:request-audience
+						  - --ca-bundle-data=%s
+						  - --request-audience=test-audience
+						  command: '.../path/to/pinniped'
+						  env: []
+						  installHint: The pinniped CLI does not appear to be installed.  See https://get.pinniped.dev/cli
+             for more details
+						  provideClusterInfo: true
+					`,
+					issuerURL,
+					base64.StdEncoding.EncodeToString([]byte(issuerCABundle)))
+			},
+		},
+		{
+			name: "autodetect JWT authenticator which is not allowed by any credential issuer frontend",
+			args: func(issuerCABundle string, issuerURL string) []string {
+				return []string{
+					"--kubeconfig", "./testdata/kubeconfig.yaml",
+				}
+			},
+			conciergeObjects: func(issuerCABundle string, issuerURL string) []runtime.Object {
+				return []runtime.Object{
+					&configv1alpha1.CredentialIssuer{
+						ObjectMeta: metav1.ObjectMeta{Name: "test-credential-issuer"},
+						Spec: configv1alpha1.CredentialIssuerSpec{
+							Frontends: []configv1alpha1.CredentialIssuerFrontendSpec{{
+								Type:                  configv1alpha1.ImpersonationProxyFrontendType,
+								AllowedAuthenticators: []configv1alpha1.AuthenticatorReference{{Kind: "JWTAuthenticator", Name: "some-other-authenticator"}},
+							}},
+						},
+						Status: configv1alpha1.CredentialIssuerStatus{
+							Strategies: []configv1alpha1.CredentialIssuerStrategy{{
+								Type:           "SomeType",
+								Status:         configv1alpha1.SuccessStrategyStatus,
+								Reason:         "SomeReason",
+								Message:        "Some message",
+								LastUpdateTime: metav1.Now(),
+								Frontend: &configv1alpha1.CredentialIssuerFrontend{
+									Type: configv1alpha1.ImpersonationProxyFrontendType,
+									ImpersonationProxyInfo: &configv1alpha1.ImpersonationProxyInfo{
+										Endpoint:                 "https://impersonation-proxy-endpoint.test",
+										CertificateAuthorityData: "dGVzdC1jb25jaWVyZ2UtY2E=",
+									},
+								},
+							}},
+						},
+					},
+					jwtAuthenticator(issuerCABundle, issuerURL),
+				}
+			},
+			wantLogs: func(issuerCABundle string, issuerURL string) []string {
+				return []string{
+					`"level"=0 "msg"="discovered CredentialIssuer"  "name"="test-credential-issuer"`,
+					`"level"=0 "msg"="found CredentialIssuer strategy"  "message"="Some message" "reason"="SomeReason" "status"="Success" "type"="SomeType"`,
+				}
+			},
+			wantError: true,
+			wantStderr: func(issuerCABundle string, issuerURL string) string {
+				return `Error: could not find successful Concierge strategy which allows JWTAuthenticator "test-authenticator"` + "\n"
+			},
+		},
 		{
 			name: "Find LDAP IDP in IDP discovery document, output ldap related flags",
 			args: func(issuerCABundle string, issuerURL string) []string {
@@ -0,0 +1,21 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+//nolint: gochecknoglobals
+var supervisorCmd = &cobra.Command{
+	Use:          "supervisor",
+	Short:        "supervisor",
+	Long:         "supervisor subcommands help to configure the Pinniped Supervisor",
+	SilenceUsage: true, // do not print usage message when commands fail
+}
+
+//nolint: gochecknoinits
+func init() {
+	rootCmd.AddCommand(supervisorCmd)
+}
@@ -0,0 +1,308 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	"go.pinniped.dev/internal/authenticators"
+	"go.pinniped.dev/internal/controller/supervisorconfig/upstreamwatchers"
+	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/upstreamldap"
+	"go.pinniped.dev/pkg/oidcclient"
+)
+
+//nolint: gochecknoinits
+func init() {
+	supervisorCmd.AddCommand(testLDAPCommand(testLDAPRealDeps()))
+}
+
+type testLDAPDeps struct {
+	dialer          upstreamldap.LDAPDialer // nil means to use a real LDAP dialer
+	promptForSecret func(promptLabel string) (string, error)
+}
+
+func testLDAPRealDeps() testLDAPDeps {
+	return testLDAPDeps{promptForSecret: oidcclient.PromptForSecret}
+}
+
+type testLDAPFlags struct {
+	providerPath   string
+	bindSecretPath string
+	username       string
+	dryRun         bool
+	timeout        time.Duration
+}
+
+// testLDAPProvider is an LDAPIdentityProvider or ActiveDirectoryIdentityProvider which was read from a file.
+type testLDAPProvider struct {
+	kind       string
+	name       string
+	config     *upstreamldap.ProviderConfig
+	tlsSpec    *idpv1alpha1.TLSSpec
+	bindMethod idpv1alpha1.BindMethod
+
+	// discoverSearchBase is true when missing search bases should be read from the RootDSE, like the Supervisor
+	// does for ActiveDirectoryIdentityProviders.
+	discoverSearchBase bool
+}
+
+func testLDAPCommand(deps testLDAPDeps) *cobra.Command {
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs, // do not accept positional arguments for this command
+		Use:   "test-ldap --provider FILE --bind-secret FILE [--username USERNAME]",
+		Short: "Test the configuration of an LDAPIdentityProvider or ActiveDirectoryIdentityProvider",
+		Long: here.Doc(`
+			Test the configuration of an LDAPIdentityProvider or ActiveDirectoryIdentityProvider
+
+			The provider and its bind Secret are read from local YAML files, so they do not need to be
+			applied to a cluster first. The bind Secret and TLS settings are validated and the connection to
+			the LDAP server is tested in the same way as the Supervisor would. When a username is given, the
+			user is searched for and their username, UID, and groups are printed, and then the user's
+			password is prompted for and a real login is attempted.
+
+			Each search request which is sent to the LDAP server is printed along with its results.
+		`),
+		SilenceUsage: true,
+	}
+	flags := &testLDAPFlags{}
+
+	f := cmd.Flags()
+	f.StringVar(&flags.providerPath, "provider", "", "Path to a YAML file containing an LDAPIdentityProvider or ActiveDirectoryIdentityProvider")
+	f.StringVar(&flags.bindSecretPath, "bind-secret", "", "Path to a YAML file containing the bind Secret of the provider")
+	f.StringVar(&flags.username, "username", "", "Username of a user to search for and log in as (optional)")
+	f.BoolVar(&flags.dryRun, "dry-run", false, "Search for the user without prompting for their password and logging in")
+	f.DurationVar(&flags.timeout, "timeout", 90*time.Second, "Timeout for each operation against the LDAP server")
+	mustMarkRequired(cmd, "provider", "bind-secret")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		return runTestLDAP(cmd.Context(), cmd.OutOrStdout(), deps, flags)
+	}
+
+	return cmd
+}
+
+func runTestLDAP(ctx context.Context, out io.Writer, deps testLDAPDeps, flags *testLDAPFlags) error {
+	upstream, err := loadTestLDAPProvider(flags.providerPath)
+	if err != nil {
+		return err
+	}
+	secret, err := loadTestLDAPBindSecret(flags.bindSecretPath)
+	if err != nil {
+		return err
+	}
+
+	config := upstream.config
+	config.Dialer = deps.dialer
+	config.SearchObserver = func(request *ldap.SearchRequest, result *ldap.SearchResult, err error) {
+		printLDAPSearch(out, request, result, err)
+	}
+
+	fmt.Fprintf(out, "Testing %s %q\n", upstream.kind, upstream.name)
+
+	if err := checkTestLDAPCondition(out, upstreamwatchers.ValidateBindSecret(secret, upstream.bindMethod, config)); err != nil {
+		return err
+	}
+	if err := checkTestLDAPCondition(out, upstreamwatchers.ValidateTLSConfig(upstream.tlsSpec, config)); err != nil {
+		return err
+	}
+
+	testConnectionCtx, cancel := context.WithTimeout(ctx, flags.timeout)
+	defer cancel()
+	if err := checkTestLDAPCondition(out, upstreamwatchers.TestConnection(testConnectionCtx, secret.Name, config, secret.ResourceVersion)); err != nil {
+		return err
+	}
+
+	if upstream.discoverSearchBase && (config.UserSearch.Base == "" || config.GroupSearch.Base == "") {
+		fmt.Fprintln(out, "\nSearching for the defaultNamingContext to use as the default search base")
+		searchBaseCtx, cancel := context.WithTimeout(ctx, flags.timeout)
+		defer cancel()
+		defaultNamingContext, err := upstreamldap.New(*config).SearchForDefaultNamingContext(searchBaseCtx)
+		if err != nil {
+			return fmt.Errorf("could not find the default search base: %w", err)
+		}
+		if config.UserSearch.Base == "" {
+			config.UserSearch.Base = defaultNamingContext
+		}
+		if config.GroupSearch.Base == "" {
+			config.GroupSearch.Base = defaultNamingContext
+		}
+		fmt.Fprintf(out, "Using %q as the default search base\n", defaultNamingContext)
+	}
+
+	if flags.username == "" {
+		return nil
+	}
+	p := upstreamldap.New(*config)
+
+	fmt.Fprintf(out, "\nSearching for user %q without logging in as the user\n", flags.username)
+	dryRunCtx, cancel := context.WithTimeout(ctx, flags.timeout)
+	defer cancel()
+	response, authenticated, err := p.DryRunAuthenticateUser(dryRunCtx, flags.username)
+	if err := printTestLDAPUser(out, response, authenticated, err, "the user was not found"); err != nil {
+		return err
+	}
+
+	if flags.dryRun {
+		return nil
+	}
+
+	password, err := deps.promptForSecret(fmt.Sprintf("Password for %q: ", flags.username))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "\nLogging in as user %q\n", flags.username)
+	loginCtx, cancel := context.WithTimeout(ctx, flags.timeout)
+	defer cancel()
+	response, authenticated, err = p.AuthenticateUser(loginCtx, flags.username, password)
+	return printTestLDAPUser(out, response, authenticated, err, "the user was not found or the password was incorrect")
+}
+
+func loadTestLDAPProvider(path string) (*testLDAPProvider, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read provider file: %w", err)
+	}
+
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &typeMeta); err != nil {
+		return nil, fmt.Errorf("could not decode provider file %q: %w", path, err)
+	}
+
+	switch typeMeta.Kind {
+	case "LDAPIdentityProvider":
+		var upstream idpv1alpha1.LDAPIdentityProvider
+		if err := yaml.UnmarshalStrict(data, &upstream); err != nil {
+			return nil, fmt.Errorf("could not decode provider file %q: %w", path, err)
+		}
+		return &testLDAPProvider{
+			kind:       typeMeta.Kind,
+			name:       upstream.Name,
+			config:     upstreamwatchers.LDAPProviderConfig(&upstream),
+			tlsSpec:    upstream.Spec.TLS,
+			bindMethod: upstream.Spec.Bind.Method,
+		}, nil
+	case "ActiveDirectoryIdentityProvider":
+		var upstream idpv1alpha1.ActiveDirectoryIdentityProvider
+		if err := yaml.UnmarshalStrict(data, &upstream); err != nil {
+			return nil, fmt.Errorf("could not decode provider file %q: %w", path, err)
+		}
+		return &testLDAPProvider{
+			kind:               typeMeta.Kind,
+			name:               upstream.Name,
+			config:             upstreamwatchers.ActiveDirectoryProviderConfig(&upstream),
+			tlsSpec:            upstream.Spec.TLS,
+			bindMethod:         upstream.Spec.Bind.Method,
+			discoverSearchBase: true,
+		}, nil
+	default:
+		return nil, fmt.Errorf("provider file %q must contain an LDAPIdentityProvider or an ActiveDirectoryIdentityProvider, but it contains kind %q", path, typeMeta.Kind)
+	}
+}
+
+func loadTestLDAPBindSecret(path string) (*corev1.Secret, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bind Secret file: %w", err)
+	}
+
+	var secret corev1.Secret
+	if err := yaml.UnmarshalStrict(data, &secret); err != nil {
+		return nil, fmt.Errorf("could not decode bind Secret file %q: %w", path, err)
+	}
+
+	// The Kubernetes API server would merge the stringData into the data of the Secret, so do the same here.
+	if len(secret.StringData) > 0 && secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for key, value := range secret.StringData {
+		secret.Data[key] = []byte(value)
+	}
+	return &secret, nil
+}
+
+// checkTestLDAPCondition prints the condition, and returns an error when the condition is not true.
+func checkTestLDAPCondition(out io.Writer, condition *idpv1alpha1.Condition) error {
+	fmt.Fprintf(out, "%s: %s\n", condition.Type, condition.Message)
+	if condition.Status != idpv1alpha1.ConditionTrue {
+		return fmt.Errorf("%s is %s with reason %s", condition.Type, condition.Status, condition.Reason)
+	}
+	return nil
+}
+
+func printTestLDAPUser(out io.Writer, response *authenticators.Response, authenticated bool, err error, notAuthenticatedMessage string) error {
+	if err != nil {
+		return fmt.Errorf("could not authenticate the user: %w", err)
+	}
+	if !authenticated {
+		return errors.New(notAuthenticatedMessage)
+	}
+
+	groups := "(none)"
+	if len(response.User.GetGroups()) > 0 {
+		groups = strings.Join(response.User.GetGroups(), ", ")
+	}
+	fmt.Fprintf(out, "  DN: %s\n  username: %s\n  UID: %s\n  groups: %s\n",
+		response.DN, response.User.GetName(), response.User.GetUID(), groups)
+
+	claimNames := make([]string, 0, len(response.AdditionalClaims))
+	for claimName := range response.AdditionalClaims {
+		claimNames = append(claimNames, claimName)
+	}
+	sort.Strings(claimNames)
+	for _, claimName := range claimNames {
+		fmt.Fprintf(out, "  additional claim %q: %s\n", claimName, response.AdditionalClaims[claimName])
+	}
+	for _, warning := range response.Warnings {
+		fmt.Fprintf(out, "  warning: %s\n", warning)
+	}
+	return nil
+}
+
+func printLDAPSearch(out io.Writer, request *ldap.SearchRequest, result *ldap.SearchResult, err error) {
+	fmt.Fprintf(out, "  LDAP search request:\n    base: %q\n    scope: %s\n    filter: %q\n    attributes: %q\n",
+		request.BaseDN, ldap.ScopeMap[request.Scope], request.Filter, request.Attributes)
+	if err != nil {
+		fmt.Fprintf(out, "  LDAP search error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Fprintf(out, "  LDAP search result: %d entries\n", len(result.Entries))
+	for _, entry := range result.Entries {
+		fmt.Fprintf(out, "    dn: %q\n", entry.DN)
+		for _, attribute := range entry.Attributes {
+			fmt.Fprintf(out, "      %s: %s\n", attribute.Name, formatLDAPAttributeValues(attribute.ByteValues))
+		}
+	}
+}
+
+// formatLDAPAttributeValues quotes each value, or base64 encodes it when it is binary, e.g. for an objectGUID.
+func formatLDAPAttributeValues(values [][]byte) string {
+	formatted := make([]string, 0, len(values))
+	for _, value := range values {
+		if utf8.Valid(value) {
+			formatted = append(formatted, fmt.Sprintf("%q", value))
+			continue
+		}
+		formatted = append(formatted, "base64:"+base64.StdEncoding.EncodeToString(value))
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,439 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/endpointaddr"
+	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/mocks/mockldapconn"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/internal/upstreamldap"
+)
+
+func TestTestLDAPCommand(t *testing.T) {
+	const (
+		ldapProviderYAML = `
+			apiVersion: idp.supervisor.pinniped.dev/v1alpha1
+			kind: LDAPIdentityProvider
+			metadata:
+			  name: my-ldap-provider
+			  namespace: pinniped-supervisor
+			spec:
+			  host: ldap.example.com
+			  bind:
+			    secretName: my-bind-secret
+			  userSearch:
+			    base: ou=users,dc=example,dc=com
+			    filter: uid={}
+			    attributes:
+			      username: mail
+			      uid: uidNumber
+			  groupSearch:
+			    base: ou=groups,dc=example,dc=com
+			    attributes:
+			      groupName: cn
+		`
+		activeDirectoryProviderYAML = `
+			apiVersion: idp.supervisor.pinniped.dev/v1alpha1
+			kind: ActiveDirectoryIdentityProvider
+			metadata:
+			  name: my-ad-provider
+			spec:
+			  host: ad.example.com
+			  bind:
+			    secretName: my-bind-secret
+		`
+		bindSecretYAML = `
+			apiVersion: v1
+			kind: Secret
+			metadata:
+			  name: my-bind-secret
+			type: kubernetes.io/basic-auth
+			stringData:
+			  username: cn=admin,dc=example,dc=com
+			  password: some-bind-password
+		`
+	)
+
+	userSearchResult := &ldap.SearchResult{
+		Entries: []*ldap.Entry{
+			ldap.NewEntry("uid=pinny,ou=users,dc=example,dc=com", map[string][]string{
+				"mail":      {"pinny@example.com"},
+				"uidNumber": {"1000"},
+			}),
+		},
+	}
+	groupSearchResult := &ldap.SearchResult{
+		Entries: []*ldap.Entry{
+			ldap.NewEntry("cn=seals,ou=groups,dc=example,dc=com", map[string][]string{"cn": {"seals"}}),
+		},
+	}
+
+	tests := []struct {
+		name           string
+		providerYAML   string
+		bindSecretYAML string
+		args           []string
+		setupMocks     func(conn *mockldapconn.MockConn)
+		password       string
+		wantError      string
+		wantStdout     string
+	}{
+		{
+			name:       "missing required flags",
+			args:       []string{},
+			wantError:  `required flag(s) "bind-secret", "provider" not set`,
+			wantStdout: "",
+		},
+		{
+			name: "provider file contains the wrong kind",
+			providerYAML: `
+				apiVersion: v1
+				kind: ConfigMap
+			`,
+			bindSecretYAML: bindSecretYAML,
+			wantError:      `provider file "PROVIDER_PATH" must contain an LDAPIdentityProvider or an ActiveDirectoryIdentityProvider, but it contains kind "ConfigMap"`,
+		},
+		{
+			name: "provider file contains an unknown field",
+			providerYAML: `
+				apiVersion: idp.supervisor.pinniped.dev/v1alpha1
+				kind: LDAPIdentityProvider
+				spec:
+				  unknownField: some-value
+			`,
+			bindSecretYAML: bindSecretYAML,
+			wantError:      `could not decode provider file "PROVIDER_PATH": error unmarshaling JSON: while decoding JSON: json: unknown field "unknownField"`,
+		},
+		{
+			name:         "bind secret has the wrong type",
+			providerYAML: ldapProviderYAML,
+			bindSecretYAML: here.Doc(`
+				apiVersion: v1
+				kind: Secret
+				metadata:
+				  name: my-bind-secret
+				type: Opaque
+			`),
+			wantError: `BindSecretValid is False with reason SecretWrongType`,
+			wantStdout: here.Doc(`
+				Testing LDAPIdentityProvider "my-ldap-provider"
+				BindSecretValid: referenced Secret "my-bind-secret" has wrong type "Opaque" (should be "kubernetes.io/basic-auth")
+			`),
+		},
+		{
+			name:           "cannot connect to the LDAP server",
+			providerYAML:   ldapProviderYAML,
+			bindSecretYAML: bindSecretYAML,
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind("cn=admin,dc=example,dc=com", "some-bind-password").Return(errors.New("some bind error")).Times(2)
+				conn.EXPECT().Close().Times(2)
+			},
+			wantError: `LDAPConnectionValid is False with reason LDAPConnectionError`,
+			wantStdout: here.Doc(`
+				Testing LDAPIdentityProvider "my-ldap-provider"
+				BindSecretValid: loaded bind secret
+				TLSConfigurationValid: no TLS configuration provided
+				LDAPConnectionValid: could not successfully connect to "ldap.example.com" and bind as user "cn=admin,dc=example,dc=com": error binding as "cn=admin,dc=example,dc=com": some bind error
+			`),
+		},
+		{
+			name:           "connection is tested without a username",
+			providerYAML:   ldapProviderYAML,
+			bindSecretYAML: bindSecretYAML,
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind("cn=admin,dc=example,dc=com", "some-bind-password")
+				conn.EXPECT().Close()
+			},
+			wantStdout: here.Doc(`
+				Testing LDAPIdentityProvider "my-ldap-provider"
+				BindSecretValid: loaded bind secret
+				TLSConfigurationValid: no TLS configuration provided
+				LDAPConnectionValid: successfully able to connect to "ldap.example.com" and bind as user "cn=admin,dc=example,dc=com" [validated with Secret "my-bind-secret" at version ""]
+			`),
+		},
+		{
+			name:           "user is searched for without a password during a dry run",
+			providerYAML:   ldapProviderYAML,
+			bindSecretYAML: bindSecretYAML,
+			args:           []string{"--username", "pinny", "--dry-run"},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				gomock.InOrder(
+					conn.EXPECT().Bind("cn=admin,dc=example,dc=com", "some-bind-password"),
+					conn.EXPECT().Close(),
+					conn.EXPECT().Bind("cn=admin,dc=example,dc=com", "some-bind-password"),
+					conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+					conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(groupSearchResult, nil),
+					conn.EXPECT().Close(),
+				)
+			},
+			wantStdout: here.Doc(`
+				Testing LDAPIdentityProvider "my-ldap-provider"
+				BindSecretValid: loaded bind secret
+				TLSConfigurationValid: no TLS configuration provided
+				LDAPConnectionValid: successfully able to connect to "ldap.example.com" and bind as user "cn=admin,dc=example,dc=com" [validated with Secret "my-bind-secret" at version ""]
+
+				Searching for user "pinny" without logging in as the user
+				  LDAP search request:
+				    base: "ou=users,dc=example,dc=com"
+				    scope: Whole Subtree
+				    filter: "(uid=pinny)"
+				    attributes: ["mail" "uidNumber"]
+				  LDAP search result: 1 entries
+				    dn: "uid=pinny,ou=users,dc=example,dc=com"
+				      mail: "pinny@example.com"
+				      uidNumber: "1000"
+				  LDAP search request:
+				    base: "ou=groups,dc=example,dc=com"
+				    scope: Whole Subtree
+				    filter: "(member=uid=pinny,ou=users,dc=example,dc=com)"
+				    attributes: ["cn"]
+				  LDAP search result: 1 entries
+				    dn: "cn=seals,ou=groups,dc=example,dc=com"
+				      cn: "seals"
+				  DN: uid=pinny,ou=users,dc=example,dc=com
+				  username: pinny@example.com
+				  UID: MTAwMA
+				  groups: seals
+			`),
+		},
+		{
+			name:           "user cannot log in with an incorrect password",
+			providerYAML:   ldapProviderYAML,
+			bindSecretYAML: bindSecretYAML,
+			args:           []string{"--username", "pinny"},
+			password:       "some-user-password",
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				gomock.InOrder(
+					conn.EXPECT().Bind("cn=admin,dc=example,dc=com", "some-bind-password"),
+					conn.EXPECT().Close(),
+					conn.EXPECT().Bind("cn=admin,dc=example,dc=com", "some-bind-password"),
+					conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+					conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(groupSearchResult, nil),
+					conn.EXPECT().Close(),
+					conn.EXPECT().Bind("cn=admin,dc=example,dc=com", "some-bind-password"),
+					conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+					conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(groupSearchResult, nil),
+					conn.EXPECT().Bind("uid=pinny,ou=users,dc=example,dc=com", "some-user-password").
+						Return(ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("some bind error"))),
+					conn.EXPECT().Close(),
+				)
+			},
+			wantError: `the user was not found or the password was incorrect`,
+			wantStdout: here.Doc(`
+				Testing LDAPIdentityProvider "my-ldap-provider"
+				BindSecretValid: loaded bind secret
+				TLSConfigurationValid: no TLS configuration provided
+				LDAPConnectionValid: successfully able to connect to "ldap.example.com" and bind as user "cn=admin,dc=example,dc=com" [validated with Secret "my-bind-secret" at version ""]
+
+				Searching for user "pinny" without logging in as the user
+				  LDAP search request:
+				    base: "ou=users,dc=example,dc=com"
+				    scope: Whole Subtree
+				    filter: "(uid=pinny)"
+				    attributes: ["mail" "uidNumber"]
+				  LDAP search result: 1 entries
+				    dn: "uid=pinny,ou=users,dc=example,dc=com"
+				      mail: "pinny@example.com"
+				      uidNumber: "1000"
+				  LDAP search request:
+				    base: "ou=groups,dc=example,dc=com"
+				    scope: Whole Subtree
+				    filter: "(member=uid=pinny,ou=users,dc=example,dc=com)"
+				    attributes: ["cn"]
+				  LDAP search result: 1 entries
+				    dn: "cn=seals,ou=groups,dc=example,dc=com"
+				      cn: "seals"
+				  DN: uid=pinny,ou=users,dc=example,dc=com
+				  username: pinny@example.com
+				  UID: MTAwMA
+				  groups: seals
+
+				Logging in as user "pinny"
+				  LDAP search request:
+				    base: "ou=users,dc=example,dc=com"
+				    scope: Whole Subtree
+				    filter: "(uid=pinny)"
+				    attributes: ["mail" "uidNumber"]
+				  LDAP search result: 1 entries
+				    dn: "uid=pinny,ou=users,dc=example,dc=com"
+				      mail: "pinny@example.com"
+				      uidNumber: "1000"
+				  LDAP search request:
+				    base: "ou=groups,dc=example,dc=com"
+				    scope: Whole Subtree
+				    filter: "(member=uid=pinny,ou=users,dc=example,dc=com)"
+				    attributes: ["cn"]
+				  LDAP search result: 1 entries
+				    dn: "cn=seals,ou=groups,dc=example,dc=com"
+				      cn: "seals"
+			`),
+		},
+		{
+			name:           "user logs in with the correct password",
+			providerYAML:   ldapProviderYAML,
+			bindSecretYAML: bindSecretYAML,
+			args:           []string{"--username", "pinny"},
+			password:       "some-user-password",
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				gomock.InOrder(
+					conn.EXPECT().Bind("cn=admin,dc=example,dc=com", "some-bind-password"),
+					conn.EXPECT().Close(),
+					conn.EXPECT().Bind("cn=admin,dc=example,dc=com", "some-bind-password"),
+					conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+					conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(groupSearchResult, nil),
+					conn.EXPECT().Close(),
+					conn.EXPECT().Bind("cn=admin,dc=example,dc=com", "some-bind-password"),
+					conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+					conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(groupSearchResult, nil),
+					conn.EXPECT().Bind("uid=pinny,ou=users,dc=example,dc=com", "some-user-password"),
+					conn.EXPECT().Close(),
+				)
+			},
+			wantStdout: here.Doc(`
+				Testing LDAPIdentityProvider "my-ldap-provider"
+				BindSecretValid: loaded bind secret
+				TLSConfigurationValid: no TLS configuration provided
+				LDAPConnectionValid: successfully able to connect to "ldap.example.com" and bind as user "cn=admin,dc=example,dc=com" [validated with Secret "my-bind-secret" at version ""]
+
+				Searching for user "pinny" without logging in as the user
+				  LDAP search request:
+				    base: "ou=users,dc=example,dc=com"
+				    scope: Whole Subtree
+				    filter: "(uid=pinny)"
+				    attributes: ["mail" "uidNumber"]
+				  LDAP search result: 1 entries
+				    dn: "uid=pinny,ou=users,dc=example,dc=com"
+				      mail: "pinny@example.com"
+				      uidNumber: "1000"
+				  LDAP search request:
+				    base: "ou=groups,dc=example,dc=com"
+				    scope: Whole Subtree
+				    filter: "(member=uid=pinny,ou=users,dc=example,dc=com)"
+				    attributes: ["cn"]
+				  LDAP search result: 1 entries
+				    dn: "cn=seals,ou=groups,dc=example,dc=com"
+				      cn: "seals"
+				  DN: uid=pinny,ou=users,dc=example,dc=com
+				  username: pinny@example.com
+				  UID: MTAwMA
+				  groups: seals
+
+				Logging in as user "pinny"
+				  LDAP search request:
+				    base: "ou=users,dc=example,dc=com"
+				    scope: Whole Subtree
+				    filter: "(uid=pinny)"
+				    attributes: ["mail" "uidNumber"]
+				  LDAP search result: 1 entries
+				    dn: "uid=pinny,ou=users,dc=example,dc=com"
+				      mail: "pinny@example.com"
+				      uidNumber: "1000"
+				  LDAP search request:
+				    base: "ou=groups,dc=example,dc=com"
+				    scope: Whole Subtree
+				    filter: "(member=uid=pinny,ou=users,dc=example,dc=com)"
+				    attributes: ["cn"]
+				  LDAP search result: 1 entries
+				    dn: "cn=seals,ou=groups,dc=example,dc=com"
+				      cn: "seals"
+				  DN: uid=pinny,ou=users,dc=example,dc=com
+				  username: pinny@example.com
+				  UID: MTAwMA
+				  groups: seals
+			`),
+		},
+		{
+			name:           "active directory search base is read from the RootDSE",
+			providerYAML:   activeDirectoryProviderYAML,
+			bindSecretYAML: bindSecretYAML,
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				gomock.InOrder(
+					conn.EXPECT().Bind("cn=admin,dc=example,dc=com", "some-bind-password"),
+					conn.EXPECT().Close(),
+					conn.EXPECT().Bind("cn=admin,dc=example,dc=com", "some-bind-password"),
+					conn.EXPECT().Search(gomock.Any()).Return(&ldap.SearchResult{
+						Entries: []*ldap.Entry{
+							ldap.NewEntry("", map[string][]string{"defaultNamingContext": {"dc=example,dc=com"}}),
+						},
+					}, nil),
+					conn.EXPECT().Close(),
+				)
+			},
+			wantStdout: here.Doc(`
+				Testing ActiveDirectoryIdentityProvider "my-ad-provider"
+				BindSecretValid: loaded bind secret
+				TLSConfigurationValid: no TLS configuration provided
+				LDAPConnectionValid: successfully able to connect to "ad.example.com" and bind as user "cn=admin,dc=example,dc=com" [validated with Secret "my-bind-secret" at version ""]
+
+				Searching for the defaultNamingContext to use as the default search base
+				  LDAP search request:
+				    base: ""
+				    scope: Base Object
+				    filter: "(objectClass=*)"
+				    attributes: ["defaultNamingContext"]
+				  LDAP search result: 1 entries
+				    dn: ""
+				      defaultNamingContext: "dc=example,dc=com"
+				Using "dc=example,dc=com" as the default search base
+			`),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			t.Cleanup(ctrl.Finish)
+			conn := mockldapconn.NewMockConn(ctrl)
+			if tt.setupMocks != nil {
+				tt.setupMocks(conn)
+			}
+
+			tmpdir := testutil.TempDir(t)
+			providerPath := filepath.Join(tmpdir, "provider.yaml")
+			bindSecretPath := filepath.Join(tmpdir, "bind-secret.yaml")
+			args := tt.args
+			if tt.providerYAML != "" {
+				require.NoError(t, ioutil.WriteFile(providerPath, []byte(here.Doc(tt.providerYAML)), 0600))
+				require.NoError(t, ioutil.WriteFile(bindSecretPath, []byte(here.Doc(tt.bindSecretYAML)), 0600))
+				args = append([]string{"--provider", providerPath, "--bind-secret", bindSecretPath}, args...)
+			}
+
+			cmd := testLDAPCommand(testLDAPDeps{
+				dialer: upstreamldap.LDAPDialerFunc(func(ctx context.Context, addr endpointaddr.HostPort) (upstreamldap.Conn, error) {
+					return conn, nil
+				}),
+				promptForSecret: func(promptLabel string) (string, error) {
+					require.Equal(t, `Password for "pinny": `, promptLabel)
+					return tt.password, nil
+				},
+			})
+			require.NotNil(t, cmd)
+
+			var stdout, stderr bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			cmd.SetArgs(args)
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantError != "" {
+				require.EqualError(t, err, strings.ReplaceAll(tt.wantError, "PROVIDER_PATH", providerPath))
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantStdout, stdout.String())
+		})
+	}
+}
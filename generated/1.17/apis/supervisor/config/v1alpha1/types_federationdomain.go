@@ -8,7 +8,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// +kubebuilder:validation:Enum=Success;Duplicate;Invalid;SameIssuerHostMustUseSameSecret
+// +kubebuilder:validation:Enum=Success;Duplicate;Invalid;SameIssuerHostMustUseSameSecret;IntrospectionInvalid
 type FederationDomainStatusCondition string
 
 const (
@@ -16,6 +16,10 @@ const (
 	DuplicateFederationDomainStatusCondition                       = FederationDomainStatusCondition("Duplicate")
 	SameIssuerHostMustUseSameSecretFederationDomainStatusCondition = FederationDomainStatusCondition("SameIssuerHostMustUseSameSecret")
 	InvalidFederationDomainStatusCondition                         = FederationDomainStatusCondition("Invalid")
+
+	// IntrospectionInvalidFederationDomainStatusCondition means that the FederationDomain is served, but its
+	// introspection endpoint denies every request, because the introspection clients could not be loaded.
+	IntrospectionInvalidFederationDomainStatusCondition = FederationDomainStatusCondition("IntrospectionInvalid")
 )
 
 // FederationDomainTLSSpec is a struct that describes the TLS configuration for an OIDC Provider.
@@ -42,6 +46,245 @@ type FederationDomainTLSSpec struct {
 	SecretName string `json:"secretName,omitempty"`
 }
 
+// FederationDomainAllowedAudience describes an audience which may be requested using the token exchange.
+type FederationDomainAllowedAudience struct {
+	// Name is the audience which may be requested, e.g. the audience which is configured in the JWTAuthenticator
+	// of a workload cluster.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Groups restricts which users may request the audience to the users who belong to at least one of these
+	// downstream groups.
+	// Optional. When not specified, any user may request the audience.
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+}
+
+// FederationDomainTokenExchangeSpec describes the policy of the RFC 8693 token exchange, which clients use to
+// exchange a user's access token for an ID token with a different audience, i.e. for a specific workload cluster.
+type FederationDomainTokenExchangeSpec struct {
+	// AllowedAudiences lists the only audiences which may be requested using the token exchange. Requests for
+	// any other audience are denied. When the list is empty, the token exchange is denied for every audience.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	AllowedAudiences []FederationDomainAllowedAudience `json:"allowedAudiences,omitempty"`
+}
+
+// FederationDomainIntrospectionClient is a resource server which may call the RFC 7662 token introspection
+// endpoint of a FederationDomain.
+type FederationDomainIntrospectionClient struct {
+	// SecretName is the name of a Secret in the same namespace as the FederationDomain, which holds the credentials
+	// with which the resource server authenticates to the introspection endpoint using HTTP basic authentication.
+	// The Secret must be of type "secrets.pinniped.dev/oidc-client" and it must contain the "clientID" and
+	// "clientSecret" keys.
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+}
+
+// FederationDomainIntrospectionSpec configures the RFC 7662 token introspection endpoint, which resource servers
+// use to validate the access tokens issued by a FederationDomain.
+type FederationDomainIntrospectionSpec struct {
+	// Clients lists the resource servers which may call the introspection endpoint. Requests from any other
+	// client are denied.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=secretName
+	Clients []FederationDomainIntrospectionClient `json:"clients"`
+}
+
+// FederationDomainLogoutSpec configures the OIDC RP-initiated logout endpoint of a FederationDomain, and which
+// applications are notified when a user logs out.
+type FederationDomainLogoutSpec struct {
+	// PostLogoutRedirectURIs lists the URIs to which the browser may be redirected after a logout, when they are
+	// requested using the post_logout_redirect_uri parameter. The redirect URIs of the client which logs out are
+	// always allowed.
+	// +optional
+	PostLogoutRedirectURIs []string `json:"postLogoutRedirectURIs,omitempty"`
+
+	// FrontChannelLogoutURIs lists the URIs of applications which are loaded in hidden iframes of the logout page,
+	// with the "iss" and "sid" query parameters, as described by OpenID Connect Front-Channel Logout 1.0.
+	// +optional
+	FrontChannelLogoutURIs []string `json:"frontChannelLogoutURIs,omitempty"`
+
+	// BackChannelLogoutClients lists the applications to which a logout token is POSTed when a user logs out, as
+	// described by OpenID Connect Back-Channel Logout 1.0. Each application is sent its own logout token, whose only
+	// audience is the client ID of the application.
+	// +optional
+	BackChannelLogoutClients []FederationDomainBackChannelLogoutClient `json:"backChannelLogoutClients,omitempty"`
+
+	// UpstreamLogout, when true, redirects the browser to the end_session_endpoint of the upstream OIDC identity
+	// provider after the logout, when the provider offers one, so that the user is also logged out of it.
+	// +optional
+	UpstreamLogout bool `json:"upstreamLogout,omitempty"`
+}
+
+// FederationDomainBackChannelLogoutClient is an application which is sent a logout token when a user logs out.
+type FederationDomainBackChannelLogoutClient struct {
+	// ClientID is the client ID of the application, which is the audience of the logout tokens which are sent to it.
+	// +kubebuilder:validation:MinLength=1
+	ClientID string `json:"clientID"`
+
+	// URI is the back-channel logout URI of the application, to which its logout tokens are POSTed.
+	// +kubebuilder:validation:MinLength=1
+	URI string `json:"uri"`
+}
+
+// FederationDomainRequestObjectsSpec configures the signed request objects, as described by RFC 9101 (JAR), which
+// clients may send to the authorization and pushed authorization request endpoints of a FederationDomain.
+type FederationDomainRequestObjectsSpec struct {
+	// Clients lists the clients which may send signed request objects, each with the public keys which verify its
+	// request objects. Only these clients may use the pushed authorization request endpoint, which requires a signed
+	// request object to authenticate the client.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=clientID
+	Clients []FederationDomainRequestObjectClient `json:"clients"`
+}
+
+// FederationDomainRequestObjectClient is a client which may send signed request objects.
+type FederationDomainRequestObjectClient struct {
+	// ClientID is the client ID of the client, which must be the iss claim of its request objects. It must be the ID
+	// of a client of the Supervisor, so currently only "pinniped-cli" is supported.
+	// +kubebuilder:validation:MinLength=1
+	ClientID string `json:"clientID"`
+
+	// JWKS is a JSON Web Key Set document which contains the public keys of the client. A request object of the
+	// client must be signed by one of these keys, using an RSA, ECDSA, or EdDSA algorithm.
+	// +kubebuilder:validation:MinLength=1
+	JWKS string `json:"jwks"`
+}
+
+// FederationDomainTokensSpec configures the lifetimes of the tokens which are issued by a FederationDomain.
+type FederationDomainTokensSpec struct {
+	// AccessTokenLifetimeSeconds is how long the access tokens issued to clients are valid. Access tokens are
+	// used by clients to perform the token exchange for cluster-scoped ID tokens.
+	// Optional. When not specified, the default is 120 (2 minutes).
+	// +kubebuilder:validation:Minimum=60
+	// +kubebuilder:validation:Maximum=3600
+	// +optional
+	AccessTokenLifetimeSeconds int32 `json:"accessTokenLifetimeSeconds,omitempty"`
+
+	// IDTokenLifetimeSeconds is how long the ID tokens issued to clients are valid, including the cluster-scoped
+	// ID tokens which are issued by the token exchange.
+	// Optional. When not specified, the default is the same as the access token lifetime.
+	// +kubebuilder:validation:Minimum=60
+	// +kubebuilder:validation:Maximum=3600
+	// +optional
+	IDTokenLifetimeSeconds int32 `json:"idTokenLifetimeSeconds,omitempty"`
+
+	// RefreshTokenLifetimeSeconds is how long each refresh token is valid. Each refresh issues a new refresh token,
+	// so a session may continue for longer than this, unless a maximum session lifetime is also configured.
+	// This must be longer than the access token lifetime.
+	// Optional. When not specified, the default is 32400 (9 hours).
+	// +kubebuilder:validation:Minimum=300
+	// +kubebuilder:validation:Maximum=2592000
+	// +optional
+	RefreshTokenLifetimeSeconds int32 `json:"refreshTokenLifetimeSeconds,omitempty"`
+
+	// AuthorizationCodeLifetimeSeconds is how long the authorization codes issued to clients may be redeemed.
+	// Optional. When not specified, the default is 600 (10 minutes).
+	// +kubebuilder:validation:Minimum=60
+	// +kubebuilder:validation:Maximum=3600
+	// +optional
+	AuthorizationCodeLifetimeSeconds int32 `json:"authorizationCodeLifetimeSeconds,omitempty"`
+
+	// MaxSessionLifetimeSeconds is the absolute maximum length of a session, measured from the time when the user
+	// logged in. Refreshes are denied after this time, so the user must log in again. This must not be shorter
+	// than the access token lifetime.
+	// Optional. When not specified, sessions may be refreshed indefinitely.
+	// +kubebuilder:validation:Minimum=300
+	// +optional
+	MaxSessionLifetimeSeconds int32 `json:"maxSessionLifetimeSeconds,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=ES256;RS256;EdDSA
+type FederationDomainSigningAlgorithm string
+
+const (
+	ES256FederationDomainSigningAlgorithm = FederationDomainSigningAlgorithm("ES256")
+	RS256FederationDomainSigningAlgorithm = FederationDomainSigningAlgorithm("RS256")
+	EdDSAFederationDomainSigningAlgorithm = FederationDomainSigningAlgorithm("EdDSA")
+)
+
+// FederationDomainKeyRotationSpec describes the schedule on which the keys which sign the tokens issued by a
+// FederationDomain are replaced.
+type FederationDomainKeyRotationSpec struct {
+	// IntervalSeconds is how long each signing key is used to sign tokens before it is replaced by a new key.
+	// +kubebuilder:validation:Minimum=7200
+	IntervalSeconds int32 `json:"intervalSeconds"`
+
+	// GracePeriodSeconds is how long the next signing key is published in the JWKS before it starts to be used to
+	// sign tokens, which gives clients that cache the JWKS a chance to fetch the new key. This must be shorter than
+	// the interval.
+	// Optional. When not specified, the default is 3600 (1 hour).
+	// +kubebuilder:validation:Minimum=60
+	// +optional
+	GracePeriodSeconds int32 `json:"gracePeriodSeconds,omitempty"`
+}
+
+// FederationDomainExternalSignerSpec describes a process outside of the Supervisor which signs the tokens issued by
+// a FederationDomain, e.g. using a hardware security module.
+type FederationDomainExternalSignerSpec struct {
+	// URL is the endpoint of the signer. For each token, the Supervisor POSTs a JSON object with the "kid" and "alg"
+	// of the active JWK and the base64url encoded JWS signing input as "payload". The signer must respond with a JSON
+	// object whose "signature" is the base64url encoded JWS signature. The URL must use https, unless the signer
+	// listens on a loopback address, e.g. in a sidecar container of the Supervisor, in which case http may be used.
+	// Only signers which are reached using HTTP are supported.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+
+	// CertificateAuthorityData is a base64 encoded PEM bundle of the certificate authorities which are trusted to
+	// issue the signer's serving certificate when the URL uses https.
+	// Optional. When not specified, the system's trusted certificate authorities are used.
+	// +optional
+	CertificateAuthorityData string `json:"certificateAuthorityData,omitempty"`
+
+	// CredentialsSecretName is the name of a Secret in the same namespace which contains the credentials with which
+	// the Supervisor authenticates to the signer. A Secret of type "kubernetes.io/tls" contains a client certificate
+	// and its private key in its "tls.crt" and "tls.key" keys, which are presented during the TLS handshake, so it
+	// requires an https URL. A Secret of type "secrets.pinniped.dev/external-signer-token" contains a bearer token in
+	// its "token" key, which is sent in the Authorization header of each request.
+	// +kubebuilder:validation:MinLength=1
+	CredentialsSecretName string `json:"credentialsSecretName"`
+}
+
+// FederationDomainSigningSpec configures the keys which sign the tokens issued by a FederationDomain.
+type FederationDomainSigningSpec struct {
+	// Algorithm is the JWS algorithm of the keys which sign the ID tokens issued by this FederationDomain. Either
+	// "ES256", "RS256", or "EdDSA". When the algorithm is changed, a new key which uses that algorithm is published
+	// in the JWKS and it replaces the active signing key after the grace period of the key rotation. Note that the
+	// Concierge's JWTAuthenticator and the pinniped CLI cannot verify tokens which are signed using EdDSA.
+	// Optional. When not specified, the default is "ES256".
+	// +kubebuilder:default=ES256
+	// +optional
+	Algorithm FederationDomainSigningAlgorithm `json:"algorithm,omitempty"`
+
+	// KeyRotation configures the scheduled replacement of the signing key. Before each replacement, the next key
+	// is published in the JWKS for a grace period. After each replacement, the previous key remains in the JWKS
+	// until all the tokens which it signed have expired.
+	// Optional. When not specified, the signing key is only replaced when the algorithm is changed.
+	// +optional
+	KeyRotation *FederationDomainKeyRotationSpec `json:"keyRotation,omitempty"`
+
+	// SecretName is the name of a Secret in the same namespace which contains the signing keys of this
+	// FederationDomain, for when the keys are managed outside of the Supervisor. The Secret must be of type
+	// "secrets.pinniped.dev/federation-domain-jwks". Its "activeJWK" key must contain the JSON of the private JWK which
+	// signs the tokens, including its "kid" and "alg", and its "jwks" key must contain the JSON of the public JWKS,
+	// which must include the public key of the active JWK. The Supervisor never modifies this Secret, so KeyRotation
+	// cannot be used with it and Algorithm is ignored. Instead, rotate the keys by updating the Secret.
+	// Optional. When not specified, the Supervisor generates the signing keys.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// ExternalSigner delegates signing to a process outside of the Supervisor, so that the private key is never
+	// stored in the cluster. It requires SecretName, and the "activeJWK" in the Secret must be the public JWK of the
+	// key which is used by the signer.
+	// Optional. When not specified, the Supervisor signs the tokens itself.
+	// +optional
+	ExternalSigner *FederationDomainExternalSignerSpec `json:"externalSigner,omitempty"`
+}
+
 // FederationDomainSpec is a struct that describes an OIDC Provider.
 type FederationDomainSpec struct {
 	// Issuer is the OIDC Provider's issuer, per the OIDC Discovery Metadata document, as well as the
@@ -58,6 +301,41 @@ type FederationDomainSpec struct {
 	// TLS configures how this FederationDomain is served over Transport Layer Security (TLS).
 	// +optional
 	TLS *FederationDomainTLSSpec `json:"tls,omitempty"`
+
+	// TokenExchange restricts which audiences may be requested using the RFC 8693 token exchange.
+	// Optional. When not specified, any audience may be requested by any user.
+	// +optional
+	TokenExchange *FederationDomainTokenExchangeSpec `json:"tokenExchange,omitempty"`
+
+	// Tokens configures the lifetimes of the tokens which are issued by this FederationDomain.
+	// Optional. When not specified, the default lifetimes are used.
+	// +optional
+	Tokens *FederationDomainTokensSpec `json:"tokens,omitempty"`
+
+	// Signing configures the algorithm and the rotation of the keys which sign the tokens issued by this
+	// FederationDomain.
+	// Optional. When not specified, ES256 keys are used and they are not rotated on a schedule.
+	// +optional
+	Signing *FederationDomainSigningSpec `json:"signing,omitempty"`
+
+	// Introspection configures which resource servers may use the RFC 7662 token introspection endpoint to
+	// validate the access tokens issued by this FederationDomain.
+	// Optional. When not specified, every request to the introspection endpoint is denied.
+	// +optional
+	Introspection *FederationDomainIntrospectionSpec `json:"introspection,omitempty"`
+
+	// Logout configures the OIDC RP-initiated logout endpoint of this FederationDomain.
+	// Optional. When not specified, a logout only ends the user's session and redirects to the redirect URIs of the
+	// client.
+	// +optional
+	Logout *FederationDomainLogoutSpec `json:"logout,omitempty"`
+
+	// RequestObjects configures the signed request objects (JAR, RFC 9101) which clients may use to send the
+	// parameters of an authorization request, either to the authorization endpoint or to the pushed authorization
+	// request endpoint.
+	// Optional. When not specified, request objects are rejected.
+	// +optional
+	RequestObjects *FederationDomainRequestObjectsSpec `json:"requestObjects,omitempty"`
 }
 
 // FederationDomainSecrets holds information about this OIDC Provider's secrets.
@@ -0,0 +1,19 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+// BindMethod decides how the bind account of an identity provider authenticates to the LDAP server.
+// +kubebuilder:validation:Enum=Simple;SASLExternal
+type BindMethod string
+
+const (
+	// BindMethodSimple performs a simple bind using the username and password from a Secret of type
+	// "kubernetes.io/basic-auth".
+	BindMethodSimple BindMethod = "Simple"
+
+	// BindMethodSASLExternal presents the client certificate from a Secret of type "kubernetes.io/tls" during
+	// the TLS handshake, and then performs a SASL EXTERNAL bind, which asks the LDAP server to use the identity
+	// that was established by the client certificate.
+	BindMethodSASLExternal BindMethod = "SASLExternal"
+)
@@ -40,9 +40,19 @@ type LDAPIdentityProviderBind struct {
 	// password for an LDAP bind user. This account will be used to perform LDAP searches. The Secret should be
 	// of type "kubernetes.io/basic-auth" which includes "username" and "password" keys. The username value
 	// should be the full dn (distinguished name) of your bind account, e.g. "cn=bind-account,ou=users,dc=example,dc=com".
-	// The password must be non-empty.
+	// The password must be non-empty. When the Method is "SASLExternal", the Secret should instead be of type
+	// "kubernetes.io/tls" which includes "tls.crt" and "tls.key" keys, containing the client certificate and private key
+	// of your bind account. Changes to the Secret are picked up without restarting the Supervisor.
 	// +kubebuilder:validation:MinLength=1
 	SecretName string `json:"secretName"`
+
+	// Method decides how the bind account authenticates to the LDAP server. Either "Simple", to bind using the
+	// username and password from the Secret, or "SASLExternal", to present the client certificate from the Secret
+	// when establishing the TLS connection and then bind using the identity of that certificate.
+	// Optional. When not specified, the default is "Simple".
+	// +kubebuilder:default=Simple
+	// +optional
+	Method BindMethod `json:"method,omitempty"`
 }
 
 type LDAPIdentityProviderUserSearchAttributes struct {
@@ -62,6 +72,15 @@ type LDAPIdentityProviderUserSearchAttributes struct {
 	// server in the user's entry. Distinguished names can be used by specifying lower-case "dn".
 	// +kubebuilder:validation:MinLength=1
 	UID string `json:"uid,omitempty"`
+
+	// AdditionalClaims maps attributes of the user's LDAP entry to additional claims in the ID tokens issued by the
+	// Supervisor, e.g. to provide the user's email address and display name to applications. The values of the
+	// attributes are read again during each refresh of the user's session.
+	// Optional. When not specified, no additional claims are included in the ID tokens.
+	// +listType=map
+	// +listMapKey=claim
+	// +optional
+	AdditionalClaims []AdditionalClaimMapping `json:"additionalClaims,omitempty"`
 }
 
 type LDAPIdentityProviderGroupSearchAttributes struct {
@@ -97,6 +116,18 @@ type LDAPIdentityProviderUserSearch struct {
 	Attributes LDAPIdentityProviderUserSearchAttributes `json:"attributes,omitempty"`
 }
 
+type LDAPIdentityProviderNestedGroupSearch struct {
+	// MaxDepth is the maximum number of times that the group search is repeated to find the groups of the
+	// groups that were already found. E.g. a MaxDepth of 1 means that the user will belong to their direct
+	// groups and to the groups of which their direct groups are members. Each group is only searched once,
+	// so cycles in the group memberships are allowed.
+	// Optional. When not specified, the default is 10.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxDepth int32 `json:"maxDepth,omitempty"`
+}
+
 type LDAPIdentityProviderGroupSearch struct {
 	// Base is the dn (distinguished name) that should be used as the search base when searching for groups. E.g.
 	// "ou=groups,dc=example,dc=com". When not specified, no group search will be performed and
@@ -120,6 +151,22 @@ type LDAPIdentityProviderGroupSearch struct {
 	// +optional
 	Attributes LDAPIdentityProviderGroupSearchAttributes `json:"attributes,omitempty"`
 
+	// Nested specifies that the user should also belong to the groups of which their groups are members,
+	// i.e. nested groups. When specified, the group search is repeated for each group that was found, with
+	// the pattern "{}" in the Filter replaced by the dn (distinguished name) of that group instead of the user.
+	// Optional. When not specified, the user will only belong to the groups of which they are directly a member.
+	// +optional
+	Nested *LDAPIdentityProviderNestedGroupSearch `json:"nested,omitempty"`
+
+	// Cache specifies that the groups found for a user should be remembered for a while, so the group search
+	// can be skipped when the user's session is refreshed. Logins always perform the group search. Cached
+	// groups are discarded when the group search settings change. This reduces the load on the LDAP provider
+	// when many sessions are refreshed, at the cost of group membership changes taking up to the TTLSeconds
+	// to be noticed by refreshes. Ignored when skipGroupRefresh is true.
+	// Optional. When not specified, the groups are not cached.
+	// +optional
+	Cache *GroupSearchCacheSpec `json:"cache,omitempty"`
+
 	// The user's group membership is refreshed as they interact with the supervisor
 	// to obtain new credentials (as their old credentials expire).  This allows group
 	// membership changes to be quickly reflected into Kubernetes clusters.  Since
@@ -146,13 +193,77 @@ type LDAPIdentityProviderGroupSearch struct {
 	SkipGroupRefresh bool `json:"skipGroupRefresh,omitempty"`
 }
 
+// LDAPIdentityProviderAccountStatus configures how to decide if a user's account may be used to log in, and when
+// to warn the user that their password will expire soon. The attributes are read from the user's entry during each
+// login and each refresh of the user's session.
+type LDAPIdentityProviderAccountStatus struct {
+	// DisabledAttribute is the name of an attribute of the user's entry which marks the user's account as disabled or
+	// locked when it has one of the DisabledValues. E.g. "nsAccountLock" for 389 Directory Server and FreeIPA.
+	// Optional. When not specified, the accounts will not be checked for being disabled.
+	// +optional
+	DisabledAttribute string `json:"disabledAttribute,omitempty"`
+
+	// DisabledValues are the values of the DisabledAttribute which mean that the account is disabled. The values are
+	// compared case-insensitively.
+	// Optional. When not specified, the default is "TRUE".
+	// +optional
+	DisabledValues []string `json:"disabledValues,omitempty"`
+
+	// AccountExpirationAttribute is the name of an attribute of the user's entry which contains the time at which the
+	// user's account expires, in LDAP Generalized Time format. E.g. "krbPrincipalExpiration" for FreeIPA.
+	// Optional. When not specified, the accounts will not be checked for being expired.
+	// +optional
+	AccountExpirationAttribute string `json:"accountExpirationAttribute,omitempty"`
+
+	// PasswordExpirationAttribute is the name of an attribute of the user's entry which contains the time at which the
+	// user's password expires, in LDAP Generalized Time format. E.g. "krbPasswordExpiration" for FreeIPA. Users whose
+	// password has expired will not be allowed to log in, and will be warned when their password will expire soon.
+	// Optional. When not specified, the passwords will not be checked for being expired.
+	// +optional
+	PasswordExpirationAttribute string `json:"passwordExpirationAttribute,omitempty"`
+
+	// PasswordExpirationWarningDays is how many days before their password expires a user will start to be warned
+	// about it when they log in.
+	// Optional. When not specified, the default is 14.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PasswordExpirationWarningDays int32 `json:"passwordExpirationWarningDays,omitempty"`
+
+	// PasswordPolicyControl, when true, requests the password policy response control
+	// (draft-behera-ldap-password-policy) from the LDAP server when binding as the user during login. Users whose
+	// account is locked or whose password must be changed will not be allowed to log in, and users will be warned
+	// when the server reports that their password will expire soon or that they are using a grace login. Also, users
+	// whose entry has a "pwdAccountLockedTime" attribute will be treated as locked.
+	// Optional. When not specified, the default is false.
+	// +optional
+	PasswordPolicyControl bool `json:"passwordPolicyControl,omitempty"`
+}
+
 // Spec for configuring an LDAP identity provider.
 type LDAPIdentityProviderSpec struct {
 	// Host is the hostname of this LDAP identity provider, i.e., where to connect. For example: ldap.example.com:636.
-	// +kubebuilder:validation:MinLength=1
-	Host string `json:"host"`
+	// Host is also used to identify this identity provider in the downstream identities of its users, so it should
+	// not be changed when adding or removing other hosts. Host may only be omitted when HostDiscovery is specified.
+	// +optional
+	Host string `json:"host,omitempty"`
 
-	// TLS contains the connection settings for how to establish the connection to the Host.
+	// AdditionalHosts are the hostnames of other replicas of this LDAP identity provider which serve the same
+	// directory as the Host. For example: ldap2.example.com:636. These hosts are used when the Host cannot be reached.
+	// +optional
+	AdditionalHosts []string `json:"additionalHosts,omitempty"`
+
+	// HostDiscovery configures looking up more hosts of this LDAP identity provider using DNS SRV records.
+	// The discovered hosts are used after the Host and the AdditionalHosts.
+	// +optional
+	HostDiscovery *HostDiscoverySpec `json:"hostDiscovery,omitempty"`
+
+	// HostSelection decides which host is used first when there is more than one host. Hosts which recently could
+	// not be reached are only used when all other hosts also cannot be reached.
+	// Optional. When not specified, the default is "Failover".
+	// +optional
+	HostSelection HostSelectionPolicy `json:"hostSelection,omitempty"`
+
+	// TLS contains the connection settings for how to establish the connection to the hosts.
 	TLS *TLSSpec `json:"tls,omitempty"`
 
 	// Bind contains the configuration for how to provide access credentials during an initial bind to the LDAP server
@@ -164,6 +275,11 @@ type LDAPIdentityProviderSpec struct {
 
 	// GroupSearch contains the configuration for searching for a user's group membership in the LDAP provider.
 	GroupSearch LDAPIdentityProviderGroupSearch `json:"groupSearch,omitempty"`
+
+	// AccountStatus contains the configuration for checking that a user's account may be used to log in, and for
+	// warning users about their upcoming password expiration.
+	// +optional
+	AccountStatus LDAPIdentityProviderAccountStatus `json:"accountStatus,omitempty"`
 }
 
 // LDAPIdentityProvider describes the configuration of an upstream Lightweight Directory Access
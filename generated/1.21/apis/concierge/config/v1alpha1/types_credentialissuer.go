@@ -8,7 +8,7 @@ import (
 )
 
 // StrategyType enumerates a type of "strategy" used to implement credential access on a cluster.
-// +kubebuilder:validation:Enum=KubeClusterSigningCertificate;ImpersonationProxy
+// +kubebuilder:validation:Enum=KubeClusterSigningCertificate;KubeCertificateSigningRequest;ImpersonationProxy
 type StrategyType string
 
 // FrontendType enumerates a type of "frontend" used to provide access to users of a cluster.
@@ -20,11 +20,12 @@ type FrontendType string
 type StrategyStatus string
 
 // StrategyReason enumerates the detailed reason why a strategy is in a particular status.
-// +kubebuilder:validation:Enum=Listening;Pending;Disabled;ErrorDuringSetup;CouldNotFetchKey;CouldNotGetClusterInfo;FetchedKey
+// +kubebuilder:validation:Enum=Listening;Pending;Disabled;ErrorDuringSetup;CouldNotFetchKey;CouldNotGetClusterInfo;FetchedKey;SignerConfigured;SignerNotIssuing
 type StrategyReason string
 
 const (
 	KubeClusterSigningCertificateStrategyType = StrategyType("KubeClusterSigningCertificate")
+	KubeCertificateSigningRequestStrategyType = StrategyType("KubeCertificateSigningRequest")
 	ImpersonationProxyStrategyType            = StrategyType("ImpersonationProxy")
 
 	TokenCredentialRequestAPIFrontendType = FrontendType("TokenCredentialRequestAPI")
@@ -40,12 +41,50 @@ const (
 	CouldNotFetchKeyStrategyReason       = StrategyReason("CouldNotFetchKey")
 	CouldNotGetClusterInfoStrategyReason = StrategyReason("CouldNotGetClusterInfo")
 	FetchedKeyStrategyReason             = StrategyReason("FetchedKey")
+	SignerConfiguredStrategyReason       = StrategyReason("SignerConfigured")
+	SignerNotIssuingStrategyReason       = StrategyReason("SignerNotIssuing")
 )
 
 // CredentialIssuerSpec describes the intended configuration of the Concierge.
 type CredentialIssuerSpec struct {
 	// ImpersonationProxy describes the intended configuration of the Concierge impersonation proxy.
 	ImpersonationProxy *ImpersonationProxySpec `json:"impersonationProxy"`
+
+	// Frontends optionally restricts which authenticators may be used through each frontend. For example, a cluster
+	// could allow only one JWTAuthenticator to be used through the impersonation proxy while allowing a different
+	// JWTAuthenticator to be used through the TokenCredentialRequest API. A frontend which is not listed here
+	// allows any authenticator to be used through it.
+	//
+	// Note that this restricts which authenticators may be used to obtain cluster credentials through each
+	// frontend. It does not restrict where the resulting cluster credentials may be used.
+	//
+	// +optional
+	Frontends []CredentialIssuerFrontendSpec `json:"frontends,omitempty"`
+}
+
+// CredentialIssuerFrontendSpec describes the intended configuration of a single frontend of the Concierge.
+type CredentialIssuerFrontendSpec struct {
+	// Type of the frontend being configured.
+	Type FrontendType `json:"type"`
+
+	// AllowedAuthenticators lists the authenticators which may be used through this frontend.
+	// When empty, any authenticator may be used through this frontend.
+	//
+	// +optional
+	AllowedAuthenticators []AuthenticatorReference `json:"allowedAuthenticators,omitempty"`
+}
+
+// AuthenticatorReference refers to a Concierge authenticator, such as a JWTAuthenticator or a WebhookAuthenticator.
+type AuthenticatorReference struct {
+	// Kind of the authenticator.
+	//
+	// +kubebuilder:validation:Enum=JWTAuthenticator;WebhookAuthenticator
+	Kind string `json:"kind"`
+
+	// Name of the authenticator.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
 }
 
 // ImpersonationProxyMode enumerates the configuration modes for the impersonation proxy.
@@ -80,6 +119,23 @@ const (
 	ImpersonationProxyServiceTypeNone = ImpersonationProxyServiceType("None")
 )
 
+// ImpersonationProxyServiceAccountTokensMode enumerates the ways in which the impersonation proxy may handle
+// Kubernetes service account tokens.
+//
+// +kubebuilder:validation:Enum=enabled;boundOnly;disabled
+type ImpersonationProxyServiceAccountTokensMode string
+
+const (
+	// ImpersonationProxyServiceAccountTokensModeEnabled accepts all valid service account tokens.
+	ImpersonationProxyServiceAccountTokensModeEnabled = ImpersonationProxyServiceAccountTokensMode("enabled")
+
+	// ImpersonationProxyServiceAccountTokensModeBoundOnly accepts only valid bound service account tokens.
+	ImpersonationProxyServiceAccountTokensModeBoundOnly = ImpersonationProxyServiceAccountTokensMode("boundOnly")
+
+	// ImpersonationProxyServiceAccountTokensModeDisabled rejects all service account tokens.
+	ImpersonationProxyServiceAccountTokensModeDisabled = ImpersonationProxyServiceAccountTokensMode("disabled")
+)
+
 // ImpersonationProxySpec describes the intended configuration of the Concierge impersonation proxy.
 type ImpersonationProxySpec struct {
 	// Mode configures whether the impersonation proxy should be started:
@@ -100,6 +156,80 @@ type ImpersonationProxySpec struct {
 	//
 	// +optional
 	ExternalEndpoint string `json:"externalEndpoint,omitempty"`
+
+	// Limits describes the rate limits and concurrency limits which the impersonation proxy enforces on
+	// incoming requests. Requests which exceed a limit are rejected with a 429 (Too Many Requests) response
+	// which includes a Retry-After header. When not set, no limits are enforced.
+	//
+	// +optional
+	Limits *ImpersonationProxyLimitsSpec `json:"limits,omitempty"`
+
+	// ServiceAccountTokens describes how the impersonation proxy handles clients which authenticate using
+	// Kubernetes service account tokens, such as in-cluster workloads. These tokens are validated using the
+	// TokenReview API and are then passed through to the Kubernetes API server unchanged.
+	// When not set, all valid service account tokens are accepted.
+	//
+	// +optional
+	ServiceAccountTokens *ImpersonationProxyServiceAccountTokensSpec `json:"serviceAccountTokens,omitempty"`
+}
+
+// ImpersonationProxyServiceAccountTokensSpec describes how the impersonation proxy handles service account tokens.
+type ImpersonationProxyServiceAccountTokensSpec struct {
+	// Mode configures whether service account tokens are accepted by the impersonation proxy:
+	// - "enabled" accepts all valid service account tokens. This is the default.
+	// - "boundOnly" accepts only bound service account tokens, such as those issued by the TokenRequest API.
+	// - "disabled" rejects all service account tokens.
+	// Legacy service account tokens, which are stored in Secrets, are not bound tokens.
+	//
+	// +kubebuilder:default:="enabled"
+	Mode ImpersonationProxyServiceAccountTokensMode `json:"mode,omitempty"`
+
+	// CacheTTLSeconds is the number of seconds for which the result of validating a service account token
+	// using the TokenReview API is cached. A cached result never outlives the expiration of the token.
+	// Defaults to 10 seconds.
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=3600
+	// +optional
+	CacheTTLSeconds int32 `json:"cacheTTLSeconds,omitempty"`
+}
+
+// ImpersonationProxyLimitsSpec describes the limits which the impersonation proxy enforces on incoming requests.
+type ImpersonationProxyLimitsSpec struct {
+	// Global describes limits which apply to the requests of all users combined.
+	//
+	// +optional
+	Global *ImpersonationProxyRequestLimits `json:"global,omitempty"`
+
+	// PerUser describes limits which apply to the requests of each user individually, where users are
+	// distinguished by the username that was authenticated by the impersonation proxy.
+	//
+	// +optional
+	PerUser *ImpersonationProxyRequestLimits `json:"perUser,omitempty"`
+}
+
+// ImpersonationProxyRequestLimits describes a set of limits on requests to the impersonation proxy.
+// A zero value for any field means that the corresponding limit is not enforced.
+type ImpersonationProxyRequestLimits struct {
+	// MaxRequestsInFlight is the maximum number of requests which may be served at the same time.
+	// Long-running requests, such as watches and exec sessions, are not counted towards this limit.
+	//
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxRequestsInFlight int32 `json:"maxRequestsInFlight,omitempty"`
+
+	// QPS is the maximum sustained number of requests per second.
+	//
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	QPS int32 `json:"qps,omitempty"`
+
+	// Burst is the maximum number of requests which may be accepted at once before the QPS limit applies.
+	// When QPS is set and Burst is not, Burst defaults to the value of QPS.
+	//
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Burst int32 `json:"burst,omitempty"`
 }
 
 // ImpersonationProxyServiceSpec describes how the Concierge should provision a Service to expose the impersonation proxy.
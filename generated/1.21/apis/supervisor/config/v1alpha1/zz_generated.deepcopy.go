@@ -40,6 +40,112 @@ func (in *FederationDomain) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainAllowedAudience) DeepCopyInto(out *FederationDomainAllowedAudience) {
+	*out = *in
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainAllowedAudience.
+func (in *FederationDomainAllowedAudience) DeepCopy() *FederationDomainAllowedAudience {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainAllowedAudience)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainBackChannelLogoutClient) DeepCopyInto(out *FederationDomainBackChannelLogoutClient) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainBackChannelLogoutClient.
+func (in *FederationDomainBackChannelLogoutClient) DeepCopy() *FederationDomainBackChannelLogoutClient {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainBackChannelLogoutClient)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainExternalSignerSpec) DeepCopyInto(out *FederationDomainExternalSignerSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainExternalSignerSpec.
+func (in *FederationDomainExternalSignerSpec) DeepCopy() *FederationDomainExternalSignerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainExternalSignerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainIntrospectionClient) DeepCopyInto(out *FederationDomainIntrospectionClient) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainIntrospectionClient.
+func (in *FederationDomainIntrospectionClient) DeepCopy() *FederationDomainIntrospectionClient {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainIntrospectionClient)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainIntrospectionSpec) DeepCopyInto(out *FederationDomainIntrospectionSpec) {
+	*out = *in
+	if in.Clients != nil {
+		in, out := &in.Clients, &out.Clients
+		*out = make([]FederationDomainIntrospectionClient, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainIntrospectionSpec.
+func (in *FederationDomainIntrospectionSpec) DeepCopy() *FederationDomainIntrospectionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainIntrospectionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainKeyRotationSpec) DeepCopyInto(out *FederationDomainKeyRotationSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainKeyRotationSpec.
+func (in *FederationDomainKeyRotationSpec) DeepCopy() *FederationDomainKeyRotationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainKeyRotationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FederationDomainList) DeepCopyInto(out *FederationDomainList) {
 	*out = *in
@@ -73,6 +179,74 @@ func (in *FederationDomainList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainLogoutSpec) DeepCopyInto(out *FederationDomainLogoutSpec) {
+	*out = *in
+	if in.PostLogoutRedirectURIs != nil {
+		in, out := &in.PostLogoutRedirectURIs, &out.PostLogoutRedirectURIs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FrontChannelLogoutURIs != nil {
+		in, out := &in.FrontChannelLogoutURIs, &out.FrontChannelLogoutURIs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BackChannelLogoutClients != nil {
+		in, out := &in.BackChannelLogoutClients, &out.BackChannelLogoutClients
+		*out = make([]FederationDomainBackChannelLogoutClient, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainLogoutSpec.
+func (in *FederationDomainLogoutSpec) DeepCopy() *FederationDomainLogoutSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainLogoutSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainRequestObjectClient) DeepCopyInto(out *FederationDomainRequestObjectClient) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainRequestObjectClient.
+func (in *FederationDomainRequestObjectClient) DeepCopy() *FederationDomainRequestObjectClient {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainRequestObjectClient)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainRequestObjectsSpec) DeepCopyInto(out *FederationDomainRequestObjectsSpec) {
+	*out = *in
+	if in.Clients != nil {
+		in, out := &in.Clients, &out.Clients
+		*out = make([]FederationDomainRequestObjectClient, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainRequestObjectsSpec.
+func (in *FederationDomainRequestObjectsSpec) DeepCopy() *FederationDomainRequestObjectsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainRequestObjectsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FederationDomainSecrets) DeepCopyInto(out *FederationDomainSecrets) {
 	*out = *in
@@ -93,6 +267,32 @@ func (in *FederationDomainSecrets) DeepCopy() *FederationDomainSecrets {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainSigningSpec) DeepCopyInto(out *FederationDomainSigningSpec) {
+	*out = *in
+	if in.KeyRotation != nil {
+		in, out := &in.KeyRotation, &out.KeyRotation
+		*out = new(FederationDomainKeyRotationSpec)
+		**out = **in
+	}
+	if in.ExternalSigner != nil {
+		in, out := &in.ExternalSigner, &out.ExternalSigner
+		*out = new(FederationDomainExternalSignerSpec)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainSigningSpec.
+func (in *FederationDomainSigningSpec) DeepCopy() *FederationDomainSigningSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainSigningSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FederationDomainSpec) DeepCopyInto(out *FederationDomainSpec) {
 	*out = *in
@@ -101,6 +301,36 @@ func (in *FederationDomainSpec) DeepCopyInto(out *FederationDomainSpec) {
 		*out = new(FederationDomainTLSSpec)
 		**out = **in
 	}
+	if in.TokenExchange != nil {
+		in, out := &in.TokenExchange, &out.TokenExchange
+		*out = new(FederationDomainTokenExchangeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tokens != nil {
+		in, out := &in.Tokens, &out.Tokens
+		*out = new(FederationDomainTokensSpec)
+		**out = **in
+	}
+	if in.Signing != nil {
+		in, out := &in.Signing, &out.Signing
+		*out = new(FederationDomainSigningSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Introspection != nil {
+		in, out := &in.Introspection, &out.Introspection
+		*out = new(FederationDomainIntrospectionSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Logout != nil {
+		in, out := &in.Logout, &out.Logout
+		*out = new(FederationDomainLogoutSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RequestObjects != nil {
+		in, out := &in.RequestObjects, &out.RequestObjects
+		*out = new(FederationDomainRequestObjectsSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -150,3 +380,42 @@ func (in *FederationDomainTLSSpec) DeepCopy() *FederationDomainTLSSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainTokenExchangeSpec) DeepCopyInto(out *FederationDomainTokenExchangeSpec) {
+	*out = *in
+	if in.AllowedAudiences != nil {
+		in, out := &in.AllowedAudiences, &out.AllowedAudiences
+		*out = make([]FederationDomainAllowedAudience, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainTokenExchangeSpec.
+func (in *FederationDomainTokenExchangeSpec) DeepCopy() *FederationDomainTokenExchangeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainTokenExchangeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainTokensSpec) DeepCopyInto(out *FederationDomainTokensSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainTokensSpec.
+func (in *FederationDomainTokensSpec) DeepCopy() *FederationDomainTokensSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainTokensSpec)
+	in.DeepCopyInto(out)
+	return out
+}
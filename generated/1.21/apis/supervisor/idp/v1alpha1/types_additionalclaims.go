@@ -0,0 +1,22 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+// AdditionalClaimMapping maps an attribute of the user's entry in an LDAP or Active Directory identity provider to
+// an additional claim in the ID tokens issued by the Supervisor.
+type AdditionalClaimMapping struct {
+	// Claim is the name of the claim in the ID tokens, e.g. "email" or "name". The "email" claim is only included
+	// when the client was granted the "email" scope, and all other claims are only included when the client was
+	// granted the "profile" scope. Claims which are set by the Supervisor itself, e.g. "sub", "username" or "groups",
+	// are not allowed and will be ignored.
+	// +kubebuilder:validation:MinLength=1
+	Claim string `json:"claim"`
+
+	// Attribute is the name of the attribute in the user's entry whose value shall become the value of the claim.
+	// The value of this field is case-sensitive and must match the case of the attribute name returned by the server
+	// in the user's entry, e.g. "mail", "displayName" or "employeeID". When the attribute has several values, the first
+	// value is used. When the user's entry does not have the attribute, the claim is omitted.
+	// +kubebuilder:validation:MinLength=1
+	Attribute string `json:"attribute"`
+}
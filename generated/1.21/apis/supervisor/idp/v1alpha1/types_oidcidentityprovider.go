@@ -140,14 +140,49 @@ type OIDCClaims struct {
 	Username string `json:"username"`
 }
 
+// OIDCClientAuthenticationMethod is a method which the Supervisor may use to authenticate as an OIDC client.
+type OIDCClientAuthenticationMethod string
+
+const (
+	// OIDCClientAuthenticationMethodClientSecret authenticates using the "clientSecret" of the client.
+	OIDCClientAuthenticationMethodClientSecret OIDCClientAuthenticationMethod = "client_secret"
+
+	// OIDCClientAuthenticationMethodPrivateKeyJWT authenticates using a JWT which is signed by the "privateKey"
+	// of the client.
+	OIDCClientAuthenticationMethodPrivateKeyJWT OIDCClientAuthenticationMethod = "private_key_jwt"
+)
+
 // OIDCClient contains information about an OIDC client (e.g., client ID and client
 // secret).
 type OIDCClient struct {
 	// SecretName contains the name of a namespace-local Secret object that provides the clientID and
 	// clientSecret for an OIDC client. If only the SecretName is specified in an OIDCClient
 	// struct, then it is expected that the Secret is of type "secrets.pinniped.dev/oidc-client" with keys
-	// "clientID" and "clientSecret".
+	// "clientID" and "clientSecret". When AuthenticationMethod is "private_key_jwt", the Secret must
+	// instead have the keys "clientID" and "privateKey", and may also have the keys "privateKeyID" and
+	// "certificate".
 	SecretName string `json:"secretName"`
+
+	// AuthenticationMethod is how the Supervisor authenticates as this client to the token and revocation
+	// endpoints of the OIDC identity provider. When "client_secret", the Supervisor sends the "clientSecret"
+	// from the Secret. When "private_key_jwt", the Supervisor instead sends a short-lived client assertion
+	// (see https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication) which it signs using the
+	// PEM-encoded RSA or ECDSA "privateKey" from the Secret. The optional "privateKeyID" of the Secret is used
+	// as the "kid" header of the client assertion, and the thumbprint of the optional PEM-encoded "certificate"
+	// of the Secret is used as its "x5t" header, which some providers (e.g. Azure AD) require to find the key.
+	// AuthenticationMethod defaults to "client_secret".
+	// +optional
+	// +kubebuilder:validation:Enum=client_secret;private_key_jwt
+	AuthenticationMethod OIDCClientAuthenticationMethod `json:"authenticationMethod,omitempty"`
+
+	// TLSClientCertificateSecretName optionally contains the name of a namespace-local Secret object of type
+	// "kubernetes.io/tls" with keys "tls.crt" and "tls.key". When set, the Supervisor presents this certificate
+	// as a TLS client certificate in all of its requests to the OIDC identity provider, including its requests to the
+	// token, userinfo and revocation endpoints. This allows the use of mutual TLS client authentication and
+	// certificate-bound access tokens (see https://datatracker.ietf.org/doc/html/rfc8705), in addition to the
+	// AuthenticationMethod.
+	// +optional
+	TLSClientCertificateSecretName string `json:"tlsClientCertificateSecretName,omitempty"`
 }
 
 // OIDCIdentityProviderSpec is the spec for configuring an OIDC identity provider.
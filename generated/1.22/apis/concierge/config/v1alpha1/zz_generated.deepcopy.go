@@ -12,6 +12,22 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuthenticatorReference) DeepCopyInto(out *AuthenticatorReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthenticatorReference.
+func (in *AuthenticatorReference) DeepCopy() *AuthenticatorReference {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthenticatorReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CredentialIssuer) DeepCopyInto(out *CredentialIssuer) {
 	*out = *in
@@ -66,6 +82,27 @@ func (in *CredentialIssuerFrontend) DeepCopy() *CredentialIssuerFrontend {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CredentialIssuerFrontendSpec) DeepCopyInto(out *CredentialIssuerFrontendSpec) {
+	*out = *in
+	if in.AllowedAuthenticators != nil {
+		in, out := &in.AllowedAuthenticators, &out.AllowedAuthenticators
+		*out = make([]AuthenticatorReference, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CredentialIssuerFrontendSpec.
+func (in *CredentialIssuerFrontendSpec) DeepCopy() *CredentialIssuerFrontendSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CredentialIssuerFrontendSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CredentialIssuerKubeConfigInfo) DeepCopyInto(out *CredentialIssuerKubeConfigInfo) {
 	*out = *in
@@ -123,6 +160,13 @@ func (in *CredentialIssuerSpec) DeepCopyInto(out *CredentialIssuerSpec) {
 		*out = new(ImpersonationProxySpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Frontends != nil {
+		in, out := &in.Frontends, &out.Frontends
+		*out = make([]CredentialIssuerFrontendSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -202,6 +246,64 @@ func (in *ImpersonationProxyInfo) DeepCopy() *ImpersonationProxyInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImpersonationProxyLimitsSpec) DeepCopyInto(out *ImpersonationProxyLimitsSpec) {
+	*out = *in
+	if in.Global != nil {
+		in, out := &in.Global, &out.Global
+		*out = new(ImpersonationProxyRequestLimits)
+		**out = **in
+	}
+	if in.PerUser != nil {
+		in, out := &in.PerUser, &out.PerUser
+		*out = new(ImpersonationProxyRequestLimits)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImpersonationProxyLimitsSpec.
+func (in *ImpersonationProxyLimitsSpec) DeepCopy() *ImpersonationProxyLimitsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ImpersonationProxyLimitsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImpersonationProxyRequestLimits) DeepCopyInto(out *ImpersonationProxyRequestLimits) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImpersonationProxyRequestLimits.
+func (in *ImpersonationProxyRequestLimits) DeepCopy() *ImpersonationProxyRequestLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(ImpersonationProxyRequestLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImpersonationProxyServiceAccountTokensSpec) DeepCopyInto(out *ImpersonationProxyServiceAccountTokensSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImpersonationProxyServiceAccountTokensSpec.
+func (in *ImpersonationProxyServiceAccountTokensSpec) DeepCopy() *ImpersonationProxyServiceAccountTokensSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ImpersonationProxyServiceAccountTokensSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImpersonationProxyServiceSpec) DeepCopyInto(out *ImpersonationProxyServiceSpec) {
 	*out = *in
@@ -229,6 +331,16 @@ func (in *ImpersonationProxyServiceSpec) DeepCopy() *ImpersonationProxyServiceSp
 func (in *ImpersonationProxySpec) DeepCopyInto(out *ImpersonationProxySpec) {
 	*out = *in
 	in.Service.DeepCopyInto(&out.Service)
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = new(ImpersonationProxyLimitsSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ServiceAccountTokens != nil {
+		in, out := &in.ServiceAccountTokens, &out.ServiceAccountTokens
+		*out = new(ImpersonationProxyServiceAccountTokensSpec)
+		**out = **in
+	}
 	return
 }
 
@@ -0,0 +1,21 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+// GroupSearchCacheSpec configures the in-memory cache of the results of the group searches of an identity provider.
+type GroupSearchCacheSpec struct {
+	// TTLSeconds is how long the groups found for a user may be reused by refreshes of the user's sessions.
+	// Optional. When not specified, the default is 300 (5 minutes).
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	// +optional
+	TTLSeconds int32 `json:"ttlSeconds,omitempty"`
+
+	// MaxSize is the maximum number of users whose groups are cached. When the cache is full, the least
+	// recently used entry is discarded.
+	// Optional. When not specified, the default is 1000.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxSize int32 `json:"maxSize,omitempty"`
+}
@@ -0,0 +1,31 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+// HostSelectionPolicy decides which host is used first when an identity provider has more than one host.
+// +kubebuilder:validation:Enum=Failover;RoundRobin
+type HostSelectionPolicy string
+
+const (
+	// HostSelectionFailover always uses the first healthy host, in the order in which the hosts are configured
+	// or discovered. The other hosts are only used when the hosts before them cannot be reached.
+	HostSelectionFailover HostSelectionPolicy = "Failover"
+
+	// HostSelectionRoundRobin spreads new connections across all healthy hosts in turn.
+	HostSelectionRoundRobin HostSelectionPolicy = "RoundRobin"
+)
+
+// HostDiscoverySpec configures how to discover the hosts of an identity provider using DNS SRV records.
+type HostDiscoverySpec struct {
+	// SRVDomain is the DNS domain in which to look up the SRV records of the hosts, e.g. "example.com" to look up
+	// the records of "_ldap._tcp.example.com". The discovered hosts are used in the order of their priority and weight.
+	// +kubebuilder:validation:MinLength=1
+	SRVDomain string `json:"srvDomain"`
+
+	// SRVService is the name of the service whose SRV records will be looked up, either "ldap" or "ldaps".
+	// Optional. When not specified, the default is "ldap".
+	// +kubebuilder:validation:Enum=ldap;ldaps
+	// +optional
+	SRVService string `json:"srvService,omitempty"`
+}
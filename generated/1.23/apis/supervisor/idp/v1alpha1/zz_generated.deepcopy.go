@@ -40,6 +40,22 @@ func (in *ActiveDirectoryIdentityProvider) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ActiveDirectoryIdentityProviderAccountStatus) DeepCopyInto(out *ActiveDirectoryIdentityProviderAccountStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ActiveDirectoryIdentityProviderAccountStatus.
+func (in *ActiveDirectoryIdentityProviderAccountStatus) DeepCopy() *ActiveDirectoryIdentityProviderAccountStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ActiveDirectoryIdentityProviderAccountStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ActiveDirectoryIdentityProviderBind) DeepCopyInto(out *ActiveDirectoryIdentityProviderBind) {
 	*out = *in
@@ -60,6 +76,11 @@ func (in *ActiveDirectoryIdentityProviderBind) DeepCopy() *ActiveDirectoryIdenti
 func (in *ActiveDirectoryIdentityProviderGroupSearch) DeepCopyInto(out *ActiveDirectoryIdentityProviderGroupSearch) {
 	*out = *in
 	out.Attributes = in.Attributes
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(GroupSearchCacheSpec)
+		**out = **in
+	}
 	return
 }
 
@@ -89,6 +110,22 @@ func (in *ActiveDirectoryIdentityProviderGroupSearchAttributes) DeepCopy() *Acti
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ActiveDirectoryIdentityProviderKerberos) DeepCopyInto(out *ActiveDirectoryIdentityProviderKerberos) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ActiveDirectoryIdentityProviderKerberos.
+func (in *ActiveDirectoryIdentityProviderKerberos) DeepCopy() *ActiveDirectoryIdentityProviderKerberos {
+	if in == nil {
+		return nil
+	}
+	out := new(ActiveDirectoryIdentityProviderKerberos)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ActiveDirectoryIdentityProviderList) DeepCopyInto(out *ActiveDirectoryIdentityProviderList) {
 	*out = *in
@@ -125,14 +162,30 @@ func (in *ActiveDirectoryIdentityProviderList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ActiveDirectoryIdentityProviderSpec) DeepCopyInto(out *ActiveDirectoryIdentityProviderSpec) {
 	*out = *in
+	if in.AdditionalHosts != nil {
+		in, out := &in.AdditionalHosts, &out.AdditionalHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HostDiscovery != nil {
+		in, out := &in.HostDiscovery, &out.HostDiscovery
+		*out = new(HostDiscoverySpec)
+		**out = **in
+	}
 	if in.TLS != nil {
 		in, out := &in.TLS, &out.TLS
 		*out = new(TLSSpec)
 		**out = **in
 	}
 	out.Bind = in.Bind
-	out.UserSearch = in.UserSearch
-	out.GroupSearch = in.GroupSearch
+	in.UserSearch.DeepCopyInto(&out.UserSearch)
+	in.GroupSearch.DeepCopyInto(&out.GroupSearch)
+	if in.Kerberos != nil {
+		in, out := &in.Kerberos, &out.Kerberos
+		*out = new(ActiveDirectoryIdentityProviderKerberos)
+		**out = **in
+	}
+	out.AccountStatus = in.AccountStatus
 	return
 }
 
@@ -172,7 +225,7 @@ func (in *ActiveDirectoryIdentityProviderStatus) DeepCopy() *ActiveDirectoryIden
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ActiveDirectoryIdentityProviderUserSearch) DeepCopyInto(out *ActiveDirectoryIdentityProviderUserSearch) {
 	*out = *in
-	out.Attributes = in.Attributes
+	in.Attributes.DeepCopyInto(&out.Attributes)
 	return
 }
 
@@ -189,6 +242,11 @@ func (in *ActiveDirectoryIdentityProviderUserSearch) DeepCopy() *ActiveDirectory
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ActiveDirectoryIdentityProviderUserSearchAttributes) DeepCopyInto(out *ActiveDirectoryIdentityProviderUserSearchAttributes) {
 	*out = *in
+	if in.AdditionalClaims != nil {
+		in, out := &in.AdditionalClaims, &out.AdditionalClaims
+		*out = make([]AdditionalClaimMapping, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -202,6 +260,22 @@ func (in *ActiveDirectoryIdentityProviderUserSearchAttributes) DeepCopy() *Activ
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdditionalClaimMapping) DeepCopyInto(out *AdditionalClaimMapping) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdditionalClaimMapping.
+func (in *AdditionalClaimMapping) DeepCopy() *AdditionalClaimMapping {
+	if in == nil {
+		return nil
+	}
+	out := new(AdditionalClaimMapping)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Condition) DeepCopyInto(out *Condition) {
 	*out = *in
@@ -219,6 +293,38 @@ func (in *Condition) DeepCopy() *Condition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GroupSearchCacheSpec) DeepCopyInto(out *GroupSearchCacheSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GroupSearchCacheSpec.
+func (in *GroupSearchCacheSpec) DeepCopy() *GroupSearchCacheSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GroupSearchCacheSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostDiscoverySpec) DeepCopyInto(out *HostDiscoverySpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostDiscoverySpec.
+func (in *HostDiscoverySpec) DeepCopy() *HostDiscoverySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HostDiscoverySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LDAPIdentityProvider) DeepCopyInto(out *LDAPIdentityProvider) {
 	*out = *in
@@ -247,6 +353,27 @@ func (in *LDAPIdentityProvider) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPIdentityProviderAccountStatus) DeepCopyInto(out *LDAPIdentityProviderAccountStatus) {
+	*out = *in
+	if in.DisabledValues != nil {
+		in, out := &in.DisabledValues, &out.DisabledValues
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPIdentityProviderAccountStatus.
+func (in *LDAPIdentityProviderAccountStatus) DeepCopy() *LDAPIdentityProviderAccountStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPIdentityProviderAccountStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LDAPIdentityProviderBind) DeepCopyInto(out *LDAPIdentityProviderBind) {
 	*out = *in
@@ -267,6 +394,16 @@ func (in *LDAPIdentityProviderBind) DeepCopy() *LDAPIdentityProviderBind {
 func (in *LDAPIdentityProviderGroupSearch) DeepCopyInto(out *LDAPIdentityProviderGroupSearch) {
 	*out = *in
 	out.Attributes = in.Attributes
+	if in.Nested != nil {
+		in, out := &in.Nested, &out.Nested
+		*out = new(LDAPIdentityProviderNestedGroupSearch)
+		**out = **in
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(GroupSearchCacheSpec)
+		**out = **in
+	}
 	return
 }
 
@@ -329,17 +466,44 @@ func (in *LDAPIdentityProviderList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPIdentityProviderNestedGroupSearch) DeepCopyInto(out *LDAPIdentityProviderNestedGroupSearch) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPIdentityProviderNestedGroupSearch.
+func (in *LDAPIdentityProviderNestedGroupSearch) DeepCopy() *LDAPIdentityProviderNestedGroupSearch {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPIdentityProviderNestedGroupSearch)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LDAPIdentityProviderSpec) DeepCopyInto(out *LDAPIdentityProviderSpec) {
 	*out = *in
+	if in.AdditionalHosts != nil {
+		in, out := &in.AdditionalHosts, &out.AdditionalHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HostDiscovery != nil {
+		in, out := &in.HostDiscovery, &out.HostDiscovery
+		*out = new(HostDiscoverySpec)
+		**out = **in
+	}
 	if in.TLS != nil {
 		in, out := &in.TLS, &out.TLS
 		*out = new(TLSSpec)
 		**out = **in
 	}
 	out.Bind = in.Bind
-	out.UserSearch = in.UserSearch
-	out.GroupSearch = in.GroupSearch
+	in.UserSearch.DeepCopyInto(&out.UserSearch)
+	in.GroupSearch.DeepCopyInto(&out.GroupSearch)
+	in.AccountStatus.DeepCopyInto(&out.AccountStatus)
 	return
 }
 
@@ -379,7 +543,7 @@ func (in *LDAPIdentityProviderStatus) DeepCopy() *LDAPIdentityProviderStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LDAPIdentityProviderUserSearch) DeepCopyInto(out *LDAPIdentityProviderUserSearch) {
 	*out = *in
-	out.Attributes = in.Attributes
+	in.Attributes.DeepCopyInto(&out.Attributes)
 	return
 }
 
@@ -396,6 +560,11 @@ func (in *LDAPIdentityProviderUserSearch) DeepCopy() *LDAPIdentityProviderUserSe
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LDAPIdentityProviderUserSearchAttributes) DeepCopyInto(out *LDAPIdentityProviderUserSearchAttributes) {
 	*out = *in
+	if in.AdditionalClaims != nil {
+		in, out := &in.AdditionalClaims, &out.AdditionalClaims
+		*out = make([]AdditionalClaimMapping, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -40,9 +40,52 @@ type ActiveDirectoryIdentityProviderBind struct {
 	// password for an Active Directory bind user. This account will be used to perform LDAP searches. The Secret should be
 	// of type "kubernetes.io/basic-auth" which includes "username" and "password" keys. The username value
 	// should be the full dn (distinguished name) of your bind account, e.g. "cn=bind-account,ou=users,dc=example,dc=com".
-	// The password must be non-empty.
+	// The password must be non-empty. When the Method is "SASLExternal", the Secret should instead be of type
+	// "kubernetes.io/tls" which includes "tls.crt" and "tls.key" keys, containing the client certificate and private key
+	// of your bind account. Changes to the Secret are picked up without restarting the Supervisor.
 	// +kubebuilder:validation:MinLength=1
 	SecretName string `json:"secretName"`
+
+	// Method decides how the bind account authenticates to the Active Directory server. Either "Simple", to bind using the
+	// username and password from the Secret, or "SASLExternal", to present the client certificate from the Secret
+	// when establishing the TLS connection and then bind using the identity of that certificate.
+	// Optional. When not specified, the default is "Simple".
+	// +kubebuilder:default=Simple
+	// +optional
+	Method BindMethod `json:"method,omitempty"`
+}
+
+type ActiveDirectoryIdentityProviderAccountStatus struct {
+	// CheckPasswordExpiration, when true, reads the time at which the user's password expires from the constructed
+	// "msDS-UserPasswordExpiryTimeComputed" attribute of the user's entry during each login and each refresh of the
+	// user's session. Users whose password has expired will not be allowed to log in, and will be warned when their
+	// password will expire soon. Reading a constructed attribute requires an additional search of the user's entry
+	// during each login.
+	// Optional. When not specified, the default is false.
+	// +optional
+	CheckPasswordExpiration bool `json:"checkPasswordExpiration,omitempty"`
+
+	// PasswordExpirationWarningDays is how many days before their password expires a user will start to be warned
+	// about it when they log in. Only used when CheckPasswordExpiration is true.
+	// Optional. When not specified, the default is 14.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PasswordExpirationWarningDays int32 `json:"passwordExpirationWarningDays,omitempty"`
+}
+
+type ActiveDirectoryIdentityProviderKerberos struct {
+	// KeytabSecretName contains the name of a namespace-local Secret object that provides the keytab of the service
+	// principal of the Supervisor, e.g. "HTTP/supervisor.example.com@EXAMPLE.COM". The keytab is used to validate the
+	// Kerberos tickets which are presented by browsers. The Secret should be of type "secrets.pinniped.dev/kerberos-keytab"
+	// which includes a "keytab" key. Changes to the Secret are picked up without restarting the Supervisor.
+	// +kubebuilder:validation:MinLength=1
+	KeytabSecretName string `json:"keytabSecretName"`
+
+	// ServicePrincipalName is the name of the service principal in the keytab which should be used to validate the
+	// Kerberos tickets, e.g. "HTTP/supervisor.example.com".
+	// Optional. When not specified, the service principal named by each ticket is used.
+	// +optional
+	ServicePrincipalName string `json:"servicePrincipalName,omitempty"`
 }
 
 type ActiveDirectoryIdentityProviderUserSearchAttributes struct {
@@ -57,6 +100,15 @@ type ActiveDirectoryIdentityProviderUserSearchAttributes struct {
 	// Optional, when empty this defaults to "objectGUID".
 	// +optional
 	UID string `json:"uid,omitempty"`
+
+	// AdditionalClaims maps attributes of the user's Active Directory entry to additional claims in the ID tokens issued by the
+	// Supervisor, e.g. to provide the user's email address and display name to applications. The values of the
+	// attributes are read again during each refresh of the user's session.
+	// Optional. When not specified, no additional claims are included in the ID tokens.
+	// +listType=map
+	// +listMapKey=claim
+	// +optional
+	AdditionalClaims []AdditionalClaimMapping `json:"additionalClaims,omitempty"`
 }
 
 type ActiveDirectoryIdentityProviderGroupSearchAttributes struct {
@@ -132,6 +184,15 @@ type ActiveDirectoryIdentityProviderGroupSearch struct {
 	// +optional
 	Attributes ActiveDirectoryIdentityProviderGroupSearchAttributes `json:"attributes,omitempty"`
 
+	// Cache specifies that the groups found for a user should be remembered for a while, so the group search
+	// can be skipped when the user's session is refreshed. Logins always perform the group search. Cached
+	// groups are discarded when the group search settings change. This reduces the load on the ActiveDirectory
+	// provider when many sessions are refreshed, at the cost of group membership changes taking up to the
+	// TTLSeconds to be noticed by refreshes. Ignored when skipGroupRefresh is true.
+	// Optional. When not specified, the groups are not cached.
+	// +optional
+	Cache *GroupSearchCacheSpec `json:"cache,omitempty"`
+
 	// The user's group membership is refreshed as they interact with the supervisor
 	// to obtain new credentials (as their old credentials expire).  This allows group
 	// membership changes to be quickly reflected into Kubernetes clusters.  Since
@@ -161,10 +222,28 @@ type ActiveDirectoryIdentityProviderGroupSearch struct {
 // Spec for configuring an ActiveDirectory identity provider.
 type ActiveDirectoryIdentityProviderSpec struct {
 	// Host is the hostname of this Active Directory identity provider, i.e., where to connect. For example: ldap.example.com:636.
-	// +kubebuilder:validation:MinLength=1
-	Host string `json:"host"`
+	// Host is also used to identify this identity provider in the downstream identities of its users, so it should
+	// not be changed when adding or removing other hosts. Host may only be omitted when HostDiscovery is specified.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// AdditionalHosts are the hostnames of other replicas of this Active Directory identity provider which serve the same
+	// directory as the Host. For example: ldap2.example.com:636. These hosts are used when the Host cannot be reached.
+	// +optional
+	AdditionalHosts []string `json:"additionalHosts,omitempty"`
+
+	// HostDiscovery configures looking up more hosts of this Active Directory identity provider using DNS SRV records.
+	// The discovered hosts are used after the Host and the AdditionalHosts.
+	// +optional
+	HostDiscovery *HostDiscoverySpec `json:"hostDiscovery,omitempty"`
+
+	// HostSelection decides which host is used first when there is more than one host. Hosts which recently could
+	// not be reached are only used when all other hosts also cannot be reached.
+	// Optional. When not specified, the default is "Failover".
+	// +optional
+	HostSelection HostSelectionPolicy `json:"hostSelection,omitempty"`
 
-	// TLS contains the connection settings for how to establish the connection to the Host.
+	// TLS contains the connection settings for how to establish the connection to the hosts.
 	TLS *TLSSpec `json:"tls,omitempty"`
 
 	// Bind contains the configuration for how to provide access credentials during an initial bind to the ActiveDirectory server
@@ -176,6 +255,20 @@ type ActiveDirectoryIdentityProviderSpec struct {
 
 	// GroupSearch contains the configuration for searching for a user's group membership in ActiveDirectory.
 	GroupSearch ActiveDirectoryIdentityProviderGroupSearch `json:"groupSearch,omitempty"`
+
+	// Kerberos enables single sign-on during browser-based logins for users whose browsers present a Kerberos ticket
+	// using SPNEGO, i.e. an "Authorization: Negotiate" header. The user named by the ticket is searched for by their
+	// sAMAccountName using the UserSearch, and then their groups are searched for as usual. Tickets of users from other
+	// realms than the realm of the service principal are rejected. Users whose browsers do not present a ticket are
+	// shown the usual login form.
+	// Optional. When not specified, users always log in using the login form.
+	// +optional
+	Kerberos *ActiveDirectoryIdentityProviderKerberos `json:"kerberos,omitempty"`
+
+	// AccountStatus contains the configuration for checking that a user's password has not expired, and for warning
+	// users about their upcoming password expiration.
+	// +optional
+	AccountStatus ActiveDirectoryIdentityProviderAccountStatus `json:"accountStatus,omitempty"`
 }
 
 // ActiveDirectoryIdentityProvider describes the configuration of an upstream Microsoft Active Directory identity provider.
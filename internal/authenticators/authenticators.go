@@ -8,8 +8,15 @@ import (
 	"context"
 
 	"k8s.io/apiserver/pkg/authentication/user"
+
+	"go.pinniped.dev/internal/constable"
 )
 
+// ErrPasswordChangeRequired is returned by UserAuthenticator when the username and password were correct,
+// but the user must change their password before they are allowed to log in, e.g. because it has expired
+// or because it was reset by an administrator.
+const ErrPasswordChangeRequired = constable.Error("password change required")
+
 // This interface is similar to the k8s token authenticator, but works with username/passwords instead
 // of a single token string.
 //
@@ -26,6 +33,10 @@ import (
 //    - nil response
 //    - false
 //    - an error
+// 4. For a correct username and password when the user must change their password before logging in:
+//    - nil response
+//    - false
+//    - an error which wraps ErrPasswordChangeRequired
 // Other combinations of return values must be avoided.
 //
 // See k8s.io/apiserver/pkg/authentication/authenticator/interfaces.go for the token authenticator
@@ -38,4 +49,6 @@ type Response struct {
 	User                   user.Info
 	DN                     string
 	ExtraRefreshAttributes map[string]string
+	AdditionalClaims       map[string]string
+	Warnings               []string
 }
@@ -0,0 +1,215 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package csrcertauthority implements a x509 certificate authority capable of issuing client
+// certificates by using a signer of the Kubernetes certificates.k8s.io/v1 CertificateSigningRequest API.
+package csrcertauthority
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/certificate/csr"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/utils/clock"
+
+	"go.pinniped.dev/internal/issuer"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// minimumDuration is the shortest certificate duration which the CertificateSigningRequest API allows us to
+	// request. Signers may issue certificates which are valid for longer than the duration which was requested.
+	minimumDuration = 10 * time.Minute
+
+	// defaultTimeout is how long we will wait for the signer to issue a certificate. This should be short because
+	// the TokenCredentialRequest which wants the certificate is waiting, and because the next ClientCertIssuer
+	// will only be tried after this one fails.
+	defaultTimeout = 10 * time.Second
+
+	// pollInterval is how often we check to see if the signer has issued the certificate.
+	pollInterval = 100 * time.Millisecond
+
+	// approvalReason is the reason on the Approved condition that the Concierge adds to its own requests.
+	approvalReason = "PinnipedConciergeApproved"
+
+	// unhealthyBackoff is how long the signer is skipped after it fails to issue a certificate, so that
+	// TokenCredentialRequests quickly fall back to the next ClientCertIssuer while the signer is not working.
+	unhealthyBackoff = time.Minute
+
+	// probeUsername is the identity of the throwaway certificates requested by Probe.
+	probeUsername = "pinniped-concierge-signer-probe"
+)
+
+// CA is a type capable of issuing certificates.
+type CA struct {
+	client     kubernetes.Interface
+	signerName string
+	clock      clock.Clock
+	timeout    time.Duration
+
+	lock           sync.Mutex
+	unhealthyUntil time.Time
+	lastErr        error
+}
+
+var _ issuer.ClientCertIssuer = (*CA)(nil)
+
+// New creates a ClientCertIssuer which creates, approves, and then deletes a CertificateSigningRequest
+// using the given signerName for each certificate that it issues.
+func New(client kubernetes.Interface, signerName string) *CA {
+	return &CA{
+		client:     client,
+		signerName: signerName,
+		clock:      clock.RealClock{},
+		timeout:    defaultTimeout,
+	}
+}
+
+func (c *CA) Name() string {
+	return fmt.Sprintf("csr-signer-%s", c.signerName)
+}
+
+// IssueClientCertPEM issues a new client certificate for the given identity and duration, returning it as a
+// pair of PEM-formatted byte slices for the certificate and private key. It fails immediately while the signer
+// is considered unhealthy because it recently failed to issue a certificate.
+func (c *CA) IssueClientCertPEM(username string, groups []string, ttl time.Duration) ([]byte, []byte, error) {
+	if err := c.healthy(); err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	certPEM, keyPEM, err := c.issue(ctx, username, groups, ttl)
+	c.recordResult(err)
+	return certPEM, keyPEM, err
+}
+
+// Probe checks that the signer issues certificates by requesting a throwaway certificate. A successful probe
+// also ends any period in which the signer was considered unhealthy.
+func (c *CA) Probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	_, _, err := c.issue(ctx, probeUsername, nil, minimumDuration)
+	c.recordResult(err)
+	return err
+}
+
+func (c *CA) healthy() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.clock.Now().Before(c.unhealthyUntil) {
+		return fmt.Errorf("skipping signer %q because it recently failed to issue a certificate: %w", c.signerName, c.lastErr)
+	}
+	return nil
+}
+
+func (c *CA) recordResult(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err != nil {
+		c.unhealthyUntil = c.clock.Now().Add(unhealthyBackoff)
+		c.lastErr = err
+		return
+	}
+	c.unhealthyUntil = time.Time{}
+	c.lastErr = nil
+}
+
+func (c *CA) issue(ctx context.Context, username string, groups []string, ttl time.Duration) ([]byte, []byte, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not generate private key: %w", err)
+	}
+
+	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not marshal private key: %w", err)
+	}
+
+	csrPEM, err := certutil.MakeCSR(privateKey, &pkix.Name{CommonName: username, Organization: groups}, nil, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create certificate request: %w", err)
+	}
+
+	if ttl < minimumDuration {
+		ttl = minimumDuration
+	}
+
+	csrClient := c.client.CertificatesV1().CertificateSigningRequests()
+
+	req, err := csrClient.Create(ctx, &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "pinniped-client-",
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:           csrPEM,
+			SignerName:        c.signerName,
+			ExpirationSeconds: csr.DurationToExpirationSeconds(ttl),
+			Usages:            []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageClientAuth},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create CertificateSigningRequest: %w", err)
+	}
+
+	// The CertificateSigningRequest is only needed until we have read the certificate, so always clean it up.
+	defer func() {
+		if err := csrClient.Delete(context.Background(), req.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &req.UID},
+		}); err != nil {
+			plog.Debug("could not delete CertificateSigningRequest", "name", req.Name, "err", err)
+		}
+	}()
+
+	req.Status.Conditions = append(req.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+		Type:           certificatesv1.CertificateApproved,
+		Status:         corev1.ConditionTrue,
+		Reason:         approvalReason,
+		Message:        "This CertificateSigningRequest was approved by the Pinniped Concierge for a TokenCredentialRequest.",
+		LastUpdateTime: metav1.NewTime(c.clock.Now()),
+	})
+	if _, err := csrClient.UpdateApproval(ctx, req.Name, req, metav1.UpdateOptions{}); err != nil {
+		return nil, nil, fmt.Errorf("could not approve CertificateSigningRequest %s: %w", req.Name, err)
+	}
+
+	var certPEM []byte
+	err = wait.PollImmediateUntilWithContext(ctx, pollInterval, func(ctx context.Context) (bool, error) {
+		current, err := csrClient.Get(ctx, req.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("could not get CertificateSigningRequest %s: %w", req.Name, err)
+		}
+		for _, condition := range current.Status.Conditions {
+			if condition.Type == certificatesv1.CertificateDenied || condition.Type == certificatesv1.CertificateFailed {
+				return false, fmt.Errorf("CertificateSigningRequest %s has condition %s: %s", req.Name, condition.Type, condition.Message)
+			}
+		}
+		certPEM = current.Status.Certificate
+		return len(certPEM) > 0, nil
+	})
+	if err != nil {
+		if errors.Is(err, wait.ErrWaitTimeout) {
+			return nil, nil, fmt.Errorf("timed out waiting for signer %q to issue a certificate for CertificateSigningRequest %s", c.signerName, req.Name)
+		}
+		return nil, nil, err
+	}
+
+	return certPEM, keyPEM, nil
+}
@@ -0,0 +1,265 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package csrcertauthority
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	kubetesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/pointer"
+
+	"go.pinniped.dev/internal/testutil"
+)
+
+func TestIssueClientCertPEM(t *testing.T) {
+	const (
+		signerName = "example.com/some-signer"
+		csrName    = "pinniped-client-abc123"
+		csrUID     = types.UID("some-uid")
+	)
+
+	now := time.Date(2022, 5, 4, 3, 2, 1, 0, time.UTC)
+
+	issuedCertPEM, _, err := testutil.CreateCertificate(now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		ttl           time.Duration
+		addReactors   func(*kubefake.Clientset)
+		wantErr       string
+		wantCertPEM   []byte
+		wantDuration  time.Duration
+		wantNoApprove bool
+	}{
+		{
+			name: "the signer issues a certificate",
+			ttl:  5 * time.Minute,
+			addReactors: func(client *kubefake.Clientset) {
+				client.PrependReactor("get", "certificatesigningrequests", func(action kubetesting.Action) (bool, runtime.Object, error) {
+					csr, err := client.Tracker().Get(action.GetResource(), "", csrName)
+					require.NoError(t, err)
+					issued := csr.(*certificatesv1.CertificateSigningRequest).DeepCopy()
+					issued.Status.Certificate = issuedCertPEM
+					return true, issued, nil
+				})
+			},
+			wantCertPEM:  issuedCertPEM,
+			wantDuration: 10 * time.Minute,
+		},
+		{
+			name: "the signer issues a certificate with a longer requested duration",
+			ttl:  time.Hour,
+			addReactors: func(client *kubefake.Clientset) {
+				client.PrependReactor("get", "certificatesigningrequests", func(action kubetesting.Action) (bool, runtime.Object, error) {
+					csr, err := client.Tracker().Get(action.GetResource(), "", csrName)
+					require.NoError(t, err)
+					issued := csr.(*certificatesv1.CertificateSigningRequest).DeepCopy()
+					issued.Status.Certificate = issuedCertPEM
+					return true, issued, nil
+				})
+			},
+			wantCertPEM:  issuedCertPEM,
+			wantDuration: time.Hour,
+		},
+		{
+			name: "the CSR cannot be created",
+			ttl:  5 * time.Minute,
+			addReactors: func(client *kubefake.Clientset) {
+				client.PrependReactor("create", "certificatesigningrequests", func(action kubetesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("some create error")
+				})
+			},
+			wantErr: "could not create CertificateSigningRequest: some create error",
+		},
+		{
+			name: "the CSR cannot be approved",
+			ttl:  5 * time.Minute,
+			addReactors: func(client *kubefake.Clientset) {
+				client.PrependReactor("update", "certificatesigningrequests", func(action kubetesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("some approval error")
+				})
+			},
+			wantErr:       "could not approve CertificateSigningRequest pinniped-client-abc123: some approval error",
+			wantDuration:  10 * time.Minute,
+			wantNoApprove: true,
+		},
+		{
+			name: "the signer fails to issue the certificate",
+			ttl:  5 * time.Minute,
+			addReactors: func(client *kubefake.Clientset) {
+				client.PrependReactor("get", "certificatesigningrequests", func(action kubetesting.Action) (bool, runtime.Object, error) {
+					csr, err := client.Tracker().Get(action.GetResource(), "", csrName)
+					require.NoError(t, err)
+					failed := csr.(*certificatesv1.CertificateSigningRequest).DeepCopy()
+					failed.Status.Conditions = append(failed.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+						Type:    certificatesv1.CertificateFailed,
+						Status:  corev1.ConditionTrue,
+						Message: "some signer failure",
+					})
+					return true, failed, nil
+				})
+			},
+			wantErr:      "CertificateSigningRequest pinniped-client-abc123 has condition Failed: some signer failure",
+			wantDuration: 10 * time.Minute,
+		},
+		{
+			name:         "the signer never issues the certificate",
+			ttl:          5 * time.Minute,
+			addReactors:  func(client *kubefake.Clientset) {},
+			wantErr:      `timed out waiting for signer "example.com/some-signer" to issue a certificate for CertificateSigningRequest pinniped-client-abc123`,
+			wantDuration: 10 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := kubefake.NewSimpleClientset()
+
+			var createdCSR *certificatesv1.CertificateSigningRequest
+			var approvedCSR *certificatesv1.CertificateSigningRequest
+			client.PrependReactor("update", "certificatesigningrequests", func(action kubetesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() == "approval" {
+					approvedCSR = action.(kubetesting.UpdateAction).GetObject().(*certificatesv1.CertificateSigningRequest).DeepCopy()
+				}
+				return false, nil, nil
+			})
+			client.PrependReactor("create", "certificatesigningrequests", func(action kubetesting.Action) (bool, runtime.Object, error) {
+				// The fake clientset does not implement generateName, so simulate it here.
+				csr := action.(kubetesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+				require.Equal(t, "pinniped-client-", csr.GenerateName)
+				csr.Name = csrName
+				csr.UID = csrUID
+				createdCSR = csr.DeepCopy()
+				return false, nil, nil
+			})
+			tt.addReactors(client)
+
+			subject := New(client, signerName)
+			subject.clock = clocktesting.NewFakeClock(now)
+			subject.timeout = time.Second
+
+			certPEM, keyPEM, err := subject.IssueClientCertPEM("some-username", []string{"some-group1", "some-group2"}, tt.ttl)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, certPEM)
+				require.Nil(t, keyPEM)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.wantCertPEM, certPEM)
+				keyBlock, _ := pem.Decode(keyPEM)
+				require.NotNil(t, keyBlock)
+				_, err = x509.ParseECPrivateKey(keyBlock.Bytes)
+				require.NoError(t, err)
+			}
+
+			if tt.wantDuration == 0 {
+				require.Nil(t, createdCSR)
+				return
+			}
+
+			require.Equal(t, signerName, createdCSR.Spec.SignerName)
+			require.Equal(t, pointer.Int32(int32(tt.wantDuration/time.Second)), createdCSR.Spec.ExpirationSeconds)
+			require.Equal(t, []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageClientAuth}, createdCSR.Spec.Usages)
+			csrBlock, _ := pem.Decode(createdCSR.Spec.Request)
+			require.NotNil(t, csrBlock)
+			request, err := x509.ParseCertificateRequest(csrBlock.Bytes)
+			require.NoError(t, err)
+			require.Equal(t, "some-username", request.Subject.CommonName)
+			require.Equal(t, []string{"some-group1", "some-group2"}, request.Subject.Organization)
+
+			if tt.wantNoApprove {
+				require.Nil(t, approvedCSR)
+			} else {
+				require.NotNil(t, approvedCSR)
+				require.Equal(t, []certificatesv1.CertificateSigningRequestCondition{{
+					Type:           certificatesv1.CertificateApproved,
+					Status:         corev1.ConditionTrue,
+					Reason:         "PinnipedConciergeApproved",
+					Message:        "This CertificateSigningRequest was approved by the Pinniped Concierge for a TokenCredentialRequest.",
+					LastUpdateTime: metav1.NewTime(now),
+				}}, approvedCSR.Status.Conditions)
+			}
+
+			// The CSR should always be cleaned up after it has been created.
+			require.True(t, wasDeleted(client, csrName), "expected CSR to be deleted")
+		})
+	}
+}
+
+func TestUnhealthySignerIsSkipped(t *testing.T) {
+	now := time.Date(2022, 5, 4, 3, 2, 1, 0, time.UTC)
+	issuedCertPEM, _, err := testutil.CreateCertificate(now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+
+	client := kubefake.NewSimpleClientset()
+	signerWorks := false
+	var creates int
+	client.PrependReactor("create", "certificatesigningrequests", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		creates++
+		csr := action.(kubetesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+		csr.Name = "pinniped-client-abc123"
+		return false, nil, nil
+	})
+	client.PrependReactor("get", "certificatesigningrequests", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		csr, err := client.Tracker().Get(action.GetResource(), "", "pinniped-client-abc123")
+		require.NoError(t, err)
+		current := csr.(*certificatesv1.CertificateSigningRequest).DeepCopy()
+		if signerWorks {
+			current.Status.Certificate = issuedCertPEM
+		}
+		return true, current, nil
+	})
+
+	clock := clocktesting.NewFakeClock(now)
+	subject := New(client, "example.com/some-signer")
+	subject.clock = clock
+	subject.timeout = 500 * time.Millisecond
+
+	_, _, err = subject.IssueClientCertPEM("some-username", nil, time.Hour)
+	require.EqualError(t, err, `timed out waiting for signer "example.com/some-signer" to issue a certificate for CertificateSigningRequest pinniped-client-abc123`)
+	require.Equal(t, 1, creates)
+
+	// The signer is skipped without creating another CSR until the backoff has passed.
+	_, _, err = subject.IssueClientCertPEM("some-username", nil, time.Hour)
+	require.EqualError(t, err, `skipping signer "example.com/some-signer" because it recently failed to issue a certificate: `+
+		`timed out waiting for signer "example.com/some-signer" to issue a certificate for CertificateSigningRequest pinniped-client-abc123`)
+	require.Equal(t, 1, creates)
+
+	clock.Step(unhealthyBackoff)
+	_, _, err = subject.IssueClientCertPEM("some-username", nil, time.Hour)
+	require.Error(t, err)
+	require.Equal(t, 2, creates)
+
+	// A probe is not skipped, and a successful probe makes the signer healthy again.
+	signerWorks = true
+	require.NoError(t, subject.Probe(context.Background()))
+	require.Equal(t, 3, creates)
+	certPEM, _, err := subject.IssueClientCertPEM("some-username", nil, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, issuedCertPEM, certPEM)
+	require.Equal(t, 4, creates)
+}
+
+func wasDeleted(client *kubefake.Clientset, name string) bool {
+	for _, action := range client.Actions() {
+		if deleteAction, ok := action.(kubetesting.DeleteAction); ok && deleteAction.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package frontendpolicy implements an in-memory representation of which authenticators may be used through
+// each frontend of the Concierge, as configured on the CredentialIssuer.
+package frontendpolicy
+
+import (
+	"context"
+	"sync"
+
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+)
+
+// ImpersonationProxyExtraKey is the user extra key which the impersonation proxy sets when it forwards a
+// TokenCredentialRequest to the Kube API server, so that the TokenCredentialRequest API can tell which
+// frontend was used to make the request. This key uses the suffix which is reserved by the impersonation
+// proxy, so it cannot be set by clients of the impersonation proxy.
+const ImpersonationProxyExtraKey = "frontend.impersonation-proxy.concierge.pinniped.dev"
+
+// Policy holds the authenticators which may be used through each frontend.
+// It may be changed at any time.
+type Policy struct {
+	lock    sync.RWMutex
+	allowed map[v1alpha1.FrontendType]map[authenticatorKey]bool
+}
+
+type authenticatorKey struct {
+	kind string
+	name string
+}
+
+// New returns a Policy which allows any authenticator to be used through any frontend until Set is called.
+func New() *Policy {
+	return &Policy{}
+}
+
+// Set replaces the current policy with the given frontend configuration. A frontend which is not
+// configured, or which is configured with an empty list of allowed authenticators, allows any authenticator.
+func (p *Policy) Set(frontends []v1alpha1.CredentialIssuerFrontendSpec) {
+	allowed := map[v1alpha1.FrontendType]map[authenticatorKey]bool{}
+	for _, frontend := range frontends {
+		if len(frontend.AllowedAuthenticators) == 0 {
+			continue
+		}
+		if allowed[frontend.Type] == nil {
+			allowed[frontend.Type] = map[authenticatorKey]bool{}
+		}
+		for _, ref := range frontend.AllowedAuthenticators {
+			allowed[frontend.Type][authenticatorKey{kind: ref.Kind, name: ref.Name}] = true
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.allowed = allowed
+}
+
+// Allowed returns whether the authenticator with the given kind and name may be used through the given frontend.
+func (p *Policy) Allowed(frontend v1alpha1.FrontendType, kind, name string) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	authenticators, restricted := p.allowed[frontend]
+	if !restricted {
+		return true
+	}
+	return authenticators[authenticatorKey{kind: kind, name: name}]
+}
+
+// FrontendFor returns the frontend through which the request in the given context was made.
+func FrontendFor(ctx context.Context) v1alpha1.FrontendType {
+	if userInfo, ok := genericapirequest.UserFrom(ctx); ok {
+		if _, ok := userInfo.GetExtra()[ImpersonationProxyExtraKey]; ok {
+			return v1alpha1.ImpersonationProxyFrontendType
+		}
+	}
+	return v1alpha1.TokenCredentialRequestAPIFrontendType
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package frontendpolicy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+
+	const (
+		tcr   = v1alpha1.TokenCredentialRequestAPIFrontendType
+		proxy = v1alpha1.ImpersonationProxyFrontendType
+	)
+
+	p := New()
+	require.True(t, p.Allowed(tcr, "JWTAuthenticator", "some-jwt-authenticator"))
+	require.True(t, p.Allowed(proxy, "WebhookAuthenticator", "some-webhook"))
+
+	p.Set([]v1alpha1.CredentialIssuerFrontendSpec{
+		{
+			Type: tcr,
+			AllowedAuthenticators: []v1alpha1.AuthenticatorReference{
+				{Kind: "JWTAuthenticator", Name: "internal"},
+				{Kind: "WebhookAuthenticator", Name: "some-webhook"},
+			},
+		},
+		{
+			// an empty list does not restrict anything
+			Type: proxy,
+		},
+	})
+	require.True(t, p.Allowed(tcr, "JWTAuthenticator", "internal"))
+	require.True(t, p.Allowed(tcr, "WebhookAuthenticator", "some-webhook"))
+	require.False(t, p.Allowed(tcr, "JWTAuthenticator", "external"))
+	require.False(t, p.Allowed(tcr, "WebhookAuthenticator", "internal"), "kinds must match")
+	require.True(t, p.Allowed(proxy, "JWTAuthenticator", "external"))
+
+	p.Set(nil)
+	require.True(t, p.Allowed(tcr, "JWTAuthenticator", "external"))
+}
+
+func TestFrontendFor(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, v1alpha1.TokenCredentialRequestAPIFrontendType, FrontendFor(context.Background()))
+
+	anonymous := genericapirequest.WithUser(context.Background(), &user.DefaultInfo{Name: user.Anonymous})
+	require.Equal(t, v1alpha1.TokenCredentialRequestAPIFrontendType, FrontendFor(anonymous))
+
+	proxied := genericapirequest.WithUser(context.Background(), &user.DefaultInfo{
+		Name:  user.Anonymous,
+		Extra: map[string][]string{ImpersonationProxyExtraKey: {"ImpersonationProxy"}},
+	})
+	require.Equal(t, v1alpha1.ImpersonationProxyFrontendType, FrontendFor(proxied))
+}
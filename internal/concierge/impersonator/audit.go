@@ -0,0 +1,148 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package impersonator
+
+import (
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	auditinternal "k8s.io/apiserver/pkg/apis/audit"
+	"k8s.io/apiserver/pkg/audit"
+	"k8s.io/apiserver/pkg/audit/policy"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/apiserver/pkg/server/dynamiccertificates"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	auditfake "k8s.io/apiserver/plugin/pkg/audit/fake"
+
+	"go.pinniped.dev/internal/config/concierge"
+)
+
+// authenticatorAuditAnnotationKey is the audit annotation which records how the credential
+// presented by the client of the impersonation proxy was authenticated.
+const authenticatorAuditAnnotationKey = "impersonation-proxy.concierge.pinniped.dev/authenticator"
+
+const (
+	// authenticatorTokenCredentialRequest means that the client presented a certificate issued by the
+	// TokenCredentialRequest API, i.e. one that was signed by the impersonation proxy signer CA.
+	authenticatorTokenCredentialRequest = "token-credential-request"
+	// authenticatorKubeClientCertificate means that the client presented a certificate signed by the Kube API server's client CA.
+	authenticatorKubeClientCertificate = "kube-client-certificate"
+	// authenticatorBearerToken means that the client presented a bearer token which was validated by the Kube API server.
+	authenticatorBearerToken = "bearer-token"
+	// authenticatorServiceAccountToken means that the client presented a service account token which was validated by the Kube API server.
+	authenticatorServiceAccountToken = "service-account-token"
+	// authenticatorAnonymous means that the client did not present any credential.
+	authenticatorAnonymous = "anonymous"
+)
+
+// allAuditStages is used to prevent any audit event from reaching the audit backend.
+var allAuditStages = []auditinternal.Stage{ //nolint:gochecknoglobals
+	auditinternal.StageRequestReceived,
+	auditinternal.StageResponseStarted,
+	auditinternal.StageResponseComplete,
+	auditinternal.StagePanic,
+}
+
+// newAuditPolicyRuleEvaluatorAndBackend returns the audit configuration for the impersonation proxy's server.
+// The impersonation proxy relies upon every request having an audit event at the metadata level so that it can
+// preserve the original user during nested impersonation. Thus when no audit policy is configured, all requests
+// get a metadata level event which is thrown away by a fake backend. When a policy is configured, requests which
+// the policy does not wish to audit still get a metadata level event, but that event never reaches the backend.
+func newAuditPolicyRuleEvaluatorAndBackend(auditConfig *concierge.ImpersonationProxyAuditSpec) (audit.PolicyRuleEvaluator, audit.Backend, error) {
+	if auditConfig == nil || len(auditConfig.PolicyFile) == 0 {
+		return policy.NewFakePolicyRuleEvaluator(auditinternal.LevelMetadata, nil), &auditfake.Backend{}, nil
+	}
+
+	auditOptions := genericoptions.NewAuditOptions()
+	auditOptions.PolicyFile = auditConfig.PolicyFile
+	auditOptions.LogOptions.Path = auditConfig.LogPath
+	auditOptions.WebhookOptions.ConfigFile = auditConfig.WebhookConfigFile
+
+	if errs := auditOptions.Validate(); len(errs) != 0 {
+		return nil, nil, utilerrors.NewAggregate(errs)
+	}
+
+	// only the audit related fields of this config are used
+	var auditServerConfig genericapiserver.Config
+	if err := auditOptions.ApplyTo(&auditServerConfig); err != nil {
+		return nil, nil, err
+	}
+
+	return &minimumMetadataPolicyRuleEvaluator{delegate: auditServerConfig.AuditPolicyRuleEvaluator}, auditServerConfig.AuditBackend, nil
+}
+
+var _ audit.PolicyRuleEvaluator = &minimumMetadataPolicyRuleEvaluator{}
+
+// minimumMetadataPolicyRuleEvaluator makes sure that every request has an audit event of at least the metadata level.
+type minimumMetadataPolicyRuleEvaluator struct {
+	delegate audit.PolicyRuleEvaluator
+}
+
+func (e *minimumMetadataPolicyRuleEvaluator) EvaluatePolicyRule(attrs authorizer.Attributes) audit.RequestAuditConfigWithLevel {
+	auditConfig := e.delegate.EvaluatePolicyRule(attrs)
+
+	if auditConfig.Level.Less(auditinternal.LevelMetadata) {
+		return audit.RequestAuditConfigWithLevel{
+			Level:              auditinternal.LevelMetadata,
+			RequestAuditConfig: audit.RequestAuditConfig{OmitStages: allAuditStages},
+		}
+	}
+
+	return auditConfig
+}
+
+// authenticatorForRequest determines how the credential on the request was authenticated. This mirrors the
+// authentication stack of the impersonation proxy, where client certificates take precedence over bearer tokens.
+func authenticatorForRequest(r *http.Request, ae *auditinternal.Event, impersonationProxySignerCA dynamiccertificates.CAContentProvider) string {
+	if ae.User.Username == user.Anonymous {
+		return authenticatorAnonymous
+	}
+
+	if r.TLS != nil && len(r.TLS.PeerCertificates) != 0 {
+		leaf := r.TLS.PeerCertificates[0]
+
+		// a certificate for some other user could have been presented along with the token that authenticated
+		if leaf.Subject.CommonName == ae.User.Username {
+			if isSignedByCA(r.TLS.PeerCertificates, impersonationProxySignerCA) {
+				return authenticatorTokenCredentialRequest
+			}
+			return authenticatorKubeClientCertificate
+		}
+	}
+
+	if strings.HasPrefix(ae.User.Username, serviceaccount.ServiceAccountUsernamePrefix) {
+		return authenticatorServiceAccountToken
+	}
+
+	return authenticatorBearerToken
+}
+
+func isSignedByCA(chain []*x509.Certificate, ca dynamiccertificates.CAContentProvider) bool {
+	caBundle := ca.CurrentCABundleContent()
+	if len(caBundle) == 0 {
+		return false
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caBundle) {
+		return false
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err == nil
+}
@@ -0,0 +1,247 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package impersonator
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	auditinternal "k8s.io/apiserver/pkg/apis/audit"
+	"k8s.io/apiserver/pkg/audit"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	auditfake "k8s.io/apiserver/plugin/pkg/audit/fake"
+
+	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/config/concierge"
+	"go.pinniped.dev/internal/dynamiccert"
+	"go.pinniped.dev/internal/here"
+)
+
+func TestNewAuditPolicyRuleEvaluatorAndBackend(t *testing.T) {
+	dir := t.TempDir()
+
+	policyFile := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, ioutil.WriteFile(policyFile, []byte(here.Doc(`
+		apiVersion: audit.k8s.io/v1
+		kind: Policy
+		omitStages: [RequestReceived]
+		rules:
+		- level: None
+		  resources:
+		  - group: ""
+		    resources: [configmaps]
+		- level: RequestResponse
+		  resources:
+		  - group: ""
+		    resources: [secrets]
+		- level: Metadata
+	`)), 0600))
+
+	invalidPolicyFile := filepath.Join(dir, "invalid-policy.yaml")
+	require.NoError(t, ioutil.WriteFile(invalidPolicyFile, []byte("this is not a policy"), 0600))
+
+	attrsFor := func(resource string) authorizer.Attributes {
+		return authorizer.AttributesRecord{
+			User:            &user.DefaultInfo{Name: "some-user"},
+			Verb:            "get",
+			Resource:        resource,
+			ResourceRequest: true,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		auditConfig   *concierge.ImpersonationProxyAuditSpec
+		wantErr       string
+		wantFake      bool
+		wantEvaluated map[string]audit.RequestAuditConfigWithLevel
+	}{
+		{
+			name:     "nil config",
+			wantFake: true,
+			wantEvaluated: map[string]audit.RequestAuditConfigWithLevel{
+				"configmaps": {Level: auditinternal.LevelMetadata},
+				"secrets":    {Level: auditinternal.LevelMetadata},
+			},
+		},
+		{
+			name:        "no policy file",
+			auditConfig: &concierge.ImpersonationProxyAuditSpec{},
+			wantFake:    true,
+			wantEvaluated: map[string]audit.RequestAuditConfigWithLevel{
+				"configmaps": {Level: auditinternal.LevelMetadata},
+				"secrets":    {Level: auditinternal.LevelMetadata},
+			},
+		},
+		{
+			name: "policy file and log backend",
+			auditConfig: &concierge.ImpersonationProxyAuditSpec{
+				PolicyFile: policyFile,
+				LogPath:    filepath.Join(dir, "audit.log"),
+			},
+			wantEvaluated: map[string]audit.RequestAuditConfigWithLevel{
+				"configmaps": {
+					Level:              auditinternal.LevelMetadata,
+					RequestAuditConfig: audit.RequestAuditConfig{OmitStages: allAuditStages},
+				},
+				"secrets": {
+					Level:              auditinternal.LevelRequestResponse,
+					RequestAuditConfig: audit.RequestAuditConfig{OmitStages: []auditinternal.Stage{auditinternal.StageRequestReceived}},
+				},
+				"pods": {
+					Level:              auditinternal.LevelMetadata,
+					RequestAuditConfig: audit.RequestAuditConfig{OmitStages: []auditinternal.Stage{auditinternal.StageRequestReceived}},
+				},
+			},
+		},
+		{
+			name: "missing policy file",
+			auditConfig: &concierge.ImpersonationProxyAuditSpec{
+				PolicyFile: filepath.Join(dir, "does-not-exist.yaml"),
+				LogPath:    "-",
+			},
+			wantErr: "loading audit policy file: failed to read file path",
+		},
+		{
+			name: "invalid policy file",
+			auditConfig: &concierge.ImpersonationProxyAuditSpec{
+				PolicyFile: invalidPolicyFile,
+				LogPath:    "-",
+			},
+			wantErr: "loading audit policy file: failed decoding",
+		},
+		{
+			name: "missing webhook config file",
+			auditConfig: &concierge.ImpersonationProxyAuditSpec{
+				PolicyFile:        policyFile,
+				WebhookConfigFile: filepath.Join(dir, "does-not-exist.kubeconfig"),
+			},
+			wantErr: "initializing audit webhook",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			evaluator, backend, err := newAuditPolicyRuleEvaluatorAndBackend(tt.auditConfig)
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				require.Nil(t, evaluator)
+				require.Nil(t, backend)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, evaluator)
+			require.NotNil(t, backend)
+
+			_, isFake := backend.(*auditfake.Backend)
+			require.Equal(t, tt.wantFake, isFake)
+
+			for resource, want := range tt.wantEvaluated {
+				require.Equal(t, want, evaluator.EvaluatePolicyRule(attrsFor(resource)), "resource %s", resource)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorForRequest(t *testing.T) {
+	signerCA, err := certauthority.New("impersonation-proxy-signer", time.Hour)
+	require.NoError(t, err)
+	signerCAKey, err := signerCA.PrivateKeyToPEM()
+	require.NoError(t, err)
+	signerCAContent := dynamiccert.NewCA("signer")
+	require.NoError(t, signerCAContent.SetCertKeyContent(signerCA.Bundle(), signerCAKey))
+
+	kubeCA, err := certauthority.New("kube-ca", time.Hour)
+	require.NoError(t, err)
+
+	peerCertsFrom := func(ca *certauthority.CA, username string) []*x509.Certificate {
+		tlsCert, err := ca.IssueClientCert(username, []string{"some-group"}, time.Hour)
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(tlsCert.Certificate[0])
+		require.NoError(t, err)
+		return []*x509.Certificate{leaf}
+	}
+
+	tests := []struct {
+		name      string
+		username  string
+		peerCerts []*x509.Certificate
+		signerCA  dynamiccert.Public
+		want      string
+	}{
+		{
+			name:     "anonymous",
+			username: user.Anonymous,
+			want:     authenticatorAnonymous,
+		},
+		{
+			name:      "cert issued by the token credential request API",
+			username:  "some-user",
+			peerCerts: peerCertsFrom(signerCA, "some-user"),
+			want:      authenticatorTokenCredentialRequest,
+		},
+		{
+			name:      "cert issued by the kube client CA",
+			username:  "some-user",
+			peerCerts: peerCertsFrom(kubeCA, "some-user"),
+			want:      authenticatorKubeClientCertificate,
+		},
+		{
+			name:      "cert issued by the token credential request API when the signer CA is not loaded",
+			username:  "some-user",
+			peerCerts: peerCertsFrom(signerCA, "some-user"),
+			signerCA:  dynamiccert.NewCA("empty"),
+			want:      authenticatorKubeClientCertificate,
+		},
+		{
+			name:      "cert for a different user",
+			username:  "some-user",
+			peerCerts: peerCertsFrom(signerCA, "some-other-user"),
+			want:      authenticatorBearerToken,
+		},
+		{
+			name:     "bearer token",
+			username: "some-user",
+			want:     authenticatorBearerToken,
+		},
+		{
+			name:     "service account token",
+			username: "system:serviceaccount:some-namespace:some-service-account",
+			want:     authenticatorServiceAccountToken,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &http.Request{}
+			if tt.peerCerts != nil {
+				r.TLS = &tls.ConnectionState{PeerCertificates: tt.peerCerts}
+			}
+
+			ca := tt.signerCA
+			if ca == nil {
+				ca = signerCAContent
+			}
+
+			ae := &auditinternal.Event{User: authenticationv1.UserInfo{Username: tt.username}}
+
+			require.Equal(t, tt.want, authenticatorForRequest(r, ae, ca))
+		})
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2022 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 /*
@@ -26,6 +26,10 @@ When disabled, the proxy will not authenticate these requests. There is one cave
 in that Pinniped itself provides the Token Credential Request API which is used
 specifically by anonymous users to retrieve credentials.  This API is the single
 API that will remain available even when anonymous authentication is disabled.
+Token Credential Requests are marked with the reserved extra key
+frontend.impersonation-proxy.concierge.pinniped.dev so that the Token Credential
+Request API can enforce which authenticators may be used through the
+impersonation proxy (as configured on the CredentialIssuer).
 
 In terms of authorization, in addition to the regular checks that the Kubernetes
 API server will make for the impersonated user, we perform the same authorization
@@ -42,9 +46,15 @@ the authenticationv1.UserInfo struct.  This is necessary to make sure that the
 Kubernetes audit log contains all three identities (original user, impersonated
 user and the impersonation proxy's service account).  Capturing the original
 user information requires that we enable the auditing stack (WithImpersonation
-only shares this information with the audit stack).  To keep things simple,
-we use the fake audit backend at the Metadata level for all requests.  This
-guarantees that we always have an audit event on every request.
+only shares this information with the audit stack).  By default, we use the
+fake audit backend at the Metadata level for all requests.  This guarantees that
+we always have an audit event on every request.  When an audit policy and
+backend are configured, requests are recorded per that policy, but requests
+that the policy would not audit still get a Metadata level event which is never
+sent to the backend.  Each event is annotated with how the original user's
+credential was authenticated (i.e. a certificate issued by the
+TokenCredentialRequest API, a Kubernetes client certificate, a service account
+token, or some other bearer token).
 
 One final wrinkle is that impersonation cannot impersonate UIDs (yet).  This is
 problematic because service account tokens always assert a UID.  To handle this
@@ -53,6 +63,12 @@ UID that was asserted via a bearer token, we simply pass the request through
 with the original bearer token and no impersonation headers set (as if the user
 had made the request directly against the Kubernetes API server).
 
+Service account tokens are recognized by their claims and validated by the
+impersonation proxy itself using the TokenReview API, with the results cached
+for a configurable amount of time (but never past the expiration of the token).
+The CredentialIssuer can be used to reject all service account tokens, or to
+only accept bound tokens (i.e. those issued by the TokenRequest API).
+
 For all normal requests, we only use http/2.0 when proxying to the API server.
 For upgrade requests, we only use http/1.1 since these always go from http/1.1
 to either websockets or SPDY.
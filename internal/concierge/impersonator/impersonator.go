@@ -32,7 +32,6 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	auditinternal "k8s.io/apiserver/pkg/apis/audit"
 	"k8s.io/apiserver/pkg/audit"
-	"k8s.io/apiserver/pkg/audit/policy"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
 	"k8s.io/apiserver/pkg/authentication/request/bearertoken"
 	"k8s.io/apiserver/pkg/authentication/user"
@@ -45,10 +44,13 @@ import (
 	"k8s.io/apiserver/pkg/server/dynamiccertificates"
 	"k8s.io/apiserver/pkg/server/filters"
 	genericoptions "k8s.io/apiserver/pkg/server/options"
-	auditfake "k8s.io/apiserver/plugin/pkg/audit/fake"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/transport"
+	"k8s.io/utils/clock"
 
+	"go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	"go.pinniped.dev/internal/concierge/frontendpolicy"
+	"go.pinniped.dev/internal/config/concierge"
 	"go.pinniped.dev/internal/constable"
 	"go.pinniped.dev/internal/crypto/ptls"
 	"go.pinniped.dev/internal/dynamiccert"
@@ -63,24 +65,37 @@ import (
 // That start function takes a stopCh which can be used to stop the server.
 // Once a server has been stopped, don't start it again using the start function.
 // Instead, call the factory function again to get a new start function.
+// The requestLimiter may be nil, in which case no rate limits or concurrency limits are enforced.
+// The serviceAccountTokenConfig may be nil, in which case service account tokens are treated like any other bearer token.
 type FactoryFunc func(
 	port int,
 	dynamicCertProvider dynamiccert.Private,
 	impersonationProxySignerCA dynamiccert.Public,
+	requestLimiter *RequestLimiter,
+	serviceAccountTokenConfig *ServiceAccountTokenConfig,
 ) (func(stopCh <-chan struct{}) error, error)
 
-func New(
-	port int,
-	dynamicCertProvider dynamiccert.Private,
-	impersonationProxySignerCA dynamiccert.Public,
-) (func(stopCh <-chan struct{}) error, error) {
-	return newInternal(port, dynamicCertProvider, impersonationProxySignerCA, kubeclient.Secure, nil, nil, nil)
+// NewFactory returns a FactoryFunc which creates impersonator servers. Requests served by those servers are
+// recorded as audit events as described by the given audit configuration, which may be nil to disable auditing.
+func NewFactory(auditConfig *concierge.ImpersonationProxyAuditSpec) FactoryFunc {
+	return func(
+		port int,
+		dynamicCertProvider dynamiccert.Private,
+		impersonationProxySignerCA dynamiccert.Public,
+		requestLimiter *RequestLimiter,
+		serviceAccountTokenConfig *ServiceAccountTokenConfig,
+	) (func(stopCh <-chan struct{}) error, error) {
+		return newInternal(port, dynamicCertProvider, impersonationProxySignerCA, auditConfig, requestLimiter, serviceAccountTokenConfig, kubeclient.Secure, nil, nil, nil)
+	}
 }
 
 func newInternal( //nolint:funlen // yeah, it's kind of long.
 	port int,
 	dynamicCertProvider dynamiccert.Private,
 	impersonationProxySignerCA dynamiccert.Public,
+	auditConfig *concierge.ImpersonationProxyAuditSpec,
+	requestLimiter *RequestLimiter,
+	serviceAccountTokenConfig *ServiceAccountTokenConfig,
 	restConfigFunc ptls.RestConfigFunc, // for unit testing, should always be kubeclient.Secure in production
 	clientOpts []kubeclient.Option, // for unit testing, should always be nil in production
 	recOpts func(*genericoptions.RecommendedOptions), // for unit testing, should always be nil in production
@@ -174,7 +189,7 @@ func newInternal( //nolint:funlen // yeah, it's kind of long.
 
 		// Assume proto config is safe because transport level configs do not use rest.ContentConfig.
 		// Thus if we are interacting with actual APIs, they should be using pre-built clients.
-		impersonationProxyFunc, err := newImpersonationReverseProxyFunc(rest.CopyConfig(kubeClientForProxy.ProtoConfig))
+		impersonationProxyFunc, err := newImpersonationReverseProxyFunc(rest.CopyConfig(kubeClientForProxy.ProtoConfig), impersonationProxySignerCA)
 		if err != nil {
 			return nil, err
 		}
@@ -194,6 +209,15 @@ func newInternal( //nolint:funlen // yeah, it's kind of long.
 			}))
 			handler = filterlatency.TrackStarted(handler, "impersonationproxy")
 
+			// Enforce rate limits and concurrency limits on the authenticated user.
+			// This runs after authentication and authorization so that it can tell users apart
+			// and so that unauthenticated clients cannot exhaust the limits of real users.
+			if requestLimiter != nil {
+				handler = filterlatency.TrackCompleted(handler)
+				handler = withRequestLimits(handler, requestLimiter, c)
+				handler = filterlatency.TrackStarted(handler, "requestlimits")
+			}
+
 			// The standard Kube handler chain (authn, authz, impersonation, audit, etc).
 			// See the genericapiserver.DefaultBuildHandlerChain func for details.
 			handler = defaultBuildHandlerChainFunc(handler, c)
@@ -211,9 +235,12 @@ func newInternal( //nolint:funlen // yeah, it's kind of long.
 			return handler
 		}
 
-		// wire up a fake audit backend at the metadata level so we can preserve the original user during nested impersonation
-		serverConfig.AuditPolicyRuleEvaluator = policy.NewFakePolicyRuleEvaluator(auditinternal.LevelMetadata, nil)
-		serverConfig.AuditBackend = &auditfake.Backend{}
+		// wire up an audit policy that always uses at least the metadata level so we can preserve the original user
+		// during nested impersonation, along with the configured backend (or a fake one when auditing is disabled)
+		serverConfig.AuditPolicyRuleEvaluator, serverConfig.AuditBackend, err = newAuditPolicyRuleEvaluatorAndBackend(auditConfig)
+		if err != nil {
+			return nil, fmt.Errorf("could not configure audit: %w", err)
+		}
 
 		// Probe the API server to figure out if anonymous auth is enabled.
 		anonymousAuthEnabled, err := isAnonymousAuthEnabled(kubeClientUnsafeForProxying.JSONConfig)
@@ -226,6 +253,16 @@ func newInternal( //nolint:funlen // yeah, it's kind of long.
 		// then we will need to update the related assumption in tokenPassthroughRoundTripper
 
 		delegatingAuthenticator := serverConfig.Authentication.Authenticator
+
+		// Validate service account tokens ourselves so that they can be disabled on a per-cluster basis
+		// and so that the TokenReview results can be cached for a configurable amount of time.
+		if serviceAccountTokenConfig != nil {
+			delegatingAuthenticator = newServiceAccountTokenAuthenticator(
+				serviceAccountTokenConfig,
+				kubeClientUnsafeForProxying.Kubernetes.AuthenticationV1().TokenReviews(),
+				clock.RealClock{},
+			).wrap(delegatingAuthenticator)
+		}
 		blockAnonymousAuthenticator := &comparableAuthenticator{
 			RequestFunc: func(req *http.Request) (*authenticator.Response, bool, error) {
 				resp, ok, err := delegatingAuthenticator.AuthenticateRequest(req)
@@ -457,7 +494,7 @@ type contextKey int
 
 const tokenKey contextKey = iota
 
-func newImpersonationReverseProxyFunc(restConfig *rest.Config) (func(*genericapiserver.Config) http.Handler, error) {
+func newImpersonationReverseProxyFunc(restConfig *rest.Config, impersonationProxySignerCA dynamiccert.Public) (func(*genericapiserver.Config) http.Handler, error) {
 	serverURL, err := url.Parse(restConfig.Host)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse host URL from in-cluster config: %w", err)
@@ -525,6 +562,9 @@ func newImpersonationReverseProxyFunc(restConfig *rest.Config) (func(*genericapi
 			// grab the request's bearer token if present.  this is optional and does not fail the request if missing.
 			token := tokenFrom(r.Context())
 
+			// record how the original user was authenticated so that audit events can be attributed to a credential source
+			audit.LogAnnotation(ae, authenticatorAuditAnnotationKey, authenticatorForRequest(r, ae, impersonationProxySignerCA))
+
 			// KAS only supports upgrades via http/1.1 to websockets/SPDY (upgrades never use http/2.0)
 			// Thus we default to using http/2.0 when the request is not an upgrade, otherwise we use http/1.1
 			baseRT, baseRTAnonymous := http2RoundTripper, http2RoundTripperAnonymous
@@ -533,7 +573,11 @@ func newImpersonationReverseProxyFunc(restConfig *rest.Config) (func(*genericapi
 				baseRT, baseRTAnonymous = http1RoundTripper, http1RoundTripperAnonymous
 			}
 
-			rt, err := getTransportForUser(r.Context(), userInfo, baseRT, baseRTAnonymous, ae, token, c.Authentication.Authenticator)
+			// mark TokenCredentialRequests so that the TokenCredentialRequest API knows they came through this frontend
+			reqInfo, ok := genericapirequest.RequestInfoFrom(r.Context())
+			isTokenCredentialRequest := ok && reqInfo.IsResourceRequest && isTokenCredReq(reqInfo)
+
+			rt, err := getTransportForUser(r.Context(), userInfo, baseRT, baseRTAnonymous, ae, token, c.Authentication.Authenticator, isTokenCredentialRequest)
 			if err != nil {
 				plog.WarningErr("rejecting request as we cannot act as the current user", err,
 					"url", r.URL.String(),
@@ -634,14 +678,24 @@ func ensureNoImpersonationHeaders(r *http.Request) error {
 	return nil
 }
 
-func getTransportForUser(ctx context.Context, userInfo user.Info, delegate, delegateAnonymous http.RoundTripper, ae *auditinternal.Event, token string, authenticator authenticator.Request) (http.RoundTripper, error) {
+func getTransportForUser(ctx context.Context, userInfo user.Info, delegate, delegateAnonymous http.RoundTripper, ae *auditinternal.Event, token string, authenticator authenticator.Request, isTokenCredentialRequest bool) (http.RoundTripper, error) {
 	if canImpersonateFully(userInfo) {
-		return standardImpersonationRoundTripper(userInfo, ae, delegate)
+		return standardImpersonationRoundTripper(userInfo, ae, delegate, isTokenCredentialRequest)
+	}
+
+	// the TokenCredentialRequest API authenticates the token in the request body and not the caller, so we can make the
+	// request as the anonymous user instead of passing through the token. this way the request is still marked as coming
+	// through this frontend, which the TokenCredentialRequest API needs to enforce the frontend policy.
+	if isTokenCredentialRequest && ae.ImpersonatedUser == nil {
+		return standardImpersonationRoundTripper(anonymousUser, ae, delegate, isTokenCredentialRequest)
 	}
 
 	return tokenPassthroughRoundTripper(ctx, delegateAnonymous, ae, token, authenticator)
 }
 
+// anonymousUser is who TokenCredentialRequests are made as when the user cannot be impersonated.
+var anonymousUser = &user.DefaultInfo{Name: user.Anonymous, Groups: []string{user.AllUnauthenticated}}
+
 func canImpersonateFully(userInfo user.Info) bool {
 	// nolint: gosimple  // this structure is on purpose because we plan to expand this function
 	if len(userInfo.GetUID()) == 0 {
@@ -653,8 +707,8 @@ func canImpersonateFully(userInfo user.Info) bool {
 	return false
 }
 
-func standardImpersonationRoundTripper(userInfo user.Info, ae *auditinternal.Event, delegate http.RoundTripper) (http.RoundTripper, error) {
-	extra, err := buildExtra(userInfo.GetExtra(), ae)
+func standardImpersonationRoundTripper(userInfo user.Info, ae *auditinternal.Event, delegate http.RoundTripper, isTokenCredentialRequest bool) (http.RoundTripper, error) {
+	extra, err := buildExtra(userInfo.GetExtra(), ae, isTokenCredentialRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -725,7 +779,7 @@ func tokenReview(ctx context.Context, token string, authenticator authenticator.
 	// propagate cancellation of parent context (without any values such as audience)
 	fakeReq = fakeReq.WithContext(valuelesscontext.New(ctx))
 
-	// this will almost always be a free call that hits our 10 second cache TTL
+	// this will almost always be a free call that hits one of our token caches
 	resp, ok, err := authenticator.AuthenticateRequest(fakeReq)
 	if err != nil {
 		return authenticationv1.UserInfo{}, err
@@ -747,7 +801,7 @@ func tokenReview(ctx context.Context, token string, authenticator authenticator.
 	return tokenUser, nil
 }
 
-func buildExtra(extra map[string][]string, ae *auditinternal.Event) (map[string][]string, error) {
+func buildExtra(extra map[string][]string, ae *auditinternal.Event, isTokenCredentialRequest bool) (map[string][]string, error) {
 	const reservedImpersonationProxySuffix = ".impersonation-proxy.concierge.pinniped.dev"
 
 	// always validate that the extra is something we support irregardless of nested impersonation
@@ -761,23 +815,30 @@ func buildExtra(extra map[string][]string, ae *auditinternal.Event) (map[string]
 		}
 	}
 
-	if ae.ImpersonatedUser == nil {
+	if ae.ImpersonatedUser == nil && !isTokenCredentialRequest {
 		return extra, nil // just return the given extra since nested impersonation is not being used
 	}
 
-	// avoid mutating input map, preallocate new map to store original user info
-	out := make(map[string][]string, len(extra)+1)
+	// avoid mutating input map, preallocate new map to store original user info and the frontend marker
+	out := make(map[string][]string, len(extra)+2)
 
 	for k, v := range extra {
 		out[k] = v // shallow copy of slice since we are not going to mutate it
 	}
 
-	origUserInfoJSON, err := json.Marshal(ae.User)
-	if err != nil {
-		return nil, err
+	if ae.ImpersonatedUser != nil {
+		origUserInfoJSON, err := json.Marshal(ae.User)
+		if err != nil {
+			return nil, err
+		}
+
+		out["original-user-info"+reservedImpersonationProxySuffix] = []string{string(origUserInfoJSON)}
 	}
 
-	out["original-user-info"+reservedImpersonationProxySuffix] = []string{string(origUserInfoJSON)}
+	// let the TokenCredentialRequest API know which frontend was used so that it can enforce the frontend policy
+	if isTokenCredentialRequest {
+		out[frontendpolicy.ImpersonationProxyExtraKey] = []string{string(v1alpha1.ImpersonationProxyFrontendType)}
+	}
 
 	return out, nil
 }
@@ -0,0 +1,235 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package impersonator
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apiserver/pkg/audit"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/utils/clock"
+
+	"go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// limitsRetryAfterSeconds is the value of the Retry-After header sent to clients which exceed a limit.
+	limitsRetryAfterSeconds = 1
+
+	// limitsIdleSweepInterval is how often unused per-user limiters are garbage collected.
+	limitsIdleSweepInterval = time.Minute
+)
+
+// RequestLimiter enforces the rate limits and concurrency limits of the impersonation proxy.
+// Its limits may be changed at any time, including while the impersonation proxy is running.
+type RequestLimiter struct {
+	clock clock.PassiveClock
+
+	lock      sync.Mutex
+	limits    v1alpha1.ImpersonationProxyLimitsSpec
+	global    *userLimiter
+	perUser   map[string]*userLimiter
+	lastSweep time.Time
+}
+
+// userLimiter tracks the usage of a single set of limits.
+type userLimiter struct {
+	limits      v1alpha1.ImpersonationProxyRequestLimits
+	rateLimiter *rate.Limiter // nil when there is no QPS limit
+	inFlight    int32
+	lastUsed    time.Time
+}
+
+// NewRequestLimiter returns a RequestLimiter which does not enforce any limits until SetLimits is called.
+func NewRequestLimiter(clock clock.PassiveClock) *RequestLimiter {
+	return &RequestLimiter{
+		clock:   clock,
+		perUser: map[string]*userLimiter{},
+	}
+}
+
+// SetLimits replaces the currently enforced limits. A nil value disables all limits.
+// Setting the same limits again is a no-op so that callers may freely call this on every sync.
+func (l *RequestLimiter) SetLimits(limits *v1alpha1.ImpersonationProxyLimitsSpec) {
+	var newLimits v1alpha1.ImpersonationProxyLimitsSpec
+	if limits != nil {
+		newLimits = *limits.DeepCopy()
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if apiequality.Semantic.DeepEqual(l.limits, newLimits) {
+		return
+	}
+
+	l.limits = newLimits
+	l.global = nil
+	if newLimits.Global != nil {
+		l.global = newUserLimiter(*newLimits.Global)
+	}
+	l.perUser = map[string]*userLimiter{}
+}
+
+// tryAcquire attempts to admit a request from the given user. When the request is admitted, the returned
+// release func must be called once the request has been served. Long-running requests are not counted
+// towards the in-flight limits but they are still subject to the QPS limits.
+func (l *RequestLimiter) tryAcquire(username string, longRunning bool) (release func(), ok bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.clock.Now()
+	l.sweepIdleLocked(now)
+
+	var perUser *userLimiter
+	if l.limits.PerUser != nil {
+		perUser = l.perUser[username]
+		if perUser == nil {
+			perUser = newUserLimiter(*l.limits.PerUser)
+			l.perUser[username] = perUser
+		}
+		perUser.lastUsed = now
+	}
+
+	// check the in-flight limits first since they do not consume anything
+	if !longRunning && (l.global.atMaxInFlight() || perUser.atMaxInFlight()) {
+		return nil, false
+	}
+
+	// a user must not be charged for a request which the global limits reject
+	cancelPerUser, ok := perUser.tryReserve(now)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := l.global.tryReserve(now); !ok {
+		cancelPerUser()
+		return nil, false
+	}
+
+	if longRunning {
+		return func() {}, true
+	}
+
+	global := l.global
+	global.addInFlight(1)
+	perUser.addInFlight(1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.lock.Lock()
+			defer l.lock.Unlock()
+
+			global.addInFlight(-1)
+			perUser.addInFlight(-1)
+		})
+	}, true
+}
+
+// sweepIdleLocked forgets about users whose limiters are indistinguishable from new ones, i.e. they have
+// no requests in flight and their token bucket has had enough time to refill completely.
+func (l *RequestLimiter) sweepIdleLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < limitsIdleSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for username, perUser := range l.perUser {
+		if perUser.inFlight == 0 && now.Sub(perUser.lastUsed) >= perUser.refillDuration() {
+			delete(l.perUser, username)
+		}
+	}
+}
+
+func newUserLimiter(limits v1alpha1.ImpersonationProxyRequestLimits) *userLimiter {
+	u := &userLimiter{limits: limits}
+	if limits.QPS > 0 {
+		u.rateLimiter = rate.NewLimiter(rate.Limit(limits.QPS), int(burstFor(limits)))
+	}
+	return u
+}
+
+// The methods below are nil-safe so that callers do not need to care about which limits are configured.
+
+func (u *userLimiter) atMaxInFlight() bool {
+	return u != nil && u.limits.MaxRequestsInFlight > 0 && u.inFlight >= u.limits.MaxRequestsInFlight
+}
+
+// tryReserve takes a token from the token bucket when one is available. The returned cancel func gives it back.
+func (u *userLimiter) tryReserve(now time.Time) (cancel func(), ok bool) {
+	if u == nil || u.rateLimiter == nil {
+		return func() {}, true
+	}
+	reservation := u.rateLimiter.ReserveN(now, 1)
+	if !reservation.OK() || reservation.DelayFrom(now) > 0 {
+		reservation.CancelAt(now)
+		return nil, false
+	}
+	return func() { reservation.CancelAt(now) }, true
+}
+
+func (u *userLimiter) addInFlight(delta int32) {
+	if u != nil {
+		u.inFlight += delta
+	}
+}
+
+func (u *userLimiter) refillDuration() time.Duration {
+	if u.limits.QPS <= 0 {
+		return 0
+	}
+	return time.Duration(burstFor(u.limits)) * time.Second / time.Duration(u.limits.QPS)
+}
+
+// burstFor returns the configured burst, which defaults to the QPS.
+func burstFor(limits v1alpha1.ImpersonationProxyRequestLimits) int32 {
+	if limits.Burst > 0 {
+		return limits.Burst
+	}
+	return limits.QPS
+}
+
+func withRequestLimits(delegate http.Handler, limiter *RequestLimiter, c *genericapiserver.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestInfo, ok := genericapirequest.RequestInfoFrom(r.Context())
+		if !ok {
+			newInternalErrResponse(w, r, c.Serializer, "no RequestInfo found in the context")
+			return
+		}
+
+		ae := audit.AuditEventFrom(r.Context())
+		if ae == nil {
+			newInternalErrResponse(w, r, c.Serializer, "invalid audit event")
+			return
+		}
+
+		// limits apply to the authenticated user and not to any user they may be impersonating
+		username := ae.User.Username
+		longRunning := c.LongRunningFunc != nil && c.LongRunningFunc(r, requestInfo)
+
+		release, ok := limiter.tryAcquire(username, longRunning)
+		if !ok {
+			plog.Debug("impersonation proxy rejected request due to limits",
+				"url", r.URL.String(),
+				"method", r.Method,
+			)
+			newStatusErrResponse(w, r, c.Serializer, apierrors.NewTooManyRequests(
+				fmt.Sprintf("too many requests, please try again in %d seconds", limitsRetryAfterSeconds),
+				limitsRetryAfterSeconds,
+			))
+			return
+		}
+		defer release()
+
+		delegate.ServeHTTP(w, r)
+	})
+}
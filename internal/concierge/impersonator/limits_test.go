@@ -0,0 +1,283 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package impersonator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	auditinternal "k8s.io/apiserver/pkg/apis/audit"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+)
+
+func TestRequestLimiter(t *testing.T) {
+	t.Parallel()
+
+	requireAcquire := func(t *testing.T, l *RequestLimiter, username string, longRunning bool) func() {
+		t.Helper()
+		release, ok := l.tryAcquire(username, longRunning)
+		require.True(t, ok, "request for %s should have been admitted", username)
+		require.NotNil(t, release)
+		return release
+	}
+
+	requireReject := func(t *testing.T, l *RequestLimiter, username string, longRunning bool) {
+		t.Helper()
+		release, ok := l.tryAcquire(username, longRunning)
+		require.False(t, ok, "request for %s should have been rejected", username)
+		require.Nil(t, release)
+	}
+
+	t.Run("no limits", func(t *testing.T) {
+		t.Parallel()
+		l := NewRequestLimiter(clocktesting.NewFakeClock(time.Now()))
+
+		for i := 0; i < 100; i++ {
+			requireAcquire(t, l, "some-user", false)
+		}
+
+		l.SetLimits(&v1alpha1.ImpersonationProxyLimitsSpec{})
+		for i := 0; i < 100; i++ {
+			requireAcquire(t, l, "some-user", false)
+		}
+	})
+
+	t.Run("per user in flight limit", func(t *testing.T) {
+		t.Parallel()
+		l := NewRequestLimiter(clocktesting.NewFakeClock(time.Now()))
+		l.SetLimits(&v1alpha1.ImpersonationProxyLimitsSpec{
+			PerUser: &v1alpha1.ImpersonationProxyRequestLimits{MaxRequestsInFlight: 2},
+		})
+
+		release1 := requireAcquire(t, l, "user-1", false)
+		release2 := requireAcquire(t, l, "user-1", false)
+		requireReject(t, l, "user-1", false)
+
+		// other users are not affected and long-running requests are not counted
+		requireAcquire(t, l, "user-2", false)
+		requireAcquire(t, l, "user-1", true)
+
+		release1()
+		release1() // releasing twice is harmless
+		requireAcquire(t, l, "user-1", false)
+		requireReject(t, l, "user-1", false)
+
+		release2()
+		requireAcquire(t, l, "user-1", false)
+	})
+
+	t.Run("global in flight limit", func(t *testing.T) {
+		t.Parallel()
+		l := NewRequestLimiter(clocktesting.NewFakeClock(time.Now()))
+		l.SetLimits(&v1alpha1.ImpersonationProxyLimitsSpec{
+			Global: &v1alpha1.ImpersonationProxyRequestLimits{MaxRequestsInFlight: 2},
+		})
+
+		release := requireAcquire(t, l, "user-1", false)
+		requireAcquire(t, l, "user-2", false)
+		requireReject(t, l, "user-3", false)
+		requireAcquire(t, l, "user-3", true)
+
+		release()
+		requireAcquire(t, l, "user-3", false)
+	})
+
+	t.Run("per user QPS limit with burst", func(t *testing.T) {
+		t.Parallel()
+		clock := clocktesting.NewFakeClock(time.Now())
+		l := NewRequestLimiter(clock)
+		l.SetLimits(&v1alpha1.ImpersonationProxyLimitsSpec{
+			PerUser: &v1alpha1.ImpersonationProxyRequestLimits{QPS: 1, Burst: 3},
+		})
+
+		for i := 0; i < 3; i++ {
+			requireAcquire(t, l, "user-1", false)
+		}
+		requireReject(t, l, "user-1", false)
+		requireReject(t, l, "user-1", true) // long-running requests are subject to QPS limits
+		requireAcquire(t, l, "user-2", false)
+
+		clock.Step(time.Second)
+		requireAcquire(t, l, "user-1", false)
+		requireReject(t, l, "user-1", false)
+	})
+
+	t.Run("global QPS limit defaults burst to QPS", func(t *testing.T) {
+		t.Parallel()
+		clock := clocktesting.NewFakeClock(time.Now())
+		l := NewRequestLimiter(clock)
+		l.SetLimits(&v1alpha1.ImpersonationProxyLimitsSpec{
+			Global: &v1alpha1.ImpersonationProxyRequestLimits{QPS: 2},
+		})
+
+		requireAcquire(t, l, "user-1", false)
+		requireAcquire(t, l, "user-2", false)
+		requireReject(t, l, "user-3", false)
+
+		clock.Step(time.Second)
+		requireAcquire(t, l, "user-3", false)
+	})
+
+	t.Run("requests rejected by the global limits do not count towards the per user limits", func(t *testing.T) {
+		t.Parallel()
+		clock := clocktesting.NewFakeClock(time.Now())
+		l := NewRequestLimiter(clock)
+		l.SetLimits(&v1alpha1.ImpersonationProxyLimitsSpec{
+			Global:  &v1alpha1.ImpersonationProxyRequestLimits{QPS: 2},
+			PerUser: &v1alpha1.ImpersonationProxyRequestLimits{QPS: 1, Burst: 2},
+		})
+
+		requireAcquire(t, l, "user-1", false)
+		requireAcquire(t, l, "user-1", false)
+		requireReject(t, l, "user-2", false)
+		requireReject(t, l, "user-2", false)
+
+		// only the global limits have refilled enough for one more request, and user-2 still has their full burst
+		clock.Step(500 * time.Millisecond)
+		requireAcquire(t, l, "user-2", false)
+		clock.Step(500 * time.Millisecond)
+		requireAcquire(t, l, "user-2", false)
+	})
+
+	t.Run("setting the same limits does not reset usage", func(t *testing.T) {
+		t.Parallel()
+		l := NewRequestLimiter(clocktesting.NewFakeClock(time.Now()))
+		l.SetLimits(&v1alpha1.ImpersonationProxyLimitsSpec{
+			PerUser: &v1alpha1.ImpersonationProxyRequestLimits{QPS: 1},
+		})
+
+		requireAcquire(t, l, "user-1", false)
+		requireReject(t, l, "user-1", false)
+
+		l.SetLimits(&v1alpha1.ImpersonationProxyLimitsSpec{
+			PerUser: &v1alpha1.ImpersonationProxyRequestLimits{QPS: 1},
+		})
+		requireReject(t, l, "user-1", false)
+
+		l.SetLimits(&v1alpha1.ImpersonationProxyLimitsSpec{
+			PerUser: &v1alpha1.ImpersonationProxyRequestLimits{QPS: 2},
+		})
+		requireAcquire(t, l, "user-1", false)
+
+		l.SetLimits(nil)
+		requireAcquire(t, l, "user-1", false)
+	})
+
+	t.Run("idle users are forgotten", func(t *testing.T) {
+		t.Parallel()
+		clock := clocktesting.NewFakeClock(time.Now())
+		l := NewRequestLimiter(clock)
+		l.SetLimits(&v1alpha1.ImpersonationProxyLimitsSpec{
+			PerUser: &v1alpha1.ImpersonationProxyRequestLimits{QPS: 1, Burst: 90, MaxRequestsInFlight: 100},
+		})
+
+		release := requireAcquire(t, l, "busy-user", false)
+		requireAcquire(t, l, "idle-user", false)()
+		require.Len(t, l.perUser, 2)
+
+		clock.Step(limitsIdleSweepInterval)
+		requireAcquire(t, l, "other-user", false)()
+		require.Len(t, l.perUser, 3, "tokens have not been refilled yet")
+
+		clock.Step(limitsIdleSweepInterval)
+		requireAcquire(t, l, "other-user", false)()
+		require.Len(t, l.perUser, 2, "the busy user still has a request in flight")
+		require.Contains(t, l.perUser, "busy-user")
+		require.Contains(t, l.perUser, "other-user")
+
+		release()
+		clock.Step(limitsIdleSweepInterval)
+		requireAcquire(t, l, "other-user", false)
+		require.Len(t, l.perUser, 1)
+		require.Contains(t, l.perUser, "other-user")
+	})
+}
+
+func TestWithRequestLimits(t *testing.T) {
+	t.Parallel()
+
+	// this is not a valid way to get a server config, but it is good enough for a unit test
+	scheme := runtime.NewScheme()
+	metav1.AddToGroupVersion(scheme, metav1.Unversioned)
+	codecs := serializer.NewCodecFactory(scheme)
+	serverConfig := genericapiserver.NewRecommendedConfig(codecs)
+	serverConfig.LongRunningFunc = func(r *http.Request, requestInfo *request.RequestInfo) bool {
+		return r.URL.Query().Get("watch") == "true"
+	}
+
+	limiter := NewRequestLimiter(clocktesting.NewFakeClock(time.Now()))
+	limiter.SetLimits(&v1alpha1.ImpersonationProxyLimitsSpec{
+		PerUser: &v1alpha1.ImpersonationProxyRequestLimits{MaxRequestsInFlight: 1},
+	})
+
+	var delegateCalls int
+	var nested http.Handler
+	handler := withRequestLimits(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delegateCalls++
+		if nested != nil {
+			nested.ServeHTTP(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}), limiter, &serverConfig.Config)
+
+	newRequestFor := func(username, impersonatedUsername string) *http.Request {
+		ae := &auditinternal.Event{
+			Level: auditinternal.LevelMetadata,
+			User:  authenticationv1.UserInfo{Username: username},
+		}
+		userInfo := &user.DefaultInfo{Name: username}
+		if impersonatedUsername != "" {
+			ae.ImpersonatedUser = &authenticationv1.UserInfo{Username: impersonatedUsername}
+			userInfo = &user.DefaultInfo{Name: impersonatedUsername}
+		}
+		return newRequest(t, http.Header{}, userInfo, ae, "")
+	}
+
+	// a single request is under the limit
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequestFor("some-user", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 1, delegateCalls)
+
+	// a second concurrent request from the same user is rejected, even when impersonating someone else
+	nestedRecorder := httptest.NewRecorder()
+	nested = http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		nested = nil
+		handler.ServeHTTP(nestedRecorder, newRequestFor("some-user", "some-other-user"))
+	})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequestFor("some-user", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 2, delegateCalls)
+	require.Equal(t, http.StatusTooManyRequests, nestedRecorder.Code)
+	require.Equal(t, "1", nestedRecorder.Header().Get("Retry-After"))
+	require.Contains(t, nestedRecorder.Body.String(), "too many requests, please try again in 1 seconds")
+
+	// long-running requests do not count towards the in flight limit
+	nestedRecorder = httptest.NewRecorder()
+	nested = http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		nested = nil
+		r := newRequestFor("some-user", "")
+		r.URL.RawQuery = "watch=true"
+		handler.ServeHTTP(nestedRecorder, r)
+	})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequestFor("some-user", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, http.StatusOK, nestedRecorder.Code)
+	require.Equal(t, 4, delegateCalls)
+}
@@ -0,0 +1,279 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package impersonator
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/square/go-jose.v2/jwt"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	authenticationv1client "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	"k8s.io/utils/clock"
+
+	"go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// defaultServiceAccountTokenCacheTTL matches the default token cache TTL of the delegating authenticator.
+	defaultServiceAccountTokenCacheTTL = 10 * time.Second
+
+	// serviceAccountTokenCacheSweepInterval is how often expired token review results are garbage collected.
+	serviceAccountTokenCacheSweepInterval = time.Minute
+
+	// legacyServiceAccountTokenIssuer is the issuer of the tokens which are stored in service account token Secrets.
+	legacyServiceAccountTokenIssuer = "kubernetes/serviceaccount"
+
+	errServiceAccountTokensDisabled       = constable.Error("service account tokens are disabled")
+	errLegacyServiceAccountTokensDisabled = constable.Error("legacy service account tokens are disabled")
+	errServiceAccountTokenInvalid         = constable.Error("service account token failed to authenticate")
+)
+
+// ServiceAccountTokenConfig holds the configuration for how the impersonation proxy handles service account tokens.
+// It may be changed at any time, including while the impersonation proxy is running.
+type ServiceAccountTokenConfig struct {
+	lock     sync.RWMutex
+	mode     v1alpha1.ImpersonationProxyServiceAccountTokensMode
+	cacheTTL time.Duration
+}
+
+// NewServiceAccountTokenConfig returns a ServiceAccountTokenConfig which accepts all valid service account tokens
+// until SetConfig is called.
+func NewServiceAccountTokenConfig() *ServiceAccountTokenConfig {
+	return &ServiceAccountTokenConfig{
+		mode:     v1alpha1.ImpersonationProxyServiceAccountTokensModeEnabled,
+		cacheTTL: defaultServiceAccountTokenCacheTTL,
+	}
+}
+
+// SetConfig replaces the current configuration. A nil value restores the defaults.
+func (c *ServiceAccountTokenConfig) SetConfig(spec *v1alpha1.ImpersonationProxyServiceAccountTokensSpec) {
+	mode := v1alpha1.ImpersonationProxyServiceAccountTokensModeEnabled
+	cacheTTL := defaultServiceAccountTokenCacheTTL
+	if spec != nil {
+		if len(spec.Mode) != 0 {
+			mode = spec.Mode
+		}
+		if spec.CacheTTLSeconds > 0 {
+			cacheTTL = time.Duration(spec.CacheTTLSeconds) * time.Second
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.mode = mode
+	c.cacheTTL = cacheTTL
+}
+
+func (c *ServiceAccountTokenConfig) get() (v1alpha1.ImpersonationProxyServiceAccountTokensMode, time.Duration) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.mode, c.cacheTTL
+}
+
+// serviceAccountTokenClaims are the claims which distinguish service account tokens from other bearer tokens.
+type serviceAccountTokenClaims struct {
+	jwt.Claims
+
+	// set on bound tokens, i.e. those issued by the TokenRequest API
+	Kubernetes *struct {
+		Namespace string `json:"namespace"`
+	} `json:"kubernetes.io,omitempty"`
+
+	// set on legacy tokens, i.e. those stored in service account token Secrets
+	LegacyNamespace string `json:"kubernetes.io/serviceaccount/namespace,omitempty"`
+}
+
+// parseServiceAccountToken determines if the given token looks like a service account token without validating it.
+// This is only used to decide how the token should be validated, which is always done via the TokenReview API.
+func parseServiceAccountToken(token string) (*serviceAccountTokenClaims, bool) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, false
+	}
+
+	var claims serviceAccountTokenClaims
+	if err := parsed.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, false
+	}
+
+	if claims.isBound() || (claims.Issuer == legacyServiceAccountTokenIssuer && len(claims.LegacyNamespace) != 0) {
+		return &claims, true
+	}
+
+	return nil, false
+}
+
+func (c *serviceAccountTokenClaims) isBound() bool {
+	return c.Kubernetes != nil && len(c.Kubernetes.Namespace) != 0
+}
+
+// serviceAccountTokenAuthenticator validates service account tokens using the TokenReview API and caches the results.
+type serviceAccountTokenAuthenticator struct {
+	config       *ServiceAccountTokenConfig
+	tokenReviews authenticationv1client.TokenReviewInterface
+	clock        clock.PassiveClock
+
+	lock      sync.Mutex
+	cache     map[[sha256.Size]byte]*tokenReviewCacheEntry
+	lastSweep time.Time
+}
+
+type tokenReviewCacheEntry struct {
+	resp   *authenticator.Response // nil when the token failed to authenticate
+	expiry time.Time
+}
+
+func newServiceAccountTokenAuthenticator(
+	config *ServiceAccountTokenConfig,
+	tokenReviews authenticationv1client.TokenReviewInterface,
+	clock clock.PassiveClock,
+) *serviceAccountTokenAuthenticator {
+	return &serviceAccountTokenAuthenticator{
+		config:       config,
+		tokenReviews: tokenReviews,
+		clock:        clock,
+		cache:        map[[sha256.Size]byte]*tokenReviewCacheEntry{},
+	}
+}
+
+// wrap returns an authenticator which validates service account tokens and delegates all other credentials.
+// Client certificates take precedence over bearer tokens, just like they do in the delegate.
+func (a *serviceAccountTokenAuthenticator) wrap(delegate authenticator.Request) authenticator.Request {
+	return authenticator.RequestFunc(func(req *http.Request) (*authenticator.Response, bool, error) {
+		if req.TLS != nil && len(req.TLS.PeerCertificates) != 0 {
+			return delegate.AuthenticateRequest(req)
+		}
+
+		token, ok := bearerTokenFrom(req)
+		if !ok {
+			return delegate.AuthenticateRequest(req)
+		}
+
+		claims, ok := parseServiceAccountToken(token)
+		if !ok {
+			return delegate.AuthenticateRequest(req)
+		}
+
+		return a.authenticate(req.Context(), token, claims)
+	})
+}
+
+func (a *serviceAccountTokenAuthenticator) authenticate(ctx context.Context, token string, claims *serviceAccountTokenClaims) (*authenticator.Response, bool, error) {
+	mode, cacheTTL := a.config.get()
+
+	switch mode {
+	case v1alpha1.ImpersonationProxyServiceAccountTokensModeDisabled:
+		return nil, false, errServiceAccountTokensDisabled
+	case v1alpha1.ImpersonationProxyServiceAccountTokensModeBoundOnly:
+		if !claims.isBound() {
+			return nil, false, errLegacyServiceAccountTokensDisabled
+		}
+	}
+
+	key := sha256.Sum256([]byte(token))
+
+	if entry, ok := a.getCached(key); ok {
+		if entry.resp == nil {
+			return nil, false, errServiceAccountTokenInvalid
+		}
+		return entry.resp, true, nil
+	}
+
+	// leave audiences unset so that the token must be valid against the Kube API server itself
+	tokenReview, err := a.tokenReviews.Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		// do not cache transient errors
+		plog.DebugErr("service account token review failed", err)
+		return nil, false, err
+	}
+
+	entry := &tokenReviewCacheEntry{expiry: a.clock.Now().Add(cacheTTL)}
+	if claims.Expiry != nil && claims.Expiry.Time().Before(entry.expiry) {
+		entry.expiry = claims.Expiry.Time()
+	}
+
+	if tokenReview.Status.Authenticated {
+		extra := make(map[string][]string, len(tokenReview.Status.User.Extra))
+		for k, v := range tokenReview.Status.User.Extra {
+			extra[k] = v
+		}
+		entry.resp = &authenticator.Response{
+			User: &user.DefaultInfo{
+				Name:   tokenReview.Status.User.Username,
+				UID:    tokenReview.Status.User.UID,
+				Groups: tokenReview.Status.User.Groups,
+				Extra:  extra,
+			},
+		}
+	}
+
+	a.putCached(key, entry)
+
+	if entry.resp == nil {
+		return nil, false, errServiceAccountTokenInvalid
+	}
+	return entry.resp, true, nil
+}
+
+func (a *serviceAccountTokenAuthenticator) getCached(key [sha256.Size]byte) (*tokenReviewCacheEntry, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	entry, ok := a.cache[key]
+	if !ok || !a.clock.Now().Before(entry.expiry) {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (a *serviceAccountTokenAuthenticator) putCached(key [sha256.Size]byte, entry *tokenReviewCacheEntry) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	now := a.clock.Now()
+	if now.Sub(a.lastSweep) >= serviceAccountTokenCacheSweepInterval {
+		a.lastSweep = now
+		for k, e := range a.cache {
+			if !now.Before(e.expiry) {
+				delete(a.cache, k)
+			}
+		}
+	}
+
+	a.cache[key] = entry
+}
+
+// bearerTokenFrom mirrors the parsing done by the bearertoken authenticator.
+func bearerTokenFrom(req *http.Request) (string, bool) {
+	auth := strings.TrimSpace(req.Header.Get("Authorization"))
+	if len(auth) == 0 {
+		return "", false
+	}
+
+	parts := strings.SplitN(auth, " ", 3)
+	if len(parts) < 2 || strings.ToLower(parts[0]) != "bearer" {
+		return "", false
+	}
+
+	token := parts[1]
+	if len(token) == 0 {
+		return "", false
+	}
+
+	return token, true
+}
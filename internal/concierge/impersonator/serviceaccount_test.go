@@ -0,0 +1,382 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package impersonator
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	coretesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+)
+
+func TestParseServiceAccountToken(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		token     string
+		wantOK    bool
+		wantBound bool
+	}{
+		{
+			name:      "bound token",
+			token:     newServiceAccountToken(t, boundServiceAccountTokenClaims(now.Add(time.Hour))),
+			wantOK:    true,
+			wantBound: true,
+		},
+		{
+			name:   "legacy token",
+			token:  newServiceAccountToken(t, legacyServiceAccountTokenClaims()),
+			wantOK: true,
+		},
+		{
+			name: "legacy namespace claim with some other issuer",
+			token: newServiceAccountToken(t, map[string]interface{}{
+				"iss":                                    "https://some-issuer.example.com",
+				"kubernetes.io/serviceaccount/namespace": "some-namespace",
+			}),
+		},
+		{
+			name:  "some other JWT",
+			token: newServiceAccountToken(t, map[string]interface{}{"iss": "https://some-issuer.example.com", "sub": "some-subject"}),
+		},
+		{
+			name:  "not a JWT",
+			token: "some-opaque-token",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			claims, ok := parseServiceAccountToken(tt.token)
+			require.Equal(t, tt.wantOK, ok)
+			if !tt.wantOK {
+				require.Nil(t, claims)
+				return
+			}
+			require.Equal(t, tt.wantBound, claims.isBound())
+		})
+	}
+}
+
+func TestServiceAccountTokenConfig(t *testing.T) {
+	t.Parallel()
+
+	config := NewServiceAccountTokenConfig()
+	requireConfig := func(wantMode v1alpha1.ImpersonationProxyServiceAccountTokensMode, wantTTL time.Duration) {
+		t.Helper()
+		mode, ttl := config.get()
+		require.Equal(t, wantMode, mode)
+		require.Equal(t, wantTTL, ttl)
+	}
+
+	requireConfig(v1alpha1.ImpersonationProxyServiceAccountTokensModeEnabled, 10*time.Second)
+
+	config.SetConfig(&v1alpha1.ImpersonationProxyServiceAccountTokensSpec{
+		Mode:            v1alpha1.ImpersonationProxyServiceAccountTokensModeBoundOnly,
+		CacheTTLSeconds: 60,
+	})
+	requireConfig(v1alpha1.ImpersonationProxyServiceAccountTokensModeBoundOnly, time.Minute)
+
+	config.SetConfig(&v1alpha1.ImpersonationProxyServiceAccountTokensSpec{})
+	requireConfig(v1alpha1.ImpersonationProxyServiceAccountTokensModeEnabled, 10*time.Second)
+
+	config.SetConfig(&v1alpha1.ImpersonationProxyServiceAccountTokensSpec{Mode: v1alpha1.ImpersonationProxyServiceAccountTokensModeDisabled})
+	requireConfig(v1alpha1.ImpersonationProxyServiceAccountTokensModeDisabled, 10*time.Second)
+
+	config.SetConfig(nil)
+	requireConfig(v1alpha1.ImpersonationProxyServiceAccountTokensModeEnabled, 10*time.Second)
+}
+
+func TestServiceAccountTokenAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	boundToken := newServiceAccountToken(t, boundServiceAccountTokenClaims(now.Add(time.Hour)))
+	shortLivedBoundToken := newServiceAccountToken(t, boundServiceAccountTokenClaims(now.Add(5*time.Second)))
+	legacyToken := newServiceAccountToken(t, legacyServiceAccountTokenClaims())
+	invalidToken := newServiceAccountToken(t, boundServiceAccountTokenClaims(now.Add(time.Hour).Add(time.Minute)))
+	otherToken := "some-opaque-token"
+
+	saUser := authenticationv1.UserInfo{
+		Username: "system:serviceaccount:some-namespace:some-service-account",
+		UID:      "some-uid",
+		Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:some-namespace", "system:authenticated"},
+		Extra: map[string]authenticationv1.ExtraValue{
+			"authentication.kubernetes.io/pod-name": {"some-pod"},
+		},
+	}
+	wantResp := &authenticator.Response{
+		User: &user.DefaultInfo{
+			Name:   saUser.Username,
+			UID:    saUser.UID,
+			Groups: saUser.Groups,
+			Extra:  map[string][]string{"authentication.kubernetes.io/pod-name": {"some-pod"}},
+		},
+	}
+	delegateResp := &authenticator.Response{User: &user.DefaultInfo{Name: "some-delegated-user"}}
+
+	type setup struct {
+		clock       *clocktesting.FakeClock
+		config      *ServiceAccountTokenConfig
+		auth        authenticator.Request
+		reviewCount func() int
+		reviewErr   *error
+	}
+
+	newSetup := func(t *testing.T) *setup {
+		s := &setup{
+			clock:     clocktesting.NewFakeClock(now),
+			config:    NewServiceAccountTokenConfig(),
+			reviewErr: new(error),
+		}
+
+		client := kubefake.NewSimpleClientset()
+		var count int
+		client.PrependReactor("create", "tokenreviews", func(action coretesting.Action) (bool, runtime.Object, error) {
+			count++
+			if *s.reviewErr != nil {
+				return true, nil, *s.reviewErr
+			}
+			review := action.(coretesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+			require.Empty(t, review.Spec.Audiences)
+			if review.Spec.Token == invalidToken {
+				return true, &authenticationv1.TokenReview{Status: authenticationv1.TokenReviewStatus{Error: "invalid token"}}, nil
+			}
+			return true, &authenticationv1.TokenReview{Status: authenticationv1.TokenReviewStatus{Authenticated: true, User: saUser}}, nil
+		})
+		s.reviewCount = func() int { return count }
+
+		delegate := authenticator.RequestFunc(func(_ *http.Request) (*authenticator.Response, bool, error) {
+			return delegateResp, true, nil
+		})
+		s.auth = newServiceAccountTokenAuthenticator(s.config, client.AuthenticationV1().TokenReviews(), s.clock).wrap(delegate)
+		return s
+	}
+
+	requestWithToken := func(token string) *http.Request {
+		r := (&http.Request{Header: http.Header{}}).WithContext(context.Background())
+		if len(token) != 0 {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return r
+	}
+
+	t.Run("non service account credentials are delegated", func(t *testing.T) {
+		t.Parallel()
+		s := newSetup(t)
+
+		for _, r := range []*http.Request{
+			requestWithToken(""),
+			requestWithToken(otherToken),
+			func() *http.Request {
+				// client certificates take precedence over bearer tokens
+				r := requestWithToken(boundToken)
+				r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}
+				return r
+			}(),
+		} {
+			resp, ok, err := s.auth.AuthenticateRequest(r)
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, delegateResp, resp)
+		}
+		require.Equal(t, 0, s.reviewCount())
+	})
+
+	t.Run("valid tokens are reviewed and cached", func(t *testing.T) {
+		t.Parallel()
+		s := newSetup(t)
+
+		for i := 0; i < 3; i++ {
+			resp, ok, err := s.auth.AuthenticateRequest(requestWithToken(boundToken))
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, wantResp, resp)
+		}
+		require.Equal(t, 1, s.reviewCount())
+
+		resp, ok, err := s.auth.AuthenticateRequest(requestWithToken(legacyToken))
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, wantResp, resp)
+		require.Equal(t, 2, s.reviewCount())
+
+		s.clock.Step(10 * time.Second)
+		_, ok, err = s.auth.AuthenticateRequest(requestWithToken(boundToken))
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, 3, s.reviewCount())
+	})
+
+	t.Run("the cache TTL is configurable", func(t *testing.T) {
+		t.Parallel()
+		s := newSetup(t)
+		s.config.SetConfig(&v1alpha1.ImpersonationProxyServiceAccountTokensSpec{CacheTTLSeconds: 60})
+
+		_, ok, err := s.auth.AuthenticateRequest(requestWithToken(boundToken))
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		s.clock.Step(59 * time.Second)
+		_, ok, err = s.auth.AuthenticateRequest(requestWithToken(boundToken))
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, 1, s.reviewCount())
+
+		s.clock.Step(time.Second)
+		_, ok, err = s.auth.AuthenticateRequest(requestWithToken(boundToken))
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, 2, s.reviewCount())
+	})
+
+	t.Run("cached results do not outlive the token", func(t *testing.T) {
+		t.Parallel()
+		s := newSetup(t)
+
+		_, ok, err := s.auth.AuthenticateRequest(requestWithToken(shortLivedBoundToken))
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		s.clock.Step(5 * time.Second)
+		_, _, _ = s.auth.AuthenticateRequest(requestWithToken(shortLivedBoundToken))
+		require.Equal(t, 2, s.reviewCount())
+	})
+
+	t.Run("invalid tokens are rejected and cached", func(t *testing.T) {
+		t.Parallel()
+		s := newSetup(t)
+
+		for i := 0; i < 2; i++ {
+			resp, ok, err := s.auth.AuthenticateRequest(requestWithToken(invalidToken))
+			require.EqualError(t, err, "service account token failed to authenticate")
+			require.False(t, ok)
+			require.Nil(t, resp)
+		}
+		require.Equal(t, 1, s.reviewCount())
+	})
+
+	t.Run("token review errors are not cached", func(t *testing.T) {
+		t.Parallel()
+		s := newSetup(t)
+		*s.reviewErr = errors.New("some api error")
+
+		for i := 0; i < 2; i++ {
+			resp, ok, err := s.auth.AuthenticateRequest(requestWithToken(boundToken))
+			require.EqualError(t, err, "some api error")
+			require.False(t, ok)
+			require.Nil(t, resp)
+		}
+		require.Equal(t, 2, s.reviewCount())
+	})
+
+	t.Run("bound only mode rejects legacy tokens", func(t *testing.T) {
+		t.Parallel()
+		s := newSetup(t)
+		s.config.SetConfig(&v1alpha1.ImpersonationProxyServiceAccountTokensSpec{Mode: v1alpha1.ImpersonationProxyServiceAccountTokensModeBoundOnly})
+
+		resp, ok, err := s.auth.AuthenticateRequest(requestWithToken(legacyToken))
+		require.EqualError(t, err, "legacy service account tokens are disabled")
+		require.False(t, ok)
+		require.Nil(t, resp)
+
+		resp, ok, err = s.auth.AuthenticateRequest(requestWithToken(boundToken))
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, wantResp, resp)
+		require.Equal(t, 1, s.reviewCount())
+	})
+
+	t.Run("disabled mode rejects all service account tokens", func(t *testing.T) {
+		t.Parallel()
+		s := newSetup(t)
+
+		// populate the cache to make sure that it is not consulted
+		_, ok, err := s.auth.AuthenticateRequest(requestWithToken(boundToken))
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		s.config.SetConfig(&v1alpha1.ImpersonationProxyServiceAccountTokensSpec{Mode: v1alpha1.ImpersonationProxyServiceAccountTokensModeDisabled})
+
+		for _, token := range []string{boundToken, legacyToken} {
+			resp, ok, err := s.auth.AuthenticateRequest(requestWithToken(token))
+			require.EqualError(t, err, "service account tokens are disabled")
+			require.False(t, ok)
+			require.Nil(t, resp)
+		}
+
+		// other credentials are unaffected
+		resp, ok, err := s.auth.AuthenticateRequest(requestWithToken(otherToken))
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, delegateResp, resp)
+		require.Equal(t, 1, s.reviewCount())
+	})
+}
+
+func boundServiceAccountTokenClaims(expiry time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss": "https://kubernetes.default.svc.cluster.local",
+		"sub": "system:serviceaccount:some-namespace:some-service-account",
+		"aud": []string{"https://kubernetes.default.svc.cluster.local"},
+		"exp": expiry.Unix(),
+		"kubernetes.io": map[string]interface{}{
+			"namespace":      "some-namespace",
+			"serviceaccount": map[string]string{"name": "some-service-account", "uid": "some-uid"},
+		},
+	}
+}
+
+func legacyServiceAccountTokenClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":                                    "kubernetes/serviceaccount",
+		"sub":                                    "system:serviceaccount:some-namespace:some-service-account",
+		"kubernetes.io/serviceaccount/namespace": "some-namespace",
+		"kubernetes.io/serviceaccount/secret.name":          "some-secret",
+		"kubernetes.io/serviceaccount/service-account.name": "some-service-account",
+	}
+}
+
+func newServiceAccountToken(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sig, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	require.NoError(t, err)
+
+	token, err := jwt.Signed(sig).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+
+	return token
+}
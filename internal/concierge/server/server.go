@@ -21,8 +21,10 @@ import (
 	"k8s.io/client-go/pkg/version"
 	"k8s.io/client-go/rest"
 
+	"go.pinniped.dev/internal/certauthority/csrcertauthority"
 	"go.pinniped.dev/internal/certauthority/dynamiccertauthority"
 	"go.pinniped.dev/internal/concierge/apiserver"
+	"go.pinniped.dev/internal/concierge/frontendpolicy"
 	conciergescheme "go.pinniped.dev/internal/concierge/scheme"
 	"go.pinniped.dev/internal/config/concierge"
 	"go.pinniped.dev/internal/controller/authenticator/authncache"
@@ -114,6 +116,9 @@ func (a *App) runServer(ctx context.Context) error {
 	// Initialize the cache of active authenticators.
 	authenticators := authncache.New()
 
+	// Initialize the policy of which authenticators may be used through each frontend.
+	frontendPolicy := frontendpolicy.New()
+
 	// This cert provider will provide certs to the API server and will
 	// be mutated by a controller to keep the certs up to date with what
 	// is stored in a k8s Secret. Therefore it also effectively acting as
@@ -133,6 +138,17 @@ func (a *App) runServer(ctx context.Context) error {
 	// injected suffix).
 	scheme, loginGV, identityGV := conciergescheme.New(*cfg.APIGroupSuffix)
 
+	// This issues client certs using the configured signer of the Kubernetes CertificateSigningRequest API, if any.
+	var csrSigner *csrcertauthority.CA
+	if signerName := cfg.KubeCertAgentConfig.CSRSignerName; signerName != nil && *signerName != "" {
+		// This client is not subject to leader election because every pod needs to be able to issue certs.
+		client, err := kubeclient.New()
+		if err != nil {
+			return fmt.Errorf("could not create client for the CSR signer: %w", err)
+		}
+		csrSigner = csrcertauthority.New(client.Kubernetes, *signerName)
+	}
+
 	// Prepare to start the controllers, but defer actually starting them until the
 	// post start hook of the aggregated API server.
 	buildControllers, err := controllermanager.PrepareControllers(
@@ -149,8 +165,11 @@ func (a *App) runServer(ctx context.Context) error {
 			ServingCertDuration:              time.Duration(*cfg.APIConfig.ServingCertificateConfig.DurationSeconds) * time.Second,
 			ServingCertRenewBefore:           time.Duration(*cfg.APIConfig.ServingCertificateConfig.RenewBeforeSeconds) * time.Second,
 			AuthenticatorCache:               authenticators,
+			FrontendPolicy:                   frontendPolicy,
+			CSRSigner:                        csrSigner,
 			// This port should be safe to cast because the config reader already validated it.
 			ImpersonationProxyServerPort: int(*cfg.ImpersonationProxyServerPort),
+			ImpersonationProxyAudit:      &cfg.ImpersonationProxyAudit,
 		},
 	)
 	if err != nil {
@@ -158,15 +177,19 @@ func (a *App) runServer(ctx context.Context) error {
 	}
 
 	certIssuer := issuer.ClientCertIssuers{
-		dynamiccertauthority.New(dynamicSigningCertProvider),            // attempt to use the real Kube CA if possible
-		dynamiccertauthority.New(impersonationProxySigningCertProvider), // fallback to our internal CA if we need to
+		dynamiccertauthority.New(dynamicSigningCertProvider), // attempt to use the real Kube CA if possible
+	}
+	if csrSigner != nil {
+		certIssuer = append(certIssuer, csrSigner) // next try the configured CSR signer, unless it is currently failing
 	}
+	certIssuer = append(certIssuer, dynamiccertauthority.New(impersonationProxySigningCertProvider)) // fallback to our internal CA if we need to
 
 	// Get the aggregated API server config.
 	aggregatedAPIServerConfig, err := getAggregatedAPIServerConfig(
 		dynamicServingCertProvider,
 		authenticators,
 		certIssuer,
+		frontendPolicy,
 		buildControllers,
 		*cfg.APIGroupSuffix,
 		*cfg.AggregatedAPIServerPort,
@@ -193,6 +216,7 @@ func getAggregatedAPIServerConfig(
 	dynamicCertProvider dynamiccert.Private,
 	authenticator credentialrequest.TokenCredentialRequestAuthenticator,
 	issuer issuer.ClientCertIssuer,
+	frontendPolicy *frontendpolicy.Policy,
 	buildControllers controllerinit.RunnerBuilder,
 	apiGroupSuffix string,
 	aggregatedAPIServerPort int64,
@@ -240,6 +264,7 @@ func getAggregatedAPIServerConfig(
 		ExtraConfig: apiserver.ExtraConfig{
 			Authenticator:                 authenticator,
 			Issuer:                        issuer,
+			FrontendPolicy:                frontendPolicy,
 			BuildControllersPostStartHook: buildControllers,
 			Scheme:                        scheme,
 			NegotiatedSerializer:          codecs,
@@ -1,4 +1,4 @@
-// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2022 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package concierge contains functionality to load/store Config's from/to
@@ -79,6 +79,10 @@ func FromPath(ctx context.Context, path string) (*Config, error) {
 		return nil, fmt.Errorf("validate names: %w", err)
 	}
 
+	if err := validateImpersonationProxyAudit(&config.ImpersonationProxyAudit); err != nil {
+		return nil, fmt.Errorf("validate impersonationProxyAudit: %w", err)
+	}
+
 	plog.MaybeSetDeprecatedLogLevel(config.LogLevel, &config.Log)
 	if err := plog.ValidateAndSetLogLevelAndFormatGlobally(ctx, config.Log); err != nil {
 		return nil, fmt.Errorf("validate log level: %w", err)
@@ -190,3 +194,17 @@ func validateServerPort(port *int64) error {
 	}
 	return nil
 }
+
+func validateImpersonationProxyAudit(audit *ImpersonationProxyAuditSpec) error {
+	hasBackend := audit.LogPath != "" || audit.WebhookConfigFile != ""
+
+	if audit.PolicyFile == "" && hasBackend {
+		return constable.Error("policyFile must be set when logPath or webhookConfigFile is set")
+	}
+
+	if audit.PolicyFile != "" && !hasBackend {
+		return constable.Error("logPath or webhookConfigFile must be set when policyFile is set")
+	}
+
+	return nil
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2022 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package concierge
@@ -56,6 +56,11 @@ func TestFromPath(t *testing.T) {
 				  namePrefix: kube-cert-agent-name-prefix-
 				  image: kube-cert-agent-image
 				  imagePullSecrets: [kube-cert-agent-image-pull-secret]
+				  csrSignerName: kubernetes.io/kube-apiserver-client
+				impersonationProxyAudit:
+				  policyFile: /some/audit/policy.yaml
+				  logPath: "-"
+				  webhookConfigFile: /some/audit/webhook.kubeconfig
 				logLevel: debug
 			`),
 			wantConfig: &Config{
@@ -90,6 +95,12 @@ func TestFromPath(t *testing.T) {
 					NamePrefix:       pointer.StringPtr("kube-cert-agent-name-prefix-"),
 					Image:            pointer.StringPtr("kube-cert-agent-image"),
 					ImagePullSecrets: []string{"kube-cert-agent-image-pull-secret"},
+					CSRSignerName:    pointer.StringPtr("kubernetes.io/kube-apiserver-client"),
+				},
+				ImpersonationProxyAudit: ImpersonationProxyAuditSpec{
+					PolicyFile:        "/some/audit/policy.yaml",
+					LogPath:           "-",
+					WebhookConfigFile: "/some/audit/webhook.kubeconfig",
 				},
 				LogLevel: func(level plog.LogLevel) *plog.LogLevel { return &level }(plog.LevelDebug),
 				Log: plog.LogSpec{
@@ -539,6 +550,44 @@ func TestFromPath(t *testing.T) {
 			`),
 			wantError: "validate impersonationProxyServerPort: must be within range 1024 to 65535",
 		},
+		{
+			name: "ImpersonationProxyAudit policy without backend",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				  impersonationLoadBalancerService: impersonationLoadBalancerService-value
+				  impersonationClusterIPService: impersonationClusterIPService-value
+				  impersonationTLSCertificateSecret: impersonationTLSCertificateSecret-value
+				  impersonationCACertificateSecret: impersonationCACertificateSecret-value
+				  impersonationSignerSecret: impersonationSignerSecret-value
+				  agentServiceAccount: agentServiceAccount-value
+				impersonationProxyAudit:
+				  policyFile: /some/audit/policy.yaml
+			`),
+			wantError: "validate impersonationProxyAudit: logPath or webhookConfigFile must be set when policyFile is set",
+		},
+		{
+			name: "ImpersonationProxyAudit backend without policy",
+			yaml: here.Doc(`
+				---
+				names:
+				  servingCertificateSecret: pinniped-concierge-api-tls-serving-certificate
+				  credentialIssuer: pinniped-config
+				  apiService: pinniped-api
+				  impersonationLoadBalancerService: impersonationLoadBalancerService-value
+				  impersonationClusterIPService: impersonationClusterIPService-value
+				  impersonationTLSCertificateSecret: impersonationTLSCertificateSecret-value
+				  impersonationCACertificateSecret: impersonationCACertificateSecret-value
+				  impersonationSignerSecret: impersonationSignerSecret-value
+				  agentServiceAccount: agentServiceAccount-value
+				impersonationProxyAudit:
+				  logPath: /some/audit/log
+			`),
+			wantError: "validate impersonationProxyAudit: policyFile must be set when logPath or webhookConfigFile is set",
+		},
 		{
 			name: "ZeroRenewBefore",
 			yaml: here.Doc(`
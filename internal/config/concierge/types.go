@@ -15,6 +15,8 @@ type Config struct {
 	NamesConfig                  NamesConfigSpec   `json:"names"`
 	KubeCertAgentConfig          KubeCertAgentSpec `json:"kubeCertAgent"`
 	Labels                       map[string]string `json:"labels"`
+
+	ImpersonationProxyAudit ImpersonationProxyAuditSpec `json:"impersonationProxyAudit"`
 	// Deprecated: use log.level instead
 	LogLevel *plog.LogLevel `json:"logLevel"`
 	Log      plog.LogSpec   `json:"log"`
@@ -79,4 +81,27 @@ type KubeCertAgentSpec struct {
 	// ImagePullSecrets is a list of names of Kubernetes Secret objects that will be used as
 	// ImagePullSecrets on the kube-cert-agent pods.
 	ImagePullSecrets []string
+
+	// CSRSignerName is the signerName of a Kubernetes certificates.k8s.io/v1 CertificateSigningRequest signer,
+	// e.g. "kubernetes.io/kube-apiserver-client" or a cert-manager issuer. When it is set, the Concierge will
+	// request and approve client certificates from this signer whenever it cannot use the cluster's signing key.
+	// The signer must issue certificates which are trusted by the Kubernetes API server.
+	CSRSignerName *string `json:"csrSignerName,omitempty"`
+}
+
+// ImpersonationProxyAuditSpec configures the audit events which are recorded for requests which are
+// served by the impersonation proxy. These events use the same format as the Kubernetes API server's
+// audit events, and they contain the identity that was authenticated by the impersonation proxy.
+type ImpersonationProxyAuditSpec struct {
+	// PolicyFile is the path to a file containing an audit.k8s.io Policy, which decides the level at which
+	// each request is audited. When this is not set, no audit events are recorded.
+	PolicyFile string `json:"policyFile,omitempty"`
+
+	// LogPath is the path to a file to which audit events will be written, one JSON object per line.
+	// A value of "-" writes the events to standard out.
+	LogPath string `json:"logPath,omitempty"`
+
+	// WebhookConfigFile is the path to a kubeconfig formatted file which describes a webhook to which
+	// audit events will be sent in batches.
+	WebhookConfigFile string `json:"webhookConfigFile,omitempty"`
 }
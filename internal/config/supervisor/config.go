@@ -71,6 +71,9 @@ func FromPath(ctx context.Context, path string) (*Config, error) {
 	maybeSetEndpointDefault(&config.Endpoints.HTTP, Endpoint{
 		Network: NetworkDisabled,
 	})
+	maybeSetEndpointDefault(&config.Endpoints.Metrics, Endpoint{
+		Network: NetworkDisabled,
+	})
 
 	if err := validateEndpoint(*config.Endpoints.HTTPS); err != nil {
 		return nil, fmt.Errorf("validate https endpoint: %w", err)
@@ -81,6 +84,9 @@ func FromPath(ctx context.Context, path string) (*Config, error) {
 	if err := validateAdditionalHTTPEndpointRequirements(*config.Endpoints.HTTP, config.AllowExternalHTTP); err != nil {
 		return nil, fmt.Errorf("validate http endpoint: %w", err)
 	}
+	if err := validateEndpoint(*config.Endpoints.Metrics); err != nil {
+		return nil, fmt.Errorf("validate metrics endpoint: %w", err)
+	}
 	if err := validateAtLeastOneEnabledEndpoint(*config.Endpoints.HTTPS, *config.Endpoints.HTTP); err != nil {
 		return nil, fmt.Errorf("validate endpoints: %w", err)
 	}
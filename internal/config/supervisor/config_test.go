@@ -41,6 +41,9 @@ func TestFromPath(t *testing.T) {
 				  http:
 				    network: tcp
 					address: 127.0.0.1:1234
+				  metrics:
+				    network: tcp
+				    address: :9090
 				insecureAcceptExternalUnencryptedHttpRequests: false
 				logLevel: trace
 			`),
@@ -62,6 +65,10 @@ func TestFromPath(t *testing.T) {
 						Network: "tcp",
 						Address: "127.0.0.1:1234",
 					},
+					Metrics: &Endpoint{
+						Network: "tcp",
+						Address: ":9090",
+					},
 				},
 				AllowExternalHTTP: false,
 				LogLevel:          func(level plog.LogLevel) *plog.LogLevel { return &level }(plog.LevelTrace),
@@ -110,6 +117,9 @@ func TestFromPath(t *testing.T) {
 						Network: "tcp",
 						Address: "127.0.0.1:1234",
 					},
+					Metrics: &Endpoint{
+						Network: "disabled",
+					},
 				},
 				AllowExternalHTTP: false,
 				Log: plog.LogSpec{
@@ -159,6 +169,9 @@ func TestFromPath(t *testing.T) {
 						Network: "tcp",
 						Address: "127.0.0.1:1234",
 					},
+					Metrics: &Endpoint{
+						Network: "disabled",
+					},
 				},
 				AllowExternalHTTP: false,
 				LogLevel:          func(level plog.LogLevel) *plog.LogLevel { return &level }(plog.LevelTrace),
@@ -201,6 +214,9 @@ func TestFromPath(t *testing.T) {
 					HTTP: &Endpoint{
 						Network: "disabled",
 					},
+					Metrics: &Endpoint{
+						Network: "disabled",
+					},
 				},
 				AllowExternalHTTP: false,
 			},
@@ -247,6 +263,35 @@ func TestFromPath(t *testing.T) {
 			`),
 			wantError: `validate http endpoint: unknown network "bar"`,
 		},
+		{
+			name: "invalid metrics endpoint",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  metrics:
+				    network: baz
+			`),
+			wantError: `validate metrics endpoint: unknown network "baz"`,
+		},
+		{
+			name: "only the metrics endpoint enabled",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  https:
+				    network: disabled
+				  http:
+				    network: disabled
+				  metrics:
+				    network: tcp
+				    address: :9090
+			`),
+			wantError: "validate endpoints: all endpoints are disabled",
+		},
 		{
 			name: "http endpoint uses tcp but binds to more than only loopback interfaces with insecureAcceptExternalUnencryptedHttpRequests missing",
 			yaml: here.Doc(`
@@ -331,6 +376,9 @@ func TestFromPath(t *testing.T) {
 						Network: "tcp",
 						Address: ":1234",
 					},
+					Metrics: &Endpoint{
+						Network: "disabled",
+					},
 				},
 				AllowExternalHTTP: true,
 			},
@@ -362,6 +410,9 @@ func TestFromPath(t *testing.T) {
 						Network: "tcp",
 						Address: ":1234",
 					},
+					Metrics: &Endpoint{
+						Network: "disabled",
+					},
 				},
 				AllowExternalHTTP: true,
 			},
@@ -27,8 +27,9 @@ type NamesConfigSpec struct {
 }
 
 type Endpoints struct {
-	HTTPS *Endpoint `json:"https,omitempty"`
-	HTTP  *Endpoint `json:"http,omitempty"`
+	HTTPS   *Endpoint `json:"https,omitempty"`
+	HTTP    *Endpoint `json:"http,omitempty"`
+	Metrics *Endpoint `json:"metrics,omitempty"`
 }
 
 type Endpoint struct {
@@ -15,8 +15,10 @@ import (
 	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
 	"github.com/go-logr/logr"
 	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/apiserver/plugin/pkg/authenticator/token/oidc"
 	"k8s.io/klog/v2"
 
@@ -34,6 +36,15 @@ import (
 const (
 	defaultUsernameClaim = "username"
 	defaultGroupsClaim   = "groups"
+
+	// actorClaim is the RFC 8693 "act" claim, which the Supervisor's token exchange adds to tokens which were
+	// requested by another party on behalf of the user.
+	actorClaim = "act"
+
+	// actorSubjectExtraKey and actorUsernameExtraKey are the keys of the user.Info extra values which name the
+	// party who is acting on behalf of the user, when the token contains an "act" claim.
+	actorSubjectExtraKey  = "authentication.concierge.pinniped.dev/actor-subject"
+	actorUsernameExtraKey = "authentication.concierge.pinniped.dev/actor-username"
 )
 
 // defaultSupportedSigningAlgos returns the default signing algos that this JWTAuthenticator
@@ -60,6 +71,53 @@ type jwtAuthenticator struct {
 	spec *auth1alpha1.JWTAuthenticatorSpec
 }
 
+// AuthenticateToken authenticates the token, and then adds the actor from the token's "act" claim, if any,
+// to the extra values of the user.
+func (a *jwtAuthenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	rsp, authenticated, err := a.tokenAuthenticatorCloser.AuthenticateToken(ctx, token)
+	if err != nil || !authenticated || rsp == nil || rsp.User == nil {
+		return rsp, authenticated, err
+	}
+
+	// The token was already verified above, so it is safe to read its claims without verifying it again.
+	parsedToken, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not parse token: %w", err)
+	}
+	var claims struct {
+		Actor *struct {
+			Subject  string `json:"sub"`
+			Username string `json:"username"`
+		} `json:"act"`
+	}
+	if err := parsedToken.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, false, fmt.Errorf("could not read %q claim: %w", actorClaim, err)
+	}
+	if claims.Actor == nil {
+		return rsp, authenticated, nil
+	}
+
+	extra := map[string][]string{}
+	for k, v := range rsp.User.GetExtra() {
+		extra[k] = v
+	}
+	if claims.Actor.Subject != "" {
+		extra[actorSubjectExtraKey] = []string{claims.Actor.Subject}
+	}
+	if claims.Actor.Username != "" {
+		extra[actorUsernameExtraKey] = []string{claims.Actor.Username}
+	}
+	return &authenticator.Response{
+		Audiences: rsp.Audiences,
+		User: &user.DefaultInfo{
+			Name:   rsp.User.GetName(),
+			UID:    rsp.User.GetUID(),
+			Groups: rsp.User.GetGroups(),
+			Extra:  extra,
+		},
+	}, authenticated, nil
+}
+
 // New instantiates a new controllerlib.Controller which will populate the provided authncache.Cache.
 func New(
 	cache *authncache.Cache,
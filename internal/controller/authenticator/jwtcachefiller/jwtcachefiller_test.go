@@ -478,6 +478,7 @@ func TestController(t *testing.T) {
 						test.distributedGroupsClaimURL,
 						tt.wantUsernameClaim,
 						username,
+						test.extraClaims,
 					)
 
 					// Loop for a while here to allow the underlying OIDC authenticator to initialize itself asynchronously.
@@ -530,6 +531,7 @@ func testTableForAuthenticateTokenTests(
 	wantAuthenticated         bool
 	wantErrorRegexp           string
 	distributedGroupsClaimURL string
+	extraClaims               map[string]interface{}
 } {
 	tests := []struct {
 		name                      string
@@ -539,6 +541,7 @@ func testTableForAuthenticateTokenTests(
 		wantAuthenticated         bool
 		wantErrorRegexp           string
 		distributedGroupsClaimURL string
+		extraClaims               map[string]interface{}
 	}{
 		{
 			name: "good token without groups and with EC signature",
@@ -616,6 +619,48 @@ func testTableForAuthenticateTokenTests(
 			},
 			wantAuthenticated: true,
 		},
+		{
+			name: "good token with actor claim",
+			jwtClaims: func(_ *jwt.Claims, groups *interface{}, username *string) {
+				*groups = []string{group0, group1}
+			},
+			extraClaims: map[string]interface{}{
+				"act": map[string]interface{}{"sub": "some-actor-subject", "username": "some-actor-username"},
+			},
+			wantResponse: &authenticator.Response{
+				User: &user.DefaultInfo{
+					Name:   goodUsername,
+					Groups: []string{group0, group1},
+					Extra: map[string][]string{
+						"authentication.concierge.pinniped.dev/actor-subject":  {"some-actor-subject"},
+						"authentication.concierge.pinniped.dev/actor-username": {"some-actor-username"},
+					},
+				},
+			},
+			wantAuthenticated: true,
+		},
+		{
+			name: "good token with actor claim which has only a subject",
+			extraClaims: map[string]interface{}{
+				"act": map[string]interface{}{"sub": "some-actor-subject"},
+			},
+			wantResponse: &authenticator.Response{
+				User: &user.DefaultInfo{
+					Name: goodUsername,
+					Extra: map[string][]string{
+						"authentication.concierge.pinniped.dev/actor-subject": {"some-actor-subject"},
+					},
+				},
+			},
+			wantAuthenticated: true,
+		},
+		{
+			name: "bad token with actor claim which is not an object",
+			extraClaims: map[string]interface{}{
+				"act": "some-actor",
+			},
+			wantErrorRegexp: `could not read "act" claim: .*`,
+		},
 		{
 			name: "good token with nbf unset",
 			jwtClaims: func(claims *jwt.Claims, _ *interface{}, username *string) {
@@ -736,6 +781,7 @@ func createJWT(
 	distributedGroupsClaimURL string,
 	usernameClaim string,
 	usernameValue string,
+	extraClaims map[string]interface{},
 ) string {
 	t.Helper()
 
@@ -756,6 +802,9 @@ func createJWT(
 	if usernameValue != "" {
 		builder = builder.Claims(map[string]interface{}{usernameClaim: usernameValue})
 	}
+	if extraClaims != nil {
+		builder = builder.Claims(extraClaims)
+	}
 	jwt, err := builder.CompactSerialize()
 	require.NoError(t, err)
 
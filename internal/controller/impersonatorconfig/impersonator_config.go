@@ -84,6 +84,8 @@ type impersonatorConfigController struct {
 	serverStopCh                      chan struct{}
 	errorCh                           chan error
 	tlsServingCertDynamicCertProvider dynamiccert.Private
+	requestLimiter                    *impersonator.RequestLimiter
+	serviceAccountTokenConfig         *impersonator.ServiceAccountTokenConfig
 	infoLog                           logr.Logger
 	debugLog                          logr.Logger
 }
@@ -133,6 +135,8 @@ func NewImpersonatorConfigController(
 				impersonationSigningCertProvider:  impersonationSigningCertProvider,
 				impersonatorFunc:                  impersonatorFunc,
 				tlsServingCertDynamicCertProvider: dynamiccert.NewServingCert("impersonation-proxy-serving-cert"),
+				requestLimiter:                    impersonator.NewRequestLimiter(clock),
+				serviceAccountTokenConfig:         impersonator.NewServiceAccountTokenConfig(),
 				infoLog:                           log.V(plog.KlogLevelInfo),
 				debugLog:                          log.V(plog.KlogLevelDebug),
 			},
@@ -250,6 +254,10 @@ func (c *impersonatorConfigController) doSync(syncCtx controllerlib.Context, cre
 		c.debugLog.Info("queried for control plane nodes", "foundControlPlaneNodes", hasControlPlaneNodes)
 	}
 
+	// These settings can be changed while the impersonator is running, so always update them before starting it.
+	c.requestLimiter.SetLimits(impersonationSpec.Limits)
+	c.serviceAccountTokenConfig.SetConfig(impersonationSpec.ServiceAccountTokens)
+
 	if c.shouldHaveImpersonator(impersonationSpec) {
 		if err = c.ensureImpersonatorIsStarted(syncCtx); err != nil {
 			return nil, err
@@ -409,6 +417,8 @@ func (c *impersonatorConfigController) ensureImpersonatorIsStarted(syncCtx contr
 		c.impersonationProxyPort,
 		c.tlsServingCertDynamicCertProvider,
 		c.impersonationSigningCertProvider,
+		c.requestLimiter,
+		c.serviceAccountTokenConfig,
 	)
 	if err != nil {
 		return err
@@ -1102,5 +1112,41 @@ func validateCredentialIssuerSpec(spec *v1alpha1.ImpersonationProxySpec) error {
 		}
 	}
 
+	// If specified, validate the service account token settings (this is normally already done via CRD validation).
+	if spec.ServiceAccountTokens != nil {
+		switch spec.ServiceAccountTokens.Mode {
+		case "":
+		case v1alpha1.ImpersonationProxyServiceAccountTokensModeEnabled:
+		case v1alpha1.ImpersonationProxyServiceAccountTokensModeBoundOnly:
+		case v1alpha1.ImpersonationProxyServiceAccountTokensModeDisabled:
+		default:
+			return fmt.Errorf("invalid serviceAccountTokens.mode %q (expected enabled, boundOnly, or disabled)", spec.ServiceAccountTokens.Mode)
+		}
+		// Zero means that the field was not specified, so the default is used.
+		if ttl := spec.ServiceAccountTokens.CacheTTLSeconds; ttl < 0 || ttl > 3600 {
+			return fmt.Errorf("invalid serviceAccountTokens.cacheTTLSeconds %d (expected between 1 and 3600, or 0 for the default)", ttl)
+		}
+	}
+
+	// Validate that the limits are not negative (this is normally already done via CRD validation).
+	if spec.Limits != nil {
+		if err := validateRequestLimits("global", spec.Limits.Global); err != nil {
+			return err
+		}
+		if err := validateRequestLimits("perUser", spec.Limits.PerUser); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateRequestLimits(name string, limits *v1alpha1.ImpersonationProxyRequestLimits) error {
+	if limits == nil {
+		return nil
+	}
+	if limits.MaxRequestsInFlight < 0 || limits.QPS < 0 || limits.Burst < 0 {
+		return fmt.Errorf("invalid limits.%s: values must not be negative", name)
+	}
 	return nil
 }
@@ -39,6 +39,7 @@ import (
 	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
 	pinnipedinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
 	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/concierge/impersonator"
 	"go.pinniped.dev/internal/controller/apicerts"
 	"go.pinniped.dev/internal/controllerlib"
 	"go.pinniped.dev/internal/dynamiccert"
@@ -295,11 +296,15 @@ func TestImpersonatorConfigControllerSync(t *testing.T) {
 			port int,
 			dynamicCertProvider dynamiccert.Private,
 			impersonationProxySignerCAProvider dynamiccert.Public,
+			requestLimiter *impersonator.RequestLimiter,
+			serviceAccountTokenConfig *impersonator.ServiceAccountTokenConfig,
 		) (func(stopCh <-chan struct{}) error, error) {
 			impersonatorFuncWasCalled++
 			r.Equal(8444, port)
 			r.NotNil(dynamicCertProvider)
 			r.NotNil(impersonationProxySignerCAProvider)
+			r.NotNil(requestLimiter)
+			r.NotNil(serviceAccountTokenConfig)
 
 			if impersonatorFuncError != nil {
 				return nil, impersonatorFuncError
@@ -3255,6 +3260,83 @@ func TestImpersonatorConfigControllerSync(t *testing.T) {
 			})
 		})
 
+		when("the CredentialIssuer has negative limits", func() {
+			it.Before(func() {
+				addCredentialIssuerToTrackers(v1alpha1.CredentialIssuer{
+					ObjectMeta: metav1.ObjectMeta{Name: credentialIssuerResourceName},
+					Spec: v1alpha1.CredentialIssuerSpec{
+						ImpersonationProxy: &v1alpha1.ImpersonationProxySpec{
+							Mode: v1alpha1.ImpersonationProxyModeEnabled,
+							Limits: &v1alpha1.ImpersonationProxyLimitsSpec{
+								Global:  &v1alpha1.ImpersonationProxyRequestLimits{QPS: 10},
+								PerUser: &v1alpha1.ImpersonationProxyRequestLimits{MaxRequestsInFlight: -1},
+							},
+						},
+					},
+				}, pinnipedInformerClient, pinnipedAPIClient)
+			})
+
+			it("returns an error", func() {
+				startInformersAndController()
+				errString := `could not load CredentialIssuer spec.impersonationProxy: invalid limits.perUser: values must not be negative`
+				r.EqualError(runControllerSync(), errString)
+				requireCredentialIssuer(newErrorStrategy(errString))
+				requireSigningCertProviderIsEmpty()
+				requireTLSServerWasNeverStarted()
+			})
+		})
+
+		when("the CredentialIssuer has an invalid service account tokens mode", func() {
+			it.Before(func() {
+				addCredentialIssuerToTrackers(v1alpha1.CredentialIssuer{
+					ObjectMeta: metav1.ObjectMeta{Name: credentialIssuerResourceName},
+					Spec: v1alpha1.CredentialIssuerSpec{
+						ImpersonationProxy: &v1alpha1.ImpersonationProxySpec{
+							Mode: v1alpha1.ImpersonationProxyModeEnabled,
+							ServiceAccountTokens: &v1alpha1.ImpersonationProxyServiceAccountTokensSpec{
+								Mode: "not-valid",
+							},
+						},
+					},
+				}, pinnipedInformerClient, pinnipedAPIClient)
+			})
+
+			it("returns an error", func() {
+				startInformersAndController()
+				errString := `could not load CredentialIssuer spec.impersonationProxy: invalid serviceAccountTokens.mode "not-valid" (expected enabled, boundOnly, or disabled)`
+				r.EqualError(runControllerSync(), errString)
+				requireCredentialIssuer(newErrorStrategy(errString))
+				requireSigningCertProviderIsEmpty()
+				requireTLSServerWasNeverStarted()
+			})
+		})
+
+		when("the CredentialIssuer has an invalid service account tokens cache TTL", func() {
+			it.Before(func() {
+				addCredentialIssuerToTrackers(v1alpha1.CredentialIssuer{
+					ObjectMeta: metav1.ObjectMeta{Name: credentialIssuerResourceName},
+					Spec: v1alpha1.CredentialIssuerSpec{
+						ImpersonationProxy: &v1alpha1.ImpersonationProxySpec{
+							Mode: v1alpha1.ImpersonationProxyModeEnabled,
+							ServiceAccountTokens: &v1alpha1.ImpersonationProxyServiceAccountTokensSpec{
+								Mode:            v1alpha1.ImpersonationProxyServiceAccountTokensModeBoundOnly,
+								CacheTTLSeconds: 3601,
+							},
+						},
+					},
+				}, pinnipedInformerClient, pinnipedAPIClient)
+			})
+
+			it("returns an error", func() {
+				startInformersAndController()
+				errString := `could not load CredentialIssuer spec.impersonationProxy: invalid serviceAccountTokens.cacheTTLSeconds 3601 (expected between 1 and 3600, or 0 for the default)`
+				r.EqualError(runControllerSync(), errString)
+				requireCredentialIssuer(newErrorStrategy(errString))
+				requireSigningCertProviderIsEmpty()
+				requireTLSServerWasNeverStarted()
+			})
+		})
+
 		when("the CredentialIssuer has invalid ExternalEndpoint", func() {
 			it.Before(func() {
 				addCredentialIssuerToTrackers(v1alpha1.CredentialIssuer{
@@ -3906,6 +3988,38 @@ func TestImpersonatorConfigControllerSync(t *testing.T) {
 					r.Len(kubeAPIClient.Actions(), 0)
 				})
 			})
+
+			when("the service account tokens cache TTL is 0, which uses the default", func() {
+				it.Before(func() {
+					addSecretToTrackers(signingCASecret, kubeInformerClient)
+					addCredentialIssuerToTrackers(v1alpha1.CredentialIssuer{
+						ObjectMeta: metav1.ObjectMeta{Name: credentialIssuerResourceName},
+						Spec: v1alpha1.CredentialIssuerSpec{
+							ImpersonationProxy: &v1alpha1.ImpersonationProxySpec{
+								Mode:             v1alpha1.ImpersonationProxyModeAuto,
+								ExternalEndpoint: localhostIP,
+								Service: v1alpha1.ImpersonationProxyServiceSpec{
+									Type: v1alpha1.ImpersonationProxyServiceTypeNone,
+								},
+								ServiceAccountTokens: &v1alpha1.ImpersonationProxyServiceAccountTokensSpec{
+									Mode:            v1alpha1.ImpersonationProxyServiceAccountTokensModeBoundOnly,
+									CacheTTLSeconds: 0,
+								},
+							},
+						},
+					}, pinnipedInformerClient, pinnipedAPIClient)
+					addNodeWithRoleToTracker("control-plane", kubeAPIClient)
+				})
+
+				it("does not return a validation error", func() {
+					startInformersAndController()
+					r.NoError(runControllerSync())
+					requireTLSServerWasNeverStarted()
+					requireNodesListed(kubeAPIClient.Actions()[0])
+					r.Len(kubeAPIClient.Actions(), 1)
+					requireCredentialIssuer(newAutoDisabledStrategy())
+				})
+			})
 		})
 	}, spec.Parallel(), spec.Report(report.Terminal{}))
 }
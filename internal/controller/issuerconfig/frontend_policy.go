@@ -0,0 +1,66 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package issuerconfig
+
+import (
+	"fmt"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	conciergeconfiginformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions/config/v1alpha1"
+	"go.pinniped.dev/internal/concierge/frontendpolicy"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/plog"
+)
+
+type frontendPolicyController struct {
+	credentialIssuerResourceName string
+	credIssuerInformer           conciergeconfiginformers.CredentialIssuerInformer
+	policy                       *frontendpolicy.Policy
+}
+
+// NewFrontendPolicyController instantiates a new controllerlib.Controller which will keep the provided
+// frontendpolicy.Policy up to date with the spec.frontends field of the CredentialIssuer.
+func NewFrontendPolicyController(
+	credentialIssuerResourceName string,
+	credentialIssuerInformer conciergeconfiginformers.CredentialIssuerInformer,
+	policy *frontendpolicy.Policy,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "frontend-policy-controller",
+			Syncer: &frontendPolicyController{
+				credentialIssuerResourceName: credentialIssuerResourceName,
+				credIssuerInformer:           credentialIssuerInformer,
+				policy:                       policy,
+			},
+		},
+		controllerlib.WithInformer(
+			credentialIssuerInformer,
+			pinnipedcontroller.SimpleFilterWithSingletonQueue(func(obj metav1.Object) bool {
+				return obj.GetName() == credentialIssuerResourceName
+			}),
+			controllerlib.InformerOption{},
+		),
+	)
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *frontendPolicyController) Sync(_ controllerlib.Context) error {
+	credIssuer, err := c.credIssuerInformer.Lister().Get(c.credentialIssuerResourceName)
+	if k8serrors.IsNotFound(err) {
+		// Without a CredentialIssuer, there is nothing restricting the use of any authenticator.
+		c.policy.Set(nil)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get CredentialIssuer: %w", err)
+	}
+
+	plog.Debug("updating frontend policy", "credentialIssuer", credIssuer.Name, "frontends", len(credIssuer.Spec.Frontends))
+	c.policy.Set(credIssuer.Spec.Frontends)
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package issuerconfig
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	pinnipedfake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	pinnipedinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
+	"go.pinniped.dev/internal/concierge/frontendpolicy"
+	"go.pinniped.dev/internal/controllerlib"
+)
+
+func TestFrontendPolicyController(t *testing.T) {
+	t.Parallel()
+
+	restrictedFrontends := []v1alpha1.CredentialIssuerFrontendSpec{
+		{
+			Type:                  v1alpha1.TokenCredentialRequestAPIFrontendType,
+			AllowedAuthenticators: []v1alpha1.AuthenticatorReference{{Kind: "JWTAuthenticator", Name: "internal"}},
+		},
+	}
+
+	tests := []struct {
+		name                string
+		objects             []runtime.Object
+		initialFrontends    []v1alpha1.CredentialIssuerFrontendSpec
+		wantInternalAllowed bool
+		wantExternalAllowed bool
+	}{
+		{
+			name:                "no CredentialIssuer resets the policy",
+			initialFrontends:    restrictedFrontends,
+			wantInternalAllowed: true,
+			wantExternalAllowed: true,
+		},
+		{
+			name: "a different CredentialIssuer is ignored",
+			objects: []runtime.Object{
+				&v1alpha1.CredentialIssuer{
+					ObjectMeta: metav1.ObjectMeta{Name: "some-other-credential-issuer"},
+					Spec:       v1alpha1.CredentialIssuerSpec{Frontends: restrictedFrontends},
+				},
+			},
+			wantInternalAllowed: true,
+			wantExternalAllowed: true,
+		},
+		{
+			name: "the frontends of the CredentialIssuer are loaded",
+			objects: []runtime.Object{
+				&v1alpha1.CredentialIssuer{
+					ObjectMeta: metav1.ObjectMeta{Name: "some-credential-issuer"},
+					Spec:       v1alpha1.CredentialIssuerSpec{Frontends: restrictedFrontends},
+				},
+			},
+			wantInternalAllowed: true,
+			wantExternalAllowed: false,
+		},
+		{
+			name: "a CredentialIssuer without frontends resets the policy",
+			objects: []runtime.Object{
+				&v1alpha1.CredentialIssuer{
+					ObjectMeta: metav1.ObjectMeta{Name: "some-credential-issuer"},
+				},
+			},
+			initialFrontends:    restrictedFrontends,
+			wantInternalAllowed: true,
+			wantExternalAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeClient := pinnipedfake.NewSimpleClientset(tt.objects...)
+			informers := pinnipedinformers.NewSharedInformerFactory(fakeClient, 0)
+			policy := frontendpolicy.New()
+			policy.Set(tt.initialFrontends)
+
+			controller := NewFrontendPolicyController(
+				"some-credential-issuer",
+				informers.Config().V1alpha1().CredentialIssuers(),
+				policy,
+			)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			informers.Start(ctx.Done())
+			informers.WaitForCacheSync(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			require.NoError(t, controllerlib.TestSync(t, controller, controllerlib.Context{Context: ctx}))
+
+			tcr := v1alpha1.TokenCredentialRequestAPIFrontendType
+			require.Equal(t, tt.wantInternalAllowed, policy.Allowed(tcr, "JWTAuthenticator", "internal"))
+			require.Equal(t, tt.wantExternalAllowed, policy.Allowed(tcr, "JWTAuthenticator", "external"))
+			require.True(t, policy.Allowed(v1alpha1.ImpersonationProxyFrontendType, "JWTAuthenticator", "external"))
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubecertagent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/utils/clock"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	configv1alpha1informers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions/config/v1alpha1"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/issuerconfig"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/kubeclient"
+)
+
+// CSRSignerConfig is the configuration for the CSR signer controller.
+type CSRSignerConfig struct {
+	// SignerName is the signerName which is used for the CertificateSigningRequests created by the Concierge.
+	SignerName string
+
+	// CredentialIssuerName specifies the CredentialIssuer to be updated.
+	CredentialIssuerName string
+
+	// DiscoveryURLOverride is the Kubernetes server endpoint to report in the CredentialIssuer, overriding any
+	// value discovered in the kube-public/cluster-info ConfigMap.
+	DiscoveryURLOverride *string
+}
+
+// SignerProber checks that a signer issues certificates.
+type SignerProber interface {
+	Probe(ctx context.Context) error
+}
+
+// signerProbeInterval is how often the signer is probed. Each probe creates a CertificateSigningRequest,
+// so this should not be too frequent.
+const signerProbeInterval = 5 * time.Minute
+
+type csrSignerController struct {
+	cfg                  CSRSignerConfig
+	client               *kubeclient.Client
+	prober               SignerProber
+	kubePublicConfigMaps corev1informers.ConfigMapInformer
+	credentialIssuers    configv1alpha1informers.CredentialIssuerInformer
+	clock                clock.Clock
+
+	lastProbeTime time.Time
+	lastProbeErr  error
+}
+
+// NewCSRSignerController returns a controller that reports the status of the strategy which issues client
+// certificates using the Kubernetes CertificateSigningRequest API on the CredentialIssuer.
+func NewCSRSignerController(
+	cfg CSRSignerConfig,
+	client *kubeclient.Client,
+	prober SignerProber,
+	kubePublicConfigMaps corev1informers.ConfigMapInformer,
+	credentialIssuers configv1alpha1informers.CredentialIssuerInformer,
+	clock clock.Clock,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: "kube-csr-signer-controller",
+			Syncer: &csrSignerController{
+				cfg:                  cfg,
+				client:               client,
+				prober:               prober,
+				kubePublicConfigMaps: kubePublicConfigMaps,
+				credentialIssuers:    credentialIssuers,
+				clock:                clock,
+			},
+		},
+		controllerlib.WithInformer(
+			kubePublicConfigMaps,
+			pinnipedcontroller.SimpleFilterWithSingletonQueue(func(obj metav1.Object) bool {
+				return obj.GetNamespace() == ClusterInfoNamespace && obj.GetName() == clusterInfoName
+			}),
+			controllerlib.InformerOption{},
+		),
+		controllerlib.WithInformer(
+			credentialIssuers,
+			pinnipedcontroller.SimpleFilterWithSingletonQueue(func(obj metav1.Object) bool {
+				return obj.GetName() == cfg.CredentialIssuerName
+			}),
+			controllerlib.InformerOption{},
+		),
+		controllerlib.WithInitialEvent(controllerlib.Key{}),
+	)
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *csrSignerController) Sync(ctx controllerlib.Context) error {
+	// Load the CredentialIssuer that we'll update with status.
+	credIssuer, err := c.credentialIssuers.Lister().Get(c.cfg.CredentialIssuerName)
+	if err != nil {
+		return fmt.Errorf("could not get CredentialIssuer to update: %w", err)
+	}
+
+	strategy := configv1alpha1.CredentialIssuerStrategy{
+		Type:           configv1alpha1.KubeCertificateSigningRequestStrategyType,
+		LastUpdateTime: metav1.NewTime(c.clock.Now()),
+	}
+
+	// Load the Kubernetes API info from the kube-public/cluster-info ConfigMap.
+	configMap, err := c.kubePublicConfigMaps.Lister().ConfigMaps(ClusterInfoNamespace).Get(clusterInfoName)
+	if err != nil {
+		err = fmt.Errorf("failed to get %s/%s configmap: %w", ClusterInfoNamespace, clusterInfoName, err)
+	}
+	var apiInfo *configv1alpha1.TokenCredentialRequestAPIInfo
+	if err == nil {
+		apiInfo, err = extractAPIInfo(configMap, c.cfg.DiscoveryURLOverride)
+		if err != nil {
+			err = fmt.Errorf("could not extract Kubernetes API endpoint info from %s/%s configmap: %w", ClusterInfoNamespace, clusterInfoName, err)
+		}
+	}
+
+	if err != nil {
+		strategy.Status = configv1alpha1.ErrorStrategyStatus
+		strategy.Reason = configv1alpha1.CouldNotGetClusterInfoStrategyReason
+		strategy.Message = err.Error()
+		updateErr := issuerconfig.Update(ctx.Context, c.client.PinnipedConcierge, credIssuer, strategy)
+		return utilerrors.NewAggregate([]error{err, updateErr})
+	}
+
+	c.probeIfDue(ctx)
+	if c.lastProbeErr != nil {
+		strategy.Status = configv1alpha1.ErrorStrategyStatus
+		strategy.Reason = configv1alpha1.SignerNotIssuingStrategyReason
+		strategy.Message = fmt.Sprintf("signer %q did not issue a certificate: %s", c.cfg.SignerName, c.lastProbeErr.Error())
+		return issuerconfig.Update(ctx.Context, c.client.PinnipedConcierge, credIssuer, strategy)
+	}
+
+	strategy.Status = configv1alpha1.SuccessStrategyStatus
+	strategy.Reason = configv1alpha1.SignerConfiguredStrategyReason
+	strategy.Message = fmt.Sprintf("client certificates will be requested from signer %q", c.cfg.SignerName)
+	strategy.Frontend = &configv1alpha1.CredentialIssuerFrontend{
+		Type:                          configv1alpha1.TokenCredentialRequestAPIFrontendType,
+		TokenCredentialRequestAPIInfo: apiInfo,
+	}
+	return issuerconfig.Update(ctx.Context, c.client.PinnipedConcierge, credIssuer, strategy)
+}
+
+// probeIfDue probes the signer when it has not been probed recently. Probing the signer creates a
+// CertificateSigningRequest, so it is only done periodically and not on every sync.
+func (c *csrSignerController) probeIfDue(ctx controllerlib.Context) {
+	now := c.clock.Now()
+	if !c.lastProbeTime.IsZero() && now.Sub(c.lastProbeTime) < signerProbeInterval {
+		return
+	}
+	c.lastProbeTime = now
+	c.lastProbeErr = c.prober.Probe(ctx.Context)
+	ctx.Queue.AddAfter(ctx.Key, signerProbeInterval)
+}
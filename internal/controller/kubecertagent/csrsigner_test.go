@@ -0,0 +1,252 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubecertagent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/pointer"
+
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
+	conciergefake "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned/fake"
+	conciergeinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/kubeclient"
+)
+
+func TestCSRSignerController(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2022, 5, 4, 3, 2, 1, 0, time.UTC)
+
+	initialCredentialIssuer := &configv1alpha1.CredentialIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "pinniped-concierge-config"},
+	}
+
+	validClusterInfoConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-public", Name: "cluster-info"},
+		Data: map[string]string{"kubeconfig": here.Docf(`
+			kind: Config
+			apiVersion: v1
+			clusters:
+			- name: ""
+			  cluster:
+				certificate-authority-data: dGVzdC1rdWJlcm5ldGVzLWNh # "test-kubernetes-ca"
+				server: https://test-kubernetes-endpoint.example.com
+			`),
+		},
+	}
+
+	tests := []struct {
+		name                 string
+		discoveryURLOverride *string
+		pinnipedObjects      []runtime.Object
+		kubeObjects          []runtime.Object
+		probeErr             error
+		wantErr              string
+		wantProbed           bool
+		wantStrategy         *configv1alpha1.CredentialIssuerStrategy
+	}{
+		{
+			name:    "no CredentialIssuer found",
+			wantErr: `could not get CredentialIssuer to update: credentialissuer.config.concierge.pinniped.dev "pinniped-concierge-config" not found`,
+		},
+		{
+			name:            "cluster-info ConfigMap is missing",
+			pinnipedObjects: []runtime.Object{initialCredentialIssuer},
+			wantErr:         `failed to get kube-public/cluster-info configmap: configmap "cluster-info" not found`,
+			wantStrategy: &configv1alpha1.CredentialIssuerStrategy{
+				Type:           configv1alpha1.KubeCertificateSigningRequestStrategyType,
+				Status:         configv1alpha1.ErrorStrategyStatus,
+				Reason:         configv1alpha1.CouldNotGetClusterInfoStrategyReason,
+				Message:        `failed to get kube-public/cluster-info configmap: configmap "cluster-info" not found`,
+				LastUpdateTime: metav1.NewTime(now),
+			},
+		},
+		{
+			name:            "cluster-info ConfigMap is missing the kubeconfig key",
+			pinnipedObjects: []runtime.Object{initialCredentialIssuer},
+			kubeObjects: []runtime.Object{
+				&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-public", Name: "cluster-info"}},
+			},
+			wantErr: `could not extract Kubernetes API endpoint info from kube-public/cluster-info configmap: missing "kubeconfig" key`,
+			wantStrategy: &configv1alpha1.CredentialIssuerStrategy{
+				Type:           configv1alpha1.KubeCertificateSigningRequestStrategyType,
+				Status:         configv1alpha1.ErrorStrategyStatus,
+				Reason:         configv1alpha1.CouldNotGetClusterInfoStrategyReason,
+				Message:        `could not extract Kubernetes API endpoint info from kube-public/cluster-info configmap: missing "kubeconfig" key`,
+				LastUpdateTime: metav1.NewTime(now),
+			},
+		},
+		{
+			name:            "the signer does not issue a certificate",
+			pinnipedObjects: []runtime.Object{initialCredentialIssuer},
+			kubeObjects:     []runtime.Object{validClusterInfoConfigMap},
+			probeErr:        errors.New("some probe error"),
+			wantProbed:      true,
+			wantStrategy: &configv1alpha1.CredentialIssuerStrategy{
+				Type:           configv1alpha1.KubeCertificateSigningRequestStrategyType,
+				Status:         configv1alpha1.ErrorStrategyStatus,
+				Reason:         configv1alpha1.SignerNotIssuingStrategyReason,
+				Message:        `signer "example.com/some-signer" did not issue a certificate: some probe error`,
+				LastUpdateTime: metav1.NewTime(now),
+			},
+		},
+		{
+			name:            "success",
+			pinnipedObjects: []runtime.Object{initialCredentialIssuer},
+			kubeObjects:     []runtime.Object{validClusterInfoConfigMap},
+			wantProbed:      true,
+			wantStrategy: &configv1alpha1.CredentialIssuerStrategy{
+				Type:           configv1alpha1.KubeCertificateSigningRequestStrategyType,
+				Status:         configv1alpha1.SuccessStrategyStatus,
+				Reason:         configv1alpha1.SignerConfiguredStrategyReason,
+				Message:        `client certificates will be requested from signer "example.com/some-signer"`,
+				LastUpdateTime: metav1.NewTime(now),
+				Frontend: &configv1alpha1.CredentialIssuerFrontend{
+					Type: configv1alpha1.TokenCredentialRequestAPIFrontendType,
+					TokenCredentialRequestAPIInfo: &configv1alpha1.TokenCredentialRequestAPIInfo{
+						Server:                   "https://test-kubernetes-endpoint.example.com",
+						CertificateAuthorityData: "dGVzdC1rdWJlcm5ldGVzLWNh",
+					},
+				},
+			},
+		},
+		{
+			name:                 "success with discovery URL override",
+			discoveryURLOverride: pointer.StringPtr("https://overridden-server.example.com/some/path"),
+			pinnipedObjects:      []runtime.Object{initialCredentialIssuer},
+			kubeObjects:          []runtime.Object{validClusterInfoConfigMap},
+			wantProbed:           true,
+			wantStrategy: &configv1alpha1.CredentialIssuerStrategy{
+				Type:           configv1alpha1.KubeCertificateSigningRequestStrategyType,
+				Status:         configv1alpha1.SuccessStrategyStatus,
+				Reason:         configv1alpha1.SignerConfiguredStrategyReason,
+				Message:        `client certificates will be requested from signer "example.com/some-signer"`,
+				LastUpdateTime: metav1.NewTime(now),
+				Frontend: &configv1alpha1.CredentialIssuerFrontend{
+					Type: configv1alpha1.TokenCredentialRequestAPIFrontendType,
+					TokenCredentialRequestAPIInfo: &configv1alpha1.TokenCredentialRequestAPIInfo{
+						Server:                   "https://overridden-server.example.com/some/path",
+						CertificateAuthorityData: "dGVzdC1rdWJlcm5ldGVzLWNh",
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conciergeClientset := conciergefake.NewSimpleClientset(tt.pinnipedObjects...)
+			conciergeInformers := conciergeinformers.NewSharedInformerFactory(conciergeClientset, 0)
+			kubeClientset := kubefake.NewSimpleClientset(tt.kubeObjects...)
+			kubeInformers := informers.NewSharedInformerFactory(kubeClientset, 0)
+			prober := &fakeSignerProber{err: tt.probeErr}
+			queue := &testQueue{}
+
+			controller := NewCSRSignerController(
+				CSRSignerConfig{
+					SignerName:           "example.com/some-signer",
+					CredentialIssuerName: initialCredentialIssuer.Name,
+					DiscoveryURLOverride: tt.discoveryURLOverride,
+				},
+				&kubeclient.Client{Kubernetes: kubeClientset, PinnipedConcierge: conciergeClientset},
+				prober,
+				kubeInformers.Core().V1().ConfigMaps(),
+				conciergeInformers.Config().V1alpha1().CredentialIssuers(),
+				clocktesting.NewFakeClock(now),
+			)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			kubeInformers.Start(ctx.Done())
+			conciergeInformers.Start(ctx.Done())
+			kubeInformers.WaitForCacheSync(ctx.Done())
+			conciergeInformers.WaitForCacheSync(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			err := controllerlib.TestSync(t, controller, controllerlib.Context{Context: ctx, Queue: queue})
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			if tt.wantProbed {
+				require.Equal(t, 1, prober.calls)
+				require.Equal(t, []time.Duration{5 * time.Minute}, queue.durations)
+			} else {
+				require.Zero(t, prober.calls)
+				require.Empty(t, queue.durations)
+			}
+
+			if tt.wantStrategy != nil {
+				credIssuer, err := conciergeClientset.ConfigV1alpha1().CredentialIssuers().Get(ctx, initialCredentialIssuer.Name, metav1.GetOptions{})
+				require.NoError(t, err)
+				require.Len(t, credIssuer.Status.Strategies, 1, "expected a single strategy in the CredentialIssuer")
+				require.Equal(t, tt.wantStrategy, &credIssuer.Status.Strategies[0])
+			}
+		})
+	}
+}
+
+func TestCSRSignerControllerProbesPeriodically(t *testing.T) {
+	t.Parallel()
+
+	clock := clocktesting.NewFakeClock(time.Date(2022, 5, 4, 3, 2, 1, 0, time.UTC))
+	prober := &fakeSignerProber{}
+	syncer := &csrSignerController{prober: prober, clock: clock}
+	queue := &testQueue{}
+	ctx := controllerlib.Context{Context: context.Background(), Queue: queue}
+
+	syncer.probeIfDue(ctx)
+	require.Equal(t, 1, prober.calls)
+	require.NoError(t, syncer.lastProbeErr)
+
+	// The signer is not probed again on every sync.
+	prober.err = errors.New("some probe error")
+	clock.Step(signerProbeInterval - time.Second)
+	syncer.probeIfDue(ctx)
+	require.Equal(t, 1, prober.calls)
+	require.NoError(t, syncer.lastProbeErr)
+
+	clock.Step(time.Second)
+	syncer.probeIfDue(ctx)
+	require.Equal(t, 2, prober.calls)
+	require.EqualError(t, syncer.lastProbeErr, "some probe error")
+	require.Equal(t, []time.Duration{signerProbeInterval, signerProbeInterval}, queue.durations)
+}
+
+type fakeSignerProber struct {
+	err   error
+	calls int
+}
+
+func (p *fakeSignerProber) Probe(_ context.Context) error {
+	p.calls++
+	return p.err
+}
+
+type testQueue struct {
+	durations []time.Duration
+
+	controllerlib.Queue // panic if any other methods called
+}
+
+func (q *testQueue) AddAfter(_ controllerlib.Key, duration time.Duration) {
+	q.durations = append(q.durations, duration)
+}
@@ -310,7 +310,7 @@ func (c *agentController) Sync(ctx controllerlib.Context) error {
 		return c.failStrategyAndErr(ctx.Context, credIssuer, firstErr(depErr, err), configv1alpha1.CouldNotGetClusterInfoStrategyReason)
 	}
 
-	apiInfo, err := c.extractAPIInfo(configMap)
+	apiInfo, err := extractAPIInfo(configMap, c.cfg.DiscoveryURLOverride)
 	if err != nil {
 		err := fmt.Errorf("could not extract Kubernetes API endpoint info from %s/%s configmap: %w", ClusterInfoNamespace, clusterInfoName, err)
 		return c.failStrategyAndErr(ctx.Context, credIssuer, firstErr(depErr, err), configv1alpha1.CouldNotGetClusterInfoStrategyReason)
@@ -456,7 +456,9 @@ func (c *agentController) failStrategyAndErr(ctx context.Context, credIssuer *co
 	return utilerrors.NewAggregate([]error{err, updateErr})
 }
 
-func (c *agentController) extractAPIInfo(configMap *corev1.ConfigMap) (*configv1alpha1.TokenCredentialRequestAPIInfo, error) {
+// extractAPIInfo reads the Kubernetes API endpoint info from the kube-public/cluster-info ConfigMap, optionally
+// overriding the server URL.
+func extractAPIInfo(configMap *corev1.ConfigMap, discoveryURLOverride *string) (*configv1alpha1.TokenCredentialRequestAPIInfo, error) {
 	kubeConfigYAML, kubeConfigPresent := configMap.Data[clusterInfoConfigMapKey]
 	if !kubeConfigPresent {
 		return nil, fmt.Errorf("missing %q key", clusterInfoConfigMapKey)
@@ -473,8 +475,8 @@ func (c *agentController) extractAPIInfo(configMap *corev1.ConfigMap) (*configv1
 			Server:                   v.Server,
 			CertificateAuthorityData: base64.StdEncoding.EncodeToString(v.CertificateAuthorityData),
 		}
-		if c.cfg.DiscoveryURLOverride != nil {
-			result.Server = *c.cfg.DiscoveryURLOverride
+		if discoveryURLOverride != nil {
+			result.Server = *discoveryURLOverride
 		}
 		return result, nil
 	}
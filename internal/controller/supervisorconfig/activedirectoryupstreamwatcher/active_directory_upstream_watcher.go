@@ -7,12 +7,9 @@ package activedirectoryupstreamwatcher
 import (
 	"context"
 	"fmt"
-	"regexp"
-	"strconv"
-	"strings"
 
-	"github.com/go-ldap/ldap/v3"
-	"github.com/google/uuid"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -33,191 +30,16 @@ import (
 const (
 	activeDirectoryControllerName = "active-directory-upstream-observer"
 
-	// Default values for active directory config.
-	defaultActiveDirectoryUsernameAttributeName = "userPrincipalName"
-	defaultActiveDirectoryUIDAttributeName      = "objectGUID"
+	// KerberosKeytabSecretType is the type of the Secret which contains the keytab of the Supervisor's service
+	// principal, which is used to validate the Kerberos tickets presented by browsers.
+	KerberosKeytabSecretType = corev1.SecretType("secrets.pinniped.dev/kerberos-keytab")
+	kerberosKeytabSecretKey  = "keytab"
 
-	// By default this group name attribute is the sAMAccountName with special mapping.
-	// Each group will look like sAMAccountName + "@" + domain.
-	// For example if your group sAMAccountName is "mammals" and your domain is
-	// "activedirectory.example.com", it would be mammals@activedirectory.example.com.
-	// This is because sAMAccountName is only unique within a domain, not a forest.
-	defaultActiveDirectoryGroupNameAttributeName = "sAMAccountName"
-
-	// - is a person.
-	// - is not a computer.
-	// - is not shown in advanced view only (which would likely mean its a system created service account with advanced permissions).
-	// - either the sAMAccountName, the userPrincipalName or the mail attribute matches the input username.
-	// - the sAMAccountType is for a normal user account.
-	defaultActiveDirectoryUserSearchFilter = "(&(objectClass=person)(!(objectClass=computer))(!(showInAdvancedViewOnly=TRUE))(|(sAMAccountName={})(mail={})(userPrincipalName={}))(sAMAccountType=805306368))"
-
-	// - is a group.
-	// - has a member that matches the DN of the user we successfully logged in as.
-	// - perform nested group search by default.
-	defaultActiveDirectoryGroupSearchFilter = "(&(objectClass=group)(member:1.2.840.113556.1.4.1941:={}))"
-
-	sAMAccountNameAttribute = "sAMAccountName"
-	// pwdLastSetAttribute is the date and time that the password for this account was last changed.
-	// https://docs.microsoft.com/en-us/windows/win32/adschema/a-pwdlastset
-	pwdLastSetAttribute = "pwdLastSet"
-	// userAccountControlAttribute represents a bitmap of user properties.
-	// https://docs.microsoft.com/en-us/troubleshoot/windows-server/identity/useraccountcontrol-manipulate-account-properties
-	userAccountControlAttribute = "userAccountControl"
-	// userAccountControlComputedAttribute represents a bitmap of user properties.
-	// https://docs.microsoft.com/en-us/windows/win32/adschema/a-msds-user-account-control-computed
-	userAccountControlComputedAttribute = "msDS-User-Account-Control-Computed"
-	// 0x0002 ACCOUNTDISABLE in userAccountControl bitmap.
-	accountDisabledBitmapValue = 2
-	// 0x0010 UF_LOCKOUT in msDS-User-Account-Control-Computed bitmap.
-	accountLockedBitmapValue = 16
+	// Constants related to conditions.
+	typeKerberosKeytabValid = "KerberosKeytabValid"
+	reasonInvalidKeytab     = "SecretInvalidKeytab"
 )
 
-type activeDirectoryUpstreamGenericLDAPImpl struct {
-	activeDirectoryIdentityProvider v1alpha1.ActiveDirectoryIdentityProvider
-}
-
-func (g *activeDirectoryUpstreamGenericLDAPImpl) Spec() upstreamwatchers.UpstreamGenericLDAPSpec {
-	return &activeDirectoryUpstreamGenericLDAPSpec{g.activeDirectoryIdentityProvider}
-}
-
-func (g *activeDirectoryUpstreamGenericLDAPImpl) Namespace() string {
-	return g.activeDirectoryIdentityProvider.Namespace
-}
-
-func (g *activeDirectoryUpstreamGenericLDAPImpl) Name() string {
-	return g.activeDirectoryIdentityProvider.Name
-}
-
-func (g *activeDirectoryUpstreamGenericLDAPImpl) Generation() int64 {
-	return g.activeDirectoryIdentityProvider.Generation
-}
-
-func (g *activeDirectoryUpstreamGenericLDAPImpl) Status() upstreamwatchers.UpstreamGenericLDAPStatus {
-	return &activeDirectoryUpstreamGenericLDAPStatus{g.activeDirectoryIdentityProvider}
-}
-
-type activeDirectoryUpstreamGenericLDAPSpec struct {
-	activeDirectoryIdentityProvider v1alpha1.ActiveDirectoryIdentityProvider
-}
-
-func (s *activeDirectoryUpstreamGenericLDAPSpec) Host() string {
-	return s.activeDirectoryIdentityProvider.Spec.Host
-}
-
-func (s *activeDirectoryUpstreamGenericLDAPSpec) TLSSpec() *v1alpha1.TLSSpec {
-	return s.activeDirectoryIdentityProvider.Spec.TLS
-}
-
-func (s *activeDirectoryUpstreamGenericLDAPSpec) BindSecretName() string {
-	return s.activeDirectoryIdentityProvider.Spec.Bind.SecretName
-}
-
-func (s *activeDirectoryUpstreamGenericLDAPSpec) UserSearch() upstreamwatchers.UpstreamGenericLDAPUserSearch {
-	return &activeDirectoryUpstreamGenericLDAPUserSearch{s.activeDirectoryIdentityProvider.Spec.UserSearch}
-}
-
-func (s *activeDirectoryUpstreamGenericLDAPSpec) GroupSearch() upstreamwatchers.UpstreamGenericLDAPGroupSearch {
-	return &activeDirectoryUpstreamGenericLDAPGroupSearch{s.activeDirectoryIdentityProvider.Spec.GroupSearch}
-}
-
-func (s *activeDirectoryUpstreamGenericLDAPSpec) DetectAndSetSearchBase(ctx context.Context, config *upstreamldap.ProviderConfig) *v1alpha1.Condition {
-	config.GroupSearch.Base = s.activeDirectoryIdentityProvider.Spec.GroupSearch.Base
-	config.UserSearch.Base = s.activeDirectoryIdentityProvider.Spec.UserSearch.Base
-	if config.GroupSearch.Base != "" && config.UserSearch.Base != "" {
-		// Both were already set in spec so just return; no need to query the RootDSE
-		return &v1alpha1.Condition{
-			Type:    upstreamwatchers.TypeSearchBaseFound,
-			Status:  v1alpha1.ConditionTrue,
-			Reason:  upstreamwatchers.ReasonUsingConfigurationFromSpec,
-			Message: "Using search base from ActiveDirectoryIdentityProvider config.",
-		}
-	}
-	ldapProvider := upstreamldap.New(*config)
-	// Query your AD server for the defaultNamingContext to get a DN to use as the search base
-	// when it isn't specified.
-	// https://ldapwiki.com/wiki/DefaultNamingContext
-	defaultNamingContext, err := ldapProvider.SearchForDefaultNamingContext(ctx)
-	if err != nil {
-		return &v1alpha1.Condition{
-			Type:    upstreamwatchers.TypeSearchBaseFound,
-			Status:  v1alpha1.ConditionFalse,
-			Reason:  upstreamwatchers.ReasonErrorFetchingSearchBase,
-			Message: fmt.Sprintf(`Error finding search base: %s`, err.Error()),
-		}
-	}
-	if config.UserSearch.Base == "" {
-		config.UserSearch.Base = defaultNamingContext
-	}
-	if config.GroupSearch.Base == "" {
-		config.GroupSearch.Base = defaultNamingContext
-	}
-	return &v1alpha1.Condition{
-		Type:    upstreamwatchers.TypeSearchBaseFound,
-		Status:  v1alpha1.ConditionTrue,
-		Reason:  upstreamwatchers.ReasonSuccess,
-		Message: "Successfully fetched defaultNamingContext to use as default search base from RootDSE.",
-	}
-}
-
-type activeDirectoryUpstreamGenericLDAPUserSearch struct {
-	userSearch v1alpha1.ActiveDirectoryIdentityProviderUserSearch
-}
-
-func (u *activeDirectoryUpstreamGenericLDAPUserSearch) Base() string {
-	return u.userSearch.Base
-}
-
-func (u *activeDirectoryUpstreamGenericLDAPUserSearch) Filter() string {
-	if len(u.userSearch.Filter) == 0 {
-		return defaultActiveDirectoryUserSearchFilter
-	}
-	return u.userSearch.Filter
-}
-
-func (u *activeDirectoryUpstreamGenericLDAPUserSearch) UsernameAttribute() string {
-	if len(u.userSearch.Attributes.Username) == 0 {
-		return defaultActiveDirectoryUsernameAttributeName
-	}
-	return u.userSearch.Attributes.Username
-}
-
-func (u *activeDirectoryUpstreamGenericLDAPUserSearch) UIDAttribute() string {
-	if len(u.userSearch.Attributes.UID) == 0 {
-		return defaultActiveDirectoryUIDAttributeName
-	}
-	return u.userSearch.Attributes.UID
-}
-
-type activeDirectoryUpstreamGenericLDAPGroupSearch struct {
-	groupSearch v1alpha1.ActiveDirectoryIdentityProviderGroupSearch
-}
-
-func (g *activeDirectoryUpstreamGenericLDAPGroupSearch) Base() string {
-	return g.groupSearch.Base
-}
-
-func (g *activeDirectoryUpstreamGenericLDAPGroupSearch) Filter() string {
-	if len(g.groupSearch.Filter) == 0 {
-		return defaultActiveDirectoryGroupSearchFilter
-	}
-	return g.groupSearch.Filter
-}
-
-func (g *activeDirectoryUpstreamGenericLDAPGroupSearch) GroupNameAttribute() string {
-	if len(g.groupSearch.Attributes.GroupName) == 0 {
-		return defaultActiveDirectoryGroupNameAttributeName
-	}
-	return g.groupSearch.Attributes.GroupName
-}
-
-type activeDirectoryUpstreamGenericLDAPStatus struct {
-	activeDirectoryIdentityProvider v1alpha1.ActiveDirectoryIdentityProvider
-}
-
-func (s *activeDirectoryUpstreamGenericLDAPStatus) Conditions() []v1alpha1.Condition {
-	return s.activeDirectoryIdentityProvider.Status.Conditions
-}
-
 // UpstreamActiveDirectoryIdentityProviderICache is a thread safe cache that holds a list of validated upstream LDAP IDP configurations.
 type UpstreamActiveDirectoryIdentityProviderICache interface {
 	SetActiveDirectoryIdentityProviders([]provider.UpstreamLDAPIdentityProviderI)
@@ -227,6 +49,8 @@ type activeDirectoryWatcherController struct {
 	cache                                   UpstreamActiveDirectoryIdentityProviderICache
 	validatedSettingsCache                  upstreamwatchers.ValidatedSettingsCacheI
 	ldapDialer                              upstreamldap.LDAPDialer
+	connectionPools                         *upstreamldap.ConnectionPools
+	groupCaches                             *upstreamldap.GroupCaches
 	client                                  pinnipedclientset.Interface
 	activeDirectoryIdentityProviderInformer idpinformers.ActiveDirectoryIdentityProviderInformer
 	secretInformer                          corev1informers.SecretInformer
@@ -267,6 +91,8 @@ func newInternal(
 		cache:                                   idpCache,
 		validatedSettingsCache:                  validatedSettingsCache,
 		ldapDialer:                              ldapDialer,
+		connectionPools:                         upstreamldap.NewConnectionPools(upstreamldap.ConnectionPoolConfig{UpstreamType: "activedirectory"}),
+		groupCaches:                             upstreamldap.NewGroupCaches(),
 		client:                                  client,
 		activeDirectoryIdentityProviderInformer: activeDirectoryIdentityProviderInformer,
 		secretInformer:                          secretInformer,
@@ -280,7 +106,14 @@ func newInternal(
 		),
 		withInformer(
 			secretInformer,
-			pinnipedcontroller.MatchAnySecretOfTypeFilter(upstreamwatchers.LDAPBindAccountSecretType, pinnipedcontroller.SingletonQueue()),
+			pinnipedcontroller.MatchAnySecretOfTypesFilter(
+				[]corev1.SecretType{
+					upstreamwatchers.LDAPBindAccountSecretType,
+					upstreamwatchers.LDAPBindAccountClientCertificateSecretType,
+					KerberosKeytabSecretType,
+				},
+				pinnipedcontroller.SingletonQueue(),
+			),
 			controllerlib.InformerOption{},
 		),
 	)
@@ -306,6 +139,7 @@ func (c *activeDirectoryWatcherController) Sync(ctx controllerlib.Context) error
 	}
 
 	c.cache.SetActiveDirectoryIdentityProviders(validatedUpstreams)
+	upstreamwatchers.PruneConnectionPools(c.connectionPools, c.groupCaches, validatedUpstreams)
 
 	if requeue {
 		return controllerlib.ErrSyntheticRequeue
@@ -314,48 +148,69 @@ func (c *activeDirectoryWatcherController) Sync(ctx controllerlib.Context) error
 }
 
 func (c *activeDirectoryWatcherController) validateUpstream(ctx context.Context, upstream *v1alpha1.ActiveDirectoryIdentityProvider) (p provider.UpstreamLDAPIdentityProviderI, requeue bool) {
-	spec := upstream.Spec
+	config := upstreamwatchers.ActiveDirectoryProviderConfig(upstream)
+	config.Dialer = c.ldapDialer
+	config.ConnectionPool = c.connectionPools.ForProvider(upstream.UID)
+	config.GroupCache = c.groupCaches.ForProvider(upstream.UID)
+
+	conditions := upstreamwatchers.ValidateGenericLDAP(ctx, upstreamwatchers.ActiveDirectoryUpstreamGenericLDAPImpl(upstream), c.secretInformer, c.validatedSettingsCache, config)
+	if upstream.Spec.Kerberos != nil {
+		// An invalid keytab is not fatal, since users can still log in using the login form.
+		conditions.Append(c.validateKerberosKeytab(upstream.Namespace, upstream.Spec.Kerberos, config), false)
+	}
 
-	adUpstreamImpl := &activeDirectoryUpstreamGenericLDAPImpl{activeDirectoryIdentityProvider: *upstream}
+	c.updateStatus(ctx, upstream, conditions.Conditions())
+
+	return upstreamwatchers.EvaluateConditions(conditions, config)
+}
 
-	config := &upstreamldap.ProviderConfig{
-		Name:        upstream.Name,
-		ResourceUID: upstream.UID,
-		Host:        spec.Host,
-		UserSearch: upstreamldap.UserSearchConfig{
-			Base:              spec.UserSearch.Base,
-			Filter:            adUpstreamImpl.Spec().UserSearch().Filter(),
-			UsernameAttribute: adUpstreamImpl.Spec().UserSearch().UsernameAttribute(),
-			UIDAttribute:      adUpstreamImpl.Spec().UserSearch().UIDAttribute(),
-		},
-		GroupSearch: upstreamldap.GroupSearchConfig{
-			Base:               spec.GroupSearch.Base,
-			Filter:             adUpstreamImpl.Spec().GroupSearch().Filter(),
-			GroupNameAttribute: adUpstreamImpl.Spec().GroupSearch().GroupNameAttribute(),
-			SkipGroupRefresh:   spec.GroupSearch.SkipGroupRefresh,
-		},
-		Dialer: c.ldapDialer,
-		UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){
-			"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID"),
-		},
-		RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
-			pwdLastSetAttribute:                 upstreamldap.AttributeUnchangedSinceLogin(pwdLastSetAttribute),
-			userAccountControlAttribute:         validUserAccountControl,
-			userAccountControlComputedAttribute: validComputedUserAccountControl,
-		},
+// validateKerberosKeytab loads the keytab which is used to validate Kerberos tickets into the config.
+func (c *activeDirectoryWatcherController) validateKerberosKeytab(
+	namespace string,
+	kerberosSpec *v1alpha1.ActiveDirectoryIdentityProviderKerberos,
+	config *upstreamldap.ProviderConfig,
+) *v1alpha1.Condition {
+	secret, err := c.secretInformer.Lister().Secrets(namespace).Get(kerberosSpec.KeytabSecretName)
+	if err != nil {
+		return invalidKerberosKeytabCondition(upstreamwatchers.ReasonNotFound, err.Error())
 	}
 
-	if spec.GroupSearch.Attributes.GroupName == "" {
-		config.GroupAttributeParsingOverrides = map[string]func(*ldap.Entry) (string, error){
-			defaultActiveDirectoryGroupNameAttributeName: groupSAMAccountNameWithDomainSuffix,
-		}
+	if secret.Type != KerberosKeytabSecretType {
+		return invalidKerberosKeytabCondition(upstreamwatchers.ReasonWrongType,
+			fmt.Sprintf("referenced Secret %q has wrong type %q (should be %q)", secret.Name, secret.Type, KerberosKeytabSecretType))
 	}
 
-	conditions := upstreamwatchers.ValidateGenericLDAP(ctx, adUpstreamImpl, c.secretInformer, c.validatedSettingsCache, config)
+	keytabData := secret.Data[kerberosKeytabSecretKey]
+	if len(keytabData) == 0 {
+		return invalidKerberosKeytabCondition(upstreamwatchers.ReasonMissingKeys,
+			fmt.Sprintf("referenced Secret %q is missing required keys %q", secret.Name, []string{kerberosKeytabSecretKey}))
+	}
 
-	c.updateStatus(ctx, upstream, conditions.Conditions())
+	kt := keytab.New()
+	if err := kt.Unmarshal(keytabData); err != nil {
+		return invalidKerberosKeytabCondition(reasonInvalidKeytab,
+			fmt.Sprintf("referenced Secret %q does not contain a valid keytab: %s", secret.Name, err.Error()))
+	}
 
-	return upstreamwatchers.EvaluateConditions(conditions, config)
+	config.Kerberos = upstreamldap.KerberosConfig{
+		Keytab:               kt,
+		ServicePrincipalName: kerberosSpec.ServicePrincipalName,
+	}
+	return &v1alpha1.Condition{
+		Type:    typeKerberosKeytabValid,
+		Status:  v1alpha1.ConditionTrue,
+		Reason:  upstreamwatchers.ReasonSuccess,
+		Message: "loaded kerberos keytab",
+	}
+}
+
+func invalidKerberosKeytabCondition(reason, message string) *v1alpha1.Condition {
+	return &v1alpha1.Condition{
+		Type:    typeKerberosKeytabValid,
+		Status:  v1alpha1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}
 }
 
 func (c *activeDirectoryWatcherController) updateStatus(ctx context.Context, upstream *v1alpha1.ActiveDirectoryIdentityProvider, conditions []*v1alpha1.Condition) {
@@ -381,84 +236,3 @@ func (c *activeDirectoryWatcherController) updateStatus(ctx context.Context, ups
 		log.Error("failed to update status", err)
 	}
 }
-
-func microsoftUUIDFromBinaryAttr(attributeName string) func(entry *ldap.Entry) (string, error) {
-	// validation has already been done so we can just get the attribute...
-	return func(entry *ldap.Entry) (string, error) {
-		binaryUUID := entry.GetRawAttributeValue(attributeName)
-		return microsoftUUIDFromBinary(binaryUUID)
-	}
-}
-
-func microsoftUUIDFromBinary(binaryUUID []byte) (string, error) {
-	uuidVal, err := uuid.FromBytes(binaryUUID) // start out with the RFC4122 version
-	if err != nil {
-		return "", err
-	}
-	// then swap it because AD stores the first 3 fields little-endian rather than the expected
-	// big-endian.
-	uuidVal[0], uuidVal[1], uuidVal[2], uuidVal[3] = uuidVal[3], uuidVal[2], uuidVal[1], uuidVal[0]
-	uuidVal[4], uuidVal[5] = uuidVal[5], uuidVal[4]
-	uuidVal[6], uuidVal[7] = uuidVal[7], uuidVal[6]
-	return uuidVal.String(), nil
-}
-
-func groupSAMAccountNameWithDomainSuffix(entry *ldap.Entry) (string, error) {
-	sAMAccountNameAttributeValues := entry.GetAttributeValues(sAMAccountNameAttribute)
-
-	if len(sAMAccountNameAttributeValues) != 1 {
-		return "", fmt.Errorf(`found %d values for attribute %q, but expected 1 result`,
-			len(sAMAccountNameAttributeValues), sAMAccountNameAttribute,
-		)
-	}
-
-	sAMAccountName := sAMAccountNameAttributeValues[0]
-	if len(sAMAccountName) == 0 {
-		return "", fmt.Errorf(`found empty value for attribute %q, but expected value to be non-empty`,
-			sAMAccountNameAttribute,
-		)
-	}
-
-	distinguishedName := entry.DN
-	domain, err := getDomainFromDistinguishedName(distinguishedName)
-	if err != nil {
-		return "", err
-	}
-	return sAMAccountName + "@" + domain, nil
-}
-
-var domainComponentsRegexp = regexp.MustCompile(",DC=|,dc=")
-
-func getDomainFromDistinguishedName(distinguishedName string) (string, error) {
-	domainComponents := domainComponentsRegexp.Split(distinguishedName, -1)
-	if len(domainComponents) == 1 {
-		return "", fmt.Errorf("did not find domain components in group dn: %s", distinguishedName)
-	}
-	return strings.Join(domainComponents[1:], "."), nil
-}
-
-func validUserAccountControl(entry *ldap.Entry, _ provider.StoredRefreshAttributes) error {
-	userAccountControl, err := strconv.Atoi(entry.GetAttributeValue(userAccountControlAttribute))
-	if err != nil {
-		return err
-	}
-
-	deactivated := userAccountControl & accountDisabledBitmapValue // bitwise and.
-	if deactivated != 0 {
-		return fmt.Errorf("user has been deactivated")
-	}
-	return nil
-}
-
-func validComputedUserAccountControl(entry *ldap.Entry, _ provider.StoredRefreshAttributes) error {
-	userAccountControl, err := strconv.Atoi(entry.GetAttributeValue(userAccountControlComputedAttribute))
-	if err != nil {
-		return err
-	}
-
-	locked := userAccountControl & accountLockedBitmapValue // bitwise and
-	if locked != 0 {
-		return fmt.Errorf("user has been locked")
-	}
-	return nil
-}
@@ -15,6 +15,8 @@ import (
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/golang/mock/gomock"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/keytab"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -55,6 +57,26 @@ func TestActiveDirectoryUpstreamWatcherControllerFilterSecrets(t *testing.T) {
 			wantUpdate: true,
 			wantDelete: true,
 		},
+		{
+			name: "a secret of the right type for client certificate binds",
+			secret: &corev1.Secret{
+				Type:       corev1.SecretTypeTLS,
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+			wantAdd:    true,
+			wantUpdate: true,
+			wantDelete: true,
+		},
+		{
+			name: "a secret of the right type for kerberos keytabs",
+			secret: &corev1.Secret{
+				Type:       "secrets.pinniped.dev/kerberos-keytab",
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+			wantAdd:    true,
+			wantUpdate: true,
+			wantDelete: true,
+		},
 		{
 			name: "a secret of the wrong type",
 			secret: &corev1.Secret{
@@ -163,6 +185,7 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 		testUsernameAttrName  = "test-username-attr"
 		testGroupNameAttrName = "test-group-name-attr"
 		testUIDAttrName       = "test-uid-attr"
+		testKeytabSecretName  = "test-keytab-secret"
 	)
 
 	testValidSecretData := map[string][]byte{"username": []byte(testBindUsername), "password": []byte(testBindPassword)}
@@ -221,12 +244,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 			Filter:             testGroupSearchFilter,
 			GroupNameAttribute: testGroupNameAttrName,
 		},
-		UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+		UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 		RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 			"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-			"userAccountControl":                 validUserAccountControl,
-			"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+			"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+			"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 		},
+		PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 	}
 
 	// Make a copy with targeted changes.
@@ -330,6 +354,34 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 		}
 	}
 
+	testKeytab := keytab.New()
+	require.NoError(t, testKeytab.AddEntry("HTTP/supervisor.example.com", "EXAMPLE.COM", "some-password", time.Now(), 1, etypeID.AES256_CTS_HMAC_SHA1_96))
+	testKeytabBytes, err := testKeytab.Marshal()
+	require.NoError(t, err)
+	// Parse the marshaled keytab again so that the timestamps have the same precision as the controller's copy.
+	testParsedKeytab := keytab.New()
+	require.NoError(t, testParsedKeytab.Unmarshal(testKeytabBytes))
+
+	validKeytabSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: testKeytabSecretName, Namespace: testNamespace},
+		Type:       "secrets.pinniped.dev/kerberos-keytab",
+		Data:       map[string][]byte{"keytab": testKeytabBytes},
+	}
+
+	upstreamWithKerberos := editedValidUpstream(func(upstream *v1alpha1.ActiveDirectoryIdentityProvider) {
+		upstream.Spec.Kerberos = &v1alpha1.ActiveDirectoryIdentityProviderKerberos{
+			KeytabSecretName:     testKeytabSecretName,
+			ServicePrincipalName: "HTTP/supervisor.example.com",
+		}
+	})
+
+	copyOfProviderConfigForValidUpstreamWithKerberos := *providerConfigForValidUpstreamWithTLS
+	providerConfigForValidUpstreamWithKerberos := &copyOfProviderConfigForValidUpstreamWithKerberos
+	providerConfigForValidUpstreamWithKerberos.Kerberos = upstreamldap.KerberosConfig{
+		Keytab:               testParsedKeytab,
+		ServicePrincipalName: "HTTP/supervisor.example.com",
+	}
+
 	expectedDefaultNamingContextSearch := func() *ldap.SearchRequest {
 		request := &ldap.SearchRequest{
 			BaseDN:       "",
@@ -563,12 +615,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             testGroupSearchFilter,
 						GroupNameAttribute: testGroupNameAttrName,
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
@@ -632,12 +685,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             testGroupSearchFilter,
 						GroupNameAttribute: "sAMAccountName",
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
@@ -704,12 +758,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             testGroupSearchFilter,
 						GroupNameAttribute: testGroupNameAttrName,
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
@@ -783,12 +838,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             testGroupSearchFilter,
 						GroupNameAttribute: testGroupNameAttrName,
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantErr: controllerlib.ErrSyntheticRequeue.Error(),
@@ -846,12 +902,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             testGroupSearchFilter,
 						GroupNameAttribute: testGroupNameAttrName,
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
@@ -996,12 +1053,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             testGroupSearchFilter,
 						GroupNameAttribute: testGroupNameAttrName,
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
@@ -1145,12 +1203,14 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             testGroupSearchFilter,
 						GroupNameAttribute: testGroupNameAttrName,
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
-					}},
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
+					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
+				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
 				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testResourceUID, Generation: 1234},
@@ -1216,12 +1276,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             testGroupSearchFilter,
 						GroupNameAttribute: testGroupNameAttrName,
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
@@ -1481,13 +1542,14 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             "(&(objectClass=group)(member:1.2.840.113556.1.4.1941:={}))",
 						GroupNameAttribute: "sAMAccountName",
 					},
-					UIDAttributeParsingOverrides:   map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
-					GroupAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"sAMAccountName": groupSAMAccountNameWithDomainSuffix},
+					UIDAttributeParsingOverrides:   map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
+					GroupAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"sAMAccountName": upstreamldap.GroupSAMAccountNameWithDomainSuffix},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
@@ -1541,12 +1603,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             testGroupSearchFilter,
 						GroupNameAttribute: testGroupNameAttrName,
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
@@ -1604,12 +1667,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             testGroupSearchFilter,
 						GroupNameAttribute: testGroupNameAttrName,
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
@@ -1667,12 +1731,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             testGroupSearchFilter,
 						GroupNameAttribute: testGroupNameAttrName,
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
@@ -1878,12 +1943,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						Filter:             testGroupSearchFilter,
 						GroupNameAttribute: testGroupNameAttrName,
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
@@ -1940,12 +2006,13 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 						GroupNameAttribute: testGroupNameAttrName,
 						SkipGroupRefresh:   true,
 					},
-					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": microsoftUUIDFromBinaryAttr("objectGUID")},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
 					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
 						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
-						"userAccountControl":                 validUserAccountControl,
-						"msDS-User-Account-Control-Computed": validComputedUserAccountControl,
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
 					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
 				},
 			},
 			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
@@ -1977,6 +2044,252 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 				SearchBaseFoundCondition:  condPtr(withoutTime(searchBaseFoundInConfigCondition(0))),
 			}},
 		},
+		{
+			name: "group search cache is configured",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.ActiveDirectoryIdentityProvider) {
+				upstream.Spec.GroupSearch.Cache = &v1alpha1.GroupSearchCacheSpec{TTLSeconds: 120, MaxSize: 200}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{
+				{
+					Name:               testName,
+					ResourceUID:        testResourceUID,
+					Host:               testHost,
+					ConnectionProtocol: upstreamldap.TLS,
+					CABundle:           testCABundle,
+					BindUsername:       testBindUsername,
+					BindPassword:       testBindPassword,
+					UserSearch: upstreamldap.UserSearchConfig{
+						Base:              testUserSearchBase,
+						Filter:            testUserSearchFilter,
+						UsernameAttribute: testUsernameAttrName,
+						UIDAttribute:      testUIDAttrName,
+					},
+					GroupSearch: upstreamldap.GroupSearchConfig{
+						Base:               testGroupSearchBase,
+						Filter:             testGroupSearchFilter,
+						GroupNameAttribute: testGroupNameAttrName,
+						Cache:              upstreamldap.GroupCacheConfig{TTL: 2 * time.Minute, MaxSize: 200},
+					},
+					UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID")},
+					RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
+						"pwdLastSet":                         upstreamldap.AttributeUnchangedSinceLogin("pwdLastSet"),
+						"userAccountControl":                 upstreamldap.ValidUserAccountControl,
+						"msDS-User-Account-Control-Computed": upstreamldap.ValidComputedUserAccountControl,
+					},
+					PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
+				},
+			},
+			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.ActiveDirectoryIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						activeDirectoryConnectionValidTrueCondition(1234, "4242"),
+						searchBaseFoundInConfigCondition(1234),
+						{
+							Type:               "TLSConfigurationValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "loaded TLS configuration",
+							ObservedGeneration: 1234,
+						},
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(activeDirectoryConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+				SearchBaseFoundCondition:  condPtr(withoutTime(searchBaseFoundInConfigCondition(0))),
+			}},
+		},
+		{
+			name: "password expiration checks are configured",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.ActiveDirectoryIdentityProvider) {
+				upstream.Spec.AccountStatus = v1alpha1.ActiveDirectoryIdentityProviderAccountStatus{
+					CheckPasswordExpiration:       true,
+					PasswordExpirationWarningDays: 7,
+				}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{func() *upstreamldap.ProviderConfig {
+				config := *providerConfigForValidUpstreamWithTLS
+				config.AccountStatus = upstreamldap.AccountStatusConfig{
+					PasswordExpirationAttribute:              "msDS-UserPasswordExpiryTimeComputed",
+					PasswordExpirationAttributeIsConstructed: true,
+					PasswordExpirationWarningPeriod:          7 * 24 * time.Hour,
+					TimestampFormat:                          upstreamldap.WindowsFileTime,
+				}
+				return &config
+			}()},
+			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.ActiveDirectoryIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						activeDirectoryConnectionValidTrueCondition(1234, "4242"),
+						searchBaseFoundInConfigCondition(1234),
+						tlsConfigurationValidLoadedTrueCondition(1234),
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(activeDirectoryConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+				SearchBaseFoundCondition:  condPtr(withoutTime(searchBaseFoundInConfigCondition(0))),
+			}},
+		},
+		{
+			name:           "kerberos keytab is loaded from its secret",
+			inputUpstreams: []runtime.Object{upstreamWithKerberos},
+			inputSecrets:   []runtime.Object{validBindUserSecret("4242"), validKeytabSecret},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{providerConfigForValidUpstreamWithKerberos},
+			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.ActiveDirectoryIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						{
+							Type:               "KerberosKeytabValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "loaded kerberos keytab",
+							ObservedGeneration: 1234,
+						},
+						activeDirectoryConnectionValidTrueCondition(1234, "4242"),
+						searchBaseFoundInConfigCondition(1234),
+						tlsConfigurationValidLoadedTrueCondition(1234),
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(activeDirectoryConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+				SearchBaseFoundCondition:  condPtr(withoutTime(searchBaseFoundInConfigCondition(0))),
+			}},
+		},
+		{
+			name:           "kerberos keytab secret does not contain a valid keytab, which is not fatal",
+			inputUpstreams: []runtime.Object{upstreamWithKerberos},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242"), &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: testKeytabSecretName, Namespace: testNamespace},
+				Type:       "secrets.pinniped.dev/kerberos-keytab",
+				Data:       map[string][]byte{"keytab": []byte("not a keytab")},
+			}},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamldap.ProviderConfig{providerConfigForValidUpstreamWithTLS},
+			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.ActiveDirectoryIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						{
+							Type:               "KerberosKeytabValid",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "SecretInvalidKeytab",
+							Message:            `referenced Secret "test-keytab-secret" does not contain a valid keytab: invalid keytab data. First byte does not equal 5`,
+							ObservedGeneration: 1234,
+						},
+						activeDirectoryConnectionValidTrueCondition(1234, "4242"),
+						searchBaseFoundInConfigCondition(1234),
+						tlsConfigurationValidLoadedTrueCondition(1234),
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(activeDirectoryConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+				SearchBaseFoundCondition:  condPtr(withoutTime(searchBaseFoundInConfigCondition(0))),
+			}},
+		},
+		{
+			name:           "kerberos keytab secret has the wrong type, which is not fatal",
+			inputUpstreams: []runtime.Object{upstreamWithKerberos},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242"), &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: testKeytabSecretName, Namespace: testNamespace},
+				Type:       corev1.SecretTypeOpaque,
+				Data:       map[string][]byte{"keytab": testKeytabBytes},
+			}},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamldap.ProviderConfig{providerConfigForValidUpstreamWithTLS},
+			wantResultingUpstreams: []v1alpha1.ActiveDirectoryIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.ActiveDirectoryIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						{
+							Type:               "KerberosKeytabValid",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "SecretWrongType",
+							Message:            `referenced Secret "test-keytab-secret" has wrong type "Opaque" (should be "secrets.pinniped.dev/kerberos-keytab")`,
+							ObservedGeneration: 1234,
+						},
+						activeDirectoryConnectionValidTrueCondition(1234, "4242"),
+						searchBaseFoundInConfigCondition(1234),
+						tlsConfigurationValidLoadedTrueCondition(1234),
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(activeDirectoryConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+				SearchBaseFoundCondition:  condPtr(withoutTime(searchBaseFoundInConfigCondition(0))),
+			}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -2055,6 +2368,11 @@ func TestActiveDirectoryUpstreamWatcherControllerSync(t *testing.T) {
 				// The dialer that was passed in to the controller's constructor should always have been
 				// passed through to the provider.
 				copyOfExpectedValueForResultingCache.Dialer = dialer
+				// Each provider should get the connection pool which belongs to its upstream.
+				require.NotNil(t, actualIDP.GetConfig().ConnectionPool)
+				copyOfExpectedValueForResultingCache.ConnectionPool = actualIDP.GetConfig().ConnectionPool
+				require.NotNil(t, actualIDP.GetConfig().GroupCache)
+				copyOfExpectedValueForResultingCache.GroupCache = actualIDP.GetConfig().GroupCache
 
 				// function equality is awkward. Do the check for equality separately from the rest of the config.
 				expectedUIDAttributeParsingOverrides := copyOfExpectedValueForResultingCache.UIDAttributeParsingOverrides
@@ -2138,270 +2456,3 @@ func normalizeActiveDirectoryUpstreams(upstreams []v1alpha1.ActiveDirectoryIdent
 
 	return result
 }
-
-func TestGroupSAMAccountNameWithDomainSuffix(t *testing.T) {
-	tests := []struct {
-		name       string
-		entry      *ldap.Entry
-		wantResult string
-		wantErr    string
-	}{
-		{
-			name: "happy path with DN and valid sAMAccountName",
-			entry: &ldap.Entry{
-				DN: "CN=animals,OU=Users,OU=pinniped-ad,DC=mycompany,DC=example,DC=com",
-				Attributes: []*ldap.EntryAttribute{
-					ldap.NewEntryAttribute("sAMAccountName", []string{"Mammals"}),
-				},
-			},
-			wantResult: "Mammals@mycompany.example.com",
-		},
-		{
-			name: "no domain components in DN",
-			entry: &ldap.Entry{
-				DN: "no-domain-components",
-				Attributes: []*ldap.EntryAttribute{
-					ldap.NewEntryAttribute("sAMAccountName", []string{"Mammals"}),
-				},
-			},
-			wantErr: "did not find domain components in group dn: no-domain-components",
-		},
-		{
-			name: "multiple values for sAMAccountName attribute",
-			entry: &ldap.Entry{
-				DN: "CN=animals,OU=Users,OU=pinniped-ad,DC=mycompany,DC=example,DC=com",
-				Attributes: []*ldap.EntryAttribute{
-					ldap.NewEntryAttribute("sAMAccountName", []string{"Mammals", "Eukaryotes"}),
-				},
-			},
-			wantErr: "found 2 values for attribute \"sAMAccountName\", but expected 1 result",
-		},
-		{
-			name: "no values for sAMAccountName attribute",
-			entry: &ldap.Entry{
-				DN: "CN=animals,OU=Users,OU=pinniped-ad,DC=mycompany,DC=example,DC=com",
-				Attributes: []*ldap.EntryAttribute{
-					ldap.NewEntryAttribute("sAMAccountName", []string{}),
-				},
-			},
-			wantErr: "found 0 values for attribute \"sAMAccountName\", but expected 1 result",
-		},
-	}
-	for _, test := range tests {
-		tt := test
-		t.Run(tt.name, func(t *testing.T) {
-			suffixedSAMAccountName, err := groupSAMAccountNameWithDomainSuffix(tt.entry)
-			if tt.wantErr != "" {
-				require.EqualError(t, err, tt.wantErr)
-			} else {
-				require.NoError(t, err)
-			}
-			require.Equal(t, tt.wantResult, suffixedSAMAccountName)
-		})
-	}
-}
-
-func TestGetMicrosoftFormattedUUID(t *testing.T) {
-	tests := []struct {
-		name       string
-		binaryUUID []byte
-		wantString string
-		wantErr    string
-	}{
-		{
-			name:       "happy path",
-			binaryUUID: []byte("\x01\x02\x03\x04\x05\x06\x07\x08\x09\x10\x11\x12\x13\x14\x15\x16"),
-			wantString: "04030201-0605-0807-0910-111213141516",
-		},
-		{
-			name:       "not the right length",
-			binaryUUID: []byte("2\xf8\xb0\xaa\xb6V\xb1D\x8b(\xee"),
-			wantErr:    "invalid UUID (got 11 bytes)",
-		},
-	}
-
-	for _, test := range tests {
-		tt := test
-		t.Run(tt.name, func(t *testing.T) {
-			actualUUIDString, err := microsoftUUIDFromBinary(tt.binaryUUID)
-			if tt.wantErr != "" {
-				require.EqualError(t, err, tt.wantErr)
-			} else {
-				require.NoError(t, err)
-			}
-			require.Equal(t, tt.wantString, actualUUIDString)
-		})
-	}
-}
-
-func TestGetDomainFromDistinguishedName(t *testing.T) {
-	tests := []struct {
-		name              string
-		distinguishedName string
-		wantDomain        string
-		wantErr           string
-	}{
-		{
-			name:              "happy path",
-			distinguishedName: "CN=Mammals,OU=Users,OU=pinniped-ad,DC=activedirectory,DC=mycompany,DC=example,DC=com",
-			wantDomain:        "activedirectory.mycompany.example.com",
-		},
-		{
-			name:              "lowercased happy path",
-			distinguishedName: "cn=Mammals,ou=Users,ou=pinniped-ad,dc=activedirectory,dc=mycompany,dc=example,dc=com",
-			wantDomain:        "activedirectory.mycompany.example.com",
-		},
-		{
-			name:              "no domain components",
-			distinguishedName: "not-a-dn",
-			wantErr:           "did not find domain components in group dn: not-a-dn",
-		},
-	}
-
-	for _, test := range tests {
-		tt := test
-		t.Run(tt.name, func(t *testing.T) {
-			actualDomain, err := getDomainFromDistinguishedName(tt.distinguishedName)
-			if tt.wantErr != "" {
-				require.EqualError(t, err, tt.wantErr)
-			} else {
-				require.NoError(t, err)
-			}
-			require.Equal(t, tt.wantDomain, actualDomain)
-		})
-	}
-}
-
-func TestValidUserAccountControl(t *testing.T) {
-	tests := []struct {
-		name    string
-		entry   *ldap.Entry
-		wantErr string
-	}{
-		{
-			name: "happy normal user",
-			entry: &ldap.Entry{
-				DN: "some-dn",
-				Attributes: []*ldap.EntryAttribute{
-					{
-						Name:   "userAccountControl",
-						Values: []string{"512"},
-					},
-				},
-			},
-		},
-		{
-			name: "happy user whose password doesn't expire",
-			entry: &ldap.Entry{
-				DN: "some-dn",
-				Attributes: []*ldap.EntryAttribute{
-					{
-						Name:   "userAccountControl",
-						Values: []string{"65536"},
-					},
-				},
-			},
-		},
-		{
-			name: "deactivated user",
-			entry: &ldap.Entry{
-				DN: "some-dn",
-				Attributes: []*ldap.EntryAttribute{
-					{
-						Name:   "userAccountControl",
-						Values: []string{"514"},
-					},
-				},
-			},
-			wantErr: "user has been deactivated",
-		},
-		{
-			name: "non-integer result",
-			entry: &ldap.Entry{
-				DN: "some-dn",
-				Attributes: []*ldap.EntryAttribute{
-					{
-						Name:   "userAccountControl",
-						Values: []string{"not-an-int"},
-					},
-				},
-			},
-			wantErr: "strconv.Atoi: parsing \"not-an-int\": invalid syntax",
-		},
-	}
-
-	for _, test := range tests {
-		tt := test
-		t.Run(tt.name, func(t *testing.T) {
-			err := validUserAccountControl(tt.entry, provider.StoredRefreshAttributes{})
-
-			if tt.wantErr != "" {
-				require.Error(t, err)
-				require.Equal(t, tt.wantErr, err.Error())
-			} else {
-				require.NoError(t, err)
-			}
-		})
-	}
-}
-
-func TestValidComputedUserAccountControl(t *testing.T) {
-	tests := []struct {
-		name    string
-		entry   *ldap.Entry
-		wantErr string
-	}{
-		{
-			name: "happy normal user",
-			entry: &ldap.Entry{
-				DN: "some-dn",
-				Attributes: []*ldap.EntryAttribute{
-					{
-						Name:   "msDS-User-Account-Control-Computed",
-						Values: []string{"0"},
-					},
-				},
-			},
-		},
-		{
-			name: "locked user",
-			entry: &ldap.Entry{
-				DN: "some-dn",
-				Attributes: []*ldap.EntryAttribute{
-					{
-						Name:   "msDS-User-Account-Control-Computed",
-						Values: []string{"16"},
-					},
-				},
-			},
-			wantErr: "user has been locked",
-		},
-		{
-			name: "non-integer result",
-			entry: &ldap.Entry{
-				DN: "some-dn",
-				Attributes: []*ldap.EntryAttribute{
-					{
-						Name:   "msDS-User-Account-Control-Computed",
-						Values: []string{"not-an-int"},
-					},
-				},
-			},
-			wantErr: "strconv.Atoi: parsing \"not-an-int\": invalid syntax",
-		},
-	}
-
-	for _, test := range tests {
-		tt := test
-		t.Run(tt.name, func(t *testing.T) {
-			err := validComputedUserAccountControl(tt.entry, provider.StoredRefreshAttributes{})
-
-			if tt.wantErr != "" {
-				require.Error(t, err)
-				require.Equal(t, tt.wantErr, err.Error())
-			} else {
-				require.NoError(t, err)
-			}
-		})
-	}
-}
@@ -8,10 +8,14 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/errors"
+	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/clock"
@@ -21,6 +25,8 @@ import (
 	configinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions/config/v1alpha1"
 	pinnipedcontroller "go.pinniped.dev/internal/controller"
 	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/clientregistry"
 	"go.pinniped.dev/internal/oidc/provider"
 	"go.pinniped.dev/internal/plog"
 )
@@ -32,20 +38,31 @@ type ProvidersSetter interface {
 	SetProviders(federationDomains ...*provider.FederationDomainIssuer)
 }
 
+const (
+	// introspectionClientSecretType is the type of the Secrets which hold the credentials of introspection clients.
+	// It is the same type as the Secrets which hold the credentials of upstream OIDC clients.
+	introspectionClientSecretType corev1.SecretType = "secrets.pinniped.dev/oidc-client"
+	clientIDDataKey                                 = "clientID"
+	clientSecretDataKey                             = "clientSecret"
+)
+
 type federationDomainWatcherController struct {
 	providerSetter           ProvidersSetter
 	clock                    clock.Clock
 	client                   pinnipedclientset.Interface
 	federationDomainInformer configinformers.FederationDomainInformer
+	secretInformer           corev1informers.SecretInformer
 }
 
 // NewFederationDomainWatcherController creates a controllerlib.Controller that watches
 // FederationDomain objects and notifies a callback object of the collection of provider configs.
+// It also watches the Secrets which hold the credentials of the introspection clients of the FederationDomains.
 func NewFederationDomainWatcherController(
 	providerSetter ProvidersSetter,
 	clock clock.Clock,
 	client pinnipedclientset.Interface,
 	federationDomainInformer configinformers.FederationDomainInformer,
+	secretInformer corev1informers.SecretInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
 ) controllerlib.Controller {
 	return controllerlib.New(
@@ -56,6 +73,7 @@ func NewFederationDomainWatcherController(
 				clock:                    clock,
 				client:                   client,
 				federationDomainInformer: federationDomainInformer,
+				secretInformer:           secretInformer,
 			},
 		},
 		withInformer(
@@ -63,6 +81,11 @@ func NewFederationDomainWatcherController(
 			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
 			controllerlib.InformerOption{},
 		),
+		withInformer(
+			secretInformer,
+			pinnipedcontroller.MatchAnySecretOfTypeFilter(introspectionClientSecretType, pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
 	)
 }
 
@@ -143,7 +166,35 @@ func (c *federationDomainWatcherController) Sync(ctx controllerlib.Context) erro
 			continue
 		}
 
-		federationDomainIssuer, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer) // This validates the Issuer URL.
+		var federationDomainIssuer *provider.FederationDomainIssuer
+		tokenExchangePolicy, err := tokenExchangePolicyFromSpec(federationDomain.Spec.TokenExchange)
+		var tokenLifetimes *provider.TokenLifetimes
+		if err == nil {
+			tokenLifetimes, err = tokenLifetimesFromSpec(federationDomain.Spec.Tokens)
+		}
+		if err == nil {
+			err = validateSigningSpec(federationDomain.Spec.Signing)
+		}
+		// Invalid introspection clients are not fatal, since the introspection endpoint denies every request without
+		// them, while the other endpoints of the FederationDomain still work.
+		introspectionClients, introspectionErr := c.introspectionClientsFromSpec(federationDomain.Namespace, federationDomain.Spec.Introspection)
+		var logoutSettings *provider.LogoutSettings
+		if err == nil {
+			logoutSettings, err = logoutSettingsFromSpec(federationDomain.Spec.Logout)
+		}
+		var requestObjectVerifier *provider.RequestObjectVerifier
+		if err == nil {
+			requestObjectVerifier, err = requestObjectVerifierFromSpec(ctx.Context, federationDomain.Spec.RequestObjects)
+		}
+		if err == nil {
+			federationDomainIssuer, err = provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{ // This validates the Issuer URL.
+				TokenExchangePolicy:  tokenExchangePolicy,
+				TokenLifetimes:       tokenLifetimes,
+				IntrospectionClients: introspectionClients,
+				LogoutSettings:       logoutSettings,
+				RequestObjects:       requestObjectVerifier,
+			})
+		}
 		if err != nil {
 			if err := c.updateStatus(
 				ctx.Context,
@@ -157,12 +208,17 @@ func (c *federationDomainWatcherController) Sync(ctx controllerlib.Context) erro
 			continue
 		}
 
+		status, message := configv1alpha1.SuccessFederationDomainStatusCondition, "Provider successfully created"
+		if introspectionErr != nil {
+			status = configv1alpha1.IntrospectionInvalidFederationDomainStatusCondition
+			message = "Provider successfully created, but the introspection endpoint denies every request: " + introspectionErr.Error()
+		}
 		if err := c.updateStatus(
 			ctx.Context,
 			federationDomain.Namespace,
 			federationDomain.Name,
-			configv1alpha1.SuccessFederationDomainStatusCondition,
-			"Provider successfully created",
+			status,
+			message,
 		); err != nil {
 			errs = append(errs, fmt.Errorf("could not update status: %w", err))
 			continue
@@ -176,6 +232,123 @@ func (c *federationDomainWatcherController) Sync(ctx controllerlib.Context) erro
 	return errors.NewAggregate(errs)
 }
 
+// tokenExchangePolicyFromSpec returns nil, which allows any audience, when the spec is nil.
+func tokenExchangePolicyFromSpec(spec *configv1alpha1.FederationDomainTokenExchangeSpec) (*provider.TokenExchangePolicy, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	allowedAudiences := make([]provider.AllowedAudience, 0, len(spec.AllowedAudiences))
+	for _, allowedAudience := range spec.AllowedAudiences {
+		allowedAudiences = append(allowedAudiences, provider.AllowedAudience{
+			Name:   allowedAudience.Name,
+			Groups: allowedAudience.Groups,
+		})
+	}
+	return provider.NewTokenExchangePolicy(allowedAudiences)
+}
+
+// tokenLifetimesFromSpec returns nil, which uses the default lifetimes, when the spec is nil.
+func tokenLifetimesFromSpec(spec *configv1alpha1.FederationDomainTokensSpec) (*provider.TokenLifetimes, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	tokenLifetimes := &provider.TokenLifetimes{
+		AccessToken:       time.Duration(spec.AccessTokenLifetimeSeconds) * time.Second,
+		IDToken:           time.Duration(spec.IDTokenLifetimeSeconds) * time.Second,
+		RefreshToken:      time.Duration(spec.RefreshTokenLifetimeSeconds) * time.Second,
+		AuthorizationCode: time.Duration(spec.AuthorizationCodeLifetimeSeconds) * time.Second,
+		MaxSession:        time.Duration(spec.MaxSessionLifetimeSeconds) * time.Second,
+	}
+	if err := oidc.OIDCTimeoutsConfiguration(tokenLifetimes).Validate(); err != nil {
+		return nil, fmt.Errorf("invalid token lifetimes: %w", err)
+	}
+	return tokenLifetimes, nil
+}
+
+// introspectionClientsFromSpec returns nil, which denies every request to the introspection endpoint, when the spec
+// is nil. The credentials of the clients are read from the Secrets which are referenced by the spec.
+func (c *federationDomainWatcherController) introspectionClientsFromSpec(
+	namespace string,
+	spec *configv1alpha1.FederationDomainIntrospectionSpec,
+) (*provider.IntrospectionClients, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	clients := make([]provider.IntrospectionClient, 0, len(spec.Clients))
+	for _, client := range spec.Clients {
+		secret, err := c.secretInformer.Lister().Secrets(namespace).Get(client.SecretName)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				return nil, fmt.Errorf("invalid introspection: secret %q not found", client.SecretName)
+			}
+			return nil, fmt.Errorf("invalid introspection: failed to get secret %q: %w", client.SecretName, err)
+		}
+		if secret.Type != introspectionClientSecretType {
+			return nil, fmt.Errorf("invalid introspection: secret %q has wrong type %q (should be %q)", client.SecretName, secret.Type, introspectionClientSecretType)
+		}
+		clientID := secret.Data[clientIDDataKey]
+		clientSecret := secret.Data[clientSecretDataKey]
+		if len(clientID) == 0 || len(clientSecret) == 0 {
+			return nil, fmt.Errorf("invalid introspection: secret %q is missing required keys %q", client.SecretName, []string{clientIDDataKey, clientSecretDataKey})
+		}
+		clients = append(clients, provider.IntrospectionClient{ID: string(clientID), Secret: string(clientSecret)})
+	}
+	introspectionClients, err := provider.NewIntrospectionClients(clients)
+	if err != nil {
+		return nil, fmt.Errorf("invalid introspection: %w", err)
+	}
+	return introspectionClients, nil
+}
+
+// logoutSettingsFromSpec returns nil, which allows only the redirect URIs of the client after a logout, when the spec
+// is nil.
+func logoutSettingsFromSpec(spec *configv1alpha1.FederationDomainLogoutSpec) (*provider.LogoutSettings, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	backChannelLogoutClients := make([]provider.BackChannelLogoutClient, 0, len(spec.BackChannelLogoutClients))
+	for _, client := range spec.BackChannelLogoutClients {
+		backChannelLogoutClients = append(backChannelLogoutClients, provider.BackChannelLogoutClient{
+			ID:  client.ClientID,
+			URI: client.URI,
+		})
+	}
+	logoutSettings, err := provider.NewLogoutSettings(
+		spec.PostLogoutRedirectURIs,
+		spec.FrontChannelLogoutURIs,
+		backChannelLogoutClients,
+		spec.UpstreamLogout,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid logout: %w", err)
+	}
+	return logoutSettings, nil
+}
+
+// requestObjectVerifierFromSpec returns nil, which rejects every signed request object, when the spec is nil.
+func requestObjectVerifierFromSpec(
+	ctx context.Context,
+	spec *configv1alpha1.FederationDomainRequestObjectsSpec,
+) (*provider.RequestObjectVerifier, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	clients := make([]provider.RequestObjectClient, 0, len(spec.Clients))
+	for i, client := range spec.Clients {
+		// The authorization requests are still handled by fosite after the request object was verified, so they
+		// would be rejected for any client which is not one of the statically defined clients of the Supervisor.
+		if _, err := (clientregistry.StaticClientManager{}).GetClient(ctx, client.ClientID); err != nil {
+			return nil, fmt.Errorf("invalid requestObjects: clients[%d] client %q is not a client of the Supervisor", i, client.ClientID)
+		}
+		clients = append(clients, provider.RequestObjectClient{ID: client.ClientID, JWKS: client.JWKS})
+	}
+	verifier, err := provider.NewRequestObjectVerifier(clients)
+	if err != nil {
+		return nil, fmt.Errorf("invalid requestObjects: %w", err)
+	}
+	return verifier, nil
+}
+
 func (c *federationDomainWatcherController) updateStatus(
 	ctx context.Context,
 	namespace, name string,
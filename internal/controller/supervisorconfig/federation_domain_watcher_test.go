@@ -16,10 +16,13 @@ import (
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubeinformers "k8s.io/client-go/informers"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
 	coretesting "k8s.io/client-go/testing"
 	clocktesting "k8s.io/utils/clock/testing"
 
@@ -37,19 +40,23 @@ func TestInformerFilters(t *testing.T) {
 		var r *require.Assertions
 		var observableWithInformerOption *testutil.ObservableWithInformerOption
 		var configMapInformerFilter controllerlib.Filter
+		var secretInformerFilter controllerlib.Filter
 
 		it.Before(func() {
 			r = require.New(t)
 			observableWithInformerOption = testutil.NewObservableWithInformerOption()
 			federationDomainInformer := pinnipedinformers.NewSharedInformerFactoryWithOptions(nil, 0).Config().V1alpha1().FederationDomains()
+			secretInformer := kubeinformers.NewSharedInformerFactoryWithOptions(nil, 0).Core().V1().Secrets()
 			_ = NewFederationDomainWatcherController(
 				nil,
 				nil,
 				nil,
 				federationDomainInformer,
+				secretInformer,
 				observableWithInformerOption.WithInformer, // make it possible to observe the behavior of the Filters
 			)
 			configMapInformerFilter = observableWithInformerOption.GetFilterForInformer(federationDomainInformer)
+			secretInformerFilter = observableWithInformerOption.GetFilterForInformer(secretInformer)
 		})
 
 		when("watching FederationDomain objects", func() {
@@ -80,6 +87,39 @@ func TestInformerFilters(t *testing.T) {
 				})
 			})
 		})
+
+		when("watching Secret objects", func() {
+			var subject controllerlib.Filter
+			var clientSecret, otherTypeSecret *corev1.Secret
+
+			it.Before(func() {
+				subject = secretInformerFilter
+				clientSecret = &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+					Type:       "secrets.pinniped.dev/oidc-client",
+				}
+				otherTypeSecret = &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "other-name", Namespace: "some-namespace"},
+					Type:       corev1.SecretTypeOpaque,
+				}
+			})
+
+			when("a Secret of the OIDC client type changes", func() {
+				it("returns true to trigger the sync method", func() {
+					r.True(subject.Add(clientSecret))
+					r.True(subject.Update(clientSecret, clientSecret))
+					r.True(subject.Delete(clientSecret))
+				})
+			})
+
+			when("a Secret of any other type changes", func() {
+				it("returns false to skip the sync method", func() {
+					r.False(subject.Add(otherTypeSecret))
+					r.False(subject.Update(otherTypeSecret, otherTypeSecret))
+					r.False(subject.Delete(otherTypeSecret))
+				})
+			})
+		})
 	}, spec.Parallel(), spec.Report(report.Terminal{}))
 }
 
@@ -102,6 +142,8 @@ func TestSync(t *testing.T) {
 		var subject controllerlib.Controller
 		var federationDomainInformerClient *pinnipedfake.Clientset
 		var federationDomainInformers pinnipedinformers.SharedInformerFactory
+		var kubeInformerClient *kubernetesfake.Clientset
+		var kubeInformers kubeinformers.SharedInformerFactory
 		var pinnipedAPIClient *pinnipedfake.Clientset
 		var cancelContext context.Context
 		var cancelContextCancelFunc context.CancelFunc
@@ -119,6 +161,7 @@ func TestSync(t *testing.T) {
 				clocktesting.NewFakeClock(frozenNow),
 				pinnipedAPIClient,
 				federationDomainInformers.Config().V1alpha1().FederationDomains(),
+				kubeInformers.Core().V1().Secrets(),
 				controllerlib.WithInformer,
 			)
 
@@ -134,6 +177,7 @@ func TestSync(t *testing.T) {
 
 			// Must start informers before calling TestRunSynchronously()
 			federationDomainInformers.Start(cancelContext.Done())
+			kubeInformers.Start(cancelContext.Done())
 			controllerlib.TestRunSynchronously(t, subject)
 		}
 
@@ -147,6 +191,8 @@ func TestSync(t *testing.T) {
 
 			federationDomainInformerClient = pinnipedfake.NewSimpleClientset()
 			federationDomainInformers = pinnipedinformers.NewSharedInformerFactory(federationDomainInformerClient, 0)
+			kubeInformerClient = kubernetesfake.NewSimpleClientset()
+			kubeInformers = kubeinformers.NewSharedInformerFactory(kubeInformerClient, 0)
 			pinnipedAPIClient = pinnipedfake.NewSimpleClientset()
 
 			federationDomainGVR = schema.GroupVersionResource{
@@ -187,10 +233,10 @@ func TestSync(t *testing.T) {
 				err := controllerlib.TestSync(t, subject, *syncContext)
 				r.NoError(err)
 
-				provider1, err := provider.NewFederationDomainIssuer(federationDomain1.Spec.Issuer)
+				provider1, err := provider.NewFederationDomainIssuer(federationDomain1.Spec.Issuer, provider.FederationDomainIssuerOptions{})
 				r.NoError(err)
 
-				provider2, err := provider.NewFederationDomainIssuer(federationDomain2.Spec.Issuer)
+				provider2, err := provider.NewFederationDomainIssuer(federationDomain2.Spec.Issuer, provider.FederationDomainIssuerOptions{})
 				r.NoError(err)
 
 				r.True(providersSetter.SetProvidersWasCalled)
@@ -288,10 +334,10 @@ func TestSync(t *testing.T) {
 					err := controllerlib.TestSync(t, subject, *syncContext)
 					r.NoError(err)
 
-					provider1, err := provider.NewFederationDomainIssuer(federationDomain1.Spec.Issuer)
+					provider1, err := provider.NewFederationDomainIssuer(federationDomain1.Spec.Issuer, provider.FederationDomainIssuerOptions{})
 					r.NoError(err)
 
-					provider2, err := provider.NewFederationDomainIssuer(federationDomain2.Spec.Issuer)
+					provider2, err := provider.NewFederationDomainIssuer(federationDomain2.Spec.Issuer, provider.FederationDomainIssuerOptions{})
 					r.NoError(err)
 
 					r.True(providersSetter.SetProvidersWasCalled)
@@ -326,10 +372,10 @@ func TestSync(t *testing.T) {
 					err := controllerlib.TestSync(t, subject, *syncContext)
 					r.EqualError(err, "could not update status: some update error")
 
-					provider1, err := provider.NewFederationDomainIssuer(federationDomain1.Spec.Issuer)
+					provider1, err := provider.NewFederationDomainIssuer(federationDomain1.Spec.Issuer, provider.FederationDomainIssuerOptions{})
 					r.NoError(err)
 
-					provider2, err := provider.NewFederationDomainIssuer(federationDomain2.Spec.Issuer)
+					provider2, err := provider.NewFederationDomainIssuer(federationDomain2.Spec.Issuer, provider.FederationDomainIssuerOptions{})
 					r.NoError(err)
 
 					r.True(providersSetter.SetProvidersWasCalled)
@@ -518,6 +564,891 @@ func TestSync(t *testing.T) {
 			})
 		})
 
+		when("there is a FederationDomain with a token exchange policy in the informer", func() {
+			var federationDomain *v1alpha1.FederationDomain
+
+			it.Before(func() {
+				federationDomain = &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec: v1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer.com",
+						TokenExchange: &v1alpha1.FederationDomainTokenExchangeSpec{
+							AllowedAudiences: []v1alpha1.FederationDomainAllowedAudience{
+								{Name: "cluster-1"},
+								{Name: "cluster-2", Groups: []string{"group-1", "group-2"}},
+							},
+						},
+					},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+			})
+
+			it("calls the ProvidersSetter with a provider which has the token exchange policy", func() {
+				startInformersAndController()
+				err := controllerlib.TestSync(t, subject, *syncContext)
+				r.NoError(err)
+
+				tokenExchangePolicy, err := provider.NewTokenExchangePolicy([]provider.AllowedAudience{
+					{Name: "cluster-1"},
+					{Name: "cluster-2", Groups: []string{"group-1", "group-2"}},
+				})
+				r.NoError(err)
+				expectedProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{TokenExchangePolicy: tokenExchangePolicy})
+				r.NoError(err)
+
+				r.True(providersSetter.SetProvidersWasCalled)
+				r.Equal(
+					[]*provider.FederationDomainIssuer{
+						expectedProvider,
+					},
+					providersSetter.FederationDomainsReceived,
+				)
+			})
+
+			when("the token exchange policy lists the same audience more than once", func() {
+				it.Before(func() {
+					federationDomain.Spec.TokenExchange.AllowedAudiences[1].Name = "cluster-1"
+					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+				})
+
+				it("does not call the ProvidersSetter with the provider and updates the status to invalid", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Empty(providersSetter.FederationDomainsReceived)
+
+					federationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = `Invalid: token exchange allowed audience "cluster-1" is listed more than once`
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+		})
+
+		when("there is a FederationDomain with token lifetimes in the informer", func() {
+			var federationDomain *v1alpha1.FederationDomain
+
+			it.Before(func() {
+				federationDomain = &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec: v1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer.com",
+						Tokens: &v1alpha1.FederationDomainTokensSpec{
+							AccessTokenLifetimeSeconds:  300,
+							RefreshTokenLifetimeSeconds: 3600,
+							MaxSessionLifetimeSeconds:   28800,
+						},
+					},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+			})
+
+			it("calls the ProvidersSetter with a provider which has the token lifetimes", func() {
+				startInformersAndController()
+				err := controllerlib.TestSync(t, subject, *syncContext)
+				r.NoError(err)
+
+				expectedProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{
+					TokenLifetimes: &provider.TokenLifetimes{
+						AccessToken:  5 * time.Minute,
+						RefreshToken: time.Hour,
+						MaxSession:   8 * time.Hour,
+					},
+				})
+				r.NoError(err)
+
+				r.True(providersSetter.SetProvidersWasCalled)
+				r.Equal(
+					[]*provider.FederationDomainIssuer{
+						expectedProvider,
+					},
+					providersSetter.FederationDomainsReceived,
+				)
+			})
+
+			when("the refresh token lifetime is not longer than the access token lifetime", func() {
+				it.Before(func() {
+					federationDomain.Spec.Tokens.RefreshTokenLifetimeSeconds = 300
+					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+				})
+
+				it("does not call the ProvidersSetter with the provider and updates the status to invalid", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Empty(providersSetter.FederationDomainsReceived)
+
+					federationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = "Invalid: invalid token lifetimes: refresh token lifetime (5m0s) must be longer than the access token lifetime (5m0s)"
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+
+			when("the maximum session lifetime is shorter than the access token lifetime", func() {
+				it.Before(func() {
+					federationDomain.Spec.Tokens.AccessTokenLifetimeSeconds = 600
+					federationDomain.Spec.Tokens.MaxSessionLifetimeSeconds = 300
+					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+				})
+
+				it("does not call the ProvidersSetter with the provider and updates the status to invalid", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Empty(providersSetter.FederationDomainsReceived)
+
+					federationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = "Invalid: invalid token lifetimes: maximum session lifetime (5m0s) must not be shorter than the access token lifetime (10m0s)"
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+		})
+
+		when("there is a FederationDomain with a key rotation in the informer", func() {
+			var federationDomain *v1alpha1.FederationDomain
+
+			it.Before(func() {
+				federationDomain = &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec: v1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer.com",
+						Signing: &v1alpha1.FederationDomainSigningSpec{
+							Algorithm: v1alpha1.RS256FederationDomainSigningAlgorithm,
+							KeyRotation: &v1alpha1.FederationDomainKeyRotationSpec{
+								IntervalSeconds:    86400,
+								GracePeriodSeconds: 3600,
+							},
+						},
+					},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+			})
+
+			it("calls the ProvidersSetter with the provider", func() {
+				startInformersAndController()
+				err := controllerlib.TestSync(t, subject, *syncContext)
+				r.NoError(err)
+
+				expectedProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{})
+				r.NoError(err)
+
+				r.True(providersSetter.SetProvidersWasCalled)
+				r.Equal(
+					[]*provider.FederationDomainIssuer{
+						expectedProvider,
+					},
+					providersSetter.FederationDomainsReceived,
+				)
+			})
+
+			when("the grace period is not shorter than the interval", func() {
+				it.Before(func() {
+					federationDomain.Spec.Signing.KeyRotation.IntervalSeconds = 7200
+					federationDomain.Spec.Signing.KeyRotation.GracePeriodSeconds = 7200
+					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+				})
+
+				it("does not call the ProvidersSetter with the provider and updates the status to invalid", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Empty(providersSetter.FederationDomainsReceived)
+
+					federationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = "Invalid: invalid key rotation: grace period (2h0m0s) must be shorter than the interval (2h0m0s)"
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+		})
+
+		when("there is a FederationDomain with a user-provided signing secret in the informer", func() {
+			var federationDomain *v1alpha1.FederationDomain
+
+			it.Before(func() {
+				federationDomain = &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec: v1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer.com",
+						Signing: &v1alpha1.FederationDomainSigningSpec{
+							SecretName: "some-signing-secret",
+							ExternalSigner: &v1alpha1.FederationDomainExternalSignerSpec{
+								URL:                   "https://signer.example.com/sign",
+								CredentialsSecretName: "some-signer-credentials",
+							},
+						},
+					},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+			})
+
+			it("calls the ProvidersSetter with the provider", func() {
+				startInformersAndController()
+				err := controllerlib.TestSync(t, subject, *syncContext)
+				r.NoError(err)
+
+				expectedProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{})
+				r.NoError(err)
+
+				r.True(providersSetter.SetProvidersWasCalled)
+				r.Equal(
+					[]*provider.FederationDomainIssuer{
+						expectedProvider,
+					},
+					providersSetter.FederationDomainsReceived,
+				)
+			})
+
+			when("the external signer is used without a secret name", func() {
+				it.Before(func() {
+					federationDomain.Spec.Signing.SecretName = ""
+					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+				})
+
+				it("does not call the ProvidersSetter with the provider and updates the status to invalid", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Empty(providersSetter.FederationDomainsReceived)
+
+					federationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = "Invalid: invalid signing: externalSigner requires secretName"
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+
+			when("key rotation is used with a secret name", func() {
+				it.Before(func() {
+					federationDomain.Spec.Signing.KeyRotation = &v1alpha1.FederationDomainKeyRotationSpec{IntervalSeconds: 86400}
+					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+				})
+
+				it("does not call the ProvidersSetter with the provider and updates the status to invalid", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Empty(providersSetter.FederationDomainsReceived)
+
+					federationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = "Invalid: invalid signing: keyRotation cannot be used with secretName"
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+
+			when("the external signer has an invalid URL", func() {
+				it.Before(func() {
+					federationDomain.Spec.Signing.ExternalSigner.URL = "ftp://signer.example.com"
+					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+				})
+
+				it("does not call the ProvidersSetter with the provider and updates the status to invalid", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Empty(providersSetter.FederationDomainsReceived)
+
+					federationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = "Invalid: invalid signing: externalSigner.url is invalid: must be an https URL, or an http URL of a loopback address"
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+
+			when("the external signer has an http URL of a host which is not a loopback address", func() {
+				it.Before(func() {
+					federationDomain.Spec.Signing.ExternalSigner.URL = "http://signer.example.com/sign"
+					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+				})
+
+				it("does not call the ProvidersSetter with the provider and updates the status to invalid", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Empty(providersSetter.FederationDomainsReceived)
+
+					federationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = "Invalid: invalid signing: externalSigner.url is invalid: must be an https URL, or an http URL of a loopback address"
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+		})
+
+		when("there is a FederationDomain with introspection clients in the informer", func() {
+			var federationDomain *v1alpha1.FederationDomain
+			var clientSecret *corev1.Secret
+
+			it.Before(func() {
+				federationDomain = &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec: v1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer.com",
+						Introspection: &v1alpha1.FederationDomainIntrospectionSpec{
+							Clients: []v1alpha1.FederationDomainIntrospectionClient{{SecretName: "some-client-secret"}},
+						},
+					},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+
+				clientSecret = &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "some-client-secret", Namespace: namespace},
+					Type:       "secrets.pinniped.dev/oidc-client",
+					Data: map[string][]byte{
+						"clientID":     []byte("some-gateway"),
+						"clientSecret": []byte("some-gateway-secret"),
+					},
+				}
+			})
+
+			when("the client secret exists", func() {
+				it.Before(func() {
+					r.NoError(kubeInformerClient.Tracker().Add(clientSecret))
+				})
+
+				it("calls the ProvidersSetter with a provider which has the introspection clients", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					expectedClients, err := provider.NewIntrospectionClients([]provider.IntrospectionClient{
+						{ID: "some-gateway", Secret: "some-gateway-secret"},
+					})
+					r.NoError(err)
+					expectedProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{IntrospectionClients: expectedClients})
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Equal(
+						[]*provider.FederationDomainIssuer{
+							expectedProvider,
+						},
+						providersSetter.FederationDomainsReceived,
+					)
+					r.True(providersSetter.FederationDomainsReceived[0].IntrospectionClients().Authenticate("some-gateway", "some-gateway-secret"))
+				})
+			})
+
+			when("the client secret does not exist", func() {
+				it.Before(func() {
+					// Do not add the secret.
+				})
+
+				it("calls the ProvidersSetter with a provider which denies every introspection request and updates the status", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					expectedProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{})
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Equal(
+						[]*provider.FederationDomainIssuer{
+							expectedProvider,
+						},
+						providersSetter.FederationDomainsReceived,
+					)
+
+					federationDomain.Status.Status = v1alpha1.IntrospectionInvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = `Provider successfully created, but the introspection endpoint denies every request: invalid introspection: secret "some-client-secret" not found`
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+
+			when("the client secret has the wrong type", func() {
+				it.Before(func() {
+					clientSecret.Type = corev1.SecretTypeOpaque
+					r.NoError(kubeInformerClient.Tracker().Add(clientSecret))
+				})
+
+				it("calls the ProvidersSetter with a provider which denies every introspection request and updates the status", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					expectedProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{})
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Equal(
+						[]*provider.FederationDomainIssuer{
+							expectedProvider,
+						},
+						providersSetter.FederationDomainsReceived,
+					)
+
+					federationDomain.Status.Status = v1alpha1.IntrospectionInvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = `Provider successfully created, but the introspection endpoint denies every request: invalid introspection: secret "some-client-secret" has wrong type "Opaque" (should be "secrets.pinniped.dev/oidc-client")`
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+
+			when("the client secret is missing the client secret key", func() {
+				it.Before(func() {
+					delete(clientSecret.Data, "clientSecret")
+					r.NoError(kubeInformerClient.Tracker().Add(clientSecret))
+				})
+
+				it("calls the ProvidersSetter with a provider which denies every introspection request and updates the status", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					expectedProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{})
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Equal(
+						[]*provider.FederationDomainIssuer{
+							expectedProvider,
+						},
+						providersSetter.FederationDomainsReceived,
+					)
+
+					federationDomain.Status.Status = v1alpha1.IntrospectionInvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = `Provider successfully created, but the introspection endpoint denies every request: invalid introspection: secret "some-client-secret" is missing required keys ["clientID" "clientSecret"]`
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+
+			when("two client secrets have the same client ID", func() {
+				it.Before(func() {
+					r.NoError(kubeInformerClient.Tracker().Add(clientSecret))
+					otherClientSecret := clientSecret.DeepCopy()
+					otherClientSecret.Name = "other-client-secret"
+					r.NoError(kubeInformerClient.Tracker().Add(otherClientSecret))
+					federationDomain.Spec.Introspection.Clients = append(federationDomain.Spec.Introspection.Clients,
+						v1alpha1.FederationDomainIntrospectionClient{SecretName: "other-client-secret"})
+					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+				})
+
+				it("calls the ProvidersSetter with a provider which denies every introspection request and updates the status", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					expectedProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{})
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Equal(
+						[]*provider.FederationDomainIssuer{
+							expectedProvider,
+						},
+						providersSetter.FederationDomainsReceived,
+					)
+
+					federationDomain.Status.Status = v1alpha1.IntrospectionInvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = `Provider successfully created, but the introspection endpoint denies every request: invalid introspection: introspection client "some-gateway" is listed more than once`
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+		})
+
+		when("there is a FederationDomain with logout settings in the informer", func() {
+			var federationDomain *v1alpha1.FederationDomain
+
+			it.Before(func() {
+				federationDomain = &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec: v1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer.com",
+						Logout: &v1alpha1.FederationDomainLogoutSpec{
+							PostLogoutRedirectURIs: []string{"https://app.example.com/logged-out"},
+							BackChannelLogoutClients: []v1alpha1.FederationDomainBackChannelLogoutClient{
+								{ClientID: "some-app", URI: "https://app.example.com/backchannel-logout"},
+							},
+							UpstreamLogout: true,
+						},
+					},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+			})
+
+			it("calls the ProvidersSetter with a provider which has the logout settings", func() {
+				startInformersAndController()
+				err := controllerlib.TestSync(t, subject, *syncContext)
+				r.NoError(err)
+
+				expectedLogoutSettings, err := provider.NewLogoutSettings(
+					[]string{"https://app.example.com/logged-out"},
+					nil,
+					[]provider.BackChannelLogoutClient{{ID: "some-app", URI: "https://app.example.com/backchannel-logout"}},
+					true,
+				)
+				r.NoError(err)
+				expectedProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{LogoutSettings: expectedLogoutSettings})
+				r.NoError(err)
+
+				r.True(providersSetter.SetProvidersWasCalled)
+				r.Equal(
+					[]*provider.FederationDomainIssuer{
+						expectedProvider,
+					},
+					providersSetter.FederationDomainsReceived,
+				)
+			})
+
+			when("a logout URI is invalid", func() {
+				it.Before(func() {
+					federationDomain.Spec.Logout.BackChannelLogoutClients[0].URI = "ftp://app.example.com/backchannel-logout"
+					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+				})
+
+				it("does not call the ProvidersSetter with the provider and updates the status to invalid", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Empty(providersSetter.FederationDomainsReceived)
+
+					federationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = `Invalid: invalid logout: backChannelLogoutClients[0] URI "ftp://app.example.com/backchannel-logout" is invalid: must be an http or https URL`
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+		})
+
+		when("there is a FederationDomain with request objects in the informer", func() {
+			const requestObjectsJWKS = `{"keys":[{"kty":"EC","crv":"P-256","alg":"ES256","kid":"client-key",` +
+				`"x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM"}]}`
+
+			var federationDomain *v1alpha1.FederationDomain
+
+			it.Before(func() {
+				federationDomain = &v1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+					Spec: v1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer.com",
+						RequestObjects: &v1alpha1.FederationDomainRequestObjectsSpec{
+							Clients: []v1alpha1.FederationDomainRequestObjectClient{
+								{ClientID: "pinniped-cli", JWKS: requestObjectsJWKS},
+							},
+						},
+					},
+				}
+				r.NoError(pinnipedAPIClient.Tracker().Add(federationDomain))
+				r.NoError(federationDomainInformerClient.Tracker().Add(federationDomain))
+			})
+
+			it("calls the ProvidersSetter with a provider which verifies request objects", func() {
+				startInformersAndController()
+				err := controllerlib.TestSync(t, subject, *syncContext)
+				r.NoError(err)
+
+				expectedVerifier, err := provider.NewRequestObjectVerifier([]provider.RequestObjectClient{
+					{ID: "pinniped-cli", JWKS: requestObjectsJWKS},
+				})
+				r.NoError(err)
+				expectedProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{RequestObjects: expectedVerifier})
+				r.NoError(err)
+
+				r.True(providersSetter.SetProvidersWasCalled)
+				r.Equal(
+					[]*provider.FederationDomainIssuer{
+						expectedProvider,
+					},
+					providersSetter.FederationDomainsReceived,
+				)
+			})
+
+			when("the JWKS is invalid", func() {
+				it.Before(func() {
+					federationDomain.Spec.RequestObjects.Clients[0].JWKS = `{"keys":[]}`
+					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+				})
+
+				it("does not call the ProvidersSetter with the provider and updates the status to invalid", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Empty(providersSetter.FederationDomainsReceived)
+
+					federationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = `Invalid: invalid requestObjects: clients[0] client "pinniped-cli" has an invalid jwks: jwks must contain at least one key`
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+
+			when("the client is not a client of the Supervisor", func() {
+				it.Before(func() {
+					federationDomain.Spec.RequestObjects.Clients[0].ClientID = "some-other-app"
+					r.NoError(pinnipedAPIClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+					r.NoError(federationDomainInformerClient.Tracker().Update(federationDomainGVR, federationDomain, namespace))
+				})
+
+				it("does not call the ProvidersSetter with the provider and updates the status to invalid", func() {
+					startInformersAndController()
+					err := controllerlib.TestSync(t, subject, *syncContext)
+					r.NoError(err)
+
+					r.True(providersSetter.SetProvidersWasCalled)
+					r.Empty(providersSetter.FederationDomainsReceived)
+
+					federationDomain.Status.Status = v1alpha1.InvalidFederationDomainStatusCondition
+					federationDomain.Status.Message = `Invalid: invalid requestObjects: clients[0] client "some-other-app" is not a client of the Supervisor`
+					federationDomain.Status.LastUpdateTime = timePtr(metav1.NewTime(frozenNow))
+
+					expectedActions := []coretesting.Action{
+						coretesting.NewGetAction(
+							federationDomainGVR,
+							federationDomain.Namespace,
+							federationDomain.Name,
+						),
+						coretesting.NewUpdateSubresourceAction(
+							federationDomainGVR,
+							"status",
+							federationDomain.Namespace,
+							federationDomain,
+						),
+					}
+					r.ElementsMatch(expectedActions, pinnipedAPIClient.Actions())
+				})
+			})
+		})
+
 		when("there are both valid and invalid FederationDomains in the informer", func() {
 			var (
 				validFederationDomain   *v1alpha1.FederationDomain
@@ -545,7 +1476,7 @@ func TestSync(t *testing.T) {
 				err := controllerlib.TestSync(t, subject, *syncContext)
 				r.NoError(err)
 
-				validProvider, err := provider.NewFederationDomainIssuer(validFederationDomain.Spec.Issuer)
+				validProvider, err := provider.NewFederationDomainIssuer(validFederationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{})
 				r.NoError(err)
 
 				r.True(providersSetter.SetProvidersWasCalled)
@@ -619,7 +1550,7 @@ func TestSync(t *testing.T) {
 					err := controllerlib.TestSync(t, subject, *syncContext)
 					r.EqualError(err, "could not update status: some update error")
 
-					validProvider, err := provider.NewFederationDomainIssuer(validFederationDomain.Spec.Issuer)
+					validProvider, err := provider.NewFederationDomainIssuer(validFederationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{})
 					r.NoError(err)
 
 					r.True(providersSetter.SetProvidersWasCalled)
@@ -709,7 +1640,7 @@ func TestSync(t *testing.T) {
 				err := controllerlib.TestSync(t, subject, *syncContext)
 				r.NoError(err)
 
-				nonDuplicateProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer)
+				nonDuplicateProvider, err := provider.NewFederationDomainIssuer(federationDomain.Spec.Issuer, provider.FederationDomainIssuerOptions{})
 				r.NoError(err)
 
 				r.True(providersSetter.SetProvidersWasCalled)
@@ -882,7 +1813,7 @@ func TestSync(t *testing.T) {
 				err := controllerlib.TestSync(t, subject, *syncContext)
 				r.NoError(err)
 
-				nonDuplicateProvider, err := provider.NewFederationDomainIssuer(federationDomainDifferentIssuerAddress.Spec.Issuer)
+				nonDuplicateProvider, err := provider.NewFederationDomainIssuer(federationDomainDifferentIssuerAddress.Spec.Issuer, provider.FederationDomainIssuerOptions{})
 				r.NoError(err)
 
 				r.True(providersSetter.SetProvidersWasCalled)
@@ -1,4 +1,4 @@
-// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2022 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package supervisorconfig
@@ -8,12 +8,14 @@ import (
 	"fmt"
 
 	"gopkg.in/square/go-jose.v2"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 
 	"go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions/config/v1alpha1"
 	pinnipedcontroller "go.pinniped.dev/internal/controller"
 	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/oidc/jwks"
 	"go.pinniped.dev/internal/plog"
 )
 
@@ -52,7 +54,11 @@ func NewJWKSObserverController(
 		},
 		withInformer(
 			secretInformer,
-			pinnipedcontroller.MatchAnySecretOfTypeFilter(jwksSecretTypeValue, nil),
+			// The external signers' credentials Secrets are also watched, so that the Supervisor uses new credentials
+			// as soon as they are rotated.
+			pinnipedcontroller.MatchAnySecretOfTypesFilter(
+				[]corev1.SecretType{jwksSecretTypeValue, externalSignerTLSSecretType, externalSignerTokenSecretType}, nil,
+			),
 			controllerlib.InformerOption{},
 		),
 		withInformer(
@@ -97,7 +103,35 @@ func (c *jwksObserverController) Sync(ctx controllerlib.Context) error {
 			continue
 		}
 
-		issuerToJWKSMap[provider.Spec.Issuer] = &jwksFromSecret
+		signing := provider.Spec.Signing
+		if signing != nil && signing.SecretName != "" {
+			// The Secret may have been provided by the user, so it must be validated like the JWKS writer does.
+			if err := validateSigningSecret(jwksSecret, signing.ExternalSigner != nil); err != nil {
+				plog.Debug("jwksObserverController Sync found an invalid signing secret", "namespace", ns, "secretName", secretRef.Name, "err", err)
+				continue
+			}
+		}
+
+		// Only publish the public keys, in case the JWKS in the Secret contains private key material.
+		publicJWKS := jose.JSONWebKeySet{Keys: make([]jose.JSONWebKey, 0, len(jwksFromSecret.Keys))}
+		for _, jwk := range jwksFromSecret.Keys {
+			if publicJWK := jwk.Public(); publicJWK.Valid() {
+				publicJWKS.Keys = append(publicJWKS.Keys, publicJWK)
+			}
+		}
+
+		if signing != nil && signing.ExternalSigner != nil {
+			// The private key is held by the external signer, so the Secret only contains the public key.
+			client, err := externalSignerClient(c.secretInformer.Lister().Secrets(ns), signing.ExternalSigner)
+			if err != nil {
+				plog.Debug("jwksObserverController Sync found an invalid external signer", "namespace", ns, "federationdomain", provider.Name, "err", err)
+				continue
+			}
+			publicJWK := activeJWKFromSecret
+			activeJWKFromSecret.Key = jwks.NewHTTPSigner(client, signing.ExternalSigner.URL, &publicJWK)
+		}
+
+		issuerToJWKSMap[provider.Spec.Issuer] = &publicJWKS
 		issuerToActiveJWKMap[provider.Spec.Issuer] = &activeJWKFromSecret
 	}
 
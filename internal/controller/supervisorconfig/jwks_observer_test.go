@@ -69,6 +69,16 @@ func TestJWKSObserverControllerInformerFilters(t *testing.T) {
 				})
 			})
 
+			when("any Secret of the types of the external signer credentials changes", func() {
+				it("returns true to trigger the sync method", func() {
+					tlsSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "any-name", Namespace: "any-namespace"}, Type: "kubernetes.io/tls"}
+					tokenSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "any-name", Namespace: "any-namespace"}, Type: "secrets.pinniped.dev/external-signer-token"}
+					r.True(subject.Add(tlsSecret))
+					r.True(subject.Update(tokenSecret, otherTypeSecret))
+					r.True(subject.Delete(tokenSecret))
+				})
+			})
+
 			when("any Secret of some other type changes", func() {
 				it("returns false to skip the sync method", func() {
 					r.False(subject.Add(otherTypeSecret))
@@ -376,5 +386,147 @@ func TestJWKSObserverControllerSync(t *testing.T) {
 				requireJWKJSON(expectedJWK2, issuerToJWKSSetter.issuerToActiveJWKMapReceived["https://issuer-with-good-secret2.com"])
 			})
 		})
+
+		when("there are FederationDomains which use an external signer", func() {
+			var expectedJWK string
+
+			it.Before(func() {
+				newFederationDomain := func(name, secretName, credentialsSecretName string) *v1alpha1.FederationDomain {
+					return &v1alpha1.FederationDomain{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      name,
+							Namespace: installedInNamespace,
+						},
+						Spec: v1alpha1.FederationDomainSpec{
+							Issuer: "https://" + name + ".com",
+							Signing: &v1alpha1.FederationDomainSigningSpec{
+								SecretName: secretName,
+								ExternalSigner: &v1alpha1.FederationDomainExternalSignerSpec{
+									URL:                   "https://signer.example.com/sign",
+									CredentialsSecretName: credentialsSecretName,
+								},
+							},
+						},
+						Status: v1alpha1.FederationDomainStatus{
+							Secrets: v1alpha1.FederationDomainSecrets{
+								JWKS: corev1.LocalObjectReference{Name: secretName},
+							},
+						},
+					}
+				}
+				newSecret := func(name, activeJWK string) *corev1.Secret {
+					return &corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      name,
+							Namespace: installedInNamespace,
+						},
+						Type: "secrets.pinniped.dev/federation-domain-jwks",
+						Data: map[string][]byte{
+							"activeJWK": []byte(activeJWK),
+							"jwks":      []byte(`{"keys": [` + expectedJWK + `]}`),
+						},
+					}
+				}
+
+				expectedJWK = string(readJWKJSON(t, "testdata/public-jwk.json"))
+				r.NotEmpty(expectedJWK)
+				privateJWK := string(readJWKJSON(t, "testdata/good-jwk.json"))
+				r.NotEmpty(privateJWK)
+
+				r.NoError(pinnipedInformerClient.Tracker().Add(newFederationDomain("good-signer", "good-signer-secret", "signer-credentials")))
+				r.NoError(pinnipedInformerClient.Tracker().Add(newFederationDomain("private-key-signer", "private-key-signer-secret", "signer-credentials")))
+				r.NoError(pinnipedInformerClient.Tracker().Add(newFederationDomain("missing-credentials-signer", "good-signer-secret", "missing-signer-credentials")))
+				r.NoError(kubeInformerClient.Tracker().Add(newSecret("good-signer-secret", expectedJWK)))
+				r.NoError(kubeInformerClient.Tracker().Add(newSecret("private-key-signer-secret", privateJWK)))
+				r.NoError(kubeInformerClient.Tracker().Add(&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "signer-credentials", Namespace: installedInNamespace},
+					Type:       "secrets.pinniped.dev/external-signer-token",
+					Data:       map[string][]byte{"token": []byte("some-token")},
+				}))
+			})
+
+			it("uses the external signer as the active key and skips secrets which contain a private key or signers without credentials", func() {
+				startInformersAndController()
+				r.NoError(controllerlib.TestSync(t, subject, *syncContext))
+
+				r.True(issuerToJWKSSetter.setIssuerToJWKSMapWasCalled)
+				r.Len(issuerToJWKSSetter.issuerToJWKSMapReceived, 1)
+				r.Len(issuerToJWKSSetter.issuerToActiveJWKMapReceived, 1)
+
+				activeJWK := issuerToJWKSSetter.issuerToActiveJWKMapReceived["https://good-signer.com"]
+				r.NotNil(activeJWK)
+				r.Equal("pinniped-supervisor-key", activeJWK.KeyID)
+				r.Equal("ES256", activeJWK.Algorithm)
+				signer, ok := activeJWK.Key.(jose.OpaqueSigner)
+				r.True(ok, "expected the active JWK to be an external signer, got %T", activeJWK.Key)
+				r.Equal([]jose.SignatureAlgorithm{jose.ES256}, signer.Algs())
+				publicJWKJSON, err := json.Marshal(signer.Public())
+				r.NoError(err)
+				r.JSONEq(expectedJWK, string(publicJWKJSON))
+			})
+		})
+
+		when("there are JWKS Secrets which contain private keys in their JWKS", func() {
+			var expectedJWK string
+
+			it.Before(func() {
+				newFederationDomain := func(name string, signing *v1alpha1.FederationDomainSigningSpec) *v1alpha1.FederationDomain {
+					return &v1alpha1.FederationDomain{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      name,
+							Namespace: installedInNamespace,
+						},
+						Spec: v1alpha1.FederationDomainSpec{
+							Issuer:  "https://" + name + ".com",
+							Signing: signing,
+						},
+						Status: v1alpha1.FederationDomainStatus{
+							Secrets: v1alpha1.FederationDomainSecrets{
+								JWKS: corev1.LocalObjectReference{Name: name + "-jwks"},
+							},
+						},
+					}
+				}
+
+				expectedJWK = string(readJWKJSON(t, "testdata/public-jwk.json"))
+				r.NotEmpty(expectedJWK)
+				privateJWK := string(readJWKJSON(t, "testdata/good-jwk.json"))
+				r.NotEmpty(privateJWK)
+				newSecret := func(name string) *corev1.Secret {
+					return &corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      name,
+							Namespace: installedInNamespace,
+						},
+						Type: "secrets.pinniped.dev/federation-domain-jwks",
+						Data: map[string][]byte{
+							"activeJWK": []byte(privateJWK),
+							"jwks":      []byte(`{"keys": [` + privateJWK + `]}`),
+						},
+					}
+				}
+
+				r.NoError(pinnipedInformerClient.Tracker().Add(newFederationDomain("generated", nil)))
+				r.NoError(pinnipedInformerClient.Tracker().Add(newFederationDomain("user-provided", &v1alpha1.FederationDomainSigningSpec{SecretName: "user-provided-jwks"})))
+				r.NoError(kubeInformerClient.Tracker().Add(newSecret("generated-jwks")))
+				r.NoError(kubeInformerClient.Tracker().Add(newSecret("user-provided-jwks")))
+			})
+
+			it("only publishes the public keys and skips user-provided secrets", func() {
+				startInformersAndController()
+				r.NoError(controllerlib.TestSync(t, subject, *syncContext))
+
+				r.True(issuerToJWKSSetter.setIssuerToJWKSMapWasCalled)
+				r.Len(issuerToJWKSSetter.issuerToJWKSMapReceived, 1)
+				r.Len(issuerToJWKSSetter.issuerToActiveJWKMapReceived, 1)
+
+				publishedJWKS := issuerToJWKSSetter.issuerToJWKSMapReceived["https://generated.com"]
+				r.NotNil(publishedJWKS)
+				r.Len(publishedJWKS.Keys, 1)
+				publishedJWKJSON, err := json.Marshal(publishedJWKS.Keys[0])
+				r.NoError(err)
+				r.JSONEq(expectedJWK, string(publishedJWKJSON))
+			})
+		})
 	}, spec.Parallel(), spec.Report(report.Terminal{}))
 }
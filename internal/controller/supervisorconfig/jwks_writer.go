@@ -6,11 +6,21 @@ package supervisorconfig
 import (
 	"context"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
 
 	"gopkg.in/square/go-jose.v2"
 	corev1 "k8s.io/api/core/v1"
@@ -19,15 +29,21 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 
 	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
 	pinnipedclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
 	configinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions/config/v1alpha1"
+	"go.pinniped.dev/internal/constable"
 	pinnipedcontroller "go.pinniped.dev/internal/controller"
 	"go.pinniped.dev/internal/controller/supervisorconfig/generator"
 	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/httputil/roundtripper"
+	"go.pinniped.dev/internal/net/phttp"
+	"go.pinniped.dev/internal/oidc"
 	"go.pinniped.dev/internal/plog"
 )
 
@@ -41,20 +57,202 @@ const (
 	//
 	// Note! The value for this key will contain only public key material!
 	jwksKey = "jwks"
+	// nextJWKKey points to the private key which will replace the active key at the end of the grace period of a
+	// key rotation. Its public key is already in the JWKS. It is only present while a key rotation is in progress.
+	//
+	// Note! The value for this key will contain private key material!
+	nextJWKKey = "nextJWK"
+	// keyRotationKey points to the keyRotationState of the keys in the secret. It is absent until the first
+	// key rotation, in which case the active key is as old as the secret.
+	keyRotationKey = "keyRotation"
 
 	jwksSecretTypeValue corev1.SecretType = "secrets.pinniped.dev/federation-domain-jwks"
 )
 
+// These constants describe the Secrets which contain the credentials of the Supervisor for an external signer.
+const (
+	// externalSignerTokenKey points to the bearer token which is sent to the external signer.
+	externalSignerTokenKey = "token"
+
+	externalSignerTokenSecretType corev1.SecretType = "secrets.pinniped.dev/external-signer-token"
+	externalSignerTLSSecretType                     = corev1.SecretTypeTLS
+)
+
 const (
 	federationDomainKind = "FederationDomain"
+
+	// activeJWKKeyID is the key ID of the first key of each FederationDomain. The keys which replace it during key
+	// rotations have key IDs which are derived from it.
+	activeJWKKeyID = "pinniped-supervisor-key"
+
+	defaultKeyRotationGracePeriod = time.Hour
+
+	// externalSignerTimeout is how long a token request may wait for the external signer.
+	externalSignerTimeout = 10 * time.Second
+
+	// retiredKeyClockSkew is how long a retired key remains in the JWKS after the last ID token which it signed has
+	// expired, since clients whose clocks are behind the Supervisor's clock may still accept those ID tokens.
+	retiredKeyClockSkew = 5 * time.Minute
 )
 
-// generateKey is stubbed out for the purpose of testing. The default behavior is to generate an EC key.
+// keyRotationState records when the keys in a FederationDomain's secret changed roles.
+type keyRotationState struct {
+	// ActivatedAt is when the active key started to sign tokens.
+	ActivatedAt metav1.Time `json:"activatedAt"`
+	// NextPublishedAt is when the next key was added to the JWKS, if there is a next key.
+	NextPublishedAt *metav1.Time `json:"nextPublishedAt,omitempty"`
+	// RetiredAt maps the key ID of each previously active key which is still in the JWKS to when it stopped
+	// signing tokens.
+	RetiredAt map[string]metav1.Time `json:"retiredAt,omitempty"`
+}
+
+// generateKey is stubbed out for the purpose of testing. The default behavior is to generate a key for the
+// requested JWS algorithm.
 //nolint:gochecknoglobals
-var generateKey = generateECKey
+var generateKey = generateKeyForAlgorithm
+
+func generateKeyForAlgorithm(r io.Reader, algorithm configv1alpha1.FederationDomainSigningAlgorithm) (interface{}, error) {
+	switch algorithm {
+	case configv1alpha1.RS256FederationDomainSigningAlgorithm:
+		return rsa.GenerateKey(r, 2048)
+	case configv1alpha1.EdDSAFederationDomainSigningAlgorithm:
+		_, privateKey, err := ed25519.GenerateKey(r)
+		return privateKey, err
+	default:
+		return ecdsa.GenerateKey(elliptic.P256(), r)
+	}
+}
+
+// signingAlgorithmFromSpec returns the algorithm of the signing keys, which is ES256 when the spec is nil.
+func signingAlgorithmFromSpec(spec *configv1alpha1.FederationDomainSigningSpec) configv1alpha1.FederationDomainSigningAlgorithm {
+	if spec == nil || spec.Algorithm == "" {
+		return configv1alpha1.ES256FederationDomainSigningAlgorithm
+	}
+	return spec.Algorithm
+}
+
+// keyRotationScheduleFromSpec returns the interval and the grace period of the key rotation. The interval is zero
+// when the signing key is not rotated on a schedule, but the grace period still applies when the algorithm changes.
+func keyRotationScheduleFromSpec(spec *configv1alpha1.FederationDomainSigningSpec) (time.Duration, time.Duration, error) {
+	if spec == nil || spec.KeyRotation == nil {
+		return 0, defaultKeyRotationGracePeriod, nil
+	}
+	interval := time.Duration(spec.KeyRotation.IntervalSeconds) * time.Second
+	gracePeriod := defaultKeyRotationGracePeriod
+	if spec.KeyRotation.GracePeriodSeconds != 0 {
+		gracePeriod = time.Duration(spec.KeyRotation.GracePeriodSeconds) * time.Second
+	}
+	if gracePeriod >= interval {
+		return 0, 0, fmt.Errorf("invalid key rotation: grace period (%s) must be shorter than the interval (%s)", gracePeriod, interval)
+	}
+	return interval, gracePeriod, nil
+}
+
+// validateSigningSpec returns an error when the signing configuration of a FederationDomain cannot be used.
+func validateSigningSpec(spec *configv1alpha1.FederationDomainSigningSpec) error {
+	if _, _, err := keyRotationScheduleFromSpec(spec); err != nil {
+		return err
+	}
+	if spec == nil {
+		return nil
+	}
+	if spec.SecretName != "" && spec.KeyRotation != nil {
+		return constable.Error("invalid signing: keyRotation cannot be used with secretName")
+	}
+	if spec.ExternalSigner != nil {
+		if spec.SecretName == "" {
+			return constable.Error("invalid signing: externalSigner requires secretName")
+		}
+		if _, err := validateExternalSignerURL(spec.ExternalSigner.URL); err != nil {
+			return fmt.Errorf("invalid signing: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateExternalSignerURL returns an error when the URL of an external signer is neither an https URL nor an http
+// URL of a loopback address, since the signing requests and the credentials of the Supervisor must not be sent over
+// the network in plain text.
+func validateExternalSignerURL(rawURL string) (*url.URL, error) {
+	signerURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("externalSigner.url is invalid: %w", err)
+	}
+	if signerURL.Host == "" || (signerURL.Scheme != "https" && !(signerURL.Scheme == "http" && isLoopbackHost(signerURL.Hostname()))) {
+		return nil, constable.Error("externalSigner.url is invalid: must be an https URL, or an http URL of a loopback address")
+	}
+	return signerURL, nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// externalSignerClient returns the HTTP client which is used to reach the external signer. It authenticates the
+// Supervisor to the signer using the credentials from the Secret which is named by the spec, either by presenting a
+// client certificate or by sending a bearer token.
+func externalSignerClient(
+	secrets corev1listers.SecretNamespaceLister,
+	spec *configv1alpha1.FederationDomainExternalSignerSpec,
+) (*http.Client, error) {
+	signerURL, err := validateExternalSignerURL(spec.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialsSecret, err := secrets.Get(spec.CredentialsSecretName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get externalSigner.credentialsSecretName: %w", err)
+	}
+
+	var rootCAs *x509.CertPool
+	if spec.CertificateAuthorityData != "" {
+		bundle, err := base64.StdEncoding.DecodeString(spec.CertificateAuthorityData)
+		if err != nil {
+			return nil, fmt.Errorf("externalSigner.certificateAuthorityData is invalid: %w", err)
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("externalSigner.certificateAuthorityData is invalid: no certificates found")
+		}
+	}
+
+	var client *http.Client
+	switch credentialsSecret.Type {
+	case externalSignerTLSSecretType:
+		if signerURL.Scheme != "https" {
+			return nil, fmt.Errorf("credentials secret %q of type %q requires an https URL", credentialsSecret.Name, credentialsSecret.Type)
+		}
+		clientCert, err := tls.X509KeyPair(credentialsSecret.Data[corev1.TLSCertKey], credentialsSecret.Data[corev1.TLSPrivateKeyKey])
+		if err != nil {
+			return nil, fmt.Errorf("credentials secret %q does not contain a valid client certificate and key: %w", credentialsSecret.Name, err)
+		}
+		client = phttp.DefaultWithClientCertificate(rootCAs, clientCert)
+	case externalSignerTokenSecretType:
+		token := string(credentialsSecret.Data[externalSignerTokenKey])
+		if token == "" {
+			return nil, fmt.Errorf("credentials secret %q is missing required key %q", credentialsSecret.Name, externalSignerTokenKey)
+		}
+		client = phttp.Default(rootCAs)
+		transport := client.Transport
+		client.Transport = roundtripper.WrapFunc(transport, func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+token)
+			return transport.RoundTrip(req)
+		})
+	default:
+		return nil, fmt.Errorf("credentials secret %q has wrong type %q (should be %q or %q)",
+			credentialsSecret.Name, credentialsSecret.Type, externalSignerTLSSecretType, externalSignerTokenSecretType)
+	}
 
-func generateECKey(r io.Reader) (interface{}, error) {
-	return ecdsa.GenerateKey(elliptic.P256(), r)
+	// Never follow redirects, which could send the credentials of the Supervisor to another server.
+	client.CheckRedirect = func(_ *http.Request, _ []*http.Request) error { return http.ErrUseLastResponse }
+	client.Timeout = externalSignerTimeout
+	return client, nil
 }
 
 // jwkController holds the fields necessary for the JWKS controller to communicate with FederationDomains and
@@ -65,12 +263,14 @@ type jwksWriterController struct {
 	kubeClient               kubernetes.Interface
 	federationDomainInformer configinformers.FederationDomainInformer
 	secretInformer           corev1informers.SecretInformer
+	clock                    clock.Clock
 }
 
 // NewJWKSWriterController returns a controllerlib.Controller that ensures a FederationDomain has a corresponding
-// Secret that contains a valid active JWK and JWKS.
+// Secret that contains a valid active JWK and JWKS, and that rotates the keys in that Secret.
 func NewJWKSWriterController(
 	jwksSecretLabels map[string]string,
+	clock clock.Clock,
 	kubeClient kubernetes.Interface,
 	pinnipedClient pinnipedclientset.Interface,
 	secretInformer corev1informers.SecretInformer,
@@ -90,6 +290,7 @@ func NewJWKSWriterController(
 				pinnipedClient:           pinnipedClient,
 				secretInformer:           secretInformer,
 				federationDomainInformer: federationDomainInformer,
+				clock:                    clock,
 			},
 		},
 		// We want to be notified when a FederationDomain's secret gets updated or deleted. When this happens, we
@@ -132,18 +333,40 @@ func (c *jwksWriterController) Sync(ctx controllerlib.Context) error {
 		return nil
 	}
 
+	if signing := federationDomain.Spec.Signing; signing != nil && signing.SecretName != "" {
+		// The signing keys are managed outside of the Supervisor, so just point the FederationDomain at them once
+		// they can be used.
+		secret, err := c.secretInformer.Lister().Secrets(federationDomain.Namespace).Get(signing.SecretName)
+		if err != nil {
+			return fmt.Errorf("cannot get signing secret: %w", err)
+		}
+		if err := validateSigningSecret(secret, signing.ExternalSigner != nil); err != nil {
+			return fmt.Errorf("invalid signing secret %q: %w", secret.Name, err)
+		}
+		if signing.ExternalSigner != nil {
+			if _, err := externalSignerClient(c.secretInformer.Lister().Secrets(federationDomain.Namespace), signing.ExternalSigner); err != nil {
+				return fmt.Errorf("invalid external signer: %w", err)
+			}
+		}
+		if federationDomain.Status.Secrets.JWKS.Name == signing.SecretName {
+			return nil
+		}
+		newFederationDomain := federationDomain.DeepCopy()
+		newFederationDomain.Status.Secrets.JWKS.Name = signing.SecretName
+		if err := c.updateFederationDomainStatus(ctx.Context, newFederationDomain); err != nil {
+			return fmt.Errorf("cannot update FederationDomain: %w", err)
+		}
+		plog.Debug("updated FederationDomain to use its signing secret", "federationdomain", klog.KObj(newFederationDomain))
+		return nil
+	}
+
 	secretNeedsUpdate, err := c.secretNeedsUpdate(federationDomain)
 	if err != nil {
 		return fmt.Errorf("cannot determine secret status: %w", err)
 	}
 	if !secretNeedsUpdate {
-		// Secret is up to date - we are good to go.
-		plog.Debug(
-			"secret is up to date",
-			"federationdomain",
-			klog.KRef(ctx.Key.Namespace, ctx.Key.Name),
-		)
-		return nil
+		// Secret is valid - we only need to check whether its keys are due to be rotated.
+		return c.rotateKeys(ctx, federationDomain)
 	}
 
 	// If the FederationDomain does not have a secret associated with it, that secret does not exist, or the secret
@@ -186,6 +409,12 @@ func (c *jwksWriterController) secretNeedsUpdate(federationDomain *configv1alpha
 		return true, nil
 	}
 
+	if !metav1.IsControlledBy(secret, federationDomain) {
+		// This secret was not generated for this FederationDomain, e.g. it was the signing secret of the
+		// FederationDomain before it was removed from the spec, so we must not modify it.
+		return true, nil
+	}
+
 	if !isValid(secret) {
 		// If this secret is invalid, we need to generate a new one.
 		return true, nil
@@ -199,18 +428,11 @@ func (c *jwksWriterController) generateSecret(federationDomain *configv1alpha1.F
 	// this FederationDomain should sign and verify ID tokens (e.g., hardcoded token secret, gRPC
 	// connection to KMS, etc).
 	//
-	// For now, we just generate an new RSA keypair and put that in the secret.
+	// For now, we just generate a new keypair of the configured algorithm and put that in the secret.
 
-	key, err := generateKey(rand.Reader)
+	jwk, err := newJWK(activeJWKKeyID, signingAlgorithmFromSpec(federationDomain.Spec.Signing))
 	if err != nil {
-		return nil, fmt.Errorf("cannot generate key: %w", err)
-	}
-
-	jwk := jose.JSONWebKey{
-		Key:       key,
-		KeyID:     "pinniped-supervisor-key",
-		Algorithm: "ES256",
-		Use:       "sig",
+		return nil, err
 	}
 	jwkData, err := json.Marshal(jwk)
 	if err != nil {
@@ -248,6 +470,181 @@ func (c *jwksWriterController) generateSecret(federationDomain *configv1alpha1.F
 	return &s, nil
 }
 
+// rotateKeys moves the keys in the FederationDomain's valid secret through their roles. The next key is published
+// when the active key does not have the configured algorithm or when its interval is almost over. The next key
+// replaces the active key at the end of the grace period. The replaced key stays in the JWKS until all the ID tokens
+// which it signed have expired. The FederationDomain is requeued for the next of these events.
+func (c *jwksWriterController) rotateKeys(ctx controllerlib.Context, federationDomain *configv1alpha1.FederationDomain) error {
+	secret, err := c.secretInformer.Lister().Secrets(federationDomain.Namespace).Get(federationDomain.Status.Secrets.JWKS.Name)
+	if err != nil {
+		return fmt.Errorf("cannot get secret: %w", err)
+	}
+
+	var activeJWK jose.JSONWebKey
+	if err := json.Unmarshal(secret.Data[activeJWKKey], &activeJWK); err != nil {
+		return fmt.Errorf("cannot unmarshal active jwk: %w", err)
+	}
+	var nextJWK *jose.JSONWebKey
+	if nextJWKData, ok := secret.Data[nextJWKKey]; ok {
+		nextJWK = &jose.JSONWebKey{}
+		if err := json.Unmarshal(nextJWKData, nextJWK); err != nil {
+			return fmt.Errorf("cannot unmarshal next jwk: %w", err)
+		}
+	}
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(secret.Data[jwksKey], &jwks); err != nil {
+		return fmt.Errorf("cannot unmarshal jwks: %w", err)
+	}
+	state := keyRotationState{ActivatedAt: secret.CreationTimestamp}
+	if stateData, ok := secret.Data[keyRotationKey]; ok {
+		if err := json.Unmarshal(stateData, &state); err != nil {
+			return fmt.Errorf("cannot unmarshal key rotation state: %w", err)
+		}
+	}
+	if nextJWK == nil {
+		state.NextPublishedAt = nil
+	}
+
+	algorithm := signingAlgorithmFromSpec(federationDomain.Spec.Signing)
+	interval, gracePeriod, err := keyRotationScheduleFromSpec(federationDomain.Spec.Signing)
+	if err != nil {
+		// The FederationDomain watcher reports the invalid spec in the status, so just skip the scheduled rotations.
+		plog.Debug("skipping scheduled key rotation", "federationdomain", klog.KObj(federationDomain), "err", err)
+		interval, gracePeriod = 0, defaultKeyRotationGracePeriod
+	}
+	tokenLifetimes, err := tokenLifetimesFromSpec(federationDomain.Spec.Tokens)
+	if err != nil {
+		tokenLifetimes = nil
+	}
+	retiredKeyLifespan := oidc.OIDCTimeoutsConfiguration(tokenLifetimes).IDTokenLifespan + retiredKeyClockSkew
+
+	now := c.clock.Now()
+	changed := false
+
+	if nextJWK != nil && !now.Before(state.NextPublishedAt.Add(gracePeriod)) {
+		// The grace period is over, so the next key replaces the active key.
+		if state.RetiredAt == nil {
+			state.RetiredAt = map[string]metav1.Time{}
+		}
+		state.RetiredAt[activeJWK.KeyID] = metav1.NewTime(now)
+		activeJWK, nextJWK = *nextJWK, nil
+		state.ActivatedAt = metav1.NewTime(now)
+		state.NextPublishedAt = nil
+		changed = true
+	}
+
+	if nextJWK != nil && nextJWK.Algorithm != string(algorithm) {
+		// The algorithm changed again since the next key was published, so replace the next key.
+		nextJWK = nil
+		state.NextPublishedAt = nil
+		changed = true
+	}
+
+	rotationIsDue := interval > 0 && !now.Before(state.ActivatedAt.Add(interval-gracePeriod))
+	if nextJWK == nil && (activeJWK.Algorithm != string(algorithm) || rotationIsDue) {
+		jwk, err := newJWK(fmt.Sprintf("%s-%d", activeJWKKeyID, now.Unix()), algorithm)
+		if err != nil {
+			return err
+		}
+		nextJWK = &jwk
+		state.NextPublishedAt = timePtr(metav1.NewTime(now))
+		changed = true
+	}
+
+	for keyID, retiredAt := range state.RetiredAt {
+		if !now.Before(retiredAt.Add(retiredKeyLifespan)) {
+			// Every ID token which was signed by this key has expired, even for clients whose clocks are behind,
+			// so it is no longer needed in the JWKS.
+			delete(state.RetiredAt, keyID)
+			changed = true
+		}
+	}
+
+	if changed {
+		newSecret := secret.DeepCopy()
+		newSecret.Data, err = keyRotationSecretData(activeJWK, nextJWK, jwks, state)
+		if err != nil {
+			return err
+		}
+		if _, err := c.kubeClient.CoreV1().Secrets(newSecret.Namespace).Update(ctx.Context, newSecret, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("cannot update secret: %w", err)
+		}
+		plog.Debug("rotated keys in secret", "secret", klog.KObj(newSecret), "activekeyid", activeJWK.KeyID)
+	}
+
+	var nextEvents []time.Time
+	if nextJWK != nil {
+		nextEvents = append(nextEvents, state.NextPublishedAt.Add(gracePeriod))
+	} else if interval > 0 {
+		nextEvents = append(nextEvents, state.ActivatedAt.Add(interval-gracePeriod))
+	}
+	for _, retiredAt := range state.RetiredAt {
+		nextEvents = append(nextEvents, retiredAt.Add(retiredKeyLifespan))
+	}
+	if len(nextEvents) > 0 {
+		sort.Slice(nextEvents, func(i, j int) bool { return nextEvents[i].Before(nextEvents[j]) })
+		ctx.Queue.AddAfter(ctx.Key, nextEvents[0].Sub(now))
+	}
+
+	return nil
+}
+
+// keyRotationSecretData returns the data of a FederationDomain's secret. The JWKS contains the public keys of the
+// active key, the next key, and those of the previously active keys which are still listed in the state.
+func keyRotationSecretData(
+	activeJWK jose.JSONWebKey,
+	nextJWK *jose.JSONWebKey,
+	oldJWKS jose.JSONWebKeySet,
+	state keyRotationState,
+) (map[string][]byte, error) {
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{activeJWK.Public()}}
+	if nextJWK != nil {
+		jwks.Keys = append(jwks.Keys, nextJWK.Public())
+	}
+	retiredKeyIDs := make([]string, 0, len(state.RetiredAt))
+	for keyID := range state.RetiredAt {
+		retiredKeyIDs = append(retiredKeyIDs, keyID)
+	}
+	sort.Strings(retiredKeyIDs)
+	for _, keyID := range retiredKeyIDs {
+		if keys := oldJWKS.Key(keyID); len(keys) > 0 {
+			jwks.Keys = append(jwks.Keys, keys[0])
+		}
+	}
+
+	data := map[string][]byte{}
+	var err error
+	if data[activeJWKKey], err = json.Marshal(activeJWK); err != nil {
+		return nil, fmt.Errorf("cannot marshal jwk: %w", err)
+	}
+	if nextJWK != nil {
+		if data[nextJWKKey], err = json.Marshal(nextJWK); err != nil {
+			return nil, fmt.Errorf("cannot marshal next jwk: %w", err)
+		}
+	}
+	if data[jwksKey], err = json.Marshal(jwks); err != nil {
+		return nil, fmt.Errorf("cannot marshal jwks: %w", err)
+	}
+	if data[keyRotationKey], err = json.Marshal(state); err != nil {
+		return nil, fmt.Errorf("cannot marshal key rotation state: %w", err)
+	}
+	return data, nil
+}
+
+// newJWK generates a new private JWK for signing tokens with the provided algorithm.
+func newJWK(keyID string, algorithm configv1alpha1.FederationDomainSigningAlgorithm) (jose.JSONWebKey, error) {
+	key, err := generateKey(rand.Reader, algorithm)
+	if err != nil {
+		return jose.JSONWebKey{}, fmt.Errorf("cannot generate key: %w", err)
+	}
+	return jose.JSONWebKey{
+		Key:       key,
+		KeyID:     keyID,
+		Algorithm: string(algorithm),
+		Use:       "sig",
+	}, nil
+}
+
 func (c *jwksWriterController) createOrUpdateSecret(
 	ctx context.Context,
 	newSecret *corev1.Secret,
@@ -305,7 +702,66 @@ func (c *jwksWriterController) updateFederationDomainStatus(
 	})
 }
 
-// isValid returns whether the provided secret contains a valid active JWK and verification JWKS.
+// validateSigningSecret returns an error when a Secret which is referenced by the spec.signing.secretName of a
+// FederationDomain cannot be used. Its active JWK must be an ECDSA, RSA, or Ed25519 private key, or a public key with
+// an ES256, RS256, or EdDSA algorithm when the private key is held by an external signer. Its JWKS must only contain valid public keys,
+// including the active key, since it is served to the clients as is.
+func validateSigningSecret(secret *corev1.Secret, externalSigner bool) error {
+	if secret.Type != jwksSecretTypeValue {
+		return fmt.Errorf("secret has wrong type %q (should be %q)", secret.Type, jwksSecretTypeValue)
+	}
+
+	var activeJWK jose.JSONWebKey
+	if err := json.Unmarshal(secret.Data[activeJWKKey], &activeJWK); err != nil {
+		return fmt.Errorf("cannot unmarshal active jwk: %w", err)
+	}
+	if !activeJWK.Valid() {
+		return constable.Error("active jwk is not valid")
+	}
+	if activeJWK.KeyID == "" {
+		return constable.Error("active jwk does not have a key ID")
+	}
+	if externalSigner {
+		if !activeJWK.IsPublic() {
+			return constable.Error("active jwk must be a public key when using an external signer")
+		}
+		switch configv1alpha1.FederationDomainSigningAlgorithm(activeJWK.Algorithm) {
+		case configv1alpha1.ES256FederationDomainSigningAlgorithm, configv1alpha1.RS256FederationDomainSigningAlgorithm,
+			configv1alpha1.EdDSAFederationDomainSigningAlgorithm:
+		default:
+			return fmt.Errorf("active jwk has unsupported algorithm %q (should be %q, %q, or %q)", activeJWK.Algorithm,
+				configv1alpha1.ES256FederationDomainSigningAlgorithm, configv1alpha1.RS256FederationDomainSigningAlgorithm,
+				configv1alpha1.EdDSAFederationDomainSigningAlgorithm)
+		}
+	} else {
+		switch activeJWK.Key.(type) {
+		case *ecdsa.PrivateKey, *rsa.PrivateKey, ed25519.PrivateKey:
+		default:
+			return constable.Error("active jwk must be an ECDSA, RSA, or Ed25519 private key")
+		}
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(secret.Data[jwksKey], &jwks); err != nil {
+		return fmt.Errorf("cannot unmarshal jwks: %w", err)
+	}
+	foundActiveJWK := false
+	for _, jwk := range jwks.Keys {
+		if !jwk.IsPublic() || !jwk.Valid() {
+			return fmt.Errorf("jwks key %q is not a valid public key", jwk.KeyID)
+		}
+		if jwk.KeyID == activeJWK.KeyID {
+			foundActiveJWK = true
+		}
+	}
+	if !foundActiveJWK {
+		return fmt.Errorf("jwks does not contain the active jwk %q", activeJWK.KeyID)
+	}
+
+	return nil
+}
+
+// isValid returns whether the provided secret contains a valid active JWK, next JWK (if any), and verification JWKS.
 func isValid(secret *corev1.Secret) bool {
 	if secret.Type != jwksSecretTypeValue {
 		plog.Debug("secret does not have the expected type", "expectedType", jwksSecretTypeValue, "actualType", secret.Type)
@@ -334,6 +790,41 @@ func isValid(secret *corev1.Secret) bool {
 		return false
 	}
 
+	var nextJWK *jose.JSONWebKey
+	if nextJWKData, ok := secret.Data[nextJWKKey]; ok {
+		nextJWK = &jose.JSONWebKey{}
+		if err := json.Unmarshal(nextJWKData, nextJWK); err != nil {
+			plog.Debug("cannot unmarshal next jwk", "err", err)
+			return false
+		}
+
+		if nextJWK.IsPublic() {
+			plog.Debug("next jwk is public", "keyid", nextJWK.KeyID)
+			return false
+		}
+
+		if !nextJWK.Valid() {
+			plog.Debug("next jwk is not valid", "keyid", nextJWK.KeyID)
+			return false
+		}
+	}
+
+	if stateData, ok := secret.Data[keyRotationKey]; ok {
+		var state keyRotationState
+		if err := json.Unmarshal(stateData, &state); err != nil {
+			plog.Debug("cannot unmarshal key rotation state", "err", err)
+			return false
+		}
+
+		if nextJWK != nil && state.NextPublishedAt == nil {
+			plog.Debug("key rotation state does not say when the next jwk was published", "keyid", nextJWK.KeyID)
+			return false
+		}
+	} else if nextJWK != nil {
+		plog.Debug("secret does not contain key rotation state for next jwk", "keyid", nextJWK.KeyID)
+		return false
+	}
+
 	jwksData, ok := secret.Data[jwksKey]
 	if !ok {
 		plog.Debug("secret does not contain valid jwks")
@@ -345,7 +836,7 @@ func isValid(secret *corev1.Secret) bool {
 		return false
 	}
 
-	foundActiveJWK := false
+	foundActiveJWK, foundNextJWK := false, nextJWK == nil
 	for _, validJWK := range validJWKS.Keys {
 		if !validJWK.IsPublic() {
 			plog.Debug("jwks key is not public", "keyid", validJWK.KeyID)
@@ -358,6 +849,9 @@ func isValid(secret *corev1.Secret) bool {
 		if validJWK.KeyID == activeJWK.KeyID {
 			foundActiveJWK = true
 		}
+		if nextJWK != nil && validJWK.KeyID == nextJWK.KeyID {
+			foundNextJWK = true
+		}
 	}
 
 	if !foundActiveJWK {
@@ -365,5 +859,10 @@ func isValid(secret *corev1.Secret) bool {
 		return false
 	}
 
+	if !foundNextJWK {
+		plog.Debug("did not find next jwk in valid jwks", "keyid", nextJWK.KeyID)
+		return false
+	}
+
 	return true
 }
@@ -6,14 +6,26 @@ package supervisorconfig
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -21,12 +33,15 @@ import (
 	kubeinformers "k8s.io/client-go/informers"
 	kubernetesfake "k8s.io/client-go/kubernetes/fake"
 	kubetesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
 
 	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
 	pinnipedfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
 	pinnipedinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions"
+	"go.pinniped.dev/internal/certauthority"
 	"go.pinniped.dev/internal/controllerlib"
 	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/internal/testutil/tlsserver"
 )
 
 func TestJWKSWriterControllerFilterSecret(t *testing.T) {
@@ -182,6 +197,7 @@ func TestJWKSWriterControllerFilterSecret(t *testing.T) {
 			withInformer := testutil.NewObservableWithInformerOption()
 			_ = NewJWKSWriterController(
 				nil, // labels, not needed
+				nil, // clock, not needed
 				nil, // kubeClient, not needed
 				nil, // pinnipedClient, not needed
 				secretInformer,
@@ -236,6 +252,7 @@ func TestJWKSWriterControllerFilterFederationDomain(t *testing.T) {
 			withInformer := testutil.NewObservableWithInformerOption()
 			_ = NewJWKSWriterController(
 				nil, // labels, not needed
+				nil, // clock, not needed
 				nil, // kubeClient, not needed
 				nil, // pinnipedClient, not needed
 				secretInformer,
@@ -328,6 +345,51 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 	secretWithWrongType := newSecret("testdata/good-jwk.json", "testdata/good-jwks.json")
 	secretWithWrongType.Type = "not-the-right-type"
 
+	userSecret := newSecret("testdata/good-jwk.json", "testdata/good-jwks.json")
+	userSecret.Name = "some-user-provided-jwks"
+	userSecret.OwnerReferences = nil
+
+	federationDomainWithUserSecret := goodFederationDomain.DeepCopy()
+	federationDomainWithUserSecret.Spec.Signing = &configv1alpha1.FederationDomainSigningSpec{SecretName: userSecret.Name}
+	federationDomainWithUserSecretWithStatus := federationDomainWithUserSecret.DeepCopy()
+	federationDomainWithUserSecretWithStatus.Status.Secrets.JWKS.Name = userSecret.Name
+
+	userSecretWithWrongType := userSecret.DeepCopy()
+	userSecretWithWrongType.Type = "not-the-right-type"
+
+	userSecretWithPrivateJWKS := newSecret("testdata/good-jwk.json", "testdata/private-jwks.json")
+	userSecretWithPrivateJWKS.Name = userSecret.Name
+	userSecretWithPrivateJWKS.OwnerReferences = nil
+
+	userSecretMissingActiveJWK := newSecret("testdata/good-jwk.json", "")
+	userSecretMissingActiveJWK.Name = userSecret.Name
+	userSecretMissingActiveJWK.OwnerReferences = nil
+	userSecretMissingActiveJWK.Data["jwks"] = []byte(`{"keys": [` + string(readJWKJSON(t, "testdata/public-jwk2.json")) + `]}`)
+
+	userSecretWithPublicActiveJWK := newSecret("testdata/public-jwk.json", "testdata/good-jwks.json")
+	userSecretWithPublicActiveJWK.Name = userSecret.Name
+	userSecretWithPublicActiveJWK.OwnerReferences = nil
+
+	federationDomainWithExternalSigner := goodFederationDomain.DeepCopy()
+	federationDomainWithExternalSigner.Spec.Signing = &configv1alpha1.FederationDomainSigningSpec{
+		SecretName: userSecret.Name,
+		ExternalSigner: &configv1alpha1.FederationDomainExternalSignerSpec{
+			URL:                   "https://signer.example.com/sign",
+			CredentialsSecretName: "some-signer-credentials",
+		},
+	}
+	federationDomainWithExternalSignerWithStatus := federationDomainWithExternalSigner.DeepCopy()
+	federationDomainWithExternalSignerWithStatus.Status.Secrets.JWKS.Name = userSecret.Name
+
+	signerCredentialsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "some-signer-credentials", Namespace: namespace},
+		Type:       "secrets.pinniped.dev/external-signer-token",
+		Data:       map[string][]byte{"token": []byte("some-token")},
+	}
+
+	federationDomainWithStatusForUserSecret := goodFederationDomain.DeepCopy()
+	federationDomainWithStatusForUserSecret.Status.Secrets.JWKS.Name = userSecret.Name
+
 	tests := []struct {
 		name                        string
 		key                         controllerlib.Key
@@ -400,6 +462,143 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 				goodSecret,
 			},
 		},
+		{
+			name: "federationDomain with a user-provided secret",
+			key:  controllerlib.Key{Namespace: goodFederationDomain.Namespace, Name: goodFederationDomain.Name},
+			federationDomains: []*configv1alpha1.FederationDomain{
+				federationDomainWithUserSecret,
+			},
+			secrets: []*corev1.Secret{
+				userSecret,
+			},
+			wantSecretActions: []kubetesting.Action{},
+			wantFederationDomainActions: []kubetesting.Action{
+				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
+				kubetesting.NewUpdateSubresourceAction(federationDomainGVR, "status", namespace, federationDomainWithUserSecretWithStatus),
+			},
+		},
+		{
+			name: "federationDomain with a user-provided secret and status",
+			key:  controllerlib.Key{Namespace: goodFederationDomain.Namespace, Name: goodFederationDomain.Name},
+			federationDomains: []*configv1alpha1.FederationDomain{
+				federationDomainWithUserSecretWithStatus,
+			},
+			secrets: []*corev1.Secret{
+				userSecret,
+			},
+			wantSecretActions:           []kubetesting.Action{},
+			wantFederationDomainActions: []kubetesting.Action{},
+		},
+		{
+			name: "federationDomain with a missing user-provided secret",
+			key:  controllerlib.Key{Namespace: goodFederationDomain.Namespace, Name: goodFederationDomain.Name},
+			federationDomains: []*configv1alpha1.FederationDomain{
+				federationDomainWithUserSecret,
+			},
+			wantSecretActions:           []kubetesting.Action{},
+			wantFederationDomainActions: []kubetesting.Action{},
+			wantError:                   `cannot get signing secret: secret "some-user-provided-jwks" not found`,
+		},
+		{
+			name: "federationDomain with a user-provided secret of the wrong type",
+			key:  controllerlib.Key{Namespace: goodFederationDomain.Namespace, Name: goodFederationDomain.Name},
+			federationDomains: []*configv1alpha1.FederationDomain{
+				federationDomainWithUserSecret,
+			},
+			secrets: []*corev1.Secret{
+				userSecretWithWrongType,
+			},
+			wantSecretActions:           []kubetesting.Action{},
+			wantFederationDomainActions: []kubetesting.Action{},
+			wantError:                   `invalid signing secret "some-user-provided-jwks": secret has wrong type "not-the-right-type" (should be "secrets.pinniped.dev/federation-domain-jwks")`,
+		},
+		{
+			name: "federationDomain with a user-provided secret whose jwks contains a private key",
+			key:  controllerlib.Key{Namespace: goodFederationDomain.Namespace, Name: goodFederationDomain.Name},
+			federationDomains: []*configv1alpha1.FederationDomain{
+				federationDomainWithUserSecret,
+			},
+			secrets: []*corev1.Secret{
+				userSecretWithPrivateJWKS,
+			},
+			wantSecretActions:           []kubetesting.Action{},
+			wantFederationDomainActions: []kubetesting.Action{},
+			wantError:                   `invalid signing secret "some-user-provided-jwks": jwks key "pinniped-supervisor-key" is not a valid public key`,
+		},
+		{
+			name: "federationDomain with a user-provided secret whose jwks does not contain the active key",
+			key:  controllerlib.Key{Namespace: goodFederationDomain.Namespace, Name: goodFederationDomain.Name},
+			federationDomains: []*configv1alpha1.FederationDomain{
+				federationDomainWithUserSecret,
+			},
+			secrets: []*corev1.Secret{
+				userSecretMissingActiveJWK,
+			},
+			wantSecretActions:           []kubetesting.Action{},
+			wantFederationDomainActions: []kubetesting.Action{},
+			wantError:                   `invalid signing secret "some-user-provided-jwks": jwks does not contain the active jwk "pinniped-supervisor-key"`,
+		},
+		{
+			name: "federationDomain with a user-provided secret whose active key is public without an external signer",
+			key:  controllerlib.Key{Namespace: goodFederationDomain.Namespace, Name: goodFederationDomain.Name},
+			federationDomains: []*configv1alpha1.FederationDomain{
+				federationDomainWithUserSecret,
+			},
+			secrets: []*corev1.Secret{
+				userSecretWithPublicActiveJWK,
+			},
+			wantSecretActions:           []kubetesting.Action{},
+			wantFederationDomainActions: []kubetesting.Action{},
+			wantError:                   `invalid signing secret "some-user-provided-jwks": active jwk must be an ECDSA, RSA, or Ed25519 private key`,
+		},
+		{
+			name: "federationDomain with an external signer",
+			key:  controllerlib.Key{Namespace: goodFederationDomain.Namespace, Name: goodFederationDomain.Name},
+			federationDomains: []*configv1alpha1.FederationDomain{
+				federationDomainWithExternalSigner,
+			},
+			secrets: []*corev1.Secret{
+				userSecretWithPublicActiveJWK,
+				signerCredentialsSecret,
+			},
+			wantSecretActions: []kubetesting.Action{},
+			wantFederationDomainActions: []kubetesting.Action{
+				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
+				kubetesting.NewUpdateSubresourceAction(federationDomainGVR, "status", namespace, federationDomainWithExternalSignerWithStatus),
+			},
+		},
+		{
+			name: "federationDomain with an external signer whose credentials secret is missing",
+			key:  controllerlib.Key{Namespace: goodFederationDomain.Namespace, Name: goodFederationDomain.Name},
+			federationDomains: []*configv1alpha1.FederationDomain{
+				federationDomainWithExternalSigner,
+			},
+			secrets: []*corev1.Secret{
+				userSecretWithPublicActiveJWK,
+			},
+			wantSecretActions:           []kubetesting.Action{},
+			wantFederationDomainActions: []kubetesting.Action{},
+			wantError:                   `invalid external signer: cannot get externalSigner.credentialsSecretName: secret "some-signer-credentials" not found`,
+		},
+		{
+			name: "federationDomain which stopped using a user-provided secret",
+			key:  controllerlib.Key{Namespace: goodFederationDomain.Namespace, Name: goodFederationDomain.Name},
+			federationDomains: []*configv1alpha1.FederationDomain{
+				federationDomainWithStatusForUserSecret,
+			},
+			secrets: []*corev1.Secret{
+				userSecret,
+			},
+			wantGenerateKeyCount: 1,
+			wantSecretActions: []kubetesting.Action{
+				kubetesting.NewGetAction(secretGVR, namespace, goodSecret.Name),
+				kubetesting.NewCreateAction(secretGVR, namespace, goodSecret),
+			},
+			wantFederationDomainActions: []kubetesting.Action{
+				kubetesting.NewGetAction(federationDomainGVR, namespace, goodFederationDomain.Name),
+				kubetesting.NewUpdateSubresourceAction(federationDomainGVR, "status", namespace, goodFederationDomainWithStatus),
+			},
+		},
 		{
 			name: "deleted federationDomain",
 			key:  controllerlib.Key{Namespace: goodFederationDomain.Namespace, Name: goodFederationDomain.Name},
@@ -668,7 +867,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			// We shouldn't run this test in parallel since it messes with a global function (generateKey).
 			generateKeyCount := 0
-			generateKey = func(_ io.Reader) (interface{}, error) {
+			generateKey = func(_ io.Reader, _ configv1alpha1.FederationDomainSigningAlgorithm) (interface{}, error) {
 				generateKeyCount++
 				return goodKey, test.generateKeyErr
 			}
@@ -710,6 +909,7 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 					"myLabelKey1": "myLabelValue1",
 					"myLabelKey2": "myLabelValue2",
 				},
+				clocktesting.NewFakeClock(time.Now()),
 				kubeAPIClient,
 				pinnipedAPIClient,
 				kubeInformers.Core().V1().Secrets(),
@@ -744,6 +944,333 @@ func TestJWKSWriterControllerSync(t *testing.T) {
 	}
 }
 
+func TestJWKSWriterControllerRotateKeys(t *testing.T) {
+	// We shouldn't run this test in parallel since it messes with a global function (generateKey).
+
+	const namespace = "tuna-namespace"
+
+	now := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+	nextKeyID := fmt.Sprintf("pinniped-supervisor-key-%d", now.Unix())
+
+	goodKeyPEM, err := ioutil.ReadFile("testdata/good-ec-key.pem")
+	require.NoError(t, err)
+	block, _ := pem.Decode(goodKeyPEM)
+	require.NotNil(t, block, "expected block to be non-nil...is goodKeyPEM a valid PEM?")
+	ecKey, err := x509.ParseECPrivateKey(block.Bytes)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keysByAlgorithm := map[configv1alpha1.FederationDomainSigningAlgorithm]interface{}{
+		configv1alpha1.ES256FederationDomainSigningAlgorithm: ecKey,
+		configv1alpha1.RS256FederationDomainSigningAlgorithm: rsaKey,
+		configv1alpha1.EdDSAFederationDomainSigningAlgorithm: edKey,
+	}
+
+	activeJWK := jose.JSONWebKey{Key: ecKey, KeyID: "pinniped-supervisor-key", Algorithm: "ES256", Use: "sig"}
+	oldJWK := jose.JSONWebKey{Key: ecKey, KeyID: "some-old-key", Algorithm: "ES256", Use: "sig"}
+	nextRSAJWK := jose.JSONWebKey{Key: rsaKey, KeyID: "some-next-key", Algorithm: "RS256", Use: "sig"}
+
+	timeAgo := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(-d))
+		return &t
+	}
+
+	newFederationDomain := func(signing *configv1alpha1.FederationDomainSigningSpec, tokens *configv1alpha1.FederationDomainTokensSpec) *configv1alpha1.FederationDomain {
+		return &configv1alpha1.FederationDomain{
+			ObjectMeta: metav1.ObjectMeta{Name: "good-federationDomain", Namespace: namespace, UID: "good-federationDomain-uid"},
+			Spec:       configv1alpha1.FederationDomainSpec{Issuer: "https://some-issuer.com", Signing: signing, Tokens: tokens},
+			Status: configv1alpha1.FederationDomainStatus{
+				Secrets: configv1alpha1.FederationDomainSecrets{JWKS: corev1.LocalObjectReference{Name: "good-federationDomain-jwks"}},
+			},
+		}
+	}
+
+	newSecret := func(
+		createdAgo time.Duration,
+		active jose.JSONWebKey,
+		next *jose.JSONWebKey,
+		retired []jose.JSONWebKey,
+		state *keyRotationState,
+	) *corev1.Secret {
+		jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{active.Public()}}
+		data := map[string][]byte{}
+		var err error
+		data["activeJWK"], err = json.Marshal(active)
+		require.NoError(t, err)
+		if next != nil {
+			data["nextJWK"], err = json.Marshal(next)
+			require.NoError(t, err)
+			jwks.Keys = append(jwks.Keys, next.Public())
+		}
+		for _, jwk := range retired {
+			jwks.Keys = append(jwks.Keys, jwk.Public())
+		}
+		data["jwks"], err = json.Marshal(jwks)
+		require.NoError(t, err)
+		if state != nil {
+			data["keyRotation"], err = json.Marshal(state)
+			require.NoError(t, err)
+		}
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "good-federationDomain-jwks",
+				Namespace:         namespace,
+				CreationTimestamp: *timeAgo(createdAgo),
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: configv1alpha1.SchemeGroupVersion.String(),
+						Kind:       "FederationDomain",
+						Name:       "good-federationDomain",
+						UID:        "good-federationDomain-uid",
+						Controller: boolPtr(true),
+					},
+				},
+			},
+			Type: "secrets.pinniped.dev/federation-domain-jwks",
+			Data: data,
+		}
+	}
+
+	tests := []struct {
+		name                   string
+		federationDomain       *configv1alpha1.FederationDomain
+		secret                 *corev1.Secret
+		configKubeClient       func(*kubernetesfake.Clientset)
+		wantGeneratedAlgorithm configv1alpha1.FederationDomainSigningAlgorithm
+		wantActiveKeyID        string
+		wantNextKeyID          string
+		wantNextAlgorithm      string
+		wantJWKSKeyIDs         []string
+		wantState              *keyRotationState // nil when the secret should not be updated
+		wantRequeueAfter       time.Duration     // zero when the FederationDomain should not be requeued
+		wantError              string
+	}{
+		{
+			name:             "no key rotation and the algorithm is unchanged",
+			federationDomain: newFederationDomain(nil, nil),
+			secret:           newSecret(30*24*time.Hour, activeJWK, nil, nil, nil),
+		},
+		{
+			name:                   "the algorithm changed, so the next key is published",
+			federationDomain:       newFederationDomain(&configv1alpha1.FederationDomainSigningSpec{Algorithm: "RS256"}, nil),
+			secret:                 newSecret(30*24*time.Hour, activeJWK, nil, nil, nil),
+			wantGeneratedAlgorithm: "RS256",
+			wantActiveKeyID:        "pinniped-supervisor-key",
+			wantNextKeyID:          nextKeyID,
+			wantNextAlgorithm:      "RS256",
+			wantJWKSKeyIDs:         []string{"pinniped-supervisor-key", nextKeyID},
+			wantState:              &keyRotationState{ActivatedAt: *timeAgo(30 * 24 * time.Hour), NextPublishedAt: timeAgo(0)},
+			wantRequeueAfter:       time.Hour,
+		},
+		{
+			name:             "the grace period of the next key is not over yet",
+			federationDomain: newFederationDomain(&configv1alpha1.FederationDomainSigningSpec{Algorithm: "RS256"}, nil),
+			secret: newSecret(30*24*time.Hour, activeJWK, &nextRSAJWK, nil, &keyRotationState{
+				ActivatedAt:     *timeAgo(30 * 24 * time.Hour),
+				NextPublishedAt: timeAgo(20 * time.Minute),
+			}),
+			wantRequeueAfter: 40 * time.Minute,
+		},
+		{
+			name:             "the grace period of the next key is over, so it replaces the active key",
+			federationDomain: newFederationDomain(&configv1alpha1.FederationDomainSigningSpec{Algorithm: "RS256"}, nil),
+			secret: newSecret(30*24*time.Hour, activeJWK, &nextRSAJWK, nil, &keyRotationState{
+				ActivatedAt:     *timeAgo(30 * 24 * time.Hour),
+				NextPublishedAt: timeAgo(time.Hour),
+			}),
+			wantActiveKeyID: "some-next-key",
+			wantJWKSKeyIDs:  []string{"some-next-key", "pinniped-supervisor-key"},
+			wantState: &keyRotationState{
+				ActivatedAt: *timeAgo(0),
+				RetiredAt:   map[string]metav1.Time{"pinniped-supervisor-key": *timeAgo(0)},
+			},
+			wantRequeueAfter: 7 * time.Minute, // the default ID token lifetime plus the clock skew allowance
+		},
+		{
+			name:             "the algorithm changed back before the grace period is over, so the next key is removed",
+			federationDomain: newFederationDomain(&configv1alpha1.FederationDomainSigningSpec{Algorithm: "ES256"}, nil),
+			secret: newSecret(30*24*time.Hour, activeJWK, &nextRSAJWK, nil, &keyRotationState{
+				ActivatedAt:     *timeAgo(30 * 24 * time.Hour),
+				NextPublishedAt: timeAgo(20 * time.Minute),
+			}),
+			wantActiveKeyID: "pinniped-supervisor-key",
+			wantJWKSKeyIDs:  []string{"pinniped-supervisor-key"},
+			wantState:       &keyRotationState{ActivatedAt: *timeAgo(30 * 24 * time.Hour)},
+		},
+		{
+			name: "the key rotation interval is not almost over yet",
+			federationDomain: newFederationDomain(&configv1alpha1.FederationDomainSigningSpec{
+				KeyRotation: &configv1alpha1.FederationDomainKeyRotationSpec{IntervalSeconds: 86400, GracePeriodSeconds: 1800},
+			}, nil),
+			secret:           newSecret(12*time.Hour, activeJWK, nil, nil, nil),
+			wantRequeueAfter: 11*time.Hour + 30*time.Minute,
+		},
+		{
+			name: "the key rotation interval is almost over, so the next key is published",
+			federationDomain: newFederationDomain(&configv1alpha1.FederationDomainSigningSpec{
+				KeyRotation: &configv1alpha1.FederationDomainKeyRotationSpec{IntervalSeconds: 86400, GracePeriodSeconds: 1800},
+			}, nil),
+			secret: newSecret(30*24*time.Hour, activeJWK, nil, nil, &keyRotationState{
+				ActivatedAt: *timeAgo(23*time.Hour + 30*time.Minute),
+			}),
+			wantGeneratedAlgorithm: "ES256",
+			wantActiveKeyID:        "pinniped-supervisor-key",
+			wantNextKeyID:          nextKeyID,
+			wantNextAlgorithm:      "ES256",
+			wantJWKSKeyIDs:         []string{"pinniped-supervisor-key", nextKeyID},
+			wantState: &keyRotationState{
+				ActivatedAt:     *timeAgo(23*time.Hour + 30*time.Minute),
+				NextPublishedAt: timeAgo(0),
+			},
+			wantRequeueAfter: 30 * time.Minute,
+		},
+		{
+			name: "an invalid key rotation is ignored",
+			federationDomain: newFederationDomain(&configv1alpha1.FederationDomainSigningSpec{
+				KeyRotation: &configv1alpha1.FederationDomainKeyRotationSpec{IntervalSeconds: 7200, GracePeriodSeconds: 7200},
+			}, nil),
+			secret: newSecret(30*24*time.Hour, activeJWK, nil, nil, nil),
+		},
+		{
+			name:             "all of the ID tokens signed by a retired key have expired, so it is removed from the JWKS",
+			federationDomain: newFederationDomain(nil, nil),
+			secret: newSecret(30*24*time.Hour, activeJWK, nil, []jose.JSONWebKey{oldJWK}, &keyRotationState{
+				ActivatedAt: *timeAgo(10 * time.Minute),
+				RetiredAt:   map[string]metav1.Time{"some-old-key": *timeAgo(7 * time.Minute)},
+			}),
+			wantActiveKeyID: "pinniped-supervisor-key",
+			wantJWKSKeyIDs:  []string{"pinniped-supervisor-key"},
+			wantState:       &keyRotationState{ActivatedAt: *timeAgo(10 * time.Minute)},
+		},
+		{
+			name:             "a retired key is kept in the JWKS for a while after the ID tokens signed by it have expired, to allow for clock skew",
+			federationDomain: newFederationDomain(nil, nil),
+			secret: newSecret(30*24*time.Hour, activeJWK, nil, []jose.JSONWebKey{oldJWK}, &keyRotationState{
+				ActivatedAt: *timeAgo(10 * time.Minute),
+				RetiredAt:   map[string]metav1.Time{"some-old-key": *timeAgo(3 * time.Minute)},
+			}),
+			wantRequeueAfter: 4 * time.Minute,
+		},
+		{
+			name:             "a retired key is kept in the JWKS for the configured ID token lifetime",
+			federationDomain: newFederationDomain(nil, &configv1alpha1.FederationDomainTokensSpec{IDTokenLifetimeSeconds: 600}),
+			secret: newSecret(30*24*time.Hour, activeJWK, nil, []jose.JSONWebKey{oldJWK}, &keyRotationState{
+				ActivatedAt: *timeAgo(10 * time.Minute),
+				RetiredAt:   map[string]metav1.Time{"some-old-key": *timeAgo(2 * time.Minute)},
+			}),
+			wantRequeueAfter: 13 * time.Minute,
+		},
+		{
+			name:             "update secret fails",
+			federationDomain: newFederationDomain(&configv1alpha1.FederationDomainSigningSpec{Algorithm: "EdDSA"}, nil),
+			secret:           newSecret(30*24*time.Hour, activeJWK, nil, nil, nil),
+			configKubeClient: func(client *kubernetesfake.Clientset) {
+				client.PrependReactor("update", "secrets", func(_ kubetesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("some update error")
+				})
+			},
+			wantGeneratedAlgorithm: "EdDSA",
+			wantError:              "cannot update secret: some update error",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// We shouldn't run this test in parallel since it messes with a global function (generateKey).
+			var generatedAlgorithm configv1alpha1.FederationDomainSigningAlgorithm
+			generateKey = func(_ io.Reader, algorithm configv1alpha1.FederationDomainSigningAlgorithm) (interface{}, error) {
+				require.Empty(t, generatedAlgorithm, "generateKey should only be called once")
+				generatedAlgorithm = algorithm
+				return keysByAlgorithm[algorithm], nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			kubeAPIClient := kubernetesfake.NewSimpleClientset(test.secret)
+			kubeInformerClient := kubernetesfake.NewSimpleClientset(test.secret)
+			if test.configKubeClient != nil {
+				test.configKubeClient(kubeAPIClient)
+			}
+			pinnipedInformerClient := pinnipedfake.NewSimpleClientset(test.federationDomain)
+
+			kubeInformers := kubeinformers.NewSharedInformerFactory(kubeInformerClient, 0)
+			pinnipedInformers := pinnipedinformers.NewSharedInformerFactory(pinnipedInformerClient, 0)
+
+			c := NewJWKSWriterController(
+				nil,
+				clocktesting.NewFakeClock(now),
+				kubeAPIClient,
+				pinnipedfake.NewSimpleClientset(),
+				kubeInformers.Core().V1().Secrets(),
+				pinnipedInformers.Config().V1alpha1().FederationDomains(),
+				controllerlib.WithInformer,
+			)
+
+			// Must start informers before calling TestRunSynchronously().
+			kubeInformers.Start(ctx.Done())
+			pinnipedInformers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, c)
+
+			queue := &testQueue{t: t}
+			err := controllerlib.TestSync(t, c, controllerlib.Context{
+				Context: ctx,
+				Key:     controllerlib.Key{Namespace: namespace, Name: test.federationDomain.Name},
+				Queue:   queue,
+			})
+			require.Equal(t, test.wantGeneratedAlgorithm, generatedAlgorithm)
+			if test.wantError != "" {
+				require.EqualError(t, err, test.wantError)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, test.wantRequeueAfter != 0, queue.called)
+			if queue.called {
+				require.Equal(t, controllerlib.Key{Namespace: namespace, Name: test.federationDomain.Name}, queue.key)
+				require.Equal(t, test.wantRequeueAfter, queue.duration)
+			}
+
+			if test.wantState == nil {
+				require.Empty(t, kubeAPIClient.Actions())
+				return
+			}
+			require.Len(t, kubeAPIClient.Actions(), 1)
+			secret := kubeAPIClient.Actions()[0].(kubetesting.UpdateAction).GetObject().(*corev1.Secret)
+			require.True(t, isValid(secret))
+
+			var gotActiveJWK jose.JSONWebKey
+			require.NoError(t, json.Unmarshal(secret.Data["activeJWK"], &gotActiveJWK))
+			require.Equal(t, test.wantActiveKeyID, gotActiveJWK.KeyID)
+
+			if test.wantNextKeyID == "" {
+				require.NotContains(t, secret.Data, "nextJWK")
+			} else {
+				var gotNextJWK jose.JSONWebKey
+				require.NoError(t, json.Unmarshal(secret.Data["nextJWK"], &gotNextJWK))
+				require.Equal(t, test.wantNextKeyID, gotNextJWK.KeyID)
+				require.Equal(t, test.wantNextAlgorithm, gotNextJWK.Algorithm)
+				wantNextKey := keysByAlgorithm[configv1alpha1.FederationDomainSigningAlgorithm(test.wantNextAlgorithm)]
+				require.Equal(t, wantNextKey.(crypto.Signer).Public(), gotNextJWK.Public().Key)
+			}
+
+			var gotJWKS jose.JSONWebKeySet
+			require.NoError(t, json.Unmarshal(secret.Data["jwks"], &gotJWKS))
+			gotJWKSKeyIDs := make([]string, 0, len(gotJWKS.Keys))
+			for _, jwk := range gotJWKS.Keys {
+				gotJWKSKeyIDs = append(gotJWKSKeyIDs, jwk.KeyID)
+			}
+			require.Equal(t, test.wantJWKSKeyIDs, gotJWKSKeyIDs)
+
+			wantStateJSON, err := json.Marshal(test.wantState)
+			require.NoError(t, err)
+			require.JSONEq(t, string(wantStateJSON), string(secret.Data["keyRotation"]))
+		})
+	}
+}
+
 func readJWKJSON(t *testing.T, path string) []byte {
 	t.Helper()
 
@@ -759,3 +1286,175 @@ func readJWKJSON(t *testing.T, path string) []byte {
 }
 
 func boolPtr(b bool) *bool { return &b }
+
+type testQueue struct {
+	t *testing.T
+
+	called   bool
+	key      controllerlib.Key
+	duration time.Duration
+
+	controllerlib.Queue // panic if any other methods called
+}
+
+func (q *testQueue) AddAfter(key controllerlib.Key, duration time.Duration) {
+	q.t.Helper()
+
+	require.False(q.t, q.called, "AddAfter should only be called once")
+
+	q.called = true
+	q.key = key
+	q.duration = duration
+}
+
+func TestExternalSignerClient(t *testing.T) {
+	t.Parallel()
+
+	const namespace = "some-namespace"
+
+	ca, err := certauthority.New("some-ca", time.Hour)
+	require.NoError(t, err)
+	clientCertPEM, clientKeyPEM, err := ca.IssueClientCertPEM("some-supervisor", nil, time.Hour)
+	require.NoError(t, err)
+
+	// The signers respond with 200 when the Supervisor authenticated, and try to redirect it otherwise.
+	tokenSigner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer some-token" {
+			http.Redirect(w, r, "/somewhere-else", http.StatusFound)
+		}
+	}))
+	t.Cleanup(tokenSigner.Close)
+	tlsSigner := tlsserver.TLSTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), func(server *httptest.Server) {
+		server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+		server.TLS.ClientCAs = ca.Pool()
+	})
+	tlsSignerCA := base64.StdEncoding.EncodeToString(tlsserver.TLSTestServerCA(tlsSigner))
+
+	tokenSecret := func(token string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "some-credentials", Namespace: namespace},
+			Type:       "secrets.pinniped.dev/external-signer-token",
+			Data:       map[string][]byte{"token": []byte(token)},
+		}
+	}
+	tlsSecret := func(certPEM, keyPEM []byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "some-credentials", Namespace: namespace},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": certPEM, "tls.key": keyPEM},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		spec      configv1alpha1.FederationDomainExternalSignerSpec
+		secret    *corev1.Secret
+		wantErr   string
+		wantReach string
+		wantCode  int
+	}{
+		{
+			name:      "bearer token to a signer on a loopback address",
+			spec:      configv1alpha1.FederationDomainExternalSignerSpec{URL: tokenSigner.URL},
+			secret:    tokenSecret("some-token"),
+			wantReach: tokenSigner.URL,
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "wrong bearer token, and the redirect of the signer is not followed",
+			spec:      configv1alpha1.FederationDomainExternalSignerSpec{URL: tokenSigner.URL},
+			secret:    tokenSecret("some-other-token"),
+			wantReach: tokenSigner.URL,
+			wantCode:  http.StatusFound,
+		},
+		{
+			name:      "client certificate to a signer using https",
+			spec:      configv1alpha1.FederationDomainExternalSignerSpec{URL: tlsSigner.URL, CertificateAuthorityData: tlsSignerCA},
+			secret:    tlsSecret(clientCertPEM, clientKeyPEM),
+			wantReach: tlsSigner.URL,
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:   "http URL of localhost",
+			spec:   configv1alpha1.FederationDomainExternalSignerSpec{URL: "http://localhost:8443/sign"},
+			secret: tokenSecret("some-token"),
+		},
+		{
+			name:   "http URL of the IPv6 loopback address",
+			spec:   configv1alpha1.FederationDomainExternalSignerSpec{URL: "http://[::1]:8443/sign"},
+			secret: tokenSecret("some-token"),
+		},
+		{
+			name:    "http URL of a host which is not a loopback address",
+			spec:    configv1alpha1.FederationDomainExternalSignerSpec{URL: "http://signer.example.com/sign"},
+			secret:  tokenSecret("some-token"),
+			wantErr: "externalSigner.url is invalid: must be an https URL, or an http URL of a loopback address",
+		},
+		{
+			name:    "client certificate with an http URL",
+			spec:    configv1alpha1.FederationDomainExternalSignerSpec{URL: "http://127.0.0.1:8443/sign"},
+			secret:  tlsSecret(clientCertPEM, clientKeyPEM),
+			wantErr: `credentials secret "some-credentials" of type "kubernetes.io/tls" requires an https URL`,
+		},
+		{
+			name:    "invalid client certificate",
+			spec:    configv1alpha1.FederationDomainExternalSignerSpec{URL: "https://signer.example.com/sign"},
+			secret:  tlsSecret(clientCertPEM, []byte("not a key")),
+			wantErr: `credentials secret "some-credentials" does not contain a valid client certificate and key: tls: failed to find any PEM data in key input`,
+		},
+		{
+			name:    "missing token",
+			spec:    configv1alpha1.FederationDomainExternalSignerSpec{URL: "https://signer.example.com/sign"},
+			secret:  tokenSecret(""),
+			wantErr: `credentials secret "some-credentials" is missing required key "token"`,
+		},
+		{
+			name: "credentials secret of the wrong type",
+			spec: configv1alpha1.FederationDomainExternalSignerSpec{URL: "https://signer.example.com/sign"},
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "some-credentials", Namespace: namespace},
+				Type:       "some-other-type",
+			},
+			wantErr: `credentials secret "some-credentials" has wrong type "some-other-type" (should be "kubernetes.io/tls" or "secrets.pinniped.dev/external-signer-token")`,
+		},
+		{
+			name:    "missing credentials secret",
+			spec:    configv1alpha1.FederationDomainExternalSignerSpec{URL: "https://signer.example.com/sign"},
+			wantErr: `cannot get externalSigner.credentialsSecretName: secret "some-credentials" not found`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			kubeInformerClient := kubernetesfake.NewSimpleClientset()
+			if test.secret != nil {
+				require.NoError(t, kubeInformerClient.Tracker().Add(test.secret))
+			}
+			kubeInformers := kubeinformers.NewSharedInformerFactory(kubeInformerClient, 0)
+			secrets := kubeInformers.Core().V1().Secrets().Lister().Secrets(namespace)
+			kubeInformers.Start(ctx.Done())
+			kubeInformers.WaitForCacheSync(ctx.Done())
+
+			test.spec.CredentialsSecretName = "some-credentials"
+			client, err := externalSignerClient(secrets, &test.spec)
+			if test.wantErr != "" {
+				require.EqualError(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, client)
+
+			if test.wantReach != "" {
+				response, err := client.Post(test.wantReach, "application/json", bytes.NewReader([]byte("{}")))
+				require.NoError(t, err)
+				require.NoError(t, response.Body.Close())
+				require.Equal(t, test.wantCode, response.StatusCode)
+			}
+		})
+	}
+}
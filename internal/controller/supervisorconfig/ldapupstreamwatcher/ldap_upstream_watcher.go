@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -69,6 +70,10 @@ func (s *ldapUpstreamGenericLDAPSpec) BindSecretName() string {
 	return s.ldapIdentityProvider.Spec.Bind.SecretName
 }
 
+func (s *ldapUpstreamGenericLDAPSpec) BindMethod() v1alpha1.BindMethod {
+	return s.ldapIdentityProvider.Spec.Bind.Method
+}
+
 func (s *ldapUpstreamGenericLDAPSpec) UserSearch() upstreamwatchers.UpstreamGenericLDAPUserSearch {
 	return &ldapUpstreamGenericLDAPUserSearch{s.ldapIdentityProvider.Spec.UserSearch}
 }
@@ -136,6 +141,8 @@ type ldapWatcherController struct {
 	cache                        UpstreamLDAPIdentityProviderICache
 	validatedSettingsCache       upstreamwatchers.ValidatedSettingsCacheI
 	ldapDialer                   upstreamldap.LDAPDialer
+	connectionPools              *upstreamldap.ConnectionPools
+	groupCaches                  *upstreamldap.GroupCaches
 	client                       pinnipedclientset.Interface
 	ldapIdentityProviderInformer idpinformers.LDAPIdentityProviderInformer
 	secretInformer               corev1informers.SecretInformer
@@ -176,6 +183,8 @@ func newInternal(
 		cache:                        idpCache,
 		validatedSettingsCache:       validatedSettingsCache,
 		ldapDialer:                   ldapDialer,
+		connectionPools:              upstreamldap.NewConnectionPools(upstreamldap.ConnectionPoolConfig{UpstreamType: "ldap"}),
+		groupCaches:                  upstreamldap.NewGroupCaches(),
 		client:                       client,
 		ldapIdentityProviderInformer: ldapIdentityProviderInformer,
 		secretInformer:               secretInformer,
@@ -189,7 +198,10 @@ func newInternal(
 		),
 		withInformer(
 			secretInformer,
-			pinnipedcontroller.MatchAnySecretOfTypeFilter(upstreamwatchers.LDAPBindAccountSecretType, pinnipedcontroller.SingletonQueue()),
+			pinnipedcontroller.MatchAnySecretOfTypesFilter(
+				[]corev1.SecretType{upstreamwatchers.LDAPBindAccountSecretType, upstreamwatchers.LDAPBindAccountClientCertificateSecretType},
+				pinnipedcontroller.SingletonQueue(),
+			),
 			controllerlib.InformerOption{},
 		),
 	)
@@ -215,6 +227,7 @@ func (c *ldapWatcherController) Sync(ctx controllerlib.Context) error {
 	}
 
 	c.cache.SetLDAPIdentityProviders(validatedUpstreams)
+	upstreamwatchers.PruneConnectionPools(c.connectionPools, c.groupCaches, validatedUpstreams)
 
 	if requeue {
 		return controllerlib.ErrSyntheticRequeue
@@ -223,26 +236,10 @@ func (c *ldapWatcherController) Sync(ctx controllerlib.Context) error {
 }
 
 func (c *ldapWatcherController) validateUpstream(ctx context.Context, upstream *v1alpha1.LDAPIdentityProvider) (p provider.UpstreamLDAPIdentityProviderI, requeue bool) {
-	spec := upstream.Spec
-
-	config := &upstreamldap.ProviderConfig{
-		Name:        upstream.Name,
-		ResourceUID: upstream.UID,
-		Host:        spec.Host,
-		UserSearch: upstreamldap.UserSearchConfig{
-			Base:              spec.UserSearch.Base,
-			Filter:            spec.UserSearch.Filter,
-			UsernameAttribute: spec.UserSearch.Attributes.Username,
-			UIDAttribute:      spec.UserSearch.Attributes.UID,
-		},
-		GroupSearch: upstreamldap.GroupSearchConfig{
-			Base:               spec.GroupSearch.Base,
-			Filter:             spec.GroupSearch.Filter,
-			GroupNameAttribute: spec.GroupSearch.Attributes.GroupName,
-			SkipGroupRefresh:   spec.GroupSearch.SkipGroupRefresh,
-		},
-		Dialer: c.ldapDialer,
-	}
+	config := upstreamwatchers.LDAPProviderConfig(upstream)
+	config.Dialer = c.ldapDialer
+	config.ConnectionPool = c.connectionPools.ForProvider(upstream.UID)
+	config.GroupCache = c.groupCaches.ForProvider(upstream.UID)
 
 	conditions := upstreamwatchers.ValidateGenericLDAP(ctx, &ldapUpstreamGenericLDAPImpl{*upstream}, c.secretInformer, c.validatedSettingsCache, config)
 
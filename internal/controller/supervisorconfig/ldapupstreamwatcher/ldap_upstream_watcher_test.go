@@ -20,6 +20,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
 
 	"go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
 	pinnipedfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
@@ -54,6 +55,16 @@ func TestLDAPUpstreamWatcherControllerFilterSecrets(t *testing.T) {
 			wantUpdate: true,
 			wantDelete: true,
 		},
+		{
+			name: "a secret of the right type for client certificate binds",
+			secret: &corev1.Secret{
+				Type:       corev1.SecretTypeTLS,
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+			wantAdd:    true,
+			wantUpdate: true,
+			wantDelete: true,
+		},
 		{
 			name: "a secret of the wrong type",
 			secret: &corev1.Secret{
@@ -171,6 +182,10 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 	testCABundle := testCA.Bundle()
 	testCABundleBase64Encoded := base64.StdEncoding.EncodeToString(testCABundle)
 
+	testClientCertPEM, testClientKeyPEM, err := testCA.IssueClientCertPEM("test-bind-client", nil, time.Hour)
+	require.NoError(t, err)
+	testClientCertSubject := "CN=test-bind-client"
+
 	validUpstream := &v1alpha1.LDAPIdentityProvider{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:       testName,
@@ -232,6 +247,12 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 	providerConfigForValidUpstreamWithStartTLS := &copyOfProviderConfigForValidUpstreamWithTLS
 	providerConfigForValidUpstreamWithStartTLS.ConnectionProtocol = upstreamldap.StartTLS
 
+	editedProviderConfigForValidUpstreamWithTLS := func(editFunc func(*upstreamldap.ProviderConfig)) *upstreamldap.ProviderConfig {
+		edited := *providerConfigForValidUpstreamWithTLS
+		editFunc(&edited)
+		return &edited
+	}
+
 	bindSecretValidTrueCondition := func(gen int64) v1alpha1.Condition {
 		return v1alpha1.Condition{
 			Type:               "BindSecretValid",
@@ -279,6 +300,16 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 			tlsConfigurationValidLoadedTrueCondition(gen),
 		}
 	}
+	validatedSettingsForValidUpstreamWithTLS := func(secretVersion string) map[string]upstreamwatchers.ValidatedSettings {
+		return map[string]upstreamwatchers.ValidatedSettings{testName: {
+			BindSecretResourceVersion: secretVersion,
+			LDAPConnectionProtocol:    upstreamldap.TLS,
+			UserSearchBase:            testUserSearchBase,
+			GroupSearchBase:           testGroupSearchBase,
+			IDPSpecGeneration:         1234,
+			ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration(secretVersion)),
+		}}
+	}
 
 	validBindUserSecret := func(secretVersion string) *corev1.Secret {
 		return &corev1.Secret{
@@ -288,6 +319,17 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 		}
 	}
 
+	validClientCertificateSecret := func(secretVersion string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: testSecretName, Namespace: testNamespace, ResourceVersion: secretVersion},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": testClientCertPEM, "tls.key": testClientKeyPEM},
+		}
+	}
+	upstreamUsingClientCertificate := editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+		upstream.Spec.Bind.Method = v1alpha1.BindMethodSASLExternal
+	})
+
 	tests := []struct {
 		name                     string
 		initialValidatedSettings map[string]upstreamwatchers.ValidatedSettings
@@ -354,6 +396,33 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 				},
 			}},
 		},
+		{
+			name: "neither host nor hostDiscovery is specified",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.Host = ""
+			})},
+			inputSecrets:       []runtime.Object{validBindUserSecret("4242")},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamldap.ProviderConfig{},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						{
+							Type:               "LDAPConnectionValid",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "HostNotSpecified",
+							Message:            "spec.host must be specified when spec.hostDiscovery is not specified",
+							ObservedGeneration: 1234,
+						},
+						tlsConfigurationValidLoadedTrueCondition(1234),
+					},
+				},
+			}},
+		},
 		{
 			name:           "secret has wrong type",
 			inputUpstreams: []runtime.Object{validUpstream},
@@ -409,6 +478,145 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 				},
 			}},
 		},
+		{
+			name:           "one valid upstream which binds using a client certificate",
+			inputUpstreams: []runtime.Object{upstreamUsingClientCertificate},
+			inputSecrets:   []runtime.Object{validClientCertificateSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and SASL EXTERNAL bind.
+				conn.EXPECT().ExternalBind().Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{
+				{
+					Name:                  testName,
+					ResourceUID:           testResourceUID,
+					Host:                  testHost,
+					ConnectionProtocol:    upstreamldap.TLS,
+					CABundle:              testCABundle,
+					BindUsername:          testClientCertSubject,
+					ClientCertificateData: testClientCertPEM,
+					ClientKeyData:         testClientKeyPEM,
+					UserSearch:            providerConfigForValidUpstreamWithTLS.UserSearch,
+					GroupSearch:           providerConfigForValidUpstreamWithTLS.GroupSearch,
+				},
+			},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						{
+							Type:               "LDAPConnectionValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message: fmt.Sprintf(
+								`successfully able to connect to "%s" and bind as user "%s" [validated with Secret "%s" at version "%s"]`,
+								testHost, testClientCertSubject, testSecretName, "4242"),
+							ObservedGeneration: 1234,
+						},
+						tlsConfigurationValidLoadedTrueCondition(1234),
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition: &v1alpha1.Condition{
+					Type:   "LDAPConnectionValid",
+					Status: "True",
+					Reason: "Success",
+					Message: fmt.Sprintf(
+						`successfully able to connect to "%s" and bind as user "%s" [validated with Secret "%s" at version "%s"]`,
+						testHost, testClientCertSubject, testSecretName, "4242"),
+				},
+			}},
+		},
+		{
+			name:               "client certificate bind with a secret of the wrong type",
+			inputUpstreams:     []runtime.Object{upstreamUsingClientCertificate},
+			inputSecrets:       []runtime.Object{validBindUserSecret("4242")},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamldap.ProviderConfig{},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []v1alpha1.Condition{
+						{
+							Type:               "BindSecretValid",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "SecretWrongType",
+							Message:            fmt.Sprintf(`referenced Secret "%s" has wrong type "kubernetes.io/basic-auth" (should be "kubernetes.io/tls")`, testSecretName),
+							ObservedGeneration: 1234,
+						},
+						tlsConfigurationValidLoadedTrueCondition(1234),
+					},
+				},
+			}},
+		},
+		{
+			name:           "client certificate secret is missing key",
+			inputUpstreams: []runtime.Object{upstreamUsingClientCertificate},
+			inputSecrets: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: testSecretName, Namespace: testNamespace},
+				Type:       corev1.SecretTypeTLS,
+				Data:       map[string][]byte{"tls.crt": testClientCertPEM},
+			}},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamldap.ProviderConfig{},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []v1alpha1.Condition{
+						{
+							Type:               "BindSecretValid",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "SecretMissingKeys",
+							Message:            fmt.Sprintf(`referenced Secret "%s" is missing required keys ["tls.crt" "tls.key"]`, testSecretName),
+							ObservedGeneration: 1234,
+						},
+						tlsConfigurationValidLoadedTrueCondition(1234),
+					},
+				},
+			}},
+		},
+		{
+			name:           "client certificate secret has a key which does not match the certificate",
+			inputUpstreams: []runtime.Object{upstreamUsingClientCertificate},
+			inputSecrets: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: testSecretName, Namespace: testNamespace},
+				Type:       corev1.SecretTypeTLS,
+				Data:       map[string][]byte{"tls.crt": testClientCertPEM, "tls.key": []byte("this is not a key")},
+			}},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamldap.ProviderConfig{},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []v1alpha1.Condition{
+						{
+							Type:               "BindSecretValid",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "SecretInvalidCertificate",
+							Message:            fmt.Sprintf(`referenced Secret "%s" does not contain a valid client certificate and key: tls: failed to find any PEM data in key input`, testSecretName),
+							ObservedGeneration: 1234,
+						},
+						tlsConfigurationValidLoadedTrueCondition(1234),
+					},
+				},
+			}},
+		},
 		{
 			name: "CertificateAuthorityData is not base64 encoded",
 			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
@@ -653,23 +861,29 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{},
 		},
 		{
-			name: "non-nil TLS configuration with empty CertificateAuthorityData is valid",
+			name: "with additional hosts, reports each host which could not be reached and caches the validated settings until the hosts should be tested again",
 			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
-				upstream.Spec.TLS.CertificateAuthorityData = ""
+				upstream.Spec.AdditionalHosts = []string{"ldap2.example.com:123", "ldap3.example.com:123"}
+				upstream.Spec.HostSelection = v1alpha1.HostSelectionRoundRobin
 			})},
 			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
 			setupMocks: func(conn *mockldapconn.MockConn) {
-				// Should perform a test dial and bind.
-				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
-				conn.EXPECT().Close().Times(1)
+				// Should perform a test dial and bind for each host which can be dialed.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(2)
+				conn.EXPECT().Close().Times(2)
+			},
+			dialErrors: map[string]error{
+				"ldap2.example.com:123": fmt.Errorf("some dial error"),
 			},
 			wantResultingCache: []*upstreamldap.ProviderConfig{
 				{
 					Name:               testName,
 					ResourceUID:        testResourceUID,
 					Host:               testHost,
+					AdditionalHosts:    []string{"ldap2.example.com:123", "ldap3.example.com:123"},
+					HostSelection:      upstreamldap.RoundRobin,
 					ConnectionProtocol: upstreamldap.TLS,
-					CABundle:           nil,
+					CABundle:           testCABundle,
 					BindUsername:       testBindUsername,
 					BindPassword:       testBindPassword,
 					UserSearch: upstreamldap.UserSearchConfig{
@@ -688,8 +902,23 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
 				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
 				Status: v1alpha1.LDAPIdentityProviderStatus{
-					Phase:      "Ready",
-					Conditions: allConditionsTrue(1234, "4242"),
+					Phase: "Ready",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						{
+							Type:               "LDAPConnectionValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "SomeHostsUnavailable",
+							Message: fmt.Sprintf(
+								`successfully able to connect to "%s", "%s" and bind as user "%s" [validated with Secret "%s" at version "%s"], `+
+									`but not to the other hosts: "%s": error dialing host "%s": some dial error`,
+								testHost, "ldap3.example.com:123", testBindUsername, testSecretName, "4242",
+								"ldap2.example.com:123", "ldap2.example.com:123"),
+							ObservedGeneration: 1234,
+						},
+						tlsConfigurationValidLoadedTrueCondition(1234),
+					},
 				},
 			}},
 			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
@@ -698,72 +927,57 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 				UserSearchBase:            testUserSearchBase,
 				GroupSearchBase:           testGroupSearchBase,
 				IDPSpecGeneration:         1234,
-				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+				ConnectionValidCondition: &v1alpha1.Condition{
+					Type:   "LDAPConnectionValid",
+					Status: "True",
+					Reason: "SomeHostsUnavailable",
+					Message: fmt.Sprintf(
+						`successfully able to connect to "%s", "%s" and bind as user "%s" [validated with Secret "%s" at version "%s"], `+
+							`but not to the other hosts: "%s": error dialing host "%s": some dial error`,
+						testHost, "ldap3.example.com:123", testBindUsername, testSecretName, "4242",
+						"ldap2.example.com:123", "ldap2.example.com:123"),
+				},
+				RetestAfter: now.Add(5 * time.Minute),
 			}},
 		},
 		{
-			name: "one valid upstream and one invalid upstream updates the cache to include only the valid upstream",
-			inputUpstreams: []runtime.Object{validUpstream, editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
-				upstream.Name = "other-upstream"
-				upstream.Generation = 42
-				upstream.Spec.Bind.SecretName = "non-existent-secret"
-				upstream.UID = "other-uid"
+			name: "with additional hosts, reports the error of each host when none of the hosts can be reached",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.AdditionalHosts = []string{"ldap2.example.com:123"}
 			})},
 			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
 			setupMocks: func(conn *mockldapconn.MockConn) {
-				// Should perform a test dial and bind for the one valid upstream configuration.
-				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
-				conn.EXPECT().Close().Times(1)
+				// All dials fail, so there should be no bind.
 			},
-			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
-			wantResultingCache: []*upstreamldap.ProviderConfig{providerConfigForValidUpstreamWithTLS},
-			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{
+			dialErrors: map[string]error{
+				testHost:                fmt.Errorf("some dial error"),
+				"ldap2.example.com:123": fmt.Errorf("some other dial error"),
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{
+				// even though the connection test failed, still loads into the cache because it is treated like a warning
 				{
-					ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "other-upstream", Generation: 42, UID: "other-uid"},
-					Status: v1alpha1.LDAPIdentityProviderStatus{
-						Phase: "Error",
-						Conditions: []v1alpha1.Condition{
-							{
-								Type:               "BindSecretValid",
-								Status:             "False",
-								LastTransitionTime: now,
-								Reason:             "SecretNotFound",
-								Message:            fmt.Sprintf(`secret "%s" not found`, "non-existent-secret"),
-								ObservedGeneration: 42,
-							},
-							tlsConfigurationValidLoadedTrueCondition(42),
-						},
+					Name:               testName,
+					ResourceUID:        testResourceUID,
+					Host:               testHost,
+					AdditionalHosts:    []string{"ldap2.example.com:123"},
+					ConnectionProtocol: upstreamldap.TLS,
+					CABundle:           testCABundle,
+					BindUsername:       testBindUsername,
+					BindPassword:       testBindPassword,
+					UserSearch: upstreamldap.UserSearchConfig{
+						Base:              testUserSearchBase,
+						Filter:            testUserSearchFilter,
+						UsernameAttribute: testUsernameAttrName,
+						UIDAttribute:      testUIDAttrName,
 					},
-				},
-				{
-					ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
-					Status: v1alpha1.LDAPIdentityProviderStatus{
-						Phase:      "Ready",
-						Conditions: allConditionsTrue(1234, "4242"),
+					GroupSearch: upstreamldap.GroupSearchConfig{
+						Base:               testGroupSearchBase,
+						Filter:             testGroupSearchFilter,
+						GroupNameAttribute: testGroupNameAttrName,
 					},
 				},
 			},
-			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
-				BindSecretResourceVersion: "4242",
-				LDAPConnectionProtocol:    upstreamldap.TLS,
-				UserSearchBase:            testUserSearchBase,
-				GroupSearchBase:           testGroupSearchBase,
-				IDPSpecGeneration:         1234,
-				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
-			}},
-		},
-		{
-			name:           "when testing the connection to the LDAP server fails then the upstream is still added to the cache anyway (treated like a warning) but not the validated settings cache",
-			inputUpstreams: []runtime.Object{validUpstream},
-			inputSecrets:   []runtime.Object{validBindUserSecret("")},
-			setupMocks: func(conn *mockldapconn.MockConn) {
-				// Should perform a test dial and bind.
-				// Expect two calls to each of these: once for trying TLS and once for trying StartTLS.
-				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(2).Return(errors.New("some bind error"))
-				conn.EXPECT().Close().Times(2)
-			},
-			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
-			wantResultingCache: []*upstreamldap.ProviderConfig{providerConfigForValidUpstreamWithTLS},
+			wantErr: controllerlib.ErrSyntheticRequeue.Error(),
 			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
 				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
 				Status: v1alpha1.LDAPIdentityProviderStatus{
@@ -776,8 +990,10 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 							LastTransitionTime: now,
 							Reason:             "LDAPConnectionError",
 							Message: fmt.Sprintf(
-								`could not successfully connect to "%s" and bind as user "%s": error binding as "%s": some bind error`,
-								testHost, testBindUsername, testBindUsername),
+								`could not successfully connect to any of "%s", "%s" and bind as user "%s": `+
+									`"%s": error dialing host "%s": some dial error; "%s": error dialing host "%s": some other dial error`,
+								testHost, "ldap2.example.com:123", testBindUsername,
+								testHost, testHost, "ldap2.example.com:123", "ldap2.example.com:123"),
 							ObservedGeneration: 1234,
 						},
 						tlsConfigurationValidLoadedTrueCondition(1234),
@@ -787,7 +1003,141 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{},
 		},
 		{
-			name: "when the LDAP server connection was already validated using TLS for the current resource generation and secret version, then do not validate it again and keep using TLS",
+			name: "non-nil TLS configuration with empty CertificateAuthorityData is valid",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.TLS.CertificateAuthorityData = ""
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{
+				{
+					Name:               testName,
+					ResourceUID:        testResourceUID,
+					Host:               testHost,
+					ConnectionProtocol: upstreamldap.TLS,
+					CABundle:           nil,
+					BindUsername:       testBindUsername,
+					BindPassword:       testBindPassword,
+					UserSearch: upstreamldap.UserSearchConfig{
+						Base:              testUserSearchBase,
+						Filter:            testUserSearchFilter,
+						UsernameAttribute: testUsernameAttrName,
+						UIDAttribute:      testUIDAttrName,
+					},
+					GroupSearch: upstreamldap.GroupSearchConfig{
+						Base:               testGroupSearchBase,
+						Filter:             testGroupSearchFilter,
+						GroupNameAttribute: testGroupNameAttrName,
+					},
+				},
+			},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase:      "Ready",
+					Conditions: allConditionsTrue(1234, "4242"),
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+			}},
+		},
+		{
+			name: "one valid upstream and one invalid upstream updates the cache to include only the valid upstream",
+			inputUpstreams: []runtime.Object{validUpstream, editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+				upstream.Name = "other-upstream"
+				upstream.Generation = 42
+				upstream.Spec.Bind.SecretName = "non-existent-secret"
+				upstream.UID = "other-uid"
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind for the one valid upstream configuration.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamldap.ProviderConfig{providerConfigForValidUpstreamWithTLS},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "other-upstream", Generation: 42, UID: "other-uid"},
+					Status: v1alpha1.LDAPIdentityProviderStatus{
+						Phase: "Error",
+						Conditions: []v1alpha1.Condition{
+							{
+								Type:               "BindSecretValid",
+								Status:             "False",
+								LastTransitionTime: now,
+								Reason:             "SecretNotFound",
+								Message:            fmt.Sprintf(`secret "%s" not found`, "non-existent-secret"),
+								ObservedGeneration: 42,
+							},
+							tlsConfigurationValidLoadedTrueCondition(42),
+						},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+					Status: v1alpha1.LDAPIdentityProviderStatus{
+						Phase:      "Ready",
+						Conditions: allConditionsTrue(1234, "4242"),
+					},
+				},
+			},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+			}},
+		},
+		{
+			name:           "when testing the connection to the LDAP server fails then the upstream is still added to the cache anyway (treated like a warning) but not the validated settings cache",
+			inputUpstreams: []runtime.Object{validUpstream},
+			inputSecrets:   []runtime.Object{validBindUserSecret("")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				// Expect two calls to each of these: once for trying TLS and once for trying StartTLS.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(2).Return(errors.New("some bind error"))
+				conn.EXPECT().Close().Times(2)
+			},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamldap.ProviderConfig{providerConfigForValidUpstreamWithTLS},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						{
+							Type:               "LDAPConnectionValid",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "LDAPConnectionError",
+							Message: fmt.Sprintf(
+								`could not successfully connect to "%s" and bind as user "%s": error binding as "%s": some bind error`,
+								testHost, testBindUsername, testBindUsername),
+							ObservedGeneration: 1234,
+						},
+						tlsConfigurationValidLoadedTrueCondition(1234),
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{},
+		},
+		{
+			name: "when the LDAP server connection was already validated using TLS for the current resource generation and secret version, then do not validate it again and keep using TLS",
 			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
 				upstream.Generation = 1234
 				upstream.Status.Conditions = []v1alpha1.Condition{
@@ -860,6 +1210,46 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
 			}},
 		},
+		{
+			name: "when the LDAP server connection was validated while some hosts were unavailable and it is time to test them again, then try to validate it again",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+				upstream.Generation = 1234
+				upstream.Status.Conditions = []v1alpha1.Condition{
+					ldapConnectionValidTrueCondition(1234, "4242"),
+				}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			initialValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+				RetestAfter:               now.Time,
+			}},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{providerConfigForValidUpstreamWithTLS},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase:      "Ready",
+					Conditions: allConditionsTrue(1234, "4242"),
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+			}},
+		},
 		{
 			name: "when the LDAP server connection was validated for an older resource generation, then try to validate it again",
 			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
@@ -1115,6 +1505,379 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
 			}},
 		},
+		{
+			name: "nested group search without a max depth uses the default max depth",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.GroupSearch.Nested = &v1alpha1.LDAPIdentityProviderNestedGroupSearch{}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{editedProviderConfigForValidUpstreamWithTLS(func(config *upstreamldap.ProviderConfig) {
+				config.GroupSearch.NestedGroupsMaxDepth = 10
+			})},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase:      "Ready",
+					Conditions: allConditionsTrue(1234, "4242"),
+				},
+			}},
+			wantValidatedSettings: validatedSettingsForValidUpstreamWithTLS("4242"),
+		},
+		{
+			name: "nested group search with a max depth",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.GroupSearch.Nested = &v1alpha1.LDAPIdentityProviderNestedGroupSearch{MaxDepth: 3}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{editedProviderConfigForValidUpstreamWithTLS(func(config *upstreamldap.ProviderConfig) {
+				config.GroupSearch.NestedGroupsMaxDepth = 3
+			})},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase:      "Ready",
+					Conditions: allConditionsTrue(1234, "4242"),
+				},
+			}},
+			wantValidatedSettings: validatedSettingsForValidUpstreamWithTLS("4242"),
+		},
+		{
+			name: "additional claims are mapped from attributes",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.UserSearch.Attributes.AdditionalClaims = []v1alpha1.AdditionalClaimMapping{
+					{Claim: "email", Attribute: "mail"},
+					{Claim: "name", Attribute: "displayName"},
+				}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{
+				{
+					Name:               testName,
+					ResourceUID:        testResourceUID,
+					Host:               testHost,
+					ConnectionProtocol: upstreamldap.TLS,
+					CABundle:           testCABundle,
+					BindUsername:       testBindUsername,
+					BindPassword:       testBindPassword,
+					UserSearch: upstreamldap.UserSearchConfig{
+						Base:                      testUserSearchBase,
+						Filter:                    testUserSearchFilter,
+						UsernameAttribute:         testUsernameAttrName,
+						UIDAttribute:              testUIDAttrName,
+						AdditionalClaimAttributes: map[string]string{"email": "mail", "name": "displayName"},
+					},
+					GroupSearch: upstreamldap.GroupSearchConfig{
+						Base:               testGroupSearchBase,
+						Filter:             testGroupSearchFilter,
+						GroupNameAttribute: testGroupNameAttrName,
+					},
+				},
+			},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						ldapConnectionValidTrueCondition(1234, "4242"),
+						{
+							Type:               "TLSConfigurationValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "loaded TLS configuration",
+							ObservedGeneration: 1234,
+						},
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+			}},
+		},
+		{
+			name: "group search cache without a TTL uses the default TTL",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.GroupSearch.Cache = &v1alpha1.GroupSearchCacheSpec{}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{
+				{
+					Name:               testName,
+					ResourceUID:        testResourceUID,
+					Host:               testHost,
+					ConnectionProtocol: upstreamldap.TLS,
+					CABundle:           testCABundle,
+					BindUsername:       testBindUsername,
+					BindPassword:       testBindPassword,
+					UserSearch: upstreamldap.UserSearchConfig{
+						Base:              testUserSearchBase,
+						Filter:            testUserSearchFilter,
+						UsernameAttribute: testUsernameAttrName,
+						UIDAttribute:      testUIDAttrName,
+					},
+					GroupSearch: upstreamldap.GroupSearchConfig{
+						Base:               testGroupSearchBase,
+						Filter:             testGroupSearchFilter,
+						GroupNameAttribute: testGroupNameAttrName,
+						Cache:              upstreamldap.GroupCacheConfig{TTL: 5 * time.Minute},
+					},
+				},
+			},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						ldapConnectionValidTrueCondition(1234, "4242"),
+						{
+							Type:               "TLSConfigurationValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "loaded TLS configuration",
+							ObservedGeneration: 1234,
+						},
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+			}},
+		},
+		{
+			name: "group search cache with a TTL and max size",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.GroupSearch.Cache = &v1alpha1.GroupSearchCacheSpec{TTLSeconds: 60, MaxSize: 50}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{
+				{
+					Name:               testName,
+					ResourceUID:        testResourceUID,
+					Host:               testHost,
+					ConnectionProtocol: upstreamldap.TLS,
+					CABundle:           testCABundle,
+					BindUsername:       testBindUsername,
+					BindPassword:       testBindPassword,
+					UserSearch: upstreamldap.UserSearchConfig{
+						Base:              testUserSearchBase,
+						Filter:            testUserSearchFilter,
+						UsernameAttribute: testUsernameAttrName,
+						UIDAttribute:      testUIDAttrName,
+					},
+					GroupSearch: upstreamldap.GroupSearchConfig{
+						Base:               testGroupSearchBase,
+						Filter:             testGroupSearchFilter,
+						GroupNameAttribute: testGroupNameAttrName,
+						Cache:              upstreamldap.GroupCacheConfig{TTL: time.Minute, MaxSize: 50},
+					},
+				},
+			},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						ldapConnectionValidTrueCondition(1234, "4242"),
+						{
+							Type:               "TLSConfigurationValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "loaded TLS configuration",
+							ObservedGeneration: 1234,
+						},
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+			}},
+		},
+		{
+			name: "account status checks are configured, with the default password expiration warning period",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.AccountStatus = v1alpha1.LDAPIdentityProviderAccountStatus{
+					DisabledAttribute:           "nsAccountLock",
+					DisabledValues:              []string{"TRUE", "1"},
+					AccountExpirationAttribute:  "krbPrincipalExpiration",
+					PasswordExpirationAttribute: "krbPasswordExpiration",
+					PasswordPolicyControl:       true,
+				}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{
+				{
+					Name:               testName,
+					ResourceUID:        testResourceUID,
+					Host:               testHost,
+					ConnectionProtocol: upstreamldap.TLS,
+					CABundle:           testCABundle,
+					BindUsername:       testBindUsername,
+					BindPassword:       testBindPassword,
+					UserSearch: upstreamldap.UserSearchConfig{
+						Base:              testUserSearchBase,
+						Filter:            testUserSearchFilter,
+						UsernameAttribute: testUsernameAttrName,
+						UIDAttribute:      testUIDAttrName,
+					},
+					GroupSearch: upstreamldap.GroupSearchConfig{
+						Base:               testGroupSearchBase,
+						Filter:             testGroupSearchFilter,
+						GroupNameAttribute: testGroupNameAttrName,
+					},
+					AccountStatus: upstreamldap.AccountStatusConfig{
+						DisabledAttribute:               "nsAccountLock",
+						DisabledValues:                  []string{"TRUE", "1"},
+						AccountExpirationAttribute:      "krbPrincipalExpiration",
+						PasswordExpirationAttribute:     "krbPasswordExpiration",
+						PasswordExpirationWarningPeriod: 14 * 24 * time.Hour,
+						PasswordPolicyControl:           true,
+					},
+				},
+			},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						ldapConnectionValidTrueCondition(1234, "4242"),
+						{
+							Type:               "TLSConfigurationValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "loaded TLS configuration",
+							ObservedGeneration: 1234,
+						},
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+			}},
+		},
+		{
+			name: "account status checks are configured with a password expiration warning period",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *v1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.AccountStatus = v1alpha1.LDAPIdentityProviderAccountStatus{
+					PasswordExpirationAttribute:   "krbPasswordExpiration",
+					PasswordExpirationWarningDays: 3,
+				}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{
+				{
+					Name:               testName,
+					ResourceUID:        testResourceUID,
+					Host:               testHost,
+					ConnectionProtocol: upstreamldap.TLS,
+					CABundle:           testCABundle,
+					BindUsername:       testBindUsername,
+					BindPassword:       testBindPassword,
+					UserSearch: upstreamldap.UserSearchConfig{
+						Base:              testUserSearchBase,
+						Filter:            testUserSearchFilter,
+						UsernameAttribute: testUsernameAttrName,
+						UIDAttribute:      testUIDAttrName,
+					},
+					GroupSearch: upstreamldap.GroupSearchConfig{
+						Base:               testGroupSearchBase,
+						Filter:             testGroupSearchFilter,
+						GroupNameAttribute: testGroupNameAttrName,
+					},
+					AccountStatus: upstreamldap.AccountStatusConfig{
+						PasswordExpirationAttribute:     "krbPasswordExpiration",
+						PasswordExpirationWarningPeriod: 3 * 24 * time.Hour,
+					},
+				},
+			},
+			wantResultingUpstreams: []v1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: v1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []v1alpha1.Condition{
+						bindSecretValidTrueCondition(1234),
+						ldapConnectionValidTrueCondition(1234, "4242"),
+						{
+							Type:               "TLSConfigurationValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "loaded TLS configuration",
+							ObservedGeneration: 1234,
+						},
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+			}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1159,6 +1922,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 					ValidatedSettingsByName: map[string]upstreamwatchers.ValidatedSettings{},
 				}
 			}
+			validatedSettingsCache.Clock = clocktesting.NewFakeClock(now.Time)
 
 			controller := newInternal(
 				cache,
@@ -1193,6 +1957,11 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 				// The dialer that was passed in to the controller's constructor should always have been
 				// passed through to the provider.
 				copyOfExpectedValueForResultingCache.Dialer = dialer
+				// Each provider should get the connection pool which belongs to its upstream.
+				require.NotNil(t, actualIDP.GetConfig().ConnectionPool)
+				copyOfExpectedValueForResultingCache.ConnectionPool = actualIDP.GetConfig().ConnectionPool
+				require.NotNil(t, actualIDP.GetConfig().GroupCache)
+				copyOfExpectedValueForResultingCache.GroupCache = actualIDP.GetConfig().GroupCache
 				require.Equal(t, copyOfExpectedValueForResultingCache, actualIDP.GetConfig())
 			}
 
@@ -6,6 +6,7 @@ package oidcupstreamwatcher
 
 import (
 	"context"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"fmt"
@@ -48,6 +49,12 @@ const (
 
 	clientIDDataKey     = "clientID"
 	clientSecretDataKey = "clientSecret"
+	privateKeyDataKey   = "privateKey"
+	privateKeyIDDataKey = "privateKeyID"
+	certificateDataKey  = "certificate"
+
+	// Constants related to the TLS client certificate Secret.
+	tlsClientCertificateSecretType = corev1.SecretTypeTLS
 
 	// Constants related to the OIDC provider discovery cache. These do not affect the cache of JWKS.
 	oidcValidatorCacheTTL = 15 * time.Minute
@@ -60,6 +67,8 @@ const (
 	reasonUnreachable             = "Unreachable"
 	reasonInvalidResponse         = "InvalidResponse"
 	reasonDisallowedParameterName = "DisallowedParameterName"
+	reasonInvalidAuthMethod       = "InvalidAuthenticationMethod"
+	reasonInvalidPrivateKey       = "SecretInvalidPrivateKey"
 	allParamNamesAllowedMsg       = "additionalAuthorizeParameters parameter names are allowed"
 
 	// Errors that are generated by our reconcile process.
@@ -101,24 +110,28 @@ type lruValidatorCacheEntry struct {
 	client   *http.Client
 }
 
-func (c *lruValidatorCache) getProvider(spec *v1alpha1.OIDCIdentityProviderSpec) (*oidc.Provider, *http.Client) {
-	if result, ok := c.cache.Get(c.cacheKey(spec)); ok {
+func (c *lruValidatorCache) getProvider(spec *v1alpha1.OIDCIdentityProviderSpec, clientCertificate *tls.Certificate) (*oidc.Provider, *http.Client) {
+	if result, ok := c.cache.Get(c.cacheKey(spec, clientCertificate)); ok {
 		entry := result.(*lruValidatorCacheEntry)
 		return entry.provider, entry.client
 	}
 	return nil, nil
 }
 
-func (c *lruValidatorCache) putProvider(spec *v1alpha1.OIDCIdentityProviderSpec, provider *oidc.Provider, client *http.Client) {
-	c.cache.Set(c.cacheKey(spec), &lruValidatorCacheEntry{provider: provider, client: client}, oidcValidatorCacheTTL)
+func (c *lruValidatorCache) putProvider(spec *v1alpha1.OIDCIdentityProviderSpec, clientCertificate *tls.Certificate, provider *oidc.Provider, client *http.Client) {
+	c.cache.Set(c.cacheKey(spec, clientCertificate), &lruValidatorCacheEntry{provider: provider, client: client}, oidcValidatorCacheTTL)
 }
 
-func (c *lruValidatorCache) cacheKey(spec *v1alpha1.OIDCIdentityProviderSpec) interface{} {
-	var key struct{ issuer, caBundle string }
+func (c *lruValidatorCache) cacheKey(spec *v1alpha1.OIDCIdentityProviderSpec, clientCertificate *tls.Certificate) interface{} {
+	var key struct{ issuer, caBundle, clientCertificate string }
 	key.issuer = spec.Issuer
 	if spec.TLS != nil {
 		key.caBundle = spec.TLS.CertificateAuthorityData
 	}
+	if clientCertificate != nil {
+		// The certificate is bound to its private key, so a new certificate is needed to change the private key.
+		key.clientCertificate = string(clientCertificate.Certificate[0])
+	}
 	return key
 }
 
@@ -129,8 +142,8 @@ type oidcWatcherController struct {
 	oidcIdentityProviderInformer idpinformers.OIDCIdentityProviderInformer
 	secretInformer               corev1informers.SecretInformer
 	validatorCache               interface {
-		getProvider(*v1alpha1.OIDCIdentityProviderSpec) (*oidc.Provider, *http.Client)
-		putProvider(*v1alpha1.OIDCIdentityProviderSpec, *oidc.Provider, *http.Client)
+		getProvider(*v1alpha1.OIDCIdentityProviderSpec, *tls.Certificate) (*oidc.Provider, *http.Client)
+		putProvider(*v1alpha1.OIDCIdentityProviderSpec, *tls.Certificate, *oidc.Provider, *http.Client)
 	}
 }
 
@@ -160,7 +173,10 @@ func New(
 		),
 		withInformer(
 			secretInformer,
-			pinnipedcontroller.MatchAnySecretOfTypeFilter(oidcClientSecretType, pinnipedcontroller.SingletonQueue()),
+			pinnipedcontroller.MatchAnySecretOfTypesFilter(
+				[]corev1.SecretType{oidcClientSecretType, tlsClientCertificateSecretType},
+				pinnipedcontroller.SingletonQueue(),
+			),
 			controllerlib.InformerOption{},
 		),
 	)
@@ -217,9 +233,10 @@ func (c *oidcWatcherController) validateUpstream(ctx controllerlib.Context, upst
 		ResourceUID:              upstream.UID,
 	}
 
+	secretCondition, clientCertificate := c.validateSecret(upstream, &result)
 	conditions := []*v1alpha1.Condition{
-		c.validateSecret(upstream, &result),
-		c.validateIssuer(ctx.Context, upstream, &result),
+		secretCondition,
+		c.validateIssuer(ctx.Context, upstream, clientCertificate, &result),
 	}
 	if len(rejectedAuthcodeAuthorizeParameters) > 0 {
 		conditions = append(conditions, &v1alpha1.Condition{
@@ -258,8 +275,9 @@ func (c *oidcWatcherController) validateUpstream(ctx controllerlib.Context, upst
 	return nil
 }
 
-// validateSecret validates the .spec.client.secretName field and returns the appropriate ClientCredentialsValid condition.
-func (c *oidcWatcherController) validateSecret(upstream *v1alpha1.OIDCIdentityProvider, result *upstreamoidc.ProviderConfig) *v1alpha1.Condition {
+// validateSecret validates the .spec.client.secretName and .spec.client.tlsClientCertificateSecretName fields and
+// returns the appropriate ClientCredentialsValid condition, along with the TLS client certificate, if there is one.
+func (c *oidcWatcherController) validateSecret(upstream *v1alpha1.OIDCIdentityProvider, result *upstreamoidc.ProviderConfig) (*v1alpha1.Condition, *tls.Certificate) {
 	secretName := upstream.Spec.Client.SecretName
 
 	// Fetch the Secret from informer cache.
@@ -270,7 +288,7 @@ func (c *oidcWatcherController) validateSecret(upstream *v1alpha1.OIDCIdentityPr
 			Status:  v1alpha1.ConditionFalse,
 			Reason:  upstreamwatchers.ReasonNotFound,
 			Message: err.Error(),
-		}
+		}, nil
 	}
 
 	// Validate the secret .type field.
@@ -280,10 +298,48 @@ func (c *oidcWatcherController) validateSecret(upstream *v1alpha1.OIDCIdentityPr
 			Status:  v1alpha1.ConditionFalse,
 			Reason:  upstreamwatchers.ReasonWrongType,
 			Message: fmt.Sprintf("referenced Secret %q has wrong type %q (should be %q)", secretName, secret.Type, oidcClientSecretType),
+		}, nil
+	}
+
+	// Validate the secret .data field for the authentication method.
+	var condition *v1alpha1.Condition
+	switch authMethod := upstream.Spec.Client.AuthenticationMethod; authMethod {
+	case "", v1alpha1.OIDCClientAuthenticationMethodClientSecret:
+		condition = validateClientSecret(secret, result)
+	case v1alpha1.OIDCClientAuthenticationMethodPrivateKeyJWT:
+		condition = validateClientPrivateKey(secret, result)
+	default:
+		condition = &v1alpha1.Condition{
+			Type:    typeClientCredentialsValid,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  reasonInvalidAuthMethod,
+			Message: fmt.Sprintf("authenticationMethod %q is not supported", authMethod),
 		}
 	}
+	if condition != nil {
+		return condition, nil
+	}
 
-	// Validate the secret .data field.
+	var clientCertificate *tls.Certificate
+	if secretName := upstream.Spec.Client.TLSClientCertificateSecretName; secretName != "" {
+		clientCertificate, condition = c.validateTLSClientCertificateSecret(upstream.Namespace, secretName)
+		if condition != nil {
+			return condition, nil
+		}
+	}
+
+	// If everything is valid, set the condition to true.
+	return &v1alpha1.Condition{
+		Type:    typeClientCredentialsValid,
+		Status:  v1alpha1.ConditionTrue,
+		Reason:  upstreamwatchers.ReasonSuccess,
+		Message: "loaded client credentials",
+	}, clientCertificate
+}
+
+// validateClientSecret loads the client ID and client secret from the Secret into the result, or returns a
+// condition describing why it could not.
+func validateClientSecret(secret *corev1.Secret, result *upstreamoidc.ProviderConfig) *v1alpha1.Condition {
 	clientID := secret.Data[clientIDDataKey]
 	clientSecret := secret.Data[clientSecretDataKey]
 	if len(clientID) == 0 || len(clientSecret) == 0 {
@@ -291,30 +347,99 @@ func (c *oidcWatcherController) validateSecret(upstream *v1alpha1.OIDCIdentityPr
 			Type:    typeClientCredentialsValid,
 			Status:  v1alpha1.ConditionFalse,
 			Reason:  upstreamwatchers.ReasonMissingKeys,
-			Message: fmt.Sprintf("referenced Secret %q is missing required keys %q", secretName, []string{clientIDDataKey, clientSecretDataKey}),
+			Message: fmt.Sprintf("referenced Secret %q is missing required keys %q", secret.Name, []string{clientIDDataKey, clientSecretDataKey}),
 		}
 	}
 
-	// If everything is valid, update the result and set the condition to true.
 	result.Config.ClientID = string(clientID)
 	result.Config.ClientSecret = string(clientSecret)
-	return &v1alpha1.Condition{
-		Type:    typeClientCredentialsValid,
-		Status:  v1alpha1.ConditionTrue,
-		Reason:  upstreamwatchers.ReasonSuccess,
-		Message: "loaded client credentials",
+	return nil
+}
+
+// validateClientPrivateKey loads the client ID and the signer of its client assertions from the Secret into the
+// result, or returns a condition describing why it could not.
+func validateClientPrivateKey(secret *corev1.Secret, result *upstreamoidc.ProviderConfig) *v1alpha1.Condition {
+	clientID := secret.Data[clientIDDataKey]
+	privateKey := secret.Data[privateKeyDataKey]
+	if len(clientID) == 0 || len(privateKey) == 0 {
+		return &v1alpha1.Condition{
+			Type:    typeClientCredentialsValid,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  upstreamwatchers.ReasonMissingKeys,
+			Message: fmt.Sprintf("referenced Secret %q is missing required keys %q", secret.Name, []string{clientIDDataKey, privateKeyDataKey}),
+		}
 	}
+
+	signer, err := upstreamoidc.NewClientAssertionSigner(privateKey, string(secret.Data[privateKeyIDDataKey]), secret.Data[certificateDataKey])
+	if err != nil {
+		return &v1alpha1.Condition{
+			Type:    typeClientCredentialsValid,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  reasonInvalidPrivateKey,
+			Message: fmt.Sprintf("referenced Secret %q does not contain a valid private key: %s", secret.Name, err.Error()),
+		}
+	}
+
+	result.Config.ClientID = string(clientID)
+	result.ClientAssertionSigner = signer
+	return nil
+}
+
+// validateTLSClientCertificateSecret loads the TLS client certificate from the Secret, or returns a condition
+// describing why it could not.
+func (c *oidcWatcherController) validateTLSClientCertificateSecret(namespace, secretName string) (*tls.Certificate, *v1alpha1.Condition) {
+	secret, err := c.secretInformer.Lister().Secrets(namespace).Get(secretName)
+	if err != nil {
+		return nil, &v1alpha1.Condition{
+			Type:    typeClientCredentialsValid,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  upstreamwatchers.ReasonNotFound,
+			Message: err.Error(),
+		}
+	}
+
+	if secret.Type != tlsClientCertificateSecretType {
+		return nil, &v1alpha1.Condition{
+			Type:    typeClientCredentialsValid,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  upstreamwatchers.ReasonWrongType,
+			Message: fmt.Sprintf("referenced Secret %q has wrong type %q (should be %q)", secretName, secret.Type, tlsClientCertificateSecretType),
+		}
+	}
+
+	certPEM := secret.Data[corev1.TLSCertKey]
+	keyPEM := secret.Data[corev1.TLSPrivateKeyKey]
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return nil, &v1alpha1.Condition{
+			Type:    typeClientCredentialsValid,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  upstreamwatchers.ReasonMissingKeys,
+			Message: fmt.Sprintf("referenced Secret %q is missing required keys %q", secretName, []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey}),
+		}
+	}
+
+	clientCertificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, &v1alpha1.Condition{
+			Type:    typeClientCredentialsValid,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  upstreamwatchers.ReasonInvalidCertificate,
+			Message: fmt.Sprintf("referenced Secret %q does not contain a valid client certificate and key: %s", secretName, err.Error()),
+		}
+	}
+	return &clientCertificate, nil
 }
 
 // validateIssuer validates the .spec.issuer field, performs OIDC discovery, and returns the appropriate OIDCDiscoverySucceeded condition.
-func (c *oidcWatcherController) validateIssuer(ctx context.Context, upstream *v1alpha1.OIDCIdentityProvider, result *upstreamoidc.ProviderConfig) *v1alpha1.Condition {
+// When there is a TLS client certificate, the HTTP Client presents it in all requests to the issuer.
+func (c *oidcWatcherController) validateIssuer(ctx context.Context, upstream *v1alpha1.OIDCIdentityProvider, clientCertificate *tls.Certificate, result *upstreamoidc.ProviderConfig) *v1alpha1.Condition {
 	// Get the provider and HTTP Client from cache if possible.
-	discoveredProvider, httpClient := c.validatorCache.getProvider(&upstream.Spec)
+	discoveredProvider, httpClient := c.validatorCache.getProvider(&upstream.Spec, clientCertificate)
 
 	// If the provider does not exist in the cache, do a fresh discovery lookup and save to the cache.
 	if discoveredProvider == nil {
 		var err error
-		httpClient, err = getClient(upstream)
+		httpClient, err = getClient(upstream, clientCertificate)
 		if err != nil {
 			return &v1alpha1.Condition{
 				Type:    typeOIDCDiscoverySucceeded,
@@ -345,7 +470,7 @@ func (c *oidcWatcherController) validateIssuer(ctx context.Context, upstream *v1
 		}
 
 		// Update the cache with the newly discovered value.
-		c.validatorCache.putProvider(&upstream.Spec, discoveredProvider, httpClient)
+		c.validatorCache.putProvider(&upstream.Spec, clientCertificate, discoveredProvider, httpClient)
 	}
 
 	// Get the revocation endpoint, if there is one. Many providers do not offer a revocation endpoint.
@@ -430,9 +555,9 @@ func (c *oidcWatcherController) updateStatus(ctx context.Context, upstream *v1al
 	}
 }
 
-func getClient(upstream *v1alpha1.OIDCIdentityProvider) (*http.Client, error) {
+func getClient(upstream *v1alpha1.OIDCIdentityProvider, clientCertificate *tls.Certificate) (*http.Client, error) {
 	if upstream.Spec.TLS == nil || upstream.Spec.TLS.CertificateAuthorityData == "" {
-		return defaultClientShortTimeout(nil), nil
+		return defaultClientShortTimeout(nil, clientCertificate), nil
 	}
 
 	bundle, err := base64.StdEncoding.DecodeString(upstream.Spec.TLS.CertificateAuthorityData)
@@ -445,11 +570,14 @@ func getClient(upstream *v1alpha1.OIDCIdentityProvider) (*http.Client, error) {
 		return nil, fmt.Errorf("spec.certificateAuthorityData is invalid: %w", upstreamwatchers.ErrNoCertificates)
 	}
 
-	return defaultClientShortTimeout(rootCAs), nil
+	return defaultClientShortTimeout(rootCAs, clientCertificate), nil
 }
 
-func defaultClientShortTimeout(rootCAs *x509.CertPool) *http.Client {
+func defaultClientShortTimeout(rootCAs *x509.CertPool, clientCertificate *tls.Certificate) *http.Client {
 	c := phttp.Default(rootCAs)
+	if clientCertificate != nil {
+		c = phttp.DefaultWithClientCertificate(rootCAs, *clientCertificate)
+	}
 	c.Timeout = time.Minute
 	return c
 }
@@ -0,0 +1,269 @@
+// Copyright 2021-2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamwatchers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/upstreamldap"
+)
+
+const (
+	// Default values for active directory config.
+	defaultActiveDirectoryUsernameAttributeName = "userPrincipalName"
+	defaultActiveDirectoryUIDAttributeName      = "objectGUID"
+
+	// By default this group name attribute is the sAMAccountName with special mapping.
+	// Each group will look like sAMAccountName + "@" + domain.
+	// For example if your group sAMAccountName is "mammals" and your domain is
+	// "activedirectory.example.com", it would be mammals@activedirectory.example.com.
+	// This is because sAMAccountName is only unique within a domain, not a forest.
+	defaultActiveDirectoryGroupNameAttributeName = "sAMAccountName"
+
+	// - is a person.
+	// - is not a computer.
+	// - is not shown in advanced view only (which would likely mean its a system created service account with advanced permissions).
+	// - either the sAMAccountName, the userPrincipalName or the mail attribute matches the input username.
+	// - the sAMAccountType is for a normal user account.
+	defaultActiveDirectoryUserSearchFilter = "(&(objectClass=person)(!(objectClass=computer))(!(showInAdvancedViewOnly=TRUE))(|(sAMAccountName={})(mail={})(userPrincipalName={}))(sAMAccountType=805306368))"
+
+	// - is a group.
+	// - has a member that matches the DN of the user we successfully logged in as.
+	// - perform nested group search by default.
+	defaultActiveDirectoryGroupSearchFilter = "(&(objectClass=group)(member:1.2.840.113556.1.4.1941:={}))"
+
+	// passwordExpiryTimeComputedAttribute is the time at which the password for this account expires.
+	// https://docs.microsoft.com/en-us/windows/win32/adschema/a-msds-userpasswordexpirytimecomputed
+	passwordExpiryTimeComputedAttribute = "msDS-UserPasswordExpiryTimeComputed"
+)
+
+// ActiveDirectoryUpstreamGenericLDAPImpl returns the given ActiveDirectoryIdentityProvider as an
+// UpstreamGenericLDAPIDP, including the defaults for Active Directory.
+func ActiveDirectoryUpstreamGenericLDAPImpl(upstream *v1alpha1.ActiveDirectoryIdentityProvider) UpstreamGenericLDAPIDP {
+	return &activeDirectoryUpstreamGenericLDAPImpl{activeDirectoryIdentityProvider: *upstream}
+}
+
+type activeDirectoryUpstreamGenericLDAPImpl struct {
+	activeDirectoryIdentityProvider v1alpha1.ActiveDirectoryIdentityProvider
+}
+
+func (g *activeDirectoryUpstreamGenericLDAPImpl) Spec() UpstreamGenericLDAPSpec {
+	return &activeDirectoryUpstreamGenericLDAPSpec{g.activeDirectoryIdentityProvider}
+}
+
+func (g *activeDirectoryUpstreamGenericLDAPImpl) Namespace() string {
+	return g.activeDirectoryIdentityProvider.Namespace
+}
+
+func (g *activeDirectoryUpstreamGenericLDAPImpl) Name() string {
+	return g.activeDirectoryIdentityProvider.Name
+}
+
+func (g *activeDirectoryUpstreamGenericLDAPImpl) Generation() int64 {
+	return g.activeDirectoryIdentityProvider.Generation
+}
+
+func (g *activeDirectoryUpstreamGenericLDAPImpl) Status() UpstreamGenericLDAPStatus {
+	return &activeDirectoryUpstreamGenericLDAPStatus{g.activeDirectoryIdentityProvider}
+}
+
+type activeDirectoryUpstreamGenericLDAPSpec struct {
+	activeDirectoryIdentityProvider v1alpha1.ActiveDirectoryIdentityProvider
+}
+
+func (s *activeDirectoryUpstreamGenericLDAPSpec) Host() string {
+	return s.activeDirectoryIdentityProvider.Spec.Host
+}
+
+func (s *activeDirectoryUpstreamGenericLDAPSpec) TLSSpec() *v1alpha1.TLSSpec {
+	return s.activeDirectoryIdentityProvider.Spec.TLS
+}
+
+func (s *activeDirectoryUpstreamGenericLDAPSpec) BindSecretName() string {
+	return s.activeDirectoryIdentityProvider.Spec.Bind.SecretName
+}
+
+func (s *activeDirectoryUpstreamGenericLDAPSpec) BindMethod() v1alpha1.BindMethod {
+	return s.activeDirectoryIdentityProvider.Spec.Bind.Method
+}
+
+func (s *activeDirectoryUpstreamGenericLDAPSpec) UserSearch() UpstreamGenericLDAPUserSearch {
+	return &activeDirectoryUpstreamGenericLDAPUserSearch{s.activeDirectoryIdentityProvider.Spec.UserSearch}
+}
+
+func (s *activeDirectoryUpstreamGenericLDAPSpec) GroupSearch() UpstreamGenericLDAPGroupSearch {
+	return &activeDirectoryUpstreamGenericLDAPGroupSearch{s.activeDirectoryIdentityProvider.Spec.GroupSearch}
+}
+
+func (s *activeDirectoryUpstreamGenericLDAPSpec) DetectAndSetSearchBase(ctx context.Context, config *upstreamldap.ProviderConfig) *v1alpha1.Condition {
+	config.GroupSearch.Base = s.activeDirectoryIdentityProvider.Spec.GroupSearch.Base
+	config.UserSearch.Base = s.activeDirectoryIdentityProvider.Spec.UserSearch.Base
+	if config.GroupSearch.Base != "" && config.UserSearch.Base != "" {
+		// Both were already set in spec so just return; no need to query the RootDSE
+		return &v1alpha1.Condition{
+			Type:    TypeSearchBaseFound,
+			Status:  v1alpha1.ConditionTrue,
+			Reason:  ReasonUsingConfigurationFromSpec,
+			Message: "Using search base from ActiveDirectoryIdentityProvider config.",
+		}
+	}
+	ldapProvider := upstreamldap.New(*config)
+	// Query your AD server for the defaultNamingContext to get a DN to use as the search base
+	// when it isn't specified.
+	// https://ldapwiki.com/wiki/DefaultNamingContext
+	defaultNamingContext, err := ldapProvider.SearchForDefaultNamingContext(ctx)
+	if err != nil {
+		return &v1alpha1.Condition{
+			Type:    TypeSearchBaseFound,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  ReasonErrorFetchingSearchBase,
+			Message: fmt.Sprintf(`Error finding search base: %s`, err.Error()),
+		}
+	}
+	if config.UserSearch.Base == "" {
+		config.UserSearch.Base = defaultNamingContext
+	}
+	if config.GroupSearch.Base == "" {
+		config.GroupSearch.Base = defaultNamingContext
+	}
+	return &v1alpha1.Condition{
+		Type:    TypeSearchBaseFound,
+		Status:  v1alpha1.ConditionTrue,
+		Reason:  ReasonSuccess,
+		Message: "Successfully fetched defaultNamingContext to use as default search base from RootDSE.",
+	}
+}
+
+type activeDirectoryUpstreamGenericLDAPUserSearch struct {
+	userSearch v1alpha1.ActiveDirectoryIdentityProviderUserSearch
+}
+
+func (u *activeDirectoryUpstreamGenericLDAPUserSearch) Base() string {
+	return u.userSearch.Base
+}
+
+func (u *activeDirectoryUpstreamGenericLDAPUserSearch) Filter() string {
+	if len(u.userSearch.Filter) == 0 {
+		return defaultActiveDirectoryUserSearchFilter
+	}
+	return u.userSearch.Filter
+}
+
+func (u *activeDirectoryUpstreamGenericLDAPUserSearch) UsernameAttribute() string {
+	if len(u.userSearch.Attributes.Username) == 0 {
+		return defaultActiveDirectoryUsernameAttributeName
+	}
+	return u.userSearch.Attributes.Username
+}
+
+func (u *activeDirectoryUpstreamGenericLDAPUserSearch) UIDAttribute() string {
+	if len(u.userSearch.Attributes.UID) == 0 {
+		return defaultActiveDirectoryUIDAttributeName
+	}
+	return u.userSearch.Attributes.UID
+}
+
+type activeDirectoryUpstreamGenericLDAPGroupSearch struct {
+	groupSearch v1alpha1.ActiveDirectoryIdentityProviderGroupSearch
+}
+
+func (g *activeDirectoryUpstreamGenericLDAPGroupSearch) Base() string {
+	return g.groupSearch.Base
+}
+
+func (g *activeDirectoryUpstreamGenericLDAPGroupSearch) Filter() string {
+	if len(g.groupSearch.Filter) == 0 {
+		return defaultActiveDirectoryGroupSearchFilter
+	}
+	return g.groupSearch.Filter
+}
+
+func (g *activeDirectoryUpstreamGenericLDAPGroupSearch) GroupNameAttribute() string {
+	if len(g.groupSearch.Attributes.GroupName) == 0 {
+		return defaultActiveDirectoryGroupNameAttributeName
+	}
+	return g.groupSearch.Attributes.GroupName
+}
+
+type activeDirectoryUpstreamGenericLDAPStatus struct {
+	activeDirectoryIdentityProvider v1alpha1.ActiveDirectoryIdentityProvider
+}
+
+func (s *activeDirectoryUpstreamGenericLDAPStatus) Conditions() []v1alpha1.Condition {
+	return s.activeDirectoryIdentityProvider.Status.Conditions
+}
+
+// ActiveDirectoryProviderConfig returns the settings of the given ActiveDirectoryIdentityProvider, including the defaults for
+// Active Directory. The settings which come from the bind Secret, the TLS spec, and from probing the Active Directory
+// server are not included.
+func ActiveDirectoryProviderConfig(upstream *v1alpha1.ActiveDirectoryIdentityProvider) *upstreamldap.ProviderConfig {
+	spec := upstream.Spec
+
+	adUpstreamImpl := ActiveDirectoryUpstreamGenericLDAPImpl(upstream)
+
+	config := &upstreamldap.ProviderConfig{
+		Name:            upstream.Name,
+		ResourceUID:     upstream.UID,
+		Host:            spec.Host,
+		AdditionalHosts: spec.AdditionalHosts,
+		HostDiscovery:   HostDiscoveryConfig(spec.HostDiscovery),
+		HostSelection:   upstreamldap.HostSelectionPolicy(spec.HostSelection),
+		UserSearch: upstreamldap.UserSearchConfig{
+			Base:                      spec.UserSearch.Base,
+			Filter:                    adUpstreamImpl.Spec().UserSearch().Filter(),
+			UsernameAttribute:         adUpstreamImpl.Spec().UserSearch().UsernameAttribute(),
+			UIDAttribute:              adUpstreamImpl.Spec().UserSearch().UIDAttribute(),
+			AdditionalClaimAttributes: AdditionalClaimAttributes(spec.UserSearch.Attributes.AdditionalClaims),
+		},
+		GroupSearch: upstreamldap.GroupSearchConfig{
+			Base:               spec.GroupSearch.Base,
+			Filter:             adUpstreamImpl.Spec().GroupSearch().Filter(),
+			GroupNameAttribute: adUpstreamImpl.Spec().GroupSearch().GroupNameAttribute(),
+			SkipGroupRefresh:   spec.GroupSearch.SkipGroupRefresh,
+			Cache:              GroupCacheConfig(spec.GroupSearch.Cache),
+		},
+		UIDAttributeParsingOverrides: map[string]func(*ldap.Entry) (string, error){
+			"objectGUID": upstreamldap.MicrosoftUUIDFromBinaryAttr("objectGUID"),
+		},
+		RefreshAttributeChecks: map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error{
+			upstreamldap.PwdLastSetAttribute:                 upstreamldap.AttributeUnchangedSinceLogin(upstreamldap.PwdLastSetAttribute),
+			upstreamldap.UserAccountControlAttribute:         upstreamldap.ValidUserAccountControl,
+			upstreamldap.UserAccountControlComputedAttribute: upstreamldap.ValidComputedUserAccountControl,
+		},
+		AccountStatus:        activeDirectoryAccountStatusConfig(spec.AccountStatus),
+		PasswordChangeMethod: upstreamldap.ActiveDirectoryUnicodePwd,
+	}
+
+	if spec.GroupSearch.Attributes.GroupName == "" {
+		config.GroupAttributeParsingOverrides = map[string]func(*ldap.Entry) (string, error){
+			defaultActiveDirectoryGroupNameAttributeName: upstreamldap.GroupSAMAccountNameWithDomainSuffix,
+		}
+	}
+
+	return config
+}
+
+// activeDirectoryAccountStatusConfig returns the configuration for checking the expiration of users' passwords, when enabled.
+// The expiration time is a constructed attribute, which Active Directory only returns from a base object search.
+func activeDirectoryAccountStatusConfig(accountStatus v1alpha1.ActiveDirectoryIdentityProviderAccountStatus) upstreamldap.AccountStatusConfig {
+	if !accountStatus.CheckPasswordExpiration {
+		return upstreamldap.AccountStatusConfig{}
+	}
+	config := upstreamldap.AccountStatusConfig{
+		PasswordExpirationAttribute:              passwordExpiryTimeComputedAttribute,
+		PasswordExpirationAttributeIsConstructed: true,
+		PasswordExpirationWarningPeriod:          DefaultPasswordExpirationWarningPeriod,
+		TimestampFormat:                          upstreamldap.WindowsFileTime,
+	}
+	if accountStatus.PasswordExpirationWarningDays > 0 {
+		config.PasswordExpirationWarningPeriod = time.Duration(accountStatus.PasswordExpirationWarningDays) * 24 * time.Hour
+	}
+	return config
+}
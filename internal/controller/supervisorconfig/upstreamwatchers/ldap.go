@@ -0,0 +1,75 @@
+// Copyright 2021-2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamwatchers
+
+import (
+	"time"
+
+	"go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	"go.pinniped.dev/internal/upstreamldap"
+)
+
+// defaultNestedGroupSearchMaxDepth is the number of levels of nested groups which are searched when
+// nested group search is enabled without specifying a maximum depth.
+const defaultNestedGroupSearchMaxDepth = 10
+
+// LDAPProviderConfig returns the settings of the given LDAPIdentityProvider. The settings which come from the bind Secret,
+// the TLS spec, and from probing the LDAP server are not included.
+func LDAPProviderConfig(upstream *v1alpha1.LDAPIdentityProvider) *upstreamldap.ProviderConfig {
+	spec := upstream.Spec
+
+	return &upstreamldap.ProviderConfig{
+		Name:            upstream.Name,
+		ResourceUID:     upstream.UID,
+		Host:            spec.Host,
+		AdditionalHosts: spec.AdditionalHosts,
+		HostDiscovery:   HostDiscoveryConfig(spec.HostDiscovery),
+		HostSelection:   upstreamldap.HostSelectionPolicy(spec.HostSelection),
+		UserSearch: upstreamldap.UserSearchConfig{
+			Base:                      spec.UserSearch.Base,
+			Filter:                    spec.UserSearch.Filter,
+			UsernameAttribute:         spec.UserSearch.Attributes.Username,
+			UIDAttribute:              spec.UserSearch.Attributes.UID,
+			AdditionalClaimAttributes: AdditionalClaimAttributes(spec.UserSearch.Attributes.AdditionalClaims),
+		},
+		GroupSearch: upstreamldap.GroupSearchConfig{
+			Base:                 spec.GroupSearch.Base,
+			Filter:               spec.GroupSearch.Filter,
+			GroupNameAttribute:   spec.GroupSearch.Attributes.GroupName,
+			SkipGroupRefresh:     spec.GroupSearch.SkipGroupRefresh,
+			NestedGroupsMaxDepth: nestedGroupsMaxDepth(spec.GroupSearch.Nested),
+			Cache:                GroupCacheConfig(spec.GroupSearch.Cache),
+		},
+		AccountStatus: ldapAccountStatusConfig(spec.AccountStatus),
+	}
+}
+
+// nestedGroupsMaxDepth returns how many levels of nested groups should be searched, or zero when only the direct
+// group memberships should be searched.
+func nestedGroupsMaxDepth(nested *v1alpha1.LDAPIdentityProviderNestedGroupSearch) int {
+	if nested == nil {
+		return 0
+	}
+	if nested.MaxDepth <= 0 {
+		return defaultNestedGroupSearchMaxDepth
+	}
+	return int(nested.MaxDepth)
+}
+
+func ldapAccountStatusConfig(accountStatus v1alpha1.LDAPIdentityProviderAccountStatus) upstreamldap.AccountStatusConfig {
+	config := upstreamldap.AccountStatusConfig{
+		DisabledAttribute:           accountStatus.DisabledAttribute,
+		DisabledValues:              accountStatus.DisabledValues,
+		AccountExpirationAttribute:  accountStatus.AccountExpirationAttribute,
+		PasswordExpirationAttribute: accountStatus.PasswordExpirationAttribute,
+		PasswordPolicyControl:       accountStatus.PasswordPolicyControl,
+	}
+	if config.PasswordExpirationAttribute != "" {
+		config.PasswordExpirationWarningPeriod = DefaultPasswordExpirationWarningPeriod
+		if accountStatus.PasswordExpirationWarningDays > 0 {
+			config.PasswordExpirationWarningPeriod = time.Duration(accountStatus.PasswordExpirationWarningDays) * 24 * time.Hour
+		}
+	}
+	return config
+}
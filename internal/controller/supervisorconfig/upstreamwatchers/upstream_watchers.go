@@ -5,13 +5,18 @@ package upstreamwatchers
 
 import (
 	"context"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/utils/clock"
 
 	"go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
 	"go.pinniped.dev/internal/constable"
@@ -21,16 +26,30 @@ import (
 )
 
 const (
-	ReasonNotFound         = "SecretNotFound"
-	ReasonWrongType        = "SecretWrongType"
-	ReasonMissingKeys      = "SecretMissingKeys"
-	ReasonSuccess          = "Success"
-	ReasonInvalidTLSConfig = "InvalidTLSConfig"
+	ReasonNotFound           = "SecretNotFound"
+	ReasonWrongType          = "SecretWrongType"
+	ReasonMissingKeys        = "SecretMissingKeys"
+	ReasonSuccess            = "Success"
+	ReasonInvalidTLSConfig   = "InvalidTLSConfig"
+	ReasonInvalidCertificate = "SecretInvalidCertificate"
 
 	ErrNoCertificates = constable.Error("no certificates found")
 
-	LDAPBindAccountSecretType = corev1.SecretTypeBasicAuth
-	probeLDAPTimeout          = 90 * time.Second
+	LDAPBindAccountSecretType                  = corev1.SecretTypeBasicAuth
+	LDAPBindAccountClientCertificateSecretType = corev1.SecretTypeTLS
+	probeLDAPTimeout                           = 90 * time.Second
+
+	// someHostsUnavailableRetestInterval is how long the settings which were validated while some of the hosts
+	// could not be reached are used before all the hosts are tested again.
+	someHostsUnavailableRetestInterval = 5 * time.Minute
+
+	// DefaultPasswordExpirationWarningPeriod is how long before their password expires users will start to be
+	// warned about it, unless configured otherwise.
+	DefaultPasswordExpirationWarningPeriod = 14 * 24 * time.Hour
+
+	// DefaultGroupSearchCacheTTL is how long cached group search results are used by refreshes when the
+	// group search cache is enabled without configuring its TTL.
+	DefaultGroupSearchCacheTTL = 5 * time.Minute
 
 	// Constants related to conditions.
 	typeBindSecretValid              = "BindSecretValid"
@@ -38,6 +57,8 @@ const (
 	typeLDAPConnectionValid          = "LDAPConnectionValid"
 	TypeSearchBaseFound              = "SearchBaseFound"
 	reasonLDAPConnectionError        = "LDAPConnectionError"
+	reasonSomeHostsUnavailable       = "SomeHostsUnavailable"
+	reasonHostNotSpecified           = "HostNotSpecified"
 	noTLSConfigurationMessage        = "no TLS configuration provided"
 	loadedTLSConfigurationMessage    = "loaded TLS configuration"
 	ReasonUsingConfigurationFromSpec = "UsingConfigurationFromSpec"
@@ -61,6 +82,10 @@ type ValidatedSettings struct {
 	// to write them to the IDP's status fails. In this case, future Syncs calls will be able to
 	// use these cached values to try writing them again.
 	ConnectionValidCondition, SearchBaseFoundCondition *v1alpha1.Condition
+
+	// When some of the hosts could not be reached during the validation, the settings are only used until
+	// RetestAfter, so those hosts will be tested again. Zero means that the settings are used indefinitely.
+	RetestAfter time.Time
 }
 
 // ValidatedSettingsCacheI is an interface for an in-memory cache with an entry for each upstream
@@ -74,12 +99,17 @@ type ValidatedSettingsCacheI interface {
 	// desired settings were not cached yet for that combination of spec generation and secret version.
 	Get(upstreamName, resourceVersion string, idpSpecGeneration int64) (ValidatedSettings, bool)
 
-	// Set some settings into the cache for a given upstream.
+	// Set some settings into the cache for a given upstream. When the settings were validated while some
+	// of the hosts could not be reached, then Get will only return them for a while.
 	Set(upstreamName string, settings ValidatedSettings)
 }
 
 type ValidatedSettingsCache struct {
 	ValidatedSettingsByName map[string]ValidatedSettings
+
+	// Clock is used to decide when settings which were validated while some hosts were unavailable expire.
+	// When nil, the real clock is used.
+	Clock clock.Clock
 }
 
 func NewValidatedSettingsCache() ValidatedSettingsCacheI {
@@ -88,16 +118,27 @@ func NewValidatedSettingsCache() ValidatedSettingsCacheI {
 
 func (s *ValidatedSettingsCache) Get(upstreamName, resourceVersion string, idpSpecGeneration int64) (ValidatedSettings, bool) {
 	validatedSettings, found := s.ValidatedSettingsByName[upstreamName]
-	if found && validatedSettings.BindSecretResourceVersion == resourceVersion && validatedSettings.IDPSpecGeneration == idpSpecGeneration {
+	if found && validatedSettings.BindSecretResourceVersion == resourceVersion && validatedSettings.IDPSpecGeneration == idpSpecGeneration &&
+		(validatedSettings.RetestAfter.IsZero() || s.clock().Now().Before(validatedSettings.RetestAfter)) {
 		return validatedSettings, true
 	}
 	return ValidatedSettings{}, false
 }
 
 func (s *ValidatedSettingsCache) Set(upstreamName string, settings ValidatedSettings) {
+	if settings.ConnectionValidCondition != nil && settings.ConnectionValidCondition.Reason == reasonSomeHostsUnavailable {
+		settings.RetestAfter = s.clock().Now().Add(someHostsUnavailableRetestInterval)
+	}
 	s.ValidatedSettingsByName[upstreamName] = settings
 }
 
+func (s *ValidatedSettingsCache) clock() clock.Clock {
+	if s.Clock == nil {
+		return clock.RealClock{}
+	}
+	return s.Clock
+}
+
 // UpstreamGenericLDAPIDP is a read-only interface for abstracting the differences between LDAP and Active Directory IDP types.
 type UpstreamGenericLDAPIDP interface {
 	Spec() UpstreamGenericLDAPSpec
@@ -111,6 +152,7 @@ type UpstreamGenericLDAPSpec interface {
 	Host() string
 	TLSSpec() *v1alpha1.TLSSpec
 	BindSecretName() string
+	BindMethod() v1alpha1.BindMethod
 	UserSearch() UpstreamGenericLDAPUserSearch
 	GroupSearch() UpstreamGenericLDAPGroupSearch
 	DetectAndSetSearchBase(ctx context.Context, config *upstreamldap.ProviderConfig) *v1alpha1.Condition
@@ -133,6 +175,42 @@ type UpstreamGenericLDAPStatus interface {
 	Conditions() []v1alpha1.Condition
 }
 
+// HostDiscoveryConfig converts the optional host discovery settings of an IDP spec into the provider's configuration.
+func HostDiscoveryConfig(spec *v1alpha1.HostDiscoverySpec) upstreamldap.HostDiscoveryConfig {
+	if spec == nil {
+		return upstreamldap.HostDiscoveryConfig{}
+	}
+	return upstreamldap.HostDiscoveryConfig{
+		SRVDomain:  spec.SRVDomain,
+		SRVService: spec.SRVService,
+	}
+}
+
+func AdditionalClaimAttributes(mappings []v1alpha1.AdditionalClaimMapping) map[string]string {
+	if len(mappings) == 0 {
+		return nil
+	}
+	attributes := make(map[string]string, len(mappings))
+	for _, mapping := range mappings {
+		attributes[mapping.Claim] = mapping.Attribute
+	}
+	return attributes
+}
+
+func GroupCacheConfig(spec *v1alpha1.GroupSearchCacheSpec) upstreamldap.GroupCacheConfig {
+	if spec == nil {
+		return upstreamldap.GroupCacheConfig{}
+	}
+	ttl := DefaultGroupSearchCacheTTL
+	if spec.TTLSeconds > 0 {
+		ttl = time.Duration(spec.TTLSeconds) * time.Second
+	}
+	return upstreamldap.GroupCacheConfig{
+		TTL:     ttl,
+		MaxSize: int(spec.MaxSize),
+	}
+}
+
 func ValidateTLSConfig(tlsSpec *v1alpha1.TLSSpec, config *upstreamldap.ProviderConfig) *v1alpha1.Condition {
 	if tlsSpec == nil {
 		return validTLSCondition(noTLSConfigurationMessage)
@@ -165,18 +243,18 @@ func TestConnection(
 	// First try using TLS.
 	config.ConnectionProtocol = upstreamldap.TLS
 	tlsLDAPProvider := upstreamldap.New(*config)
-	err := tlsLDAPProvider.TestConnection(ctx)
+	statuses, err := testConnectionToEachHost(ctx, tlsLDAPProvider)
 	if err != nil {
 		plog.InfoErr("testing LDAP connection using TLS failed, so trying again with StartTLS", err, "host", config.Host)
 		// If there was any error, try again with StartTLS instead.
 		config.ConnectionProtocol = upstreamldap.StartTLS
 		startTLSLDAPProvider := upstreamldap.New(*config)
-		startTLSErr := startTLSLDAPProvider.TestConnection(ctx)
+		startTLSStatuses, startTLSErr := testConnectionToEachHost(ctx, startTLSLDAPProvider)
 		if startTLSErr == nil {
 			plog.Info("testing LDAP connection using StartTLS succeeded", "host", config.Host)
 			// Successfully able to fall back to using StartTLS, so clear the original
 			// error and consider the connection test to be successful.
-			err = nil
+			statuses, err = startTLSStatuses, nil
 		} else {
 			plog.InfoErr("testing LDAP connection using StartTLS also failed", err, "host", config.Host)
 			// Falling back to StartTLS also failed, so put TLS back into the config
@@ -190,8 +268,28 @@ func TestConnection(
 			Type:   typeLDAPConnectionValid,
 			Status: v1alpha1.ConditionFalse,
 			Reason: reasonLDAPConnectionError,
-			Message: fmt.Sprintf(`could not successfully connect to "%s" and bind as user "%s": %s`,
-				config.Host, config.BindUsername, err.Error()),
+			Message: fmt.Sprintf(`could not successfully connect to %s and bind as user "%s": %s`,
+				describeHosts(config, statuses), config.BindUsername, err.Error()),
+		}
+	}
+
+	var healthy []string
+	var unhealthy []upstreamldap.HostStatus
+	for _, status := range statuses {
+		if status.Err != nil {
+			unhealthy = append(unhealthy, status)
+			continue
+		}
+		healthy = append(healthy, status.Host)
+	}
+
+	if len(unhealthy) > 0 {
+		return &v1alpha1.Condition{
+			Type:   typeLDAPConnectionValid,
+			Status: v1alpha1.ConditionTrue,
+			Reason: reasonSomeHostsUnavailable,
+			Message: fmt.Sprintf(`successfully able to connect to %s and bind as user "%s" [validated with Secret "%s" at version "%s"], but not to the other hosts: %s`,
+				quoteHosts(healthy), config.BindUsername, bindSecretName, currentSecretVersion, describeHostErrors(unhealthy)),
 		}
 	}
 
@@ -199,9 +297,64 @@ func TestConnection(
 		Type:   typeLDAPConnectionValid,
 		Status: v1alpha1.ConditionTrue,
 		Reason: ReasonSuccess,
-		Message: fmt.Sprintf(`successfully able to connect to "%s" and bind as user "%s" [validated with Secret "%s" at version "%s"]`,
-			config.Host, config.BindUsername, bindSecretName, currentSecretVersion),
+		Message: fmt.Sprintf(`successfully able to connect to %s and bind as user "%s" [validated with Secret "%s" at version "%s"]`,
+			quoteHosts(healthy), config.BindUsername, bindSecretName, currentSecretVersion),
+	}
+}
+
+// testConnectionToEachHost returns the result of testing each host of the provider, or an error when none of
+// the hosts could be used.
+func testConnectionToEachHost(ctx context.Context, p *upstreamldap.Provider) ([]upstreamldap.HostStatus, error) {
+	statuses, err := p.TestConnectionToEachHost(ctx)
+	if err != nil {
+		return nil, err
 	}
+	for _, status := range statuses {
+		if status.Err == nil {
+			return statuses, nil
+		}
+	}
+	if len(statuses) == 1 {
+		return statuses, statuses[0].Err
+	}
+	return statuses, errors.New(describeHostErrors(statuses))
+}
+
+// describeHosts describes the hosts which were tested, or the configured hosts when the hosts could not be tested.
+func describeHosts(config *upstreamldap.ProviderConfig, statuses []upstreamldap.HostStatus) string {
+	hosts := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		hosts = append(hosts, status.Host)
+	}
+	if len(hosts) == 0 {
+		if config.Host != "" {
+			hosts = append(hosts, config.Host)
+		}
+		hosts = append(hosts, config.AdditionalHosts...)
+	}
+	if len(hosts) == 0 {
+		return fmt.Sprintf("the hosts discovered in %q", config.HostDiscovery.SRVDomain)
+	}
+	if len(hosts) == 1 {
+		return quoteHosts(hosts)
+	}
+	return "any of " + quoteHosts(hosts)
+}
+
+func describeHostErrors(statuses []upstreamldap.HostStatus) string {
+	descriptions := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		descriptions = append(descriptions, fmt.Sprintf("%q: %s", status.Host, status.Err.Error()))
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+func quoteHosts(hosts []string) string {
+	quoted := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		quoted = append(quoted, fmt.Sprintf("%q", host))
+	}
+	return strings.Join(quoted, ", ")
 }
 
 func validTLSCondition(message string) *v1alpha1.Condition {
@@ -222,7 +375,13 @@ func invalidTLSCondition(message string) *v1alpha1.Condition {
 	}
 }
 
-func ValidateSecret(secretInformer corev1informers.SecretInformer, secretName string, secretNamespace string, config *upstreamldap.ProviderConfig) (*v1alpha1.Condition, string) {
+func ValidateSecret(
+	secretInformer corev1informers.SecretInformer,
+	secretName string,
+	secretNamespace string,
+	bindMethod v1alpha1.BindMethod,
+	config *upstreamldap.ProviderConfig,
+) (*v1alpha1.Condition, string) {
 	secret, err := secretInformer.Lister().Secrets(secretNamespace).Get(secretName)
 	if err != nil {
 		return &v1alpha1.Condition{
@@ -233,14 +392,27 @@ func ValidateSecret(secretInformer corev1informers.SecretInformer, secretName st
 		}, ""
 	}
 
-	if secret.Type != corev1.SecretTypeBasicAuth {
+	return ValidateBindSecret(secret, bindMethod, config), secret.ResourceVersion
+}
+
+// ValidateBindSecret loads the bind credentials for the given bind method from the given Secret into the config.
+func ValidateBindSecret(secret *corev1.Secret, bindMethod v1alpha1.BindMethod, config *upstreamldap.ProviderConfig) *v1alpha1.Condition {
+	expectedType := LDAPBindAccountSecretType
+	if bindMethod == v1alpha1.BindMethodSASLExternal {
+		expectedType = LDAPBindAccountClientCertificateSecretType
+	}
+	if secret.Type != expectedType {
 		return &v1alpha1.Condition{
 			Type:   typeBindSecretValid,
 			Status: v1alpha1.ConditionFalse,
 			Reason: ReasonWrongType,
 			Message: fmt.Sprintf("referenced Secret %q has wrong type %q (should be %q)",
-				secretName, secret.Type, corev1.SecretTypeBasicAuth),
-		}, secret.ResourceVersion
+				secret.Name, secret.Type, expectedType),
+		}
+	}
+
+	if bindMethod == v1alpha1.BindMethodSASLExternal {
+		return validateClientCertificateSecret(secret, config)
 	}
 
 	config.BindUsername = string(secret.Data[corev1.BasicAuthUsernameKey])
@@ -251,16 +423,55 @@ func ValidateSecret(secretInformer corev1informers.SecretInformer, secretName st
 			Status: v1alpha1.ConditionFalse,
 			Reason: ReasonMissingKeys,
 			Message: fmt.Sprintf("referenced Secret %q is missing required keys %q",
-				secretName, []string{corev1.BasicAuthUsernameKey, corev1.BasicAuthPasswordKey}),
-		}, secret.ResourceVersion
+				secret.Name, []string{corev1.BasicAuthUsernameKey, corev1.BasicAuthPasswordKey}),
+		}
+	}
+
+	return validBindSecretCondition()
+}
+
+// validateClientCertificateSecret loads the client certificate and private key which will be used for SASL EXTERNAL
+// binds from a Secret of type "kubernetes.io/tls". Since there is no bind username, the subject of the certificate
+// is used to describe the bind user in messages.
+func validateClientCertificateSecret(secret *corev1.Secret, config *upstreamldap.ProviderConfig) *v1alpha1.Condition {
+	certPEM := secret.Data[corev1.TLSCertKey]
+	keyPEM := secret.Data[corev1.TLSPrivateKeyKey]
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return &v1alpha1.Condition{
+			Type:   typeBindSecretValid,
+			Status: v1alpha1.ConditionFalse,
+			Reason: ReasonMissingKeys,
+			Message: fmt.Sprintf("referenced Secret %q is missing required keys %q",
+				secret.Name, []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey}),
+		}
+	}
+
+	clientCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err == nil {
+		clientCert.Leaf, err = x509.ParseCertificate(clientCert.Certificate[0])
 	}
+	if err != nil {
+		return &v1alpha1.Condition{
+			Type:    typeBindSecretValid,
+			Status:  v1alpha1.ConditionFalse,
+			Reason:  ReasonInvalidCertificate,
+			Message: fmt.Sprintf("referenced Secret %q does not contain a valid client certificate and key: %s", secret.Name, err.Error()),
+		}
+	}
+
+	config.ClientCertificateData = certPEM
+	config.ClientKeyData = keyPEM
+	config.BindUsername = clientCert.Leaf.Subject.String()
+	return validBindSecretCondition()
+}
 
+func validBindSecretCondition() *v1alpha1.Condition {
 	return &v1alpha1.Condition{
 		Type:    typeBindSecretValid,
 		Status:  v1alpha1.ConditionTrue,
 		Reason:  ReasonSuccess,
 		Message: "loaded bind secret",
-	}, secret.ResourceVersion
+	}
 }
 
 // gradatedCondition is a condition and a boolean that tells you whether the condition is fatal or just a warning.
@@ -295,15 +506,20 @@ func ValidateGenericLDAP(
 ) GradatedConditions {
 	conditions := GradatedConditions{}
 
-	secretValidCondition, currentSecretVersion := ValidateSecret(secretInformer, upstream.Spec().BindSecretName(), upstream.Namespace(), config)
+	secretValidCondition, currentSecretVersion := ValidateSecret(secretInformer, upstream.Spec().BindSecretName(), upstream.Namespace(), upstream.Spec().BindMethod(), config)
 	conditions.Append(secretValidCondition, true)
 
 	tlsValidCondition := ValidateTLSConfig(upstream.Spec().TLSSpec(), config)
 	conditions.Append(tlsValidCondition, true)
 
 	var ldapConnectionValidCondition, searchBaseFoundCondition *v1alpha1.Condition
-	// No point in trying to connect to the server if the config was already determined to be invalid.
-	if secretValidCondition.Status == v1alpha1.ConditionTrue && tlsValidCondition.Status == v1alpha1.ConditionTrue {
+	switch {
+	case config.Host == "" && config.HostDiscovery.SRVDomain == "":
+		// The CRD cannot require that at least one of these is specified, so check it here. There is nothing
+		// to connect to, so retrying will not help.
+		conditions.Append(noHostCondition(), true)
+	case secretValidCondition.Status == v1alpha1.ConditionTrue && tlsValidCondition.Status == v1alpha1.ConditionTrue:
+		// No point in trying to connect to the server if the config was already determined to be invalid.
 		ldapConnectionValidCondition, searchBaseFoundCondition = validateAndSetLDAPServerConnectivityAndSearchBase(ctx, validatedSettingsCache, upstream, config, currentSecretVersion)
 		conditions.Append(ldapConnectionValidCondition, false)
 		if searchBaseFoundCondition != nil { // currently, only used for AD, so may be nil
@@ -313,6 +529,15 @@ func ValidateGenericLDAP(
 	return conditions
 }
 
+func noHostCondition() *v1alpha1.Condition {
+	return &v1alpha1.Condition{
+		Type:    typeLDAPConnectionValid,
+		Status:  v1alpha1.ConditionFalse,
+		Reason:  reasonHostNotSpecified,
+		Message: "spec.host must be specified when spec.hostDiscovery is not specified",
+	}
+}
+
 func validateAndSetLDAPServerConnectivityAndSearchBase(
 	ctx context.Context,
 	validatedSettingsCache ValidatedSettingsCacheI,
@@ -342,7 +567,8 @@ func validateAndSetLDAPServerConnectivityAndSearchBase(
 
 		// When there were no failures, write the newly validated settings to the cache.
 		// It's okay for the search base condition to be nil, since it's only used by Active Directory providers,
-		// but if it exists make sure it was not a failure.
+		// but if it exists make sure it was not a failure. When only some of the hosts could be reached, the
+		// cache will only keep the settings for a while, so the hosts will be tested again later.
 		if ldapConnectionValidCondition.Status == v1alpha1.ConditionTrue &&
 			(searchBaseFoundCondition == nil || (searchBaseFoundCondition.Status == v1alpha1.ConditionTrue)) {
 			// Remember (in-memory for this pod) that the controller has successfully validated the LDAP or AD provider
@@ -381,3 +607,17 @@ func EvaluateConditions(conditions GradatedConditions, config *upstreamldap.Prov
 	// Fully validated provider, so load it into the cache.
 	return upstreamldap.New(*config), false
 }
+
+// PruneConnectionPools closes the connection pools and discards the group caches of any upstreams which are not in
+// the given list of validated upstreams.
+func PruneConnectionPools(pools *upstreamldap.ConnectionPools, groupCaches *upstreamldap.GroupCaches, validatedUpstreams []provider.UpstreamLDAPIdentityProviderI) {
+	uids := make([]types.UID, 0, len(validatedUpstreams))
+	names := make(map[types.UID]string, len(validatedUpstreams))
+	for _, upstream := range validatedUpstreams {
+		uids = append(uids, upstream.GetResourceUID())
+		names[upstream.GetResourceUID()] = upstream.GetName()
+	}
+
+	pools.Prune(names)
+	groupCaches.Prune(uids)
+}
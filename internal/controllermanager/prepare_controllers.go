@@ -16,6 +16,8 @@ import (
 	pinnipedclientset "go.pinniped.dev/generated/latest/client/concierge/clientset/versioned"
 	pinnipedinformers "go.pinniped.dev/generated/latest/client/concierge/informers/externalversions"
 	"go.pinniped.dev/internal/apiserviceref"
+	"go.pinniped.dev/internal/certauthority/csrcertauthority"
+	"go.pinniped.dev/internal/concierge/frontendpolicy"
 	"go.pinniped.dev/internal/concierge/impersonator"
 	"go.pinniped.dev/internal/config/concierge"
 	"go.pinniped.dev/internal/controller/apicerts"
@@ -24,6 +26,7 @@ import (
 	"go.pinniped.dev/internal/controller/authenticator/jwtcachefiller"
 	"go.pinniped.dev/internal/controller/authenticator/webhookcachefiller"
 	"go.pinniped.dev/internal/controller/impersonatorconfig"
+	"go.pinniped.dev/internal/controller/issuerconfig"
 	"go.pinniped.dev/internal/controller/kubecertagent"
 	"go.pinniped.dev/internal/controllerinit"
 	"go.pinniped.dev/internal/controllerlib"
@@ -62,6 +65,10 @@ type Config struct {
 	// ImpersonationProxyServerPort decides which port the impersonation proxy should bind.
 	ImpersonationProxyServerPort int
 
+	// ImpersonationProxyAudit comes from the Pinniped config API (see api.Config). It configures which
+	// audit events the impersonation proxy records and where it sends them.
+	ImpersonationProxyAudit *concierge.ImpersonationProxyAuditSpec
+
 	// DiscoveryURLOverride allows a caller to inject a hardcoded discovery URL into Pinniped
 	// discovery document.
 	DiscoveryURLOverride *string
@@ -92,6 +99,13 @@ type Config struct {
 	// AuthenticatorCache is a cache of authenticators shared amongst various authenticated-related controllers.
 	AuthenticatorCache *authncache.Cache
 
+	// FrontendPolicy is the policy of which authenticators may be used through each frontend.
+	FrontendPolicy *frontendpolicy.Policy
+
+	// CSRSigner issues client certs using the configured signer of the Kubernetes CertificateSigningRequest API.
+	// It is only set when KubeCertAgentConfig.CSRSignerName is set.
+	CSRSigner *csrcertauthority.CA
+
 	// Labels are labels that should be added to any resources created by the controllers.
 	Labels map[string]string
 }
@@ -254,6 +268,16 @@ func PrepareControllers(c *Config) (controllerinit.RunnerBuilder, error) {
 			),
 			singletonWorker,
 		).
+		// The frontend policy controller keeps the in-memory policy of which authenticators may be used
+		// through each frontend up to date with the CredentialIssuer.
+		WithController(
+			issuerconfig.NewFrontendPolicyController(
+				c.NamesConfig.CredentialIssuer,
+				informers.pinniped.Config().V1alpha1().CredentialIssuers(),
+				c.FrontendPolicy,
+			),
+			singletonWorker,
+		).
 
 		// The impersonator configuration controller dynamically configures the impersonation proxy feature.
 		WithController(
@@ -273,7 +297,7 @@ func PrepareControllers(c *Config) (controllerinit.RunnerBuilder, error) {
 				c.NamesConfig.ImpersonationCACertificateSecret,
 				c.Labels,
 				clock.RealClock{},
-				impersonator.New,
+				impersonator.NewFactory(c.ImpersonationProxyAudit),
 				c.NamesConfig.ImpersonationSignerSecret,
 				c.ImpersonationSigningCertProvider,
 				plog.Logr(), // nolint: staticcheck  // old controller with lots of log statements
@@ -309,6 +333,26 @@ func PrepareControllers(c *Config) (controllerinit.RunnerBuilder, error) {
 			singletonWorker,
 		)
 
+	// The CSR signer controller is responsible for reporting status on the cluster integration strategy which
+	// issues client certs using the Kubernetes CertificateSigningRequest API, when a signer has been configured.
+	if signerName := c.KubeCertAgentConfig.CSRSignerName; signerName != nil && *signerName != "" {
+		controllerManager = controllerManager.WithController(
+			kubecertagent.NewCSRSignerController(
+				kubecertagent.CSRSignerConfig{
+					SignerName:           *signerName,
+					CredentialIssuerName: c.NamesConfig.CredentialIssuer,
+					DiscoveryURLOverride: c.DiscoveryURLOverride,
+				},
+				client,
+				c.CSRSigner,
+				informers.kubePublicNamespaceK8s.Core().V1().ConfigMaps(),
+				informers.pinniped.Config().V1alpha1().CredentialIssuers(),
+				clock.RealClock{},
+			),
+			singletonWorker,
+		)
+	}
+
 	return controllerinit.Prepare(controllerManager.Start, leaderElector,
 		informers.kubePublicNamespaceK8s,
 		informers.kubeSystemNamespaceK8s,
@@ -0,0 +1,108 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package pushedauthorizerequest stores the authorization requests which clients push to the pushed authorization
+// request endpoint (RFC 9126) until they are used at the authorization endpoint.
+package pushedauthorizerequest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ory/fosite"
+	"k8s.io/apimachinery/pkg/api/errors"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/crud"
+)
+
+const (
+	TypeLabelValue = "pushed-authorize-request"
+
+	ErrInvalidPushedAuthorizeRequestVersion = constable.Error("pushed authorize request data has wrong version")
+	ErrInvalidPushedAuthorizeRequestData    = constable.Error("pushed authorize request data must be present")
+
+	// Version 1 was the initial release of storage.
+	pushedAuthorizeRequestStorageVersion = "1"
+)
+
+// PushedAuthorizeRequest is an authorization request which was pushed by a client.
+type PushedAuthorizeRequest struct {
+	// ClientID is the client which pushed the request, which must be the same client which uses it.
+	ClientID string `json:"clientID"`
+
+	// Params are the parameters of the authorization request, after any request object has been resolved.
+	Params url.Values `json:"params"`
+
+	// ExpiresAt is the time after which the request may no longer be used.
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PushedAuthorizeRequestStorage stores pushed authorization requests by their request_uri.
+type PushedAuthorizeRequestStorage interface {
+	CreatePushedAuthorizeRequest(ctx context.Context, requestURI string, request *PushedAuthorizeRequest) error
+	GetPushedAuthorizeRequest(ctx context.Context, requestURI string) (*PushedAuthorizeRequest, error)
+	DeletePushedAuthorizeRequest(ctx context.Context, requestURI string) error
+}
+
+var _ PushedAuthorizeRequestStorage = &pushedAuthorizeRequestStorage{}
+
+type pushedAuthorizeRequestStorage struct {
+	storage crud.Storage
+	clock   func() time.Time
+}
+
+type session struct {
+	Request *PushedAuthorizeRequest `json:"request"`
+	Version string                  `json:"version"`
+}
+
+func New(secrets corev1client.SecretInterface, clock func() time.Time, sessionStorageLifetime time.Duration) PushedAuthorizeRequestStorage {
+	return &pushedAuthorizeRequestStorage{storage: crud.New(TypeLabelValue, secrets, clock, sessionStorageLifetime), clock: clock}
+}
+
+func (a *pushedAuthorizeRequestStorage) CreatePushedAuthorizeRequest(ctx context.Context, requestURI string, request *PushedAuthorizeRequest) error {
+	if request == nil || request.ClientID == "" {
+		return ErrInvalidPushedAuthorizeRequestData
+	}
+
+	_, err := a.storage.Create(ctx, requestURI, &session{Request: request, Version: pushedAuthorizeRequestStorageVersion}, nil)
+	return err
+}
+
+// GetPushedAuthorizeRequest returns fosite.ErrNotFound when the request does not exist or when it has expired,
+// since an expired request may not have been garbage collected yet.
+func (a *pushedAuthorizeRequestStorage) GetPushedAuthorizeRequest(ctx context.Context, requestURI string) (*PushedAuthorizeRequest, error) {
+	session := &session{Request: &PushedAuthorizeRequest{}}
+	_, err := a.storage.Get(ctx, requestURI, session)
+
+	if errors.IsNotFound(err) {
+		return nil, fosite.ErrNotFound.WithWrap(err).WithDebug(err.Error())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pushed authorize request for %s: %w", requestURI, err)
+	}
+
+	if version := session.Version; version != pushedAuthorizeRequestStorageVersion {
+		return nil, fmt.Errorf("%w: pushed authorize request for %s has version %s instead of %s",
+			ErrInvalidPushedAuthorizeRequestVersion, requestURI, version, pushedAuthorizeRequestStorageVersion)
+	}
+
+	if session.Request.ClientID == "" {
+		return nil, fmt.Errorf("malformed pushed authorize request for %s: %w", requestURI, ErrInvalidPushedAuthorizeRequestData)
+	}
+
+	if !a.clock().Before(session.Request.ExpiresAt) {
+		return nil, fosite.ErrNotFound.WithDebug("pushed authorize request has expired")
+	}
+
+	return session.Request, nil
+}
+
+func (a *pushedAuthorizeRequestStorage) DeletePushedAuthorizeRequest(ctx context.Context, requestURI string) error {
+	return a.storage.Delete(ctx, requestURI)
+}
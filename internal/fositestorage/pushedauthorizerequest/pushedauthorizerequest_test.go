@@ -0,0 +1,179 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package pushedauthorizerequest
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	coretesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"go.pinniped.dev/internal/testutil"
+)
+
+const (
+	namespace  = "test-ns"
+	requestURI = "urn:ietf:params:oauth:request_uri:fancy-request"
+	secretName = "pinniped-storage-pushed-authorize-request-ovzg4otjmv2gmotqmfzgc3lthjxwc5luna5hezlrovsxg5c7ovzgsotgmfxgg6jnojsxc5lfon2a"
+)
+
+var fakeNow = time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+var lifetime = time.Minute * 10
+var fakeNowPlusLifetimeAsString = metav1.Time{Time: fakeNow.Add(lifetime)}.Format(time.RFC3339)
+
+func TestPushedAuthorizeRequestStorage(t *testing.T) {
+	secretsGVR := schema.GroupVersionResource{
+		Group:    "",
+		Version:  "v1",
+		Resource: "secrets",
+	}
+
+	wantActions := []coretesting.Action{
+		coretesting.NewCreateAction(secretsGVR, namespace, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            secretName,
+				ResourceVersion: "",
+				Labels: map[string]string{
+					"storage.pinniped.dev/type": "pushed-authorize-request",
+				},
+				Annotations: map[string]string{
+					"storage.pinniped.dev/garbage-collect-after": fakeNowPlusLifetimeAsString,
+				},
+			},
+			Data: map[string][]byte{
+				"pinniped-storage-data":    []byte(`{"request":{"clientID":"pinniped-cli","params":{"client_id":["pinniped-cli"],"scope":["openid"]},"expiresAt":"2030-01-01T00:01:30Z"},"version":"1"}`),
+				"pinniped-storage-version": []byte("1"),
+			},
+			Type: "storage.pinniped.dev/pushed-authorize-request",
+		}),
+		coretesting.NewGetAction(secretsGVR, namespace, secretName),
+		coretesting.NewDeleteAction(secretsGVR, namespace, secretName),
+	}
+
+	ctx, client, _, _, storage := makeTestSubject()
+
+	request := &PushedAuthorizeRequest{
+		ClientID:  "pinniped-cli",
+		Params:    url.Values{"client_id": []string{"pinniped-cli"}, "scope": []string{"openid"}},
+		ExpiresAt: fakeNow.Add(90 * time.Second),
+	}
+	err := storage.CreatePushedAuthorizeRequest(ctx, requestURI, request)
+	require.NoError(t, err)
+
+	newRequest, err := storage.GetPushedAuthorizeRequest(ctx, requestURI)
+	require.NoError(t, err)
+	require.Equal(t, request, newRequest)
+
+	err = storage.DeletePushedAuthorizeRequest(ctx, requestURI)
+	require.NoError(t, err)
+
+	testutil.LogActualJSONFromCreateAction(t, client, 0) // makes it easier to update expected values when needed
+	require.Equal(t, wantActions, client.Actions())
+}
+
+func TestGetNotFound(t *testing.T) {
+	ctx, _, _, _, storage := makeTestSubject()
+
+	_, notFoundErr := storage.GetPushedAuthorizeRequest(ctx, "non-existent-request-uri")
+	require.EqualError(t, notFoundErr, "not_found")
+	require.True(t, errors.Is(notFoundErr, fosite.ErrNotFound))
+}
+
+func TestGetExpired(t *testing.T) {
+	ctx, _, _, clock, storage := makeTestSubject()
+
+	err := storage.CreatePushedAuthorizeRequest(ctx, requestURI, &PushedAuthorizeRequest{
+		ClientID:  "pinniped-cli",
+		Params:    url.Values{"client_id": []string{"pinniped-cli"}},
+		ExpiresAt: fakeNow.Add(90 * time.Second),
+	})
+	require.NoError(t, err)
+
+	clock.Step(90 * time.Second)
+
+	_, expiredErr := storage.GetPushedAuthorizeRequest(ctx, requestURI)
+	require.EqualError(t, expiredErr, "not_found")
+	require.True(t, errors.Is(expiredErr, fosite.ErrNotFound))
+}
+
+func TestWrongVersion(t *testing.T) {
+	ctx, _, secrets, _, storage := makeTestSubject()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            secretName,
+			ResourceVersion: "",
+			Labels: map[string]string{
+				"storage.pinniped.dev/type": "pushed-authorize-request",
+			},
+			Annotations: map[string]string{
+				"storage.pinniped.dev/garbage-collect-after": fakeNowPlusLifetimeAsString,
+			},
+		},
+		Data: map[string][]byte{
+			"pinniped-storage-data":    []byte(`{"request":{"clientID":"pinniped-cli"},"version":"not-the-right-version"}`),
+			"pinniped-storage-version": []byte("1"),
+		},
+		Type: "storage.pinniped.dev/pushed-authorize-request",
+	}
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, err = storage.GetPushedAuthorizeRequest(ctx, requestURI)
+
+	require.EqualError(t, err, "pushed authorize request data has wrong version: pushed authorize request for "+requestURI+" has version not-the-right-version instead of 1")
+}
+
+func TestNilSessionRequest(t *testing.T) {
+	ctx, _, secrets, _, storage := makeTestSubject()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            secretName,
+			ResourceVersion: "",
+			Labels: map[string]string{
+				"storage.pinniped.dev/type": "pushed-authorize-request",
+			},
+			Annotations: map[string]string{
+				"storage.pinniped.dev/garbage-collect-after": fakeNowPlusLifetimeAsString,
+			},
+		},
+		Data: map[string][]byte{
+			"pinniped-storage-data":    []byte(`{"nonsense-key": "nonsense-value","version":"1"}`),
+			"pinniped-storage-version": []byte("1"),
+		},
+		Type: "storage.pinniped.dev/pushed-authorize-request",
+	}
+
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, err = storage.GetPushedAuthorizeRequest(ctx, requestURI)
+	require.EqualError(t, err, "malformed pushed authorize request for "+requestURI+": pushed authorize request data must be present")
+}
+
+func TestCreateWithNilRequest(t *testing.T) {
+	ctx, _, _, _, storage := makeTestSubject()
+
+	err := storage.CreatePushedAuthorizeRequest(ctx, "request-uri-doesnt-matter", nil)
+	require.EqualError(t, err, "pushed authorize request data must be present")
+}
+
+func makeTestSubject() (context.Context, *fake.Clientset, corev1client.SecretInterface, *clocktesting.FakeClock, PushedAuthorizeRequestStorage) {
+	client := fake.NewSimpleClientset()
+	secrets := client.CoreV1().Secrets(namespace)
+	clock := clocktesting.NewFakeClock(fakeNow)
+	return context.Background(), client, secrets, clock, New(secrets, clock.Now, lifetime)
+}
@@ -64,6 +64,49 @@ func (mr *MockConnMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockConn)(nil).Close))
 }
 
+// ExternalBind mocks base method.
+func (m *MockConn) ExternalBind() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExternalBind")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExternalBind indicates an expected call of ExternalBind.
+func (mr *MockConnMockRecorder) ExternalBind() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExternalBind", reflect.TypeOf((*MockConn)(nil).ExternalBind))
+}
+
+// Modify mocks base method.
+func (m *MockConn) Modify(arg0 *ldap.ModifyRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Modify", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Modify indicates an expected call of Modify.
+func (mr *MockConnMockRecorder) Modify(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Modify", reflect.TypeOf((*MockConn)(nil).Modify), arg0)
+}
+
+// PasswordModify mocks base method.
+func (m *MockConn) PasswordModify(arg0 *ldap.PasswordModifyRequest) (*ldap.PasswordModifyResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PasswordModify", arg0)
+	ret0, _ := ret[0].(*ldap.PasswordModifyResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PasswordModify indicates an expected call of PasswordModify.
+func (mr *MockConnMockRecorder) PasswordModify(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PasswordModify", reflect.TypeOf((*MockConn)(nil).PasswordModify), arg0)
+}
+
 // Search mocks base method.
 func (m *MockConn) Search(arg0 *ldap.SearchRequest) (*ldap.SearchResult, error) {
 	m.ctrl.T.Helper()
@@ -93,3 +136,18 @@ func (mr *MockConnMockRecorder) SearchWithPaging(arg0, arg1 interface{}) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchWithPaging", reflect.TypeOf((*MockConn)(nil).SearchWithPaging), arg0, arg1)
 }
+
+// SimpleBind mocks base method.
+func (m *MockConn) SimpleBind(arg0 *ldap.SimpleBindRequest) (*ldap.SimpleBindResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SimpleBind", arg0)
+	ret0, _ := ret[0].(*ldap.SimpleBindResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SimpleBind indicates an expected call of SimpleBind.
+func (mr *MockConnMockRecorder) SimpleBind(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SimpleBind", reflect.TypeOf((*MockConn)(nil).SimpleBind), arg0)
+}
@@ -4,6 +4,7 @@
 package phttp
 
 import (
+	"crypto/tls"
 	"crypto/x509"
 	"net/http"
 	"time"
@@ -24,6 +25,16 @@ func Secure(rootCAs *x509.CertPool) *http.Client {
 	return buildClient(ptls.Secure, rootCAs)
 }
 
+// DefaultWithClientCertificate is like Default, but the returned client also presents the given client certificate
+// during TLS handshakes.
+func DefaultWithClientCertificate(rootCAs *x509.CertPool, certificate tls.Certificate) *http.Client {
+	return buildClient(func(rootCAs *x509.CertPool) *tls.Config {
+		c := ptls.Default(rootCAs)
+		c.Certificates = []tls.Certificate{certificate}
+		return c
+	}, rootCAs)
+}
+
 func buildClient(tlsConfigFunc ptls.ConfigFunc, rootCAs *x509.CertPool) *http.Client {
 	baseRT := defaultTransport()
 	baseRT.TLSClientConfig = tlsConfigFunc(rootCAs)
@@ -24,9 +24,12 @@ import (
 func TestUnwrap(t *testing.T) {
 	t.Parallel()
 
+	clientCertificate := tls.Certificate{Certificate: [][]byte{[]byte("some-certificate")}}
+
 	tests := []struct {
-		name string
-		f    func(*x509.CertPool) *http.Client
+		name             string
+		f                func(*x509.CertPool) *http.Client
+		wantCertificates []tls.Certificate
 	}{
 		{
 			name: "default",
@@ -36,6 +39,13 @@ func TestUnwrap(t *testing.T) {
 			name: "secure",
 			f:    Secure,
 		},
+		{
+			name: "default with client certificate",
+			f: func(rootCAs *x509.CertPool) *http.Client {
+				return DefaultWithClientCertificate(rootCAs, clientCertificate)
+			},
+			wantCertificates: []tls.Certificate{clientCertificate},
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -54,6 +64,7 @@ func TestUnwrap(t *testing.T) {
 			require.NotEmpty(t, tlsConfig.NextProtos)
 			require.GreaterOrEqual(t, tlsConfig.MinVersion, uint16(tls.VersionTLS12))
 			require.Equal(t, p, tlsConfig.RootCAs)
+			require.Equal(t, tt.wantCertificates, tlsConfig.Certificates)
 		})
 	}
 }
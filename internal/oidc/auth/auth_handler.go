@@ -5,9 +5,11 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
@@ -17,6 +19,8 @@ import (
 	"golang.org/x/oauth2"
 
 	supervisoroidc "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
+	"go.pinniped.dev/internal/authenticators"
+	"go.pinniped.dev/internal/fositestorage/pushedauthorizerequest"
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/httputil/securityheader"
 	"go.pinniped.dev/internal/oidc"
@@ -33,6 +37,10 @@ import (
 const (
 	promptParamName = "prompt"
 	promptParamNone = "none"
+
+	clientIDParamName   = "client_id"
+	requestParamName    = "request"
+	requestURIParamName = "request_uri"
 )
 
 func NewHandler(
@@ -45,6 +53,8 @@ func NewHandler(
 	generateNonce func() (nonce.Nonce, error),
 	upstreamStateEncoder oidc.Encoder,
 	cookieCodec oidc.Codec,
+	pushedAuthorizeRequestStorage pushedauthorizerequest.PushedAuthorizeRequestStorage,
+	requestObjectVerifier *provider.RequestObjectVerifier,
 ) http.Handler {
 	return securityheader.Wrap(httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
 		if r.Method != http.MethodPost && r.Method != http.MethodGet {
@@ -54,6 +64,13 @@ func NewHandler(
 			return httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET or POST)", r.Method)
 		}
 
+		// The params of the request might have been pushed to the pushed authorization request endpoint, or they
+		// might be inside a signed request object. Either way, replace the params of the request with them before
+		// fosite reads the params.
+		if err := resolveAuthorizeParams(r, downstreamIssuer, pushedAuthorizeRequestStorage, requestObjectVerifier); err != nil {
+			return err
+		}
+
 		// Note that the client might have used supervisoroidc.AuthorizeUpstreamIDPNameParamName and
 		// supervisoroidc.AuthorizeUpstreamIDPTypeParamName query params to request a certain upstream IDP.
 		// The Pinniped CLI has been sending these params since v0.9.0.
@@ -108,6 +125,68 @@ func NewHandler(
 	}))
 }
 
+// resolveAuthorizeParams replaces the form of the request with the params of a pushed authorization request, when
+// the request has a request_uri param (RFC 9126), or with the claims of a signed request object, when the request has
+// a request param (RFC 9101). In both cases, the other params of the request are ignored, except for the client_id
+// which must match.
+func resolveAuthorizeParams(
+	r *http.Request,
+	downstreamIssuer string,
+	pushedAuthorizeRequestStorage pushedauthorizerequest.PushedAuthorizeRequestStorage,
+	requestObjectVerifier *provider.RequestObjectVerifier,
+) error {
+	if err := r.ParseForm(); err != nil {
+		return httperr.Wrap(http.StatusBadRequest, "unable to parse form params", err)
+	}
+
+	clientID := r.Form.Get(clientIDParamName)
+	requestURI := r.Form.Get(requestURIParamName)
+	requestObject := r.Form.Get(requestParamName)
+
+	switch {
+	case requestURI != "" && requestObject != "":
+		return httperr.New(http.StatusBadRequest, "request and request_uri params must not both be present")
+
+	case requestURI != "":
+		if !strings.HasPrefix(requestURI, oidc.PushedAuthorizeRequestURIPrefix) {
+			return httperr.New(http.StatusBadRequest, "request_uri must have been returned by the pushed authorization request endpoint")
+		}
+		pushedRequest, err := pushedAuthorizeRequestStorage.GetPushedAuthorizeRequest(r.Context(), requestURI)
+		if errors.Is(err, fosite.ErrNotFound) {
+			return httperr.New(http.StatusBadRequest, "request_uri is invalid or has expired")
+		}
+		if err != nil {
+			plog.WarningErr("error reading pushed authorization request", err)
+			return httperr.Wrap(http.StatusInternalServerError, "error reading pushed authorization request", err)
+		}
+		// Each request_uri may only be used once.
+		if err := pushedAuthorizeRequestStorage.DeletePushedAuthorizeRequest(r.Context(), requestURI); err != nil {
+			plog.WarningErr("error deleting pushed authorization request", err)
+			return httperr.Wrap(http.StatusInternalServerError, "error deleting pushed authorization request", err)
+		}
+		if pushedRequest.ClientID != clientID {
+			return httperr.New(http.StatusBadRequest, "client_id param must match the client which pushed the request_uri")
+		}
+		replaceForm(r, pushedRequest.Params)
+
+	case requestObject != "":
+		params, err := requestObjectVerifier.Verify(requestObject, downstreamIssuer, clientID, time.Now())
+		if err != nil {
+			return httperr.Newf(http.StatusBadRequest, "invalid request object: %s", err.Error())
+		}
+		replaceForm(r, params)
+	}
+
+	return nil
+}
+
+// replaceForm makes fosite read the given params instead of the query and body params of the request, since
+// fosite does not parse the request again when r.Form is already set.
+func replaceForm(r *http.Request, params url.Values) {
+	r.Form = params
+	r.PostForm = url.Values{}
+}
+
 func handleAuthRequestForLDAPUpstreamCLIFlow(
 	r *http.Request,
 	w http.ResponseWriter,
@@ -126,6 +205,12 @@ func handleAuthRequestForLDAPUpstreamCLIFlow(
 	}
 
 	authenticateResponse, authenticated, err := ldapUpstream.AuthenticateUser(r.Context(), username, password)
+	if errors.Is(err, authenticators.ErrPasswordChangeRequired) {
+		// The CLI cannot prompt for a new password, so the user must change it using the browser-based flow.
+		oidc.WriteAuthorizeError(w, oauthHelper, authorizeRequester,
+			fosite.ErrAccessDenied.WithHintf("The password must be changed before logging in. Please log in using a browser to change it."), true)
+		return nil
+	}
 	if err != nil {
 		plog.WarningErr("unexpected error during upstream LDAP authentication", err, "upstreamName", ldapUpstream.GetName())
 		return httperr.New(http.StatusBadGateway, "unexpected error during upstream authentication")
@@ -141,6 +226,7 @@ func handleAuthRequestForLDAPUpstreamCLIFlow(
 	groups := authenticateResponse.User.GetGroups()
 	customSessionData := downstreamsession.MakeDownstreamLDAPOrADCustomSessionData(ldapUpstream, idpType, authenticateResponse)
 	openIDSession := downstreamsession.MakeDownstreamSession(subject, username, groups, customSessionData)
+	downstreamsession.SetAdditionalClaims(openIDSession, authorizeRequester.GetGrantedScopes(), authenticateResponse.AdditionalClaims)
 	oidc.PerformAuthcodeRedirect(r, w, oauthHelper, authorizeRequester, openIDSession, true)
 
 	return nil
@@ -324,7 +410,7 @@ func newAuthorizeRequest(r *http.Request, w http.ResponseWriter, oauthHelper fos
 		return nil, false
 	}
 
-	// Automatically grant the openid, offline_access, and pinniped:request-audience scopes, but only if they were requested.
+	// Automatically grant the openid, offline_access, profile, email, and pinniped:request-audience scopes, but only if they were requested.
 	// Grant the openid scope (for now) if they asked for it so that `NewAuthorizeResponse` will perform its OIDC validations.
 	// There don't seem to be any validations inside `NewAuthorizeResponse` related to the offline_access scope
 	// at this time, however we will temporarily grant the scope just in case that changes in a future release of fosite.
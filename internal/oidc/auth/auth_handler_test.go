@@ -5,6 +5,10 @@ package auth
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html"
@@ -20,6 +24,8 @@ import (
 	"github.com/ory/fosite"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/client-go/kubernetes/fake"
@@ -27,6 +33,7 @@ import (
 	"k8s.io/utils/pointer"
 
 	"go.pinniped.dev/internal/authenticators"
+	"go.pinniped.dev/internal/fositestorage/pushedauthorizerequest"
 	"go.pinniped.dev/internal/here"
 	"go.pinniped.dev/internal/oidc"
 	"go.pinniped.dev/internal/oidc/csrftoken"
@@ -68,6 +75,7 @@ func TestAuthorizationEndpoint(t *testing.T) {
 		downstreamPKCEChallengeMethod          = "S256"
 		happyState                             = "8b-state"
 		downstreamClientID                     = "pinniped-cli"
+		happyRequestURI                        = "urn:ietf:params:oauth:request_uri:some-pushed-request"
 		upstreamLDAPURL                        = "ldaps://some-ldap-host:123?base=ou%3Dusers%2Cdc%3Dpinniped%2Cdc%3Ddev"
 		htmlContentType                        = "text/html; charset=utf-8"
 		jsonContentType                        = "application/json; charset=utf-8"
@@ -153,6 +161,12 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			"state":             happyState,
 		}
 
+		fositeAccessDeniedWithPasswordChangeRequiredHintErrorQuery = map[string]string{
+			"error":             "access_denied",
+			"error_description": "The resource owner or authorization server denied the request. The password must be changed before logging in. Please log in using a browser to change it.",
+			"state":             happyState,
+		}
+
 		fositeAccessDeniedWithMissingUsernamePasswordHintErrorQuery = map[string]string{
 			"error":             "access_denied",
 			"error_description": "The resource owner or authorization server denied the request. Missing or blank username or password.",
@@ -223,12 +237,12 @@ func TestAuthorizationEndpoint(t *testing.T) {
 		// Configure fosite the same way that the production code would when using Kube storage.
 		// Inject this into our test subject at the last second so we get a fresh storage for every test.
 		kubeOauthStore := oidc.NewKubeStorage(secretsClient, timeoutsConfiguration)
-		return oidc.FositeOauth2Helper(kubeOauthStore, downstreamIssuer, hmacSecretFunc, jwksProviderIsUnused, timeoutsConfiguration), kubeOauthStore
+		return oidc.FositeOauth2Helper(kubeOauthStore, downstreamIssuer, hmacSecretFunc, jwksProviderIsUnused, timeoutsConfiguration, nil), kubeOauthStore
 	}
 
 	// Configure fosite the same way that the production code would, using NullStorage to turn off storage.
 	nullOauthStore := oidc.NullStorage{}
-	oauthHelperWithNullStorage := oidc.FositeOauth2Helper(nullOauthStore, downstreamIssuer, hmacSecretFunc, jwksProviderIsUnused, timeoutsConfiguration)
+	oauthHelperWithNullStorage := oidc.FositeOauth2Helper(nullOauthStore, downstreamIssuer, hmacSecretFunc, jwksProviderIsUnused, timeoutsConfiguration, nil)
 
 	upstreamAuthURL, err := url.Parse("https://some-upstream-idp:8443/auth")
 	require.NoError(t, err)
@@ -328,6 +342,14 @@ func TestAuthorizationEndpoint(t *testing.T) {
 		},
 	}
 
+	passwordChangeRequiredUpstreamLDAPIdentityProvider := oidctestutil.TestUpstreamLDAPIdentityProvider{
+		Name:        ldapUpstreamName,
+		ResourceUID: ldapUpstreamResourceUID,
+		AuthenticateFunc: func(ctx context.Context, username, password string) (*authenticators.Response, bool, error) {
+			return nil, false, fmt.Errorf("user %q must change their password: %w", username, authenticators.ErrPasswordChangeRequired)
+		},
+	}
+
 	happyCSRF := "test-csrf"
 	happyPKCE := "test-pkce"
 	happyNonce := "test-nonce"
@@ -376,7 +398,7 @@ func TestAuthorizationEndpoint(t *testing.T) {
 	}
 
 	happyDownstreamScopesRequested := []string{"openid", "profile", "email"}
-	happyDownstreamScopesGranted := []string{"openid"}
+	happyDownstreamScopesGranted := []string{"openid", "profile", "email"}
 
 	happyGetRequestQueryMap := map[string]string{
 		"response_type":         "code",
@@ -411,6 +433,46 @@ func TestAuthorizationEndpoint(t *testing.T) {
 		return pathWithQuery("/some/path", modifiedHappyGetRequestQueryMap(queryOverrides))
 	}
 
+	happyPushedAuthorizeRequest := func(clientID string, expiresAt time.Time) *pushedauthorizerequest.PushedAuthorizeRequest {
+		params := url.Values{}
+		for k, v := range happyGetRequestQueryMap {
+			params.Set(k, v)
+		}
+		return &pushedauthorizerequest.PushedAuthorizeRequest{ClientID: clientID, Params: params, ExpiresAt: expiresAt}
+	}
+
+	requestObjectKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherRequestObjectKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	requestObjectJWKS, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &requestObjectKey.PublicKey, KeyID: "client-key", Algorithm: string(jose.ES256)},
+	}})
+	require.NoError(t, err)
+	happyRequestObjectVerifier, err := provider.NewRequestObjectVerifier([]provider.RequestObjectClient{
+		{ID: downstreamClientID, JWKS: string(requestObjectJWKS)},
+	})
+	require.NoError(t, err)
+
+	signedRequestObject := func(key *ecdsa.PrivateKey) string {
+		claims := map[string]interface{}{
+			"iss": downstreamClientID,
+			"aud": downstreamIssuer,
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+		for k, v := range happyGetRequestQueryMap {
+			claims[k] = v
+		}
+		signer, err := jose.NewSigner(
+			jose.SigningKey{Algorithm: jose.ES256, Key: key},
+			(&jose.SignerOptions{}).WithHeader("kid", "client-key"),
+		)
+		require.NoError(t, err)
+		token, err := josejwt.Signed(signer).Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
 	expectedUpstreamStateParam := func(queryOverrides map[string]string, csrfValueOverride, upstreamName, upstreamType string) string {
 		csrf := happyCSRF
 		if csrfValueOverride != "" {
@@ -495,7 +557,7 @@ func TestAuthorizationEndpoint(t *testing.T) {
 	}
 
 	// Note that fosite puts the granted scopes as a param in the redirect URI even though the spec doesn't seem to require it
-	happyAuthcodeDownstreamRedirectLocationRegexp := downstreamRedirectURI + `\?code=([^&]+)&scope=openid&state=` + happyState
+	happyAuthcodeDownstreamRedirectLocationRegexp := downstreamRedirectURI + `\?code=([^&]+)&scope=openid\+profile\+email&state=` + happyState
 
 	incomingCookieCSRFValue := "csrf-value-from-cookie"
 	encodedIncomingCookieCSRFValue, err := happyCookieEncoder.Encode("csrf", incomingCookieCSRFValue)
@@ -518,6 +580,9 @@ func TestAuthorizationEndpoint(t *testing.T) {
 		customUsernameHeader *string // nil means do not send header, empty means send header with empty value
 		customPasswordHeader *string // nil means do not send header, empty means send header with empty value
 
+		pushedAuthorizeRequest *pushedauthorizerequest.PushedAuthorizeRequest // stored using happyRequestURI before the request
+		requestObjectVerifier  *provider.RequestObjectVerifier
+
 		wantStatus                             int
 		wantContentType                        string
 		wantBodyString                         string
@@ -638,6 +703,47 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
 			wantDownstreamCustomSessionData:   expectedHappyLDAPUpstreamCustomSession,
 		},
+		{
+			name: "LDAP cli upstream happy path when the authenticator returns warnings, stores them in the session",
+			idps: oidctestutil.NewUpstreamIDPListerBuilder().WithLDAP(&oidctestutil.TestUpstreamLDAPIdentityProvider{
+				Name:        ldapUpstreamName,
+				ResourceUID: ldapUpstreamResourceUID,
+				URL:         parsedUpstreamLDAPURL,
+				AuthenticateFunc: func(ctx context.Context, username, password string) (*authenticators.Response, bool, error) {
+					response, authenticated, err := ldapAuthenticateFunc(ctx, username, password)
+					if authenticated {
+						response.Warnings = []string{"Your password will expire in 3 days. Please change your password."}
+					}
+					return response, authenticated, err
+				},
+			}),
+			method:                            http.MethodGet,
+			path:                              happyGetRequestPath,
+			customUsernameHeader:              pointer.StringPtr(happyLDAPUsername),
+			customPasswordHeader:              pointer.StringPtr(happyLDAPPassword),
+			wantStatus:                        http.StatusFound,
+			wantContentType:                   htmlContentType,
+			wantRedirectLocationRegexp:        happyAuthcodeDownstreamRedirectLocationRegexp,
+			wantDownstreamIDTokenSubject:      upstreamLDAPURL + "&sub=" + happyLDAPUID,
+			wantDownstreamIDTokenUsername:     happyLDAPUsernameFromAuthenticator,
+			wantDownstreamIDTokenGroups:       happyLDAPGroups,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamRedirectURI:         downstreamRedirectURI,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantDownstreamCustomSessionData: &psession.CustomSessionData{
+				ProviderUID:  ldapUpstreamResourceUID,
+				ProviderName: ldapUpstreamName,
+				ProviderType: psession.ProviderTypeLDAP,
+				LDAP: &psession.LDAPSessionData{
+					UserDN:                 happyLDAPUserDN,
+					ExtraRefreshAttributes: map[string]string{happyLDAPExtraRefreshAttribute: happyLDAPExtraRefreshValue},
+				},
+				Warnings: []string{"Your password will expire in 3 days. Please change your password."},
+			},
+		},
 		{
 			name:                              "ActiveDirectory cli upstream happy path using GET",
 			idps:                              oidctestutil.NewUpstreamIDPListerBuilder().WithActiveDirectory(&upstreamActiveDirectoryIdentityProvider),
@@ -956,7 +1062,7 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			wantPasswordGrantCall:             happyUpstreamPasswordGrantMockExpectation,
 			wantStatus:                        http.StatusFound,
 			wantContentType:                   htmlContentType,
-			wantRedirectLocationRegexp:        downstreamRedirectURIWithDifferentPort + `\?code=([^&]+)&scope=openid&state=` + happyState,
+			wantRedirectLocationRegexp:        downstreamRedirectURIWithDifferentPort + `\?code=([^&]+)&scope=openid\+profile\+email&state=` + happyState,
 			wantDownstreamIDTokenSubject:      oidcUpstreamIssuer + "?sub=" + oidcUpstreamSubjectQueryEscaped,
 			wantDownstreamIDTokenUsername:     oidcUpstreamUsername,
 			wantDownstreamIDTokenGroups:       oidcUpstreamGroupMembership,
@@ -979,7 +1085,7 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			customPasswordHeader:              pointer.StringPtr(happyLDAPPassword),
 			wantStatus:                        http.StatusFound,
 			wantContentType:                   htmlContentType,
-			wantRedirectLocationRegexp:        downstreamRedirectURIWithDifferentPort + `\?code=([^&]+)&scope=openid&state=` + happyState,
+			wantRedirectLocationRegexp:        downstreamRedirectURIWithDifferentPort + `\?code=([^&]+)&scope=openid\+profile\+email&state=` + happyState,
 			wantDownstreamIDTokenSubject:      upstreamLDAPURL + "&sub=" + happyLDAPUID,
 			wantDownstreamIDTokenUsername:     happyLDAPUsernameFromAuthenticator,
 			wantDownstreamIDTokenGroups:       happyLDAPGroups,
@@ -1108,6 +1214,18 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			wantContentType:      htmlContentType,
 			wantBodyString:       "Bad Gateway: unexpected error during upstream authentication\n",
 		},
+		{
+			name:                 "upstream LDAP authentication requires a password change",
+			idps:                 oidctestutil.NewUpstreamIDPListerBuilder().WithLDAP(&passwordChangeRequiredUpstreamLDAPIdentityProvider),
+			method:               http.MethodGet,
+			path:                 happyGetRequestPath,
+			customUsernameHeader: pointer.StringPtr(happyLDAPUsername),
+			customPasswordHeader: pointer.StringPtr(happyLDAPPassword),
+			wantStatus:           http.StatusFound,
+			wantContentType:      jsonContentType,
+			wantLocationHeader:   urlWithQuery(downstreamRedirectURI, fositeAccessDeniedWithPasswordChangeRequiredHintErrorQuery),
+			wantBodyString:       "",
+		},
 		{
 			name: "wrong upstream credentials for OIDC password grant authentication",
 			idps: oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(
@@ -1913,13 +2031,13 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			wantPasswordGrantCall:             happyUpstreamPasswordGrantMockExpectation,
 			wantStatus:                        http.StatusFound,
 			wantContentType:                   htmlContentType,
-			wantRedirectLocationRegexp:        downstreamRedirectURI + `\?code=([^&]+)&scope=&state=` + happyState, // no scopes granted
+			wantRedirectLocationRegexp:        downstreamRedirectURI + `\?code=([^&]+)&scope=email&state=` + happyState, // only email was granted
 			wantDownstreamIDTokenSubject:      oidcUpstreamIssuer + "?sub=" + oidcUpstreamSubjectQueryEscaped,
 			wantDownstreamIDTokenUsername:     oidcUpstreamUsername,
 			wantDownstreamIDTokenGroups:       oidcUpstreamGroupMembership,
 			wantDownstreamRequestedScopes:     []string{"email"}, // only email was requested
 			wantDownstreamRedirectURI:         downstreamRedirectURI,
-			wantDownstreamGrantedScopes:       []string{}, // no scopes granted
+			wantDownstreamGrantedScopes:       []string{"email"}, // only email was granted
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
@@ -1935,13 +2053,13 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			customPasswordHeader:              pointer.StringPtr(happyLDAPPassword),
 			wantStatus:                        http.StatusFound,
 			wantContentType:                   htmlContentType,
-			wantRedirectLocationRegexp:        downstreamRedirectURI + `\?code=([^&]+)&scope=&state=` + happyState, // no scopes granted
+			wantRedirectLocationRegexp:        downstreamRedirectURI + `\?code=([^&]+)&scope=email&state=` + happyState, // only email was granted
 			wantDownstreamIDTokenSubject:      upstreamLDAPURL + "&sub=" + happyLDAPUID,
 			wantDownstreamIDTokenUsername:     happyLDAPUsernameFromAuthenticator,
 			wantDownstreamIDTokenGroups:       happyLDAPGroups,
 			wantDownstreamRequestedScopes:     []string{"email"}, // only email was requested
 			wantDownstreamRedirectURI:         downstreamRedirectURI,
-			wantDownstreamGrantedScopes:       []string{}, // no scopes granted
+			wantDownstreamGrantedScopes:       []string{"email"}, // only email was granted
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
@@ -2529,6 +2647,108 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			wantContentType: "text/plain; charset=utf-8",
 			wantBodyString:  "Unprocessable Entity: Too many upstream providers are configured (support for multiple upstreams is not yet implemented)\n",
 		},
+		{
+			name:                                   "OIDC upstream browser flow happy path using a request_uri from a pushed authorization request",
+			idps:                                   oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
+			generateCSRF:                           happyCSRFGenerator,
+			generatePKCE:                           happyPKCEGenerator,
+			generateNonce:                          happyNonceGenerator,
+			stateEncoder:                           happyStateEncoder,
+			cookieEncoder:                          happyCookieEncoder,
+			method:                                 http.MethodGet,
+			path:                                   pathWithQuery("/some/path", map[string]string{"client_id": downstreamClientID, "request_uri": happyRequestURI}),
+			pushedAuthorizeRequest:                 happyPushedAuthorizeRequest(downstreamClientID, time.Now().Add(time.Minute)),
+			wantStatus:                             http.StatusSeeOther,
+			wantContentType:                        htmlContentType,
+			wantCSRFValueInCookieHeader:            happyCSRF,
+			wantLocationHeader:                     expectedRedirectLocationForUpstreamOIDC(expectedUpstreamStateParam(nil, "", oidcUpstreamName, "oidc"), nil),
+			wantUpstreamStateParamInLocationHeader: true,
+			wantBodyStringWithLocationInHref:       true,
+		},
+		{
+			name:                                   "OIDC upstream browser flow happy path using a signed request object",
+			idps:                                   oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
+			generateCSRF:                           happyCSRFGenerator,
+			generatePKCE:                           happyPKCEGenerator,
+			generateNonce:                          happyNonceGenerator,
+			stateEncoder:                           happyStateEncoder,
+			cookieEncoder:                          happyCookieEncoder,
+			method:                                 http.MethodGet,
+			path:                                   pathWithQuery("/some/path", map[string]string{"client_id": downstreamClientID, "request": signedRequestObject(requestObjectKey)}),
+			requestObjectVerifier:                  happyRequestObjectVerifier,
+			wantStatus:                             http.StatusSeeOther,
+			wantContentType:                        htmlContentType,
+			wantCSRFValueInCookieHeader:            happyCSRF,
+			wantLocationHeader:                     expectedRedirectLocationForUpstreamOIDC(expectedUpstreamStateParam(nil, "", oidcUpstreamName, "oidc"), nil),
+			wantUpstreamStateParamInLocationHeader: true,
+			wantBodyStringWithLocationInHref:       true,
+		},
+		{
+			name:            "request_uri which was not returned by the pushed authorization request endpoint",
+			idps:            oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
+			method:          http.MethodGet,
+			path:            pathWithQuery("/some/path", map[string]string{"client_id": downstreamClientID, "request_uri": "https://client.example.com/request.jwt"}),
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Bad Request: request_uri must have been returned by the pushed authorization request endpoint\n",
+		},
+		{
+			name:            "request_uri which was never pushed",
+			idps:            oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
+			method:          http.MethodGet,
+			path:            pathWithQuery("/some/path", map[string]string{"client_id": downstreamClientID, "request_uri": happyRequestURI}),
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Bad Request: request_uri is invalid or has expired\n",
+		},
+		{
+			name:                   "request_uri which has expired",
+			idps:                   oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
+			pushedAuthorizeRequest: happyPushedAuthorizeRequest(downstreamClientID, time.Now().Add(-time.Second)),
+			method:                 http.MethodGet,
+			path:                   pathWithQuery("/some/path", map[string]string{"client_id": downstreamClientID, "request_uri": happyRequestURI}),
+			wantStatus:             http.StatusBadRequest,
+			wantContentType:        "text/plain; charset=utf-8",
+			wantBodyString:         "Bad Request: request_uri is invalid or has expired\n",
+		},
+		{
+			name:                   "request_uri which was pushed by another client",
+			idps:                   oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
+			pushedAuthorizeRequest: happyPushedAuthorizeRequest("some-other-client", time.Now().Add(time.Minute)),
+			method:                 http.MethodGet,
+			path:                   pathWithQuery("/some/path", map[string]string{"client_id": downstreamClientID, "request_uri": happyRequestURI}),
+			wantStatus:             http.StatusBadRequest,
+			wantContentType:        "text/plain; charset=utf-8",
+			wantBodyString:         "Bad Request: client_id param must match the client which pushed the request_uri\n",
+		},
+		{
+			name:            "both request and request_uri params",
+			idps:            oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
+			method:          http.MethodGet,
+			path:            pathWithQuery("/some/path", map[string]string{"client_id": downstreamClientID, "request_uri": happyRequestURI, "request": signedRequestObject(requestObjectKey)}),
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Bad Request: request and request_uri params must not both be present\n",
+		},
+		{
+			name:            "request object when request objects are not configured",
+			idps:            oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
+			method:          http.MethodGet,
+			path:            pathWithQuery("/some/path", map[string]string{"client_id": downstreamClientID, "request": signedRequestObject(requestObjectKey)}),
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Bad Request: invalid request object: request objects are not supported by this federation domain\n",
+		},
+		{
+			name:                  "request object signed by an unknown key",
+			idps:                  oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
+			requestObjectVerifier: happyRequestObjectVerifier,
+			method:                http.MethodGet,
+			path:                  pathWithQuery("/some/path", map[string]string{"client_id": downstreamClientID, "request": signedRequestObject(otherRequestObjectKey)}),
+			wantStatus:            http.StatusBadRequest,
+			wantContentType:       "text/plain; charset=utf-8",
+			wantBodyString:        "Bad Request: invalid request object: request object signature could not be verified\n",
+		},
 		{
 			name:            "PUT is a bad method",
 			idps:            oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
@@ -2671,12 +2891,18 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			kubeClient := fake.NewSimpleClientset()
 			secretsClient := kubeClient.CoreV1().Secrets("some-namespace")
 			oauthHelperWithRealStorage, kubeOauthStore := createOauthHelperWithRealStorage(secretsClient)
+			// Use separate storage for the pushed authorization requests, so that they are not counted as stored records.
+			pushedAuthorizeRequestStorage := newPushedAuthorizeRequestStorage()
+			if test.pushedAuthorizeRequest != nil {
+				require.NoError(t, pushedAuthorizeRequestStorage.CreatePushedAuthorizeRequest(context.Background(), happyRequestURI, test.pushedAuthorizeRequest))
+			}
 			subject := NewHandler(
 				downstreamIssuer,
 				test.idps.Build(),
 				oauthHelperWithNullStorage, oauthHelperWithRealStorage,
 				test.generateCSRF, test.generatePKCE, test.generateNonce,
 				test.stateEncoder, test.cookieEncoder,
+				pushedAuthorizeRequestStorage, test.requestObjectVerifier,
 			)
 			runOneTestCase(t, test, subject, kubeOauthStore, kubeClient, secretsClient)
 		})
@@ -2697,6 +2923,7 @@ func TestAuthorizationEndpoint(t *testing.T) {
 			oauthHelperWithNullStorage, oauthHelperWithRealStorage,
 			test.generateCSRF, test.generatePKCE, test.generateNonce,
 			test.stateEncoder, test.cookieEncoder,
+			newPushedAuthorizeRequestStorage(), nil,
 		)
 
 		runOneTestCase(t, test, subject, kubeOauthStore, kubeClient, secretsClient)
@@ -2739,6 +2966,45 @@ func TestAuthorizationEndpoint(t *testing.T) {
 		// on every request.
 		runOneTestCase(t, test, subject, kubeOauthStore, kubeClient, secretsClient)
 	})
+
+	t.Run("allows each request_uri to be used only once", func(t *testing.T) {
+		// Re-use the pushed authorization request happy path test case.
+		var test testCase
+		for _, tc := range tests {
+			if tc.name == "OIDC upstream browser flow happy path using a request_uri from a pushed authorization request" {
+				test = tc
+			}
+		}
+		require.NotNil(t, test.pushedAuthorizeRequest)
+
+		kubeClient := fake.NewSimpleClientset()
+		secretsClient := kubeClient.CoreV1().Secrets("some-namespace")
+		oauthHelperWithRealStorage, kubeOauthStore := createOauthHelperWithRealStorage(secretsClient)
+		pushedAuthorizeRequestStorage := newPushedAuthorizeRequestStorage()
+		require.NoError(t, pushedAuthorizeRequestStorage.CreatePushedAuthorizeRequest(context.Background(), happyRequestURI, test.pushedAuthorizeRequest))
+		subject := NewHandler(
+			downstreamIssuer,
+			test.idps.Build(),
+			oauthHelperWithNullStorage, oauthHelperWithRealStorage,
+			test.generateCSRF, test.generatePKCE, test.generateNonce,
+			test.stateEncoder, test.cookieEncoder,
+			pushedAuthorizeRequestStorage, nil,
+		)
+
+		runOneTestCase(t, test, subject, kubeOauthStore, kubeClient, secretsClient)
+
+		// The pushed authorization request was deleted when it was used, so using the request_uri again fails.
+		_, err := pushedAuthorizeRequestStorage.GetPushedAuthorizeRequest(context.Background(), happyRequestURI)
+		require.ErrorIs(t, err, fosite.ErrNotFound)
+		rsp := httptest.NewRecorder()
+		subject.ServeHTTP(rsp, httptest.NewRequest(test.method, test.path, nil))
+		require.Equal(t, http.StatusBadRequest, rsp.Code)
+		require.Equal(t, "Bad Request: request_uri is invalid or has expired\n", rsp.Body.String())
+	})
+}
+
+func newPushedAuthorizeRequestStorage() pushedauthorizerequest.PushedAuthorizeRequestStorage {
+	return pushedauthorizerequest.New(fake.NewSimpleClientset().CoreV1().Secrets("some-namespace"), time.Now, time.Minute)
 }
 
 type errorReturningEncoder struct {
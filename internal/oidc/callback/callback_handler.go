@@ -51,7 +51,7 @@ func NewHandler(
 			return httperr.New(http.StatusBadRequest, "error using state downstream auth params")
 		}
 
-		// Automatically grant the openid, offline_access, and pinniped:request-audience scopes, but only if they were requested.
+		// Automatically grant the openid, offline_access, profile, email, and pinniped:request-audience scopes, but only if they were requested.
 		downstreamsession.GrantScopesIfRequested(authorizeRequester)
 
 		token, err := upstreamIDPConfig.ExchangeAuthcodeAndValidateTokens(
@@ -77,6 +77,7 @@ func NewHandler(
 		}
 
 		openIDSession := downstreamsession.MakeDownstreamSession(subject, username, groups, customSessionData)
+		oidc.SetDownstreamSessionID(openIDSession, authorizeRequester)
 
 		authorizeResponder, err := oauthHelper.NewAuthorizeResponse(r.Context(), authorizeRequester, openIDSession)
 		if err != nil {
@@ -9,8 +9,12 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"gopkg.in/square/go-jose.v2"
+
 	"go.pinniped.dev/generated/latest/apis/supervisor/idpdiscovery/v1alpha1"
 	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/jwks"
+	"go.pinniped.dev/internal/oidc/provider"
 )
 
 // Metadata holds all fields (that we care about) from the OpenID Provider Metadata section in the
@@ -34,6 +38,32 @@ type Metadata struct {
 
 	// vvv Optional vvv
 
+	UserInfoEndpoint string `json:"userinfo_endpoint"`
+
+	// https://datatracker.ietf.org/doc/html/rfc8414#section-2
+	IntrospectionEndpoint                     string   `json:"introspection_endpoint"`
+	IntrospectionEndpointAuthMethodsSupported []string `json:"introspection_endpoint_auth_methods_supported"`
+
+	// https://openid.net/specs/openid-connect-rpinitiated-1_0.html#OPMetadata
+	EndSessionEndpoint string `json:"end_session_endpoint"`
+
+	// https://openid.net/specs/openid-connect-frontchannel-1_0.html#OPLogout and
+	// https://openid.net/specs/openid-connect-backchannel-1_0.html#BCSupport
+	FrontChannelLogoutSupported        bool `json:"frontchannel_logout_supported"`
+	FrontChannelLogoutSessionSupported bool `json:"frontchannel_logout_session_supported"`
+	BackChannelLogoutSupported         bool `json:"backchannel_logout_supported"`
+	BackChannelLogoutSessionSupported  bool `json:"backchannel_logout_session_supported"`
+
+	// https://datatracker.ietf.org/doc/html/rfc9126#section-5
+	PushedAuthorizationRequestEndpoint string `json:"pushed_authorization_request_endpoint,omitempty"`
+	RequirePushedAuthorizationRequests bool   `json:"require_pushed_authorization_requests"`
+
+	// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata and
+	// https://datatracker.ietf.org/doc/html/rfc9101#section-9.2
+	RequestParameterSupported              bool     `json:"request_parameter_supported"`
+	RequestURIParameterSupported           bool     `json:"request_uri_parameter_supported"`
+	RequestObjectSigningAlgValuesSupported []string `json:"request_object_signing_alg_values_supported,omitempty"`
+
 	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
 	ScopesSupported                   []string `json:"scopes_supported"`
 	ClaimsSupported                   []string `json:"claims_supported"`
@@ -50,13 +80,19 @@ type Metadata struct {
 	// ^^^ Custom ^^^
 }
 
-// NewHandler returns an http.Handler that serves an OIDC discovery endpoint.
-func NewHandler(issuerURL string) http.Handler {
+// NewHandler returns an http.Handler that serves an OIDC discovery endpoint. The supported ID token signing
+// algorithms are those of the keys in the issuer's JWKS, which can change during a key rotation. Signed request
+// objects and the pushed authorization request endpoint, which requires them, are only advertised when the
+// requestObjectVerifier is not nil.
+func NewHandler(issuerURL string, jwksProvider jwks.DynamicJWKSProvider, requestObjectVerifier *provider.RequestObjectVerifier) http.Handler {
 	oidcConfig := Metadata{
 		Issuer:                issuerURL,
 		AuthorizationEndpoint: issuerURL + oidc.AuthorizationEndpointPath,
 		TokenEndpoint:         issuerURL + oidc.TokenEndpointPath,
 		JWKSURI:               issuerURL + oidc.JWKSEndpointPath,
+		UserInfoEndpoint:      issuerURL + oidc.UserInfoEndpointPath,
+		IntrospectionEndpoint: issuerURL + oidc.IntrospectionEndpointPath,
+		EndSessionEndpoint:    issuerURL + oidc.EndSessionEndpointPath,
 		OIDCDiscoveryResponse: v1alpha1.OIDCDiscoveryResponse{
 			SupervisorDiscovery: v1alpha1.OIDCDiscoveryResponseIDPEndpoint{
 				PinnipedIDPsEndpoint: issuerURL + oidc.PinnipedIDPsPathV1Alpha1,
@@ -65,16 +101,33 @@ func NewHandler(issuerURL string) http.Handler {
 		ResponseTypesSupported:            []string{"code"},
 		ResponseModesSupported:            []string{"query", "form_post"},
 		SubjectTypesSupported:             []string{"public"},
-		IDTokenSigningAlgValuesSupported:  []string{"ES256"},
 		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic"},
 		CodeChallengeMethodsSupported:     []string{"S256"},
-		ScopesSupported:                   []string{"openid", "offline"},
-		ClaimsSupported:                   []string{"groups"},
+		ScopesSupported:                   []string{"openid", "offline", "profile", "email"},
+		// The "email" and "name" claims are mapped from the attributes of LDAP and Active Directory users when
+		// their identity providers are configured to do so. Other additional claims depend on each identity
+		// provider, so they are not listed.
+		ClaimsSupported: []string{"groups", "email", "name"},
+
+		IntrospectionEndpointAuthMethodsSupported: []string{"client_secret_basic"},
+
+		FrontChannelLogoutSupported:        true,
+		FrontChannelLogoutSessionSupported: true,
+		BackChannelLogoutSupported:         true,
+		BackChannelLogoutSessionSupported:  true,
+
+		RequirePushedAuthorizationRequests: false,
+
+		// A request_uri is only accepted when it was returned by the pushed authorization request endpoint, so
+		// request objects may not be passed by reference.
+		RequestParameterSupported:              requestObjectVerifier != nil,
+		RequestURIParameterSupported:           false,
+		RequestObjectSigningAlgValuesSupported: requestObjectVerifier.SigningAlgorithms(),
 	}
 
-	var b bytes.Buffer
-	encodeErr := json.NewEncoder(&b).Encode(&oidcConfig)
-	encodedMetadata := b.Bytes()
+	if requestObjectVerifier != nil {
+		oidcConfig.PushedAuthorizationRequestEndpoint = issuerURL + oidc.PushedAuthorizationRequestEndpointPath
+	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -82,15 +135,45 @@ func NewHandler(issuerURL string) http.Handler {
 			return
 		}
 
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
+		metadata := oidcConfig
+		metadata.IDTokenSigningAlgValuesSupported = idTokenSigningAlgorithms(jwksProvider.GetJWKS(issuerURL))
+
+		var b bytes.Buffer
+		if err := json.NewEncoder(&b).Encode(&metadata); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		if _, err := w.Write(encodedMetadata); err != nil {
+		if _, err := w.Write(b.Bytes()); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 	})
 }
+
+// idTokenSigningAlgorithms returns the distinct algorithms of the keys in the JWKS, starting with the algorithm of
+// the active key. It returns ES256, which is the algorithm of the keys that the Supervisor generates by default,
+// when the keys are not loaded yet.
+func idTokenSigningAlgorithms(keySet *jose.JSONWebKeySet, activeJWK *jose.JSONWebKey) []string {
+	var algorithms []string
+	seen := map[string]bool{}
+	add := func(algorithm string) {
+		if algorithm != "" && !seen[algorithm] {
+			seen[algorithm] = true
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	if activeJWK != nil {
+		add(activeJWK.Algorithm)
+	}
+	if keySet != nil {
+		for _, jwk := range keySet.Keys {
+			add(jwk.Algorithm)
+		}
+	}
+	if len(algorithms) == 0 {
+		return []string{"ES256"}
+	}
+	return algorithms
+}
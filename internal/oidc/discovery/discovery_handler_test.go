@@ -9,18 +9,24 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
 
 	"go.pinniped.dev/internal/here"
 	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/jwks"
+	"go.pinniped.dev/internal/oidc/provider"
 )
 
 func TestDiscovery(t *testing.T) {
 	tests := []struct {
 		name string
 
-		issuer string
-		method string
-		path   string
+		issuer    string
+		jwks      *jose.JSONWebKeySet
+		activeJWK *jose.JSONWebKey
+		verifier  *provider.RequestObjectVerifier
+		method    string
+		path      string
 
 		wantStatus      int
 		wantContentType string
@@ -28,7 +34,7 @@ func TestDiscovery(t *testing.T) {
 		wantBodyString  string
 	}{
 		{
-			name:            "happy path",
+			name:            "happy path with the keys of the issuer not loaded yet",
 			issuer:          "https://some-issuer.com/some/path",
 			method:          http.MethodGet,
 			path:            "/some/path" + oidc.WellKnownEndpointPath,
@@ -40,14 +46,153 @@ func TestDiscovery(t *testing.T) {
 				"authorization_endpoint": "https://some-issuer.com/some/path/oauth2/authorize",
 				"token_endpoint": "https://some-issuer.com/some/path/oauth2/token",
 				"jwks_uri": "https://some-issuer.com/some/path/jwks.json",
+				"userinfo_endpoint": "https://some-issuer.com/some/path/oauth2/userinfo",
+				"introspection_endpoint": "https://some-issuer.com/some/path/oauth2/introspect",
+				"introspection_endpoint_auth_methods_supported": ["client_secret_basic"],
+				"end_session_endpoint": "https://some-issuer.com/some/path/oauth2/logout",
+				"frontchannel_logout_supported": true,
+				"frontchannel_logout_session_supported": true,
+				"backchannel_logout_supported": true,
+				"backchannel_logout_session_supported": true,
+				"require_pushed_authorization_requests": false,
+				"request_parameter_supported": false,
+				"request_uri_parameter_supported": false,
 				"response_types_supported": ["code"],
 				"response_modes_supported": ["query", "form_post"],
 				"subject_types_supported": ["public"],
 				"id_token_signing_alg_values_supported": ["ES256"],
 				"token_endpoint_auth_methods_supported": ["client_secret_basic"],
-				"scopes_supported": ["openid", "offline"],
+				"scopes_supported": ["openid", "offline", "profile", "email"],
 				"code_challenge_methods_supported": ["S256"],
-				"claims_supported": ["groups"],
+				"claims_supported": ["groups", "email", "name"],
+				"discovery.supervisor.pinniped.dev/v1alpha1": {
+					"pinniped_identity_providers_endpoint": "https://some-issuer.com/some/path/v1alpha1/pinniped_identity_providers"
+				}
+			}
+			`),
+		},
+		{
+			name:   "happy path during a key rotation from ES256 to RS256",
+			issuer: "https://some-issuer.com/some/path",
+			method: http.MethodGet,
+			path:   "/some/path" + oidc.WellKnownEndpointPath,
+			jwks: &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+				{KeyID: "some-active-key", Algorithm: "ES256"},
+				{KeyID: "some-next-key", Algorithm: "RS256"},
+				{KeyID: "some-retired-key", Algorithm: "ES256"},
+			}},
+			activeJWK:       &jose.JSONWebKey{KeyID: "some-active-key", Algorithm: "ES256"},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBodyJSON: here.Doc(`
+			{
+				"issuer": "https://some-issuer.com/some/path",
+				"authorization_endpoint": "https://some-issuer.com/some/path/oauth2/authorize",
+				"token_endpoint": "https://some-issuer.com/some/path/oauth2/token",
+				"jwks_uri": "https://some-issuer.com/some/path/jwks.json",
+				"userinfo_endpoint": "https://some-issuer.com/some/path/oauth2/userinfo",
+				"introspection_endpoint": "https://some-issuer.com/some/path/oauth2/introspect",
+				"introspection_endpoint_auth_methods_supported": ["client_secret_basic"],
+				"end_session_endpoint": "https://some-issuer.com/some/path/oauth2/logout",
+				"frontchannel_logout_supported": true,
+				"frontchannel_logout_session_supported": true,
+				"backchannel_logout_supported": true,
+				"backchannel_logout_session_supported": true,
+				"require_pushed_authorization_requests": false,
+				"request_parameter_supported": false,
+				"request_uri_parameter_supported": false,
+				"response_types_supported": ["code"],
+				"response_modes_supported": ["query", "form_post"],
+				"subject_types_supported": ["public"],
+				"id_token_signing_alg_values_supported": ["ES256", "RS256"],
+				"token_endpoint_auth_methods_supported": ["client_secret_basic"],
+				"scopes_supported": ["openid", "offline", "profile", "email"],
+				"code_challenge_methods_supported": ["S256"],
+				"claims_supported": ["groups", "email", "name"],
+				"discovery.supervisor.pinniped.dev/v1alpha1": {
+					"pinniped_identity_providers_endpoint": "https://some-issuer.com/some/path/v1alpha1/pinniped_identity_providers"
+				}
+			}
+			`),
+		},
+		{
+			name:   "happy path after a key rotation from ES256 to EdDSA",
+			issuer: "https://some-issuer.com/some/path",
+			method: http.MethodGet,
+			path:   "/some/path" + oidc.WellKnownEndpointPath,
+			jwks: &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+				{KeyID: "some-retired-key", Algorithm: "ES256"},
+				{KeyID: "some-active-key", Algorithm: "EdDSA"},
+			}},
+			activeJWK:       &jose.JSONWebKey{KeyID: "some-active-key", Algorithm: "EdDSA"},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBodyJSON: here.Doc(`
+			{
+				"issuer": "https://some-issuer.com/some/path",
+				"authorization_endpoint": "https://some-issuer.com/some/path/oauth2/authorize",
+				"token_endpoint": "https://some-issuer.com/some/path/oauth2/token",
+				"jwks_uri": "https://some-issuer.com/some/path/jwks.json",
+				"userinfo_endpoint": "https://some-issuer.com/some/path/oauth2/userinfo",
+				"introspection_endpoint": "https://some-issuer.com/some/path/oauth2/introspect",
+				"introspection_endpoint_auth_methods_supported": ["client_secret_basic"],
+				"end_session_endpoint": "https://some-issuer.com/some/path/oauth2/logout",
+				"frontchannel_logout_supported": true,
+				"frontchannel_logout_session_supported": true,
+				"backchannel_logout_supported": true,
+				"backchannel_logout_session_supported": true,
+				"require_pushed_authorization_requests": false,
+				"request_parameter_supported": false,
+				"request_uri_parameter_supported": false,
+				"response_types_supported": ["code"],
+				"response_modes_supported": ["query", "form_post"],
+				"subject_types_supported": ["public"],
+				"id_token_signing_alg_values_supported": ["EdDSA", "ES256"],
+				"token_endpoint_auth_methods_supported": ["client_secret_basic"],
+				"scopes_supported": ["openid", "offline", "profile", "email"],
+				"code_challenge_methods_supported": ["S256"],
+				"claims_supported": ["groups", "email", "name"],
+				"discovery.supervisor.pinniped.dev/v1alpha1": {
+					"pinniped_identity_providers_endpoint": "https://some-issuer.com/some/path/v1alpha1/pinniped_identity_providers"
+				}
+			}
+			`),
+		},
+		{
+			name:            "happy path with request objects",
+			issuer:          "https://some-issuer.com/some/path",
+			method:          http.MethodGet,
+			path:            "/some/path" + oidc.WellKnownEndpointPath,
+			verifier:        newRequestObjectVerifier(t),
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBodyJSON: here.Doc(`
+			{
+				"issuer": "https://some-issuer.com/some/path",
+				"authorization_endpoint": "https://some-issuer.com/some/path/oauth2/authorize",
+				"token_endpoint": "https://some-issuer.com/some/path/oauth2/token",
+				"jwks_uri": "https://some-issuer.com/some/path/jwks.json",
+				"userinfo_endpoint": "https://some-issuer.com/some/path/oauth2/userinfo",
+				"introspection_endpoint": "https://some-issuer.com/some/path/oauth2/introspect",
+				"introspection_endpoint_auth_methods_supported": ["client_secret_basic"],
+				"end_session_endpoint": "https://some-issuer.com/some/path/oauth2/logout",
+				"frontchannel_logout_supported": true,
+				"frontchannel_logout_session_supported": true,
+				"backchannel_logout_supported": true,
+				"backchannel_logout_session_supported": true,
+				"pushed_authorization_request_endpoint": "https://some-issuer.com/some/path/oauth2/par",
+				"require_pushed_authorization_requests": false,
+				"request_parameter_supported": true,
+				"request_uri_parameter_supported": false,
+				"request_object_signing_alg_values_supported": ["ES256"],
+				"response_types_supported": ["code"],
+				"response_modes_supported": ["query", "form_post"],
+				"subject_types_supported": ["public"],
+				"id_token_signing_alg_values_supported": ["ES256"],
+				"token_endpoint_auth_methods_supported": ["client_secret_basic"],
+				"scopes_supported": ["openid", "offline", "profile", "email"],
+				"code_challenge_methods_supported": ["S256"],
+				"claims_supported": ["groups", "email", "name"],
 				"discovery.supervisor.pinniped.dev/v1alpha1": {
 					"pinniped_identity_providers_endpoint": "https://some-issuer.com/some/path/v1alpha1/pinniped_identity_providers"
 				}
@@ -67,7 +212,15 @@ func TestDiscovery(t *testing.T) {
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
-			handler := NewHandler(test.issuer)
+			jwksProvider := jwks.NewDynamicJWKSProvider()
+			if test.jwks != nil {
+				jwksProvider.SetIssuerToJWKSMap(
+					map[string]*jose.JSONWebKeySet{test.issuer: test.jwks},
+					map[string]*jose.JSONWebKey{test.issuer: test.activeJWK},
+				)
+			}
+
+			handler := NewHandler(test.issuer, jwksProvider, test.verifier)
 			req := httptest.NewRequest(test.method, test.path, nil)
 			rsp := httptest.NewRecorder()
 			handler.ServeHTTP(rsp, req)
@@ -86,3 +239,14 @@ func TestDiscovery(t *testing.T) {
 		})
 	}
 }
+
+func newRequestObjectVerifier(t *testing.T) *provider.RequestObjectVerifier {
+	t.Helper()
+	verifier, err := provider.NewRequestObjectVerifier([]provider.RequestObjectClient{{
+		ID: "some-client",
+		JWKS: `{"keys":[{"kty":"EC","crv":"P-256","alg":"ES256",` +
+			`"x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM"}]}`,
+	}})
+	require.NoError(t, err)
+	return verifier
+}
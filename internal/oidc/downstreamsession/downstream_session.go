@@ -15,6 +15,7 @@ import (
 	"github.com/ory/fosite/handler/openid"
 	"github.com/ory/fosite/token/jwt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"go.pinniped.dev/internal/authenticators"
 	"go.pinniped.dev/internal/constable"
@@ -32,6 +33,11 @@ const (
 	// The name of the email_verified claim from https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
 	emailVerifiedClaimName = "email_verified"
 
+	// The names of the scopes from https://openid.net/specs/openid-connect-core-1_0.html#ScopeClaims which allow
+	// additional claims to be included in the downstream ID tokens.
+	emailScopeName   = "email"
+	profileScopeName = "profile"
+
 	requiredClaimMissingErr            = constable.Error("required claim in upstream ID token missing")
 	requiredClaimInvalidFormatErr      = constable.Error("required claim in upstream ID token has invalid format")
 	requiredClaimEmptyErr              = constable.Error("required claim in upstream ID token is empty")
@@ -39,6 +45,13 @@ const (
 	emailVerifiedClaimFalseErr         = constable.Error("email_verified claim in upstream ID token has false value")
 )
 
+// reservedClaimNames are the names of the claims of the downstream ID tokens which are set by the Supervisor or by
+// fosite, so they cannot be used as additional claims.
+var reservedClaimNames = sets.NewString( //nolint:gochecknoglobals
+	"iss", "sub", "aud", "exp", "iat", "nbf", "jti", "auth_time", "rat", "nonce", "azp", "at_hash", "c_hash", "acr", "amr", "sid",
+	oidc.DownstreamUsernameClaim, oidc.DownstreamGroupsClaim, oidc.DownstreamActorClaim,
+)
+
 // MakeDownstreamSession creates a downstream OIDC session.
 func MakeDownstreamSession(subject string, username string, groups []string, custom *psession.CustomSessionData) *psession.PinnipedSession {
 	now := time.Now().UTC()
@@ -71,6 +84,7 @@ func MakeDownstreamLDAPOrADCustomSessionData(
 		ProviderUID:  ldapUpstream.GetResourceUID(),
 		ProviderName: ldapUpstream.GetName(),
 		ProviderType: idpType,
+		Warnings:     authenticateResponse.Warnings,
 	}
 
 	if idpType == psession.ProviderTypeLDAP {
@@ -90,6 +104,55 @@ func MakeDownstreamLDAPOrADCustomSessionData(
 	return customSessionData
 }
 
+// SetAdditionalClaims replaces the additional claims of an LDAP or Active Directory session, which were mapped from the
+// attributes of the user's entry, and includes them in the session's ID token claims when the scopes which allow them
+// were granted. The "email" claim requires the "email" scope and all other additional claims require the "profile" scope.
+func SetAdditionalClaims(session *psession.PinnipedSession, grantedScopes fosite.Arguments, additionalClaims map[string]string) {
+	var storedClaims *map[string]string
+	switch {
+	case session.Custom.LDAP != nil:
+		storedClaims = &session.Custom.LDAP.AdditionalClaims
+	case session.Custom.ActiveDirectory != nil:
+		storedClaims = &session.Custom.ActiveDirectory.AdditionalClaims
+	default:
+		return
+	}
+
+	idTokenClaims := session.IDTokenClaims()
+	if idTokenClaims.Extra == nil {
+		idTokenClaims.Extra = map[string]interface{}{}
+	}
+
+	// Remove the previous values first, since the user's entry may no longer have some of the attributes.
+	for claimName := range *storedClaims {
+		delete(idTokenClaims.Extra, claimName)
+	}
+
+	var allowedClaims map[string]string
+	for claimName, value := range additionalClaims {
+		if reservedClaimNames.Has(claimName) {
+			plog.Warning("ignoring additional claim which cannot be configured because it is set by the Supervisor",
+				"upstreamName", session.Custom.ProviderName,
+				"claimName", claimName,
+			)
+			continue
+		}
+		if allowedClaims == nil {
+			allowedClaims = map[string]string{}
+		}
+		allowedClaims[claimName] = value
+
+		requiredScope := profileScopeName
+		if claimName == emailClaimName {
+			requiredScope = emailScopeName
+		}
+		if grantedScopes.Has(requiredScope) {
+			idTokenClaims.Extra[claimName] = value
+		}
+	}
+	*storedClaims = allowedClaims
+}
+
 func MakeDownstreamOIDCCustomSessionData(oidcUpstream provider.UpstreamOIDCIdentityProviderI, token *oidctypes.Token) (*psession.CustomSessionData, error) {
 	upstreamSubject, err := ExtractStringClaimValue(oidc.IDTokenSubjectClaim, oidcUpstream.GetName(), token.IDToken.Claims)
 	if err != nil {
@@ -150,6 +213,8 @@ func MakeDownstreamOIDCCustomSessionData(oidcUpstream provider.UpstreamOIDCIdent
 func GrantScopesIfRequested(authorizeRequester fosite.AuthorizeRequester) {
 	oidc.GrantScopeIfRequested(authorizeRequester, coreosoidc.ScopeOpenID)
 	oidc.GrantScopeIfRequested(authorizeRequester, coreosoidc.ScopeOfflineAccess)
+	oidc.GrantScopeIfRequested(authorizeRequester, profileScopeName)
+	oidc.GrantScopeIfRequested(authorizeRequester, emailScopeName)
 	oidc.GrantScopeIfRequested(authorizeRequester, "pinniped:request-audience")
 }
 
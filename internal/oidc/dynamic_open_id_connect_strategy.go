@@ -0,0 +1,218 @@
+// Copyright 2020-2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"reflect"
+	"strings"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/token/jwt"
+	"gopkg.in/square/go-jose.v2"
+
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/oidc/jwks"
+	"go.pinniped.dev/internal/plog"
+)
+
+// dynamicOpenIDConnectStrategy is an openid.OpenIDConnectTokenStrategy that can dynamically
+// load a signing key to issue ID tokens. We want this dynamic capability since our controllers for
+// loading FederationDomain's and signing keys run in parallel, and thus the signing key might not be
+// ready when an FederationDomain is otherwise ready. The signing key also changes during key rotations,
+// possibly to a key of a different algorithm.
+//
+// If we ever update FederationDomain's to hold their signing key, we might not need this type, since we
+// could have an invariant that routes to an FederationDomain's endpoints are only wired up if an
+// FederationDomain has a valid signing key.
+type dynamicOpenIDConnectStrategy struct {
+	fositeConfig *compose.Config
+	jwksProvider jwks.DynamicJWKSProvider
+}
+
+var _ openid.OpenIDConnectTokenStrategy = &dynamicOpenIDConnectStrategy{}
+
+func newDynamicOpenIDConnectStrategy(
+	fositeConfig *compose.Config,
+	jwksProvider jwks.DynamicJWKSProvider,
+) *dynamicOpenIDConnectStrategy {
+	return &dynamicOpenIDConnectStrategy{
+		fositeConfig: fositeConfig,
+		jwksProvider: jwksProvider,
+	}
+}
+
+func (s *dynamicOpenIDConnectStrategy) GenerateIDToken(
+	ctx context.Context,
+	requester fosite.Requester,
+) (string, error) {
+	jwtStrategy, err := activeJWTStrategy(s.jwksProvider, s.fositeConfig.IDTokenIssuer)
+	if err != nil {
+		return "", err
+	}
+
+	return (&openid.DefaultStrategy{
+		JWTStrategy:         jwtStrategy,
+		Expiry:              s.fositeConfig.GetIDTokenLifespan(),
+		Issuer:              s.fositeConfig.IDTokenIssuer,
+		MinParameterEntropy: s.fositeConfig.GetMinParameterEntropy(),
+	}).GenerateIDToken(ctx, requester)
+}
+
+// SignJWT signs the given claims using the active signing key of the issuer. The typ header of the JWT is set to
+// the given type, e.g. "logout+jwt" for the logout tokens of OpenID Connect Back-Channel Logout 1.0.
+func SignJWT(
+	ctx context.Context,
+	jwksProvider jwks.DynamicJWKSProvider,
+	issuer string,
+	typ string,
+	claims map[string]interface{},
+) (string, error) {
+	jwtStrategy, err := activeJWTStrategy(jwksProvider, issuer)
+	if err != nil {
+		return "", err
+	}
+	// This does not use jwtStrategy.Generate because jwt.Headers drops the typ header.
+	token := jwt.NewWithClaims(jwtStrategy.algorithm, claims)
+	token.Header["kid"] = jwtStrategy.keyID
+	token.Header["typ"] = typ
+	return token.SignedString(jwtStrategy.signingKey(ctx))
+}
+
+// activeJWTStrategy returns a JWT strategy which signs using the active signing key of the issuer.
+func activeJWTStrategy(jwksProvider jwks.DynamicJWKSProvider, issuer string) (*keyIDJWTStrategy, error) {
+	_, activeJwk := jwksProvider.GetJWKS(issuer)
+	if activeJwk == nil {
+		plog.Debug("no JWK found for issuer", "issuer", issuer)
+		return nil, fosite.ErrTemporarilyUnavailable.WithWrap(constable.Error("no JWK found for issuer"))
+	}
+	algorithm, ok := signingAlgorithmForKey(activeJwk.Key)
+	if !ok {
+		actualType := "nil"
+		if t := reflect.TypeOf(activeJwk.Key); t != nil {
+			actualType = t.String()
+		}
+		plog.Debug(
+			"JWK must be of type ecdsa, rsa, ed25519, or an external signer",
+			"issuer",
+			issuer,
+			"actualType",
+			actualType,
+		)
+		return nil, fosite.ErrServerError.WithWrap(constable.Error("JWK must be of type ecdsa, rsa, ed25519, or an external signer"))
+	}
+	return &keyIDJWTStrategy{
+		algorithm:  algorithm,
+		keyID:      activeJwk.KeyID,
+		privateKey: activeJwk.Key,
+	}, nil
+}
+
+// signingAlgorithmForKey returns the JWS algorithm which the JWKS writer controller pairs with the type of the
+// private key, or the algorithm of the external signer when the private key is not available to the Supervisor.
+func signingAlgorithmForKey(key interface{}) (jose.SignatureAlgorithm, bool) {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return jose.ES256, true
+	case *rsa.PrivateKey:
+		return jose.RS256, true
+	case ed25519.PrivateKey:
+		return jose.EdDSA, true
+	case jose.OpaqueSigner:
+		if algs := k.Algs(); len(algs) > 0 {
+			return algs[0], true
+		}
+		return "", false
+	default:
+		return "", false
+	}
+}
+
+// keyIDJWTStrategy is like fosite's jwt.ES256JWTStrategy and jwt.RS256JWTStrategy, except that it also supports
+// EdDSA and external signers, and it sets the kid header of the tokens, so that clients can choose the right key from the JWKS while
+// more than one key is published during a key rotation.
+type keyIDJWTStrategy struct {
+	algorithm  jose.SignatureAlgorithm
+	keyID      string
+	privateKey interface{}
+}
+
+var _ jwt.JWTStrategy = &keyIDJWTStrategy{}
+
+func (s *keyIDJWTStrategy) Generate(ctx context.Context, claims jwt.MapClaims, header jwt.Mapper) (string, string, error) {
+	if header == nil || claims == nil {
+		return "", "", constable.Error("either claims or header is nil")
+	}
+
+	token := jwt.NewWithClaims(s.algorithm, claims)
+	for k, v := range header.ToMap() {
+		token.Header[k] = v
+	}
+	token.Header["kid"] = s.keyID
+
+	rawToken, err := token.SignedString(s.signingKey(ctx))
+	if err != nil {
+		return "", "", err
+	}
+	signature, err := s.GetSignature(ctx, rawToken)
+	if err != nil {
+		return "", "", err
+	}
+	return rawToken, signature, nil
+}
+
+// signingKey returns the private key, or the external signer bound to ctx, so that a request to the external signer
+// is canceled along with the request which needs the signature.
+func (s *keyIDJWTStrategy) signingKey(ctx context.Context) interface{} {
+	if signer, ok := s.privateKey.(jwks.ContextSigner); ok {
+		return signer.WithContext(ctx)
+	}
+	return s.privateKey
+}
+
+func (s *keyIDJWTStrategy) Validate(ctx context.Context, token string) (string, error) {
+	if _, err := s.Decode(ctx, token); err != nil {
+		return "", err
+	}
+	return s.GetSignature(ctx, token)
+}
+
+func (s *keyIDJWTStrategy) Decode(_ context.Context, token string) (*jwt.Token, error) {
+	var publicKey interface{}
+	switch k := s.privateKey.(type) {
+	case jose.OpaqueSigner:
+		publicKey = k.Public().Key
+	case crypto.Signer:
+		publicKey = k.Public()
+	default:
+		return nil, constable.Error("private key cannot provide a public key")
+	}
+	return jwt.ParseWithClaims(token, jwt.MapClaims{}, func(*jwt.Token) (interface{}, error) {
+		return publicKey, nil
+	})
+}
+
+func (s *keyIDJWTStrategy) GetSignature(_ context.Context, token string) (string, error) {
+	split := strings.Split(token, ".")
+	if len(split) != 3 {
+		return "", constable.Error("header, body and signature must all be set")
+	}
+	return split[2], nil
+}
+
+func (s *keyIDJWTStrategy) Hash(_ context.Context, in []byte) ([]byte, error) {
+	hash := sha256.Sum256(in)
+	return hash[:], nil
+}
+
+func (s *keyIDJWTStrategy) GetSigningMethodLength() int {
+	return sha256.Size
+}
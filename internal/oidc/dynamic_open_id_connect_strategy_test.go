@@ -0,0 +1,276 @@
+// Copyright 2020-2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/token/jwt"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+
+	"go.pinniped.dev/internal/oidc/jwks"
+)
+
+func TestDynamicOpenIDConnectStrategy(t *testing.T) {
+	const (
+		goodIssuer   = "https://some-good-issuer.com"
+		clientID     = "some-client-id"
+		goodSubject  = "some-subject"
+		goodUsername = "some-username"
+		goodNonce    = "some-nonce-value-with-enough-bytes-to-exceed-min-allowed"
+	)
+
+	ecPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	_, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		issuer         string
+		jwksProvider   func(jwks.DynamicJWKSProvider)
+		wantErrorType  *fosite.RFC6749Error
+		wantErrorCause string
+		wantSigningJWK *jose.JSONWebKey
+		wantAlgorithm  string
+	}{
+		{
+			name:   "jwks provider does contain ecdsa signing key for issuer",
+			issuer: goodIssuer,
+			jwksProvider: func(provider jwks.DynamicJWKSProvider) {
+				provider.SetIssuerToJWKSMap(
+					nil,
+					map[string]*jose.JSONWebKey{
+						goodIssuer: {
+							Key:   ecPrivateKey,
+							KeyID: "some-key-id",
+						},
+					},
+				)
+			},
+			wantSigningJWK: &jose.JSONWebKey{
+				Key:   ecPrivateKey,
+				KeyID: "some-key-id",
+			},
+			wantAlgorithm: "ES256",
+		},
+		{
+			name:   "jwks provider does contain rsa signing key for issuer",
+			issuer: goodIssuer,
+			jwksProvider: func(provider jwks.DynamicJWKSProvider) {
+				provider.SetIssuerToJWKSMap(
+					nil,
+					map[string]*jose.JSONWebKey{
+						goodIssuer: {
+							Key:   rsaPrivateKey,
+							KeyID: "some-key-id",
+						},
+					},
+				)
+			},
+			wantSigningJWK: &jose.JSONWebKey{
+				Key:   rsaPrivateKey,
+				KeyID: "some-key-id",
+			},
+			wantAlgorithm: "RS256",
+		},
+		{
+			name:   "jwks provider does contain ed25519 signing key for issuer",
+			issuer: goodIssuer,
+			jwksProvider: func(provider jwks.DynamicJWKSProvider) {
+				provider.SetIssuerToJWKSMap(
+					nil,
+					map[string]*jose.JSONWebKey{
+						goodIssuer: {
+							Key:   edPrivateKey,
+							KeyID: "some-key-id",
+						},
+					},
+				)
+			},
+			wantSigningJWK: &jose.JSONWebKey{
+				Key:   edPrivateKey,
+				KeyID: "some-key-id",
+			},
+			wantAlgorithm: "EdDSA",
+		},
+		{
+			name:   "jwks provider does contain an external signer for issuer",
+			issuer: goodIssuer,
+			jwksProvider: func(provider jwks.DynamicJWKSProvider) {
+				provider.SetIssuerToJWKSMap(
+					nil,
+					map[string]*jose.JSONWebKey{
+						goodIssuer: {
+							Key:       &fakeRSASigner{privateKey: rsaPrivateKey, keyID: "some-external-key-id"},
+							KeyID:     "some-external-key-id",
+							Algorithm: "RS256",
+						},
+					},
+				)
+			},
+			wantSigningJWK: &jose.JSONWebKey{
+				Key:   rsaPrivateKey,
+				KeyID: "some-external-key-id",
+			},
+			wantAlgorithm: "RS256",
+		},
+		{
+			name:           "jwks provider does not contain signing key for issuer",
+			issuer:         goodIssuer,
+			wantErrorType:  fosite.ErrTemporarilyUnavailable,
+			wantErrorCause: "no JWK found for issuer",
+		},
+		{
+			name:   "jwks provider contains signing key of wrong type for issuer",
+			issuer: goodIssuer,
+			jwksProvider: func(provider jwks.DynamicJWKSProvider) {
+				provider.SetIssuerToJWKSMap(
+					nil,
+					map[string]*jose.JSONWebKey{
+						goodIssuer: {
+							Key: []byte("some-symmetric-key"),
+						},
+					},
+				)
+			},
+			wantErrorType:  fosite.ErrServerError,
+			wantErrorCause: "JWK must be of type ecdsa, rsa, ed25519, or an external signer",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			jwksProvider := jwks.NewDynamicJWKSProvider()
+			if test.jwksProvider != nil {
+				test.jwksProvider(jwksProvider)
+			}
+			s := newDynamicOpenIDConnectStrategy(
+				&compose.Config{IDTokenIssuer: test.issuer},
+				jwksProvider,
+			)
+
+			requester := &fosite.Request{
+				Client: &fosite.DefaultClient{
+					ID: clientID,
+				},
+				Session: &openid.DefaultSession{
+					Claims: &jwt.IDTokenClaims{
+						Subject: goodSubject,
+					},
+					Subject:  goodSubject,
+					Username: goodUsername,
+				},
+				Form: url.Values{
+					"nonce": {goodNonce},
+				},
+			}
+			idToken, err := s.GenerateIDToken(context.Background(), requester)
+			if test.wantErrorType != nil {
+				require.True(t, errors.Is(err, test.wantErrorType))
+				require.EqualError(t, err.(*fosite.RFC6749Error).Cause(), test.wantErrorCause)
+			} else {
+				require.NoError(t, err)
+
+				// Perform a light validation on the token to make sure 1) we passed through the correct
+				// signing key and 2) we forwarded the fosite.Requester correctly. Token generation is
+				// tested more expansively in the token endpoint.
+				token, err := josejwt.ParseSigned(idToken)
+				require.NoError(t, err)
+				require.Len(t, token.Headers, 1)
+				require.Equal(t, test.wantAlgorithm, token.Headers[0].Algorithm)
+				require.Equal(t, test.wantSigningJWK.KeyID, token.Headers[0].KeyID)
+
+				var claims josejwt.Claims
+				var nonceClaim struct {
+					Nonce string `json:"nonce"`
+				}
+				require.NoError(t, token.Claims(test.wantSigningJWK.Public().Key, &claims, &nonceClaim))
+				require.NoError(t, claims.Validate(josejwt.Expected{Issuer: goodIssuer, Audience: josejwt.Audience{clientID}}))
+				require.Equal(t, goodSubject, claims.Subject)
+				require.Equal(t, goodNonce, nonceClaim.Nonce)
+			}
+		})
+	}
+}
+
+func TestSignJWT(t *testing.T) {
+	const goodIssuer = "https://some-good-issuer.com"
+
+	ecPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	jwksProvider := jwks.NewDynamicJWKSProvider()
+
+	_, err = SignJWT(context.Background(), jwksProvider, goodIssuer, "logout+jwt", map[string]interface{}{"sub": "some-subject"})
+	require.True(t, errors.Is(err, fosite.ErrTemporarilyUnavailable))
+
+	jwksProvider.SetIssuerToJWKSMap(nil, map[string]*jose.JSONWebKey{
+		goodIssuer: {Key: ecPrivateKey, KeyID: "some-key-id"},
+	})
+
+	signed, err := SignJWT(context.Background(), jwksProvider, goodIssuer, "logout+jwt", map[string]interface{}{
+		"iss": goodIssuer,
+		"sub": "some-subject",
+		"sid": "some-session-id",
+	})
+	require.NoError(t, err)
+
+	token, err := josejwt.ParseSigned(signed)
+	require.NoError(t, err)
+	require.Len(t, token.Headers, 1)
+	require.Equal(t, "ES256", token.Headers[0].Algorithm)
+	require.Equal(t, "some-key-id", token.Headers[0].KeyID)
+	require.Equal(t, "logout+jwt", token.Headers[0].ExtraHeaders[jose.HeaderType])
+
+	var claims map[string]interface{}
+	require.NoError(t, token.Claims(ecPrivateKey.Public(), &claims))
+	require.Equal(t, map[string]interface{}{
+		"iss": goodIssuer,
+		"sub": "some-subject",
+		"sid": "some-session-id",
+	}, claims)
+}
+
+// fakeRSASigner is an in-process jose.OpaqueSigner which acts like an external signer.
+type fakeRSASigner struct {
+	privateKey *rsa.PrivateKey
+	keyID      string
+}
+
+func (s *fakeRSASigner) Public() *jose.JSONWebKey {
+	return &jose.JSONWebKey{Key: s.privateKey.Public(), KeyID: s.keyID, Algorithm: "RS256", Use: "sig"}
+}
+
+func (s *fakeRSASigner) Algs() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{jose.RS256}
+}
+
+func (s *fakeRSASigner) SignPayload(payload []byte, alg jose.SignatureAlgorithm) ([]byte, error) {
+	if alg != jose.RS256 {
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	digest := sha256.Sum256(payload)
+	return rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, digest[:])
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package introspection provides a handler for the RFC 7662 token introspection endpoint.
+package introspection
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ory/fosite"
+
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+)
+
+// response is the body of an introspection response, as described in
+// https://datatracker.ietf.org/doc/html/rfc7662#section-2.2, plus the downstream groups of the user.
+type response struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Groups    []string `json:"groups,omitempty"`
+}
+
+// errorResponse is the body of an error response, as described in
+// https://datatracker.ietf.org/doc/html/rfc6749#section-5.2.
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// NewHandler returns an http.Handler that serves the RFC 7662 token introspection endpoint, which resource servers
+// use to validate the access tokens issued by the FederationDomain. The resource servers authenticate using HTTP
+// basic authentication with the credentials of one of the given clients. Only access tokens are introspected, so
+// any other token, e.g. a refresh token, is reported as inactive.
+func NewHandler(issuer string, oauthHelper fosite.OAuth2Provider, clients *provider.IntrospectionClients) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, `Method not allowed (try POST)`, http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !authenticateClient(r, clients) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+			writeJSON(w, http.StatusUnauthorized, &errorResponse{
+				Error:            "invalid_client",
+				ErrorDescription: "client authentication failed",
+			})
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			writeJSON(w, http.StatusBadRequest, &errorResponse{
+				Error:            "invalid_request",
+				ErrorDescription: "unable to parse form params",
+			})
+			return
+		}
+		token := r.PostForm.Get("token")
+		if token == "" {
+			writeJSON(w, http.StatusBadRequest, &errorResponse{
+				Error:            "invalid_request",
+				ErrorDescription: "missing token parameter",
+			})
+			return
+		}
+
+		tokenUse, requester, err := oauthHelper.IntrospectToken(r.Context(), token, fosite.AccessToken, psession.NewPinnipedSession())
+		if err == nil && tokenUse != fosite.AccessToken {
+			err = fosite.ErrInvalidTokenFormat.WithHint("Only access tokens may be introspected.")
+		}
+		if err != nil {
+			// Details about inactive tokens must not be revealed, see https://datatracker.ietf.org/doc/html/rfc7662#section-2.2.
+			plog.Debug("introspected inactive token", oidc.FositeErrorForLog(err)...)
+			writeJSON(w, http.StatusOK, &response{Active: false})
+			return
+		}
+
+		session, ok := requester.GetSession().(*psession.PinnipedSession)
+		if !ok || session.Fosite == nil || session.Fosite.Claims == nil {
+			http.Error(w, "invalid session", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, activeResponse(issuer, requester, session))
+	})
+}
+
+// authenticateClient returns true when the request has the HTTP basic authentication credentials of one of the
+// clients. The client ID and secret are form-encoded, see https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1.
+func authenticateClient(r *http.Request, clients *provider.IntrospectionClients) bool {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	decodedID, err := url.QueryUnescape(id)
+	if err != nil {
+		return false
+	}
+	decodedSecret, err := url.QueryUnescape(secret)
+	if err != nil {
+		return false
+	}
+	return clients.Authenticate(decodedID, decodedSecret)
+}
+
+func activeResponse(issuer string, requester fosite.Requester, session *psession.PinnipedSession) *response {
+	idTokenClaims := session.IDTokenClaims()
+	username, _ := idTokenClaims.Extra[oidc.DownstreamUsernameClaim].(string)
+	rsp := &response{
+		Active:    true,
+		Scope:     strings.Join(requester.GetGrantedScopes(), " "),
+		ClientID:  requester.GetClient().GetID(),
+		Username:  username,
+		TokenType: "Bearer",
+		IssuedAt:  requester.GetRequestedAt().Unix(),
+		Subject:   idTokenClaims.Subject,
+		Audience:  requester.GetGrantedAudience(),
+		Issuer:    issuer,
+		Groups:    oidc.DownstreamGroupsFromClaims(idTokenClaims.Extra),
+	}
+	if expiresAt := session.GetExpiresAt(fosite.AccessToken); !expiresAt.IsZero() {
+		rsp.ExpiresAt = expiresAt.Unix()
+	}
+	return rsp
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_, _ = w.Write(b.Bytes())
+}
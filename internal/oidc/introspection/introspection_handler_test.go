@@ -0,0 +1,244 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package introspection
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/clientregistry"
+	"go.pinniped.dev/internal/oidc/downstreamsession"
+	"go.pinniped.dev/internal/oidc/jwks"
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/psession"
+)
+
+const (
+	goodIssuer = "https://some-issuer.com"
+	hmacSecret = "this needs to be at least 32 characters to meet entropy requirements"
+)
+
+func TestIntrospectionHandler(t *testing.T) {
+	clients, err := provider.NewIntrospectionClients([]provider.IntrospectionClient{
+		{ID: "some-gateway", Secret: "some-gateway-secret"},
+	})
+	require.NoError(t, err)
+
+	newRequest := func(method string, params url.Values) *http.Request {
+		req := httptest.NewRequest(method, "/some/path"+oidc.IntrospectionEndpointPath, strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+	authenticated := func(req *http.Request) *http.Request {
+		req.SetBasicAuth("some-gateway", "some-gateway-secret")
+		return req
+	}
+
+	tests := []struct {
+		name string
+		// createToken is true to create an access token for the test.
+		createToken         bool
+		expiresIn           time.Duration
+		clients             *provider.IntrospectionClients
+		makeRequest         func(accessToken string) *http.Request
+		wantStatus          int
+		wantContentType     string
+		wantBodyJSON        string
+		wantBodyString      string
+		wantWWWAuthenticate string
+	}{
+		{
+			name:        "happy path",
+			createToken: true,
+			clients:     clients,
+			makeRequest: func(accessToken string) *http.Request {
+				return authenticated(newRequest(http.MethodPost, url.Values{"token": []string{accessToken}}))
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBodyJSON: `{"active": true, "scope": "openid offline_access", "client_id": "pinniped-cli",
+				"username": "some-username", "token_type": "Bearer", "exp": EXP, "iat": IAT, "sub": "some-subject",
+				"iss": "https://some-issuer.com", "groups": ["group1", "group2"]}`,
+		},
+		{
+			name:        "happy path with a token type hint",
+			createToken: true,
+			clients:     clients,
+			makeRequest: func(accessToken string) *http.Request {
+				return authenticated(newRequest(http.MethodPost, url.Values{
+					"token":           []string{accessToken},
+					"token_type_hint": []string{"access_token"},
+				}))
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBodyJSON: `{"active": true, "scope": "openid offline_access", "client_id": "pinniped-cli",
+				"username": "some-username", "token_type": "Bearer", "exp": EXP, "iat": IAT, "sub": "some-subject",
+				"iss": "https://some-issuer.com", "groups": ["group1", "group2"]}`,
+		},
+		{
+			name:    "bad method",
+			clients: clients,
+			makeRequest: func(accessToken string) *http.Request {
+				return authenticated(newRequest(http.MethodGet, nil))
+			},
+			wantStatus:      http.StatusMethodNotAllowed,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Method not allowed (try POST)\n",
+		},
+		{
+			name:        "missing client credentials",
+			createToken: true,
+			clients:     clients,
+			makeRequest: func(accessToken string) *http.Request {
+				return newRequest(http.MethodPost, url.Values{"token": []string{accessToken}})
+			},
+			wantStatus:          http.StatusUnauthorized,
+			wantContentType:     "application/json",
+			wantBodyJSON:        `{"error": "invalid_client", "error_description": "client authentication failed"}`,
+			wantWWWAuthenticate: `Basic realm="introspection"`,
+		},
+		{
+			name:        "wrong client secret",
+			createToken: true,
+			clients:     clients,
+			makeRequest: func(accessToken string) *http.Request {
+				req := newRequest(http.MethodPost, url.Values{"token": []string{accessToken}})
+				req.SetBasicAuth("some-gateway", "wrong-secret")
+				return req
+			},
+			wantStatus:          http.StatusUnauthorized,
+			wantContentType:     "application/json",
+			wantBodyJSON:        `{"error": "invalid_client", "error_description": "client authentication failed"}`,
+			wantWWWAuthenticate: `Basic realm="introspection"`,
+		},
+		{
+			name:        "no clients are configured",
+			createToken: true,
+			clients:     nil,
+			makeRequest: func(accessToken string) *http.Request {
+				return authenticated(newRequest(http.MethodPost, url.Values{"token": []string{accessToken}}))
+			},
+			wantStatus:          http.StatusUnauthorized,
+			wantContentType:     "application/json",
+			wantBodyJSON:        `{"error": "invalid_client", "error_description": "client authentication failed"}`,
+			wantWWWAuthenticate: `Basic realm="introspection"`,
+		},
+		{
+			name:    "missing token parameter",
+			clients: clients,
+			makeRequest: func(accessToken string) *http.Request {
+				return authenticated(newRequest(http.MethodPost, url.Values{}))
+			},
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: "application/json",
+			wantBodyJSON:    `{"error": "invalid_request", "error_description": "missing token parameter"}`,
+		},
+		{
+			name:    "unknown token",
+			clients: clients,
+			makeRequest: func(accessToken string) *http.Request {
+				return authenticated(newRequest(http.MethodPost, url.Values{"token": []string{"pin_at_some-unknown-token.some-signature"}}))
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBodyJSON:    `{"active": false}`,
+		},
+		{
+			name:        "expired token",
+			createToken: true,
+			expiresIn:   -time.Minute,
+			clients:     clients,
+			makeRequest: func(accessToken string) *http.Request {
+				return authenticated(newRequest(http.MethodPost, url.Values{"token": []string{accessToken}}))
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBodyJSON:    `{"active": false}`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			secrets := fake.NewSimpleClientset().CoreV1().Secrets("some-namespace")
+			timeouts := oidc.DefaultOIDCTimeoutsConfiguration()
+			oauthStore := oidc.NewKubeStorage(secrets, timeouts)
+			hmacSecretFunc := func() []byte { return []byte(hmacSecret) }
+			oauthHelper := oidc.FositeOauth2Helper(oauthStore, goodIssuer, hmacSecretFunc, jwks.NewDynamicJWKSProvider(), timeouts, nil)
+
+			var accessToken string
+			var expiresAt, issuedAt time.Time
+			if test.createToken {
+				accessToken, expiresAt, issuedAt = createAccessToken(ctx, t, oauthStore, hmacSecretFunc(), test.expiresIn)
+			}
+
+			rsp := httptest.NewRecorder()
+			NewHandler(goodIssuer, oauthHelper, test.clients).ServeHTTP(rsp, test.makeRequest(accessToken))
+
+			require.Equal(t, test.wantStatus, rsp.Code)
+			require.Equal(t, test.wantContentType, rsp.Header().Get("Content-Type"))
+			require.Equal(t, test.wantWWWAuthenticate, rsp.Header().Get("WWW-Authenticate"))
+			if test.wantBodyJSON != "" {
+				wantBodyJSON := strings.NewReplacer(
+					"EXP", strconv.FormatInt(expiresAt.Unix(), 10),
+					"IAT", strconv.FormatInt(issuedAt.Unix(), 10),
+				).Replace(test.wantBodyJSON)
+				require.JSONEq(t, wantBodyJSON, rsp.Body.String())
+				require.Equal(t, "no-store", rsp.Header().Get("Cache-Control"))
+			}
+			if test.wantBodyString != "" {
+				require.Equal(t, test.wantBodyString, rsp.Body.String())
+			}
+		})
+	}
+}
+
+// createAccessToken stores an access token session like the token endpoint would, and returns the access token
+// along with its expiration and issue times.
+func createAccessToken(
+	ctx context.Context,
+	t *testing.T,
+	oauthStore *oidc.KubeStorage,
+	hmacSecret []byte,
+	expiresIn time.Duration,
+) (string, time.Time, time.Time) {
+	t.Helper()
+
+	if expiresIn == 0 {
+		expiresIn = 5 * time.Minute
+	}
+	expiresAt := time.Now().Add(expiresIn)
+
+	session := downstreamsession.MakeDownstreamSession("some-subject", "some-username", []string{"group1", "group2"}, &psession.CustomSessionData{})
+	session.SetExpiresAt(fosite.AccessToken, expiresAt)
+
+	request := fosite.NewRequest()
+	request.Client = clientregistry.PinnipedCLI()
+	request.Session = session
+	request.RequestedScope = []string{"openid", "offline_access"}
+	request.GrantedScope = []string{"openid", "offline_access"}
+
+	config := &compose.Config{AccessTokenLifespan: expiresIn}
+	accessToken, signature, err := compose.NewOAuth2HMACStrategy(config, hmacSecret, nil).GenerateAccessToken(ctx, request)
+	require.NoError(t, err)
+	require.NoError(t, oauthStore.CreateAccessTokenSession(ctx, signature, request))
+
+	// The Supervisor's access tokens have a prefix, see dynamic_oauth2_hmac_strategy.go.
+	return "pin_at_" + accessToken, expiresAt, request.GetRequestedAt()
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package jwks
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+
+	"gopkg.in/square/go-jose.v2"
+
+	"go.pinniped.dev/internal/constable"
+)
+
+// httpSignerRequest is the body which is POSTed to an external signer for each signature.
+type httpSignerRequest struct {
+	// KeyID is the key ID of the key which should sign the payload.
+	KeyID string `json:"kid"`
+	// Algorithm is the JWS algorithm of the signature.
+	Algorithm string `json:"alg"`
+	// Payload is the base64url encoded JWS signing input, i.e. the bytes which should be signed.
+	Payload string `json:"payload"`
+}
+
+// httpSignerResponse is the body which an external signer returns for each signature.
+type httpSignerResponse struct {
+	// Signature is the base64url encoded JWS signature of the payload, e.g. R || S for ES256.
+	Signature string `json:"signature"`
+}
+
+// ContextSigner is a jose.OpaqueSigner whose signatures can be bound to the context of a request, since the
+// SignPayload method of jose.OpaqueSigner does not take a context.
+type ContextSigner interface {
+	jose.OpaqueSigner
+	// WithContext returns a copy of the signer which uses ctx for each signature.
+	WithContext(ctx context.Context) jose.OpaqueSigner
+}
+
+type httpSigner struct {
+	ctx       context.Context
+	client    *http.Client
+	url       string
+	publicJWK *jose.JSONWebKey
+}
+
+var _ ContextSigner = &httpSigner{}
+
+// NewHTTPSigner returns a jose.OpaqueSigner which delegates signing to an external signer process, e.g. one which
+// uses a hardware security module, so that the private key never has to be available to the Supervisor. The signer
+// can be used as the Key of the active JWK of a DynamicJWKSProvider. For each signature, a JSON object with the kid
+// and alg of the publicJWK and the base64url encoded payload is POSTed to the url, which must respond with a JSON
+// object whose signature field is the base64url encoded JWS signature. Signatures which do not verify against the
+// publicJWK are rejected.
+func NewHTTPSigner(client *http.Client, url string, publicJWK *jose.JSONWebKey) ContextSigner {
+	return &httpSigner{
+		ctx:       context.Background(),
+		client:    client,
+		url:       url,
+		publicJWK: publicJWK,
+	}
+}
+
+func (s *httpSigner) Public() *jose.JSONWebKey {
+	return s.publicJWK
+}
+
+func (s *httpSigner) Algs() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{jose.SignatureAlgorithm(s.publicJWK.Algorithm)}
+}
+
+func (s *httpSigner) WithContext(ctx context.Context) jose.OpaqueSigner {
+	signer := *s
+	signer.ctx = ctx
+	return &signer
+}
+
+func (s *httpSigner) SignPayload(payload []byte, alg jose.SignatureAlgorithm) ([]byte, error) {
+	requestBody, err := json.Marshal(&httpSignerRequest{
+		KeyID:     s.publicJWK.KeyID,
+		Algorithm: string(alg),
+		Payload:   base64.RawURLEncoding.EncodeToString(payload),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode signer request: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("could not create signer request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach signer: %w", err)
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read signer response: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signer responded with unexpected status %q", response.Status)
+	}
+
+	var signerResponse httpSignerResponse
+	if err := json.Unmarshal(responseBody, &signerResponse); err != nil {
+		return nil, fmt.Errorf("could not decode signer response: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(signerResponse.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode signature from signer: %w", err)
+	}
+	if err := verifySignature(s.publicJWK, payload, signature, alg); err != nil {
+		return nil, fmt.Errorf("could not verify signature from signer: %w", err)
+	}
+	return signature, nil
+}
+
+// verifySignature checks a JWS signature of the ES256, RS256, or EdDSA algorithm against the public key.
+func verifySignature(publicJWK *jose.JSONWebKey, payload, signature []byte, alg jose.SignatureAlgorithm) error {
+	digest := sha256.Sum256(payload)
+	switch key := publicJWK.Key.(type) {
+	case ed25519.PublicKey:
+		// EdDSA signs the payload itself rather than its digest.
+		if alg != jose.EdDSA || !ed25519.Verify(key, payload, signature) {
+			return constable.Error("invalid EdDSA signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if alg != jose.ES256 || len(signature) != 64 {
+			return constable.Error("invalid ES256 signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		sig := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key, digest[:], r, sig) {
+			return constable.Error("invalid ES256 signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if alg != jose.RS256 {
+			return constable.Error("invalid RS256 signature")
+		}
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicJWK.Key)
+	}
+}
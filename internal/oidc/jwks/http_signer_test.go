@@ -0,0 +1,191 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestHTTPSigner(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicJWK := &jose.JSONWebKey{Key: privateKey.Public(), KeyID: "some-key-id", Algorithm: "ES256", Use: "sig"}
+
+	// goodSigner acts like an external signer which holds the private key.
+	goodSigner := func(t *testing.T) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPost, r.Method)
+			require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+			var request httpSignerRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			require.Equal(t, "some-key-id", request.KeyID)
+			require.Equal(t, "ES256", request.Algorithm)
+			payload, err := base64.RawURLEncoding.DecodeString(request.Payload)
+			require.NoError(t, err)
+
+			digest := sha256.Sum256(payload)
+			r1, s1, err := ecdsa.Sign(rand.Reader, privateKey, digest[:])
+			require.NoError(t, err)
+			signature := make([]byte, 64)
+			r1.FillBytes(signature[:32])
+			s1.FillBytes(signature[32:])
+
+			require.NoError(t, json.NewEncoder(w).Encode(&httpSignerResponse{
+				Signature: base64.RawURLEncoding.EncodeToString(signature),
+			}))
+		}
+	}
+
+	tests := []struct {
+		name          string
+		handler       func(t *testing.T) http.HandlerFunc
+		wantErrPrefix string
+	}{
+		{
+			name:    "happy path",
+			handler: goodSigner,
+		},
+		{
+			name: "signer responds with an error status",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "some error", http.StatusForbidden)
+				}
+			},
+			wantErrPrefix: `signer responded with unexpected status "403 Forbidden"`,
+		},
+		{
+			name: "signer responds with invalid JSON",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte("not-json"))
+				}
+			},
+			wantErrPrefix: "could not decode signer response: ",
+		},
+		{
+			name: "signer responds with an invalid signature encoding",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"signature": "not base64url!"}`))
+				}
+			},
+			wantErrPrefix: "could not decode signature from signer: ",
+		},
+		{
+			name: "signer responds with a signature from the wrong key",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"signature": "` + base64.RawURLEncoding.EncodeToString(make([]byte, 64)) + `"}`))
+				}
+			},
+			wantErrPrefix: "could not verify signature from signer: invalid ES256 signature",
+		},
+		{
+			name: "signer responds with a signature of the wrong length",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"signature": "` + base64.RawURLEncoding.EncodeToString(make([]byte, 32)) + `"}`))
+				}
+			},
+			wantErrPrefix: "could not verify signature from signer: invalid ES256 signature",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(test.handler(t))
+			defer server.Close()
+
+			joseSigner, err := jose.NewSigner(
+				jose.SigningKey{Algorithm: jose.ES256, Key: NewHTTPSigner(server.Client(), server.URL, publicJWK)},
+				nil,
+			)
+			require.NoError(t, err)
+
+			var parsed *jose.JSONWebSignature
+			jws, err := joseSigner.Sign([]byte("some-payload"))
+			if err == nil {
+				parsed, err = jose.ParseSigned(jws.FullSerialize())
+				require.NoError(t, err)
+				_, err = parsed.Verify(privateKey.Public())
+			}
+			if test.wantErrPrefix != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), test.wantErrPrefix)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "some-key-id", parsed.Signatures[0].Protected.KeyID)
+		})
+	}
+}
+
+func TestHTTPSignerEdDSA(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	publicJWK := &jose.JSONWebKey{Key: publicKey, KeyID: "some-key-id", Algorithm: "EdDSA", Use: "sig"}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request httpSignerRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		payload, err := base64.RawURLEncoding.DecodeString(request.Payload)
+		require.NoError(t, err)
+
+		require.NoError(t, json.NewEncoder(w).Encode(&httpSignerResponse{
+			Signature: base64.RawURLEncoding.EncodeToString(ed25519.Sign(privateKey, payload)),
+		}))
+	}))
+	defer server.Close()
+
+	joseSigner, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.EdDSA, Key: NewHTTPSigner(server.Client(), server.URL, publicJWK)},
+		nil,
+	)
+	require.NoError(t, err)
+	jws, err := joseSigner.Sign([]byte("some-payload"))
+	require.NoError(t, err)
+	parsed, err := jose.ParseSigned(jws.FullSerialize())
+	require.NoError(t, err)
+	_, err = parsed.Verify(publicKey)
+	require.NoError(t, err)
+
+	// A valid Ed25519 signature is still rejected when it was requested for another algorithm.
+	_, err = NewHTTPSigner(server.Client(), server.URL, publicJWK).SignPayload([]byte("some-payload"), jose.ES256)
+	require.EqualError(t, err, "could not verify signature from signer: invalid EdDSA signature")
+}
+
+func TestHTTPSignerWithContext(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicJWK := &jose.JSONWebKey{Key: privateKey.Public(), KeyID: "some-key-id", Algorithm: "ES256", Use: "sig"}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to the signer after the context was canceled")
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	signer := NewHTTPSigner(server.Client(), server.URL, publicJWK).WithContext(ctx)
+	_, err = signer.SignPayload([]byte("some-payload"), jose.ES256)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "could not reach signer: ")
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -17,18 +17,20 @@ import (
 	"go.pinniped.dev/internal/fositestorage/authorizationcode"
 	"go.pinniped.dev/internal/fositestorage/openidconnect"
 	"go.pinniped.dev/internal/fositestorage/pkce"
+	"go.pinniped.dev/internal/fositestorage/pushedauthorizerequest"
 	"go.pinniped.dev/internal/fositestorage/refreshtoken"
 	"go.pinniped.dev/internal/fositestoragei"
 	"go.pinniped.dev/internal/oidc/clientregistry"
 )
 
 type KubeStorage struct {
-	clientManager            fosite.ClientManager
-	authorizationCodeStorage oauth2.AuthorizeCodeStorage
-	pkceStorage              fositepkce.PKCERequestStorage
-	oidcStorage              openid.OpenIDConnectRequestStorage
-	accessTokenStorage       accesstoken.RevocationStorage
-	refreshTokenStorage      refreshtoken.RevocationStorage
+	clientManager                 fosite.ClientManager
+	authorizationCodeStorage      oauth2.AuthorizeCodeStorage
+	pkceStorage                   fositepkce.PKCERequestStorage
+	oidcStorage                   openid.OpenIDConnectRequestStorage
+	accessTokenStorage            accesstoken.RevocationStorage
+	refreshTokenStorage           refreshtoken.RevocationStorage
+	pushedAuthorizeRequestStorage pushedauthorizerequest.PushedAuthorizeRequestStorage
 }
 
 var _ fositestoragei.AllFositeStorage = &KubeStorage{}
@@ -36,12 +38,13 @@ var _ fositestoragei.AllFositeStorage = &KubeStorage{}
 func NewKubeStorage(secrets corev1client.SecretInterface, timeoutsConfiguration TimeoutsConfiguration) *KubeStorage {
 	nowFunc := time.Now
 	return &KubeStorage{
-		clientManager:            &clientregistry.StaticClientManager{},
-		authorizationCodeStorage: authorizationcode.New(secrets, nowFunc, timeoutsConfiguration.AuthorizationCodeSessionStorageLifetime),
-		pkceStorage:              pkce.New(secrets, nowFunc, timeoutsConfiguration.PKCESessionStorageLifetime),
-		oidcStorage:              openidconnect.New(secrets, nowFunc, timeoutsConfiguration.OIDCSessionStorageLifetime),
-		accessTokenStorage:       accesstoken.New(secrets, nowFunc, timeoutsConfiguration.AccessTokenSessionStorageLifetime),
-		refreshTokenStorage:      refreshtoken.New(secrets, nowFunc, timeoutsConfiguration.RefreshTokenSessionStorageLifetime),
+		clientManager:                 &clientregistry.StaticClientManager{},
+		authorizationCodeStorage:      authorizationcode.New(secrets, nowFunc, timeoutsConfiguration.AuthorizationCodeSessionStorageLifetime),
+		pkceStorage:                   pkce.New(secrets, nowFunc, timeoutsConfiguration.PKCESessionStorageLifetime),
+		oidcStorage:                   openidconnect.New(secrets, nowFunc, timeoutsConfiguration.OIDCSessionStorageLifetime),
+		accessTokenStorage:            accesstoken.New(secrets, nowFunc, timeoutsConfiguration.AccessTokenSessionStorageLifetime),
+		refreshTokenStorage:           refreshtoken.New(secrets, nowFunc, timeoutsConfiguration.RefreshTokenSessionStorageLifetime),
+		pushedAuthorizeRequestStorage: pushedauthorizerequest.New(secrets, nowFunc, timeoutsConfiguration.PushedAuthorizeRequestSessionStorageLifetime),
 	}
 }
 
@@ -184,6 +187,28 @@ func (k KubeStorage) RevokeRefreshTokenMaybeGracePeriod(ctx context.Context, req
 	return k.refreshTokenStorage.RevokeRefreshTokenMaybeGracePeriod(ctx, requestID, signature)
 }
 
+//
+// Pushed authorization requests:
+//
+// These are keyed by the request_uri which the pushed authorization request endpoint returned to the client.
+//
+// The pushed authorization request endpoint will create these, and the authorize endpoint will delete them when they
+// are used, since each request_uri may only be used once. If a client never uses its request_uri, then these will be
+// garbage collected.
+//
+
+func (k KubeStorage) CreatePushedAuthorizeRequest(ctx context.Context, requestURI string, request *pushedauthorizerequest.PushedAuthorizeRequest) error {
+	return k.pushedAuthorizeRequestStorage.CreatePushedAuthorizeRequest(ctx, requestURI, request)
+}
+
+func (k KubeStorage) GetPushedAuthorizeRequest(ctx context.Context, requestURI string) (*pushedauthorizerequest.PushedAuthorizeRequest, error) {
+	return k.pushedAuthorizeRequestStorage.GetPushedAuthorizeRequest(ctx, requestURI)
+}
+
+func (k KubeStorage) DeletePushedAuthorizeRequest(ctx context.Context, requestURI string) error {
+	return k.pushedAuthorizeRequestStorage.DeletePushedAuthorizeRequest(ctx, requestURI)
+}
+
 //
 // OAuth client definitions:
 //
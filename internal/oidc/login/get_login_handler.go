@@ -4,39 +4,131 @@
 package login
 
 import (
+	"encoding/base64"
+	"errors"
 	"net/http"
 
+	"github.com/ory/fosite"
+
+	"go.pinniped.dev/internal/authenticators"
 	"go.pinniped.dev/internal/oidc"
 	"go.pinniped.dev/internal/oidc/login/loginhtml"
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
 )
 
 const (
 	internalErrorMessage                    = "An internal error occurred. Please contact your administrator for help."
 	incorrectUsernameOrPasswordErrorMessage = "Incorrect username or password."
+	passwordChangeRequiredMessage           = "Your password has expired or must be changed before you can log in. Please choose a new password."
+	passwordChangeErrorMessage              = "Your password could not be changed. Please check your current password and make sure that your new password meets the password requirements."
+	passwordMismatchErrorMessage            = "The new passwords do not match."
 )
 
-func NewGetHandler(loginPath string) HandlerFunc {
+// NewGetHandler returns a HandlerFunc which renders the login page. When the upstream IDP accepts Kerberos tickets,
+// browsers which present a ticket in an "Authorization: Negotiate" header are logged in without showing the login
+// page, and the login page of other browsers is sent along with a challenge to present a ticket.
+func NewGetHandler(issuerURL string, loginPath string, upstreamIDPs oidc.UpstreamIdentityProvidersLister, oauthHelper fosite.OAuth2Provider) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, encodedState string, decodedState *oidc.UpstreamStateParamData) error {
 		alertMessage, hasAlert := getAlert(r)
 
+		// Only offer Kerberos when the user was not sent back to the login page, to avoid challenging the browser
+		// for a ticket again after its ticket was already used.
+		_, ldapUpstream, idpType, err := oidc.FindUpstreamIDPByNameAndType(upstreamIDPs, decodedState.UpstreamName, decodedState.UpstreamType)
+		if err == nil && ldapUpstream.KerberosEnabled() && !hasAlert {
+			encodedToken, hasToken := negotiateTokenFromRequest(r)
+			if !hasToken {
+				// Browsers which do not have a ticket render the login page which is sent along with the challenge.
+				w.Header().Set("WWW-Authenticate", negotiateAuthScheme)
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.WriteHeader(http.StatusUnauthorized)
+			} else {
+				loggedIn, err := loginWithKerberosTicket(w, r, issuerURL, encodedState, decodedState, oauthHelper, ldapUpstream, idpType, encodedToken)
+				if err != nil || loggedIn {
+					return err
+				}
+				// Otherwise, the ticket was not accepted, so fall back to showing the login page without a challenge.
+			}
+		}
+
 		pageInputs := &loginhtml.PageData{
-			PostPath:      loginPath,
-			State:         encodedState,
-			IDPName:       decodedState.UpstreamName,
-			HasAlertError: hasAlert,
-			AlertMessage:  alertMessage,
+			PostPath:       loginPath,
+			State:          encodedState,
+			IDPName:        decodedState.UpstreamName,
+			HasAlertError:  hasAlert,
+			AlertMessage:   alertMessage,
+			ChangePassword: showChangePasswordForm(r),
 		}
 		return loginhtml.Template().Execute(w, pageInputs)
 	}
 }
 
+// loginWithKerberosTicket authenticates the user with the Kerberos ticket that their browser presented. It returns
+// true when it has already responded to the request, and false when the ticket was not accepted.
+func loginWithKerberosTicket(
+	w http.ResponseWriter,
+	r *http.Request,
+	issuerURL string,
+	encodedState string,
+	decodedState *oidc.UpstreamStateParamData,
+	oauthHelper fosite.OAuth2Provider,
+	ldapUpstream provider.UpstreamLDAPIdentityProviderI,
+	idpType psession.ProviderType,
+	encodedToken string,
+) (bool, error) {
+	negotiateToken, err := base64.StdEncoding.DecodeString(encodedToken)
+	if err != nil {
+		plog.DebugErr("error decoding kerberos negotiate token", err, "upstreamName", ldapUpstream.GetName())
+		return false, nil
+	}
+
+	authorizeRequester, err := reconstituteAuthorizeRequest(r, oauthHelper, decodedState)
+	if err != nil {
+		return true, err
+	}
+
+	authenticateResponse, authenticated, err := ldapUpstream.AuthenticateKerberosTicket(r.Context(), negotiateToken)
+	if errors.Is(err, authenticators.ErrPasswordChangeRequired) {
+		// The upstream will not allow the user to log in until they change their password.
+		return true, RedirectToLoginPage(r, w, issuerURL, encodedState, ShowPasswordChangeRequired)
+	}
+	if err != nil {
+		plog.WarningErr("unexpected error during upstream kerberos authentication", err, "upstreamName", ldapUpstream.GetName())
+		return true, RedirectToLoginPage(r, w, issuerURL, encodedState, ShowInternalError)
+	}
+	if !authenticated {
+		return false, nil
+	}
+
+	performAuthcodeRedirect(r, w, oauthHelper, authorizeRequester, ldapUpstream, idpType, authenticateResponse)
+	return true, nil
+}
+
 func getAlert(r *http.Request) (string, bool) {
 	errorParamValue := r.URL.Query().Get(errParamName)
 
 	message := internalErrorMessage
-	if errorParamValue == string(ShowBadUserPassErr) {
+	switch ErrorParamValue(errorParamValue) {
+	case ShowBadUserPassErr:
 		message = incorrectUsernameOrPasswordErrorMessage
+	case ShowPasswordChangeRequired:
+		message = passwordChangeRequiredMessage
+	case ShowPasswordChangeErr:
+		message = passwordChangeErrorMessage
+	case ShowPasswordMismatchErr:
+		message = passwordMismatchErrorMessage
 	}
 
 	return message, errorParamValue != ""
 }
+
+// showChangePasswordForm returns true when the user was sent back to the login page to change their password.
+func showChangePasswordForm(r *http.Request) bool {
+	switch ErrorParamValue(r.URL.Query().Get(errParamName)) {
+	case ShowPasswordChangeRequired, ShowPasswordChangeErr, ShowPasswordMismatchErr:
+		return true
+	default:
+		return false
+	}
+}
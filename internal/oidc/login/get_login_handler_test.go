@@ -4,15 +4,25 @@
 package login
 
 import (
+	"context"
+	"encoding/base64"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes/fake"
 
+	"go.pinniped.dev/internal/authenticators"
 	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/jwks"
 	"go.pinniped.dev/internal/oidc/login/loginhtml"
+	"go.pinniped.dev/internal/psession"
 	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/internal/testutil/oidctestutil"
 )
 
 func TestGetLogin(t *testing.T) {
@@ -72,6 +82,48 @@ func TestGetLogin(t *testing.T) {
 				"An internal error occurred. Please contact your administrator for help.",
 			),
 		},
+		{
+			name: "displays change password form when err=password_change_required param is sent",
+			decodedState: &oidc.UpstreamStateParamData{
+				UpstreamName: testUpstreamName,
+				UpstreamType: testUpstreamType,
+			},
+			encodedState:    testEncodedState,
+			errParam:        "password_change_required",
+			wantStatus:      http.StatusOK,
+			wantContentType: htmlContentType,
+			wantBody: testutil.ExpectedChangePasswordPageHTML(loginhtml.CSS(), testUpstreamName, testPath, testEncodedState,
+				"Your password has expired or must be changed before you can log in. Please choose a new password.",
+			),
+		},
+		{
+			name: "displays change password form with error banner when err=password_change_error param is sent",
+			decodedState: &oidc.UpstreamStateParamData{
+				UpstreamName: testUpstreamName,
+				UpstreamType: testUpstreamType,
+			},
+			encodedState:    testEncodedState,
+			errParam:        "password_change_error",
+			wantStatus:      http.StatusOK,
+			wantContentType: htmlContentType,
+			wantBody: testutil.ExpectedChangePasswordPageHTML(loginhtml.CSS(), testUpstreamName, testPath, testEncodedState,
+				"Your password could not be changed. Please check your current password and make sure that your new password meets the password requirements.",
+			),
+		},
+		{
+			name: "displays change password form with error banner when err=password_mismatch param is sent",
+			decodedState: &oidc.UpstreamStateParamData{
+				UpstreamName: testUpstreamName,
+				UpstreamType: testUpstreamType,
+			},
+			encodedState:    testEncodedState,
+			errParam:        "password_mismatch",
+			wantStatus:      http.StatusOK,
+			wantContentType: htmlContentType,
+			wantBody: testutil.ExpectedChangePasswordPageHTML(loginhtml.CSS(), testUpstreamName, testPath, testEncodedState,
+				"The new passwords do not match.",
+			),
+		},
 		{
 			// If we get an error that we don't recognize, that's also an error, so we
 			// should probably just tell you to contact your administrator...
@@ -96,7 +148,11 @@ func TestGetLogin(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			handler := NewGetHandler(testPath)
+			idps := tt.idps
+			if idps == nil {
+				idps = oidctestutil.NewUpstreamIDPListerBuilder().Build()
+			}
+			handler := NewGetHandler("https://my-downstream-issuer.com/some", testPath, idps, nil)
 			target := testPath + "?state=" + tt.encodedState
 			if tt.errParam != "" {
 				target += "&err=" + tt.errParam
@@ -114,3 +170,214 @@ func TestGetLogin(t *testing.T) {
 		})
 	}
 }
+
+func TestGetLoginWithKerberos(t *testing.T) {
+	const (
+		downstreamIssuer      = "https://my-downstream-issuer.com/path"
+		testPath              = "/path/login"
+		testUpstreamName      = "some-active-directory-idp"
+		testUpstreamType      = "activedirectory"
+		testUpstreamUID       = "active-directory-resource-uid"
+		testEncodedState      = "fake-encoded-state-value"
+		downstreamRedirectURI = "http://127.0.0.1/callback"
+		downstreamClientID    = "pinniped-cli"
+		downstreamState       = "8b-state"
+		downstreamNonce       = "some-nonce-value"
+		downstreamPKCE        = "some-challenge"
+		happyNegotiateToken   = "some-negotiate-token"
+		happyUserDN           = "cn=foo,dn=bar"
+		happyUsername         = "some-mapped-ad-username"
+	)
+
+	happyDecodedState := &oidc.UpstreamStateParamData{
+		AuthParams: url.Values{
+			"response_type":         []string{"code"},
+			"scope":                 []string{"openid"},
+			"client_id":             []string{downstreamClientID},
+			"state":                 []string{downstreamState},
+			"nonce":                 []string{downstreamNonce},
+			"code_challenge":        []string{downstreamPKCE},
+			"code_challenge_method": []string{"S256"},
+			"redirect_uri":          []string{downstreamRedirectURI},
+		}.Encode(),
+		UpstreamName:  testUpstreamName,
+		UpstreamType:  testUpstreamType,
+		FormatVersion: "2",
+	}
+
+	parsedUpstreamURL, err := url.Parse("ldaps://some-ad-host:123?base=dc%3Dexample%2Cdc%3Dcom")
+	require.NoError(t, err)
+
+	kerberosUpstream := func(authenticateFunc func(ctx context.Context, negotiateToken []byte) (*authenticators.Response, bool, error)) *oidctestutil.UpstreamIDPListerBuilder {
+		return oidctestutil.NewUpstreamIDPListerBuilder().WithActiveDirectory(&oidctestutil.TestUpstreamLDAPIdentityProvider{
+			Name:                           testUpstreamName,
+			ResourceUID:                    testUpstreamUID,
+			URL:                            parsedUpstreamURL,
+			AuthenticateKerberosTicketFunc: authenticateFunc,
+		})
+	}
+
+	happyKerberosUpstream := kerberosUpstream(func(ctx context.Context, negotiateToken []byte) (*authenticators.Response, bool, error) {
+		if string(negotiateToken) != happyNegotiateToken {
+			return nil, false, nil
+		}
+		return &authenticators.Response{
+			User: &user.DefaultInfo{Name: happyUsername, UID: "some-ad-uid", Groups: []string{"group1", "group2"}},
+			DN:   happyUserDN,
+		}, true, nil
+	})
+
+	tests := []struct {
+		name                string
+		idps                *oidctestutil.UpstreamIDPListerBuilder
+		errParam            string
+		authorizationHeader string
+
+		wantStatus                   int
+		wantWWWAuthenticate          string
+		wantLoginPage                bool
+		wantRedirectToLoginPageError string
+		wantAuthcodeRedirect         bool
+	}{
+		{
+			name:                 "logs in the user who presented a valid ticket",
+			idps:                 happyKerberosUpstream,
+			authorizationHeader:  "Negotiate " + base64.StdEncoding.EncodeToString([]byte(happyNegotiateToken)),
+			wantStatus:           http.StatusSeeOther,
+			wantAuthcodeRedirect: true,
+		},
+		{
+			name:                 "accepts the negotiate scheme in any case",
+			idps:                 happyKerberosUpstream,
+			authorizationHeader:  "negotiate " + base64.StdEncoding.EncodeToString([]byte(happyNegotiateToken)),
+			wantStatus:           http.StatusSeeOther,
+			wantAuthcodeRedirect: true,
+		},
+		{
+			name:                "challenges the browser for a ticket along with the login page when no ticket was presented",
+			idps:                happyKerberosUpstream,
+			wantStatus:          http.StatusUnauthorized,
+			wantWWWAuthenticate: "Negotiate",
+			wantLoginPage:       true,
+		},
+		{
+			name:                "shows the login page without a challenge when the ticket was not accepted",
+			idps:                happyKerberosUpstream,
+			authorizationHeader: "Negotiate " + base64.StdEncoding.EncodeToString([]byte("some-bad-token")),
+			wantStatus:          http.StatusOK,
+			wantLoginPage:       true,
+		},
+		{
+			name:                "shows the login page without a challenge when the token is not base64 encoded",
+			idps:                happyKerberosUpstream,
+			authorizationHeader: "Negotiate not-base64!",
+			wantStatus:          http.StatusOK,
+			wantLoginPage:       true,
+		},
+		{
+			name:          "shows the login page without a challenge when the user was sent back to the login page",
+			idps:          happyKerberosUpstream,
+			errParam:      "login_error",
+			wantStatus:    http.StatusOK,
+			wantLoginPage: true,
+		},
+		{
+			name:          "shows the login page without a challenge when the upstream does not accept tickets",
+			idps:          kerberosUpstream(nil),
+			wantStatus:    http.StatusOK,
+			wantLoginPage: true,
+		},
+		{
+			name: "redirects to the login page with an error when the upstream fails",
+			idps: kerberosUpstream(func(ctx context.Context, negotiateToken []byte) (*authenticators.Response, bool, error) {
+				return nil, false, fmt.Errorf("some ldap upstream auth error")
+			}),
+			authorizationHeader:          "Negotiate " + base64.StdEncoding.EncodeToString([]byte(happyNegotiateToken)),
+			wantStatus:                   http.StatusSeeOther,
+			wantRedirectToLoginPageError: "internal_error",
+		},
+		{
+			name: "redirects to the change password page when the user's password has expired",
+			idps: kerberosUpstream(func(ctx context.Context, negotiateToken []byte) (*authenticators.Response, bool, error) {
+				return nil, false, fmt.Errorf("user must change their password: %w", authenticators.ErrPasswordChangeRequired)
+			}),
+			authorizationHeader:          "Negotiate " + base64.StdEncoding.EncodeToString([]byte(happyNegotiateToken)),
+			wantStatus:                   http.StatusSeeOther,
+			wantRedirectToLoginPageError: "password_change_required",
+		},
+	}
+
+	for _, test := range tests {
+		tt := test
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			kubeClient := fake.NewSimpleClientset()
+			secretsClient := kubeClient.CoreV1().Secrets("some-namespace")
+			timeoutsConfiguration := oidc.DefaultOIDCTimeoutsConfiguration()
+			kubeOauthStore := oidc.NewKubeStorage(secretsClient, timeoutsConfiguration)
+			hmacSecretFunc := func() []byte { return []byte("some secret - must have at least 32 bytes") }
+			oauthHelper := oidc.FositeOauth2Helper(kubeOauthStore, downstreamIssuer, hmacSecretFunc, jwks.NewDynamicJWKSProvider(), timeoutsConfiguration, nil)
+
+			target := testPath + "?state=" + testEncodedState
+			if tt.errParam != "" {
+				target += "&err=" + tt.errParam
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			if tt.authorizationHeader != "" {
+				req.Header.Set("Authorization", tt.authorizationHeader)
+			}
+			rsp := httptest.NewRecorder()
+
+			handler := NewGetHandler(downstreamIssuer, testPath, tt.idps.Build(), oauthHelper)
+			err := handler(rsp, req, testEncodedState, happyDecodedState)
+			require.NoError(t, err)
+
+			require.Equal(t, tt.wantStatus, rsp.Code)
+			require.Equal(t, tt.wantWWWAuthenticate, rsp.Header().Get("WWW-Authenticate"))
+
+			switch {
+			case tt.wantLoginPage:
+				testutil.RequireEqualContentType(t, rsp.Header().Get("Content-Type"), htmlContentType)
+				var alertMessage string
+				if tt.errParam != "" {
+					alertMessage = "Incorrect username or password."
+				}
+				require.Equal(t, testutil.ExpectedLoginPageHTML(loginhtml.CSS(), testUpstreamName, testPath, testEncodedState, alertMessage), rsp.Body.String())
+				require.Empty(t, kubeClient.Actions())
+			case tt.wantRedirectToLoginPageError != "":
+				require.Equal(t, downstreamIssuer+oidc.PinnipedLoginPath+"?err="+tt.wantRedirectToLoginPageError+"&state="+testEncodedState,
+					rsp.Header().Get("Location"))
+				require.Empty(t, kubeClient.Actions())
+			case tt.wantAuthcodeRedirect:
+				oidctestutil.RequireAuthCodeRegexpMatch(
+					t,
+					rsp.Header().Get("Location"),
+					downstreamRedirectURI+`\?code=([^&]+)&scope=openid&state=`+downstreamState,
+					kubeClient,
+					secretsClient,
+					kubeOauthStore,
+					[]string{"openid"},
+					"ldaps://some-ad-host:123?base=dc%3Dexample%2Cdc%3Dcom&sub=some-ad-uid",
+					happyUsername,
+					[]string{"group1", "group2"},
+					[]string{"openid"},
+					downstreamPKCE,
+					"S256",
+					downstreamNonce,
+					downstreamClientID,
+					downstreamRedirectURI,
+					&psession.CustomSessionData{
+						ProviderUID:     testUpstreamUID,
+						ProviderName:    testUpstreamName,
+						ProviderType:    psession.ProviderTypeActiveDirectory,
+						ActiveDirectory: &psession.ActiveDirectorySessionData{UserDN: happyUserDN},
+					},
+				)
+			default:
+				require.Fail(t, "test should have expected a login page or a redirect")
+			}
+		})
+	}
+}
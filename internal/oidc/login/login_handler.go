@@ -6,27 +6,42 @@ package login
 import (
 	"net/http"
 	"net/url"
+	"strings"
+
+	"github.com/ory/fosite"
 
 	idpdiscoveryv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idpdiscovery/v1alpha1"
+	"go.pinniped.dev/internal/authenticators"
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/httputil/securityheader"
 	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/downstreamsession"
 	"go.pinniped.dev/internal/oidc/login/loginhtml"
+	"go.pinniped.dev/internal/oidc/provider"
 	"go.pinniped.dev/internal/oidc/provider/formposthtml"
 	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
 )
 
 type ErrorParamValue string
 
 const (
-	usernameParamName = "username"
-	passwordParamName = "password"
-	stateParamName    = "state"
-	errParamName      = "err"
-
-	ShowNoError        ErrorParamValue = ""
-	ShowInternalError  ErrorParamValue = "internal_error"
-	ShowBadUserPassErr ErrorParamValue = "login_error"
+	usernameParamName           = "username"
+	passwordParamName           = "password"
+	newPasswordParamName        = "new_password"
+	confirmNewPasswordParamName = "confirm_new_password"
+	stateParamName              = "state"
+	errParamName                = "err"
+
+	ShowNoError                ErrorParamValue = ""
+	ShowInternalError          ErrorParamValue = "internal_error"
+	ShowBadUserPassErr         ErrorParamValue = "login_error"
+	ShowPasswordChangeRequired ErrorParamValue = "password_change_required"
+	ShowPasswordChangeErr      ErrorParamValue = "password_change_error"
+	ShowPasswordMismatchErr    ErrorParamValue = "password_mismatch"
+
+	// negotiateAuthScheme is the HTTP authentication scheme which browsers use to present Kerberos tickets (SPNEGO).
+	negotiateAuthScheme = "Negotiate"
 )
 
 // HandlerFunc is a function that can handle either a GET or POST request for the login endpoint.
@@ -86,14 +101,25 @@ func NewHandler(
 func wrapSecurityHeaders(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		wrapped := securityheader.WrapWithCustomCSP(handler, loginhtml.ContentSecurityPolicy())
-		if r.Method == http.MethodPost {
-			// POST requests can result in the form_post html page, so allow it with CSP headers.
+		if _, hasNegotiateToken := negotiateTokenFromRequest(r); r.Method == http.MethodPost || hasNegotiateToken {
+			// POST requests, and GET requests which present a Kerberos ticket, can result in the form_post html page,
+			// so allow it with CSP headers.
 			wrapped = securityheader.WrapWithCustomCSP(handler, formposthtml.ContentSecurityPolicy())
 		}
 		wrapped.ServeHTTP(w, r)
 	})
 }
 
+// negotiateTokenFromRequest returns the base64 encoded SPNEGO token from the "Authorization: Negotiate" header of
+// the request, if there is one.
+func negotiateTokenFromRequest(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, negotiateAuthScheme) || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
 // RedirectToLoginPage redirects to the GET /login page of the specified issuer.
 // The specified issuer should never end with a "/", which is validated by
 // provider.FederationDomainIssuer when the issuer string comes from that type.
@@ -123,3 +149,57 @@ func RedirectToLoginPage(
 
 	return nil
 }
+
+// reconstituteAuthorizeRequest recreates the downstream authorize request from the original params that were used at
+// the authorization endpoint, which were saved in the state param.
+func reconstituteAuthorizeRequest(
+	r *http.Request,
+	oauthHelper fosite.OAuth2Provider,
+	decodedState *oidc.UpstreamStateParamData,
+) (fosite.AuthorizeRequester, error) {
+	// Get the original params that were used at the authorization endpoint.
+	downstreamAuthParams, err := url.ParseQuery(decodedState.AuthParams)
+	if err != nil {
+		// This shouldn't really happen because the authorization endpoint encoded these query params correctly.
+		plog.Error("error reading state downstream auth params", err)
+		return nil, httperr.New(http.StatusBadRequest, "error reading state downstream auth params")
+	}
+
+	// Recreate enough of the original authorize request so we can pass it to NewAuthorizeRequest().
+	reconstitutedAuthRequest := &http.Request{Form: downstreamAuthParams}
+	authorizeRequester, err := oauthHelper.NewAuthorizeRequest(r.Context(), reconstitutedAuthRequest)
+	if err != nil {
+		// This shouldn't really happen because the authorization endpoint has already validated these params
+		// by calling NewAuthorizeRequest() itself.
+		plog.Error("error using state downstream auth params", err)
+		return nil, httperr.New(http.StatusBadRequest, "error using state downstream auth params")
+	}
+
+	// Automatically grant the openid, offline_access, profile, email, and pinniped:request-audience scopes, but only if they were requested.
+	downstreamsession.GrantScopesIfRequested(authorizeRequester)
+
+	return authorizeRequester, nil
+}
+
+// performAuthcodeRedirect continues the downstream authcode flow for a user who was authenticated by the upstream.
+func performAuthcodeRedirect(
+	r *http.Request,
+	w http.ResponseWriter,
+	oauthHelper fosite.OAuth2Provider,
+	authorizeRequester fosite.AuthorizeRequester,
+	ldapUpstream provider.UpstreamLDAPIdentityProviderI,
+	idpType psession.ProviderType,
+	authenticateResponse *authenticators.Response,
+) {
+	// We had previously interrupted the regular steps of the OIDC authcode flow to show the login page UI.
+	// Now the upstream IDP has authenticated the user, so now we're back into the regular OIDC authcode flow steps.
+	// Both success and error responses from this point onwards should look like the usual fosite redirect
+	// responses, and a happy redirect response will include a downstream authcode.
+	subject := downstreamsession.DownstreamSubjectFromUpstreamLDAP(ldapUpstream, authenticateResponse)
+	username := authenticateResponse.User.GetName()
+	groups := authenticateResponse.User.GetGroups()
+	customSessionData := downstreamsession.MakeDownstreamLDAPOrADCustomSessionData(ldapUpstream, idpType, authenticateResponse)
+	openIDSession := downstreamsession.MakeDownstreamSession(subject, username, groups, customSessionData)
+	downstreamsession.SetAdditionalClaims(openIDSession, authorizeRequester.GetGrantedScopes(), authenticateResponse.AdditionalClaims)
+	oidc.PerformAuthcodeRedirect(r, w, oauthHelper, authorizeRequester, openIDSession, false)
+}
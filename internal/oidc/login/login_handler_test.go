@@ -112,6 +112,7 @@ func TestLoginEndpoint(t *testing.T) {
 		method         string
 		path           string
 		csrfCookie     string
+		authorization  string
 		getHandlerErr  error
 		postHandlerErr error
 
@@ -364,6 +365,18 @@ func TestLoginEndpoint(t *testing.T) {
 			wantEncodedState: happyActiveDirectoryState,
 			wantDecodedState: expectedHappyDecodedUpstreamStateParamForActiveDirectory(),
 		},
+		{
+			name:             "happy GET request which presents a Kerberos ticket for ActiveDirectory upstream",
+			method:           http.MethodGet,
+			path:             newRequestPath().WithState(happyActiveDirectoryState).String(),
+			csrfCookie:       happyCSRFCookie,
+			authorization:    "Negotiate c29tZS10aWNrZXQ=",
+			wantStatus:       http.StatusOK,
+			wantContentType:  htmlContentType,
+			wantBody:         happyGetResult,
+			wantEncodedState: happyActiveDirectoryState,
+			wantDecodedState: expectedHappyDecodedUpstreamStateParamForActiveDirectory(),
+		},
 	}
 
 	for _, test := range tests {
@@ -376,6 +389,9 @@ func TestLoginEndpoint(t *testing.T) {
 			if tt.csrfCookie != "" {
 				req.Header.Set("Cookie", tt.csrfCookie)
 			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
 			rsp := httptest.NewRecorder()
 
 			testGetHandler := func(
@@ -416,7 +432,8 @@ func TestLoginEndpoint(t *testing.T) {
 
 			subject.ServeHTTP(rsp, req)
 
-			if tt.method == http.MethodPost {
+			if tt.method == http.MethodPost || tt.authorization != "" {
+				// A GET request which presents a Kerberos ticket can also result in the form_post html page.
 				testutil.RequireSecurityHeadersWithFormPostPageCSPs(t, rsp)
 			} else {
 				testutil.RequireSecurityHeadersWithLoginPageCSPs(t, rsp)
@@ -62,4 +62,7 @@ type PageData struct {
 	AlertMessage  string
 	MinifiedCSS   template.CSS
 	PostPath      string
+
+	// ChangePassword shows the form for changing an expired password instead of the login form.
+	ChangePassword bool
 }
@@ -52,6 +52,14 @@ func TestTemplate(t *testing.T) {
 	buf = bytes.Buffer{} // clear previous result from buffer
 	require.NoError(t, Template().Execute(&buf, pageInputs))
 	require.Equal(t, expectedHTMLWithoutAlert, buf.String())
+
+	// Render again as the change password form.
+	pageInputs.HasAlertError = true
+	pageInputs.ChangePassword = true
+	expectedChangePasswordHTML := testutil.ExpectedChangePasswordPageHTML(testExpectedCSS, testUpstreamName, testPath, testEncodedState, testAlert)
+	buf = bytes.Buffer{} // clear previous result from buffer
+	require.NoError(t, Template().Execute(&buf, pageInputs))
+	require.Equal(t, expectedChangePasswordHTML, buf.String())
 }
 
 func TestContentSecurityPolicy(t *testing.T) {
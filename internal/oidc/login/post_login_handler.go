@@ -4,14 +4,14 @@
 package login
 
 import (
+	"errors"
 	"net/http"
-	"net/url"
 
 	"github.com/ory/fosite"
 
+	"go.pinniped.dev/internal/authenticators"
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/oidc"
-	"go.pinniped.dev/internal/oidc/downstreamsession"
 	"go.pinniped.dev/internal/plog"
 )
 
@@ -26,27 +26,11 @@ func NewPostHandler(issuerURL string, upstreamIDPs oidc.UpstreamIdentityProvider
 			return httperr.Wrap(http.StatusUnprocessableEntity, "error finding upstream provider", err)
 		}
 
-		// Get the original params that were used at the authorization endpoint.
-		downstreamAuthParams, err := url.ParseQuery(decodedState.AuthParams)
+		authorizeRequester, err := reconstituteAuthorizeRequest(r, oauthHelper, decodedState)
 		if err != nil {
-			// This shouldn't really happen because the authorization endpoint encoded these query params correctly.
-			plog.Error("error reading state downstream auth params", err)
-			return httperr.New(http.StatusBadRequest, "error reading state downstream auth params")
+			return err
 		}
 
-		// Recreate enough of the original authorize request so we can pass it to NewAuthorizeRequest().
-		reconstitutedAuthRequest := &http.Request{Form: downstreamAuthParams}
-		authorizeRequester, err := oauthHelper.NewAuthorizeRequest(r.Context(), reconstitutedAuthRequest)
-		if err != nil {
-			// This shouldn't really happen because the authorization endpoint has already validated these params
-			// by calling NewAuthorizeRequest() itself.
-			plog.Error("error using state downstream auth params", err)
-			return httperr.New(http.StatusBadRequest, "error using state downstream auth params")
-		}
-
-		// Automatically grant the openid, offline_access, and pinniped:request-audience scopes, but only if they were requested.
-		downstreamsession.GrantScopesIfRequested(authorizeRequester)
-
 		// Get the username and password form params from the POST body.
 		username := r.PostFormValue(usernameParamName)
 		password := r.PostFormValue(passwordParamName)
@@ -58,8 +42,33 @@ func NewPostHandler(issuerURL string, upstreamIDPs oidc.UpstreamIdentityProvider
 			return RedirectToLoginPage(r, w, issuerURL, encodedState, ShowBadUserPassErr)
 		}
 
+		// When the user submitted the change password form, change their password before logging in with it.
+		if r.PostForm.Has(newPasswordParamName) {
+			newPassword := r.PostFormValue(newPasswordParamName)
+			if newPassword == "" || newPassword != r.PostFormValue(confirmNewPasswordParamName) {
+				return RedirectToLoginPage(r, w, issuerURL, encodedState, ShowPasswordMismatchErr)
+			}
+
+			changed, err := ldapUpstream.ChangePassword(r.Context(), username, password, newPassword)
+			if err != nil {
+				plog.WarningErr("unexpected error during upstream LDAP password change", err, "upstreamName", ldapUpstream.GetName())
+				return RedirectToLoginPage(r, w, issuerURL, encodedState, ShowInternalError)
+			}
+			if !changed {
+				// The upstream did not accept the current password or the new password.
+				// The user may try again if they'd like, so redirect back to the change password page with an error.
+				return RedirectToLoginPage(r, w, issuerURL, encodedState, ShowPasswordChangeErr)
+			}
+			password = newPassword
+		}
+
 		// Attempt to authenticate the user with the upstream IDP.
 		authenticateResponse, authenticated, err := ldapUpstream.AuthenticateUser(r.Context(), username, password)
+		if errors.Is(err, authenticators.ErrPasswordChangeRequired) {
+			// The password was correct, but the upstream will not allow the user to log in until it is changed.
+			// Redirect to the change password page so the user can choose a new password.
+			return RedirectToLoginPage(r, w, issuerURL, encodedState, ShowPasswordChangeRequired)
+		}
 		if err != nil {
 			plog.WarningErr("unexpected error during upstream LDAP authentication", err, "upstreamName", ldapUpstream.GetName())
 			// There was some problem during authentication with the upstream, aside from bad username/password.
@@ -72,16 +81,7 @@ func NewPostHandler(issuerURL string, upstreamIDPs oidc.UpstreamIdentityProvider
 			return RedirectToLoginPage(r, w, issuerURL, encodedState, ShowBadUserPassErr)
 		}
 
-		// We had previously interrupted the regular steps of the OIDC authcode flow to show the login page UI.
-		// Now the upstream IDP has authenticated the user, so now we're back into the regular OIDC authcode flow steps.
-		// Both success and error responses from this point onwards should look like the usual fosite redirect
-		// responses, and a happy redirect response will include a downstream authcode.
-		subject := downstreamsession.DownstreamSubjectFromUpstreamLDAP(ldapUpstream, authenticateResponse)
-		username = authenticateResponse.User.GetName()
-		groups := authenticateResponse.User.GetGroups()
-		customSessionData := downstreamsession.MakeDownstreamLDAPOrADCustomSessionData(ldapUpstream, idpType, authenticateResponse)
-		openIDSession := downstreamsession.MakeDownstreamSession(subject, username, groups, customSessionData)
-		oidc.PerformAuthcodeRedirect(r, w, oauthHelper, authorizeRequester, openIDSession, false)
+		performAuthcodeRedirect(r, w, oauthHelper, authorizeRequester, ldapUpstream, idpType, authenticateResponse)
 
 		return nil
 	}
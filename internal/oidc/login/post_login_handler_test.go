@@ -54,6 +54,12 @@ func TestPostLoginEndpoint(t *testing.T) {
 		passParam                = "password"
 		badUserPassErrParamValue = "login_error"
 		internalErrParamValue    = "internal_error"
+
+		newPassParam                        = "new_password"
+		confirmNewPassParam                 = "confirm_new_password"
+		passwordChangeRequiredErrParamValue = "password_change_required"
+		passwordChangeErrParamValue         = "password_change_error"
+		passwordMismatchErrParamValue       = "password_mismatch"
 	)
 
 	var (
@@ -134,7 +140,8 @@ func TestPostLoginEndpoint(t *testing.T) {
 
 	happyLDAPUsername := "some-ldap-user"
 	happyLDAPUsernameFromAuthenticator := "some-mapped-ldap-username"
-	happyLDAPPassword := "some-ldap-password" //nolint:gosec
+	happyLDAPPassword := "some-ldap-password"        //nolint:gosec
+	happyLDAPNewPassword := "some-new-ldap-password" //nolint:gosec
 	happyLDAPUID := "some-ldap-uid"
 	happyLDAPUserDN := "cn=foo,dn=bar"
 	happyLDAPGroups := []string{"group1", "group2", "group3"}
@@ -186,6 +193,56 @@ func TestPostLoginEndpoint(t *testing.T) {
 		},
 	}
 
+	passwordChangeRequiredUpstreamLDAPIdentityProvider := oidctestutil.TestUpstreamLDAPIdentityProvider{
+		Name:        ldapUpstreamName,
+		ResourceUID: ldapUpstreamResourceUID,
+		URL:         parsedUpstreamLDAPURL,
+		AuthenticateFunc: func(ctx context.Context, username, password string) (*authenticators.Response, bool, error) {
+			switch password {
+			case happyLDAPPassword:
+				return nil, false, fmt.Errorf("user %q must change their password: %w", username, authenticators.ErrPasswordChangeRequired)
+			case happyLDAPNewPassword:
+				// The password was changed, so the new password works just like the old one used to.
+				return ldapAuthenticateFunc(ctx, username, happyLDAPPassword)
+			default:
+				return nil, false, nil
+			}
+		},
+		ChangePasswordFunc: func(ctx context.Context, username, currentPassword, newPassword string) (bool, error) {
+			if username == happyLDAPUsername && currentPassword == happyLDAPPassword && newPassword == happyLDAPNewPassword {
+				return true, nil
+			}
+			return false, nil
+		},
+	}
+
+	erroringPasswordChangeUpstreamLDAPIdentityProvider := oidctestutil.TestUpstreamLDAPIdentityProvider{
+		Name:        ldapUpstreamName,
+		ResourceUID: ldapUpstreamResourceUID,
+		AuthenticateFunc: func(ctx context.Context, username, password string) (*authenticators.Response, bool, error) {
+			return nil, false, fmt.Errorf("should not have tried to authenticate after the password change failed")
+		},
+		ChangePasswordFunc: func(ctx context.Context, username, currentPassword, newPassword string) (bool, error) {
+			return false, fmt.Errorf("some ldap upstream password change error")
+		},
+	}
+
+	happyChangePasswordFormParams := url.Values{
+		userParam:           []string{happyLDAPUsername},
+		passParam:           []string{happyLDAPPassword},
+		newPassParam:        []string{happyLDAPNewPassword},
+		confirmNewPassParam: []string{happyLDAPNewPassword},
+	}
+
+	modifyHappyChangePasswordFormParams := func(edit func(url.Values)) url.Values {
+		params := url.Values{}
+		for k, v := range happyChangePasswordFormParams {
+			params[k] = v
+		}
+		edit(params)
+		return params
+	}
+
 	expectedHappyActiveDirectoryUpstreamCustomSession := &psession.CustomSessionData{
 		ProviderUID:  activeDirectoryUpstreamResourceUID,
 		ProviderName: activeDirectoryUpstreamName,
@@ -398,13 +455,13 @@ func TestPostLoginEndpoint(t *testing.T) {
 			wantStatus:                        http.StatusSeeOther,
 			wantContentType:                   htmlContentType,
 			wantBodyString:                    "",
-			wantRedirectLocationRegexp:        downstreamRedirectURI + `\?code=([^&]+)&scope=&state=` + happyDownstreamState, // no scopes granted
+			wantRedirectLocationRegexp:        downstreamRedirectURI + `\?code=([^&]+)&scope=email&state=` + happyDownstreamState, // only email was granted
 			wantDownstreamIDTokenSubject:      upstreamLDAPURL + "&sub=" + happyLDAPUID,
 			wantDownstreamIDTokenUsername:     happyLDAPUsernameFromAuthenticator,
 			wantDownstreamIDTokenGroups:       happyLDAPGroups,
 			wantDownstreamRequestedScopes:     []string{"email"}, // only email was requested
 			wantDownstreamRedirectURI:         downstreamRedirectURI,
-			wantDownstreamGrantedScopes:       []string{}, // no scopes granted
+			wantDownstreamGrantedScopes:       []string{"email"}, // only email was granted
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
@@ -470,6 +527,83 @@ func TestPostLoginEndpoint(t *testing.T) {
 			wantBodyString:               "",
 			wantRedirectToLoginPageError: internalErrParamValue,
 		},
+		{
+			name:                         "LDAP login when the upstream requires a password change",
+			idps:                         oidctestutil.NewUpstreamIDPListerBuilder().WithLDAP(&passwordChangeRequiredUpstreamLDAPIdentityProvider),
+			decodedState:                 happyLDAPDecodedState,
+			formParams:                   happyUsernamePasswordFormParams,
+			wantStatus:                   http.StatusSeeOther,
+			wantContentType:              htmlContentType,
+			wantBodyString:               "",
+			wantRedirectToLoginPageError: passwordChangeRequiredErrParamValue,
+		},
+		{
+			name:                              "happy LDAP password change logs in with the new password",
+			idps:                              oidctestutil.NewUpstreamIDPListerBuilder().WithLDAP(&passwordChangeRequiredUpstreamLDAPIdentityProvider),
+			decodedState:                      happyLDAPDecodedState,
+			formParams:                        happyChangePasswordFormParams,
+			wantStatus:                        http.StatusSeeOther,
+			wantContentType:                   htmlContentType,
+			wantBodyString:                    "",
+			wantRedirectLocationRegexp:        happyAuthcodeDownstreamRedirectLocationRegexp,
+			wantDownstreamIDTokenSubject:      upstreamLDAPURL + "&sub=" + happyLDAPUID,
+			wantDownstreamIDTokenUsername:     happyLDAPUsernameFromAuthenticator,
+			wantDownstreamIDTokenGroups:       happyLDAPGroups,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamRedirectURI:         downstreamRedirectURI,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantDownstreamCustomSessionData:   expectedHappyLDAPUpstreamCustomSession,
+		},
+		{
+			name:         "LDAP password change when the new passwords do not match",
+			idps:         oidctestutil.NewUpstreamIDPListerBuilder().WithLDAP(&passwordChangeRequiredUpstreamLDAPIdentityProvider),
+			decodedState: happyLDAPDecodedState,
+			formParams: modifyHappyChangePasswordFormParams(func(params url.Values) {
+				params.Set(confirmNewPassParam, "some-other-password")
+			}),
+			wantStatus:                   http.StatusSeeOther,
+			wantContentType:              htmlContentType,
+			wantBodyString:               "",
+			wantRedirectToLoginPageError: passwordMismatchErrParamValue,
+		},
+		{
+			name:         "LDAP password change with a blank new password",
+			idps:         oidctestutil.NewUpstreamIDPListerBuilder().WithLDAP(&passwordChangeRequiredUpstreamLDAPIdentityProvider),
+			decodedState: happyLDAPDecodedState,
+			formParams: modifyHappyChangePasswordFormParams(func(params url.Values) {
+				params.Set(newPassParam, "")
+				params.Set(confirmNewPassParam, "")
+			}),
+			wantStatus:                   http.StatusSeeOther,
+			wantContentType:              htmlContentType,
+			wantBodyString:               "",
+			wantRedirectToLoginPageError: passwordMismatchErrParamValue,
+		},
+		{
+			name:         "LDAP password change when the upstream refuses to change the password",
+			idps:         oidctestutil.NewUpstreamIDPListerBuilder().WithLDAP(&passwordChangeRequiredUpstreamLDAPIdentityProvider),
+			decodedState: happyLDAPDecodedState,
+			formParams: modifyHappyChangePasswordFormParams(func(params url.Values) {
+				params.Set(passParam, "wrong!")
+			}),
+			wantStatus:                   http.StatusSeeOther,
+			wantContentType:              htmlContentType,
+			wantBodyString:               "",
+			wantRedirectToLoginPageError: passwordChangeErrParamValue,
+		},
+		{
+			name:                         "error during upstream LDAP password change",
+			idps:                         oidctestutil.NewUpstreamIDPListerBuilder().WithLDAP(&erroringPasswordChangeUpstreamLDAPIdentityProvider),
+			decodedState:                 happyLDAPDecodedState,
+			formParams:                   happyChangePasswordFormParams,
+			wantStatus:                   http.StatusSeeOther,
+			wantContentType:              htmlContentType,
+			wantBodyString:               "",
+			wantRedirectToLoginPageError: internalErrParamValue,
+		},
 		{
 			name: "downstream redirect uri does not match what is configured for client",
 			idps: oidctestutil.NewUpstreamIDPListerBuilder().WithLDAP(&upstreamLDAPIdentityProvider),
@@ -654,7 +788,7 @@ func TestPostLoginEndpoint(t *testing.T) {
 			hmacSecretFunc := func() []byte { return []byte("some secret - must have at least 32 bytes") }
 			require.GreaterOrEqual(t, len(hmacSecretFunc()), 32, "fosite requires that hmac secrets have at least 32 bytes")
 			jwksProviderIsUnused := jwks.NewDynamicJWKSProvider()
-			oauthHelper := oidc.FositeOauth2Helper(kubeOauthStore, downstreamIssuer, hmacSecretFunc, jwksProviderIsUnused, timeoutsConfiguration)
+			oauthHelper := oidc.FositeOauth2Helper(kubeOauthStore, downstreamIssuer, hmacSecretFunc, jwksProviderIsUnused, timeoutsConfiguration, nil)
 
 			req := httptest.NewRequest(http.MethodPost, "/ignored", strings.NewReader(tt.formParams.Encode()))
 			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
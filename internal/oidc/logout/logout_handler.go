@@ -0,0 +1,333 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package logout provides a handler for the OIDC RP-initiated logout endpoint, which also notifies other
+// applications of the logout using OIDC front-channel and back-channel logout.
+package logout
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ory/fosite"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/httputil/securityheader"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/jwks"
+	"go.pinniped.dev/internal/oidc/logout/logouthtml"
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// backChannelLogoutEvent is the member of the events claim of a logout token, see
+	// https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken.
+	backChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+	// logoutTokenType is the typ header of a logout token.
+	logoutTokenType = "logout+jwt"
+
+	// backChannelLogoutTimeout limits how long all the back-channel logout URIs together may take to respond, so
+	// that slow applications cannot hold up the browser of the user.
+	backChannelLogoutTimeout = 5 * time.Second
+)
+
+// Storage is the part of the Supervisor's storage which is needed to end a session. It is implemented by
+// oidc.KubeStorage.
+type Storage interface {
+	GetClient(ctx context.Context, id string) (fosite.Client, error)
+	RevokeAccessToken(ctx context.Context, requestID string) error
+	RevokeRefreshToken(ctx context.Context, requestID string) error
+}
+
+// idTokenHintClaims are the claims of the id_token_hint parameter which are used to find the session.
+type idTokenHintClaims struct {
+	josejwt.Claims
+	SessionID string `json:"sid"`
+}
+
+type handler struct {
+	issuer         string
+	jwksProvider   jwks.DynamicJWKSProvider
+	storage        Storage
+	idpLister      oidc.UpstreamIdentityProvidersLister
+	logoutSettings *provider.LogoutSettings
+	httpClient     *http.Client
+}
+
+// NewHandler returns an http.Handler that serves the OIDC RP-initiated logout endpoint, as described in
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html. The session is found using the sid claim of
+// the id_token_hint parameter, and its downstream access and refresh tokens are revoked. The logout is then
+// sent to the back-channel logout clients of the logoutSettings using httpClient, the front-channel logout URIs
+// are loaded in iframes of a logout page, and finally the browser is redirected to the post_logout_redirect_uri
+// parameter or, when configured, to the end_session_endpoint of the upstream OIDC identity provider.
+func NewHandler(
+	issuer string,
+	jwksProvider jwks.DynamicJWKSProvider,
+	storage Storage,
+	idpLister oidc.UpstreamIdentityProvidersLister,
+	logoutSettings *provider.LogoutSettings,
+	httpClient *http.Client,
+) http.Handler {
+	h := &handler{
+		issuer:         issuer,
+		jwksProvider:   jwksProvider,
+		storage:        storage,
+		idpLister:      idpLister,
+		logoutSettings: logoutSettings,
+		httpClient:     httpClient,
+	}
+	return securityheader.WrapWithCustomCSP(
+		httperr.HandlerFunc(h.serveHTTP),
+		logouthtml.ContentSecurityPolicy(logoutSettings.FrontChannelLogoutURIs()),
+	)
+}
+
+func (h *handler) serveHTTP(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		return httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET or POST)", r.Method)
+	}
+	if err := r.ParseForm(); err != nil {
+		return httperr.Wrap(http.StatusBadRequest, "error parsing request params", err)
+	}
+
+	rawIDTokenHint := r.Form.Get("id_token_hint")
+	if rawIDTokenHint == "" {
+		return httperr.New(http.StatusBadRequest, "id_token_hint parameter is required")
+	}
+	claims, err := h.verifyIDTokenHint(rawIDTokenHint)
+	if err != nil {
+		plog.Info("logout request has invalid id_token_hint", "err", err.Error())
+		return httperr.New(http.StatusBadRequest, "id_token_hint parameter is invalid")
+	}
+
+	clientID := r.Form.Get("client_id")
+	if clientID != "" && !claims.Audience.Contains(clientID) {
+		return httperr.New(http.StatusBadRequest, "client_id parameter does not match the audience of the id_token_hint")
+	}
+	if clientID == "" && len(claims.Audience) == 1 {
+		clientID = claims.Audience[0]
+	}
+
+	redirectURI, err := h.postLogoutRedirectURI(r.Context(), clientID, r.Form.Get("post_logout_redirect_uri"), r.Form.Get("state"))
+	if err != nil {
+		return err
+	}
+
+	// The downstream tokens of the session are stored using the ID of the authorize request, which is the sid.
+	// Either of them may already be gone, e.g. when the refresh token was never granted, so errors are only logged.
+	if err := h.storage.RevokeAccessToken(r.Context(), claims.SessionID); err != nil {
+		plog.DebugErr("logout could not revoke access tokens", err, "sid", claims.SessionID)
+	}
+	if err := h.storage.RevokeRefreshToken(r.Context(), claims.SessionID); err != nil {
+		plog.DebugErr("logout could not revoke refresh tokens", err, "sid", claims.SessionID)
+	}
+
+	h.sendBackChannelLogouts(r.Context(), claims)
+
+	if upstreamURI := h.upstreamEndSessionURI(redirectURI); upstreamURI != "" {
+		redirectURI = upstreamURI
+	}
+
+	frontChannelLogoutURIs := h.frontChannelLogoutURIs(claims.SessionID)
+	if len(frontChannelLogoutURIs) == 0 && redirectURI != "" {
+		http.Redirect(w, r, redirectURI, http.StatusSeeOther)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	return logouthtml.Template().Execute(w, &logouthtml.PageData{
+		RedirectURI:            redirectURI,
+		FrontChannelLogoutURIs: frontChannelLogoutURIs,
+	})
+}
+
+// verifyIDTokenHint returns the claims of an ID token which was issued by this FederationDomain. The ID token
+// may have expired, because the user is often logging out of an old session.
+func (h *handler) verifyIDTokenHint(rawIDTokenHint string) (*idTokenHintClaims, error) {
+	token, err := josejwt.ParseSigned(rawIDTokenHint)
+	if err != nil {
+		return nil, err
+	}
+	keySet, _ := h.jwksProvider.GetJWKS(h.issuer)
+	if keySet == nil {
+		return nil, fmt.Errorf("no JWKS found for issuer %q", h.issuer)
+	}
+	keys := keySet.Keys
+	if len(token.Headers) > 0 && token.Headers[0].KeyID != "" {
+		keys = keySet.Key(token.Headers[0].KeyID)
+	}
+
+	var claims idTokenHintClaims
+	verified := false
+	for i := range keys {
+		if err := token.Claims(&keys[i], &claims); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("signature could not be verified using the JWKS of issuer %q", h.issuer)
+	}
+	if claims.Issuer != h.issuer {
+		return nil, fmt.Errorf("issuer %q does not match %q", claims.Issuer, h.issuer)
+	}
+	if claims.Subject == "" || claims.SessionID == "" {
+		return nil, fmt.Errorf("sub and %s claims are required", oidc.DownstreamSessionIDClaim)
+	}
+	return &claims, nil
+}
+
+// postLogoutRedirectURI returns the URI, including the state parameter, to which the browser should be
+// redirected after the logout, or an empty string when no post_logout_redirect_uri was requested.
+func (h *handler) postLogoutRedirectURI(ctx context.Context, clientID, requestedURI, state string) (string, error) {
+	if requestedURI == "" {
+		return "", nil
+	}
+	if !h.logoutSettings.AllowsPostLogoutRedirectURI(requestedURI) && !h.clientAllowsRedirectURI(ctx, clientID, requestedURI) {
+		return "", httperr.New(http.StatusBadRequest, "post_logout_redirect_uri parameter is not allowed")
+	}
+	if state == "" {
+		return requestedURI, nil
+	}
+	return withQueryParams(requestedURI, url.Values{"state": {state}})
+}
+
+// clientAllowsRedirectURI returns true when the URI is one of the redirect URIs of the client, using the same
+// rules as the authorize endpoint.
+func (h *handler) clientAllowsRedirectURI(ctx context.Context, clientID, requestedURI string) bool {
+	if clientID == "" {
+		return false
+	}
+	client, err := h.storage.GetClient(ctx, clientID)
+	if err != nil {
+		return false
+	}
+	redirectURI, err := fosite.MatchRedirectURIWithClientRedirectURIs(requestedURI, client)
+	return err == nil && fosite.IsValidRedirectURI(redirectURI)
+}
+
+// sendBackChannelLogouts posts a logout token to the back-channel logout URI of each back-channel logout client, as
+// described in https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRequest. Each client is sent its own
+// logout token, whose only audience is the client. The clients are notified concurrently, and all of them must
+// respond before the backChannelLogoutTimeout. Failures are logged, since the logout of the user must not depend
+// on the availability of other applications.
+func (h *handler) sendBackChannelLogouts(ctx context.Context, claims *idTokenHintClaims) {
+	clients := h.logoutSettings.BackChannelLogoutClients()
+	if len(clients) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, backChannelLogoutTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		client := client
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := h.sendBackChannelLogout(ctx, claims, client); err != nil {
+				plog.WarningErr("back-channel logout failed", err, "issuer", h.issuer, "clientID", client.ID, "backChannelLogoutURI", client.URI)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (h *handler) sendBackChannelLogout(ctx context.Context, claims *idTokenHintClaims, client provider.BackChannelLogoutClient) error {
+	logoutToken, err := oidc.SignJWT(ctx, h.jwksProvider, h.issuer, logoutTokenType, map[string]interface{}{
+		"iss":                         h.issuer,
+		"aud":                         client.ID,
+		"iat":                         time.Now().Unix(),
+		"jti":                         uuid.NewString(),
+		"sub":                         claims.Subject,
+		oidc.DownstreamSessionIDClaim: claims.SessionID,
+		"events":                      map[string]interface{}{backChannelLogoutEvent: map[string]interface{}{}},
+	})
+	if err != nil {
+		return fmt.Errorf("could not sign logout token: %w", err)
+	}
+
+	body := url.Values{"logout_token": {logoutToken}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.URI, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rsp, err := h.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected response status %q", rsp.Status)
+	}
+	return nil
+}
+
+// upstreamEndSessionURI returns the end_session_endpoint of the upstream OIDC identity provider, including the
+// client ID of the Supervisor and the post-logout redirect of the downstream client, or an empty string when
+// the browser should not be sent to the upstream identity provider.
+func (h *handler) upstreamEndSessionURI(redirectURI string) string {
+	if !h.logoutSettings.UpstreamLogout() {
+		return ""
+	}
+	upstreamIDPs := h.idpLister.GetOIDCIdentityProviders()
+	if len(upstreamIDPs) != 1 {
+		// The Supervisor only supports one upstream identity provider, so there is nothing to log out of when
+		// it is not an OIDC identity provider.
+		return ""
+	}
+	endSessionURL := upstreamIDPs[0].GetEndSessionURL()
+	if endSessionURL == nil {
+		return ""
+	}
+
+	params := url.Values{"client_id": {upstreamIDPs[0].GetClientID()}}
+	if redirectURI != "" {
+		params.Set("post_logout_redirect_uri", redirectURI)
+	}
+	upstreamURI, err := withQueryParams(endSessionURL.String(), params)
+	if err != nil {
+		return ""
+	}
+	return upstreamURI
+}
+
+// frontChannelLogoutURIs returns the front-channel logout URIs with their iss and sid query parameters, see
+// https://openid.net/specs/openid-connect-frontchannel-1_0.html#RPLogout.
+func (h *handler) frontChannelLogoutURIs(sessionID string) []string {
+	var uris []string
+	for _, uri := range h.logoutSettings.FrontChannelLogoutURIs() {
+		uriWithParams, err := withQueryParams(uri, url.Values{"iss": {h.issuer}, "sid": {sessionID}})
+		if err != nil {
+			continue
+		}
+		uris = append(uris, uriWithParams)
+	}
+	return uris
+}
+
+// withQueryParams returns the URI with the params added to its existing query parameters.
+func withQueryParams(uri string, params url.Values) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
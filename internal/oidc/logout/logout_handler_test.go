@@ -0,0 +1,497 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package logout
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/clientregistry"
+	"go.pinniped.dev/internal/oidc/jwks"
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/testutil/oidctestutil"
+)
+
+const (
+	goodIssuer    = "https://some-issuer.com"
+	goodSessionID = "some-session-id"
+)
+
+type fakeStorage struct {
+	revokeErr            error
+	revokedAccessTokens  []string
+	revokedRefreshTokens []string
+}
+
+func (s *fakeStorage) GetClient(ctx context.Context, id string) (fosite.Client, error) {
+	return clientregistry.StaticClientManager{}.GetClient(ctx, id)
+}
+
+func (s *fakeStorage) RevokeAccessToken(_ context.Context, requestID string) error {
+	s.revokedAccessTokens = append(s.revokedAccessTokens, requestID)
+	return s.revokeErr
+}
+
+func (s *fakeStorage) RevokeRefreshToken(_ context.Context, requestID string) error {
+	s.revokedRefreshTokens = append(s.revokedRefreshTokens, requestID)
+	return s.revokeErr
+}
+
+// backChannelServer records the logout tokens which it receives, by request path. When inFlightRequests is more
+// than one, each request is only recorded once that many requests are in flight at the same time, so the logout
+// tokens are only recorded when they are sent concurrently.
+type backChannelServer struct {
+	*httptest.Server
+	mu           sync.Mutex
+	logoutTokens map[string][]string
+}
+
+func newBackChannelServer(t *testing.T, status int, inFlightRequests int) *backChannelServer {
+	s := &backChannelServer{logoutTokens: map[string][]string{}}
+	var arrived sync.WaitGroup
+	allInFlight := make(chan struct{})
+	if inFlightRequests > 1 {
+		arrived.Add(inFlightRequests)
+		go func() {
+			arrived.Wait()
+			close(allInFlight)
+		}()
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+		require.NoError(t, r.ParseForm())
+		if inFlightRequests > 1 {
+			arrived.Done()
+			select {
+			case <-allInFlight:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		s.mu.Lock()
+		s.logoutTokens[r.URL.Path] = append(s.logoutTokens[r.URL.Path], r.PostForm.Get("logout_token"))
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestLogoutHandler(t *testing.T) {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	jwksProvider := jwks.NewDynamicJWKSProvider()
+	jwksProvider.SetIssuerToJWKSMap(
+		map[string]*jose.JSONWebKeySet{goodIssuer: {Keys: []jose.JSONWebKey{
+			{Key: &signingKey.PublicKey, KeyID: "some-key-id", Algorithm: "ES256", Use: "sig"},
+		}}},
+		map[string]*jose.JSONWebKey{goodIssuer: {Key: signingKey, KeyID: "some-key-id"}},
+	)
+	otherJWKSProvider := jwks.NewDynamicJWKSProvider()
+	otherJWKSProvider.SetIssuerToJWKSMap(nil, map[string]*jose.JSONWebKey{goodIssuer: {Key: otherKey, KeyID: "some-key-id"}})
+
+	idToken := func(signer jwks.DynamicJWKSProvider, modify func(claims map[string]interface{})) string {
+		claims := map[string]interface{}{
+			"iss": goodIssuer,
+			"aud": []string{"pinniped-cli"},
+			"sub": "some-subject",
+			"sid": goodSessionID,
+			"iat": time.Now().Add(-time.Hour).Unix(),
+			"exp": time.Now().Add(-time.Minute).Unix(), // an expired ID token is still a fine hint
+		}
+		if modify != nil {
+			modify(claims)
+		}
+		token, err := oidc.SignJWT(context.Background(), signer, goodIssuer, "JWT", claims)
+		require.NoError(t, err)
+		return token
+	}
+	goodIDToken := idToken(jwksProvider, nil)
+
+	endSessionURL, err := url.Parse("https://upstream.example.com/logout?existing=param")
+	require.NoError(t, err)
+	upstreamWithEndSessionURL := oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(
+		oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().
+			WithName("some-oidc-idp").
+			WithClientID("some-upstream-client-id").
+			WithEndSessionURL(endSessionURL).
+			Build(),
+	).Build()
+	upstreamWithoutEndSessionURL := oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(
+		oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().
+			WithName("some-oidc-idp").
+			WithClientID("some-upstream-client-id").
+			Build(),
+	).Build()
+
+	mustLogoutSettings := func(post, front []string, back []provider.BackChannelLogoutClient, upstreamLogout bool) *provider.LogoutSettings {
+		settings, err := provider.NewLogoutSettings(post, front, back, upstreamLogout)
+		require.NoError(t, err)
+		return settings
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		params    url.Values
+		revokeErr error
+		// logoutSettings is given the URL of a back-channel logout server.
+		logoutSettings    func(backChannelURL string) *provider.LogoutSettings
+		backChannelStatus int
+		idpLister         oidc.UpstreamIdentityProvidersLister
+		wantStatus        int
+		wantLocation      string
+		wantBody          string
+		wantBodyContains  []string
+		wantCSPContains   string
+		wantRevoked       bool
+		// wantBackChannelClients are the client IDs which should each be sent one logout token at the path
+		// "/<client ID>" of the back-channel logout server.
+		wantBackChannelClients []string
+		// backChannelsInFlight is how many back-channel logouts must be in flight at the same time before any of
+		// them is recorded, to show that they are sent concurrently.
+		backChannelsInFlight int
+	}{
+		{
+			name:             "GET without a post_logout_redirect_uri shows the logout page",
+			method:           http.MethodGet,
+			params:           url.Values{"id_token_hint": {goodIDToken}},
+			wantStatus:       http.StatusOK,
+			wantBodyContains: []string{"You have been logged out"},
+			wantRevoked:      true,
+		},
+		{
+			name:   "POST with a redirect URI of the client redirects with the state",
+			method: http.MethodPost,
+			params: url.Values{
+				"id_token_hint":            {goodIDToken},
+				"post_logout_redirect_uri": {"http://127.0.0.1:12345/callback"},
+				"state":                    {"some-state"},
+			},
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "http://127.0.0.1:12345/callback?state=some-state",
+			wantRevoked:  true,
+		},
+		{
+			name:   "redirect URI which is allowed by the logout settings",
+			method: http.MethodGet,
+			params: url.Values{
+				"id_token_hint":            {goodIDToken},
+				"client_id":                {"pinniped-cli"},
+				"post_logout_redirect_uri": {"https://app.example.com/logged-out?existing=param"},
+			},
+			logoutSettings: func(string) *provider.LogoutSettings {
+				return mustLogoutSettings([]string{"https://app.example.com/logged-out?existing=param"}, nil, nil, false)
+			},
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "https://app.example.com/logged-out?existing=param",
+			wantRevoked:  true,
+		},
+		{
+			name:   "errors revoking the tokens do not prevent the logout",
+			method: http.MethodGet,
+			params: url.Values{
+				"id_token_hint":            {goodIDToken},
+				"post_logout_redirect_uri": {"http://127.0.0.1/callback"},
+			},
+			revokeErr:    errors.New("none found"),
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "http://127.0.0.1/callback",
+			wantRevoked:  true,
+		},
+		{
+			name:   "back-channel logout",
+			method: http.MethodGet,
+			params: url.Values{
+				"id_token_hint":            {goodIDToken},
+				"post_logout_redirect_uri": {"http://127.0.0.1/callback"},
+			},
+			logoutSettings: func(backChannelURL string) *provider.LogoutSettings {
+				return mustLogoutSettings(nil, nil, []provider.BackChannelLogoutClient{
+					{ID: "some-app", URI: backChannelURL + "/some-app"},
+				}, false)
+			},
+			backChannelStatus:      http.StatusOK,
+			wantStatus:             http.StatusSeeOther,
+			wantLocation:           "http://127.0.0.1/callback",
+			wantRevoked:            true,
+			wantBackChannelClients: []string{"some-app"},
+		},
+		{
+			name:   "back-channel logout sends each client its own logout token, concurrently",
+			method: http.MethodGet,
+			params: url.Values{
+				"id_token_hint":            {goodIDToken},
+				"post_logout_redirect_uri": {"http://127.0.0.1/callback"},
+			},
+			logoutSettings: func(backChannelURL string) *provider.LogoutSettings {
+				return mustLogoutSettings(nil, nil, []provider.BackChannelLogoutClient{
+					{ID: "some-app", URI: backChannelURL + "/some-app"},
+					{ID: "other-app", URI: backChannelURL + "/other-app"},
+				}, false)
+			},
+			backChannelStatus:      http.StatusOK,
+			backChannelsInFlight:   2,
+			wantStatus:             http.StatusSeeOther,
+			wantLocation:           "http://127.0.0.1/callback",
+			wantRevoked:            true,
+			wantBackChannelClients: []string{"some-app", "other-app"},
+		},
+		{
+			name:   "failed back-channel logout does not prevent the logout",
+			method: http.MethodGet,
+			params: url.Values{
+				"id_token_hint":            {goodIDToken},
+				"post_logout_redirect_uri": {"http://127.0.0.1/callback"},
+			},
+			logoutSettings: func(backChannelURL string) *provider.LogoutSettings {
+				return mustLogoutSettings(nil, nil, []provider.BackChannelLogoutClient{
+					{ID: "some-app", URI: backChannelURL + "/some-app"},
+				}, false)
+			},
+			backChannelStatus:      http.StatusInternalServerError,
+			wantStatus:             http.StatusSeeOther,
+			wantLocation:           "http://127.0.0.1/callback",
+			wantRevoked:            true,
+			wantBackChannelClients: []string{"some-app"},
+		},
+		{
+			name:   "front-channel logout shows the logout page with iframes before redirecting",
+			method: http.MethodGet,
+			params: url.Values{
+				"id_token_hint":            {goodIDToken},
+				"post_logout_redirect_uri": {"http://127.0.0.1/callback"},
+				"state":                    {"some-state"},
+			},
+			logoutSettings: func(string) *provider.LogoutSettings {
+				return mustLogoutSettings(nil, []string{"https://app.example.com/frontchannel-logout"}, nil, false)
+			},
+			wantStatus: http.StatusOK,
+			wantBodyContains: []string{
+				`<meta http-equiv="refresh" content="2;url=http://127.0.0.1/callback?state=some-state">`,
+				`<iframe src="https://app.example.com/frontchannel-logout?iss=https%3A%2F%2Fsome-issuer.com&amp;sid=some-session-id" title="logout notification"></iframe>`,
+			},
+			wantCSPContains: "frame-src https://app.example.com;",
+			wantRevoked:     true,
+		},
+		{
+			name:   "upstream logout redirects to the end_session_endpoint of the upstream OIDC identity provider",
+			method: http.MethodGet,
+			params: url.Values{
+				"id_token_hint":            {goodIDToken},
+				"post_logout_redirect_uri": {"http://127.0.0.1/callback"},
+				"state":                    {"some-state"},
+			},
+			logoutSettings: func(string) *provider.LogoutSettings {
+				return mustLogoutSettings(nil, nil, nil, true)
+			},
+			idpLister:  upstreamWithEndSessionURL,
+			wantStatus: http.StatusSeeOther,
+			wantLocation: "https://upstream.example.com/logout?client_id=some-upstream-client-id&existing=param" +
+				"&post_logout_redirect_uri=http%3A%2F%2F127.0.0.1%2Fcallback%3Fstate%3Dsome-state",
+			wantRevoked: true,
+		},
+		{
+			name:   "upstream logout without a post_logout_redirect_uri",
+			method: http.MethodGet,
+			params: url.Values{"id_token_hint": {goodIDToken}},
+			logoutSettings: func(string) *provider.LogoutSettings {
+				return mustLogoutSettings(nil, nil, nil, true)
+			},
+			idpLister:    upstreamWithEndSessionURL,
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "https://upstream.example.com/logout?client_id=some-upstream-client-id&existing=param",
+			wantRevoked:  true,
+		},
+		{
+			name:   "upstream logout when the upstream OIDC identity provider has no end_session_endpoint",
+			method: http.MethodGet,
+			params: url.Values{
+				"id_token_hint":            {goodIDToken},
+				"post_logout_redirect_uri": {"http://127.0.0.1/callback"},
+			},
+			logoutSettings: func(string) *provider.LogoutSettings {
+				return mustLogoutSettings(nil, nil, nil, true)
+			},
+			idpLister:    upstreamWithoutEndSessionURL,
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "http://127.0.0.1/callback",
+			wantRevoked:  true,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodPut,
+			params:     url.Values{"id_token_hint": {goodIDToken}},
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   "Method Not Allowed: PUT (try GET or POST)\n",
+		},
+		{
+			name:       "missing id_token_hint",
+			method:     http.MethodGet,
+			params:     url.Values{},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: id_token_hint parameter is required\n",
+		},
+		{
+			name:       "id_token_hint which is not a JWT",
+			method:     http.MethodGet,
+			params:     url.Values{"id_token_hint": {"not-a-jwt"}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: id_token_hint parameter is invalid\n",
+		},
+		{
+			name:       "id_token_hint signed by another key",
+			method:     http.MethodGet,
+			params:     url.Values{"id_token_hint": {idToken(otherJWKSProvider, nil)}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: id_token_hint parameter is invalid\n",
+		},
+		{
+			name:   "id_token_hint from another issuer",
+			method: http.MethodGet,
+			params: url.Values{"id_token_hint": {idToken(jwksProvider, func(claims map[string]interface{}) {
+				claims["iss"] = "https://other-issuer.com"
+			})}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: id_token_hint parameter is invalid\n",
+		},
+		{
+			name:   "id_token_hint without a sid",
+			method: http.MethodGet,
+			params: url.Values{"id_token_hint": {idToken(jwksProvider, func(claims map[string]interface{}) {
+				delete(claims, "sid")
+			})}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: id_token_hint parameter is invalid\n",
+		},
+		{
+			name:   "client_id which is not an audience of the id_token_hint",
+			method: http.MethodGet,
+			params: url.Values{
+				"id_token_hint": {goodIDToken},
+				"client_id":     {"some-other-client"},
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: client_id parameter does not match the audience of the id_token_hint\n",
+		},
+		{
+			name:   "post_logout_redirect_uri which is not allowed",
+			method: http.MethodGet,
+			params: url.Values{
+				"id_token_hint":            {goodIDToken},
+				"post_logout_redirect_uri": {"https://evil.example.com/callback"},
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: post_logout_redirect_uri parameter is not allowed\n",
+		},
+		{
+			name:   "post_logout_redirect_uri of the client without a known client",
+			method: http.MethodGet,
+			params: url.Values{
+				"id_token_hint": {idToken(jwksProvider, func(claims map[string]interface{}) {
+					claims["aud"] = []string{"pinniped-cli", "some-cluster"}
+				})},
+				"post_logout_redirect_uri": {"http://127.0.0.1/callback"},
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: post_logout_redirect_uri parameter is not allowed\n",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			backChannel := newBackChannelServer(t, test.backChannelStatus, test.backChannelsInFlight)
+			var logoutSettings *provider.LogoutSettings
+			if test.logoutSettings != nil {
+				logoutSettings = test.logoutSettings(backChannel.URL)
+			}
+			idpLister := test.idpLister
+			if idpLister == nil {
+				idpLister = oidctestutil.NewUpstreamIDPListerBuilder().Build()
+			}
+			storage := &fakeStorage{revokeErr: test.revokeErr}
+
+			subject := NewHandler(goodIssuer, jwksProvider, storage, idpLister, logoutSettings, backChannel.Client())
+
+			var req *http.Request
+			if test.method == http.MethodPost {
+				req = httptest.NewRequest(test.method, "/some/path"+oidc.EndSessionEndpointPath, strings.NewReader(test.params.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(test.method, "/some/path"+oidc.EndSessionEndpointPath+"?"+test.params.Encode(), nil)
+			}
+			rsp := httptest.NewRecorder()
+			subject.ServeHTTP(rsp, req)
+
+			require.Equal(t, test.wantStatus, rsp.Code)
+			require.Equal(t, test.wantLocation, rsp.Header().Get("Location"))
+			if test.wantBody != "" {
+				require.Equal(t, test.wantBody, rsp.Body.String())
+			}
+			for _, want := range test.wantBodyContains {
+				require.Contains(t, rsp.Body.String(), want)
+			}
+			require.Contains(t, rsp.Header().Get("Content-Security-Policy"), test.wantCSPContains)
+			require.Equal(t, "DENY", rsp.Header().Get("X-Frame-Options"))
+
+			if test.wantRevoked {
+				require.Equal(t, []string{goodSessionID}, storage.revokedAccessTokens)
+				require.Equal(t, []string{goodSessionID}, storage.revokedRefreshTokens)
+			} else {
+				require.Empty(t, storage.revokedAccessTokens)
+				require.Empty(t, storage.revokedRefreshTokens)
+			}
+
+			require.Len(t, backChannel.logoutTokens, len(test.wantBackChannelClients))
+			for _, clientID := range test.wantBackChannelClients {
+				logoutTokens := backChannel.logoutTokens["/"+clientID]
+				require.Len(t, logoutTokens, 1)
+				requireLogoutToken(t, logoutTokens[0], &signingKey.PublicKey, clientID)
+			}
+		})
+	}
+}
+
+func requireLogoutToken(t *testing.T, rawLogoutToken string, publicKey *ecdsa.PublicKey, wantAud string) {
+	t.Helper()
+
+	token, err := josejwt.ParseSigned(rawLogoutToken)
+	require.NoError(t, err)
+	require.Len(t, token.Headers, 1)
+	require.Equal(t, "some-key-id", token.Headers[0].KeyID)
+	require.Equal(t, "logout+jwt", token.Headers[0].ExtraHeaders[jose.HeaderType])
+
+	var claims josejwt.Claims
+	var extra map[string]interface{}
+	require.NoError(t, token.Claims(publicKey, &claims, &extra))
+	require.Equal(t, goodIssuer, claims.Issuer)
+	require.Equal(t, josejwt.Audience{wantAud}, claims.Audience)
+	require.Equal(t, "some-subject", claims.Subject)
+	require.NotEmpty(t, claims.ID)
+	require.WithinDuration(t, time.Now(), claims.IssuedAt.Time(), time.Minute)
+	require.Nil(t, claims.Expiry)
+	require.Equal(t, goodSessionID, extra["sid"])
+	require.Equal(t, map[string]interface{}{"http://schemas.openid.net/event/backchannel-logout": map[string]interface{}{}}, extra["events"])
+	require.NotContains(t, extra, "nonce")
+}
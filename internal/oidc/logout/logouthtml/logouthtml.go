@@ -0,0 +1,76 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package logouthtml defines HTML templates used by the Supervisor.
+//nolint: gochecknoglobals // This package uses globals to ensure that all parsing and minifying happens at init.
+package logouthtml
+
+import (
+	_ "embed" // Needed to trigger //go:embed directives below.
+	"html/template"
+	"net/url"
+	"strings"
+
+	"github.com/tdewolff/minify/v2/minify"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"go.pinniped.dev/internal/oidc/provider/csp"
+)
+
+var (
+	//go:embed logout.css
+	rawCSS      string
+	minifiedCSS = panicOnError(minify.CSS(rawCSS))
+
+	//go:embed logout.gohtml
+	rawHTMLTemplate string
+)
+
+// Parse the Go templated HTML and inject functions providing the minified inline CSS.
+var parsedHTMLTemplate = template.Must(template.New("logout.gohtml").Funcs(template.FuncMap{
+	"minifiedCSS": func() template.CSS { return template.CSS(CSS()) },
+}).Parse(rawHTMLTemplate))
+
+func panicOnError(s string, err error) string {
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// ContentSecurityPolicy returns the Content-Security-Policy header value to make the Template() operate correctly.
+// The origins of the given front-channel logout URIs are allowed as sources of the iframes of the page.
+//
+// See https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Security-Policy.
+func ContentSecurityPolicy(frontChannelLogoutURIs []string) string {
+	directives := []string{
+		`default-src 'none'`,
+		`style-src '` + csp.Hash(minifiedCSS) + `'`,
+	}
+	origins := sets.NewString()
+	for _, uri := range frontChannelLogoutURIs {
+		if parsed, err := url.Parse(uri); err == nil && parsed.Host != "" {
+			origins.Insert(parsed.Scheme + "://" + parsed.Host)
+		}
+	}
+	if origins.Len() > 0 {
+		directives = append(directives, `frame-src `+strings.Join(origins.List(), " "))
+	}
+	directives = append(directives, `frame-ancestors 'none'`)
+	return strings.Join(directives, "; ")
+}
+
+// Template returns the html/template.Template for rendering the logout page.
+func Template() *template.Template { return parsedHTMLTemplate }
+
+// CSS returns the minified CSS that will be embedded into the page template.
+func CSS() string { return minifiedCSS }
+
+// PageData represents the inputs to the template.
+type PageData struct {
+	// RedirectURI is where the browser goes after the page has been shown, when it is not empty.
+	RedirectURI string
+
+	// FrontChannelLogoutURIs are loaded in hidden iframes, including their iss and sid query parameters.
+	FrontChannelLogoutURIs []string
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package logouthtml
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testExpectedCSS = `html{height:100%}body{font-family:metropolis-light,Helvetica,sans-serif;display:flex;flex-flow:column wrap;justify-content:flex-start;align-items:center;background:linear-gradient(to top,#f8f8f8,white);min-height:100%}h1{font-size:20px;margin:0}.box{display:flex;flex-direction:column;flex-wrap:nowrap;border-radius:4px;border-color:#ddd;border-width:1px;border-style:solid;width:400px;padding:30px;margin:60px 20px 0;background:#fff;font-size:14px}a{margin-top:30px;color:#218fcf}iframe{display:none}`
+
+	// It's okay if this changes in the future, but this gives us a chance to eyeball the formatting.
+	testExpectedCSPWithoutFrames = `default-src 'none'; ` +
+		`style-src 'sha256-yPqNn7AUVzgphlIFM6GT2bYAHDAT9CXZGyQIwTq0ruc='; ` +
+		`frame-ancestors 'none'`
+
+	testExpectedCSPWithFrames = `default-src 'none'; ` +
+		`style-src 'sha256-yPqNn7AUVzgphlIFM6GT2bYAHDAT9CXZGyQIwTq0ruc='; ` +
+		`frame-src http://b.example.com https://a.example.com:8443; ` +
+		`frame-ancestors 'none'`
+)
+
+func TestTemplate(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Template().Execute(&buf, &PageData{
+		RedirectURI: "https://app.example.com/done?state=some-state&other=value",
+		FrontChannelLogoutURIs: []string{
+			"https://a.example.com:8443/frontchannel-logout?iss=https%3A%2F%2Fissuer.example.com&sid=some-sid",
+			"http://b.example.com/frontchannel-logout?iss=https%3A%2F%2Fissuer.example.com&sid=some-sid",
+		},
+	}))
+	require.Equal(t, `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <title>Pinniped Logout</title>
+    <meta charset="UTF-8">
+    <meta http-equiv="refresh" content="2;url=https://app.example.com/done?state=some-state&amp;other=value">
+    <style>`+testExpectedCSS+`</style>
+</head>
+<body>
+<div class="box" aria-label="logout" role="main">
+    <h1>You have been logged out</h1>
+    <a href="https://app.example.com/done?state=some-state&amp;other=value">Continue</a>
+</div>
+<iframe src="https://a.example.com:8443/frontchannel-logout?iss=https%3A%2F%2Fissuer.example.com&amp;sid=some-sid" title="logout notification"></iframe>
+<iframe src="http://b.example.com/frontchannel-logout?iss=https%3A%2F%2Fissuer.example.com&amp;sid=some-sid" title="logout notification"></iframe>
+</body>
+</html>
+`, buf.String())
+
+	// Render again without a redirect or any iframes.
+	buf = bytes.Buffer{} // clear previous result from buffer
+	require.NoError(t, Template().Execute(&buf, &PageData{}))
+	require.Equal(t, `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <title>Pinniped Logout</title>
+    <meta charset="UTF-8">
+    <style>`+testExpectedCSS+`</style>
+</head>
+<body>
+<div class="box" aria-label="logout" role="main">
+    <h1>You have been logged out</h1>
+</div>
+</body>
+</html>
+`, buf.String())
+}
+
+func TestContentSecurityPolicy(t *testing.T) {
+	require.Equal(t, testExpectedCSPWithoutFrames, ContentSecurityPolicy(nil))
+	require.Equal(t, testExpectedCSPWithFrames, ContentSecurityPolicy([]string{
+		"https://a.example.com:8443/frontchannel-logout",
+		"http://b.example.com/frontchannel-logout",
+		"https://a.example.com:8443/other-frontchannel-logout",
+	}))
+}
+
+func TestCSS(t *testing.T) {
+	require.Equal(t, testExpectedCSS, CSS())
+}
+
+func TestHelpers(t *testing.T) {
+	require.Equal(t, "test", panicOnError("test", nil))
+	require.PanicsWithError(t, "some error", func() { panicOnError("", fmt.Errorf("some error")) })
+}
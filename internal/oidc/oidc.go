@@ -30,13 +30,23 @@ import (
 )
 
 const (
-	WellKnownEndpointPath     = "/.well-known/openid-configuration"
-	AuthorizationEndpointPath = "/oauth2/authorize"
-	TokenEndpointPath         = "/oauth2/token" //nolint:gosec // ignore lint warning that this is a credential
-	CallbackEndpointPath      = "/callback"
-	JWKSEndpointPath          = "/jwks.json"
-	PinnipedIDPsPathV1Alpha1  = "/v1alpha1/pinniped_identity_providers"
-	PinnipedLoginPath         = "/login"
+	WellKnownEndpointPath                  = "/.well-known/openid-configuration"
+	AuthorizationEndpointPath              = "/oauth2/authorize"
+	TokenEndpointPath                      = "/oauth2/token" //nolint:gosec // ignore lint warning that this is a credential
+	CallbackEndpointPath                   = "/callback"
+	JWKSEndpointPath                       = "/jwks.json"
+	UserInfoEndpointPath                   = "/oauth2/userinfo"
+	IntrospectionEndpointPath              = "/oauth2/introspect"
+	EndSessionEndpointPath                 = "/oauth2/logout"
+	PushedAuthorizationRequestEndpointPath = "/oauth2/par"
+	PinnipedIDPsPathV1Alpha1               = "/v1alpha1/pinniped_identity_providers"
+	PinnipedLoginPath                      = "/login"
+)
+
+const (
+	// PushedAuthorizeRequestURIPrefix is the prefix of every request_uri which is returned by the pushed
+	// authorization request endpoint, as suggested by https://datatracker.ietf.org/doc/html/rfc9126#section-2.2.
+	PushedAuthorizeRequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
 )
 
 const (
@@ -76,6 +86,14 @@ const (
 	// information.
 	DownstreamGroupsClaim = "groups"
 
+	// DownstreamSessionIDClaim is the "sid" claim in the downstream ID tokens, as described by OpenID Connect
+	// Front-Channel Logout 1.0. It identifies the user's session, so that a logout can end the session.
+	DownstreamSessionIDClaim = "sid"
+
+	// DownstreamActorClaim is the RFC 8693 "act" claim in the ID tokens which are issued by the token exchange on
+	// behalf of a user when another party presented an actor token. It names the party who is acting for the user.
+	DownstreamActorClaim = "act"
+
 	// CSRFCookieLifespan is the length of time that the CSRF cookie is valid. After this time, the
 	// Supervisor's authorization endpoint should give the browser a new CSRF cookie. We set it to
 	// a week so that it is unlikely to expire during a login.
@@ -180,34 +198,91 @@ type TimeoutsConfiguration struct {
 	// when the token does not exist. If this is desirable, then the RefreshTokenSessionStorageLifetime can be made
 	// to be significantly larger than RefreshTokenLifespan, at the cost of slower cleanup.
 	RefreshTokenSessionStorageLifetime time.Duration
+
+	// MaxSessionLifespan is the length of time since the user logged in after which the token endpoint denies
+	// refreshes of the user's session, so the user must log in again. Zero means that sessions may be refreshed
+	// indefinitely, for as long as each refresh token is used before it expires.
+	MaxSessionLifespan time.Duration
+
+	// PushedAuthorizeRequestLifespan is the length of time that a request_uri returned by the pushed authorization
+	// request endpoint may be used at the authorization endpoint. RFC 9126 recommends a short lifetime, since the
+	// client is expected to redirect the browser to the authorization endpoint right away.
+	PushedAuthorizeRequestLifespan time.Duration
+
+	// PushedAuthorizeRequestSessionStorageLifetime is the length of time after which a pushed authorization request
+	// is allowed to be garbage collected from storage. A pushed authorization request is deleted when it is used, and
+	// it is rejected after the PushedAuthorizeRequestLifespan, so this can be just slightly longer than that lifespan.
+	PushedAuthorizeRequestSessionStorageLifetime time.Duration
 }
 
 // Get the defaults for the Supervisor server.
 func DefaultOIDCTimeoutsConfiguration() TimeoutsConfiguration {
+	return OIDCTimeoutsConfiguration(nil)
+}
+
+// OIDCTimeoutsConfiguration returns the defaults for the Supervisor server, overridden by any non-zero token lifetimes
+// of a FederationDomain. The storage lifetimes are derived from the resulting token lifespans.
+func OIDCTimeoutsConfiguration(tokenLifetimes *provider.TokenLifetimes) TimeoutsConfiguration {
 	accessTokenLifespan := 2 * time.Minute
 	authorizationCodeLifespan := 10 * time.Minute
 	refreshTokenLifespan := 9 * time.Hour
+	var idTokenLifespan, maxSessionLifespan time.Duration
+
+	if tokenLifetimes != nil {
+		accessTokenLifespan = durationOrDefault(tokenLifetimes.AccessToken, accessTokenLifespan)
+		authorizationCodeLifespan = durationOrDefault(tokenLifetimes.AuthorizationCode, authorizationCodeLifespan)
+		refreshTokenLifespan = durationOrDefault(tokenLifetimes.RefreshToken, refreshTokenLifespan)
+		idTokenLifespan = tokenLifetimes.IDToken
+		maxSessionLifespan = tokenLifetimes.MaxSession
+	}
+	idTokenLifespan = durationOrDefault(idTokenLifespan, accessTokenLifespan)
+	pushedAuthorizeRequestLifespan := 90 * time.Second
 
 	return TimeoutsConfiguration{
-		UpstreamStateParamLifespan:              90 * time.Minute,
-		AuthorizeCodeLifespan:                   authorizationCodeLifespan,
-		AccessTokenLifespan:                     accessTokenLifespan,
-		IDTokenLifespan:                         accessTokenLifespan,
-		RefreshTokenLifespan:                    refreshTokenLifespan,
-		AuthorizationCodeSessionStorageLifetime: authorizationCodeLifespan + refreshTokenLifespan,
-		PKCESessionStorageLifetime:              authorizationCodeLifespan + (1 * time.Minute),
-		OIDCSessionStorageLifetime:              authorizationCodeLifespan + (1 * time.Minute),
-		AccessTokenSessionStorageLifetime:       refreshTokenLifespan + accessTokenLifespan,
-		RefreshTokenSessionStorageLifetime:      refreshTokenLifespan + accessTokenLifespan,
+		UpstreamStateParamLifespan:                   90 * time.Minute,
+		AuthorizeCodeLifespan:                        authorizationCodeLifespan,
+		AccessTokenLifespan:                          accessTokenLifespan,
+		IDTokenLifespan:                              idTokenLifespan,
+		RefreshTokenLifespan:                         refreshTokenLifespan,
+		MaxSessionLifespan:                           maxSessionLifespan,
+		AuthorizationCodeSessionStorageLifetime:      authorizationCodeLifespan + refreshTokenLifespan,
+		PKCESessionStorageLifetime:                   authorizationCodeLifespan + (1 * time.Minute),
+		OIDCSessionStorageLifetime:                   authorizationCodeLifespan + (1 * time.Minute),
+		AccessTokenSessionStorageLifetime:            refreshTokenLifespan + accessTokenLifespan,
+		RefreshTokenSessionStorageLifetime:           refreshTokenLifespan + accessTokenLifespan,
+		PushedAuthorizeRequestLifespan:               pushedAuthorizeRequestLifespan,
+		PushedAuthorizeRequestSessionStorageLifetime: pushedAuthorizeRequestLifespan + (1 * time.Minute),
 	}
 }
 
+// Validate returns an error when the lifespans would not allow a working session, e.g. when the refresh token
+// would expire before the access token which it is supposed to refresh.
+func (c TimeoutsConfiguration) Validate() error {
+	if c.RefreshTokenLifespan <= c.AccessTokenLifespan {
+		return fmt.Errorf("refresh token lifetime (%s) must be longer than the access token lifetime (%s)",
+			c.RefreshTokenLifespan, c.AccessTokenLifespan)
+	}
+	if c.MaxSessionLifespan != 0 && c.MaxSessionLifespan < c.AccessTokenLifespan {
+		return fmt.Errorf("maximum session lifetime (%s) must not be shorter than the access token lifetime (%s)",
+			c.MaxSessionLifespan, c.AccessTokenLifespan)
+	}
+	return nil
+}
+
+func durationOrDefault(d time.Duration, defaultDuration time.Duration) time.Duration {
+	if d == 0 {
+		return defaultDuration
+	}
+	return d
+}
+
 func FositeOauth2Helper(
 	oauthStore interface{},
 	issuer string,
 	hmacSecretOfLengthAtLeast32Func func() []byte,
 	jwksProvider jwks.DynamicJWKSProvider,
 	timeoutsConfiguration TimeoutsConfiguration,
+	tokenExchangePolicy *provider.TokenExchangePolicy,
 ) fosite.OAuth2Provider {
 	oauthConfig := &compose.Config{
 		IDTokenIssuer: issuer,
@@ -237,7 +312,7 @@ func FositeOauth2Helper(
 		&compose.CommonStrategy{
 			// Note that Fosite requires the HMAC secret to be at least 32 bytes.
 			CoreStrategy:               newDynamicOauth2HMACStrategy(oauthConfig, hmacSecretOfLengthAtLeast32Func),
-			OpenIDConnectTokenStrategy: newDynamicOpenIDConnectECDSAStrategy(oauthConfig, jwksProvider),
+			OpenIDConnectTokenStrategy: newDynamicOpenIDConnectStrategy(oauthConfig, jwksProvider),
 		},
 		nil, // hasher, defaults to using BCrypt when nil. Used for hashing client secrets.
 		compose.OAuth2AuthorizeExplicitFactory,
@@ -245,7 +320,8 @@ func FositeOauth2Helper(
 		compose.OpenIDConnectExplicitFactory,
 		compose.OpenIDConnectRefreshFactory,
 		compose.OAuth2PKCEFactory,
-		TokenExchangeFactory, // handle the "urn:ietf:params:oauth:grant-type:token-exchange" grant type
+		compose.OAuth2TokenIntrospectionFactory,   // validate access tokens for the userinfo and introspection endpoints
+		TokenExchangeFactory(tokenExchangePolicy), // handle the "urn:ietf:params:oauth:grant-type:token-exchange" grant type
 	)
 	provider.(*fosite.Fosite).FormPostHTMLTemplate = formposthtml.Template()
 	return provider
@@ -255,11 +331,12 @@ func FositeOauth2Helper(
 // passed to a plog function (e.g., plog.Info()).
 //
 // Sample usage:
-//   err := someFositeLibraryFunction()
-//   if err != nil {
-//     	plog.Info("some error", FositeErrorForLog(err)...)
-//      ...
-//    }
+//
+//	err := someFositeLibraryFunction()
+//	if err != nil {
+//	  	plog.Info("some error", FositeErrorForLog(err)...)
+//	   ...
+//	 }
 func FositeErrorForLog(err error) []interface{} {
 	rfc6749Error := fosite.ErrorToRFC6749Error(err)
 	keysAndValues := make([]interface{}, 0)
@@ -444,6 +521,7 @@ func PerformAuthcodeRedirect(
 	openIDSession *psession.PinnipedSession,
 	isBrowserless bool,
 ) {
+	SetDownstreamSessionID(openIDSession, authorizeRequester)
 	authorizeResponder, err := oauthHelper.NewAuthorizeResponse(r.Context(), authorizeRequester, openIDSession)
 	if err != nil {
 		plog.WarningErr("error while generating and saving authcode", err)
@@ -456,6 +534,16 @@ func PerformAuthcodeRedirect(
 	oauthHelper.WriteAuthorizeResponse(w, authorizeRequester, authorizeResponder)
 }
 
+// SetDownstreamSessionID sets the "sid" claim of a new downstream session to the ID of the authorize request. Fosite
+// stores all the tokens of the session under this ID, including the tokens which are issued by later refreshes, so
+// it identifies the session when the user logs out.
+func SetDownstreamSessionID(openIDSession *psession.PinnipedSession, authorizeRequester fosite.AuthorizeRequester) {
+	if openIDSession.IDTokenClaims().Extra == nil {
+		openIDSession.IDTokenClaims().Extra = map[string]interface{}{}
+	}
+	openIDSession.IDTokenClaims().Extra[DownstreamSessionIDClaim] = authorizeRequester.GetID()
+}
+
 func rewriteStatusSeeOtherToStatusFoundForBrowserless(w http.ResponseWriter) http.ResponseWriter {
 	// rewrite http.StatusSeeOther to http.StatusFound for backwards compatibility with old pinniped CLIs.
 	// we can drop this in a few releases once we feel enough time has passed for users to update.
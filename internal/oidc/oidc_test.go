@@ -0,0 +1,114 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/oidc/provider"
+)
+
+func TestOIDCTimeoutsConfiguration(t *testing.T) {
+	tests := []struct {
+		name           string
+		tokenLifetimes *provider.TokenLifetimes
+		want           TimeoutsConfiguration
+		wantErr        string
+	}{
+		{
+			name:           "defaults",
+			tokenLifetimes: nil,
+			want: TimeoutsConfiguration{
+				UpstreamStateParamLifespan:                   90 * time.Minute,
+				AuthorizeCodeLifespan:                        10 * time.Minute,
+				AccessTokenLifespan:                          2 * time.Minute,
+				IDTokenLifespan:                              2 * time.Minute,
+				RefreshTokenLifespan:                         9 * time.Hour,
+				AuthorizationCodeSessionStorageLifetime:      9*time.Hour + 10*time.Minute,
+				PKCESessionStorageLifetime:                   11 * time.Minute,
+				OIDCSessionStorageLifetime:                   11 * time.Minute,
+				AccessTokenSessionStorageLifetime:            9*time.Hour + 2*time.Minute,
+				RefreshTokenSessionStorageLifetime:           9*time.Hour + 2*time.Minute,
+				PushedAuthorizeRequestLifespan:               90 * time.Second,
+				PushedAuthorizeRequestSessionStorageLifetime: 150 * time.Second,
+			},
+		},
+		{
+			name:           "empty token lifetimes use the defaults",
+			tokenLifetimes: &provider.TokenLifetimes{},
+			want:           DefaultOIDCTimeoutsConfiguration(),
+		},
+		{
+			name: "all token lifetimes are overridden and the storage lifetimes are derived from them",
+			tokenLifetimes: &provider.TokenLifetimes{
+				AccessToken:       5 * time.Minute,
+				IDToken:           15 * time.Minute,
+				RefreshToken:      time.Hour,
+				AuthorizationCode: 2 * time.Minute,
+				MaxSession:        8 * time.Hour,
+			},
+			want: TimeoutsConfiguration{
+				UpstreamStateParamLifespan:                   90 * time.Minute,
+				AuthorizeCodeLifespan:                        2 * time.Minute,
+				AccessTokenLifespan:                          5 * time.Minute,
+				IDTokenLifespan:                              15 * time.Minute,
+				RefreshTokenLifespan:                         time.Hour,
+				MaxSessionLifespan:                           8 * time.Hour,
+				AuthorizationCodeSessionStorageLifetime:      time.Hour + 2*time.Minute,
+				PKCESessionStorageLifetime:                   3 * time.Minute,
+				OIDCSessionStorageLifetime:                   3 * time.Minute,
+				AccessTokenSessionStorageLifetime:            time.Hour + 5*time.Minute,
+				RefreshTokenSessionStorageLifetime:           time.Hour + 5*time.Minute,
+				PushedAuthorizeRequestLifespan:               90 * time.Second,
+				PushedAuthorizeRequestSessionStorageLifetime: 150 * time.Second,
+			},
+		},
+		{
+			name:           "ID token lifetime defaults to the overridden access token lifetime",
+			tokenLifetimes: &provider.TokenLifetimes{AccessToken: 5 * time.Minute},
+			want: TimeoutsConfiguration{
+				UpstreamStateParamLifespan:                   90 * time.Minute,
+				AuthorizeCodeLifespan:                        10 * time.Minute,
+				AccessTokenLifespan:                          5 * time.Minute,
+				IDTokenLifespan:                              5 * time.Minute,
+				RefreshTokenLifespan:                         9 * time.Hour,
+				AuthorizationCodeSessionStorageLifetime:      9*time.Hour + 10*time.Minute,
+				PKCESessionStorageLifetime:                   11 * time.Minute,
+				OIDCSessionStorageLifetime:                   11 * time.Minute,
+				AccessTokenSessionStorageLifetime:            9*time.Hour + 5*time.Minute,
+				RefreshTokenSessionStorageLifetime:           9*time.Hour + 5*time.Minute,
+				PushedAuthorizeRequestLifespan:               90 * time.Second,
+				PushedAuthorizeRequestSessionStorageLifetime: 150 * time.Second,
+			},
+		},
+		{
+			name:           "refresh token lifetime must be longer than the access token lifetime",
+			tokenLifetimes: &provider.TokenLifetimes{AccessToken: time.Hour, RefreshToken: time.Hour},
+			wantErr:        "refresh token lifetime (1h0m0s) must be longer than the access token lifetime (1h0m0s)",
+		},
+		{
+			name:           "maximum session lifetime must not be shorter than the access token lifetime",
+			tokenLifetimes: &provider.TokenLifetimes{AccessToken: 10 * time.Minute, MaxSession: 5 * time.Minute},
+			wantErr:        "maximum session lifetime (5m0s) must not be shorter than the access token lifetime (10m0s)",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := OIDCTimeoutsConfiguration(test.tokenLifetimes)
+			err := got.Validate()
+			if test.wantErr != "" {
+				require.EqualError(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.want, got)
+		})
+	}
+}
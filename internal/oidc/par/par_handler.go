@@ -0,0 +1,161 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package par provides a handler for the RFC 9126 pushed authorization request endpoint.
+package par
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ory/fosite"
+
+	"go.pinniped.dev/internal/fositestorage/pushedauthorizerequest"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	clientIDParamName   = "client_id"
+	requestParamName    = "request"
+	requestURIParamName = "request_uri"
+)
+
+// response is the body of a successful response, as described in
+// https://datatracker.ietf.org/doc/html/rfc9126#section-2.2.
+type response struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int64  `json:"expires_in"`
+}
+
+// errorResponse is the body of an error response, as described in
+// https://datatracker.ietf.org/doc/html/rfc9126#section-2.3.
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// NewHandler returns an http.Handler that serves the RFC 9126 pushed authorization request endpoint. A client posts
+// a signed request object which contains the params of an authorization request, and receives a short-lived
+// request_uri which it then sends to the authorization endpoint instead of the params. Since each pushed request is
+// stored, the signature of the request object authenticates the client, so that only the clients which have keys in
+// the requestObjectVerifier can store requests. The params are validated using the given oauthHelper, which should
+// not have storage, since nothing is stored by fosite until the user logs in using the authorization endpoint.
+func NewHandler(
+	issuer string,
+	oauthHelper fosite.OAuth2Provider,
+	storage pushedauthorizerequest.PushedAuthorizeRequestStorage,
+	requestObjectVerifier *provider.RequestObjectVerifier,
+	lifespan time.Duration,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, `Method not allowed (try POST)`, http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			writeError(w, fosite.ErrInvalidRequest.WithHint("Unable to parse form params."))
+			return
+		}
+		params := r.PostForm
+		clientID := params.Get(clientIDParamName)
+
+		if params.Get(requestURIParamName) != "" {
+			writeError(w, fosite.ErrInvalidRequest.WithHint("The request_uri parameter must not be pushed."))
+			return
+		}
+
+		requestObject := params.Get(requestParamName)
+		if requestObject == "" {
+			writeError(w, fosite.ErrInvalidClient.WithHint("The client must be authenticated by a signed request object."))
+			return
+		}
+		params, err := requestObjectVerifier.Verify(requestObject, issuer, clientID, time.Now())
+		if errors.Is(err, provider.ErrRequestObjectsNotSupported) {
+			writeError(w, fosite.ErrRequestNotSupported.WithWrap(err).WithHint(err.Error()))
+			return
+		}
+		if errors.Is(err, provider.ErrRequestObjectNotVerified) {
+			writeError(w, fosite.ErrInvalidClient.WithWrap(err).WithHint(err.Error()))
+			return
+		}
+		if err != nil {
+			writeError(w, fosite.ErrInvalidRequestObject.WithWrap(err).WithHint(err.Error()))
+			return
+		}
+
+		// Validate the params in the same way as the authorization endpoint will validate them later, so that the
+		// client learns about an invalid request right away.
+		authorizeRequest, err := http.NewRequestWithContext(r.Context(), http.MethodGet, issuer+oidc.AuthorizationEndpointPath, nil)
+		if err != nil {
+			writeError(w, fosite.ErrServerError.WithWrap(err))
+			return
+		}
+		authorizeRequest.Form = params
+		authorizeRequester, err := oauthHelper.NewAuthorizeRequest(r.Context(), authorizeRequest)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		requestURI, err := generateRequestURI(rand.Reader)
+		if err != nil {
+			writeError(w, fosite.ErrServerError.WithWrap(err))
+			return
+		}
+		if err := storage.CreatePushedAuthorizeRequest(r.Context(), requestURI, &pushedauthorizerequest.PushedAuthorizeRequest{
+			ClientID:  authorizeRequester.GetClient().GetID(),
+			Params:    params,
+			ExpiresAt: time.Now().Add(lifespan),
+		}); err != nil {
+			plog.WarningErr("error storing pushed authorization request", err)
+			writeError(w, fosite.ErrServerError.WithWrap(err))
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, &response{
+			RequestURI: requestURI,
+			ExpiresIn:  int64(lifespan.Seconds()),
+		})
+	})
+}
+
+// generateRequestURI returns a request_uri which cannot be guessed, since anyone who knows it could use it to start
+// a login, see https://datatracker.ietf.org/doc/html/rfc9126#section-7.1.
+func generateRequestURI(reader io.Reader) (string, error) {
+	var buf [32]byte
+	if _, err := io.ReadFull(reader, buf[:]); err != nil {
+		return "", err
+	}
+	return oidc.PushedAuthorizeRequestURIPrefix + hex.EncodeToString(buf[:]), nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	rfc6749Error := fosite.ErrorToRFC6749Error(err)
+	plog.Info("pushed authorization request error", oidc.FositeErrorForLog(err)...)
+	writeJSON(w, rfc6749Error.CodeField, &errorResponse{
+		Error:            rfc6749Error.ErrorField,
+		ErrorDescription: rfc6749Error.GetDescription(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_, _ = w.Write(b.Bytes())
+}
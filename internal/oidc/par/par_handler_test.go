@@ -0,0 +1,264 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package par
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+
+	"go.pinniped.dev/internal/fositestorage/pushedauthorizerequest"
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/oidc/provider"
+)
+
+const (
+	goodIssuer   = "https://some-issuer.com/some/path"
+	goodClientID = "pinniped-cli"
+	goodLifespan = 90 * time.Second
+)
+
+type fakeStorage struct {
+	requests  map[string]*pushedauthorizerequest.PushedAuthorizeRequest
+	createErr error
+}
+
+func (s *fakeStorage) CreatePushedAuthorizeRequest(_ context.Context, requestURI string, request *pushedauthorizerequest.PushedAuthorizeRequest) error {
+	if s.createErr != nil {
+		return s.createErr
+	}
+	s.requests[requestURI] = request
+	return nil
+}
+
+func (s *fakeStorage) GetPushedAuthorizeRequest(_ context.Context, requestURI string) (*pushedauthorizerequest.PushedAuthorizeRequest, error) {
+	request, ok := s.requests[requestURI]
+	if !ok {
+		return nil, fosite.ErrNotFound
+	}
+	return request, nil
+}
+
+func (s *fakeStorage) DeletePushedAuthorizeRequest(_ context.Context, requestURI string) error {
+	delete(s.requests, requestURI)
+	return nil
+}
+
+func TestPushedAuthorizationRequestHandler(t *testing.T) {
+	hmacSecretFunc := func() []byte { return []byte("some secret - must have at least 32 bytes") }
+	oauthHelper := oidc.FositeOauth2Helper(oidc.NullStorage{}, goodIssuer, hmacSecretFunc, nil, oidc.DefaultOIDCTimeoutsConfiguration(), nil)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &key.PublicKey, KeyID: "client-key", Algorithm: string(jose.ES256)},
+	}})
+	require.NoError(t, err)
+	verifier, err := provider.NewRequestObjectVerifier([]provider.RequestObjectClient{
+		{ID: goodClientID, JWKS: string(jwks)},
+		{ID: "some-unregistered-client", JWKS: string(jwks)},
+	})
+	require.NoError(t, err)
+
+	happyParams := url.Values{
+		"response_type":         []string{"code"},
+		"scope":                 []string{"openid offline_access"},
+		"client_id":             []string{goodClientID},
+		"state":                 []string{"some-state-value"},
+		"nonce":                 []string{"some-nonce-value"},
+		"code_challenge":        []string{"some-challenge"},
+		"code_challenge_method": []string{"S256"},
+		"redirect_uri":          []string{"http://127.0.0.1/callback"},
+	}
+	modifiedHappyParams := func(overrides map[string]string) url.Values {
+		params := url.Values{}
+		for k, v := range happyParams {
+			params[k] = v
+		}
+		for k, v := range overrides {
+			params.Set(k, v)
+		}
+		return params
+	}
+	signedRequestObject := func(params url.Values) string {
+		claims := map[string]interface{}{
+			"iss": params.Get("client_id"),
+			"aud": goodIssuer,
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+		for k := range params {
+			claims[k] = params.Get(k)
+		}
+		signer, err := jose.NewSigner(
+			jose.SigningKey{Algorithm: jose.ES256, Key: key},
+			(&jose.SignerOptions{}).WithHeader("kid", "client-key"),
+		)
+		require.NoError(t, err)
+		token, err := josejwt.Signed(signer).Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+	signedParams := func(params url.Values) url.Values {
+		return url.Values{
+			"client_id": []string{params.Get("client_id")},
+			"request":   []string{signedRequestObject(params)},
+		}
+	}
+
+	tests := []struct {
+		name                        string
+		method                      string
+		params                      url.Values
+		requestObjectsNotConfigured bool
+		storageErr                  error
+		wantStatus                  int
+		wantBodyJSON                string
+		wantBodyString              string
+		wantParams                  url.Values
+	}{
+		{
+			name:       "happy path",
+			method:     http.MethodPost,
+			params:     signedParams(happyParams),
+			wantStatus: http.StatusCreated,
+			wantParams: happyParams,
+		},
+		{
+			name:           "bad method",
+			method:         http.MethodGet,
+			wantStatus:     http.StatusMethodNotAllowed,
+			wantBodyString: "Method not allowed (try POST)\n",
+		},
+		{
+			name:       "params without a signed request object",
+			method:     http.MethodPost,
+			params:     happyParams,
+			wantStatus: http.StatusUnauthorized,
+			wantBodyJSON: `{"error": "invalid_client", "error_description": "Client authentication failed (e.g., unknown client, ` +
+				`no client authentication included, or unsupported authentication method). The client must be authenticated by a signed request object."}`,
+		},
+		{
+			name:       "request_uri param",
+			method:     http.MethodPost,
+			params:     modifiedHappyParams(map[string]string{"request_uri": oidc.PushedAuthorizeRequestURIPrefix + "some-request"}),
+			wantStatus: http.StatusBadRequest,
+			wantBodyJSON: `{"error": "invalid_request", "error_description": "The request is missing a required parameter, ` +
+				`includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. ` +
+				`The request_uri parameter must not be pushed."}`,
+		},
+		{
+			name:       "unknown client",
+			method:     http.MethodPost,
+			params:     signedParams(modifiedHappyParams(map[string]string{"client_id": "some-unregistered-client"})),
+			wantStatus: http.StatusUnauthorized,
+			wantBodyJSON: `{"error": "invalid_client", "error_description": "Client authentication failed (e.g., unknown client, ` +
+				`no client authentication included, or unsupported authentication method). The requested OAuth 2.0 Client does not exist."}`,
+		},
+		{
+			name:       "invalid redirect_uri",
+			method:     http.MethodPost,
+			params:     signedParams(modifiedHappyParams(map[string]string{"redirect_uri": "https://attacker.example.com/callback"})),
+			wantStatus: http.StatusBadRequest,
+			wantBodyJSON: `{"error": "invalid_request", "error_description": "The request is missing a required parameter, ` +
+				`includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. ` +
+				`The 'redirect_uri' parameter does not match any of the OAuth 2.0 Client's pre-registered redirect urls."}`,
+		},
+		{
+			name:                        "request object when request objects are not configured",
+			method:                      http.MethodPost,
+			params:                      signedParams(happyParams),
+			requestObjectsNotConfigured: true,
+			wantStatus:                  http.StatusBadRequest,
+			wantBodyJSON: `{"error": "request_not_supported", "error_description": "The OP does not support use of the request parameter. ` +
+				`request objects are not supported by this federation domain"}`,
+		},
+		{
+			name:   "request object sent by a client which has no keys",
+			method: http.MethodPost,
+			params: url.Values{
+				"client_id": []string{"some-other-client"},
+				"request":   []string{signedRequestObject(happyParams)},
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBodyJSON: `{"error": "invalid_client", "error_description": "Client authentication failed (e.g., unknown client, ` +
+				`no client authentication included, or unsupported authentication method). request object signature could not be verified"}`,
+		},
+		{
+			name:   "request object of another client",
+			method: http.MethodPost,
+			params: url.Values{
+				"client_id": []string{"some-unregistered-client"},
+				"request":   []string{signedRequestObject(happyParams)},
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBodyJSON: `{"error": "invalid_request_object", "error_description": "The request parameter contains an invalid Request Object. ` +
+				`request object has invalid claims: square/go-jose/jwt: validation failed, invalid issuer claim (iss)"}`,
+		},
+		{
+			name:       "storage error",
+			method:     http.MethodPost,
+			params:     signedParams(happyParams),
+			storageErr: errors.New("some storage error"),
+			wantStatus: http.StatusInternalServerError,
+			wantBodyJSON: `{"error": "server_error", "error_description": "The authorization server encountered an unexpected ` +
+				`condition that prevented it from fulfilling the request."}`,
+		},
+	}
+	for _, test := range tests {
+		tt := test
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &fakeStorage{requests: map[string]*pushedauthorizerequest.PushedAuthorizeRequest{}, createErr: tt.storageErr}
+			requestObjectVerifier := verifier
+			if tt.requestObjectsNotConfigured {
+				requestObjectVerifier = nil
+			}
+			subject := NewHandler(goodIssuer, oauthHelper, storage, requestObjectVerifier, goodLifespan)
+
+			req := httptest.NewRequest(tt.method, "/some/path"+oidc.PushedAuthorizationRequestEndpointPath, strings.NewReader(tt.params.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rsp := httptest.NewRecorder()
+			subject.ServeHTTP(rsp, req)
+
+			require.Equal(t, tt.wantStatus, rsp.Code)
+
+			switch {
+			case tt.wantBodyString != "":
+				require.Equal(t, tt.wantBodyString, rsp.Body.String())
+				require.Empty(t, storage.requests)
+			case tt.wantBodyJSON != "":
+				require.Equal(t, "application/json", rsp.Header().Get("Content-Type"))
+				require.Equal(t, "no-store", rsp.Header().Get("Cache-Control"))
+				require.JSONEq(t, tt.wantBodyJSON, rsp.Body.String())
+				require.Empty(t, storage.requests)
+			default:
+				require.Equal(t, "application/json", rsp.Header().Get("Content-Type"))
+				require.Equal(t, "no-store", rsp.Header().Get("Cache-Control"))
+				var body response
+				require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &body))
+				require.Regexp(t, "^"+oidc.PushedAuthorizeRequestURIPrefix+"[0-9a-f]{64}$", body.RequestURI)
+				require.Equal(t, int64(90), body.ExpiresIn)
+
+				stored, err := storage.GetPushedAuthorizeRequest(context.Background(), body.RequestURI)
+				require.NoError(t, err)
+				require.Equal(t, goodClientID, stored.ClientID)
+				require.Equal(t, tt.wantParams, stored.Params)
+				require.WithinDuration(t, time.Now().Add(goodLifespan), stored.ExpiresAt, 5*time.Second)
+			}
+		})
+	}
+}
@@ -43,6 +43,10 @@ type UpstreamOIDCIdentityProviderI interface {
 	// HasUserInfoURL returns whether there is a non-empty value for userinfo_endpoint fetched from discovery.
 	HasUserInfoURL() bool
 
+	// GetEndSessionURL returns the end_session_endpoint fetched from discovery, or nil when the provider does not
+	// offer RP-initiated logout.
+	GetEndSessionURL() *url.URL
+
 	// GetScopes returns the scopes to request in authorization (authcode or password grant) flow.
 	GetScopes() []string
 
@@ -107,8 +111,22 @@ type UpstreamLDAPIdentityProviderI interface {
 	// UserAuthenticator adds an interface method for performing user authentication against the upstream LDAP provider.
 	authenticators.UserAuthenticator
 
-	// PerformRefresh performs a refresh against the upstream LDAP identity provider
-	PerformRefresh(ctx context.Context, storedRefreshAttributes StoredRefreshAttributes) (groups []string, err error)
+	// PerformRefresh performs a refresh against the upstream LDAP identity provider. It returns the user's current
+	// groups, the current values of the user's additional claims, and any warnings which should be shown to the user,
+	// e.g. about their upcoming password expiration.
+	PerformRefresh(ctx context.Context, storedRefreshAttributes StoredRefreshAttributes) (groups []string, additionalClaims map[string]string, warnings []string, err error)
+
+	// ChangePassword changes the password of the user with the given username, after verifying their current
+	// password. It returns false with a nil error when the upstream refused the change, e.g. because the current
+	// password was wrong or because the new password does not meet the upstream's password policy.
+	ChangePassword(ctx context.Context, username, currentPassword, newPassword string) (bool, error)
+
+	// KerberosEnabled returns true when users may log in by presenting a Kerberos ticket during browser-based logins.
+	KerberosEnabled() bool
+
+	// AuthenticateKerberosTicket authenticates the user who presented the given SPNEGO token, which is the decoded
+	// value of an "Authorization: Negotiate" header. It returns false with a nil error when the ticket was not valid.
+	AuthenticateKerberosTicket(ctx context.Context, negotiateToken []byte) (*authenticators.Response, bool, error)
 }
 
 type StoredRefreshAttributes struct {
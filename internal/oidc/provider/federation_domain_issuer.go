@@ -1,4 +1,4 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2022 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package provider
@@ -17,10 +17,46 @@ type FederationDomainIssuer struct {
 	issuer     string
 	issuerHost string
 	issuerPath string
+
+	tokenExchangePolicy  *TokenExchangePolicy
+	tokenLifetimes       *TokenLifetimes
+	introspectionClients *IntrospectionClients
+	logoutSettings       *LogoutSettings
+	requestObjects       *RequestObjectVerifier
 }
 
-func NewFederationDomainIssuer(issuer string) (*FederationDomainIssuer, error) {
-	p := FederationDomainIssuer{issuer: issuer}
+// FederationDomainIssuerOptions are the optional settings of a FederationDomainIssuer.
+type FederationDomainIssuerOptions struct {
+	// TokenExchangePolicy decides which audiences may be requested using the token exchange.
+	// Nil allows any audience.
+	TokenExchangePolicy *TokenExchangePolicy
+
+	// TokenLifetimes are the lifetimes of the tokens which are issued. Nil uses the default token lifetimes.
+	TokenLifetimes *TokenLifetimes
+
+	// IntrospectionClients are the clients which may use the token introspection endpoint.
+	// Nil denies every request to the token introspection endpoint.
+	IntrospectionClients *IntrospectionClients
+
+	// LogoutSettings decide where users may be redirected after a logout.
+	// Nil allows only the redirect URIs of the client.
+	LogoutSettings *LogoutSettings
+
+	// RequestObjects verifies the signed request objects of authorization requests.
+	// Nil rejects every signed request object.
+	RequestObjects *RequestObjectVerifier
+}
+
+// NewFederationDomainIssuer returns a FederationDomainIssuer for the given issuer with the given optional settings.
+func NewFederationDomainIssuer(issuer string, opts FederationDomainIssuerOptions) (*FederationDomainIssuer, error) {
+	p := FederationDomainIssuer{
+		issuer:               issuer,
+		tokenExchangePolicy:  opts.TokenExchangePolicy,
+		tokenLifetimes:       opts.TokenLifetimes,
+		introspectionClients: opts.IntrospectionClients,
+		logoutSettings:       opts.LogoutSettings,
+		requestObjects:       opts.RequestObjects,
+	}
 	err := p.validate()
 	if err != nil {
 		return nil, err
@@ -75,3 +111,23 @@ func (p *FederationDomainIssuer) IssuerHost() string {
 func (p *FederationDomainIssuer) IssuerPath() string {
 	return p.issuerPath
 }
+
+func (p *FederationDomainIssuer) TokenExchangePolicy() *TokenExchangePolicy {
+	return p.tokenExchangePolicy
+}
+
+func (p *FederationDomainIssuer) TokenLifetimes() *TokenLifetimes {
+	return p.tokenLifetimes
+}
+
+func (p *FederationDomainIssuer) IntrospectionClients() *IntrospectionClients {
+	return p.introspectionClients
+}
+
+func (p *FederationDomainIssuer) LogoutSettings() *LogoutSettings {
+	return p.logoutSettings
+}
+
+func (p *FederationDomainIssuer) RequestObjectVerifier() *RequestObjectVerifier {
+	return p.requestObjects
+}
@@ -72,7 +72,7 @@ func TestFederationDomainIssuerValidations(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := NewFederationDomainIssuer(tt.issuer)
+			_, err := NewFederationDomainIssuer(tt.issuer, FederationDomainIssuerOptions{})
 			if tt.wantError != "" {
 				require.EqualError(t, err, tt.wantError)
 			} else {
@@ -0,0 +1,57 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"crypto/subtle"
+	"fmt"
+
+	"go.pinniped.dev/internal/constable"
+)
+
+// IntrospectionClient is a resource server which may call the RFC 7662 token introspection endpoint.
+type IntrospectionClient struct {
+	// ID is the client ID with which the resource server authenticates.
+	ID string
+
+	// Secret is the client secret with which the resource server authenticates.
+	Secret string
+}
+
+// IntrospectionClients decides which resource servers may call the RFC 7662 token introspection endpoint.
+// A nil IntrospectionClients denies every request.
+type IntrospectionClients struct {
+	// secretsByID maps the ID of each client to its secret.
+	secretsByID map[string]string
+}
+
+// NewIntrospectionClients returns an IntrospectionClients which allows only the given clients.
+func NewIntrospectionClients(clients []IntrospectionClient) (*IntrospectionClients, error) {
+	c := &IntrospectionClients{secretsByID: make(map[string]string, len(clients))}
+	for _, client := range clients {
+		if client.ID == "" {
+			return nil, constable.Error("introspection client must have an ID")
+		}
+		if client.Secret == "" {
+			return nil, fmt.Errorf("introspection client %q must have a secret", client.ID)
+		}
+		if _, ok := c.secretsByID[client.ID]; ok {
+			return nil, fmt.Errorf("introspection client %q is listed more than once", client.ID)
+		}
+		c.secretsByID[client.ID] = client.Secret
+	}
+	return c, nil
+}
+
+// Authenticate returns true when the given credentials belong to one of the clients.
+func (c *IntrospectionClients) Authenticate(id, secret string) bool {
+	if c == nil {
+		return false
+	}
+	wantSecret, ok := c.secretsByID[id]
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(wantSecret), []byte(secret)) == 1
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntrospectionClients(t *testing.T) {
+	clients, err := NewIntrospectionClients([]IntrospectionClient{
+		{ID: "some-gateway", Secret: "some-gateway-secret"},
+		{ID: "other-gateway", Secret: "other-gateway-secret"},
+	})
+	require.NoError(t, err)
+
+	var nilClients *IntrospectionClients
+
+	tests := []struct {
+		name    string
+		clients *IntrospectionClients
+		id      string
+		secret  string
+		want    bool
+	}{
+		{
+			name:    "nil clients deny every client",
+			clients: nilClients,
+			id:      "some-gateway",
+			secret:  "some-gateway-secret",
+			want:    false,
+		},
+		{
+			name:    "client with the right secret is allowed",
+			clients: clients,
+			id:      "other-gateway",
+			secret:  "other-gateway-secret",
+			want:    true,
+		},
+		{
+			name:    "client with the wrong secret is denied",
+			clients: clients,
+			id:      "some-gateway",
+			secret:  "other-gateway-secret",
+			want:    false,
+		},
+		{
+			name:    "client with an empty secret is denied",
+			clients: clients,
+			id:      "some-gateway",
+			secret:  "",
+			want:    false,
+		},
+		{
+			name:    "client which is not listed is denied",
+			clients: clients,
+			id:      "unknown-gateway",
+			secret:  "some-gateway-secret",
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.clients.Authenticate(tt.id, tt.secret))
+		})
+	}
+}
+
+func TestNewIntrospectionClientsValidations(t *testing.T) {
+	_, err := NewIntrospectionClients([]IntrospectionClient{{ID: "", Secret: "some-secret"}})
+	require.EqualError(t, err, "introspection client must have an ID")
+
+	_, err = NewIntrospectionClients([]IntrospectionClient{{ID: "some-gateway", Secret: ""}})
+	require.EqualError(t, err, `introspection client "some-gateway" must have a secret`)
+
+	_, err = NewIntrospectionClients([]IntrospectionClient{{ID: "some-gateway", Secret: "a"}, {ID: "some-gateway", Secret: "b"}})
+	require.EqualError(t, err, `introspection client "some-gateway" is listed more than once`)
+}
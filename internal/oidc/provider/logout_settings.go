@@ -0,0 +1,127 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"fmt"
+	"net/url"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"go.pinniped.dev/internal/constable"
+)
+
+// BackChannelLogoutClient is an application which is sent a logout token when a user logs out.
+type BackChannelLogoutClient struct {
+	// ID is the client ID of the application, which is the only audience of its logout tokens.
+	ID string
+
+	// URI is the back-channel logout URI of the application.
+	URI string
+}
+
+// LogoutSettings configures the OIDC RP-initiated logout endpoint of a FederationDomain.
+// A nil LogoutSettings allows only the redirect URIs of the client after a logout, and notifies no other applications.
+type LogoutSettings struct {
+	// postLogoutRedirectURIs are the URIs, in addition to the redirect URIs of the client, to which the browser may
+	// be redirected after a logout.
+	postLogoutRedirectURIs sets.String
+
+	// frontChannelLogoutURIs are loaded in hidden iframes of the logout page.
+	frontChannelLogoutURIs []string
+
+	// backChannelLogoutClients are each sent their own logout token.
+	backChannelLogoutClients []BackChannelLogoutClient
+
+	// upstreamLogout is true when the browser should be redirected to the end_session_endpoint of the upstream
+	// OIDC identity provider after a logout.
+	upstreamLogout bool
+}
+
+// NewLogoutSettings returns LogoutSettings after validating that each of the given URIs is an absolute http or
+// https URL, and that each back-channel logout client has a unique ID.
+func NewLogoutSettings(
+	postLogoutRedirectURIs []string,
+	frontChannelLogoutURIs []string,
+	backChannelLogoutClients []BackChannelLogoutClient,
+	upstreamLogout bool,
+) (*LogoutSettings, error) {
+	for _, uris := range []struct {
+		name string
+		uris []string
+	}{
+		{name: "postLogoutRedirectURIs", uris: postLogoutRedirectURIs},
+		{name: "frontChannelLogoutURIs", uris: frontChannelLogoutURIs},
+	} {
+		for i, uri := range uris.uris {
+			if err := validateLogoutURI(uri); err != nil {
+				return nil, fmt.Errorf("%s[%d] %q is invalid: %w", uris.name, i, uri, err)
+			}
+		}
+	}
+	clientIDs := sets.NewString()
+	for i, client := range backChannelLogoutClients {
+		if client.ID == "" {
+			return nil, fmt.Errorf("backChannelLogoutClients[%d] must have a client ID", i)
+		}
+		if clientIDs.Has(client.ID) {
+			return nil, fmt.Errorf("backChannelLogoutClients[%d] client %q is listed more than once", i, client.ID)
+		}
+		clientIDs.Insert(client.ID)
+		if err := validateLogoutURI(client.URI); err != nil {
+			return nil, fmt.Errorf("backChannelLogoutClients[%d] URI %q is invalid: %w", i, client.URI, err)
+		}
+	}
+	return &LogoutSettings{
+		postLogoutRedirectURIs:   sets.NewString(postLogoutRedirectURIs...),
+		frontChannelLogoutURIs:   frontChannelLogoutURIs,
+		backChannelLogoutClients: backChannelLogoutClients,
+		upstreamLogout:           upstreamLogout,
+	}, nil
+}
+
+func validateLogoutURI(uri string) error {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return constable.Error("must be an http or https URL")
+	}
+	if parsed.Fragment != "" {
+		return constable.Error("must not have a fragment")
+	}
+	return nil
+}
+
+// AllowsPostLogoutRedirectURI returns true when the browser may be redirected to the given URI after a logout,
+// without considering the redirect URIs of the client.
+func (s *LogoutSettings) AllowsPostLogoutRedirectURI(uri string) bool {
+	if s == nil {
+		return false
+	}
+	return s.postLogoutRedirectURIs.Has(uri)
+}
+
+// FrontChannelLogoutURIs returns the URIs which are loaded in hidden iframes of the logout page.
+func (s *LogoutSettings) FrontChannelLogoutURIs() []string {
+	if s == nil {
+		return nil
+	}
+	return s.frontChannelLogoutURIs
+}
+
+// BackChannelLogoutClients returns the applications which are each sent their own logout token.
+func (s *LogoutSettings) BackChannelLogoutClients() []BackChannelLogoutClient {
+	if s == nil {
+		return nil
+	}
+	return s.backChannelLogoutClients
+}
+
+// UpstreamLogout returns true when the browser should be redirected to the end_session_endpoint of the upstream
+// OIDC identity provider after a logout.
+func (s *LogoutSettings) UpstreamLogout() bool {
+	return s != nil && s.upstreamLogout
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogoutSettings(t *testing.T) {
+	settings, err := NewLogoutSettings(
+		[]string{"https://app.example.com/logged-out"},
+		[]string{"https://app.example.com/frontchannel-logout"},
+		[]BackChannelLogoutClient{{ID: "some-app", URI: "https://app.example.com/backchannel-logout"}},
+		true,
+	)
+	require.NoError(t, err)
+
+	require.True(t, settings.AllowsPostLogoutRedirectURI("https://app.example.com/logged-out"))
+	require.False(t, settings.AllowsPostLogoutRedirectURI("https://app.example.com/other"))
+	require.Equal(t, []string{"https://app.example.com/frontchannel-logout"}, settings.FrontChannelLogoutURIs())
+	require.Equal(t, []BackChannelLogoutClient{{ID: "some-app", URI: "https://app.example.com/backchannel-logout"}}, settings.BackChannelLogoutClients())
+	require.True(t, settings.UpstreamLogout())
+
+	var nilSettings *LogoutSettings
+	require.False(t, nilSettings.AllowsPostLogoutRedirectURI("https://app.example.com/logged-out"))
+	require.Nil(t, nilSettings.FrontChannelLogoutURIs())
+	require.Nil(t, nilSettings.BackChannelLogoutClients())
+	require.False(t, nilSettings.UpstreamLogout())
+}
+
+func TestNewLogoutSettingsValidations(t *testing.T) {
+	_, err := NewLogoutSettings([]string{"https://app.example.com", "ftp://app.example.com"}, nil, nil, false)
+	require.EqualError(t, err, `postLogoutRedirectURIs[1] "ftp://app.example.com" is invalid: must be an http or https URL`)
+
+	_, err = NewLogoutSettings(nil, []string{"/relative/path"}, nil, false)
+	require.EqualError(t, err, `frontChannelLogoutURIs[0] "/relative/path" is invalid: must be an http or https URL`)
+
+	_, err = NewLogoutSettings(nil, nil, []BackChannelLogoutClient{{ID: "some-app", URI: "https://app.example.com/logout#fragment"}}, false)
+	require.EqualError(t, err, `backChannelLogoutClients[0] URI "https://app.example.com/logout#fragment" is invalid: must not have a fragment`)
+
+	_, err = NewLogoutSettings(nil, nil, []BackChannelLogoutClient{{ID: "some-app", URI: "https://app.example.com/%zz"}}, false)
+	require.EqualError(t, err, `backChannelLogoutClients[0] URI "https://app.example.com/%zz" is invalid: parse "https://app.example.com/%zz": invalid URL escape "%zz"`)
+
+	_, err = NewLogoutSettings(nil, nil, []BackChannelLogoutClient{{URI: "https://app.example.com/logout"}}, false)
+	require.EqualError(t, err, `backChannelLogoutClients[0] must have a client ID`)
+
+	_, err = NewLogoutSettings(nil, nil, []BackChannelLogoutClient{
+		{ID: "some-app", URI: "https://app.example.com/logout"},
+		{ID: "some-app", URI: "https://other-app.example.com/logout"},
+	}, false)
+	require.EqualError(t, err, `backChannelLogoutClients[1] client "some-app" is listed more than once`)
+}
@@ -17,10 +17,15 @@ import (
 	"go.pinniped.dev/internal/oidc/discovery"
 	"go.pinniped.dev/internal/oidc/dynamiccodec"
 	"go.pinniped.dev/internal/oidc/idpdiscovery"
+	"go.pinniped.dev/internal/oidc/introspection"
 	"go.pinniped.dev/internal/oidc/jwks"
 	"go.pinniped.dev/internal/oidc/login"
+	"go.pinniped.dev/internal/oidc/logout"
+	"go.pinniped.dev/internal/oidc/par"
 	"go.pinniped.dev/internal/oidc/provider"
 	"go.pinniped.dev/internal/oidc/token"
+	"go.pinniped.dev/internal/oidc/userinfo"
+	"go.pinniped.dev/internal/net/phttp"
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/internal/secret"
 	"go.pinniped.dev/pkg/oidcclient/nonce"
@@ -39,6 +44,7 @@ type Manager struct {
 	upstreamIDPs        oidc.UpstreamIdentityProvidersLister // in-memory cache of upstream IDPs
 	secretCache         *secret.Cache                        // in-memory cache of cryptographic material
 	secretsClient       corev1client.SecretInterface
+	backChannelClient   *http.Client // used to send logout tokens to back-channel logout URIs
 }
 
 // NewManager returns an empty Manager.
@@ -59,6 +65,7 @@ func NewManager(
 		upstreamIDPs:        upstreamIDPs,
 		secretCache:         secretCache,
 		secretsClient:       secretsClient,
+		backChannelClient:   phttp.Default(nil),
 	}
 }
 
@@ -89,14 +96,15 @@ func (m *Manager) SetProviders(federationDomains ...*provider.FederationDomainIs
 
 		tokenHMACKeyGetter := wrapGetter(incomingProvider.Issuer(), m.secretCache.GetTokenHMACKey)
 
-		timeoutsConfiguration := oidc.DefaultOIDCTimeoutsConfiguration()
+		timeoutsConfiguration := oidc.OIDCTimeoutsConfiguration(incomingProvider.TokenLifetimes())
 
 		// Use NullStorage for the authorize endpoint because we do not actually want to store anything until
 		// the upstream callback endpoint is called later.
-		oauthHelperWithNullStorage := oidc.FositeOauth2Helper(oidc.NullStorage{}, issuer, tokenHMACKeyGetter, nil, timeoutsConfiguration)
+		oauthHelperWithNullStorage := oidc.FositeOauth2Helper(oidc.NullStorage{}, issuer, tokenHMACKeyGetter, nil, timeoutsConfiguration, nil)
 
 		// For all the other endpoints, make another oauth helper with exactly the same settings except use real storage.
-		oauthHelperWithKubeStorage := oidc.FositeOauth2Helper(oidc.NewKubeStorage(m.secretsClient, timeoutsConfiguration), issuer, tokenHMACKeyGetter, m.dynamicJWKSProvider, timeoutsConfiguration)
+		kubeStorage := oidc.NewKubeStorage(m.secretsClient, timeoutsConfiguration)
+		oauthHelperWithKubeStorage := oidc.FositeOauth2Helper(kubeStorage, issuer, tokenHMACKeyGetter, m.dynamicJWKSProvider, timeoutsConfiguration, incomingProvider.TokenExchangePolicy())
 
 		var upstreamStateEncoder = dynamiccodec.New(
 			timeoutsConfiguration.UpstreamStateParamLifespan,
@@ -104,7 +112,7 @@ func (m *Manager) SetProviders(federationDomains ...*provider.FederationDomainIs
 			wrapGetter(incomingProvider.Issuer(), m.secretCache.GetStateEncoderBlockKey),
 		)
 
-		m.providerHandlers[(issuerHostWithPath + oidc.WellKnownEndpointPath)] = discovery.NewHandler(issuer)
+		m.providerHandlers[(issuerHostWithPath + oidc.WellKnownEndpointPath)] = discovery.NewHandler(issuer, m.dynamicJWKSProvider, incomingProvider.RequestObjectVerifier())
 
 		m.providerHandlers[(issuerHostWithPath + oidc.JWKSEndpointPath)] = jwks.NewHandler(issuer, m.dynamicJWKSProvider)
 
@@ -120,6 +128,16 @@ func (m *Manager) SetProviders(federationDomains ...*provider.FederationDomainIs
 			nonce.Generate,
 			upstreamStateEncoder,
 			csrfCookieEncoder,
+			kubeStorage,
+			incomingProvider.RequestObjectVerifier(),
+		)
+
+		m.providerHandlers[(issuerHostWithPath + oidc.PushedAuthorizationRequestEndpointPath)] = par.NewHandler(
+			issuer,
+			oauthHelperWithNullStorage,
+			kubeStorage,
+			incomingProvider.RequestObjectVerifier(),
+			timeoutsConfiguration.PushedAuthorizeRequestLifespan,
 		)
 
 		m.providerHandlers[(issuerHostWithPath + oidc.CallbackEndpointPath)] = callback.NewHandler(
@@ -133,12 +151,30 @@ func (m *Manager) SetProviders(federationDomains ...*provider.FederationDomainIs
 		m.providerHandlers[(issuerHostWithPath + oidc.TokenEndpointPath)] = token.NewHandler(
 			m.upstreamIDPs,
 			oauthHelperWithKubeStorage,
+			timeoutsConfiguration.MaxSessionLifespan,
+		)
+
+		m.providerHandlers[(issuerHostWithPath + oidc.UserInfoEndpointPath)] = userinfo.NewHandler(oauthHelperWithKubeStorage)
+
+		m.providerHandlers[(issuerHostWithPath + oidc.IntrospectionEndpointPath)] = introspection.NewHandler(
+			issuer,
+			oauthHelperWithKubeStorage,
+			incomingProvider.IntrospectionClients(),
+		)
+
+		m.providerHandlers[(issuerHostWithPath + oidc.EndSessionEndpointPath)] = logout.NewHandler(
+			issuer,
+			m.dynamicJWKSProvider,
+			kubeStorage,
+			m.upstreamIDPs,
+			incomingProvider.LogoutSettings(),
+			m.backChannelClient,
 		)
 
 		m.providerHandlers[(issuerHostWithPath + oidc.PinnipedLoginPath)] = login.NewHandler(
 			upstreamStateEncoder,
 			csrfCookieEncoder,
-			login.NewGetHandler(incomingProvider.IssuerPath()+oidc.PinnipedLoginPath),
+			login.NewGetHandler(issuer, incomingProvider.IssuerPath()+oidc.PinnipedLoginPath, m.upstreamIDPs, oauthHelperWithKubeStorage),
 			login.NewPostHandler(issuer, m.upstreamIDPs, oauthHelperWithKubeStorage),
 		)
 
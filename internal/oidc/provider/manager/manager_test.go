@@ -171,7 +171,7 @@ func TestManager(t *testing.T) {
 			r.NoError(err)
 			actualLocationQueryParams := parsedLocation.Query()
 			r.Contains(actualLocationQueryParams, "code")
-			r.Equal("openid", actualLocationQueryParams.Get("scope"))
+			r.Equal("openid profile email", actualLocationQueryParams.Get("scope"))
 			r.Equal("some-state-value-with-enough-bytes-to-exceed-min-allowed", actualLocationQueryParams.Get("state"))
 
 			// Make sure that we wired up the callback endpoint to use kube storage for fosite sessions.
@@ -182,7 +182,7 @@ func TestManager(t *testing.T) {
 			return actualLocationQueryParams.Get("code")
 		}
 
-		requireTokenRequestToBeHandled := func(requestIssuer, authCode string, jwks *jose.JSONWebKeySet, jwkIssuer string) {
+		requireTokenRequestToBeHandled := func(requestIssuer, authCode string, jwks *jose.JSONWebKeySet, jwkIssuer string) string {
 			recorder := httptest.NewRecorder()
 
 			numberOfKubeActionsBeforeThisRequest := len(kubeClient.Actions())
@@ -219,6 +219,61 @@ func TestManager(t *testing.T) {
 			// Make sure that we wired up the callback endpoint to use kube storage for fosite sessions.
 			r.Equal(len(kubeClient.Actions()), numberOfKubeActionsBeforeThisRequest+8,
 				"did not perform any kube actions during the callback request, but should have")
+
+			// Return the access token so we can use it in our next request to the userinfo endpoint.
+			accessToken, ok := body["access_token"].(string)
+			r.True(ok, "wanted access_token type to be string, but was %T", body["access_token"])
+			return accessToken
+		}
+
+		requireUserInfoRequestToBeHandled := func(requestIssuer, accessToken string, wantStatus int) {
+			recorder := httptest.NewRecorder()
+
+			numberOfKubeActionsBeforeThisRequest := len(kubeClient.Actions())
+
+			getRequest := newGetRequest(requestIssuer + oidc.UserInfoEndpointPath)
+			getRequest.Header.Set("Authorization", "Bearer "+accessToken)
+			subject.ServeHTTP(recorder, getRequest)
+
+			r.False(fallbackHandlerWasCalled)
+
+			// Minimal check to ensure that the right endpoint was called
+			r.Equal(wantStatus, recorder.Code)
+			if wantStatus != http.StatusOK {
+				return
+			}
+			var body map[string]interface{}
+			r.NoError(json.Unmarshal(recorder.Body.Bytes(), &body))
+			r.Equal("https://some-issuer.com?sub=some-subject", body["username"])
+			r.Contains(body, "sub")
+
+			// Make sure that we wired up the userinfo endpoint to use kube storage for fosite sessions.
+			r.Equal(len(kubeClient.Actions()), numberOfKubeActionsBeforeThisRequest+1,
+				"did not perform any kube actions during the userinfo request, but should have")
+		}
+
+		requireLogoutRequestToBeHandled := func(requestIssuer string) {
+			recorder := httptest.NewRecorder()
+
+			subject.ServeHTTP(recorder, newGetRequest(requestIssuer+oidc.EndSessionEndpointPath))
+
+			r.False(fallbackHandlerWasCalled)
+
+			// Minimal check to ensure that the right endpoint was called
+			r.Equal(http.StatusBadRequest, recorder.Code)
+			r.Equal("Bad Request: id_token_hint parameter is required\n", recorder.Body.String())
+		}
+
+		requirePushedAuthorizationRequestToBeHandled := func(requestIssuer string) {
+			recorder := httptest.NewRecorder()
+
+			subject.ServeHTTP(recorder, newGetRequest(requestIssuer+oidc.PushedAuthorizationRequestEndpointPath))
+
+			r.False(fallbackHandlerWasCalled)
+
+			// Minimal check to ensure that the right endpoint was called
+			r.Equal(http.StatusMethodNotAllowed, recorder.Code)
+			r.Equal("Method not allowed (try POST)\n", recorder.Body.String())
 		}
 
 		requireJWKSRequestToBeHandled := func(requestIssuer, requestURLSuffix, expectedJWKKeyID string) *jose.JSONWebKeySet {
@@ -376,19 +431,32 @@ func TestManager(t *testing.T) {
 			downstreamAuthCode3 := requireCallbackRequestToBeHandled(issuer1DifferentCaseHostname, callbackRequestParams1, csrfCookieValue1)
 			downstreamAuthCode4 := requireCallbackRequestToBeHandled(issuer2DifferentCaseHostname, callbackRequestParams2, csrfCookieValue2)
 
-			requireTokenRequestToBeHandled(issuer1, downstreamAuthCode1, issuer1JWKS, issuer1)
-			requireTokenRequestToBeHandled(issuer2, downstreamAuthCode2, issuer2JWKS, issuer2)
+			accessToken1 := requireTokenRequestToBeHandled(issuer1, downstreamAuthCode1, issuer1JWKS, issuer1)
+			accessToken2 := requireTokenRequestToBeHandled(issuer2, downstreamAuthCode2, issuer2JWKS, issuer2)
 
 			// Hostnames are case-insensitive, so test that we can handle that.
 			requireTokenRequestToBeHandled(issuer1DifferentCaseHostname, downstreamAuthCode3, issuer1JWKS, issuer1)
 			requireTokenRequestToBeHandled(issuer2DifferentCaseHostname, downstreamAuthCode4, issuer2JWKS, issuer2)
+
+			requireUserInfoRequestToBeHandled(issuer1, accessToken1, http.StatusOK)
+			requireUserInfoRequestToBeHandled(issuer2, accessToken2, http.StatusOK)
+			requireUserInfoRequestToBeHandled(issuer1DifferentCaseHostname, accessToken1, http.StatusOK)
+
+			// Access tokens are only valid for the issuer which issued them.
+			requireUserInfoRequestToBeHandled(issuer2, accessToken1, http.StatusUnauthorized)
+
+			requireLogoutRequestToBeHandled(issuer1)
+			requireLogoutRequestToBeHandled(issuer2DifferentCaseHostname)
+
+			requirePushedAuthorizationRequestToBeHandled(issuer1)
+			requirePushedAuthorizationRequestToBeHandled(issuer2DifferentCaseHostname)
 		}
 
 		when("given some valid providers via SetProviders()", func() {
 			it.Before(func() {
-				p1, err := provider.NewFederationDomainIssuer(issuer1)
+				p1, err := provider.NewFederationDomainIssuer(issuer1, provider.FederationDomainIssuerOptions{})
 				r.NoError(err)
-				p2, err := provider.NewFederationDomainIssuer(issuer2)
+				p2, err := provider.NewFederationDomainIssuer(issuer2, provider.FederationDomainIssuerOptions{})
 				r.NoError(err)
 				subject.SetProviders(p1, p2)
 
@@ -429,9 +497,9 @@ func TestManager(t *testing.T) {
 
 		when("given the same valid providers as arguments to SetProviders() in reverse order", func() {
 			it.Before(func() {
-				p1, err := provider.NewFederationDomainIssuer(issuer1)
+				p1, err := provider.NewFederationDomainIssuer(issuer1, provider.FederationDomainIssuerOptions{})
 				r.NoError(err)
-				p2, err := provider.NewFederationDomainIssuer(issuer2)
+				p2, err := provider.NewFederationDomainIssuer(issuer2, provider.FederationDomainIssuerOptions{})
 				r.NoError(err)
 				subject.SetProviders(p2, p1)
 
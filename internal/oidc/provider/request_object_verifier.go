@@ -0,0 +1,180 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"go.pinniped.dev/internal/constable"
+)
+
+const (
+	ErrRequestObjectsNotSupported = constable.Error("request objects are not supported by this federation domain")
+	ErrRequestObjectNotVerified   = constable.Error("request object signature could not be verified")
+)
+
+// requestObjectSigningAlgorithms are the asymmetric algorithms which may sign a request object. Symmetric and
+// "none" algorithms are not allowed, since the keys of the clients are configured as a public JWKS.
+// nolint: gochecknoglobals
+var requestObjectSigningAlgorithms = sets.NewString(
+	string(jose.RS256), string(jose.RS384), string(jose.RS512),
+	string(jose.PS256), string(jose.PS384), string(jose.PS512),
+	string(jose.ES256), string(jose.ES384), string(jose.ES512),
+	string(jose.EdDSA),
+)
+
+// requestObjectRegisteredClaims are the claims of a request object which describe the request object itself,
+// rather than being parameters of the authorization request.
+// nolint: gochecknoglobals
+var requestObjectRegisteredClaims = sets.NewString("iss", "aud", "exp", "iat", "nbf", "jti")
+
+// RequestObjectClient is a client which may send signed request objects.
+type RequestObjectClient struct {
+	// ID is the client ID of the client, which must be the iss claim of its request objects.
+	ID string
+
+	// JWKS is a JSON Web Key Set document which contains the public keys of the client.
+	JWKS string
+}
+
+// RequestObjectVerifier verifies the signed request objects (JAR, RFC 9101) of a FederationDomain. Each request
+// object is only verified using the keys of the client which sent it, so that no client can send request objects
+// on behalf of another client. A nil RequestObjectVerifier rejects every request object.
+type RequestObjectVerifier struct {
+	keysByClientID map[string][]jose.JSONWebKey
+}
+
+// NewRequestObjectVerifier returns a RequestObjectVerifier after validating that each of the given clients has a
+// unique ID and a JWKS which contains at least one public key, and that every key names one of the allowed signing
+// algorithms.
+func NewRequestObjectVerifier(clients []RequestObjectClient) (*RequestObjectVerifier, error) {
+	if len(clients) == 0 {
+		return nil, constable.Error("clients must contain at least one client")
+	}
+	keysByClientID := make(map[string][]jose.JSONWebKey, len(clients))
+	for i, client := range clients {
+		if client.ID == "" {
+			return nil, fmt.Errorf("clients[%d] must have a client ID", i)
+		}
+		if _, ok := keysByClientID[client.ID]; ok {
+			return nil, fmt.Errorf("clients[%d] client %q is listed more than once", i, client.ID)
+		}
+		keys, err := parseRequestObjectJWKS(client.JWKS)
+		if err != nil {
+			return nil, fmt.Errorf("clients[%d] client %q has an invalid jwks: %w", i, client.ID, err)
+		}
+		keysByClientID[client.ID] = keys
+	}
+	return &RequestObjectVerifier{keysByClientID: keysByClientID}, nil
+}
+
+func parseRequestObjectJWKS(jwks string) ([]jose.JSONWebKey, error) {
+	var keySet jose.JSONWebKeySet
+	if err := json.Unmarshal([]byte(jwks), &keySet); err != nil {
+		return nil, fmt.Errorf("could not parse jwks: %w", err)
+	}
+	if len(keySet.Keys) == 0 {
+		return nil, constable.Error("jwks must contain at least one key")
+	}
+	for i, key := range keySet.Keys {
+		if !key.IsPublic() {
+			return nil, fmt.Errorf("jwks keys[%d] must be a public key", i)
+		}
+		if !requestObjectSigningAlgorithms.Has(key.Algorithm) {
+			return nil, fmt.Errorf("jwks keys[%d] has unsupported alg %q, must be one of %v", i, key.Algorithm, requestObjectSigningAlgorithms.List())
+		}
+	}
+	return keySet.Keys, nil
+}
+
+// SigningAlgorithms returns the sorted algorithms which may sign a request object, for the discovery document.
+func (v *RequestObjectVerifier) SigningAlgorithms() []string {
+	if v == nil {
+		return nil
+	}
+	algorithms := sets.NewString()
+	for _, keys := range v.keysByClientID {
+		for _, key := range keys {
+			algorithms.Insert(key.Algorithm)
+		}
+	}
+	return algorithms.List()
+}
+
+// Verify checks the signature and the claims of the given request object, which must have been signed by a key of
+// the given client for the given issuer, and returns the authorization request parameters which it contains. A
+// request object of a client which has no keys is never verified.
+func (v *RequestObjectVerifier) Verify(requestObject, issuer, clientID string, now time.Time) (url.Values, error) {
+	if v == nil {
+		return nil, ErrRequestObjectsNotSupported
+	}
+
+	token, err := josejwt.ParseSigned(requestObject)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse request object: %w", err)
+	}
+	if len(token.Headers) != 1 {
+		return nil, constable.Error("request object must have exactly one signature")
+	}
+	header := token.Headers[0]
+
+	var registered josejwt.Claims
+	var claims map[string]interface{}
+	verified := false
+	keys := v.keysByClientID[clientID]
+	for i := range keys {
+		key := &keys[i]
+		if key.Algorithm != header.Algorithm || (header.KeyID != "" && key.KeyID != header.KeyID) {
+			continue
+		}
+		if err := token.Claims(key, &registered, &claims); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrRequestObjectNotVerified
+	}
+
+	if registered.Expiry == nil {
+		return nil, constable.Error("request object must have an exp claim")
+	}
+	if err := registered.ValidateWithLeeway(josejwt.Expected{
+		Issuer:   clientID,
+		Audience: josejwt.Audience{issuer},
+		Time:     now,
+	}, 0); err != nil {
+		return nil, fmt.Errorf("request object has invalid claims: %w", err)
+	}
+	if claimedClientID, _ := claims["client_id"].(string); claimedClientID != clientID {
+		return nil, constable.Error("request object client_id claim must match the client_id parameter")
+	}
+
+	params := url.Values{}
+	for name, value := range claims {
+		if requestObjectRegisteredClaims.Has(name) {
+			continue
+		}
+		if name == "request" || name == "request_uri" {
+			return nil, fmt.Errorf("request object must not contain a %s claim", name)
+		}
+		if s, ok := value.(string); ok {
+			params.Set(name, s)
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode request object claim %s: %w", name, err)
+		}
+		params.Set(name, string(encoded))
+	}
+	return params, nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestRequestObjectVerifier(t *testing.T) {
+	const (
+		issuer   = "https://issuer.example.com/some/path"
+		clientID = "pinniped-cli"
+	)
+	now := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &key.PublicKey, KeyID: "client-key", Algorithm: string(jose.ES256), Use: "sig"},
+	}})
+	require.NoError(t, err)
+	// The other client uses the same key ID, so that only the client ID decides which keys are tried.
+	otherJWKS, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &otherKey.PublicKey, KeyID: "client-key", Algorithm: string(jose.ES256), Use: "sig"},
+	}})
+	require.NoError(t, err)
+	verifier, err := NewRequestObjectVerifier([]RequestObjectClient{
+		{ID: clientID, JWKS: string(jwks)},
+		{ID: "other-client", JWKS: string(otherJWKS)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"ES256"}, verifier.SigningAlgorithms())
+
+	sign := func(t *testing.T, signingKey *ecdsa.PrivateKey, claims map[string]interface{}) string {
+		t.Helper()
+		signer, err := jose.NewSigner(
+			jose.SigningKey{Algorithm: jose.ES256, Key: signingKey},
+			(&jose.SignerOptions{}).WithHeader("kid", "client-key"),
+		)
+		require.NoError(t, err)
+		token, err := josejwt.Signed(signer).Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":           clientID,
+			"aud":           issuer,
+			"exp":           now.Add(time.Minute).Unix(),
+			"iat":           now.Unix(),
+			"client_id":     clientID,
+			"response_type": "code",
+			"scope":         "openid offline_access",
+			"max_age":       300,
+		}
+	}
+
+	params, err := verifier.Verify(sign(t, key, validClaims()), issuer, clientID, now)
+	require.NoError(t, err)
+	require.Equal(t, url.Values{
+		"client_id":     []string{clientID},
+		"response_type": []string{"code"},
+		"scope":         []string{"openid offline_access"},
+		"max_age":       []string{"300"},
+	}, params)
+
+	tests := []struct {
+		name       string
+		signingKey *ecdsa.PrivateKey
+		editClaims func(map[string]interface{})
+		clientID   string
+		wantErr    string
+	}{
+		{
+			name:       "signed by an unknown key",
+			signingKey: otherKey,
+			wantErr:    "request object signature could not be verified",
+		},
+		{
+			name:       "missing exp",
+			editClaims: func(c map[string]interface{}) { delete(c, "exp") },
+			wantErr:    "request object must have an exp claim",
+		},
+		{
+			name:       "expired",
+			editClaims: func(c map[string]interface{}) { c["exp"] = now.Add(-time.Second).Unix() },
+			wantErr:    "request object has invalid claims: square/go-jose/jwt: validation failed, token is expired (exp)",
+		},
+		{
+			name:       "wrong audience",
+			editClaims: func(c map[string]interface{}) { c["aud"] = "https://other.example.com" },
+			wantErr:    "request object has invalid claims: square/go-jose/jwt: validation failed, invalid audience claim (aud)",
+		},
+		{
+			name:       "signed by the key of another client",
+			signingKey: otherKey,
+			wantErr:    "request object signature could not be verified",
+		},
+		{
+			name:     "sent by another client",
+			clientID: "other-client",
+			wantErr:  "request object signature could not be verified",
+		},
+		{
+			name:       "issued by another client",
+			signingKey: otherKey,
+			editClaims: func(c map[string]interface{}) { c["iss"] = clientID },
+			clientID:   "other-client",
+			wantErr:    "request object has invalid claims: square/go-jose/jwt: validation failed, invalid issuer claim (iss)",
+		},
+		{
+			name:     "sent by a client which has no keys",
+			clientID: "unknown-client",
+			wantErr:  "request object signature could not be verified",
+		},
+		{
+			name:       "client_id claim does not match",
+			editClaims: func(c map[string]interface{}) { c["client_id"] = "other-client" },
+			wantErr:    "request object client_id claim must match the client_id parameter",
+		},
+		{
+			name:       "nested request_uri",
+			editClaims: func(c map[string]interface{}) { c["request_uri"] = "urn:example" },
+			wantErr:    "request object must not contain a request_uri claim",
+		},
+	}
+	for _, test := range tests {
+		tt := test
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			if tt.editClaims != nil {
+				tt.editClaims(claims)
+			}
+			signingKey := key
+			if tt.signingKey != nil {
+				signingKey = tt.signingKey
+			}
+			wantClientID := clientID
+			if tt.clientID != "" {
+				wantClientID = tt.clientID
+			}
+			_, err := verifier.Verify(sign(t, signingKey, claims), issuer, wantClientID, now)
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+
+	var nilVerifier *RequestObjectVerifier
+	_, err = nilVerifier.Verify(sign(t, key, validClaims()), issuer, clientID, now)
+	require.EqualError(t, err, "request objects are not supported by this federation domain")
+	require.Nil(t, nilVerifier.SigningAlgorithms())
+}
+
+func TestNewRequestObjectVerifierValidations(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = NewRequestObjectVerifier(nil)
+	require.EqualError(t, err, "clients must contain at least one client")
+
+	_, err = NewRequestObjectVerifier([]RequestObjectClient{{JWKS: "not json"}})
+	require.EqualError(t, err, "clients[0] must have a client ID")
+
+	withJWKS := func(jwks string) []RequestObjectClient {
+		return []RequestObjectClient{{ID: "some-client", JWKS: jwks}}
+	}
+
+	_, err = NewRequestObjectVerifier(withJWKS("not json"))
+	require.EqualError(t, err, `clients[0] client "some-client" has an invalid jwks: could not parse jwks: invalid character 'o' in literal null (expecting 'u')`)
+
+	_, err = NewRequestObjectVerifier(withJWKS(`{"keys":[]}`))
+	require.EqualError(t, err, `clients[0] client "some-client" has an invalid jwks: jwks must contain at least one key`)
+
+	private, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: key, Algorithm: string(jose.ES256)}}})
+	require.NoError(t, err)
+	_, err = NewRequestObjectVerifier(withJWKS(string(private)))
+	require.EqualError(t, err, `clients[0] client "some-client" has an invalid jwks: jwks keys[0] must be a public key`)
+
+	noAlg, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey}}})
+	require.NoError(t, err)
+	_, err = NewRequestObjectVerifier(withJWKS(string(noAlg)))
+	require.EqualError(t, err, `clients[0] client "some-client" has an invalid jwks: jwks keys[0] has unsupported alg "", must be one of [ES256 ES384 ES512 EdDSA PS256 PS384 PS512 RS256 RS384 RS512]`)
+
+	public, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, Algorithm: string(jose.ES256)}}})
+	require.NoError(t, err)
+	_, err = NewRequestObjectVerifier(append(withJWKS(string(public)), RequestObjectClient{ID: "some-client", JWKS: string(public)}))
+	require.EqualError(t, err, `clients[1] client "some-client" is listed more than once`)
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"go.pinniped.dev/internal/constable"
+)
+
+// AllowedAudience is an audience which may be requested using the RFC 8693 token exchange.
+type AllowedAudience struct {
+	// Name is the audience.
+	Name string
+
+	// Groups are the downstream groups which may request the audience. A user must belong to at least one of them.
+	// Empty means that any user may request the audience.
+	Groups []string
+}
+
+// TokenExchangePolicy decides which audiences may be requested by which users using the RFC 8693 token exchange.
+// A nil TokenExchangePolicy allows any user to request any audience.
+type TokenExchangePolicy struct {
+	// allowedAudiences maps each allowed audience to the groups which may request it, where nil means any user.
+	allowedAudiences map[string]sets.String
+}
+
+// NewTokenExchangePolicy returns a TokenExchangePolicy which allows only the given audiences. When the list is
+// empty, then no audience may be requested.
+func NewTokenExchangePolicy(allowedAudiences []AllowedAudience) (*TokenExchangePolicy, error) {
+	p := &TokenExchangePolicy{allowedAudiences: make(map[string]sets.String, len(allowedAudiences))}
+	for _, allowedAudience := range allowedAudiences {
+		if allowedAudience.Name == "" {
+			return nil, constable.Error("token exchange allowed audience must have a name")
+		}
+		if _, ok := p.allowedAudiences[allowedAudience.Name]; ok {
+			return nil, fmt.Errorf("token exchange allowed audience %q is listed more than once", allowedAudience.Name)
+		}
+		var groups sets.String
+		if len(allowedAudience.Groups) > 0 {
+			groups = sets.NewString(allowedAudience.Groups...)
+		}
+		p.allowedAudiences[allowedAudience.Name] = groups
+	}
+	return p, nil
+}
+
+// AllowsAudience returns true when a user who belongs to the given downstream groups may request the audience.
+func (p *TokenExchangePolicy) AllowsAudience(audience string, groups []string) bool {
+	if p == nil {
+		return true
+	}
+	allowedGroups, ok := p.allowedAudiences[audience]
+	if !ok {
+		return false
+	}
+	return allowedGroups == nil || allowedGroups.HasAny(groups...)
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenExchangePolicy(t *testing.T) {
+	policy, err := NewTokenExchangePolicy([]AllowedAudience{
+		{Name: "cluster-for-anyone"},
+		{Name: "cluster-for-tenant-a", Groups: []string{"tenant-a-admins", "tenant-a-devs"}},
+	})
+	require.NoError(t, err)
+
+	var nilPolicy *TokenExchangePolicy
+	emptyPolicy, err := NewTokenExchangePolicy(nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		policy   *TokenExchangePolicy
+		audience string
+		groups   []string
+		want     bool
+	}{
+		{
+			name:     "nil policy allows any audience",
+			policy:   nilPolicy,
+			audience: "any-cluster",
+			want:     true,
+		},
+		{
+			name:     "empty policy allows no audience",
+			policy:   emptyPolicy,
+			audience: "any-cluster",
+			want:     false,
+		},
+		{
+			name:     "audience without groups is allowed for any user",
+			policy:   policy,
+			audience: "cluster-for-anyone",
+			want:     true,
+		},
+		{
+			name:     "audience with groups is allowed for a member of one of the groups",
+			policy:   policy,
+			audience: "cluster-for-tenant-a",
+			groups:   []string{"tenant-b-devs", "tenant-a-devs"},
+			want:     true,
+		},
+		{
+			name:     "audience with groups is denied for a user who is not a member of any of the groups",
+			policy:   policy,
+			audience: "cluster-for-tenant-a",
+			groups:   []string{"tenant-b-devs"},
+			want:     false,
+		},
+		{
+			name:     "audience with groups is denied for a user without groups",
+			policy:   policy,
+			audience: "cluster-for-tenant-a",
+			want:     false,
+		},
+		{
+			name:     "audience which is not listed is denied",
+			policy:   policy,
+			audience: "cluster-for-tenant-b",
+			groups:   []string{"tenant-a-admins"},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.policy.AllowsAudience(tt.audience, tt.groups))
+		})
+	}
+}
+
+func TestNewTokenExchangePolicyValidations(t *testing.T) {
+	_, err := NewTokenExchangePolicy([]AllowedAudience{{Name: ""}})
+	require.EqualError(t, err, "token exchange allowed audience must have a name")
+
+	_, err = NewTokenExchangePolicy([]AllowedAudience{{Name: "some-cluster"}, {Name: "some-cluster", Groups: []string{"some-group"}}})
+	require.EqualError(t, err, `token exchange allowed audience "some-cluster" is listed more than once`)
+}
@@ -0,0 +1,19 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import "time"
+
+// TokenLifetimes overrides the default lifetimes of the tokens which are issued by a FederationDomain.
+// Each zero value means that the corresponding default should be used.
+type TokenLifetimes struct {
+	AccessToken       time.Duration
+	IDToken           time.Duration
+	RefreshToken      time.Duration
+	AuthorizationCode time.Duration
+
+	// MaxSession is the maximum length of time since the user logged in, after which refreshes are denied.
+	// Zero means that sessions may be refreshed indefinitely.
+	MaxSession time.Duration
+}
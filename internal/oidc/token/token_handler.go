@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/ory/fosite"
 	errorsx "github.com/pkg/errors"
@@ -24,9 +25,12 @@ import (
 	"go.pinniped.dev/internal/psession"
 )
 
+// NewHandler returns a handler for the token endpoint. When maxSessionLifespan is not zero, then refreshes are
+// denied once that much time has passed since the user logged in.
 func NewHandler(
 	idpLister oidc.UpstreamIdentityProvidersLister,
 	oauthHelper fosite.OAuth2Provider,
+	maxSessionLifespan time.Duration,
 ) http.Handler {
 	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
 		session := psession.NewPinnipedSession()
@@ -43,6 +47,12 @@ func NewHandler(
 			// The session, requested scopes, and requested audience from the original authorize request was retrieved
 			// from the Kube storage layer and added to the accessRequest. Additionally, the audience and scopes may
 			// have already been granted on the accessRequest.
+			err = checkMaxSessionLifespan(accessRequest, maxSessionLifespan)
+			if err != nil {
+				plog.Info("refresh denied", oidc.FositeErrorForLog(err)...)
+				oauthHelper.WriteAccessError(w, accessRequest, err)
+				return nil
+			}
 			err = upstreamRefresh(r.Context(), accessRequest, idpLister)
 			if err != nil {
 				plog.Info("upstream refresh error", oidc.FositeErrorForLog(err)...)
@@ -93,6 +103,22 @@ func errUpstreamRefreshError() *fosite.RFC6749Error {
 	}
 }
 
+// checkMaxSessionLifespan denies the refresh when the session began longer ago than the maximum session lifespan.
+func checkMaxSessionLifespan(accessRequest fosite.AccessRequester, maxSessionLifespan time.Duration) error {
+	if maxSessionLifespan == 0 {
+		return nil
+	}
+	session := accessRequest.GetSession().(*psession.PinnipedSession)
+	authTime := session.IDTokenClaims().AuthTime
+	if authTime.IsZero() {
+		return errorsx.WithStack(errMissingUpstreamSessionInternalError())
+	}
+	if time.Since(authTime) > maxSessionLifespan {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The session has exceeded its maximum lifetime."))
+	}
+	return nil
+}
+
 func upstreamRefresh(ctx context.Context, accessRequest fosite.AccessRequester, providerCache oidc.UpstreamIdentityProvidersLister) error {
 	session := accessRequest.GetSession().(*psession.PinnipedSession)
 
@@ -110,9 +136,9 @@ func upstreamRefresh(ctx context.Context, accessRequest fosite.AccessRequester,
 	case psession.ProviderTypeOIDC:
 		return upstreamOIDCRefresh(ctx, session, providerCache)
 	case psession.ProviderTypeLDAP:
-		return upstreamLDAPRefresh(ctx, providerCache, session)
+		return upstreamLDAPRefresh(ctx, providerCache, session, accessRequest.GetGrantedScopes())
 	case psession.ProviderTypeActiveDirectory:
-		return upstreamLDAPRefresh(ctx, providerCache, session)
+		return upstreamLDAPRefresh(ctx, providerCache, session, accessRequest.GetGrantedScopes())
 	default:
 		return errorsx.WithStack(errMissingUpstreamSessionInternalError())
 	}
@@ -291,7 +317,12 @@ func findOIDCProviderByNameAndValidateUID(
 		WithDebugf("provider name: %q, provider type: %q", s.ProviderName, s.ProviderType))
 }
 
-func upstreamLDAPRefresh(ctx context.Context, providerCache oidc.UpstreamIdentityProvidersLister, session *psession.PinnipedSession) error {
+func upstreamLDAPRefresh(
+	ctx context.Context,
+	providerCache oidc.UpstreamIdentityProvidersLister,
+	session *psession.PinnipedSession,
+	grantedScopes fosite.Arguments,
+) error {
 	username, err := getDownstreamUsernameFromPinnipedSession(session)
 	if err != nil {
 		return err
@@ -327,7 +358,7 @@ func upstreamLDAPRefresh(ctx context.Context, providerCache oidc.UpstreamIdentit
 		return errorsx.WithStack(errMissingUpstreamSessionInternalError())
 	}
 	// run PerformRefresh
-	groups, err := p.PerformRefresh(ctx, provider.StoredRefreshAttributes{
+	groups, additionalClaims, warnings, err := p.PerformRefresh(ctx, provider.StoredRefreshAttributes{
 		Username:             username,
 		Subject:              subject,
 		DN:                   dn,
@@ -339,11 +370,18 @@ func upstreamLDAPRefresh(ctx context.Context, providerCache oidc.UpstreamIdentit
 			"Upstream refresh failed.").WithTrace(err).
 			WithDebugf("provider name: %q, provider type: %q", s.ProviderName, s.ProviderType)
 	}
-	// Replace the old value with the new value.
+	// Replace the old values with the new values.
 	session.Fosite.Claims.Extra[oidc.DownstreamGroupsClaim] = groups
+	downstreamsession.SetAdditionalClaims(session, grantedScopes, additionalClaims)
 
 	warnIfGroupsChanged(ctx, oldGroups, groups, username)
 
+	// These warnings only apply to this refresh, e.g. that the user's password will expire soon, so send them to
+	// the client now instead of storing them in the session.
+	for _, warningText := range warnings {
+		warning.AddWarning(ctx, "", warningText)
+	}
+
 	return nil
 }
 
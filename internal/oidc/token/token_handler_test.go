@@ -254,9 +254,10 @@ type authcodeExchangeInputs struct {
 		s fositestoragei.AllFositeStorage,
 		authCode string,
 	)
-	makeOathHelper    OauthHelperFactoryFunc
-	customSessionData *psession.CustomSessionData
-	want              tokenEndpointResponseExpectedValues
+	makeOathHelper     OauthHelperFactoryFunc
+	customSessionData  *psession.CustomSessionData
+	maxSessionLifespan time.Duration
+	want               tokenEndpointResponseExpectedValues
 }
 
 func TestTokenEndpointAuthcodeExchange(t *testing.T) {
@@ -651,7 +652,10 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 		requestedAudience   string
 
 		wantStatus               int
+		wantAudience             string // when empty, then the token should have the requestedAudience
 		wantResponseBodyContains string
+		wantGroups               []string // when nil, then the token should contain the groups from the initial login
+		wantActClaim             map[string]interface{}
 	}{
 		{
 			name:              "happy path",
@@ -664,7 +668,7 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 			authcodeExchange:         doValidAuthCodeExchange,
 			requestedAudience:        "",
 			wantStatus:               http.StatusBadRequest,
-			wantResponseBodyContains: "missing audience parameter",
+			wantResponseBodyContains: "missing audience or resource parameter",
 		},
 		{
 			name:              "missing subject_token",
@@ -697,14 +701,142 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 			wantResponseBodyContains: `unsupported requested_token_type parameter value`,
 		},
 		{
-			name:              "unsupported RFC8693 parameter",
+			name:              "resource instead of audience",
+			authcodeExchange:  doValidAuthCodeExchange,
+			requestedAudience: "",
+			modifyRequestParams: func(t *testing.T, params url.Values) {
+				params.Set("resource", "https://some-workload-cluster.example.com/api")
+			},
+			wantStatus:   http.StatusOK,
+			wantAudience: "https://some-workload-cluster.example.com/api",
+		},
+		{
+			name:              "resource which is the same as the audience",
+			authcodeExchange:  doValidAuthCodeExchange,
+			requestedAudience: "https://some-workload-cluster.example.com/api",
+			modifyRequestParams: func(t *testing.T, params url.Values) {
+				params.Set("resource", "https://some-workload-cluster.example.com/api")
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:              "resource which is different from the audience",
+			authcodeExchange:  doValidAuthCodeExchange,
+			requestedAudience: "some-workload-cluster",
+			modifyRequestParams: func(t *testing.T, params url.Values) {
+				params.Set("resource", "https://other-workload-cluster.example.com/api")
+			},
+			wantStatus:               http.StatusBadRequest,
+			wantResponseBodyContains: `"error":"invalid_target","error_description":"The requested audience or resource is invalid, unknown, or malformed. only one audience or resource may be requested"`,
+		},
+		{
+			name:              "resource which is not an absolute URI",
+			authcodeExchange:  doValidAuthCodeExchange,
+			requestedAudience: "",
+			modifyRequestParams: func(t *testing.T, params url.Values) {
+				params.Set("resource", "some-workload-cluster")
+			},
+			wantStatus:               http.StatusBadRequest,
+			wantResponseBodyContains: `"error":"invalid_target","error_description":"The requested audience or resource is invalid, unknown, or malformed. resource parameter 'some-workload-cluster' must be an absolute URI without a fragment"`,
+		},
+		{
+			name:              "actor token",
+			authcodeExchange:  doValidAuthCodeExchange,
+			requestedAudience: "some-workload-cluster",
+			modifyRequestParams: func(t *testing.T, params url.Values) {
+				params.Set("actor_token", params.Get("subject_token"))
+				params.Set("actor_token_type", "urn:ietf:params:oauth:token-type:access_token")
+			},
+			wantStatus:   http.StatusOK,
+			wantActClaim: map[string]interface{}{"sub": goodSubject, "username": goodUsername},
+		},
+		{
+			name:              "actor token without actor_token_type",
+			authcodeExchange:  doValidAuthCodeExchange,
+			requestedAudience: "some-workload-cluster",
+			modifyRequestParams: func(t *testing.T, params url.Values) {
+				params.Set("actor_token", params.Get("subject_token"))
+			},
+			wantStatus:               http.StatusBadRequest,
+			wantResponseBodyContains: `unsupported actor_token_type parameter value`,
+		},
+		{
+			name:              "wrong actor_token_type",
+			authcodeExchange:  doValidAuthCodeExchange,
+			requestedAudience: "some-workload-cluster",
+			modifyRequestParams: func(t *testing.T, params url.Values) {
+				params.Set("actor_token", params.Get("subject_token"))
+				params.Set("actor_token_type", "urn:ietf:params:oauth:token-type:jwt")
+			},
+			wantStatus:               http.StatusBadRequest,
+			wantResponseBodyContains: `unsupported actor_token_type parameter value`,
+		},
+		{
+			name:              "actor_token_type without actor token",
+			authcodeExchange:  doValidAuthCodeExchange,
+			requestedAudience: "some-workload-cluster",
+			modifyRequestParams: func(t *testing.T, params url.Values) {
+				params.Set("actor_token_type", "urn:ietf:params:oauth:token-type:access_token")
+			},
+			wantStatus:               http.StatusBadRequest,
+			wantResponseBodyContains: `actor_token_type parameter is not allowed without actor_token parameter`,
+		},
+		{
+			name:              "bogus actor token",
 			authcodeExchange:  doValidAuthCodeExchange,
 			requestedAudience: "some-workload-cluster",
 			modifyRequestParams: func(t *testing.T, params url.Values) {
-				params.Set("resource", "some-resource-parameter-value")
+				params.Set("actor_token", "some-bogus-value")
+				params.Set("actor_token_type", "urn:ietf:params:oauth:token-type:access_token")
 			},
 			wantStatus:               http.StatusBadRequest,
-			wantResponseBodyContains: `unsupported parameter resource`,
+			wantResponseBodyContains: `Invalid token format`,
+		},
+		{
+			name:              "scope downscopes the groups",
+			authcodeExchange:  doValidAuthCodeExchange,
+			requestedAudience: "some-workload-cluster",
+			modifyRequestParams: func(t *testing.T, params url.Values) {
+				params.Set("scope", "group:"+goodGroups[1])
+			},
+			wantStatus: http.StatusOK,
+			wantGroups: []string{goodGroups[1]},
+		},
+		{
+			name:              "scope requests a group which the user is not in",
+			authcodeExchange:  doValidAuthCodeExchange,
+			requestedAudience: "some-workload-cluster",
+			modifyRequestParams: func(t *testing.T, params url.Values) {
+				params.Set("scope", "group:"+goodGroups[0]+" group:some-other-group")
+			},
+			wantStatus:               http.StatusBadRequest,
+			wantResponseBodyContains: `the requested group 'some-other-group' is not one of the user's groups`,
+		},
+		{
+			name:              "scope which is not a group",
+			authcodeExchange:  doValidAuthCodeExchange,
+			requestedAudience: "some-workload-cluster",
+			modifyRequestParams: func(t *testing.T, params url.Values) {
+				params.Set("scope", "openid")
+			},
+			wantStatus:               http.StatusBadRequest,
+			wantResponseBodyContains: `unsupported scope 'openid'`,
+		},
+		{
+			name: "downscoped groups are not allowed by the token exchange policy",
+			authcodeExchange: authcodeExchangeInputs{
+				modifyAuthRequest: doValidAuthCodeExchange.modifyAuthRequest,
+				makeOathHelper: makeOauthHelperWithTokenExchangePolicy(
+					provider.AllowedAudience{Name: "some-workload-cluster", Groups: []string{goodGroups[1]}},
+				),
+				want: successfulAuthCodeExchange,
+			},
+			requestedAudience: "some-workload-cluster",
+			modifyRequestParams: func(t *testing.T, params url.Values) {
+				params.Set("scope", "group:"+goodGroups[0])
+			},
+			wantStatus:               http.StatusForbidden,
+			wantResponseBodyContains: `the requested audience 'some-workload-cluster' is not allowed`,
 		},
 		{
 			name:              "bogus access token",
@@ -778,6 +910,56 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 			wantStatus:               http.StatusServiceUnavailable,
 			wantResponseBodyContains: `The authorization server is currently unable to handle the request`,
 		},
+		{
+			name: "audience allowed by the token exchange policy",
+			authcodeExchange: authcodeExchangeInputs{
+				modifyAuthRequest: doValidAuthCodeExchange.modifyAuthRequest,
+				makeOathHelper: makeOauthHelperWithTokenExchangePolicy(
+					provider.AllowedAudience{Name: "some-workload-cluster"},
+				),
+				want: successfulAuthCodeExchange,
+			},
+			requestedAudience: "some-workload-cluster",
+			wantStatus:        http.StatusOK,
+		},
+		{
+			name: "audience allowed by the token exchange policy for one of the user's groups",
+			authcodeExchange: authcodeExchangeInputs{
+				modifyAuthRequest: doValidAuthCodeExchange.modifyAuthRequest,
+				makeOathHelper: makeOauthHelperWithTokenExchangePolicy(
+					provider.AllowedAudience{Name: "some-workload-cluster", Groups: []string{"some-other-group", goodGroups[1]}},
+				),
+				want: successfulAuthCodeExchange,
+			},
+			requestedAudience: "some-workload-cluster",
+			wantStatus:        http.StatusOK,
+		},
+		{
+			name: "audience not listed by the token exchange policy",
+			authcodeExchange: authcodeExchangeInputs{
+				modifyAuthRequest: doValidAuthCodeExchange.modifyAuthRequest,
+				makeOathHelper: makeOauthHelperWithTokenExchangePolicy(
+					provider.AllowedAudience{Name: "some-other-workload-cluster"},
+				),
+				want: successfulAuthCodeExchange,
+			},
+			requestedAudience:        "some-workload-cluster",
+			wantStatus:               http.StatusForbidden,
+			wantResponseBodyContains: `"error":"access_denied"`,
+		},
+		{
+			name: "audience allowed by the token exchange policy only for groups which the user is not in",
+			authcodeExchange: authcodeExchangeInputs{
+				modifyAuthRequest: doValidAuthCodeExchange.modifyAuthRequest,
+				makeOathHelper: makeOauthHelperWithTokenExchangePolicy(
+					provider.AllowedAudience{Name: "some-workload-cluster", Groups: []string{"some-other-group"}},
+				),
+				want: successfulAuthCodeExchange,
+			},
+			requestedAudience:        "some-workload-cluster",
+			wantStatus:               http.StatusForbidden,
+			wantResponseBodyContains: `the requested audience 'some-workload-cluster' is not allowed`,
+		},
 	}
 	for _, test := range tests {
 		test := test
@@ -846,6 +1028,9 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 
 			// Make sure that these are the only fields in the token.
 			idTokenFields := []string{"sub", "aud", "iss", "jti", "auth_time", "exp", "iat", "rat", "groups", "username"}
+			if test.wantActClaim != nil {
+				idTokenFields = append(idTokenFields, "act")
+			}
 			require.ElementsMatch(t, idTokenFields, getMapKeys(tokenClaims))
 
 			// Assert that the returned token has expected claims values.
@@ -855,11 +1040,22 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 			require.NotEmpty(t, tokenClaims["iat"])
 			require.NotEmpty(t, tokenClaims["rat"])
 			require.Len(t, tokenClaims["aud"], 1)
-			require.Contains(t, tokenClaims["aud"], test.requestedAudience)
+			wantAudience := test.wantAudience
+			if wantAudience == "" {
+				wantAudience = test.requestedAudience
+			}
+			require.Contains(t, tokenClaims["aud"], wantAudience)
 			require.Equal(t, goodSubject, tokenClaims["sub"])
 			require.Equal(t, goodIssuer, tokenClaims["iss"])
 			require.Equal(t, goodUsername, tokenClaims["username"])
-			require.Equal(t, toSliceOfInterface(test.authcodeExchange.want.wantGroups), tokenClaims["groups"])
+			wantGroups := test.wantGroups
+			if wantGroups == nil {
+				wantGroups = test.authcodeExchange.want.wantGroups
+			}
+			require.Equal(t, toSliceOfInterface(wantGroups), tokenClaims["groups"])
+			if test.wantActClaim != nil {
+				require.Equal(t, test.wantActClaim, tokenClaims["act"])
+			}
 
 			// Also assert that some are the same as the original downstream ID token.
 			requireClaimsAreEqual(t, "iss", claimsOfFirstIDToken, tokenClaims)       // issuer
@@ -1100,6 +1296,28 @@ func TestRefreshGrant(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "refresh grant after the maximum session lifetime has passed",
+			idps: oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(
+				upstreamOIDCIdentityProviderBuilder().WithRefreshedTokens(refreshedUpstreamTokensWithIDAndRefreshTokens()).Build()),
+			authcodeExchange: authcodeExchangeInputs{
+				customSessionData:  initialUpstreamOIDCRefreshTokenCustomSessionData(),
+				modifyAuthRequest:  func(r *http.Request) { r.Form.Set("scope", "openid offline_access") },
+				maxSessionLifespan: 8 * time.Hour, // the fixed auth time used by these tests was long ago
+				want:               happyAuthcodeExchangeTokenResponseForOpenIDAndOfflineAccess(initialUpstreamOIDCRefreshTokenCustomSessionData()),
+			},
+			refreshRequest: refreshRequestInputs{
+				want: tokenEndpointResponseExpectedValues{
+					wantStatus: http.StatusBadRequest,
+					wantErrorResponseBody: here.Doc(`
+						{
+							"error":             "invalid_grant",
+							"error_description": "The provided authorization grant (e.g., authorization code, resource owner credentials) or refresh token is invalid, expired, revoked, does not match the redirection URI used in the authorization request, or was issued to another client. The session has exceeded its maximum lifetime."
+						}
+					`),
+				},
+			},
+		},
 		{
 			name: "refresh grant with unchanged username claim",
 			idps: oidctestutil.NewUpstreamIDPListerBuilder().WithOIDC(
@@ -1366,6 +1584,42 @@ func TestRefreshGrant(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "happy path refresh grant when the upstream refresh returns additional claims from LDAP, it updates the additional claims in the session",
+			idps: oidctestutil.NewUpstreamIDPListerBuilder().WithLDAP(&oidctestutil.TestUpstreamLDAPIdentityProvider{
+				Name:                           ldapUpstreamName,
+				ResourceUID:                    ldapUpstreamResourceUID,
+				URL:                            ldapUpstreamURL,
+				PerformRefreshGroups:           goodGroups,
+				PerformRefreshAdditionalClaims: map[string]string{"email": "new-email@example.com", "sub": "ignored-reserved-claim", "act": "ignored-reserved-claim"},
+			}),
+			authcodeExchange: authcodeExchangeInputs{
+				modifyAuthRequest: func(r *http.Request) { r.Form.Set("scope", "openid offline_access") },
+				customSessionData: happyLDAPCustomSessionData,
+				want: happyAuthcodeExchangeTokenResponseForOpenIDAndOfflineAccess(
+					happyLDAPCustomSessionData,
+				),
+			},
+			refreshRequest: refreshRequestInputs{
+				want: tokenEndpointResponseExpectedValues{
+					wantStatus:              http.StatusOK,
+					wantSuccessBodyFields:   []string{"refresh_token", "access_token", "id_token", "token_type", "expires_in", "scope"},
+					wantRequestedScopes:     []string{"openid", "offline_access"},
+					wantGrantedScopes:       []string{"openid", "offline_access"},
+					wantGroups:              goodGroups,
+					wantUpstreamRefreshCall: happyLDAPUpstreamRefreshCall(),
+					wantCustomSessionDataStored: &psession.CustomSessionData{
+						ProviderUID:  ldapUpstreamResourceUID,
+						ProviderName: ldapUpstreamName,
+						ProviderType: ldapUpstreamType,
+						LDAP: &psession.LDAPSessionData{
+							UserDN:           ldapUpstreamDN,
+							AdditionalClaims: map[string]string{"email": "new-email@example.com"},
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "happy path refresh grant when the upstream refresh returns empty list of group memberships from LDAP, it updates groups to an empty list",
 			idps: oidctestutil.NewUpstreamIDPListerBuilder().WithLDAP(&oidctestutil.TestUpstreamLDAPIdentityProvider{
@@ -2913,7 +3167,7 @@ func exchangeAuthcodeForTokens(t *testing.T, test authcodeExchangeInputs, idps p
 		test.modifyStorage(t, oauthStore, authCode)
 	}
 
-	subject = NewHandler(idps, oauthHelper)
+	subject = NewHandler(idps, oauthHelper, test.maxSessionLifespan)
 
 	authorizeEndpointGrantedOpenIDScope := strings.Contains(authRequest.Form.Get("scope"), "openid")
 	expectedNumberOfIDSessionsStored := 0
@@ -3111,11 +3365,30 @@ func makeHappyOauthHelper(
 	t.Helper()
 
 	jwtSigningKey, jwkProvider := generateJWTSigningKeyAndJWKSProvider(t, goodIssuer)
-	oauthHelper := oidc.FositeOauth2Helper(store, goodIssuer, hmacSecretFunc, jwkProvider, oidc.DefaultOIDCTimeoutsConfiguration())
+	oauthHelper := oidc.FositeOauth2Helper(store, goodIssuer, hmacSecretFunc, jwkProvider, oidc.DefaultOIDCTimeoutsConfiguration(), nil)
 	authResponder := simulateAuthEndpointHavingAlreadyRun(t, authRequest, oauthHelper, initialCustomSessionData)
 	return oauthHelper, authResponder.GetCode(), jwtSigningKey
 }
 
+func makeOauthHelperWithTokenExchangePolicy(allowedAudiences ...provider.AllowedAudience) OauthHelperFactoryFunc {
+	return func(
+		t *testing.T,
+		authRequest *http.Request,
+		store fositestoragei.AllFositeStorage,
+		initialCustomSessionData *psession.CustomSessionData,
+	) (fosite.OAuth2Provider, string, *ecdsa.PrivateKey) {
+		t.Helper()
+
+		tokenExchangePolicy, err := provider.NewTokenExchangePolicy(allowedAudiences)
+		require.NoError(t, err)
+
+		jwtSigningKey, jwkProvider := generateJWTSigningKeyAndJWKSProvider(t, goodIssuer)
+		oauthHelper := oidc.FositeOauth2Helper(store, goodIssuer, hmacSecretFunc, jwkProvider, oidc.DefaultOIDCTimeoutsConfiguration(), tokenExchangePolicy)
+		authResponder := simulateAuthEndpointHavingAlreadyRun(t, authRequest, oauthHelper, initialCustomSessionData)
+		return oauthHelper, authResponder.GetCode(), jwtSigningKey
+	}
+}
+
 type singleUseJWKProvider struct {
 	jwks.DynamicJWKSProvider
 	calls int
@@ -3138,7 +3411,7 @@ func makeOauthHelperWithJWTKeyThatWorksOnlyOnce(
 	t.Helper()
 
 	jwtSigningKey, jwkProvider := generateJWTSigningKeyAndJWKSProvider(t, goodIssuer)
-	oauthHelper := oidc.FositeOauth2Helper(store, goodIssuer, hmacSecretFunc, &singleUseJWKProvider{DynamicJWKSProvider: jwkProvider}, oidc.DefaultOIDCTimeoutsConfiguration())
+	oauthHelper := oidc.FositeOauth2Helper(store, goodIssuer, hmacSecretFunc, &singleUseJWKProvider{DynamicJWKSProvider: jwkProvider}, oidc.DefaultOIDCTimeoutsConfiguration(), nil)
 	authResponder := simulateAuthEndpointHavingAlreadyRun(t, authRequest, oauthHelper, initialCustomSessionData)
 	return oauthHelper, authResponder.GetCode(), jwtSigningKey
 }
@@ -3152,7 +3425,7 @@ func makeOauthHelperWithNilPrivateJWTSigningKey(
 	t.Helper()
 
 	jwkProvider := jwks.NewDynamicJWKSProvider() // empty provider which contains no signing key for this issuer
-	oauthHelper := oidc.FositeOauth2Helper(store, goodIssuer, hmacSecretFunc, jwkProvider, oidc.DefaultOIDCTimeoutsConfiguration())
+	oauthHelper := oidc.FositeOauth2Helper(store, goodIssuer, hmacSecretFunc, jwkProvider, oidc.DefaultOIDCTimeoutsConfiguration(), nil)
 	authResponder := simulateAuthEndpointHavingAlreadyRun(t, authRequest, oauthHelper, initialCustomSessionData)
 	return oauthHelper, authResponder.GetCode(), nil
 }
@@ -3679,3 +3952,54 @@ func TestDiffSortedGroups(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckMaxSessionLifespan(t *testing.T) {
+	tests := []struct {
+		name               string
+		authTime           time.Time
+		maxSessionLifespan time.Duration
+		wantErr            string
+	}{
+		{
+			name:               "no maximum session lifespan",
+			authTime:           goodAuthTime,
+			maxSessionLifespan: 0,
+		},
+		{
+			name:               "session is within the maximum session lifespan",
+			authTime:           time.Now().Add(-7 * time.Hour),
+			maxSessionLifespan: 8 * time.Hour,
+		},
+		{
+			name:               "session has exceeded the maximum session lifespan",
+			authTime:           time.Now().Add(-9 * time.Hour),
+			maxSessionLifespan: 8 * time.Hour,
+			wantErr:            "The session has exceeded its maximum lifetime.",
+		},
+		{
+			name:               "auth time is the zero value",
+			authTime:           time.Time{},
+			maxSessionLifespan: 8 * time.Hour,
+			wantErr:            "Required upstream data not found in session.",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			session := psession.NewPinnipedSession()
+			session.Fosite.Claims.AuthTime = test.authTime
+			accessRequest := fosite.NewAccessRequest(session)
+
+			err := checkMaxSessionLifespan(accessRequest, test.maxSessionLifespan)
+			if test.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			var rfc6749Error *fosite.RFC6749Error
+			require.ErrorAs(t, err, &rfc6749Error)
+			require.Equal(t, test.wantErr, rfc6749Error.HintField)
+		})
+	}
+}
@@ -1,11 +1,13 @@
-// Copyright 2020 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2022 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidc
 
 import (
 	"context"
+	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/ory/fosite"
@@ -13,24 +15,51 @@ import (
 	"github.com/ory/fosite/handler/oauth2"
 	"github.com/ory/fosite/handler/openid"
 	"github.com/pkg/errors"
+
+	"go.pinniped.dev/internal/oidc/provider"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
 )
 
 const (
 	tokenTypeAccessToken       = "urn:ietf:params:oauth:token-type:access_token" //nolint: gosec
 	tokenTypeJWT               = "urn:ietf:params:oauth:token-type:jwt"          //nolint: gosec
 	pinnipedTokenExchangeScope = "pinniped:request-audience"                     //nolint: gosec
+
+	// groupScopePrefix is the prefix of the scopes which downscope the groups of the issued token, e.g. the scope
+	// "group:admins" requests that the issued token should contain the "admins" group.
+	groupScopePrefix = "group:"
 )
 
+// errInvalidTarget is the RFC 8693 error for a token exchange which requests a target service for which no token
+// can be issued, see https://datatracker.ietf.org/doc/html/rfc8693#section-2.2.2.
+func errInvalidTarget() *fosite.RFC6749Error {
+	return &fosite.RFC6749Error{
+		ErrorField:       "invalid_target",
+		DescriptionField: "The requested audience or resource is invalid, unknown, or malformed.",
+		CodeField:        http.StatusBadRequest,
+	}
+}
+
 type stsParams struct {
 	subjectAccessToken string
+	actorAccessToken   string
 	requestedAudience  string
+
+	// requestedGroups are the only groups which should be included in the issued token. Nil means all the groups.
+	requestedGroups []string
 }
 
-func TokenExchangeFactory(config *compose.Config, storage interface{}, strategy interface{}) interface{} {
-	return &TokenExchangeHandler{
-		idTokenStrategy:     strategy.(openid.OpenIDConnectTokenStrategy),
-		accessTokenStrategy: strategy.(oauth2.AccessTokenStrategy),
-		accessTokenStorage:  storage.(oauth2.AccessTokenStorage),
+// TokenExchangeFactory returns a compose.Factory for a TokenExchangeHandler which only mints tokens for the audiences
+// which are allowed by the given policy. A nil policy allows any audience.
+func TokenExchangeFactory(policy *provider.TokenExchangePolicy) compose.Factory {
+	return func(config *compose.Config, storage interface{}, strategy interface{}) interface{} {
+		return &TokenExchangeHandler{
+			idTokenStrategy:     strategy.(openid.OpenIDConnectTokenStrategy),
+			accessTokenStrategy: strategy.(oauth2.AccessTokenStrategy),
+			accessTokenStorage:  storage.(oauth2.AccessTokenStorage),
+			policy:              policy,
+		}
 	}
 }
 
@@ -38,6 +67,7 @@ type TokenExchangeHandler struct {
 	idTokenStrategy     openid.OpenIDConnectTokenStrategy
 	accessTokenStrategy oauth2.AccessTokenStrategy
 	accessTokenStorage  oauth2.AccessTokenStorage
+	policy              *provider.TokenExchangePolicy
 }
 
 var _ fosite.TokenEndpointHandler = (*TokenExchangeHandler)(nil)
@@ -62,7 +92,7 @@ func (t *TokenExchangeHandler) PopulateTokenEndpointResponse(ctx context.Context
 	}
 
 	// Validate the incoming access token and lookup the information about the original authorize request.
-	originalRequester, err := t.validateAccessToken(ctx, requester, params.subjectAccessToken)
+	originalRequester, err := t.validateAccessToken(ctx, requester, params.subjectAccessToken, "subject_token")
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -75,8 +105,40 @@ func (t *TokenExchangeHandler) PopulateTokenEndpointResponse(ctx context.Context
 		return errors.WithStack(fosite.ErrAccessDenied.WithHintf("missing the %q scope", oidc.ScopeOpenID))
 	}
 
+	// Make a copy of the original session so that the claims of the new JWT can be changed.
+	session, ok := originalRequester.GetSession().Clone().(*psession.PinnipedSession)
+	if !ok || session.Fosite == nil || session.Fosite.Claims == nil {
+		return errors.WithStack(fosite.ErrServerError.WithHint("invalid session"))
+	}
+	if session.Fosite.Claims.Extra == nil {
+		session.Fosite.Claims.Extra = map[string]interface{}{}
+	}
+
+	// Remove the groups which were not requested.
+	groups, err := downscopeGroups(DownstreamGroupsFromClaims(session.Fosite.Claims.Extra), params.requestedGroups)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if params.requestedGroups != nil {
+		session.Fosite.Claims.Extra[DownstreamGroupsClaim] = groups
+	}
+
+	// Require that the user is allowed to request the audience, using only the groups which the new JWT will contain.
+	if err := t.authorizeAudience(session, groups, params.requestedAudience); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// When another party is acting on behalf of the user, then name them in the new JWT.
+	if params.actorAccessToken != "" {
+		actorClaim, err := t.actorClaim(ctx, requester, params.actorAccessToken)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		session.Fosite.Claims.Extra[DownstreamActorClaim] = actorClaim
+	}
+
 	// Use the original authorize request information, along with the requested audience, to mint a new JWT.
-	responseToken, err := t.mintJWT(ctx, originalRequester, params.requestedAudience)
+	responseToken, err := t.mintJWT(ctx, session, params.requestedAudience)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -88,8 +150,86 @@ func (t *TokenExchangeHandler) PopulateTokenEndpointResponse(ctx context.Context
 	return nil
 }
 
-func (t *TokenExchangeHandler) mintJWT(ctx context.Context, requester fosite.Requester, audience string) (string, error) {
-	downscoped := fosite.NewAccessRequest(requester.GetSession())
+func (t *TokenExchangeHandler) authorizeAudience(session *psession.PinnipedSession, groups []string, audience string) error {
+	if !t.policy.AllowsAudience(audience, groups) {
+		plog.Info("token exchange denied for requested audience",
+			"audience", audience,
+			"subject", session.Fosite.Claims.Subject,
+			"username", session.Fosite.Claims.Extra[DownstreamUsernameClaim],
+			"groups", groups,
+		)
+		return fosite.ErrAccessDenied.WithHintf("the requested audience %q is not allowed", audience)
+	}
+	return nil
+}
+
+// actorClaim validates the access token of the actor, and returns the RFC 8693 "act" claim which names the actor.
+func (t *TokenExchangeHandler) actorClaim(ctx context.Context, requester fosite.AccessRequester, actorAccessToken string) (map[string]interface{}, error) {
+	actorRequester, err := t.validateAccessToken(ctx, requester, actorAccessToken, "actor_token")
+	if err != nil {
+		return nil, err
+	}
+
+	// The actor must have been allowed to use the token exchange, just like the user.
+	if !actorRequester.GetGrantedScopes().Has(pinnipedTokenExchangeScope) {
+		return nil, fosite.ErrAccessDenied.WithHintf("actor_token is missing the %q scope", pinnipedTokenExchangeScope)
+	}
+
+	actorSession, ok := actorRequester.GetSession().(*psession.PinnipedSession)
+	if !ok || actorSession.Fosite == nil || actorSession.Fosite.Claims == nil {
+		return nil, fosite.ErrServerError.WithHint("invalid actor session")
+	}
+	actorClaim := map[string]interface{}{
+		IDTokenSubjectClaim: actorSession.Fosite.Claims.Subject,
+	}
+	if username, ok := actorSession.Fosite.Claims.Extra[DownstreamUsernameClaim]; ok {
+		actorClaim[DownstreamUsernameClaim] = username
+	}
+	return actorClaim, nil
+}
+
+// downscopeGroups returns the requested groups, which must all be groups of the user. When requestedGroups is nil,
+// then it returns all the groups of the user.
+func downscopeGroups(groups []string, requestedGroups []string) ([]string, error) {
+	if requestedGroups == nil {
+		return groups, nil
+	}
+	for _, requestedGroup := range requestedGroups {
+		found := false
+		for _, group := range groups {
+			if group == requestedGroup {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fosite.ErrInvalidScope.WithHintf("the requested group %q is not one of the user's groups", requestedGroup)
+		}
+	}
+	return requestedGroups, nil
+}
+
+// DownstreamGroupsFromClaims returns the groups in the ID token claims of the downstream session. The groups
+// are a []interface{} after the session was read from storage.
+func DownstreamGroupsFromClaims(extra map[string]interface{}) []string {
+	switch groups := extra[DownstreamGroupsClaim].(type) {
+	case []string:
+		return groups
+	case []interface{}:
+		result := make([]string, 0, len(groups))
+		for _, group := range groups {
+			if groupName, ok := group.(string); ok {
+				result = append(result, groupName)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+func (t *TokenExchangeHandler) mintJWT(ctx context.Context, session fosite.Session, audience string) (string, error) {
+	downscoped := fosite.NewAccessRequest(session)
 	downscoped.Client.(*fosite.DefaultClient).ID = audience
 	return t.idTokenStrategy.GenerateIDToken(ctx, downscoped)
 }
@@ -97,11 +237,15 @@ func (t *TokenExchangeHandler) mintJWT(ctx context.Context, requester fosite.Req
 func (t *TokenExchangeHandler) validateParams(params url.Values) (*stsParams, error) {
 	var result stsParams
 
-	// Validate some required parameters.
-	result.requestedAudience = params.Get("audience")
-	if result.requestedAudience == "" {
-		return nil, fosite.ErrInvalidRequest.WithHint("missing audience parameter")
+	// Validate the target of the token, which may be requested using either the audience parameter or the resource
+	// parameter. Both name the audience of the issued token, so a token may only be requested for one target.
+	requestedAudience, err := requestedAudienceParam(params)
+	if err != nil {
+		return nil, err
 	}
+	result.requestedAudience = requestedAudience
+
+	// Validate some required parameters.
 	result.subjectAccessToken = params.Get("subject_token")
 	if result.subjectAccessToken == "" {
 		return nil, fosite.ErrInvalidRequest.WithHint("missing subject_token parameter")
@@ -115,29 +259,70 @@ func (t *TokenExchangeHandler) validateParams(params url.Values) (*stsParams, er
 		return nil, fosite.ErrInvalidRequest.WithHintf("unsupported requested_token_type parameter value, must be %q", tokenTypeJWT)
 	}
 
-	// Validate that none of these unsupported parameters were sent. These are optional and we do not currently support them.
-	for _, param := range []string{
-		"resource",
-		"scope",
-		"actor_token",
-		"actor_token_type",
-	} {
-		if params.Get(param) != "" {
-			return nil, fosite.ErrInvalidRequest.WithHintf("unsupported parameter %s", param)
+	// Validate the optional actor token, which is only allowed together with its type.
+	result.actorAccessToken = params.Get("actor_token")
+	actorTokenType := params.Get("actor_token_type")
+	if result.actorAccessToken == "" && actorTokenType != "" {
+		return nil, fosite.ErrInvalidRequest.WithHint("actor_token_type parameter is not allowed without actor_token parameter")
+	}
+	if result.actorAccessToken != "" && actorTokenType != tokenTypeAccessToken {
+		return nil, fosite.ErrInvalidRequest.WithHintf("unsupported actor_token_type parameter value, must be %q", tokenTypeAccessToken)
+	}
+
+	// Validate the optional scopes, which may only downscope the groups of the issued token.
+	if scope := params.Get("scope"); scope != "" {
+		result.requestedGroups = []string{}
+		for _, requestedScope := range strings.Fields(scope) {
+			group := strings.TrimPrefix(requestedScope, groupScopePrefix)
+			if group == requestedScope || group == "" {
+				return nil, fosite.ErrInvalidScope.WithHintf("unsupported scope %q, only scopes of the form %q are allowed", requestedScope, groupScopePrefix+"<group>")
+			}
+			result.requestedGroups = append(result.requestedGroups, group)
 		}
 	}
 
 	return &result, nil
 }
 
-func (t *TokenExchangeHandler) validateAccessToken(ctx context.Context, requester fosite.AccessRequester, accessToken string) (fosite.Requester, error) {
+// requestedAudienceParam returns the one audience which was requested using the audience and resource parameters,
+// see https://datatracker.ietf.org/doc/html/rfc8693#section-2.1. A resource must be an absolute URI, while an audience
+// may be any name, e.g. the name of a workload cluster.
+func requestedAudienceParam(params url.Values) (string, error) {
+	for _, resource := range params["resource"] {
+		resourceURL, err := url.Parse(resource)
+		if err != nil || !resourceURL.IsAbs() || resourceURL.Fragment != "" {
+			return "", errInvalidTarget().WithHintf("resource parameter %q must be an absolute URI without a fragment", resource)
+		}
+	}
+
+	var requestedAudience string
+	targets := make([]string, 0, len(params["audience"])+len(params["resource"]))
+	targets = append(targets, params["audience"]...)
+	targets = append(targets, params["resource"]...)
+	for _, target := range targets {
+		switch {
+		case target == "":
+			continue
+		case requestedAudience == "":
+			requestedAudience = target
+		case target != requestedAudience:
+			return "", errInvalidTarget().WithHint("only one audience or resource may be requested")
+		}
+	}
+	if requestedAudience == "" {
+		return "", fosite.ErrInvalidRequest.WithHint("missing audience or resource parameter")
+	}
+	return requestedAudience, nil
+}
+
+func (t *TokenExchangeHandler) validateAccessToken(ctx context.Context, requester fosite.AccessRequester, accessToken string, paramName string) (fosite.Requester, error) {
 	if err := t.accessTokenStrategy.ValidateAccessToken(ctx, requester, accessToken); err != nil {
 		return nil, errors.WithStack(err)
 	}
 	signature := t.accessTokenStrategy.AccessTokenSignature(accessToken)
 	originalRequester, err := t.accessTokenStorage.GetAccessTokenSession(ctx, signature, requester.GetSession())
 	if err != nil {
-		return nil, fosite.ErrRequestUnauthorized.WithWrap(err).WithHint("invalid subject_token")
+		return nil, fosite.ErrRequestUnauthorized.WithWrap(err).WithHintf("invalid %s", paramName)
 	}
 	return originalRequester, nil
 }
@@ -0,0 +1,93 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package userinfo provides a handler for the OIDC UserInfo endpoint.
+package userinfo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/ory/fosite"
+
+	"go.pinniped.dev/internal/oidc"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+)
+
+// NewHandler returns an http.Handler that serves the OIDC UserInfo endpoint, as described in
+// https://openid.net/specs/openid-connect-core-1_0.html#UserInfo. The access token is read from the
+// Authorization header or from the access_token form parameter, as described in RFC 6750. The response
+// contains the same identity as the ID tokens of the session, i.e. the sub, username, and groups claims
+// and any additional claims which were granted.
+func NewHandler(oauthHelper fosite.OAuth2Provider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			http.Error(w, `Method not allowed (try GET or POST)`, http.StatusMethodNotAllowed)
+			return
+		}
+
+		accessToken := fosite.AccessTokenFromRequest(r)
+		if accessToken == "" {
+			// RFC 6750 says that there should be no error code when the request lacks any authentication information.
+			w.Header().Set("WWW-Authenticate", `Bearer`)
+			http.Error(w, "missing access token", http.StatusUnauthorized)
+			return
+		}
+
+		tokenUse, requester, err := oauthHelper.IntrospectToken(r.Context(), accessToken, fosite.AccessToken, psession.NewPinnipedSession())
+		if err == nil && tokenUse != fosite.AccessToken {
+			err = fosite.ErrInvalidTokenFormat.WithHint("Only access tokens may be used at the userinfo endpoint.")
+		}
+		if err != nil {
+			plog.Info("userinfo request error", oidc.FositeErrorForLog(err)...)
+			writeBearerError(w, http.StatusUnauthorized, "invalid_token", "the access token is invalid or has expired")
+			return
+		}
+
+		if !requester.GetGrantedScopes().Has(coreosoidc.ScopeOpenID) {
+			writeBearerError(w, http.StatusForbidden, "insufficient_scope", fmt.Sprintf("the access token must have the %q scope", coreosoidc.ScopeOpenID))
+			return
+		}
+
+		session, ok := requester.GetSession().(*psession.PinnipedSession)
+		if !ok || session.Fosite == nil || session.Fosite.Claims == nil {
+			http.Error(w, "invalid session", http.StatusInternalServerError)
+			return
+		}
+
+		var b bytes.Buffer
+		if err := json.NewEncoder(&b).Encode(claimsFromSession(session)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if _, err := w.Write(b.Bytes()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
+
+// claimsFromSession returns the identity claims of the ID tokens of the session. The additional claims in the
+// session were already filtered by the granted scopes when the session was created or refreshed.
+func claimsFromSession(session *psession.PinnipedSession) map[string]interface{} {
+	idTokenClaims := session.IDTokenClaims()
+	claims := make(map[string]interface{}, len(idTokenClaims.Extra)+1)
+	for claimName, value := range idTokenClaims.Extra {
+		claims[claimName] = value
+	}
+	claims["sub"] = idTokenClaims.Subject
+	return claims
+}
+
+// writeBearerError writes an error response as described in https://datatracker.ietf.org/doc/html/rfc6750#section-3.
+func writeBearerError(w http.ResponseWriter, code int, errorCode string, description string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error=%q, error_description=%q`, errorCode, description))
+	http.Error(w, description, code)
+}
@@ -92,12 +92,22 @@ type OIDCSessionData struct {
 type LDAPSessionData struct {
 	UserDN                 string            `json:"userDN"`
 	ExtraRefreshAttributes map[string]string `json:"extraRefreshAttributes,omitempty"`
+
+	// AdditionalClaims are the values of the additional downstream claims which were mapped from the attributes of
+	// the user's entry during their most recent login or refresh. They are included in the downstream ID tokens
+	// depending on the granted scopes.
+	AdditionalClaims map[string]string `json:"additionalClaims,omitempty"`
 }
 
 // ActiveDirectorySessionData is the additional data needed by Pinniped when the upstream IDP is an Active Directory provider.
 type ActiveDirectorySessionData struct {
 	UserDN                 string            `json:"userDN"`
 	ExtraRefreshAttributes map[string]string `json:"extraRefreshAttributes,omitempty"`
+
+	// AdditionalClaims are the values of the additional downstream claims which were mapped from the attributes of
+	// the user's entry during their most recent login or refresh. They are included in the downstream ID tokens
+	// depending on the granted scopes.
+	AdditionalClaims map[string]string `json:"additionalClaims,omitempty"`
 }
 
 // NewPinnipedSession returns a new empty session.
@@ -1,4 +1,4 @@
-// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2022 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package credentialrequest provides REST functionality for the CredentialRequest resource.
@@ -21,6 +21,7 @@ import (
 	"k8s.io/utils/trace"
 
 	loginapi "go.pinniped.dev/generated/latest/apis/concierge/login"
+	"go.pinniped.dev/internal/concierge/frontendpolicy"
 	"go.pinniped.dev/internal/issuer"
 )
 
@@ -31,10 +32,11 @@ type TokenCredentialRequestAuthenticator interface {
 	AuthenticateTokenCredentialRequest(ctx context.Context, req *loginapi.TokenCredentialRequest) (user.Info, error)
 }
 
-func NewREST(authenticator TokenCredentialRequestAuthenticator, issuer issuer.ClientCertIssuer, resource schema.GroupResource) *REST {
+func NewREST(authenticator TokenCredentialRequestAuthenticator, issuer issuer.ClientCertIssuer, frontendPolicy *frontendpolicy.Policy, resource schema.GroupResource) *REST {
 	return &REST{
 		authenticator:  authenticator,
 		issuer:         issuer,
+		frontendPolicy: frontendPolicy,
 		tableConvertor: rest.NewDefaultTableConvertor(resource),
 	}
 }
@@ -42,6 +44,7 @@ func NewREST(authenticator TokenCredentialRequestAuthenticator, issuer issuer.Cl
 type REST struct {
 	authenticator  TokenCredentialRequestAuthenticator
 	issuer         issuer.ClientCertIssuer
+	frontendPolicy *frontendpolicy.Policy
 	tableConvertor rest.TableConvertor
 }
 
@@ -96,6 +99,12 @@ func (r *REST) Create(ctx context.Context, obj runtime.Object, createValidation
 		return nil, err
 	}
 
+	frontend := frontendpolicy.FrontendFor(ctx)
+	if !r.frontendPolicy.Allowed(frontend, credentialRequest.Spec.Authenticator.Kind, credentialRequest.Spec.Authenticator.Name) {
+		traceValidationFailure(t, fmt.Sprintf("authenticator is not allowed through the %s frontend", frontend))
+		return failureResponse(), nil
+	}
+
 	userInfo, err := r.authenticator.AuthenticateTokenCredentialRequest(ctx, credentialRequest)
 	if err != nil {
 		traceFailureWithError(t, "token authentication", err)
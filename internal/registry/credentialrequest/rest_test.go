@@ -14,6 +14,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/sclevine/spec"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -24,7 +25,9 @@ import (
 	"k8s.io/klog/v2"
 	"k8s.io/utils/pointer"
 
+	configv1alpha1 "go.pinniped.dev/generated/latest/apis/concierge/config/v1alpha1"
 	loginapi "go.pinniped.dev/generated/latest/apis/concierge/login"
+	"go.pinniped.dev/internal/concierge/frontendpolicy"
 	"go.pinniped.dev/internal/issuer"
 	"go.pinniped.dev/internal/mocks/credentialrequestmocks"
 	"go.pinniped.dev/internal/mocks/issuermocks"
@@ -32,7 +35,7 @@ import (
 )
 
 func TestNew(t *testing.T) {
-	r := NewREST(nil, nil, schema.GroupResource{Group: "bears", Resource: "panda"})
+	r := NewREST(nil, nil, frontendpolicy.New(), schema.GroupResource{Group: "bears", Resource: "panda"})
 	require.NotNil(t, r)
 	require.False(t, r.NamespaceScoped())
 	require.Equal(t, []string{"pinniped"}, r.Categories())
@@ -97,7 +100,7 @@ func TestCreate(t *testing.T) {
 				5*time.Minute,
 			).Return([]byte("test-cert"), []byte("test-key"), nil)
 
-			storage := NewREST(requestAuthenticator, clientCertIssuer, schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, clientCertIssuer, frontendpolicy.New(), schema.GroupResource{})
 
 			response, err := callCreate(context.Background(), storage, req)
 
@@ -136,7 +139,7 @@ func TestCreate(t *testing.T) {
 				IssueClientCertPEM(gomock.Any(), gomock.Any(), gomock.Any()).
 				Return(nil, nil, fmt.Errorf("some certificate authority error"))
 
-			storage := NewREST(requestAuthenticator, clientCertIssuer, schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, clientCertIssuer, frontendpolicy.New(), schema.GroupResource{})
 
 			response, err := callCreate(context.Background(), storage, req)
 			requireSuccessfulResponseWithAuthenticationFailureMessage(t, err, response)
@@ -149,7 +152,7 @@ func TestCreate(t *testing.T) {
 			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
 			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).Return(nil, nil)
 
-			storage := NewREST(requestAuthenticator, nil, schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, nil, frontendpolicy.New(), schema.GroupResource{})
 
 			response, err := callCreate(context.Background(), storage, req)
 
@@ -157,6 +160,68 @@ func TestCreate(t *testing.T) {
 			requireOneLogStatement(r, logger, `"success" userID:<none>,hasExtra:false,authenticated:false`)
 		})
 
+		it("CreateFailsWhenTheAuthenticatorIsNotAllowedThroughTheTokenCredentialRequestAPI", func() {
+			req := credentialRequest(loginapi.TokenCredentialRequestSpec{
+				Token:         "some token",
+				Authenticator: corev1.TypedLocalObjectReference{Kind: "JWTAuthenticator", Name: "external"},
+			})
+
+			policy := frontendpolicy.New()
+			policy.Set([]configv1alpha1.CredentialIssuerFrontendSpec{
+				{
+					Type:                  configv1alpha1.TokenCredentialRequestAPIFrontendType,
+					AllowedAuthenticators: []configv1alpha1.AuthenticatorReference{{Kind: "JWTAuthenticator", Name: "internal"}},
+				},
+			})
+
+			// the authenticator is never called
+			storage := NewREST(credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl), nil, policy, schema.GroupResource{})
+
+			response, err := callCreate(context.Background(), storage, req)
+
+			requireSuccessfulResponseWithAuthenticationFailureMessage(t, err, response)
+			requireOneLogStatement(r, logger, `"failure" failureType:request validation,msg:authenticator is not allowed through the TokenCredentialRequestAPI frontend`)
+		})
+
+		it("CreateSucceedsWhenTheAuthenticatorIsAllowedThroughTheImpersonationProxy", func() {
+			req := credentialRequest(loginapi.TokenCredentialRequestSpec{
+				Token:         "some token",
+				Authenticator: corev1.TypedLocalObjectReference{Kind: "JWTAuthenticator", Name: "external"},
+			})
+
+			policy := frontendpolicy.New()
+			policy.Set([]configv1alpha1.CredentialIssuerFrontendSpec{
+				{
+					Type:                  configv1alpha1.TokenCredentialRequestAPIFrontendType,
+					AllowedAuthenticators: []configv1alpha1.AuthenticatorReference{{Kind: "JWTAuthenticator", Name: "internal"}},
+				},
+				{
+					Type:                  configv1alpha1.ImpersonationProxyFrontendType,
+					AllowedAuthenticators: []configv1alpha1.AuthenticatorReference{{Kind: "JWTAuthenticator", Name: "external"}},
+				},
+			})
+
+			requestAuthenticator := credentialrequestmocks.NewMockTokenCredentialRequestAuthenticator(ctrl)
+			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
+				Return(&user.DefaultInfo{Name: "test-user"}, nil)
+
+			clientCertIssuer := issuermocks.NewMockClientCertIssuer(ctrl)
+			clientCertIssuer.EXPECT().IssueClientCertPEM("test-user", nil, 5*time.Minute).
+				Return([]byte("test-cert"), []byte("test-key"), nil)
+
+			storage := NewREST(requestAuthenticator, clientCertIssuer, policy, schema.GroupResource{})
+
+			ctx := genericapirequest.WithUser(context.Background(), &user.DefaultInfo{
+				Name:  "system:anonymous",
+				Extra: map[string][]string{frontendpolicy.ImpersonationProxyExtraKey: {"ImpersonationProxy"}},
+			})
+			response, err := callCreate(ctx, storage, req)
+
+			r.NoError(err)
+			r.Equal("test-cert", response.(*loginapi.TokenCredentialRequest).Status.Credential.ClientCertificateData)
+			requireOneLogStatement(r, logger, `"success" userID:,hasExtra:false,authenticated:true`)
+		})
+
 		it("CreateSucceedsWithAnUnauthenticatedStatusWhenWebhookFails", func() {
 			req := validCredentialRequest()
 
@@ -164,7 +229,7 @@ func TestCreate(t *testing.T) {
 			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
 				Return(nil, errors.New("some webhook error"))
 
-			storage := NewREST(requestAuthenticator, nil, schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, nil, frontendpolicy.New(), schema.GroupResource{})
 
 			response, err := callCreate(context.Background(), storage, req)
 
@@ -179,7 +244,7 @@ func TestCreate(t *testing.T) {
 			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req).
 				Return(&user.DefaultInfo{Name: ""}, nil)
 
-			storage := NewREST(requestAuthenticator, nil, schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, nil, frontendpolicy.New(), schema.GroupResource{})
 
 			response, err := callCreate(context.Background(), storage, req)
 
@@ -198,7 +263,7 @@ func TestCreate(t *testing.T) {
 					Groups: []string{"test-group-1", "test-group-2"},
 				}, nil)
 
-			storage := NewREST(requestAuthenticator, nil, schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, nil, frontendpolicy.New(), schema.GroupResource{})
 
 			response, err := callCreate(context.Background(), storage, req)
 
@@ -217,7 +282,7 @@ func TestCreate(t *testing.T) {
 					Extra:  map[string][]string{"test-key": {"test-val-1", "test-val-2"}},
 				}, nil)
 
-			storage := NewREST(requestAuthenticator, nil, schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, nil, frontendpolicy.New(), schema.GroupResource{})
 
 			response, err := callCreate(context.Background(), storage, req)
 
@@ -227,7 +292,7 @@ func TestCreate(t *testing.T) {
 
 		it("CreateFailsWhenGivenTheWrongInputType", func() {
 			notACredentialRequest := runtime.Unknown{}
-			response, err := NewREST(nil, nil, schema.GroupResource{}).Create(
+			response, err := NewREST(nil, nil, frontendpolicy.New(), schema.GroupResource{}).Create(
 				genericapirequest.NewContext(),
 				&notACredentialRequest,
 				rest.ValidateAllObjectFunc,
@@ -238,7 +303,7 @@ func TestCreate(t *testing.T) {
 		})
 
 		it("CreateFailsWhenTokenValueIsEmptyInRequest", func() {
-			storage := NewREST(nil, nil, schema.GroupResource{})
+			storage := NewREST(nil, nil, frontendpolicy.New(), schema.GroupResource{})
 			response, err := callCreate(context.Background(), storage, credentialRequest(loginapi.TokenCredentialRequestSpec{
 				Token: "",
 			}))
@@ -249,7 +314,7 @@ func TestCreate(t *testing.T) {
 		})
 
 		it("CreateFailsWhenValidationFails", func() {
-			storage := NewREST(nil, nil, schema.GroupResource{})
+			storage := NewREST(nil, nil, frontendpolicy.New(), schema.GroupResource{})
 			response, err := storage.Create(
 				context.Background(),
 				validCredentialRequest(),
@@ -269,7 +334,7 @@ func TestCreate(t *testing.T) {
 			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req.DeepCopy()).
 				Return(&user.DefaultInfo{Name: "test-user"}, nil)
 
-			storage := NewREST(requestAuthenticator, successfulIssuer(ctrl), schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, successfulIssuer(ctrl), frontendpolicy.New(), schema.GroupResource{})
 			response, err := storage.Create(
 				context.Background(),
 				req,
@@ -290,7 +355,7 @@ func TestCreate(t *testing.T) {
 			requestAuthenticator.EXPECT().AuthenticateTokenCredentialRequest(gomock.Any(), req.DeepCopy()).
 				Return(&user.DefaultInfo{Name: "test-user"}, nil)
 
-			storage := NewREST(requestAuthenticator, successfulIssuer(ctrl), schema.GroupResource{})
+			storage := NewREST(requestAuthenticator, successfulIssuer(ctrl), frontendpolicy.New(), schema.GroupResource{})
 			validationFunctionWasCalled := false
 			var validationFunctionSawTokenValue string
 			response, err := storage.Create(
@@ -310,7 +375,7 @@ func TestCreate(t *testing.T) {
 		})
 
 		it("CreateFailsWhenRequestOptionsDryRunIsNotEmpty", func() {
-			response, err := NewREST(nil, nil, schema.GroupResource{}).Create(
+			response, err := NewREST(nil, nil, frontendpolicy.New(), schema.GroupResource{}).Create(
 				genericapirequest.NewContext(),
 				validCredentialRequest(),
 				rest.ValidateAllObjectFunc,
@@ -324,7 +389,7 @@ func TestCreate(t *testing.T) {
 		})
 
 		it("CreateFailsWhenNamespaceIsNotEmpty", func() {
-			response, err := NewREST(nil, nil, schema.GroupResource{}).Create(
+			response, err := NewREST(nil, nil, frontendpolicy.New(), schema.GroupResource{}).Create(
 				genericapirequest.WithNamespace(genericapirequest.NewContext(), "some-ns"),
 				validCredentialRequest(),
 				rest.ValidateAllObjectFunc,
@@ -28,6 +28,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/pkg/version"
 	"k8s.io/client-go/rest"
+	"k8s.io/component-base/metrics/legacyregistry"
 	"k8s.io/utils/clock"
 
 	configv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
@@ -146,6 +147,7 @@ func prepareControllers(
 				clock.RealClock{},
 				pinnipedClient,
 				federationDomainInformer,
+				secretInformer,
 				controllerlib.WithInformer,
 			),
 			singletonWorker,
@@ -153,6 +155,7 @@ func prepareControllers(
 		WithController(
 			supervisorconfig.NewJWKSWriterController(
 				cfg.Labels,
+				clock.RealClock{},
 				kubeClient,
 				pinnipedClient,
 				secretInformer,
@@ -462,6 +465,26 @@ func runSupervisor(ctx context.Context, podInfo *downward.PodInfo, cfg *supervis
 		plog.Debug("supervisor https listener started", "address", httpsListener.Addr().String())
 	}
 
+	if e := cfg.Endpoints.Metrics; e.Network != supervisor.NetworkDisabled {
+		finishSetupPerms := maybeSetupUnixPerms(e, supervisorPod)
+
+		metricsListener, err := net.Listen(e.Network, e.Address)
+		if err != nil {
+			return fmt.Errorf("cannot create metrics listener with network %q and address %q: %w", e.Network, e.Address, err)
+		}
+
+		if err := finishSetupPerms(); err != nil {
+			return fmt.Errorf("cannot setup metrics listener permissions for network %q and address %q: %w", e.Network, e.Address, err)
+		}
+
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", legacyregistry.Handler())
+
+		defer func() { _ = metricsListener.Close() }()
+		startServer(ctx, shutdown, metricsListener, metricsMux)
+		plog.Debug("supervisor metrics listener started", "address", metricsListener.Addr().String())
+	}
+
 	plog.Debug("supervisor started")
 	defer plog.Debug("supervisor exiting")
 
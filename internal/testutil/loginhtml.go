@@ -10,6 +10,30 @@ import (
 )
 
 func ExpectedLoginPageHTML(wantCSS, wantIDPName, wantPostPath, wantEncodedState, wantAlert string) string {
+	return expectedLoginPageHTML(wantCSS, wantIDPName, wantPostPath, wantEncodedState, wantAlert, false)
+}
+
+func ExpectedChangePasswordPageHTML(wantCSS, wantIDPName, wantPostPath, wantEncodedState, wantAlert string) string {
+	return expectedLoginPageHTML(wantCSS, wantIDPName, wantPostPath, wantEncodedState, wantAlert, true)
+}
+
+func expectedLoginPageHTML(wantCSS, wantIDPName, wantPostPath, wantEncodedState, wantAlert string, changePassword bool) string {
+	passwordPlaceholder, submitValue, newPasswordHTML := "Password", "Log in", ""
+	if changePassword {
+		passwordPlaceholder, submitValue = "Current password", "Change password"
+		newPasswordHTML = "\n" +
+			"        <div class=\"form-field\">\n" +
+			"            <label for=\"new_password\"><span class=\"hidden\" aria-hidden=\"true\">New password</span></label>\n" +
+			"            <input type=\"password\" name=\"new_password\" id=\"new_password\"\n" +
+			"                   autocomplete=\"new-password\" placeholder=\"New password\" required>\n" +
+			"        </div>\n" +
+			"        <div class=\"form-field\">\n" +
+			"            <label for=\"confirm_new_password\"><span class=\"hidden\" aria-hidden=\"true\">Confirm new password</span></label>\n" +
+			"            <input type=\"password\" name=\"confirm_new_password\" id=\"confirm_new_password\"\n" +
+			"                   autocomplete=\"new-password\" placeholder=\"Confirm new password\" required>\n" +
+			"        </div>"
+	}
+
 	alertHTML := ""
 	if wantAlert != "" {
 		alertHTML = fmt.Sprintf("\n"+
@@ -45,10 +69,10 @@ func ExpectedLoginPageHTML(wantCSS, wantIDPName, wantPostPath, wantEncodedState,
                 <div class="form-field">
                     <label for="password"><span class="hidden" aria-hidden="true">Password</span></label>
                     <input type="password" name="password" id="password"
-                           autocomplete="current-password" placeholder="Password" required>
-                </div>
+                           autocomplete="current-password" placeholder="%s" required>
+                </div>%s
                 <div class="form-field">
-                    <input type="submit" name="submit" id="submit" value="Log in"/>
+                    <input type="submit" name="submit" id="submit" value="%s"/>
                 </div>
             </form>
         </div>
@@ -60,5 +84,8 @@ func ExpectedLoginPageHTML(wantCSS, wantIDPName, wantPostPath, wantEncodedState,
 		alertHTML,
 		wantPostPath,
 		wantEncodedState,
+		passwordPlaceholder,
+		newPasswordHTML,
+		submitValue,
 	)
 }
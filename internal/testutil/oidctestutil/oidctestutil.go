@@ -95,14 +95,18 @@ type ValidateRefreshArgs struct {
 }
 
 type TestUpstreamLDAPIdentityProvider struct {
-	Name                    string
-	ResourceUID             types.UID
-	URL                     *url.URL
-	AuthenticateFunc        func(ctx context.Context, username, password string) (*authenticators.Response, bool, error)
-	performRefreshCallCount int
-	performRefreshArgs      []*PerformRefreshArgs
-	PerformRefreshErr       error
-	PerformRefreshGroups    []string
+	Name                           string
+	ResourceUID                    types.UID
+	URL                            *url.URL
+	AuthenticateFunc               func(ctx context.Context, username, password string) (*authenticators.Response, bool, error)
+	ChangePasswordFunc             func(ctx context.Context, username, currentPassword, newPassword string) (bool, error)
+	AuthenticateKerberosTicketFunc func(ctx context.Context, negotiateToken []byte) (*authenticators.Response, bool, error)
+	performRefreshCallCount        int
+	performRefreshArgs             []*PerformRefreshArgs
+	PerformRefreshErr              error
+	PerformRefreshGroups           []string
+	PerformRefreshAdditionalClaims map[string]string
+	PerformRefreshWarnings         []string
 }
 
 var _ provider.UpstreamLDAPIdentityProviderI = &TestUpstreamLDAPIdentityProvider{}
@@ -123,7 +127,19 @@ func (u *TestUpstreamLDAPIdentityProvider) GetURL() *url.URL {
 	return u.URL
 }
 
-func (u *TestUpstreamLDAPIdentityProvider) PerformRefresh(ctx context.Context, storedRefreshAttributes provider.StoredRefreshAttributes) ([]string, error) {
+func (u *TestUpstreamLDAPIdentityProvider) ChangePassword(ctx context.Context, username, currentPassword, newPassword string) (bool, error) {
+	return u.ChangePasswordFunc(ctx, username, currentPassword, newPassword)
+}
+
+func (u *TestUpstreamLDAPIdentityProvider) KerberosEnabled() bool {
+	return u.AuthenticateKerberosTicketFunc != nil
+}
+
+func (u *TestUpstreamLDAPIdentityProvider) AuthenticateKerberosTicket(ctx context.Context, negotiateToken []byte) (*authenticators.Response, bool, error) {
+	return u.AuthenticateKerberosTicketFunc(ctx, negotiateToken)
+}
+
+func (u *TestUpstreamLDAPIdentityProvider) PerformRefresh(ctx context.Context, storedRefreshAttributes provider.StoredRefreshAttributes) ([]string, map[string]string, []string, error) {
 	if u.performRefreshArgs == nil {
 		u.performRefreshArgs = make([]*PerformRefreshArgs, 0)
 	}
@@ -135,9 +151,9 @@ func (u *TestUpstreamLDAPIdentityProvider) PerformRefresh(ctx context.Context, s
 		ExpectedSubject:  storedRefreshAttributes.Subject,
 	})
 	if u.PerformRefreshErr != nil {
-		return nil, u.PerformRefreshErr
+		return nil, nil, nil, u.PerformRefreshErr
 	}
-	return u.PerformRefreshGroups, nil
+	return u.PerformRefreshGroups, u.PerformRefreshAdditionalClaims, u.PerformRefreshWarnings, nil
 }
 
 func (u *TestUpstreamLDAPIdentityProvider) PerformRefreshCallCount() int {
@@ -158,6 +174,7 @@ type TestUpstreamOIDCIdentityProvider struct {
 	AuthorizationURL         url.URL
 	UserInfoURL              bool
 	RevocationURL            *url.URL
+	EndSessionURL            *url.URL
 	UsernameClaim            string
 	GroupsClaim              string
 	Scopes                   []string
@@ -225,6 +242,10 @@ func (u *TestUpstreamOIDCIdentityProvider) GetRevocationURL() *url.URL {
 	return u.RevocationURL
 }
 
+func (u *TestUpstreamOIDCIdentityProvider) GetEndSessionURL() *url.URL {
+	return u.EndSessionURL
+}
+
 func (u *TestUpstreamOIDCIdentityProvider) GetScopes() []string {
 	return u.Scopes
 }
@@ -627,6 +648,7 @@ type TestUpstreamOIDCIdentityProviderBuilder struct {
 	validatedAndMergedWithUserInfoTokens *oidctypes.Token
 	authorizationURL                     url.URL
 	hasUserInfoURL                       bool
+	endSessionURL                        *url.URL
 	additionalAuthcodeParams             map[string]string
 	allowPasswordGrant                   bool
 	authcodeExchangeErr                  error
@@ -666,6 +688,11 @@ func (u *TestUpstreamOIDCIdentityProviderBuilder) WithoutUserInfoURL() *TestUpst
 	return u
 }
 
+func (u *TestUpstreamOIDCIdentityProviderBuilder) WithEndSessionURL(value *url.URL) *TestUpstreamOIDCIdentityProviderBuilder {
+	u.endSessionURL = value
+	return u
+}
+
 func (u *TestUpstreamOIDCIdentityProviderBuilder) WithAllowPasswordGrant(value bool) *TestUpstreamOIDCIdentityProviderBuilder {
 	u.allowPasswordGrant = value
 	return u
@@ -789,6 +816,7 @@ func (u *TestUpstreamOIDCIdentityProviderBuilder) Build() *TestUpstreamOIDCIdent
 		AllowPasswordGrant:       u.allowPasswordGrant,
 		AuthorizationURL:         u.authorizationURL,
 		UserInfoURL:              u.hasUserInfoURL,
+		EndSessionURL:            u.endSessionURL,
 		AdditionalAuthcodeParams: u.additionalAuthcodeParams,
 		ExchangeAuthcodeAndValidateTokensFunc: func(ctx context.Context, authcode string, pkceCodeVerifier pkce.Code, expectedIDTokenNonce nonce.Nonce) (*oidctypes.Token, error) {
 			if u.authcodeExchangeErr != nil {
@@ -1063,7 +1091,8 @@ func validateAuthcodeStorage(
 	// Check the user's identity, which are put into the downstream ID token's subject, username and groups claims.
 	require.Equal(t, wantDownstreamIDTokenSubject, actualClaims.Subject)
 	require.Equal(t, wantDownstreamIDTokenUsername, actualClaims.Extra["username"])
-	require.Len(t, actualClaims.Extra, 2)
+	require.Equal(t, storedRequestFromAuthcode.ID, actualClaims.Extra["sid"])
+	require.Len(t, actualClaims.Extra, 3)
 	actualDownstreamIDTokenGroups := actualClaims.Extra["groups"]
 	require.NotNil(t, actualDownstreamIDTokenGroups)
 	require.ElementsMatch(t, wantDownstreamIDTokenGroups, actualDownstreamIDTokenGroups)
@@ -0,0 +1,275 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"go.pinniped.dev/internal/authenticators"
+)
+
+const (
+	// defaultDisabledValue is the value of the DisabledAttribute which means that an account is disabled,
+	// when no other values are configured.
+	defaultDisabledValue = "TRUE"
+
+	// pwdAccountLockedTimeAttribute is the operational attribute which is set on a user's entry by LDAP servers which
+	// implement draft-behera-ldap-password-policy when the account is locked.
+	pwdAccountLockedTimeAttribute = "pwdAccountLockedTime"
+
+	// windowsFileTimeNeverExpires is the value of a Windows FILETIME attribute which means that it never expires.
+	windowsFileTimeNeverExpires = math.MaxInt64
+
+	// windowsFileTimeUnixEpoch is the Unix epoch as a Windows FILETIME, i.e. the number of 100 nanosecond intervals
+	// between January 1, 1601 UTC and January 1, 1970 UTC.
+	windowsFileTimeUnixEpoch = 116444736000000000
+)
+
+// TimestampFormat is the format of the values of the attributes which contain timestamps.
+type TimestampFormat string
+
+const (
+	// GeneralizedTime is the LDAP Generalized Time syntax, e.g. "20220102150405Z".
+	GeneralizedTime = TimestampFormat("")
+
+	// WindowsFileTime is the number of 100 nanosecond intervals since January 1, 1601 UTC, as used by Active Directory.
+	WindowsFileTime = TimestampFormat("WindowsFileTime")
+)
+
+// AccountStatusConfig contains information about how to decide if a user's account may be used to log in, and
+// when to warn the user that their password will expire soon. The zero value does not check anything.
+type AccountStatusConfig struct {
+	// DisabledAttribute is the attribute in the user's entry which marks the account as disabled when it has
+	// one of the DisabledValues. Empty means to not check if accounts are disabled.
+	DisabledAttribute string
+
+	// DisabledValues are the case-insensitive values of the DisabledAttribute which mean that the account is
+	// disabled. Empty means to use "TRUE".
+	DisabledValues []string
+
+	// AccountExpirationAttribute is the attribute in the user's entry which contains the time at which the account
+	// expires. Empty means to not check if accounts are expired.
+	AccountExpirationAttribute string
+
+	// PasswordExpirationAttribute is the attribute in the user's entry which contains the time at which the
+	// password expires. Empty means to not check if passwords are expired.
+	PasswordExpirationAttribute string
+
+	// PasswordExpirationAttributeIsConstructed, when true, means that the PasswordExpirationAttribute is a
+	// constructed attribute, like Active Directory's "msDS-UserPasswordExpiryTimeComputed", which is only returned
+	// by searches whose scope is the user's entry itself. It is then read by an additional search during logins.
+	PasswordExpirationAttributeIsConstructed bool
+
+	// PasswordExpirationWarningPeriod is how long before the password expires the user will be warned about it.
+	// Zero means to never warn.
+	PasswordExpirationWarningPeriod time.Duration
+
+	// PasswordPolicyControl, when true, requests the draft-behera-ldap-password-policy response control when
+	// binding as the end user, and treats accounts with a pwdAccountLockedTime attribute as locked.
+	PasswordPolicyControl bool
+
+	// TimestampFormat is the format of the AccountExpirationAttribute and PasswordExpirationAttribute values.
+	TimestampFormat TimestampFormat
+}
+
+func (c *AccountStatusConfig) attributes() []string {
+	var attributes []string
+	for _, attribute := range []string{c.DisabledAttribute, c.AccountExpirationAttribute, c.PasswordExpirationAttribute} {
+		if attribute != "" {
+			attributes = append(attributes, attribute)
+		}
+	}
+	if c.PasswordPolicyControl {
+		attributes = append(attributes, pwdAccountLockedTimeAttribute)
+	}
+	return attributes
+}
+
+// searchForConstructedAccountStatusAttributes reads the constructed attributes which are used to check the account
+// status from the user's entry using a base object search, and adds them to the entry which was found by the user
+// search, because constructed attributes are not returned by subtree searches.
+func (p *Provider) searchForConstructedAccountStatusAttributes(conn Conn, userEntry *ldap.Entry) error {
+	c := p.c.AccountStatus
+	if !c.PasswordExpirationAttributeIsConstructed || c.PasswordExpirationAttribute == "" {
+		return nil
+	}
+
+	searchResult, err := conn.Search(&ldap.SearchRequest{
+		BaseDN:       userEntry.DN,
+		Scope:        ldap.ScopeBaseObject,
+		DerefAliases: ldap.NeverDerefAliases,
+		SizeLimit:    2,
+		TimeLimit:    90,
+		TypesOnly:    false,
+		Filter:       "(objectClass=*)", // we already have the dn, so the filter doesn't matter
+		Attributes:   []string{c.PasswordExpirationAttribute},
+	})
+	if err != nil {
+		return fmt.Errorf(`error searching for attribute %q of user %q: %w`, c.PasswordExpirationAttribute, userEntry.DN, err)
+	}
+	if len(searchResult.Entries) != 1 {
+		return fmt.Errorf(`searching for attribute %q of user %q resulted in %d search results, but expected 1 result`,
+			c.PasswordExpirationAttribute, userEntry.DN, len(searchResult.Entries),
+		)
+	}
+
+	attributes := make([]*ldap.EntryAttribute, 0, len(userEntry.Attributes)+1)
+	for _, attribute := range userEntry.Attributes {
+		if !strings.EqualFold(attribute.Name, c.PasswordExpirationAttribute) {
+			attributes = append(attributes, attribute)
+		}
+	}
+	for _, attribute := range searchResult.Entries[0].Attributes {
+		if strings.EqualFold(attribute.Name, c.PasswordExpirationAttribute) {
+			attributes = append(attributes, attribute)
+		}
+	}
+	userEntry.Attributes = attributes
+	return nil
+}
+
+// checkAccountStatus returns an error when the attributes of the user's entry show that the account may not be
+// used to log in. Otherwise, it returns any warnings which should be shown to the user.
+func (p *Provider) checkAccountStatus(entry *ldap.Entry) ([]string, error) {
+	c := p.c.AccountStatus
+	now := p.clock.Now()
+
+	if c.DisabledAttribute != "" {
+		disabledValues := c.DisabledValues
+		if len(disabledValues) == 0 {
+			disabledValues = []string{defaultDisabledValue}
+		}
+		for _, value := range entry.GetAttributeValues(c.DisabledAttribute) {
+			for _, disabledValue := range disabledValues {
+				if strings.EqualFold(value, disabledValue) {
+					return nil, fmt.Errorf("account is disabled: attribute %q has value %q", c.DisabledAttribute, value)
+				}
+			}
+		}
+	}
+
+	if c.PasswordPolicyControl && entry.GetAttributeValue(pwdAccountLockedTimeAttribute) != "" {
+		return nil, fmt.Errorf("account is locked: attribute %q is set", pwdAccountLockedTimeAttribute)
+	}
+
+	if c.AccountExpirationAttribute != "" {
+		expiration, err := p.timestampAttribute(c.AccountExpirationAttribute, entry)
+		if err != nil {
+			return nil, err
+		}
+		if !expiration.IsZero() && !now.Before(expiration) {
+			return nil, fmt.Errorf("account has expired at %s", expiration.UTC().Format(time.RFC3339))
+		}
+	}
+
+	var warnings []string
+	if c.PasswordExpirationAttribute != "" {
+		expiration, err := p.timestampAttribute(c.PasswordExpirationAttribute, entry)
+		if err != nil {
+			return nil, err
+		}
+		if !expiration.IsZero() {
+			remaining := expiration.Sub(now)
+			if remaining <= 0 {
+				return nil, fmt.Errorf("password has expired at %s", expiration.UTC().Format(time.RFC3339))
+			}
+			if remaining <= c.PasswordExpirationWarningPeriod {
+				warnings = append(warnings, passwordExpirationWarning(remaining))
+			}
+		}
+	}
+
+	return warnings, nil
+}
+
+// checkPasswordPolicyControl returns an error when the password policy response control from a successful bind
+// shows that the account may not be used to log in, which wraps authenticators.ErrPasswordChangeRequired when the
+// user may log in after changing their password. Otherwise, it returns any warnings which should be shown
+// to the user.
+func checkPasswordPolicyControl(controls []ldap.Control) ([]string, error) {
+	ppolicy, ok := ldap.FindControl(controls, ldap.ControlTypeBeheraPasswordPolicy).(*ldap.ControlBeheraPasswordPolicy)
+	if !ok {
+		return nil, nil
+	}
+
+	if ppolicy.Error == ldap.BeheraChangeAfterReset {
+		// The bind succeeded, but the server will not allow anything other than changing the password.
+		return nil, authenticators.ErrPasswordChangeRequired
+	}
+	if ppolicy.Error >= 0 {
+		// E.g. the password was reset by an administrator and must be changed before the account can be used.
+		return nil, fmt.Errorf("password policy error: %s", ppolicy.ErrorString)
+	}
+
+	var warnings []string
+	if ppolicy.Expire >= 0 {
+		warnings = append(warnings, passwordExpirationWarning(time.Duration(ppolicy.Expire)*time.Second))
+	}
+	if ppolicy.Grace >= 0 {
+		warnings = append(warnings, fmt.Sprintf("Your password has expired. You have %d grace logins remaining. Please change your password.", ppolicy.Grace))
+	}
+	return warnings, nil
+}
+
+func passwordExpirationWarning(remaining time.Duration) string {
+	switch days := int(remaining.Hours() / 24); days {
+	case 0:
+		return "Your password will expire in less than a day. Please change your password."
+	case 1:
+		return "Your password will expire in 1 day. Please change your password."
+	default:
+		return fmt.Sprintf("Your password will expire in %d days. Please change your password.", days)
+	}
+}
+
+// timestampAttribute returns the time in the given attribute of the entry, or the zero time when the attribute
+// is not set or means that it never expires.
+func (p *Provider) timestampAttribute(attributeName string, entry *ldap.Entry) (time.Time, error) {
+	value := entry.GetAttributeValue(attributeName)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	var timestamp time.Time
+	var err error
+	switch p.c.AccountStatus.TimestampFormat {
+	case WindowsFileTime:
+		timestamp, err = parseWindowsFileTime(value)
+	default:
+		timestamp, err = parseGeneralizedTime(value)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse value %q of attribute %q: %w", value, attributeName, err)
+	}
+	return timestamp, nil
+}
+
+// parseGeneralizedTime parses the common forms of the LDAP Generalized Time syntax from RFC 4517 section 3.3.13.
+func parseGeneralizedTime(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102150405Z0700", "20060102150405.999999999Z0700", "200601021504Z0700"} {
+		if timestamp, err := time.Parse(layout, value); err == nil {
+			return timestamp, nil
+		}
+	}
+	return time.Time{}, errors.New("not a valid generalized time")
+}
+
+func parseWindowsFileTime(value string) (time.Time, error) {
+	fileTime, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("not a valid Windows file time")
+	}
+	if fileTime == windowsFileTimeNeverExpires {
+		return time.Time{}, nil
+	}
+	sinceUnixEpoch := fileTime - windowsFileTimeUnixEpoch
+	return time.Unix(sinceUnixEpoch/1e7, (sinceUnixEpoch%1e7)*100), nil
+}
@@ -0,0 +1,397 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"go.pinniped.dev/internal/authenticators"
+	"go.pinniped.dev/internal/endpointaddr"
+	"go.pinniped.dev/internal/mocks/mockldapconn"
+	"go.pinniped.dev/internal/oidc/provider"
+)
+
+func TestCheckAccountStatus(t *testing.T) {
+	now := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	entry := func(attributes map[string]string) *ldap.Entry {
+		e := &ldap.Entry{DN: testUserSearchResultDNValue}
+		for name, value := range attributes {
+			e.Attributes = append(e.Attributes, ldap.NewEntryAttribute(name, []string{value}))
+		}
+		return e
+	}
+
+	tests := []struct {
+		name         string
+		config       AccountStatusConfig
+		entry        *ldap.Entry
+		wantWarnings []string
+		wantErr      string
+	}{
+		{
+			name:   "nothing is checked when nothing is configured",
+			config: AccountStatusConfig{},
+			entry:  entry(map[string]string{"nsAccountLock": "TRUE", pwdAccountLockedTimeAttribute: "000001010000Z"}),
+		},
+		{
+			name:    "disabled with the default disabled value",
+			config:  AccountStatusConfig{DisabledAttribute: "nsAccountLock"},
+			entry:   entry(map[string]string{"nsAccountLock": "true"}),
+			wantErr: `account is disabled: attribute "nsAccountLock" has value "true"`,
+		},
+		{
+			name:   "not disabled with the default disabled value",
+			config: AccountStatusConfig{DisabledAttribute: "nsAccountLock"},
+			entry:  entry(map[string]string{"nsAccountLock": "FALSE"}),
+		},
+		{
+			name:    "disabled with a configured disabled value",
+			config:  AccountStatusConfig{DisabledAttribute: "accountStatus", DisabledValues: []string{"inactive", "locked"}},
+			entry:   entry(map[string]string{"accountStatus": "Locked"}),
+			wantErr: `account is disabled: attribute "accountStatus" has value "Locked"`,
+		},
+		{
+			name:   "disabled attribute is not set",
+			config: AccountStatusConfig{DisabledAttribute: "nsAccountLock"},
+			entry:  entry(nil),
+		},
+		{
+			name:    "locked by the password policy",
+			config:  AccountStatusConfig{PasswordPolicyControl: true},
+			entry:   entry(map[string]string{pwdAccountLockedTimeAttribute: "20220304050607Z"}),
+			wantErr: `account is locked: attribute "pwdAccountLockedTime" is set`,
+		},
+		{
+			name:    "account has expired",
+			config:  AccountStatusConfig{AccountExpirationAttribute: "krbPrincipalExpiration"},
+			entry:   entry(map[string]string{"krbPrincipalExpiration": "20220304050607Z"}),
+			wantErr: `account has expired at 2022-03-04T05:06:07Z`,
+		},
+		{
+			name:   "account has not expired yet",
+			config: AccountStatusConfig{AccountExpirationAttribute: "krbPrincipalExpiration"},
+			entry:  entry(map[string]string{"krbPrincipalExpiration": "20220304070607+0100"}),
+		},
+		{
+			name:    "account expiration cannot be parsed",
+			config:  AccountStatusConfig{AccountExpirationAttribute: "krbPrincipalExpiration"},
+			entry:   entry(map[string]string{"krbPrincipalExpiration": "tomorrow"}),
+			wantErr: `could not parse value "tomorrow" of attribute "krbPrincipalExpiration": not a valid generalized time`,
+		},
+		{
+			name:    "password has expired",
+			config:  AccountStatusConfig{PasswordExpirationAttribute: "krbPasswordExpiration", PasswordExpirationWarningPeriod: 72 * time.Hour},
+			entry:   entry(map[string]string{"krbPasswordExpiration": "20220304050606.5Z"}),
+			wantErr: `password has expired at 2022-03-04T05:06:06Z`,
+		},
+		{
+			name:         "password will expire within the warning period",
+			config:       AccountStatusConfig{PasswordExpirationAttribute: "krbPasswordExpiration", PasswordExpirationWarningPeriod: 72 * time.Hour},
+			entry:        entry(map[string]string{"krbPasswordExpiration": "20220306050607Z"}),
+			wantWarnings: []string{"Your password will expire in 2 days. Please change your password."},
+		},
+		{
+			name:         "password will expire in less than a day",
+			config:       AccountStatusConfig{PasswordExpirationAttribute: "krbPasswordExpiration", PasswordExpirationWarningPeriod: 72 * time.Hour},
+			entry:        entry(map[string]string{"krbPasswordExpiration": "202203041506Z"}),
+			wantWarnings: []string{"Your password will expire in less than a day. Please change your password."},
+		},
+		{
+			name:   "password will expire after the warning period",
+			config: AccountStatusConfig{PasswordExpirationAttribute: "krbPasswordExpiration", PasswordExpirationWarningPeriod: 72 * time.Hour},
+			entry:  entry(map[string]string{"krbPasswordExpiration": "20220307050608Z"}),
+		},
+		{
+			name: "password will expire within the warning period using Windows file time",
+			config: AccountStatusConfig{
+				PasswordExpirationAttribute:     "msDS-UserPasswordExpiryTimeComputed",
+				PasswordExpirationWarningPeriod: 14 * 24 * time.Hour,
+				TimestampFormat:                 WindowsFileTime,
+			},
+			entry:        entry(map[string]string{"msDS-UserPasswordExpiryTimeComputed": "132909696000000000"}), // 2022-03-05T16:00:00Z
+			wantWarnings: []string{"Your password will expire in 1 day. Please change your password."},
+		},
+		{
+			name: "password never expires using Windows file time",
+			config: AccountStatusConfig{
+				PasswordExpirationAttribute:     "msDS-UserPasswordExpiryTimeComputed",
+				PasswordExpirationWarningPeriod: 14 * 24 * time.Hour,
+				TimestampFormat:                 WindowsFileTime,
+			},
+			entry: entry(map[string]string{"msDS-UserPasswordExpiryTimeComputed": "9223372036854775807"}),
+		},
+		{
+			name: "password has expired using Windows file time",
+			config: AccountStatusConfig{
+				PasswordExpirationAttribute:     "msDS-UserPasswordExpiryTimeComputed",
+				PasswordExpirationWarningPeriod: 14 * 24 * time.Hour,
+				TimestampFormat:                 WindowsFileTime,
+			},
+			entry:   entry(map[string]string{"msDS-UserPasswordExpiryTimeComputed": "0"}),
+			wantErr: `password has expired at 1601-01-01T00:00:00Z`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(ProviderConfig{AccountStatus: tt.config})
+			p.clock = clocktesting.NewFakeClock(now)
+
+			warnings, err := p.checkAccountStatus(tt.entry)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, warnings)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantWarnings, warnings)
+		})
+	}
+}
+
+func TestCheckPasswordPolicyControl(t *testing.T) {
+	ppolicy := func(editFunc func(c *ldap.ControlBeheraPasswordPolicy)) []ldap.Control {
+		c := ldap.NewControlBeheraPasswordPolicy()
+		editFunc(c)
+		return []ldap.Control{ldap.NewControlManageDsaIT(false), c}
+	}
+
+	tests := []struct {
+		name         string
+		controls     []ldap.Control
+		wantWarnings []string
+		wantErr      string
+	}{
+		{
+			name: "no controls",
+		},
+		{
+			name:     "no warnings or errors",
+			controls: ppolicy(func(c *ldap.ControlBeheraPasswordPolicy) {}),
+		},
+		{
+			name:         "password will expire soon",
+			controls:     ppolicy(func(c *ldap.ControlBeheraPasswordPolicy) { c.Expire = 5 * 24 * 60 * 60 }),
+			wantWarnings: []string{"Your password will expire in 5 days. Please change your password."},
+		},
+		{
+			name:         "grace login",
+			controls:     ppolicy(func(c *ldap.ControlBeheraPasswordPolicy) { c.Grace = 2 }),
+			wantWarnings: []string{"Your password has expired. You have 2 grace logins remaining. Please change your password."},
+		},
+		{
+			name: "password must be changed",
+			controls: ppolicy(func(c *ldap.ControlBeheraPasswordPolicy) {
+				c.Error = ldap.BeheraChangeAfterReset
+				c.ErrorString = ldap.BeheraPasswordPolicyErrorMap[ldap.BeheraChangeAfterReset]
+			}),
+			wantErr: "password change required",
+		},
+		{
+			name: "account is locked",
+			controls: ppolicy(func(c *ldap.ControlBeheraPasswordPolicy) {
+				c.Error = ldap.BeheraAccountLocked
+				c.ErrorString = ldap.BeheraPasswordPolicyErrorMap[ldap.BeheraAccountLocked]
+			}),
+			wantErr: "password policy error: Account locked",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := checkPasswordPolicyControl(tt.controls)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantWarnings, warnings)
+		})
+	}
+}
+
+func TestAccountStatusDuringLoginAndRefresh(t *testing.T) {
+	now := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	userEntry := func(passwordExpiration string) *ldap.SearchResult {
+		return &ldap.SearchResult{Entries: []*ldap.Entry{{
+			DN: testUserSearchResultDNValue,
+			Attributes: []*ldap.EntryAttribute{
+				ldap.NewEntryAttribute(testUserSearchUsernameAttribute, []string{testUserSearchResultUsernameAttributeValue}),
+				ldap.NewEntryAttribute(testUserSearchUIDAttribute, []string{testUserSearchResultUIDAttributeValue}),
+				ldap.NewEntryAttribute("krbPasswordExpiration", []string{passwordExpiration}),
+			},
+		}}}
+	}
+
+	setup := func(t *testing.T, passwordPolicyControl bool) (*Provider, *mockldapconn.MockConn) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		conn := mockldapconn.NewMockConn(ctrl)
+
+		p := New(ProviderConfig{
+			Name:               "some-provider-name",
+			Host:               testHost,
+			ConnectionProtocol: TLS,
+			BindUsername:       testBindUsername,
+			BindPassword:       testBindPassword,
+			UserSearch: UserSearchConfig{
+				Base:              testUserSearchBase,
+				Filter:            testUserSearchFilter,
+				UsernameAttribute: testUserSearchUsernameAttribute,
+				UIDAttribute:      testUserSearchUIDAttribute,
+			},
+			AccountStatus: AccountStatusConfig{
+				PasswordExpirationAttribute:     "krbPasswordExpiration",
+				PasswordExpirationWarningPeriod: 7 * 24 * time.Hour,
+				PasswordPolicyControl:           passwordPolicyControl,
+			},
+			Dialer: LDAPDialerFunc(func(ctx context.Context, addr endpointaddr.HostPort) (Conn, error) {
+				return conn, nil
+			}),
+		})
+		p.clock = clocktesting.NewFakeClock(now)
+		return p, conn
+	}
+
+	expectUserSearch := func(conn *mockldapconn.MockConn, wantAttributes []string, result *ldap.SearchResult) {
+		conn.EXPECT().Bind(testBindUsername, testBindPassword)
+		conn.EXPECT().Search(gomock.Any()).DoAndReturn(func(r *ldap.SearchRequest) (*ldap.SearchResult, error) {
+			require.Equal(t, wantAttributes, r.Attributes)
+			return result, nil
+		})
+		conn.EXPECT().Close()
+	}
+
+	t.Run("login returns the warnings from the user's entry and from the password policy control", func(t *testing.T) {
+		p, conn := setup(t, true)
+		expectUserSearch(conn,
+			[]string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "krbPasswordExpiration", pwdAccountLockedTimeAttribute},
+			userEntry("20220306050607Z"),
+		)
+		conn.EXPECT().SimpleBind(&ldap.SimpleBindRequest{
+			Username: testUserSearchResultDNValue,
+			Password: testUpstreamPassword,
+			Controls: []ldap.Control{ldap.NewControlBeheraPasswordPolicy()},
+		}).Return(&ldap.SimpleBindResult{Controls: []ldap.Control{&ldap.ControlBeheraPasswordPolicy{Expire: 60, Grace: -1, Error: -1}}}, nil)
+
+		response, authenticated, err := p.AuthenticateUser(context.Background(), testUpstreamUsername, testUpstreamPassword)
+		require.NoError(t, err)
+		require.True(t, authenticated)
+		require.Equal(t, []string{
+			"Your password will expire in 2 days. Please change your password.",
+			"Your password will expire in less than a day. Please change your password.",
+		}, response.Warnings)
+	})
+
+	t.Run("login fails when the user's password has expired", func(t *testing.T) {
+		p, conn := setup(t, false)
+		expectUserSearch(conn, []string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "krbPasswordExpiration"}, userEntry("20220304050607Z"))
+		conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword)
+
+		response, authenticated, err := p.AuthenticateUser(context.Background(), testUpstreamUsername, testUpstreamPassword)
+		require.NoError(t, err)
+		require.False(t, authenticated)
+		require.Nil(t, response)
+	})
+
+	t.Run("login fails when the password policy control says that the password must be changed", func(t *testing.T) {
+		p, conn := setup(t, true)
+		expectUserSearch(conn,
+			[]string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "krbPasswordExpiration", pwdAccountLockedTimeAttribute},
+			userEntry("20230304050607Z"),
+		)
+		conn.EXPECT().SimpleBind(gomock.Any()).
+			Return(&ldap.SimpleBindResult{Controls: []ldap.Control{&ldap.ControlBeheraPasswordPolicy{Expire: -1, Grace: -1, Error: 2, ErrorString: "Change After Reset"}}}, nil)
+
+		response, authenticated, err := p.AuthenticateUser(context.Background(), testUpstreamUsername, testUpstreamPassword)
+		require.EqualError(t, err, `user "some-upstream-username" must change their password: password change required`)
+		require.ErrorIs(t, err, authenticators.ErrPasswordChangeRequired)
+		require.False(t, authenticated)
+		require.Nil(t, response)
+	})
+
+	t.Run("login fails when the password policy control says that the account is locked", func(t *testing.T) {
+		p, conn := setup(t, true)
+		expectUserSearch(conn,
+			[]string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "krbPasswordExpiration", pwdAccountLockedTimeAttribute},
+			userEntry("20230304050607Z"),
+		)
+		conn.EXPECT().SimpleBind(gomock.Any()).
+			Return(&ldap.SimpleBindResult{Controls: []ldap.Control{&ldap.ControlBeheraPasswordPolicy{Expire: -1, Grace: -1, Error: 1, ErrorString: "Account locked"}}}, nil)
+
+		response, authenticated, err := p.AuthenticateUser(context.Background(), testUpstreamUsername, testUpstreamPassword)
+		require.NoError(t, err)
+		require.False(t, authenticated)
+		require.Nil(t, response)
+	})
+
+	t.Run("login reads a constructed password expiration attribute with a base object search of the user's entry", func(t *testing.T) {
+		p, conn := setup(t, false)
+		p.c.AccountStatus.PasswordExpirationAttributeIsConstructed = true
+		entryWithoutExpiration := userEntry("")
+		entryWithoutExpiration.Entries[0].Attributes = entryWithoutExpiration.Entries[0].Attributes[:2]
+		gomock.InOrder(
+			conn.EXPECT().Bind(testBindUsername, testBindPassword),
+			conn.EXPECT().Search(gomock.Any()).DoAndReturn(func(r *ldap.SearchRequest) (*ldap.SearchResult, error) {
+				require.Equal(t, ldap.ScopeWholeSubtree, r.Scope)
+				return entryWithoutExpiration, nil
+			}),
+			conn.EXPECT().Search(gomock.Any()).DoAndReturn(func(r *ldap.SearchRequest) (*ldap.SearchResult, error) {
+				require.Equal(t, testUserSearchResultDNValue, r.BaseDN)
+				require.Equal(t, ldap.ScopeBaseObject, r.Scope)
+				require.Equal(t, []string{"krbPasswordExpiration"}, r.Attributes)
+				return &ldap.SearchResult{Entries: []*ldap.Entry{{
+					DN:         testUserSearchResultDNValue,
+					Attributes: []*ldap.EntryAttribute{ldap.NewEntryAttribute("krbPasswordExpiration", []string{"20220306050607Z"})},
+				}}}, nil
+			}),
+			conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword),
+			conn.EXPECT().Close(),
+		)
+
+		response, authenticated, err := p.AuthenticateUser(context.Background(), testUpstreamUsername, testUpstreamPassword)
+		require.NoError(t, err)
+		require.True(t, authenticated)
+		require.Equal(t, []string{"Your password will expire in 2 days. Please change your password."}, response.Warnings)
+	})
+
+	refresh := func(p *Provider) ([]string, []string, error) {
+		groups, _, warnings, err := p.PerformRefresh(context.Background(), provider.StoredRefreshAttributes{
+			Username: testUserSearchResultUsernameAttributeValue,
+			Subject:  "ldaps://ldap.example.com:8443?base=some-upstream-user-base-dn&sub=" + base64.RawURLEncoding.EncodeToString([]byte(testUserSearchResultUIDAttributeValue)),
+			DN:       testUserSearchResultDNValue,
+		})
+		return groups, warnings, err
+	}
+
+	t.Run("refresh returns the warnings from the user's entry", func(t *testing.T) {
+		p, conn := setup(t, false)
+		expectUserSearch(conn, []string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "krbPasswordExpiration"}, userEntry("20220310050607Z"))
+
+		groups, warnings, err := refresh(p)
+		require.NoError(t, err)
+		require.Equal(t, []string{}, groups)
+		require.Equal(t, []string{"Your password will expire in 6 days. Please change your password."}, warnings)
+	})
+
+	t.Run("refresh fails when the user's password has expired", func(t *testing.T) {
+		p, conn := setup(t, false)
+		expectUserSearch(conn, []string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "krbPasswordExpiration"}, userEntry("20220301050607Z"))
+
+		groups, warnings, err := refresh(p)
+		require.EqualError(t, err, `account status check for user "some-upstream-user-dn" failed during upstream refresh: password has expired at 2022-03-01T05:06:07Z`)
+		require.Nil(t, groups)
+		require.Nil(t, warnings)
+	})
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/utils/clock"
+)
+
+// defaultGroupCacheMaxSize is the default maximum number of users whose groups are cached for a single
+// upstream LDAP provider.
+const defaultGroupCacheMaxSize = 1000
+
+// GroupCacheConfig configures the cache of the group search results of a provider. The zero value disables the cache.
+type GroupCacheConfig struct {
+	// TTL is how long the groups which were found for a user may be reused by refreshes of that user's sessions
+	// before the group search is performed again. Zero disables the cache.
+	TTL time.Duration
+
+	// MaxSize is the maximum number of users whose groups are cached. When the cache is full, the least recently
+	// used entry is discarded. Zero means to use the default.
+	MaxSize int
+}
+
+// GroupCaches holds one GroupCache per upstream LDAP provider. Like ConnectionPools, the caches are kept here,
+// keyed by the provider's resource UID, to allow the cached group search results to outlive each Provider.
+type GroupCaches struct {
+	clock clock.Clock
+
+	lock   sync.Mutex
+	caches map[types.UID]*GroupCache
+}
+
+// NewGroupCaches returns an empty set of group caches.
+func NewGroupCaches() *GroupCaches {
+	return &GroupCaches{
+		clock:  clock.RealClock{},
+		caches: map[types.UID]*GroupCache{},
+	}
+}
+
+// ForProvider returns the GroupCache for the upstream provider with the given resource UID, creating it if needed.
+func (gcs *GroupCaches) ForProvider(uid types.UID) *GroupCache {
+	gcs.lock.Lock()
+	defer gcs.lock.Unlock()
+
+	groupCache, ok := gcs.caches[uid]
+	if !ok {
+		groupCache = &GroupCache{clock: gcs.clock}
+		gcs.caches[uid] = groupCache
+	}
+	return groupCache
+}
+
+// Prune discards the caches of any providers whose resource UIDs are not in the given list.
+func (gcs *GroupCaches) Prune(keep []types.UID) {
+	keepSet := make(map[types.UID]bool, len(keep))
+	for _, uid := range keep {
+		keepSet[uid] = true
+	}
+
+	gcs.lock.Lock()
+	defer gcs.lock.Unlock()
+
+	for uid := range gcs.caches {
+		if !keepSet[uid] {
+			delete(gcs.caches, uid)
+		}
+	}
+}
+
+// GroupCache caches the group names which were found for the users of a single upstream LDAP provider, keyed by
+// the user's DN. All cached entries are discarded when the settings which affect the group search results change.
+type GroupCache struct {
+	clock clock.Clock
+
+	lock     sync.Mutex
+	settings groupCacheSettings
+	entries  *cache.LRUExpireCache
+}
+
+// groupCacheSettings are the settings of a provider which decide the results of its group searches.
+type groupCacheSettings struct {
+	connection   connectionSettings
+	bindUsername string
+	groupSearch  GroupSearchConfig
+}
+
+func (p *Provider) groupCacheSettings() groupCacheSettings {
+	return groupCacheSettings{
+		connection:   p.connectionSettings(),
+		bindUsername: p.c.BindUsername,
+		groupSearch:  p.c.GroupSearch,
+	}
+}
+
+// get returns the cached groups of the user, if they were cached using the provider's current settings and have
+// not expired yet.
+func (gc *GroupCache) get(p *Provider, userDN string) ([]string, bool) {
+	if gc == nil {
+		return nil, false
+	}
+
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+
+	cached, ok := gc.entriesFor(p).Get(userDN)
+	if !ok {
+		return nil, false
+	}
+	return append([]string{}, cached.([]string)...), true
+}
+
+// put caches the groups of the user.
+func (gc *GroupCache) put(p *Provider, userDN string, groups []string) {
+	if gc == nil {
+		return
+	}
+
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+
+	gc.entriesFor(p).Add(userDN, append([]string{}, groups...), p.c.GroupSearch.Cache.TTL)
+}
+
+// entriesFor returns the cached entries, after discarding them when they were cached using different settings.
+// The caller must hold the lock.
+func (gc *GroupCache) entriesFor(p *Provider) *cache.LRUExpireCache {
+	settings := p.groupCacheSettings()
+	if gc.entries == nil || gc.settings != settings {
+		maxSize := p.c.GroupSearch.Cache.MaxSize
+		if maxSize <= 0 {
+			maxSize = defaultGroupCacheMaxSize
+		}
+		gc.entries = cache.NewLRUExpireCacheWithClock(maxSize, gc.clock)
+		gc.settings = settings
+	}
+	return gc.entries
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"go.pinniped.dev/internal/endpointaddr"
+	"go.pinniped.dev/internal/mocks/mockldapconn"
+	"go.pinniped.dev/internal/oidc/provider"
+)
+
+func TestGroupCache(t *testing.T) {
+	const providerUID = types.UID("some-provider-uid")
+
+	userSearchResult := &ldap.SearchResult{
+		Entries: []*ldap.Entry{
+			{
+				DN: testUserSearchResultDNValue,
+				Attributes: []*ldap.EntryAttribute{
+					ldap.NewEntryAttribute(testUserSearchUsernameAttribute, []string{testUserSearchResultUsernameAttributeValue}),
+					ldap.NewEntryAttribute(testUserSearchUIDAttribute, []string{testUserSearchResultUIDAttributeValue}),
+				},
+			},
+		},
+	}
+
+	groupSearchResult := func(groupNames ...string) *ldap.SearchResult {
+		result := &ldap.SearchResult{}
+		for _, groupName := range groupNames {
+			result.Entries = append(result.Entries, &ldap.Entry{
+				DN:         "cn=" + groupName,
+				Attributes: []*ldap.EntryAttribute{ldap.NewEntryAttribute(testGroupSearchGroupNameAttribute, []string{groupName})},
+			})
+		}
+		return result
+	}
+
+	storedRefreshAttributes := provider.StoredRefreshAttributes{
+		Username: testUserSearchResultUsernameAttributeValue,
+		Subject:  "ldaps://ldap.example.com:8443?base=some-upstream-user-base-dn&sub=c29tZS11cHN0cmVhbS11aWQtdmFsdWU",
+		DN:       testUserSearchResultDNValue,
+		Groups:   []string{"some-stored-group"},
+	}
+
+	// setup returns a func which creates Providers that share the same group cache and connection.
+	setup := func(t *testing.T) (func(editFunc func(*ProviderConfig)) *Provider, *mockldapconn.MockConn, *clocktesting.FakeClock) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		conn := mockldapconn.NewMockConn(ctrl)
+		conn.EXPECT().Bind(gomock.Any(), gomock.Any()).AnyTimes()
+		conn.EXPECT().Close().AnyTimes()
+
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+		groupCaches := NewGroupCaches()
+		groupCaches.clock = fakeClock
+
+		newProvider := func(editFunc func(*ProviderConfig)) *Provider {
+			config := ProviderConfig{
+				Name:               "some-provider-name",
+				ResourceUID:        providerUID,
+				Host:               testHost,
+				ConnectionProtocol: TLS,
+				BindUsername:       testBindUsername,
+				BindPassword:       testBindPassword,
+				UserSearch: UserSearchConfig{
+					Base:              testUserSearchBase,
+					Filter:            testUserSearchFilter,
+					UsernameAttribute: testUserSearchUsernameAttribute,
+					UIDAttribute:      testUserSearchUIDAttribute,
+				},
+				GroupSearch: GroupSearchConfig{
+					Base:               testGroupSearchBase,
+					Filter:             testGroupSearchFilter,
+					GroupNameAttribute: testGroupSearchGroupNameAttribute,
+					Cache:              GroupCacheConfig{TTL: time.Minute},
+				},
+				Dialer: LDAPDialerFunc(func(ctx context.Context, addr endpointaddr.HostPort) (Conn, error) {
+					return conn, nil
+				}),
+				GroupCache: groupCaches.ForProvider(providerUID),
+			}
+			if editFunc != nil {
+				editFunc(&config)
+			}
+			return New(config)
+		}
+		return newProvider, conn, fakeClock
+	}
+
+	refresh := func(t *testing.T, p *Provider) []string {
+		t.Helper()
+		groups, _, _, err := p.PerformRefresh(context.Background(), storedRefreshAttributes)
+		require.NoError(t, err)
+		return groups
+	}
+
+	t.Run("refreshes reuse the groups found by a previous refresh until they expire", func(t *testing.T) {
+		newProvider, conn, fakeClock := setup(t)
+		gomock.InOrder(
+			conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+			conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(groupSearchResult("group1"), nil),
+			conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+			conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+			conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(groupSearchResult("group2"), nil),
+		)
+
+		require.Equal(t, []string{"group1"}, refresh(t, newProvider(nil)))
+
+		// Providers are recreated by the watchers, but they share the cache.
+		fakeClock.Step(59 * time.Second)
+		require.Equal(t, []string{"group1"}, refresh(t, newProvider(nil)))
+
+		fakeClock.Step(2 * time.Second)
+		require.Equal(t, []string{"group2"}, refresh(t, newProvider(nil)))
+	})
+
+	t.Run("logins always search for groups and update the cache", func(t *testing.T) {
+		newProvider, conn, _ := setup(t)
+		gomock.InOrder(
+			conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+			conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(groupSearchResult("group1"), nil),
+			conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+			conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(groupSearchResult("group2"), nil),
+			conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+		)
+
+		require.Equal(t, []string{"group1"}, refresh(t, newProvider(nil)))
+
+		response, authenticated, err := newProvider(nil).AuthenticateUser(context.Background(), testUpstreamUsername, testUpstreamPassword)
+		require.NoError(t, err)
+		require.True(t, authenticated)
+		require.Equal(t, []string{"group2"}, response.User.GetGroups())
+
+		require.Equal(t, []string{"group2"}, refresh(t, newProvider(nil)))
+	})
+
+	t.Run("skipping group refresh returns the stored groups without using the cache", func(t *testing.T) {
+		newProvider, conn, _ := setup(t)
+		conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil).Times(2)
+
+		skipGroupRefresh := func(c *ProviderConfig) { c.GroupSearch.SkipGroupRefresh = true }
+		require.Equal(t, storedRefreshAttributes.Groups, refresh(t, newProvider(skipGroupRefresh)))
+		require.Equal(t, storedRefreshAttributes.Groups, refresh(t, newProvider(skipGroupRefresh)))
+	})
+
+	t.Run("cached groups are discarded when the group search settings change", func(t *testing.T) {
+		newProvider, conn, _ := setup(t)
+		gomock.InOrder(
+			conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+			conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(groupSearchResult("group1"), nil),
+			conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+			conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(groupSearchResult("group2"), nil),
+			conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+			conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(groupSearchResult("group3"), nil),
+		)
+
+		require.Equal(t, []string{"group1"}, refresh(t, newProvider(nil)))
+		require.Equal(t, []string{"group2"}, refresh(t, newProvider(func(c *ProviderConfig) {
+			c.GroupSearch.Filter = "some-other-group-filter={}"
+		})))
+		require.Equal(t, []string{"group3"}, refresh(t, newProvider(func(c *ProviderConfig) {
+			c.BindUsername = "some-other-bind-username"
+		})))
+	})
+
+	t.Run("the least recently used entries are discarded when the cache is full", func(t *testing.T) {
+		newProvider, _, _ := setup(t)
+		p := newProvider(func(c *ProviderConfig) { c.GroupSearch.Cache.MaxSize = 2 })
+		gc := p.groupCache()
+
+		gc.put(p, "user1", []string{"group1"})
+		gc.put(p, "user2", []string{"group2"})
+		_, ok := gc.get(p, "user1")
+		require.True(t, ok)
+		gc.put(p, "user3", []string{"group3"})
+
+		_, ok = gc.get(p, "user2")
+		require.False(t, ok)
+		groups, ok := gc.get(p, "user1")
+		require.True(t, ok)
+		require.Equal(t, []string{"group1"}, groups)
+		groups, ok = gc.get(p, "user3")
+		require.True(t, ok)
+		require.Equal(t, []string{"group3"}, groups)
+	})
+
+	t.Run("the cache is disabled without a TTL or a group search base", func(t *testing.T) {
+		newProvider, _, _ := setup(t)
+		require.NotNil(t, newProvider(nil).groupCache())
+		require.Nil(t, newProvider(func(c *ProviderConfig) { c.GroupSearch.Cache = GroupCacheConfig{} }).groupCache())
+		require.Nil(t, newProvider(func(c *ProviderConfig) { c.GroupSearch.Base = "" }).groupCache())
+		require.Nil(t, newProvider(func(c *ProviderConfig) { c.GroupCache = nil }).groupCache())
+	})
+}
+
+func TestGroupCachesPrune(t *testing.T) {
+	groupCaches := NewGroupCaches()
+	cache1 := groupCaches.ForProvider("uid1")
+	cache2 := groupCaches.ForProvider("uid2")
+	require.Same(t, cache1, groupCaches.ForProvider("uid1"))
+
+	groupCaches.Prune([]types.UID{"uid2"})
+
+	require.NotSame(t, cache1, groupCaches.ForProvider("uid1"))
+	require.Same(t, cache2, groupCaches.ForProvider("uid2"))
+}
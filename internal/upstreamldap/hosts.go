@@ -0,0 +1,238 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// unhealthyHostRetryInterval is how long a host which could not be dialed is avoided, as long as there are
+	// other hosts which have not recently failed.
+	unhealthyHostRetryInterval = 30 * time.Second
+
+	// hostDiscoveryInterval is how long the hosts which were discovered using DNS SRV records are used before the
+	// records are looked up again.
+	hostDiscoveryInterval = 5 * time.Minute
+
+	defaultSRVService = "ldap"
+)
+
+// HostSelectionPolicy decides which host is used first when a provider has more than one host.
+type HostSelectionPolicy string
+
+const (
+	// Failover always prefers the first healthy host, in the order in which the hosts are configured or discovered.
+	Failover = HostSelectionPolicy("Failover")
+
+	// RoundRobin spreads new connections across all healthy hosts in turn.
+	RoundRobin = HostSelectionPolicy("RoundRobin")
+)
+
+// SRVResolver looks up DNS SRV records. It is implemented by net.Resolver.
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// HostDiscoveryConfig contains information about how to discover the hosts of the upstream LDAP IDP using DNS.
+type HostDiscoveryConfig struct {
+	// SRVDomain is the domain in which to look up the SRV records, e.g. "example.com".
+	SRVDomain string
+
+	// SRVService is the service name of the SRV records. Empty means to use "ldap".
+	SRVService string
+}
+
+// HostStatus is the result of testing the connection to one of the hosts of a Provider.
+type HostStatus struct {
+	// Host is the hostname or "hostname:port" of the LDAP server.
+	Host string
+
+	// Err is the error from dialing or binding to the Host, or nil when it was successful.
+	Err error
+}
+
+// hosts returns all hosts of the provider, with the configured hosts first followed by any discovered hosts.
+func (p *Provider) hosts(ctx context.Context) ([]string, error) {
+	hosts := make([]string, 0, 1+len(p.c.AdditionalHosts))
+	if p.c.Host != "" {
+		hosts = append(hosts, p.c.Host)
+	}
+	hosts = append(hosts, p.c.AdditionalHosts...)
+
+	if p.c.HostDiscovery.SRVDomain != "" {
+		discovered, err := p.discoverHosts(ctx)
+		if err != nil {
+			if len(hosts) == 0 {
+				return nil, err
+			}
+			// The configured hosts can still be used.
+			plog.WarningErr("could not discover hosts, so only using the configured hosts", err, "upstreamName", p.GetName())
+		}
+		for _, host := range discovered {
+			if !containsString(hosts, host) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts were configured or discovered")
+	}
+	return hosts, nil
+}
+
+func (p *Provider) discoverHosts(ctx context.Context) ([]string, error) {
+	service := p.c.HostDiscovery.SRVService
+	if service == "" {
+		service = defaultSRVService
+	}
+
+	// The discovered hosts are cached by the connection pool of the provider for the hostDiscoveryInterval, so
+	// dialing does not require a DNS lookup every time.
+	key := fmt.Sprintf("_%s._tcp.%s", service, p.c.HostDiscovery.SRVDomain)
+	tracker := p.hostTracker()
+	if hosts, ok := tracker.cachedDiscoveredHosts(key); ok {
+		return hosts, nil
+	}
+
+	resolver := p.c.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	// The returned records are already sorted by priority and randomized by weight within each priority.
+	_, records, err := resolver.LookupSRV(ctx, service, "tcp", p.c.HostDiscovery.SRVDomain)
+	if err != nil {
+		return nil, fmt.Errorf(`error looking up SRV records for %q: %w`, key, err)
+	}
+
+	hosts := make([]string, 0, len(records))
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		if target == "" {
+			continue // a target of "." means that the service is not available in this domain
+		}
+		hosts = append(hosts, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf(`no hosts found in SRV records for %q`, key)
+	}
+
+	tracker.cacheDiscoveredHosts(key, hosts)
+	return hosts, nil
+}
+
+// hostTracker remembers which hosts of a provider recently could not be dialed, whose turn it is next when
+// using RoundRobin, and which hosts were discovered using DNS. A nil hostTracker has no memory, so it always
+// returns the hosts in their original order.
+type hostTracker struct {
+	clock clock.Clock
+
+	lock           sync.Mutex
+	unhealthyUntil map[string]time.Time
+	next           int
+
+	discoveryKey    string
+	discovered      []string
+	discoveredUntil time.Time
+}
+
+func newHostTracker(clock clock.Clock) *hostTracker {
+	return &hostTracker{clock: clock, unhealthyUntil: map[string]time.Time{}}
+}
+
+// order returns the hosts in the order in which they should be tried. Healthy hosts come first, in an order
+// decided by the policy, followed by the hosts which recently failed as a last resort.
+func (h *hostTracker) order(hosts []string, policy HostSelectionPolicy) []string {
+	if h == nil {
+		return hosts
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	now := h.clock.Now()
+	healthy := make([]string, 0, len(hosts))
+	var unhealthy []string
+	for _, host := range hosts {
+		if until, failed := h.unhealthyUntil[host]; failed && now.Before(until) {
+			unhealthy = append(unhealthy, host)
+			continue
+		}
+		healthy = append(healthy, host)
+	}
+
+	if policy == RoundRobin && len(healthy) > 1 {
+		start := h.next % len(healthy)
+		h.next++
+		rotated := make([]string, 0, len(healthy))
+		rotated = append(rotated, healthy[start:]...)
+		healthy = append(rotated, healthy[:start]...)
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+func (h *hostTracker) markFailed(host string) {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.unhealthyUntil[host] = h.clock.Now().Add(unhealthyHostRetryInterval)
+}
+
+func (h *hostTracker) markHealthy(host string) {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.unhealthyUntil, host)
+}
+
+// cachedDiscoveredHosts returns the hosts which were discovered using the SRV records identified by the key,
+// unless they were discovered more than the hostDiscoveryInterval ago.
+func (h *hostTracker) cachedDiscoveredHosts(key string) ([]string, bool) {
+	if h == nil {
+		return nil, false
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.discoveryKey != key || !h.clock.Now().Before(h.discoveredUntil) {
+		return nil, false
+	}
+	return h.discovered, true
+}
+
+func (h *hostTracker) cacheDiscoveredHosts(key string, hosts []string) {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.discoveryKey = key
+	h.discovered = hosts
+	h.discoveredUntil = h.clock.Now().Add(hostDiscoveryInterval)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"go.pinniped.dev/internal/endpointaddr"
+	"go.pinniped.dev/internal/mocks/mockldapconn"
+)
+
+type fakeSRVResolver func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+func (f fakeSRVResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return f(ctx, service, proto, name)
+}
+
+func TestMultipleHosts(t *testing.T) {
+	const providerUID = types.UID("some-provider-uid")
+
+	// setup returns a config which records the address of each dial, and which fails to dial the given hosts.
+	setup := func(t *testing.T, failingHosts ...string) (ProviderConfig, *[]string) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		var dialed []string
+		dialer := LDAPDialerFunc(func(ctx context.Context, addr endpointaddr.HostPort) (Conn, error) {
+			dialed = append(dialed, addr.Endpoint())
+			for _, failingHost := range failingHosts {
+				if addr.Endpoint() == failingHost {
+					return nil, errors.New("some dial error")
+				}
+			}
+			conn := mockldapconn.NewMockConn(ctrl)
+			conn.EXPECT().Bind(testBindUsername, testBindPassword).Return(nil)
+			conn.EXPECT().Close()
+			return conn, nil
+		})
+
+		return ProviderConfig{
+			Name:               "some-provider-name",
+			ResourceUID:        providerUID,
+			ConnectionProtocol: TLS,
+			BindUsername:       testBindUsername,
+			BindPassword:       testBindPassword,
+			Dialer:             dialer,
+		}, &dialed
+	}
+
+	newPool := func(clock *clocktesting.FakeClock) *ConnectionPool {
+		pools := NewConnectionPools(ConnectionPoolConfig{})
+		pools.clock = clock
+		return pools.ForProvider(providerUID)
+	}
+
+	t.Run("fails over to the additional hosts in order and avoids recently failed hosts", func(t *testing.T) {
+		config, dialed := setup(t, "ldap1.example.com:636")
+		config.Host = "ldap1.example.com"
+		config.AdditionalHosts = []string{"ldap2.example.com", "ldap3.example.com"}
+		clock := clocktesting.NewFakeClock(time.Now())
+		config.ConnectionPool = newPool(clock)
+
+		require.NoError(t, New(config).TestConnection(context.Background()))
+		require.Equal(t, []string{"ldap1.example.com:636", "ldap2.example.com:636"}, *dialed)
+
+		// The first host recently failed, so it is not tried first anymore.
+		*dialed = nil
+		require.NoError(t, New(config).TestConnection(context.Background()))
+		require.Equal(t, []string{"ldap2.example.com:636"}, *dialed)
+
+		// After a while, the first host is tried first again.
+		*dialed = nil
+		clock.Step(unhealthyHostRetryInterval + time.Second)
+		require.NoError(t, New(config).TestConnection(context.Background()))
+		require.Equal(t, []string{"ldap1.example.com:636", "ldap2.example.com:636"}, *dialed)
+	})
+
+	t.Run("fails over without remembering failed hosts when there is no connection pool", func(t *testing.T) {
+		config, dialed := setup(t, "ldap1.example.com:636")
+		config.Host = "ldap1.example.com"
+		config.AdditionalHosts = []string{"ldap2.example.com"}
+
+		require.NoError(t, New(config).TestConnection(context.Background()))
+		require.NoError(t, New(config).TestConnection(context.Background()))
+		require.Equal(t, []string{
+			"ldap1.example.com:636", "ldap2.example.com:636",
+			"ldap1.example.com:636", "ldap2.example.com:636",
+		}, *dialed)
+	})
+
+	t.Run("round robin takes turns between the healthy hosts", func(t *testing.T) {
+		config, dialed := setup(t, "ldap2.example.com:636")
+		config.Host = "ldap1.example.com"
+		config.AdditionalHosts = []string{"ldap2.example.com", "ldap3.example.com"}
+		config.HostSelection = RoundRobin
+		config.ConnectionPool = newPool(clocktesting.NewFakeClock(time.Now()))
+
+		for i := 0; i < 4; i++ {
+			require.NoError(t, New(config).TestConnection(context.Background()))
+		}
+		require.Equal(t, []string{
+			"ldap1.example.com:636",
+			"ldap2.example.com:636", "ldap3.example.com:636", // the second host fails, so it is avoided from now on
+			"ldap1.example.com:636",
+			"ldap3.example.com:636",
+		}, *dialed)
+	})
+
+	t.Run("returns the error of each host when none of the hosts can be dialed", func(t *testing.T) {
+		config, _ := setup(t, "ldap1.example.com:636", "ldap2.example.com:636")
+		config.Host = "ldap1.example.com"
+		config.AdditionalHosts = []string{"ldap2.example.com"}
+
+		err := New(config).TestConnection(context.Background())
+		require.EqualError(t, err, `[error dialing host "ldap1.example.com": some dial error, error dialing host "ldap2.example.com": some dial error]`)
+	})
+
+	t.Run("discovers the hosts using DNS SRV records", func(t *testing.T) {
+		config, dialed := setup(t, "dc1.example.com:389")
+		config.HostDiscovery = HostDiscoveryConfig{SRVDomain: "example.com"}
+		config.Resolver = fakeSRVResolver(func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			require.Equal(t, "ldap", service)
+			require.Equal(t, "tcp", proto)
+			require.Equal(t, "example.com", name)
+			return "_ldap._tcp.example.com.", []*net.SRV{
+				{Target: "dc1.example.com.", Port: 389},
+				{Target: "dc2.example.com.", Port: 3268},
+			}, nil
+		})
+
+		statuses, err := New(config).TestConnectionToEachHost(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []HostStatus{
+			{Host: "dc1.example.com:389", Err: statuses[0].Err},
+			{Host: "dc2.example.com:3268"},
+		}, statuses)
+		require.EqualError(t, statuses[0].Err, `error dialing host "dc1.example.com:389": some dial error`)
+		require.Equal(t, []string{"dc1.example.com:389", "dc2.example.com:3268"}, *dialed)
+
+		// The discovered hosts may change, so the provider is identified by the domain.
+		require.Equal(t, "ldaps://example.com?base=", New(config).GetURL().String())
+	})
+
+	t.Run("caches the discovered hosts in the connection pool until they are due to be discovered again", func(t *testing.T) {
+		config, dialed := setup(t)
+		config.HostDiscovery = HostDiscoveryConfig{SRVDomain: "example.com"}
+		lookups := 0
+		config.Resolver = fakeSRVResolver(func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			lookups++
+			return "_ldap._tcp.example.com.", []*net.SRV{{Target: "dc1.example.com.", Port: 389}}, nil
+		})
+		clock := clocktesting.NewFakeClock(time.Now())
+		config.ConnectionPool = newPool(clock)
+
+		require.NoError(t, New(config).TestConnection(context.Background()))
+		clock.Step(hostDiscoveryInterval - time.Second)
+		require.NoError(t, New(config).TestConnection(context.Background()))
+		require.Equal(t, 1, lookups)
+
+		clock.Step(time.Second)
+		require.NoError(t, New(config).TestConnection(context.Background()))
+		require.Equal(t, 2, lookups)
+
+		// A different domain is looked up right away.
+		config.HostDiscovery.SRVDomain = "other.example.com"
+		require.NoError(t, New(config).TestConnection(context.Background()))
+		require.Equal(t, 3, lookups)
+		require.Equal(t, []string{"dc1.example.com:389", "dc1.example.com:389", "dc1.example.com:389", "dc1.example.com:389"}, *dialed)
+	})
+
+	t.Run("uses the configured hosts when the DNS SRV records cannot be looked up", func(t *testing.T) {
+		config, dialed := setup(t)
+		config.Host = "ldap1.example.com"
+		config.HostDiscovery = HostDiscoveryConfig{SRVDomain: "example.com", SRVService: "ldaps"}
+		config.Resolver = fakeSRVResolver(func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			require.Equal(t, "ldaps", service)
+			return "", nil, errors.New("some lookup error")
+		})
+
+		require.NoError(t, New(config).TestConnection(context.Background()))
+		require.Equal(t, []string{"ldap1.example.com:636"}, *dialed)
+		require.Equal(t, "ldaps://ldap1.example.com?base=", New(config).GetURL().String())
+	})
+
+	t.Run("returns an error when there are no configured or discovered hosts", func(t *testing.T) {
+		config, _ := setup(t)
+		config.HostDiscovery = HostDiscoveryConfig{SRVDomain: "example.com"}
+		config.Resolver = fakeSRVResolver(func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			return "", []*net.SRV{{Target: "."}}, nil
+		})
+
+		_, err := New(config).TestConnectionToEachHost(context.Background())
+		require.EqualError(t, err, `no hosts found in SRV records for "_ldap._tcp.example.com"`)
+
+		err = New(config).TestConnection(context.Background())
+		require.EqualError(t, err, `LDAP Result Code 200 "Network Error": no hosts found in SRV records for "_ldap._tcp.example.com"`)
+	})
+}
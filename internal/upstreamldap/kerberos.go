@@ -0,0 +1,109 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/service"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+
+	"go.pinniped.dev/internal/authenticators"
+	"go.pinniped.dev/internal/plog"
+)
+
+// KerberosConfig contains information about how to validate the Kerberos tickets which browsers present using
+// SPNEGO, i.e. in an "Authorization: Negotiate" header.
+type KerberosConfig struct {
+	// Keytab contains the keys of the service principal which are used to decrypt the tickets. Nil means that
+	// Kerberos single sign-on is disabled.
+	Keytab *keytab.Keytab
+
+	// ServicePrincipalName is the name of the service principal in the Keytab which is used to decrypt the
+	// tickets, e.g. "HTTP/supervisor.example.com". Empty means to use the service principal named by each ticket.
+	ServicePrincipalName string
+}
+
+// KerberosEnabled returns true when the Provider can authenticate users with Kerberos tickets.
+func (p *Provider) KerberosEnabled() bool {
+	return p.c.Kerberos.Keytab != nil
+}
+
+// AuthenticateKerberosTicket authenticates the end user who presented the given SPNEGO token, which is the decoded
+// value of an "Authorization: Negotiate" header. After the Kerberos ticket in the token is validated using the
+// keytab, the user named by the ticket is searched for using the UserSearch, and their groups are searched for
+// using the GroupSearch, exactly as they would be by AuthenticateUser. It returns false with a nil error when the
+// ticket is not valid or when the user cannot be found.
+func (p *Provider) AuthenticateKerberosTicket(ctx context.Context, negotiateToken []byte) (*authenticators.Response, bool, error) {
+	if !p.KerberosEnabled() {
+		return nil, false, fmt.Errorf("kerberos is not configured for upstream %q", p.GetName())
+	}
+
+	username, err := p.verifyKerberosTicket(negotiateToken)
+	if err != nil {
+		plog.DebugErr("error validating kerberos ticket", err, "upstreamName", p.GetName())
+		return nil, false, nil
+	}
+
+	ticketBindFunc := func(conn Conn, foundUserDN string) ([]ldap.Control, error) {
+		// The ticket already proved the identity of the user, so there is no password to bind with.
+		return nil, nil
+	}
+	return p.authenticateUserImpl(ctx, username, ticketBindFunc)
+}
+
+// verifyKerberosTicket validates the AP-REQ in the given SPNEGO token and returns the name of the client principal
+// without its realm, e.g. "alice" for "alice@EXAMPLE.COM". Since the user is searched for without their realm, the
+// client principal must be in the same realm as the service principal which the ticket was issued for. Otherwise,
+// a user of a trusted realm could log in as the user of the same name in the realm of the Supervisor.
+func (p *Provider) verifyKerberosTicket(negotiateToken []byte) (string, error) {
+	mechToken := negotiateToken
+	var spnegoToken spnego.SPNEGOToken
+	if err := spnegoToken.Unmarshal(negotiateToken); err == nil {
+		if !spnegoToken.Init || len(spnegoToken.NegTokenInit.MechTypes) == 0 {
+			return "", errors.New("SPNEGO token is not an initial token")
+		}
+		mechType := spnegoToken.NegTokenInit.MechTypes[0]
+		if !mechType.Equal(gssapi.OIDKRB5.OID()) && !mechType.Equal(gssapi.OIDMSLegacyKRB5.OID()) {
+			return "", fmt.Errorf("SPNEGO token has unsupported mechanism %s", mechType.String())
+		}
+		mechToken = spnegoToken.NegTokenInit.MechTokenBytes
+	}
+	// Otherwise, some clients send a raw Kerberos token which is not wrapped in SPNEGO.
+
+	var krb5Token spnego.KRB5Token
+	if err := krb5Token.Unmarshal(mechToken); err != nil {
+		return "", err
+	}
+	if !krb5Token.IsAPReq() {
+		return "", errors.New("kerberos token does not contain an AP-REQ")
+	}
+
+	settings := []func(*service.Settings){
+		// The user's groups come from the GroupSearch, so there is no need to decode the PAC in tickets from AD.
+		service.DecodePAC(false),
+	}
+	if p.c.Kerberos.ServicePrincipalName != "" {
+		settings = append(settings, service.KeytabPrincipal(p.c.Kerberos.ServicePrincipalName))
+	}
+
+	ok, credentials, err := service.VerifyAPREQ(&krb5Token.APReq, service.NewSettings(p.c.Kerberos.Keytab, settings...))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errors.New("kerberos ticket is not valid")
+	}
+	if credentials.Domain() != krb5Token.APReq.Ticket.Realm {
+		return "", fmt.Errorf("kerberos ticket is for a client principal in realm %q, but the service principal is in realm %q",
+			credentials.Domain(), krb5Token.APReq.Ticket.Realm,
+		)
+	}
+	return credentials.UserName(), nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/golang/mock/gomock"
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/endpointaddr"
+	"go.pinniped.dev/internal/mocks/mockldapconn"
+)
+
+const (
+	testRealm                = "EXAMPLE.COM"
+	testServicePrincipalName = "HTTP/supervisor.example.com"
+)
+
+func TestAuthenticateKerberosTicket(t *testing.T) {
+	serviceKeytab := testKeytab(t, testServicePrincipalName, "some-service-password")
+	otherKeytab := testKeytab(t, testServicePrincipalName, "some-other-service-password")
+
+	setup := func(t *testing.T, kerberos KerberosConfig) (*Provider, *mockldapconn.MockConn) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		conn := mockldapconn.NewMockConn(ctrl)
+
+		p := New(ProviderConfig{
+			Name:               "some-provider-name",
+			Host:               testHost,
+			ConnectionProtocol: TLS,
+			BindUsername:       testBindUsername,
+			BindPassword:       testBindPassword,
+			UserSearch: UserSearchConfig{
+				Base:              testUserSearchBase,
+				Filter:            testUserSearchFilter,
+				UsernameAttribute: testUserSearchUsernameAttribute,
+				UIDAttribute:      testUserSearchUIDAttribute,
+			},
+			Kerberos: kerberos,
+			Dialer: LDAPDialerFunc(func(ctx context.Context, addr endpointaddr.HostPort) (Conn, error) {
+				return conn, nil
+			}),
+		})
+		return p, conn
+	}
+
+	expectUserSearch := func(conn *mockldapconn.MockConn) {
+		conn.EXPECT().Bind(testBindUsername, testBindPassword)
+		conn.EXPECT().Search(gomock.Any()).DoAndReturn(func(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+			require.Equal(t, testUserSearchFilterInterpolated, request.Filter)
+			return &ldap.SearchResult{Entries: []*ldap.Entry{{
+				DN: testUserSearchResultDNValue,
+				Attributes: []*ldap.EntryAttribute{
+					ldap.NewEntryAttribute(testUserSearchUsernameAttribute, []string{testUserSearchResultUsernameAttributeValue}),
+					ldap.NewEntryAttribute(testUserSearchUIDAttribute, []string{testUserSearchResultUIDAttributeValue}),
+				},
+			}}}, nil
+		})
+		conn.EXPECT().Close()
+	}
+
+	t.Run("authenticates the user named by a valid SPNEGO token without binding as them", func(t *testing.T) {
+		p, conn := setup(t, KerberosConfig{Keytab: serviceKeytab})
+		expectUserSearch(conn)
+
+		response, authenticated, err := p.AuthenticateKerberosTicket(context.Background(), testNegotiateToken(t, serviceKeytab, true))
+		require.NoError(t, err)
+		require.True(t, authenticated)
+		require.Equal(t, testUserSearchResultUsernameAttributeValue, response.User.GetName())
+		require.Equal(t, testUserSearchResultDNValue, response.DN)
+	})
+
+	t.Run("accepts a raw kerberos token which is not wrapped in SPNEGO", func(t *testing.T) {
+		p, conn := setup(t, KerberosConfig{Keytab: serviceKeytab, ServicePrincipalName: testServicePrincipalName})
+		expectUserSearch(conn)
+
+		response, authenticated, err := p.AuthenticateKerberosTicket(context.Background(), testNegotiateToken(t, serviceKeytab, false))
+		require.NoError(t, err)
+		require.True(t, authenticated)
+		require.Equal(t, testUserSearchResultUsernameAttributeValue, response.User.GetName())
+	})
+
+	t.Run("rejects a ticket which was encrypted for a different key", func(t *testing.T) {
+		p, _ := setup(t, KerberosConfig{Keytab: serviceKeytab})
+
+		response, authenticated, err := p.AuthenticateKerberosTicket(context.Background(), testNegotiateToken(t, otherKeytab, true))
+		require.NoError(t, err)
+		require.False(t, authenticated)
+		require.Nil(t, response)
+	})
+
+	t.Run("rejects a ticket which is not for the configured service principal", func(t *testing.T) {
+		p, _ := setup(t, KerberosConfig{Keytab: serviceKeytab, ServicePrincipalName: "HTTP/other.example.com"})
+
+		response, authenticated, err := p.AuthenticateKerberosTicket(context.Background(), testNegotiateToken(t, serviceKeytab, true))
+		require.NoError(t, err)
+		require.False(t, authenticated)
+		require.Nil(t, response)
+	})
+
+	t.Run("rejects a ticket for a client principal in a different realm than the service principal", func(t *testing.T) {
+		p, _ := setup(t, KerberosConfig{Keytab: serviceKeytab})
+
+		response, authenticated, err := p.AuthenticateKerberosTicket(context.Background(), testNegotiateTokenForRealm(t, serviceKeytab, "TRUSTED.EXAMPLE.COM", true))
+		require.NoError(t, err)
+		require.False(t, authenticated)
+		require.Nil(t, response)
+	})
+
+	t.Run("rejects a token which was already used", func(t *testing.T) {
+		p, conn := setup(t, KerberosConfig{Keytab: serviceKeytab})
+		expectUserSearch(conn)
+		token := testNegotiateToken(t, serviceKeytab, true)
+
+		_, authenticated, err := p.AuthenticateKerberosTicket(context.Background(), token)
+		require.NoError(t, err)
+		require.True(t, authenticated)
+
+		_, authenticated, err = p.AuthenticateKerberosTicket(context.Background(), token)
+		require.NoError(t, err)
+		require.False(t, authenticated)
+	})
+
+	t.Run("rejects a token which is not a kerberos token", func(t *testing.T) {
+		p, _ := setup(t, KerberosConfig{Keytab: serviceKeytab})
+
+		response, authenticated, err := p.AuthenticateKerberosTicket(context.Background(), []byte("not a token"))
+		require.NoError(t, err)
+		require.False(t, authenticated)
+		require.Nil(t, response)
+	})
+
+	t.Run("returns an error when kerberos is not configured", func(t *testing.T) {
+		p, _ := setup(t, KerberosConfig{})
+		require.False(t, p.KerberosEnabled())
+
+		_, _, err := p.AuthenticateKerberosTicket(context.Background(), testNegotiateToken(t, serviceKeytab, true))
+		require.EqualError(t, err, `kerberos is not configured for upstream "some-provider-name"`)
+	})
+}
+
+func testKeytab(t *testing.T, principalName, password string) *keytab.Keytab {
+	t.Helper()
+	kt := keytab.New()
+	require.NoError(t, kt.AddEntry(principalName, testRealm, password, time.Now(), 1, etypeID.AES256_CTS_HMAC_SHA1_96))
+	return kt
+}
+
+// testNegotiateToken returns a token like the one which a browser would present after getting a ticket for the
+// testServicePrincipalName from the KDC, using the given keytab to stand in for the KDC's copy of the service key.
+func testNegotiateToken(t *testing.T, serviceKeytab *keytab.Keytab, wrapInSPNEGO bool) []byte {
+	t.Helper()
+	return testNegotiateTokenForRealm(t, serviceKeytab, testRealm, wrapInSPNEGO)
+}
+
+// testNegotiateTokenForRealm is like testNegotiateToken, but for a client principal in the given realm, e.g. a
+// user of a trusted realm.
+func testNegotiateTokenForRealm(t *testing.T, serviceKeytab *keytab.Keytab, clientRealm string, wrapInSPNEGO bool) []byte {
+	t.Helper()
+
+	cl := client.NewWithPassword(testUpstreamUsername, clientRealm, "some-client-password", config.New())
+	now := time.Now().UTC()
+	ticket, sessionKey, err := messages.NewTicket(
+		cl.Credentials.CName(), clientRealm,
+		types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, testServicePrincipalName), testRealm,
+		types.NewKrbFlags(), serviceKeytab, etypeID.AES256_CTS_HMAC_SHA1_96, 1,
+		now, now, now.Add(time.Hour), now.Add(time.Hour),
+	)
+	require.NoError(t, err)
+
+	krb5Token, err := spnego.NewKRB5TokenAPREQ(cl, ticket, sessionKey, []int{gssapi.ContextFlagInteg, gssapi.ContextFlagConf}, []int{})
+	require.NoError(t, err)
+	krb5TokenBytes, err := krb5Token.Marshal()
+	require.NoError(t, err)
+	if !wrapInSPNEGO {
+		return krb5TokenBytes
+	}
+
+	spnegoToken := spnego.SPNEGOToken{
+		Init: true,
+		NegTokenInit: spnego.NegTokenInit{
+			MechTypes:      []asn1.ObjectIdentifier{gssapi.OIDKRB5.OID()},
+			MechTokenBytes: krb5TokenBytes,
+		},
+	}
+	spnegoTokenBytes, err := spnegoToken.Marshal()
+	require.NoError(t, err)
+	return spnegoTokenBytes
+}
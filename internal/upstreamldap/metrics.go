@@ -0,0 +1,97 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// poolMetricLabels are the labels of every connection pool metric.
+var poolMetricLabels = []string{"upstream_type", "upstream_name"} //nolint:gochecknoglobals
+
+//nolint:gochecknoglobals
+var (
+	poolIdleDesc = metrics.NewDesc("pinniped_supervisor_ldap_connection_pool_idle_connections",
+		"Number of open connections which are waiting in the connection pool of an upstream LDAP provider to be used.",
+		poolMetricLabels, nil, metrics.ALPHA, "")
+	poolInUseDesc = metrics.NewDesc("pinniped_supervisor_ldap_connection_pool_in_use_connections",
+		"Number of connections which are currently checked out of the connection pool of an upstream LDAP provider.",
+		poolMetricLabels, nil, metrics.ALPHA, "")
+	poolDialsDesc = metrics.NewDesc("pinniped_supervisor_ldap_connection_pool_dials_total",
+		"Number of new connections which were dialed and bound by the connection pool of an upstream LDAP provider.",
+		poolMetricLabels, nil, metrics.ALPHA, "")
+	poolReusesDesc = metrics.NewDesc("pinniped_supervisor_ldap_connection_pool_reuses_total",
+		"Number of times that an idle connection was reused instead of dialing a new connection to an upstream LDAP provider.",
+		poolMetricLabels, nil, metrics.ALPHA, "")
+	poolRebindsDesc = metrics.NewDesc("pinniped_supervisor_ldap_connection_pool_rebinds_total",
+		"Number of times that an idle connection to an upstream LDAP provider was bound again because the bind credentials changed.",
+		poolMetricLabels, nil, metrics.ALPHA, "")
+	poolWaitsDesc = metrics.NewDesc("pinniped_supervisor_ldap_connection_pool_waits_total",
+		"Number of times that a caller waited because the maximum number of connections to an upstream LDAP provider were in use.",
+		poolMetricLabels, nil, metrics.ALPHA, "")
+	poolDiscardsDesc = metrics.NewDesc("pinniped_supervisor_ldap_connection_pool_discards_total",
+		"Number of connections to an upstream LDAP provider which were closed because they were unhealthy, idle for too long, or outdated.",
+		poolMetricLabels, nil, metrics.ALPHA, "")
+	poolRetriesDesc = metrics.NewDesc("pinniped_supervisor_ldap_connection_pool_retries_total",
+		"Number of operations which were retried on a new connection because an idle connection to an upstream LDAP provider was broken.",
+		poolMetricLabels, nil, metrics.ALPHA, "")
+)
+
+// poolMetrics collects the metrics of every ConnectionPools which was created with an UpstreamType.
+var poolMetrics = &connectionPoolCollector{} //nolint:gochecknoglobals
+
+type connectionPoolCollector struct {
+	metrics.BaseStableCollector
+
+	registerOnce sync.Once
+	lock         sync.Mutex
+	pools        []*ConnectionPools
+}
+
+var _ metrics.StableCollector = &connectionPoolCollector{}
+
+// add starts collecting the metrics of the given pools, registering the collector the first time that it is called.
+func (c *connectionPoolCollector) add(ps *ConnectionPools) {
+	c.registerOnce.Do(func() {
+		legacyregistry.CustomMustRegister(c)
+	})
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pools = append(c.pools, ps)
+}
+
+func (c *connectionPoolCollector) DescribeWithStability(ch chan<- *metrics.Desc) {
+	ch <- poolIdleDesc
+	ch <- poolInUseDesc
+	ch <- poolDialsDesc
+	ch <- poolReusesDesc
+	ch <- poolRebindsDesc
+	ch <- poolWaitsDesc
+	ch <- poolDiscardsDesc
+	ch <- poolRetriesDesc
+}
+
+func (c *connectionPoolCollector) CollectWithStability(ch chan<- metrics.Metric) {
+	c.lock.Lock()
+	pools := c.pools
+	c.lock.Unlock()
+
+	for _, ps := range pools {
+		for name, stats := range ps.statsByName() {
+			labels := []string{ps.config.UpstreamType, name}
+			ch <- metrics.NewLazyConstMetric(poolIdleDesc, metrics.GaugeValue, float64(stats.Idle), labels...)
+			ch <- metrics.NewLazyConstMetric(poolInUseDesc, metrics.GaugeValue, float64(stats.InUse), labels...)
+			ch <- metrics.NewLazyConstMetric(poolDialsDesc, metrics.CounterValue, float64(stats.Dials), labels...)
+			ch <- metrics.NewLazyConstMetric(poolReusesDesc, metrics.CounterValue, float64(stats.Reuses), labels...)
+			ch <- metrics.NewLazyConstMetric(poolRebindsDesc, metrics.CounterValue, float64(stats.Rebinds), labels...)
+			ch <- metrics.NewLazyConstMetric(poolWaitsDesc, metrics.CounterValue, float64(stats.Waits), labels...)
+			ch <- metrics.NewLazyConstMetric(poolDiscardsDesc, metrics.CounterValue, float64(stats.Discards), labels...)
+			ch <- metrics.NewLazyConstMetric(poolRetriesDesc, metrics.CounterValue, float64(stats.Retries), labels...)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestConnectionPoolMetrics(t *testing.T) {
+	pools := &ConnectionPools{
+		config: ConnectionPoolConfig{UpstreamType: "ldap"},
+		pools: map[types.UID]*ConnectionPool{
+			"some-uid":    {stats: ConnectionPoolStats{Dials: 2, Reuses: 5, Discards: 1, Retries: 1}},
+			"unknown-uid": {stats: ConnectionPoolStats{Dials: 7}},
+		},
+		names: map[types.UID]string{"some-uid": "some-provider-name"},
+	}
+	collector := &connectionPoolCollector{pools: []*ConnectionPools{pools}}
+
+	// Pools of providers which have not been pruned yet have no name, so they are left out.
+	err := testutil.CustomCollectAndCompare(collector, strings.NewReader(`
+		# HELP pinniped_supervisor_ldap_connection_pool_dials_total [ALPHA] Number of new connections which were dialed and bound by the connection pool of an upstream LDAP provider.
+		# TYPE pinniped_supervisor_ldap_connection_pool_dials_total counter
+		pinniped_supervisor_ldap_connection_pool_dials_total{upstream_name="some-provider-name",upstream_type="ldap"} 2
+		# HELP pinniped_supervisor_ldap_connection_pool_retries_total [ALPHA] Number of operations which were retried on a new connection because an idle connection to an upstream LDAP provider was broken.
+		# TYPE pinniped_supervisor_ldap_connection_pool_retries_total counter
+		pinniped_supervisor_ldap_connection_pool_retries_total{upstream_name="some-provider-name",upstream_type="ldap"} 1
+		# HELP pinniped_supervisor_ldap_connection_pool_reuses_total [ALPHA] Number of times that an idle connection was reused instead of dialing a new connection to an upstream LDAP provider.
+		# TYPE pinniped_supervisor_ldap_connection_pool_reuses_total counter
+		pinniped_supervisor_ldap_connection_pool_reuses_total{upstream_name="some-provider-name",upstream_type="ldap"} 5
+	`), "pinniped_supervisor_ldap_connection_pool_dials_total",
+		"pinniped_supervisor_ldap_connection_pool_retries_total",
+		"pinniped_supervisor_ldap_connection_pool_reuses_total")
+	require.NoError(t, err)
+}
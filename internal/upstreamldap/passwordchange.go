@@ -0,0 +1,151 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/go-ldap/ldap/v3"
+	"k8s.io/utils/trace"
+
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// activeDirectoryPasswordExpired and activeDirectoryPasswordMustChange are found in the diagnostic message of
+	// the invalid credentials error which Active Directory returns when binding with a correct password which must be
+	// changed, e.g. "80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 773, v4563".
+	activeDirectoryPasswordExpired    = "data 532"
+	activeDirectoryPasswordMustChange = "data 773"
+
+	activeDirectoryPasswordAttribute = "unicodePwd"
+)
+
+// PasswordChangeMethod decides how a user's password is changed in the upstream LDAP IDP.
+type PasswordChangeMethod string
+
+const (
+	// PasswordModifyExtendedOperation binds as the user with their current password and then changes it using the
+	// password modify extended operation from RFC 3062.
+	PasswordModifyExtendedOperation = PasswordChangeMethod("")
+
+	// ActiveDirectoryUnicodePwd changes the password using the bind account, by removing the current value of the
+	// user's unicodePwd attribute and adding the new value in the same modify request. Active Directory treats this
+	// as a password change by the user rather than a password reset, so it verifies the current password and enforces
+	// the password policy. This does not require binding as the user, which is not allowed while they must change
+	// their password.
+	ActiveDirectoryUnicodePwd = PasswordChangeMethod("ActiveDirectoryUnicodePwd")
+)
+
+// ChangePassword changes the password of an end user after verifying their current password. It returns false with
+// a nil error when the upstream LDAP IDP refused to change the password. Implements provider.UpstreamLDAPIdentityProviderI.
+func (p *Provider) ChangePassword(ctx context.Context, username, currentPassword, newPassword string) (bool, error) {
+	t := trace.FromContext(ctx).Nest("slow ldap change password attempt", trace.Field{Key: "providerName", Value: p.GetName()})
+	defer t.LogIfLong(500 * time.Millisecond) // to help users debug slow LDAP operations
+
+	err := p.validateConfig()
+	if err != nil {
+		return false, err
+	}
+
+	if len(username) == 0 || len(currentPassword) == 0 || len(newPassword) == 0 {
+		return false, nil
+	}
+
+	conn, release, err := p.searchConn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	userEntry, err := p.searchForUser(conn, username)
+	if err != nil || userEntry == nil {
+		release(err)
+		return false, err
+	}
+
+	switch p.c.PasswordChangeMethod {
+	case ActiveDirectoryUnicodePwd:
+		err = conn.Modify(activeDirectoryPasswordChangeRequest(userEntry.DN, currentPassword, newPassword))
+		release(err)
+	default:
+		release(nil)
+		err = p.changePasswordAsEndUser(ctx, userEntry.DN, currentPassword, newPassword)
+	}
+
+	if err != nil {
+		plog.DebugErr("error changing password for user", err, "upstreamName", p.GetName(), "username", username, "dn", userEntry.DN)
+		if isPasswordChangeRefusedError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf(`error changing password for user %q: %w`, username, err)
+	}
+
+	plog.Debug("changed password for user", "upstreamName", p.GetName(), "username", username, "dn", userEntry.DN)
+	return true, nil
+}
+
+// changePasswordAsEndUser binds as the end user on a new connection, since pooled connections must stay bound as
+// the BindUsername, and then uses the password modify extended operation.
+func (p *Provider) changePasswordAsEndUser(ctx context.Context, userDN, currentPassword, newPassword string) error {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// When the password must be changed after a reset, the bind succeeds but only allows changing the password.
+	if _, err := p.bindAsEndUser(conn, userDN, currentPassword); err != nil {
+		return err
+	}
+
+	_, err = conn.PasswordModify(ldap.NewPasswordModifyRequest(userDN, currentPassword, newPassword))
+	return err
+}
+
+func activeDirectoryPasswordChangeRequest(userDN, currentPassword, newPassword string) *ldap.ModifyRequest {
+	modifyRequest := ldap.NewModifyRequest(userDN, nil)
+	modifyRequest.Delete(activeDirectoryPasswordAttribute, []string{activeDirectoryPasswordValue(currentPassword)})
+	modifyRequest.Add(activeDirectoryPasswordAttribute, []string{activeDirectoryPasswordValue(newPassword)})
+	return modifyRequest
+}
+
+// activeDirectoryPasswordValue encodes a password in the format required for the unicodePwd attribute,
+// which is the password surrounded by quotes, encoded as UTF-16LE.
+func activeDirectoryPasswordValue(password string) string {
+	encoded := utf16.Encode([]rune(`"` + password + `"`))
+	value := make([]byte, 2*len(encoded))
+	for i, codeUnit := range encoded {
+		binary.LittleEndian.PutUint16(value[2*i:], codeUnit)
+	}
+	return string(value)
+}
+
+// isPasswordChangeRequiredBindError returns true when an error from binding as the end user means that the
+// password was correct, but must be changed before the user may log in.
+func isPasswordChangeRequiredBindError(err error) bool {
+	ldapErr := &ldap.Error{}
+	if !errors.As(err, &ldapErr) || ldapErr.ResultCode != ldap.LDAPResultInvalidCredentials || ldapErr.Err == nil {
+		return false
+	}
+	message := ldapErr.Err.Error()
+	return strings.Contains(message, activeDirectoryPasswordExpired) || strings.Contains(message, activeDirectoryPasswordMustChange)
+}
+
+// isPasswordChangeRefusedError returns true when an error from changing a password means that the upstream LDAP IDP
+// refused the change, e.g. because the current password was wrong or the new password does not meet the password
+// policy, as opposed to an unexpected error.
+func isPasswordChangeRefusedError(err error) bool {
+	return ldap.IsErrorAnyOf(err,
+		ldap.LDAPResultInvalidCredentials,
+		ldap.LDAPResultConstraintViolation,
+		ldap.LDAPResultInsufficientAccessRights,
+		ldap.LDAPResultUnwillingToPerform,
+	)
+}
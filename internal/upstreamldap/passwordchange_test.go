@@ -0,0 +1,207 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/authenticators"
+	"go.pinniped.dev/internal/endpointaddr"
+	"go.pinniped.dev/internal/mocks/mockldapconn"
+)
+
+func TestChangePassword(t *testing.T) {
+	const (
+		testCurrentPassword = "some-current-password" //nolint:gosec
+		testNewPassword     = "some-new-password"     //nolint:gosec
+	)
+
+	setup := func(t *testing.T, method PasswordChangeMethod) (*Provider, *mockldapconn.MockConn) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		conn := mockldapconn.NewMockConn(ctrl)
+
+		p := New(ProviderConfig{
+			Name:               "some-provider-name",
+			Host:               testHost,
+			ConnectionProtocol: TLS,
+			BindUsername:       testBindUsername,
+			BindPassword:       testBindPassword,
+			UserSearch: UserSearchConfig{
+				Base:              testUserSearchBase,
+				Filter:            testUserSearchFilter,
+				UsernameAttribute: testUserSearchUsernameAttribute,
+				UIDAttribute:      testUserSearchUIDAttribute,
+			},
+			PasswordChangeMethod: method,
+			Dialer: LDAPDialerFunc(func(ctx context.Context, addr endpointaddr.HostPort) (Conn, error) {
+				return conn, nil
+			}),
+		})
+		return p, conn
+	}
+
+	expectUserSearch := func(conn *mockldapconn.MockConn, entries ...*ldap.Entry) {
+		conn.EXPECT().Bind(testBindUsername, testBindPassword)
+		conn.EXPECT().Search(gomock.Any()).Return(&ldap.SearchResult{Entries: entries}, nil)
+	}
+
+	userEntry := &ldap.Entry{DN: testUserSearchResultDNValue}
+
+	wantActiveDirectoryModifyRequest := &ldap.ModifyRequest{
+		DN: testUserSearchResultDNValue,
+		Changes: []ldap.Change{
+			{
+				Operation:    ldap.DeleteAttribute,
+				Modification: ldap.PartialAttribute{Type: "unicodePwd", Vals: []string{activeDirectoryPasswordValue(testCurrentPassword)}},
+			},
+			{
+				Operation:    ldap.AddAttribute,
+				Modification: ldap.PartialAttribute{Type: "unicodePwd", Vals: []string{activeDirectoryPasswordValue(testNewPassword)}},
+			},
+		},
+	}
+
+	t.Run("changes the password with the password modify extended operation while bound as the user", func(t *testing.T) {
+		p, conn := setup(t, PasswordModifyExtendedOperation)
+		expectUserSearch(conn, userEntry)
+		conn.EXPECT().Close().Times(2) // once for the search connection and once for the connection bound as the user
+		conn.EXPECT().Bind(testUserSearchResultDNValue, testCurrentPassword)
+		conn.EXPECT().PasswordModify(ldap.NewPasswordModifyRequest(testUserSearchResultDNValue, testCurrentPassword, testNewPassword)).
+			Return(&ldap.PasswordModifyResult{}, nil)
+
+		changed, err := p.ChangePassword(context.Background(), testUpstreamUsername, testCurrentPassword, testNewPassword)
+		require.NoError(t, err)
+		require.True(t, changed)
+	})
+
+	t.Run("returns false when the current password is wrong", func(t *testing.T) {
+		p, conn := setup(t, PasswordModifyExtendedOperation)
+		expectUserSearch(conn, userEntry)
+		conn.EXPECT().Close().Times(2)
+		conn.EXPECT().Bind(testUserSearchResultDNValue, testCurrentPassword).
+			Return(ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("some bind error")))
+
+		changed, err := p.ChangePassword(context.Background(), testUpstreamUsername, testCurrentPassword, testNewPassword)
+		require.NoError(t, err)
+		require.False(t, changed)
+	})
+
+	t.Run("returns false when the new password does not meet the password policy", func(t *testing.T) {
+		p, conn := setup(t, PasswordModifyExtendedOperation)
+		expectUserSearch(conn, userEntry)
+		conn.EXPECT().Close().Times(2)
+		conn.EXPECT().Bind(testUserSearchResultDNValue, testCurrentPassword)
+		conn.EXPECT().PasswordModify(gomock.Any()).
+			Return(nil, ldap.NewError(ldap.LDAPResultConstraintViolation, errors.New("password fails quality checking policy")))
+
+		changed, err := p.ChangePassword(context.Background(), testUpstreamUsername, testCurrentPassword, testNewPassword)
+		require.NoError(t, err)
+		require.False(t, changed)
+	})
+
+	t.Run("returns an error when the password change fails unexpectedly", func(t *testing.T) {
+		p, conn := setup(t, PasswordModifyExtendedOperation)
+		expectUserSearch(conn, userEntry)
+		conn.EXPECT().Close().Times(2)
+		conn.EXPECT().Bind(testUserSearchResultDNValue, testCurrentPassword)
+		conn.EXPECT().PasswordModify(gomock.Any()).
+			Return(nil, ldap.NewError(ldap.LDAPResultOperationsError, errors.New("some modify error")))
+
+		changed, err := p.ChangePassword(context.Background(), testUpstreamUsername, testCurrentPassword, testNewPassword)
+		require.EqualError(t, err, `error changing password for user "some-upstream-username": LDAP Result Code 1 "Operations Error": some modify error`)
+		require.False(t, changed)
+	})
+
+	t.Run("changes the password of an Active Directory user using the bind account", func(t *testing.T) {
+		p, conn := setup(t, ActiveDirectoryUnicodePwd)
+		expectUserSearch(conn, userEntry)
+		conn.EXPECT().Modify(wantActiveDirectoryModifyRequest)
+		conn.EXPECT().Close()
+
+		changed, err := p.ChangePassword(context.Background(), testUpstreamUsername, testCurrentPassword, testNewPassword)
+		require.NoError(t, err)
+		require.True(t, changed)
+	})
+
+	t.Run("returns false when Active Directory refuses to change the password", func(t *testing.T) {
+		p, conn := setup(t, ActiveDirectoryUnicodePwd)
+		expectUserSearch(conn, userEntry)
+		conn.EXPECT().Modify(wantActiveDirectoryModifyRequest).
+			Return(ldap.NewError(ldap.LDAPResultConstraintViolation, errors.New("0000052D: Constraint violation")))
+		conn.EXPECT().Close()
+
+		changed, err := p.ChangePassword(context.Background(), testUpstreamUsername, testCurrentPassword, testNewPassword)
+		require.NoError(t, err)
+		require.False(t, changed)
+	})
+
+	t.Run("returns false when the user is not found", func(t *testing.T) {
+		p, conn := setup(t, ActiveDirectoryUnicodePwd)
+		expectUserSearch(conn)
+		conn.EXPECT().Close()
+
+		changed, err := p.ChangePassword(context.Background(), testUpstreamUsername, testCurrentPassword, testNewPassword)
+		require.NoError(t, err)
+		require.False(t, changed)
+	})
+
+	t.Run("returns false without contacting the server when any input is blank", func(t *testing.T) {
+		p, _ := setup(t, ActiveDirectoryUnicodePwd)
+
+		for _, inputs := range [][3]string{
+			{"", testCurrentPassword, testNewPassword},
+			{testUpstreamUsername, "", testNewPassword},
+			{testUpstreamUsername, testCurrentPassword, ""},
+		} {
+			changed, err := p.ChangePassword(context.Background(), inputs[0], inputs[1], inputs[2])
+			require.NoError(t, err)
+			require.False(t, changed)
+		}
+	})
+
+	t.Run("login fails with a password change required error when Active Directory says the password must be changed", func(t *testing.T) {
+		p, conn := setup(t, ActiveDirectoryUnicodePwd)
+		conn.EXPECT().Bind(testBindUsername, testBindPassword)
+		conn.EXPECT().Search(gomock.Any()).Return(&ldap.SearchResult{Entries: []*ldap.Entry{{
+			DN: testUserSearchResultDNValue,
+			Attributes: []*ldap.EntryAttribute{
+				ldap.NewEntryAttribute(testUserSearchUsernameAttribute, []string{testUserSearchResultUsernameAttributeValue}),
+				ldap.NewEntryAttribute(testUserSearchUIDAttribute, []string{testUserSearchResultUIDAttributeValue}),
+			},
+		}}}, nil)
+		conn.EXPECT().Bind(testUserSearchResultDNValue, testCurrentPassword).Return(ldap.NewError(ldap.LDAPResultInvalidCredentials,
+			errors.New("80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 773, v4563")))
+		conn.EXPECT().Close()
+
+		response, authenticated, err := p.AuthenticateUser(context.Background(), testUpstreamUsername, testCurrentPassword)
+		require.EqualError(t, err, `user "some-upstream-username" must change their password: password change required`)
+		require.ErrorIs(t, err, authenticators.ErrPasswordChangeRequired)
+		require.False(t, authenticated)
+		require.Nil(t, response)
+	})
+}
+
+func TestActiveDirectoryPasswordValue(t *testing.T) {
+	require.Equal(t, "\"\x00p\x00w\x00\"\x00", activeDirectoryPasswordValue("pw"))
+	require.Equal(t, "\"\x00\xe9\x00\"\x00", activeDirectoryPasswordValue("é"))
+}
+
+func TestIsPasswordChangeRequiredBindError(t *testing.T) {
+	require.True(t, isPasswordChangeRequiredBindError(ldap.NewError(ldap.LDAPResultInvalidCredentials,
+		errors.New("80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 532, v4563"))))
+	require.True(t, isPasswordChangeRequiredBindError(ldap.NewError(ldap.LDAPResultInvalidCredentials,
+		errors.New("80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 773, v4563"))))
+	require.False(t, isPasswordChangeRequiredBindError(ldap.NewError(ldap.LDAPResultInvalidCredentials,
+		errors.New("80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 52e, v4563"))))
+	require.False(t, isPasswordChangeRequiredBindError(ldap.NewError(ldap.LDAPResultOperationsError,
+		errors.New("data 773"))))
+	require.False(t, isPasswordChangeRequiredBindError(errors.New("data 773")))
+}
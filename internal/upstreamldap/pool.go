@@ -0,0 +1,418 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// defaultPoolMaxOpen is the default maximum number of search-bind connections which may be open at the
+	// same time to a single upstream LDAP provider.
+	defaultPoolMaxOpen = 8
+
+	// defaultPoolIdleTimeout is the default amount of time that an unused connection will be kept in the pool.
+	// This should be shorter than the idle timeout of most LDAP servers, e.g. Active Directory's MaxConnIdleTime.
+	defaultPoolIdleTimeout = 90 * time.Second
+)
+
+// ConnectionPoolConfig configures the connection pools which are created by ConnectionPools.
+type ConnectionPoolConfig struct {
+	// MaxOpen is the maximum number of connections to an upstream LDAP provider which may be open at the same
+	// time, including both idle connections and connections which are in use. Zero means to use the default.
+	MaxOpen int
+
+	// IdleTimeout is how long a connection may stay idle in the pool before it is closed. Zero means to use
+	// the default.
+	IdleTimeout time.Duration
+
+	// UpstreamType identifies the type of the upstream providers, e.g. "ldap", in the metrics of the pools.
+	// Pools without an UpstreamType do not report metrics.
+	UpstreamType string
+}
+
+// ConnectionPoolStats are the usage counters of a ConnectionPool.
+type ConnectionPoolStats struct {
+	// Idle is the number of open connections which are waiting in the pool to be used.
+	Idle int
+	// InUse is the number of connections which are currently checked out of the pool.
+	InUse int
+	// Dials is the number of new connections which were dialed and bound.
+	Dials uint64
+	// Reuses is the number of times that an idle connection was taken from the pool instead of dialing.
+	Reuses uint64
+	// Rebinds is the number of times that an idle connection had to be bound again because the bind
+	// username or password had changed since it was last bound.
+	Rebinds uint64
+	// Waits is the number of times that a caller had to wait because the maximum number of connections were in use.
+	Waits uint64
+	// Discards is the number of connections which were closed because they were unhealthy, had been idle
+	// for too long, or were dialed using outdated connection settings.
+	Discards uint64
+	// Retries is the number of times that an operation was retried on a new connection because the idle
+	// connection which it was first performed on turned out to be broken.
+	Retries uint64
+}
+
+// ConnectionPools holds one ConnectionPool per upstream LDAP provider. Providers are frequently recreated
+// by the upstream watcher controllers, so the pools are kept here, keyed by the provider's resource UID,
+// to allow the connections to outlive each Provider.
+type ConnectionPools struct {
+	config ConnectionPoolConfig
+	clock  clock.Clock
+
+	lock  sync.Mutex
+	pools map[types.UID]*ConnectionPool
+	names map[types.UID]string
+}
+
+// NewConnectionPools returns an empty set of connection pools which will use the given configuration.
+func NewConnectionPools(config ConnectionPoolConfig) *ConnectionPools {
+	if config.MaxOpen <= 0 {
+		config.MaxOpen = defaultPoolMaxOpen
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaultPoolIdleTimeout
+	}
+	ps := &ConnectionPools{
+		config: config,
+		clock:  clock.RealClock{},
+		pools:  map[types.UID]*ConnectionPool{},
+		names:  map[types.UID]string{},
+	}
+	if config.UpstreamType != "" {
+		poolMetrics.add(ps)
+	}
+	return ps
+}
+
+// ForProvider returns the ConnectionPool for the upstream provider with the given resource UID, creating it if needed.
+func (ps *ConnectionPools) ForProvider(uid types.UID) *ConnectionPool {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	pool, ok := ps.pools[uid]
+	if !ok {
+		pool = &ConnectionPool{
+			config: ps.config,
+			clock:  ps.clock,
+			slots:  make(chan struct{}, ps.config.MaxOpen),
+			hosts:  newHostTracker(ps.clock),
+		}
+		ps.pools[uid] = pool
+	}
+	return pool
+}
+
+// Prune closes the pools of any providers whose resource UIDs are not keys of the given map, and closes the
+// connections which have been idle for too long in the remaining pools. The values of the map are the names
+// of the providers, which are used to label the metrics of their pools.
+func (ps *ConnectionPools) Prune(keep map[types.UID]string) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	ps.names = make(map[types.UID]string, len(keep))
+	for uid, name := range keep {
+		ps.names[uid] = name
+	}
+
+	for uid, pool := range ps.pools {
+		if _, ok := keep[uid]; !ok {
+			pool.close()
+			delete(ps.pools, uid)
+			continue
+		}
+		pool.closeExpiredIdle()
+	}
+}
+
+// Stats returns the usage counters of each pool, keyed by the provider's resource UID.
+func (ps *ConnectionPools) Stats() map[types.UID]ConnectionPoolStats {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	stats := make(map[types.UID]ConnectionPoolStats, len(ps.pools))
+	for uid, pool := range ps.pools {
+		stats[uid] = pool.Stats()
+	}
+	return stats
+}
+
+// statsByName returns the usage counters of the pools of the providers whose names are known.
+func (ps *ConnectionPools) statsByName() map[string]ConnectionPoolStats {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	stats := make(map[string]ConnectionPoolStats, len(ps.pools))
+	for uid, pool := range ps.pools {
+		if name, ok := ps.names[uid]; ok {
+			stats[name] = pool.Stats()
+		}
+	}
+	return stats
+}
+
+// ConnectionPool is a bounded pool of connections to a single upstream LDAP provider which are bound as the
+// provider's bind user. It must only be used for operations performed as the bind user. Connections which
+// need to be bound as any other user must not be returned to the pool.
+type ConnectionPool struct {
+	config ConnectionPoolConfig
+	clock  clock.Clock
+
+	// slots limits the number of connections which are in use. A value is sent for each connection which is
+	// checked out of the pool. Since a new connection is only dialed when there are no idle connections, this
+	// also limits the total number of open connections.
+	slots chan struct{}
+
+	// hosts remembers which hosts of the provider recently could not be dialed.
+	hosts *hostTracker
+
+	lock   sync.Mutex
+	idle   []*pooledConn // most recently used connections are at the end
+	stats  ConnectionPoolStats
+	closed bool
+}
+
+// pooledConn remembers how a Conn was dialed and bound, so it can be validated before it is reused.
+type pooledConn struct {
+	Conn
+	settings     connectionSettings
+	bindUsername string
+	bindPassword string
+	lastUsed     time.Time
+}
+
+// connectionSettings are the settings which decide where and how a connection was dialed. A pooled
+// connection which was dialed with different settings will never be reused. This includes the client
+// certificate, since it was presented during the TLS handshake and cannot be changed by binding again.
+type connectionSettings struct {
+	hosts             string
+	protocol          LDAPConnectionProtocol
+	caBundle          string
+	clientCertificate string
+}
+
+// Stats returns the current usage counters of the pool.
+func (cp *ConnectionPool) Stats() ConnectionPoolStats {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	stats := cp.stats
+	stats.Idle = len(cp.idle)
+	stats.InUse = len(cp.slots)
+	return stats
+}
+
+// do performs the given operation on a connection which is bound as the provider's current bind user. An idle
+// connection can be broken without the client knowing, e.g. when the server or a firewall silently dropped it, so
+// when the operation fails on an idle connection in a way which means that the connection is broken, the operation
+// is retried once on a newly dialed connection. The operation must therefore be safe to perform twice.
+func (cp *ConnectionPool) do(ctx context.Context, p *Provider, op func(conn Conn) error) error {
+	conn, release, reused, err := cp.checkout(ctx, p, true)
+	if err != nil {
+		return err
+	}
+	err = op(conn)
+	release(err)
+	if !reused || isReusableAfter(err) {
+		return err
+	}
+
+	plog.DebugErr("retrying ldap operation on a new connection", err, "upstreamName", p.GetName())
+	cp.count(func(s *ConnectionPoolStats) { s.Retries++ })
+	conn, release, _, err = cp.checkout(ctx, p, false)
+	if err != nil {
+		return err
+	}
+	err = op(conn)
+	release(err)
+	return err
+}
+
+// get returns a connection which is bound as the provider's current bind user, reusing an idle connection when
+// possible. The returned release func must be called exactly once when the caller is done with the connection,
+// with the error from the last operation performed on the connection (if any).
+func (cp *ConnectionPool) get(ctx context.Context, p *Provider) (Conn, func(error), error) {
+	conn, release, _, err := cp.checkout(ctx, p, true)
+	return conn, release, err
+}
+
+// checkout is like get, but it only reuses an idle connection when allowed to, and it also returns whether the
+// connection was reused.
+func (cp *ConnectionPool) checkout(ctx context.Context, p *Provider, allowIdle bool) (Conn, func(error), bool, error) {
+	if err := cp.acquireSlot(ctx); err != nil {
+		return nil, nil, false, err
+	}
+
+	settings := p.connectionSettings()
+	for allowIdle {
+		pc := cp.popIdle()
+		if pc == nil {
+			break
+		}
+		if pc.settings != settings || cp.expired(pc) || isClosing(pc.Conn) {
+			cp.discard(pc.Conn)
+			continue
+		}
+		if pc.bindUsername != p.c.BindUsername || pc.bindPassword != p.c.BindPassword {
+			// The bind Secret has been rotated since this connection was bound, so bind again.
+			if err := p.bind(pc.Conn); err != nil {
+				cp.discard(pc.Conn)
+				cp.releaseSlot()
+				return nil, nil, false, fmt.Errorf(`error binding as %q before user search: %w`, p.c.BindUsername, err)
+			}
+			pc.bindUsername, pc.bindPassword = p.c.BindUsername, p.c.BindPassword
+			cp.count(func(s *ConnectionPoolStats) { s.Rebinds++ })
+		}
+		cp.count(func(s *ConnectionPoolStats) { s.Reuses++ })
+		return pc.Conn, cp.releaseFunc(pc), true, nil
+	}
+
+	conn, err := p.dialAndBind(ctx)
+	if err != nil {
+		cp.releaseSlot()
+		return nil, nil, false, err
+	}
+	cp.count(func(s *ConnectionPoolStats) { s.Dials++ })
+
+	pc := &pooledConn{
+		Conn:         conn,
+		settings:     settings,
+		bindUsername: p.c.BindUsername,
+		bindPassword: p.c.BindPassword,
+	}
+	return conn, cp.releaseFunc(pc), false, nil
+}
+
+func (cp *ConnectionPool) acquireSlot(ctx context.Context) error {
+	select {
+	case cp.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	cp.count(func(s *ConnectionPoolStats) { s.Waits++ })
+	select {
+	case cp.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ldap.NewError(ldap.ErrorNetwork, fmt.Errorf("timed out waiting for an available connection: %w", ctx.Err()))
+	}
+}
+
+func (cp *ConnectionPool) releaseSlot() {
+	<-cp.slots
+}
+
+func (cp *ConnectionPool) releaseFunc(pc *pooledConn) func(error) {
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			cp.lock.Lock()
+			reusable := !cp.closed && isReusableAfter(err) && !isClosing(pc.Conn)
+			if reusable {
+				pc.lastUsed = cp.clock.Now()
+				cp.idle = append(cp.idle, pc)
+			}
+			cp.lock.Unlock()
+
+			if !reusable {
+				cp.discard(pc.Conn)
+			}
+			cp.releaseSlot()
+		})
+	}
+}
+
+func (cp *ConnectionPool) popIdle() *pooledConn {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	if len(cp.idle) == 0 {
+		return nil
+	}
+	pc := cp.idle[len(cp.idle)-1]
+	cp.idle = cp.idle[:len(cp.idle)-1]
+	return pc
+}
+
+func (cp *ConnectionPool) expired(pc *pooledConn) bool {
+	return cp.clock.Since(pc.lastUsed) > cp.config.IdleTimeout
+}
+
+func (cp *ConnectionPool) discard(conn Conn) {
+	conn.Close()
+	cp.count(func(s *ConnectionPoolStats) { s.Discards++ })
+}
+
+func (cp *ConnectionPool) count(f func(s *ConnectionPoolStats)) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	f(&cp.stats)
+}
+
+// closeExpiredIdle closes the connections which have been idle for longer than the idle timeout.
+func (cp *ConnectionPool) closeExpiredIdle() {
+	cp.lock.Lock()
+	var expired []*pooledConn
+	kept := cp.idle[:0]
+	for _, pc := range cp.idle {
+		if cp.expired(pc) {
+			expired = append(expired, pc)
+		} else {
+			kept = append(kept, pc)
+		}
+	}
+	cp.idle = kept
+	cp.lock.Unlock()
+
+	for _, pc := range expired {
+		cp.discard(pc.Conn)
+	}
+}
+
+// close closes all idle connections and makes sure that connections which are currently in use will be closed
+// instead of being returned to the pool.
+func (cp *ConnectionPool) close() {
+	cp.lock.Lock()
+	cp.closed = true
+	idle := cp.idle
+	cp.idle = nil
+	cp.lock.Unlock()
+
+	for _, pc := range idle {
+		cp.discard(pc.Conn)
+	}
+}
+
+// isReusableAfter decides if a connection may be returned to the pool after an operation returned the given error.
+// Errors which were returned by the server about the operation itself, e.g. no such object, and errors which did
+// not come from the LDAP client at all leave the connection usable. Errors from the client library, e.g. network
+// errors, and errors that mean the server is unavailable do not.
+func isReusableAfter(err error) bool {
+	ldapErr := &ldap.Error{}
+	if err == nil || !errors.As(err, &ldapErr) {
+		return true
+	}
+	if ldapErr.ResultCode >= ldap.ErrorNetwork {
+		return false // client-side errors, which are numbered starting from ErrorNetwork
+	}
+	return !ldap.IsErrorAnyOf(ldapErr, ldap.LDAPResultBusy, ldap.LDAPResultUnavailable, ldap.LDAPResultUnwillingToPerform)
+}
+
+// isClosing returns true when the Conn knows that it has been closed, e.g. because the server closed it.
+func isClosing(conn Conn) bool {
+	closer, ok := conn.(interface{ IsClosing() bool })
+	return ok && closer.IsClosing()
+}
@@ -0,0 +1,329 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"go.pinniped.dev/internal/endpointaddr"
+	"go.pinniped.dev/internal/mocks/mockldapconn"
+)
+
+func TestConnectionPool(t *testing.T) {
+	const providerUID = types.UID("some-provider-uid")
+
+	userSearchResult := &ldap.SearchResult{
+		Entries: []*ldap.Entry{
+			{
+				DN: testUserSearchResultDNValue,
+				Attributes: []*ldap.EntryAttribute{
+					ldap.NewEntryAttribute(testUserSearchUsernameAttribute, []string{testUserSearchResultUsernameAttributeValue}),
+					ldap.NewEntryAttribute(testUserSearchUIDAttribute, []string{testUserSearchResultUIDAttributeValue}),
+				},
+			},
+		},
+	}
+
+	// setup returns a Provider which uses the pool and a dialer which hands out the given connections in order.
+	setup := func(t *testing.T, pool *ConnectionPool, conns ...Conn) (func(editFunc func(*ProviderConfig)) *Provider, *int) {
+		dials := 0
+		dialer := LDAPDialerFunc(func(ctx context.Context, addr endpointaddr.HostPort) (Conn, error) {
+			require.Less(t, dials, len(conns), "unexpected dial")
+			conn := conns[dials]
+			dials++
+			return conn, nil
+		})
+		newProvider := func(editFunc func(*ProviderConfig)) *Provider {
+			config := ProviderConfig{
+				Name:               "some-provider-name",
+				ResourceUID:        providerUID,
+				Host:               testHost,
+				ConnectionProtocol: TLS,
+				BindUsername:       testBindUsername,
+				BindPassword:       testBindPassword,
+				UserSearch: UserSearchConfig{
+					Base:              testUserSearchBase,
+					Filter:            testUserSearchFilter,
+					UsernameAttribute: testUserSearchUsernameAttribute,
+					UIDAttribute:      testUserSearchUIDAttribute,
+				},
+				Dialer:         dialer,
+				ConnectionPool: pool,
+			}
+			if editFunc != nil {
+				editFunc(&config)
+			}
+			return New(config)
+		}
+		return newProvider, &dials
+	}
+
+	t.Run("the search connection is reused and end users are bound on their own connections", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		searchConn := mockldapconn.NewMockConn(ctrl)
+		userConn1 := mockldapconn.NewMockConn(ctrl)
+		userConn2 := mockldapconn.NewMockConn(ctrl)
+
+		searchConn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+		searchConn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil).Times(2)
+		userConn1.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
+		userConn1.EXPECT().Close().Times(1)
+		userConn2.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
+		userConn2.EXPECT().Close().Times(1)
+
+		pools := NewConnectionPools(ConnectionPoolConfig{})
+		newProvider, dials := setup(t, pools.ForProvider(providerUID), searchConn, userConn1, userConn2)
+
+		for i := 0; i < 2; i++ {
+			// Providers are recreated by the watchers, but they share the pool.
+			response, authenticated, err := newProvider(nil).AuthenticateUser(context.Background(), testUpstreamUsername, testUpstreamPassword)
+			require.NoError(t, err)
+			require.True(t, authenticated)
+			require.Equal(t, testUserSearchResultUsernameAttributeValue, response.User.GetName())
+		}
+
+		require.Equal(t, 3, *dials)
+		require.Equal(t, map[types.UID]ConnectionPoolStats{
+			providerUID: {Idle: 1, Dials: 1, Reuses: 1},
+		}, pools.Stats())
+	})
+
+	t.Run("idle connections are bound again when the bind password changes", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		searchConn := mockldapconn.NewMockConn(ctrl)
+
+		gomock.InOrder(
+			searchConn.EXPECT().Bind(testBindUsername, testBindPassword),
+			searchConn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+			searchConn.EXPECT().Bind(testBindUsername, "some-rotated-password"),
+			searchConn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+		)
+
+		pools := NewConnectionPools(ConnectionPoolConfig{})
+		newProvider, dials := setup(t, pools.ForProvider(providerUID), searchConn)
+
+		_, authenticated, err := newProvider(nil).DryRunAuthenticateUser(context.Background(), testUpstreamUsername)
+		require.NoError(t, err)
+		require.True(t, authenticated)
+
+		_, authenticated, err = newProvider(func(c *ProviderConfig) {
+			c.BindPassword = "some-rotated-password"
+		}).DryRunAuthenticateUser(context.Background(), testUpstreamUsername)
+		require.NoError(t, err)
+		require.True(t, authenticated)
+
+		require.Equal(t, 1, *dials)
+		require.Equal(t, ConnectionPoolStats{Idle: 1, Dials: 1, Reuses: 1, Rebinds: 1}, pools.ForProvider(providerUID).Stats())
+	})
+
+	t.Run("idle connections are not reused after the connection settings change", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		oldConn := mockldapconn.NewMockConn(ctrl)
+		newConn := mockldapconn.NewMockConn(ctrl)
+
+		oldConn.EXPECT().Bind(testBindUsername, testBindPassword)
+		oldConn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil)
+		oldConn.EXPECT().Close()
+		newConn.EXPECT().Bind(testBindUsername, testBindPassword)
+		newConn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil)
+
+		pools := NewConnectionPools(ConnectionPoolConfig{})
+		newProvider, dials := setup(t, pools.ForProvider(providerUID), oldConn, newConn)
+
+		_, _, err := newProvider(nil).DryRunAuthenticateUser(context.Background(), testUpstreamUsername)
+		require.NoError(t, err)
+		_, _, err = newProvider(func(c *ProviderConfig) {
+			c.Host = "some-other-host.example.com"
+		}).DryRunAuthenticateUser(context.Background(), testUpstreamUsername)
+		require.NoError(t, err)
+
+		require.Equal(t, 2, *dials)
+		require.Equal(t, ConnectionPoolStats{Idle: 1, Dials: 2, Discards: 1}, pools.ForProvider(providerUID).Stats())
+	})
+
+	t.Run("client certificate binds use SASL EXTERNAL and are not reused after the certificate is rotated", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		oldConn := mockldapconn.NewMockConn(ctrl)
+		newConn := mockldapconn.NewMockConn(ctrl)
+
+		oldConn.EXPECT().ExternalBind()
+		oldConn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil).Times(2)
+		oldConn.EXPECT().Close()
+		newConn.EXPECT().ExternalBind()
+		newConn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil)
+
+		pools := NewConnectionPools(ConnectionPoolConfig{})
+		newProvider, dials := setup(t, pools.ForProvider(providerUID), oldConn, newConn)
+		withClientCertificate := func(cert string) func(c *ProviderConfig) {
+			return func(c *ProviderConfig) {
+				c.BindUsername = "CN=some-client"
+				c.BindPassword = ""
+				c.ClientCertificateData = []byte(cert)
+				c.ClientKeyData = []byte("some-key")
+			}
+		}
+
+		for _, cert := range []string{"some-cert", "some-cert", "some-rotated-cert"} {
+			_, authenticated, err := newProvider(withClientCertificate(cert)).DryRunAuthenticateUser(context.Background(), testUpstreamUsername)
+			require.NoError(t, err)
+			require.True(t, authenticated)
+		}
+
+		require.Equal(t, 2, *dials)
+		require.Equal(t, ConnectionPoolStats{Idle: 1, Dials: 2, Reuses: 1, Discards: 1}, pools.ForProvider(providerUID).Stats())
+	})
+
+	t.Run("connections are discarded after network errors", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		brokenConn := mockldapconn.NewMockConn(ctrl)
+		newConn := mockldapconn.NewMockConn(ctrl)
+
+		brokenConn.EXPECT().Bind(testBindUsername, testBindPassword)
+		brokenConn.EXPECT().Search(gomock.Any()).Return(nil, ldap.NewError(ldap.ErrorNetwork, context.DeadlineExceeded))
+		brokenConn.EXPECT().Close()
+		newConn.EXPECT().Bind(testBindUsername, testBindPassword)
+		newConn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil)
+
+		pools := NewConnectionPools(ConnectionPoolConfig{})
+		newProvider, dials := setup(t, pools.ForProvider(providerUID), brokenConn, newConn)
+
+		_, _, err := newProvider(nil).DryRunAuthenticateUser(context.Background(), testUpstreamUsername)
+		require.EqualError(t, err, `error searching for user: LDAP Result Code 200 "Network Error": context deadline exceeded`)
+		_, authenticated, err := newProvider(nil).DryRunAuthenticateUser(context.Background(), testUpstreamUsername)
+		require.NoError(t, err)
+		require.True(t, authenticated)
+
+		require.Equal(t, 2, *dials)
+		require.Equal(t, ConnectionPoolStats{Idle: 1, Dials: 2, Discards: 1}, pools.ForProvider(providerUID).Stats())
+	})
+
+	t.Run("operations which fail on a broken idle connection are retried once on a new connection", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		staleConn := mockldapconn.NewMockConn(ctrl)
+		newConn := mockldapconn.NewMockConn(ctrl)
+
+		gomock.InOrder(
+			staleConn.EXPECT().Bind(testBindUsername, testBindPassword),
+			staleConn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+			staleConn.EXPECT().Search(gomock.Any()).Return(nil, ldap.NewError(ldap.ErrorNetwork, io.EOF)),
+			staleConn.EXPECT().Close(),
+		)
+		newConn.EXPECT().Bind(testBindUsername, testBindPassword)
+		newConn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil)
+
+		pools := NewConnectionPools(ConnectionPoolConfig{})
+		newProvider, dials := setup(t, pools.ForProvider(providerUID), staleConn, newConn)
+
+		for i := 0; i < 2; i++ {
+			_, authenticated, err := newProvider(nil).DryRunAuthenticateUser(context.Background(), testUpstreamUsername)
+			require.NoError(t, err)
+			require.True(t, authenticated)
+		}
+
+		require.Equal(t, 2, *dials)
+		require.Equal(t, ConnectionPoolStats{Idle: 1, Dials: 2, Reuses: 1, Discards: 1, Retries: 1}, pools.ForProvider(providerUID).Stats())
+	})
+
+	t.Run("connections are not discarded after errors about the operation itself", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		conn := mockldapconn.NewMockConn(ctrl)
+
+		conn.EXPECT().Bind(testBindUsername, testBindPassword)
+		conn.EXPECT().Search(gomock.Any()).Return(nil, ldap.NewError(ldap.LDAPResultNoSuchObject, nil)).Times(2)
+
+		pools := NewConnectionPools(ConnectionPoolConfig{})
+		newProvider, dials := setup(t, pools.ForProvider(providerUID), conn)
+
+		for i := 0; i < 2; i++ {
+			_, _, err := newProvider(nil).DryRunAuthenticateUser(context.Background(), testUpstreamUsername)
+			require.Error(t, err)
+		}
+
+		require.Equal(t, 1, *dials)
+		require.Equal(t, ConnectionPoolStats{Idle: 1, Dials: 1, Reuses: 1}, pools.ForProvider(providerUID).Stats())
+	})
+
+	t.Run("callers wait for a connection when the maximum number of connections are in use", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		conn := mockldapconn.NewMockConn(ctrl)
+
+		conn.EXPECT().Bind(testBindUsername, testBindPassword)
+
+		pools := NewConnectionPools(ConnectionPoolConfig{MaxOpen: 1})
+		pool := pools.ForProvider(providerUID)
+		newProvider, _ := setup(t, pool, conn)
+
+		_, release, err := pool.get(context.Background(), newProvider(nil))
+		require.NoError(t, err)
+		require.Equal(t, ConnectionPoolStats{InUse: 1, Dials: 1}, pool.Stats())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, _, err = pool.get(ctx, newProvider(nil))
+		require.EqualError(t, err, `LDAP Result Code 200 "Network Error": timed out waiting for an available connection: context deadline exceeded`)
+
+		release(nil)
+		reused, release, err := pool.get(context.Background(), newProvider(nil))
+		require.NoError(t, err)
+		require.Equal(t, conn, reused)
+		release(nil)
+
+		require.Equal(t, ConnectionPoolStats{Idle: 1, Dials: 1, Reuses: 1, Waits: 1}, pool.Stats())
+	})
+
+	t.Run("pruning closes idle connections which have expired and the pools of removed providers", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		expiredConn := mockldapconn.NewMockConn(ctrl)
+		otherConn := mockldapconn.NewMockConn(ctrl)
+
+		expiredConn.EXPECT().Bind(testBindUsername, testBindPassword)
+		expiredConn.EXPECT().Close()
+		otherConn.EXPECT().Bind(testBindUsername, testBindPassword)
+		otherConn.EXPECT().Close()
+
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+		pools := NewConnectionPools(ConnectionPoolConfig{IdleTimeout: time.Minute})
+		pools.clock = fakeClock
+
+		newProvider, _ := setup(t, pools.ForProvider(providerUID), expiredConn)
+		_, release, err := pools.ForProvider(providerUID).get(context.Background(), newProvider(nil))
+		require.NoError(t, err)
+		release(nil)
+
+		newOtherProvider, _ := setup(t, pools.ForProvider("some-other-uid"), otherConn)
+		_, release, err = pools.ForProvider("some-other-uid").get(context.Background(), newOtherProvider(nil))
+		require.NoError(t, err)
+		release(nil)
+
+		fakeClock.Step(30 * time.Second)
+		pools.Prune(map[types.UID]string{providerUID: "some-provider-name"})
+		require.Equal(t, map[types.UID]ConnectionPoolStats{
+			providerUID: {Idle: 1, Dials: 1},
+		}, pools.Stats())
+
+		fakeClock.Step(31 * time.Second)
+		pools.Prune(map[types.UID]string{providerUID: "some-provider-name"})
+		require.Equal(t, map[types.UID]ConnectionPoolStats{
+			providerUID: {Dials: 1, Discards: 1},
+		}, pools.Stats())
+	})
+}
@@ -18,8 +18,10 @@ import (
 
 	"github.com/go-ldap/ldap/v3"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/utils/clock"
 	"k8s.io/utils/trace"
 
 	"go.pinniped.dev/internal/authenticators"
@@ -43,6 +45,14 @@ const (
 type Conn interface {
 	Bind(username, password string) error
 
+	SimpleBind(simpleBindRequest *ldap.SimpleBindRequest) (*ldap.SimpleBindResult, error)
+
+	ExternalBind() error
+
+	Modify(modifyRequest *ldap.ModifyRequest) error
+
+	PasswordModify(passwordModifyRequest *ldap.PasswordModifyRequest) (*ldap.PasswordModifyResult, error)
+
 	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
 
 	SearchWithPaging(searchRequest *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error)
@@ -85,9 +95,24 @@ type ProviderConfig struct {
 	ResourceUID types.UID
 
 	// Host is the hostname or "hostname:port" of the LDAP server. When the port is not specified,
-	// the default LDAP port will be used.
+	// the default LDAP port will be used. Host is also used to identify the provider in GetURL, so
+	// it should stay the same when the other hosts change. Can be empty when HostDiscovery is used.
 	Host string
 
+	// AdditionalHosts are the hostnames or "hostname:port" of other LDAP servers which serve the same
+	// directory as the Host. They are tried when the Host cannot be reached.
+	AdditionalHosts []string
+
+	// HostDiscovery contains information about how to discover more LDAP servers using DNS. When its
+	// SRVDomain is empty, no hosts will be discovered.
+	HostDiscovery HostDiscoveryConfig
+
+	// HostSelection decides which host is dialed first. Empty means to use Failover.
+	HostSelection HostSelectionPolicy
+
+	// Resolver exists to enable testing. When nil, will use a default appropriate for production use.
+	Resolver SRVResolver
+
 	// ConnectionProtocol determines how to establish the connection to the server. Either StartTLS or TLS.
 	ConnectionProtocol LDAPConnectionProtocol
 
@@ -100,6 +125,13 @@ type ProviderConfig struct {
 	// BindPassword is the password to use when performing a bind with the upstream LDAP IDP.
 	BindPassword string
 
+	// ClientCertificateData and ClientKeyData are the PEM-encoded client certificate and private key to present
+	// to the upstream LDAP IDP during the TLS handshake. When they are set, the bind is performed using SASL EXTERNAL,
+	// i.e. using the identity of the client certificate, instead of using the BindUsername and BindPassword. In that
+	// case the BindUsername is only used to describe the bind user in messages.
+	ClientCertificateData []byte
+	ClientKeyData         []byte
+
 	// UserSearch contains information about how to search for users in the upstream LDAP IDP.
 	UserSearch UserSearchConfig
 
@@ -109,6 +141,10 @@ type ProviderConfig struct {
 	// Dialer exists to enable testing. When nil, will use a default appropriate for production use.
 	Dialer LDAPDialer
 
+	// SearchObserver, when set, is called with each search request which is sent to the upstream LDAP IDP along
+	// with its result or error. It exists to help debug configurations, e.g. from the pinniped CLI.
+	SearchObserver func(request *ldap.SearchRequest, result *ldap.SearchResult, err error)
+
 	// UIDAttributeParsingOverrides are mappings between an attribute name and a way to parse it as a UID when
 	// it comes out of LDAP.
 	UIDAttributeParsingOverrides map[string]func(*ldap.Entry) (string, error)
@@ -119,6 +155,27 @@ type ProviderConfig struct {
 
 	// RefreshAttributeChecks are extra checks that attributes in a refresh response are as expected.
 	RefreshAttributeChecks map[string]func(*ldap.Entry, provider.StoredRefreshAttributes) error
+
+	// AccountStatus contains information about how to check that the user's account may be used during logins and
+	// refreshes, and when to warn the user about their upcoming password expiration.
+	AccountStatus AccountStatusConfig
+
+	// PasswordChangeMethod decides how ChangePassword changes a user's password. Empty means to use the
+	// password modify extended operation.
+	PasswordChangeMethod PasswordChangeMethod
+
+	// ConnectionPool, when set, is used to reuse the connections which are bound as the BindUsername for user
+	// searches during logins and refreshes. When nil, a new connection is dialed and bound for each operation.
+	// The ConnectionPool also remembers which hosts recently could not be reached, so they can be avoided.
+	ConnectionPool *ConnectionPool
+
+	// GroupCache, when set and enabled by the GroupSearch Cache config, is used to remember the groups which were
+	// found for each user during logins and refreshes, so that refreshes may skip the group search.
+	GroupCache *GroupCache
+
+	// Kerberos contains information about how to validate the Kerberos tickets which browsers may present during
+	// logins. When its Keytab is nil, users can only log in with their username and password.
+	Kerberos KerberosConfig
 }
 
 // UserSearchConfig contains information about how to search for users in the upstream LDAP IDP.
@@ -136,6 +193,10 @@ type UserSearchConfig struct {
 	// UIDAttribute is the attribute in the LDAP entry from which the user's unique ID should be
 	// retrieved.
 	UIDAttribute string
+
+	// AdditionalClaimAttributes maps the names of additional downstream claims to the attributes in the LDAP entry
+	// from which their values should be retrieved. Attributes which are missing from the entry are skipped.
+	AdditionalClaimAttributes map[string]string
 }
 
 // GroupSearchConfig contains information about how to search for group membership for users in the upstream LDAP IDP.
@@ -155,10 +216,20 @@ type GroupSearchConfig struct {
 	// (every 5 minutes). This can be done if group search is very slow or resource intensive for the LDAP
 	// server.
 	SkipGroupRefresh bool
+
+	// NestedGroupsMaxDepth is the maximum number of times that the group search is repeated for the groups
+	// that were already found, to find the groups of which those groups are members. Zero means to only find
+	// the groups of which the user is directly a member.
+	NestedGroupsMaxDepth int
+
+	// Cache configures how long the groups found for a user may be reused by refreshes, when the ProviderConfig
+	// has a GroupCache. The zero value disables caching.
+	Cache GroupCacheConfig
 }
 
 type Provider struct {
-	c ProviderConfig
+	c     ProviderConfig
+	clock clock.Clock
 }
 
 var _ provider.UpstreamLDAPIdentityProviderI = &Provider{}
@@ -167,7 +238,7 @@ var _ authenticators.UserAuthenticator = &Provider{}
 // Create a Provider. The config is not a pointer to ensure that a copy of the config is created,
 // making the resulting Provider use an effectively read-only configuration.
 func New(config ProviderConfig) *Provider {
-	return &Provider{c: config}
+	return &Provider{c: config, clock: clock.RealClock{}}
 }
 
 // A reader for the config. Returns a copy of the config to keep the underlying config read-only.
@@ -175,75 +246,129 @@ func (p *Provider) GetConfig() ProviderConfig {
 	return p.c
 }
 
-func (p *Provider) PerformRefresh(ctx context.Context, storedRefreshAttributes provider.StoredRefreshAttributes) ([]string, error) {
+func (p *Provider) PerformRefresh(ctx context.Context, storedRefreshAttributes provider.StoredRefreshAttributes) ([]string, map[string]string, []string, error) {
 	t := trace.FromContext(ctx).Nest("slow ldap refresh attempt", trace.Field{Key: "providerName", Value: p.GetName()})
 	defer t.LogIfLong(500 * time.Millisecond) // to help users debug slow LDAP searches
-	userDN := storedRefreshAttributes.DN
 
-	conn, err := p.dial(ctx)
+	var groups, warnings []string
+	var additionalClaims map[string]string
+	err := p.withSearchConn(ctx, func(conn Conn) error {
+		var err error
+		groups, additionalClaims, warnings, err = p.performRefresh(t, conn, storedRefreshAttributes)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf(`error dialing host %q: %w`, p.c.Host, err)
+		return nil, nil, nil, err
 	}
-	defer conn.Close()
+	return groups, additionalClaims, warnings, nil
+}
 
-	err = conn.Bind(p.c.BindUsername, p.c.BindPassword)
-	if err != nil {
-		return nil, fmt.Errorf(`error binding as %q before user search: %w`, p.c.BindUsername, err)
-	}
+func (p *Provider) performRefresh(t *trace.Trace, conn Conn, storedRefreshAttributes provider.StoredRefreshAttributes) ([]string, map[string]string, []string, error) {
+	userDN := storedRefreshAttributes.DN
 
 	searchResult, err := p.performUserRefreshSearch(conn, userDN)
 	if err != nil {
 		p.traceRefreshFailure(t, err)
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	// if any more or less than one entry, error.
 	// we don't need to worry about logging this because we know it's a dn.
 	if len(searchResult.Entries) != 1 {
-		return nil, fmt.Errorf(`searching for user %q resulted in %d search results, but expected 1 result`,
+		return nil, nil, nil, fmt.Errorf(`searching for user %q resulted in %d search results, but expected 1 result`,
 			userDN, len(searchResult.Entries),
 		)
 	}
 
 	userEntry := searchResult.Entries[0]
 	if len(userEntry.DN) == 0 {
-		return nil, fmt.Errorf(`searching for user with original DN %q resulted in search result without DN`, userDN)
+		return nil, nil, nil, fmt.Errorf(`searching for user with original DN %q resulted in search result without DN`, userDN)
 	}
 
 	newUsername, err := p.getSearchResultAttributeValue(p.c.UserSearch.UsernameAttribute, userEntry, userDN)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	if newUsername != storedRefreshAttributes.Username {
-		return nil, fmt.Errorf(`searching for user %q returned a different username than the previous value. expected: %q, actual: %q`,
+		return nil, nil, nil, fmt.Errorf(`searching for user %q returned a different username than the previous value. expected: %q, actual: %q`,
 			userDN, storedRefreshAttributes.Username, newUsername,
 		)
 	}
 
 	newUID, err := p.getSearchResultAttributeRawValueEncoded(p.c.UserSearch.UIDAttribute, userEntry, userDN)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	newSubject := downstreamsession.DownstreamLDAPSubject(newUID, *p.GetURL())
 	if newSubject != storedRefreshAttributes.Subject {
-		return nil, fmt.Errorf(`searching for user %q produced a different subject than the previous value. expected: %q, actual: %q`, userDN, storedRefreshAttributes.Subject, newSubject)
+		return nil, nil, nil, fmt.Errorf(`searching for user %q produced a different subject than the previous value. expected: %q, actual: %q`, userDN, storedRefreshAttributes.Subject, newSubject)
 	}
 	for attribute, validateFunc := range p.c.RefreshAttributeChecks {
 		err = validateFunc(userEntry, storedRefreshAttributes)
 		if err != nil {
-			return nil, fmt.Errorf(`validation for attribute %q failed during upstream refresh: %w`, attribute, err)
+			return nil, nil, nil, fmt.Errorf(`validation for attribute %q failed during upstream refresh: %w`, attribute, err)
 		}
 	}
 
+	warnings, err := p.checkAccountStatus(userEntry)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf(`account status check for user %q failed during upstream refresh: %w`, userDN, err)
+	}
+
+	additionalClaims := p.additionalClaims(userEntry)
+
 	if p.c.GroupSearch.SkipGroupRefresh {
-		return storedRefreshAttributes.Groups, nil
+		return storedRefreshAttributes.Groups, additionalClaims, warnings, nil
 	}
 
-	mappedGroupNames, err := p.searchGroupsForUserDN(conn, userDN)
+	mappedGroupNames, err := p.refreshGroupsForUserDN(conn, userDN)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return mappedGroupNames, additionalClaims, warnings, nil
+}
+
+// additionalClaims returns the values of the additional claims which were read from the attributes of the user's entry.
+func (p *Provider) additionalClaims(userEntry *ldap.Entry) map[string]string {
+	if len(p.c.UserSearch.AdditionalClaimAttributes) == 0 {
+		return nil
+	}
+	additionalClaims := make(map[string]string, len(p.c.UserSearch.AdditionalClaimAttributes))
+	for claim, attribute := range p.c.UserSearch.AdditionalClaimAttributes {
+		value := userEntry.GetAttributeValue(attribute)
+		if attribute == distinguishedNameAttributeName {
+			value = userEntry.DN
+		}
+		if len(value) == 0 {
+			continue
+		}
+		additionalClaims[claim] = value
+	}
+	return additionalClaims
+}
+
+// refreshGroupsForUserDN returns the groups of the user during a refresh, reusing the groups which were found by
+// a recent login or refresh of the same user when the group cache is enabled.
+func (p *Provider) refreshGroupsForUserDN(conn Conn, userDN string) ([]string, error) {
+	if groups, ok := p.groupCache().get(p, userDN); ok {
+		plog.Debug("using cached groups for user during refresh", "upstreamName", p.GetName(), "dn", userDN)
+		return groups, nil
+	}
+
+	groups, err := p.searchGroupsForUserDN(conn, userDN)
 	if err != nil {
 		return nil, err
 	}
-	return mappedGroupNames, nil
+	p.groupCache().put(p, userDN, groups)
+	return groups, nil
+}
+
+// groupCache returns the provider's group cache, or nil when caching is disabled.
+func (p *Provider) groupCache() *GroupCache {
+	if p.c.GroupCache == nil || p.c.GroupSearch.Cache.TTL <= 0 || len(p.c.GroupSearch.Base) == 0 {
+		return nil
+	}
+	return p.c.GroupCache
 }
 
 func (p *Provider) performUserRefreshSearch(conn Conn, userDN string) (*ldap.SearchResult, error) {
@@ -257,13 +382,115 @@ func (p *Provider) performUserRefreshSearch(conn Conn, userDN string) (*ldap.Sea
 	return searchResult, nil
 }
 
+// searchConn returns a connection which is bound as the BindUsername, along with a func which must be called
+// with the error from the last operation (if any) when the caller is done with the connection.
+func (p *Provider) searchConn(ctx context.Context) (Conn, func(error), error) {
+	if p.c.ConnectionPool != nil {
+		return p.c.ConnectionPool.get(ctx, p)
+	}
+
+	conn, err := p.dialAndBind(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, func(error) { conn.Close() }, nil
+}
+
+// withSearchConn performs the given operation on a connection which is bound as the BindUsername. When the
+// ConnectionPool is used, the operation may be retried once on a new connection, so it must be safe to perform twice.
+func (p *Provider) withSearchConn(ctx context.Context, op func(conn Conn) error) error {
+	if p.c.ConnectionPool != nil {
+		return p.c.ConnectionPool.do(ctx, p, op)
+	}
+
+	conn, err := p.dialAndBind(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return op(conn)
+}
+
+func (p *Provider) dialAndBind(ctx context.Context) (Conn, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.bind(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf(`error binding as %q before user search: %w`, p.c.BindUsername, err)
+	}
+
+	return conn, nil
+}
+
+// bind binds the connection as the provider's bind user.
+func (p *Provider) bind(conn Conn) error {
+	if p.usesClientCertificate() {
+		// The server decides who we are based on the client certificate which was presented during the TLS handshake.
+		return conn.ExternalBind()
+	}
+	return conn.Bind(p.c.BindUsername, p.c.BindPassword)
+}
+
+func (p *Provider) usesClientCertificate() bool {
+	return len(p.c.ClientCertificateData) > 0
+}
+
+func (p *Provider) connectionSettings() connectionSettings {
+	return connectionSettings{
+		hosts:             strings.Join(append([]string{p.c.Host, p.c.HostDiscovery.SRVService, p.c.HostDiscovery.SRVDomain}, p.c.AdditionalHosts...), ","),
+		protocol:          p.c.ConnectionProtocol,
+		caBundle:          string(p.c.CABundle),
+		clientCertificate: string(p.c.ClientCertificateData),
+	}
+}
+
+// dial dials the first host which can be reached, trying the hosts in the order decided by the HostSelection
+// policy. The returned error describes why each host could not be dialed.
 func (p *Provider) dial(ctx context.Context) (Conn, error) {
-	tlsAddr, err := endpointaddr.Parse(p.c.Host, defaultLDAPSPort)
+	hosts, err := p.hosts(ctx)
 	if err != nil {
 		return nil, ldap.NewError(ldap.ErrorNetwork, err)
 	}
 
-	startTLSAddr, err := endpointaddr.Parse(p.c.Host, defaultLDAPPort)
+	tracker := p.hostTracker()
+	var errs []error
+	for _, host := range tracker.order(hosts, p.c.HostSelection) {
+		conn, err := p.dialHost(ctx, host)
+		if err == nil {
+			tracker.markHealthy(host)
+			return conn, nil
+		}
+		tracker.markFailed(host)
+		errs = append(errs, fmt.Errorf(`error dialing host %q: %w`, host, err))
+		if ctx.Err() != nil {
+			break // no point in trying the other hosts
+		}
+	}
+
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	return nil, utilerrors.NewAggregate(errs)
+}
+
+func (p *Provider) hostTracker() *hostTracker {
+	if p.c.ConnectionPool == nil {
+		return nil
+	}
+	return p.c.ConnectionPool.hosts
+}
+
+func (p *Provider) dialHost(ctx context.Context, host string) (Conn, error) {
+	tlsAddr, err := endpointaddr.Parse(host, defaultLDAPSPort)
+	if err != nil {
+		return nil, ldap.NewError(ldap.ErrorNetwork, err)
+	}
+
+	startTLSAddr, err := endpointaddr.Parse(host, defaultLDAPPort)
 	if err != nil {
 		return nil, ldap.NewError(ldap.ErrorNetwork, err)
 	}
@@ -287,7 +514,29 @@ func (p *Provider) dial(ctx context.Context) (Conn, error) {
 		dialFunc = p.c.Dialer.Dial
 	}
 
-	return dialFunc(ctx, addr)
+	conn, err := dialFunc(ctx, addr)
+	if err != nil || p.c.SearchObserver == nil {
+		return conn, err
+	}
+	return &observedConn{Conn: conn, observe: p.c.SearchObserver}, nil
+}
+
+// observedConn is a Conn which reports each of its searches to the SearchObserver of the ProviderConfig.
+type observedConn struct {
+	Conn
+	observe func(request *ldap.SearchRequest, result *ldap.SearchResult, err error)
+}
+
+func (c *observedConn) Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	result, err := c.Conn.Search(searchRequest)
+	c.observe(searchRequest, result, err)
+	return result, err
+}
+
+func (c *observedConn) SearchWithPaging(searchRequest *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	result, err := c.Conn.SearchWithPaging(searchRequest, pagingSize)
+	c.observe(searchRequest, result, err)
+	return result, err
 }
 
 // dialTLS is a default implementation of the Dialer, used when Dialer is nil and ConnectionProtocol is TLS.
@@ -349,7 +598,15 @@ func (p *Provider) tlsConfig() (*tls.Config, error) {
 			return nil, fmt.Errorf("could not parse CA bundle")
 		}
 	}
-	return ptls.DefaultLDAP(rootCAs), nil
+	tlsConfig := ptls.DefaultLDAP(rootCAs)
+	if p.usesClientCertificate() {
+		clientCert, err := tls.X509KeyPair(p.c.ClientCertificateData, p.c.ClientKeyData)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+	return tlsConfig, nil
 }
 
 // A name for this upstream provider.
@@ -365,7 +622,12 @@ func (p *Provider) GetResourceUID() types.UID {
 // This URL is not used for connecting to the provider, but rather is used for creating a globally unique user
 // identifier by being combined with the user's UID, since user UIDs are only unique within one provider.
 func (p *Provider) GetURL() *url.URL {
-	u := &url.URL{Scheme: ldapsScheme, Host: p.c.Host}
+	host := p.c.Host
+	if host == "" {
+		// The discovered hosts may change, so use the domain in which they were discovered instead.
+		host = p.c.HostDiscovery.SRVDomain
+	}
+	u := &url.URL{Scheme: ldapsScheme, Host: host}
 	q := u.Query()
 	q.Set("base", p.c.UserSearch.Base)
 	u.RawQuery = q.Encode()
@@ -382,11 +644,54 @@ func (p *Provider) TestConnection(ctx context.Context) error {
 
 	conn, err := p.dial(ctx)
 	if err != nil {
-		return fmt.Errorf(`error dialing host %q: %w`, p.c.Host, err)
+		return err
 	}
 	defer conn.Close()
 
-	err = conn.Bind(p.c.BindUsername, p.c.BindPassword)
+	err = p.bind(conn)
+	if err != nil {
+		return fmt.Errorf(`error binding as %q: %w`, p.c.BindUsername, err)
+	}
+
+	return nil
+}
+
+// TestConnectionToEachHost is like TestConnection, except that it performs a dial and bind for every host,
+// instead of stopping at the first host which can be reached. It returns the result for each host, or an
+// error when the hosts could not be tested at all.
+func (p *Provider) TestConnectionToEachHost(ctx context.Context) ([]HostStatus, error) {
+	err := p.validateConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, err := p.hosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tracker := p.hostTracker()
+	statuses := make([]HostStatus, 0, len(hosts))
+	for _, host := range hosts {
+		err := p.testHost(ctx, host)
+		if err != nil {
+			tracker.markFailed(host)
+		} else {
+			tracker.markHealthy(host)
+		}
+		statuses = append(statuses, HostStatus{Host: host, Err: err})
+	}
+	return statuses, nil
+}
+
+func (p *Provider) testHost(ctx context.Context, host string) error {
+	conn, err := p.dialHost(ctx, host)
+	if err != nil {
+		return fmt.Errorf(`error dialing host %q: %w`, host, err)
+	}
+	defer conn.Close()
+
+	err = p.bind(conn)
 	if err != nil {
 		return fmt.Errorf(`error binding as %q: %w`, p.c.BindUsername, err)
 	}
@@ -399,22 +704,54 @@ func (p *Provider) TestConnection(ctx context.Context) error {
 // not bind as that user, so it does not test their password. It returns the same values that a real call to
 // AuthenticateUser with the correct password would return.
 func (p *Provider) DryRunAuthenticateUser(ctx context.Context, username string) (*authenticators.Response, bool, error) {
-	endUserBindFunc := func(conn Conn, foundUserDN string) error {
+	endUserBindFunc := func(conn Conn, foundUserDN string) ([]ldap.Control, error) {
 		// Act as if the end user bind always succeeds.
-		return nil
+		return nil, nil
 	}
 	return p.authenticateUserImpl(ctx, username, endUserBindFunc)
 }
 
 // Authenticate an end user and return their mapped username, groups, and UID. Implements authenticators.UserAuthenticator.
 func (p *Provider) AuthenticateUser(ctx context.Context, username, password string) (*authenticators.Response, bool, error) {
-	endUserBindFunc := func(conn Conn, foundUserDN string) error {
-		return conn.Bind(foundUserDN, password)
+	endUserBindFunc := func(conn Conn, foundUserDN string) ([]ldap.Control, error) {
+		if p.c.ConnectionPool != nil {
+			// Pooled connections must stay bound as the BindUsername, so bind as the end user on a new connection.
+			return p.bindOnNewConn(ctx, foundUserDN, password)
+		}
+		return p.bindAsEndUser(conn, foundUserDN, password)
 	}
 	return p.authenticateUserImpl(ctx, username, endUserBindFunc)
 }
 
-func (p *Provider) authenticateUserImpl(ctx context.Context, username string, bindFunc func(conn Conn, foundUserDN string) error) (*authenticators.Response, bool, error) {
+func (p *Provider) bindOnNewConn(ctx context.Context, username, password string) ([]ldap.Control, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return p.bindAsEndUser(conn, username, password)
+}
+
+// bindAsEndUser binds as the end user, requesting the password policy control when it is configured, and returns
+// the response controls.
+func (p *Provider) bindAsEndUser(conn Conn, username, password string) ([]ldap.Control, error) {
+	if !p.c.AccountStatus.PasswordPolicyControl {
+		return nil, conn.Bind(username, password)
+	}
+
+	result, err := conn.SimpleBind(&ldap.SimpleBindRequest{
+		Username: username,
+		Password: password,
+		Controls: []ldap.Control{ldap.NewControlBeheraPasswordPolicy()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Controls, nil
+}
+
+func (p *Provider) authenticateUserImpl(ctx context.Context, username string, bindFunc func(conn Conn, foundUserDN string) ([]ldap.Control, error)) (*authenticators.Response, bool, error) {
 	t := trace.FromContext(ctx).Nest("slow ldap authenticate user attempt", trace.Field{Key: "providerName", Value: p.GetName()})
 	defer t.LogIfLong(500 * time.Millisecond) // to help users debug slow LDAP searches
 
@@ -430,20 +767,12 @@ func (p *Provider) authenticateUserImpl(ctx context.Context, username string, bi
 		return nil, false, nil
 	}
 
-	conn, err := p.dial(ctx)
-	if err != nil {
-		p.traceAuthFailure(t, err)
-		return nil, false, fmt.Errorf(`error dialing host %q: %w`, p.c.Host, err)
-	}
-	defer conn.Close()
-
-	err = conn.Bind(p.c.BindUsername, p.c.BindPassword)
-	if err != nil {
-		p.traceAuthFailure(t, err)
-		return nil, false, fmt.Errorf(`error binding as %q before user search: %w`, p.c.BindUsername, err)
-	}
-
-	response, err := p.searchAndBindUser(conn, username, bindFunc)
+	var response *authenticators.Response
+	err = p.withSearchConn(ctx, func(conn Conn) error {
+		var err error
+		response, err = p.searchAndBindUser(conn, username, bindFunc)
+		return err
+	})
 	if err != nil {
 		p.traceAuthFailure(t, err)
 		return nil, false, err
@@ -463,9 +792,9 @@ func (p *Provider) searchGroupsForUserDN(conn Conn, userDN string) ([]string, er
 		return []string{}, nil
 	}
 
-	searchResult, err := conn.SearchWithPaging(p.groupSearchRequest(userDN), groupSearchPageSize)
+	groupEntries, err := p.searchGroupEntries(conn, userDN)
 	if err != nil {
-		return nil, fmt.Errorf(`error searching for group memberships for user with DN %q: %w`, userDN, err)
+		return nil, err
 	}
 
 	groupAttributeName := p.c.GroupSearch.GroupNameAttribute
@@ -475,10 +804,7 @@ func (p *Provider) searchGroupsForUserDN(conn Conn, userDN string) ([]string, er
 
 	groups := []string{}
 entries:
-	for _, groupEntry := range searchResult.Entries {
-		if len(groupEntry.DN) == 0 {
-			return nil, fmt.Errorf(`searching for group memberships for user with DN %q resulted in search result without DN`, userDN)
-		}
+	for _, groupEntry := range groupEntries {
 		if overrideFunc := p.c.GroupAttributeParsingOverrides[groupAttributeName]; overrideFunc != nil {
 			overrideGroupName, err := overrideFunc(groupEntry)
 			if err != nil {
@@ -499,6 +825,43 @@ entries:
 	return sets.NewString(groups...).List(), nil
 }
 
+// searchGroupEntries returns the entries of the groups of which the user is a member. When nested groups are
+// enabled, it also returns the groups of which those groups are members, and so on, up to the maximum depth.
+// Each group is searched at most once per login or refresh, which also protects against membership cycles.
+func (p *Provider) searchGroupEntries(conn Conn, userDN string) ([]*ldap.Entry, error) {
+	var groupEntries []*ldap.Entry
+	found := sets.NewString(userDN)
+	memberDNs := []string{userDN}
+
+	for depth := 0; depth <= p.c.GroupSearch.NestedGroupsMaxDepth && len(memberDNs) > 0; depth++ {
+		var nextMemberDNs []string
+		for _, memberDN := range memberDNs {
+			searchResult, err := conn.SearchWithPaging(p.groupSearchRequest(memberDN), groupSearchPageSize)
+			if err != nil {
+				if memberDN != userDN {
+					return nil, fmt.Errorf(`error searching for nested group memberships of group with DN %q for user with DN %q: %w`, memberDN, userDN, err)
+				}
+				return nil, fmt.Errorf(`error searching for group memberships for user with DN %q: %w`, userDN, err)
+			}
+
+			for _, groupEntry := range searchResult.Entries {
+				if len(groupEntry.DN) == 0 {
+					return nil, fmt.Errorf(`searching for group memberships for user with DN %q resulted in search result without DN`, userDN)
+				}
+				groupEntries = append(groupEntries, groupEntry)
+				if found.Has(groupEntry.DN) {
+					continue // already found through another membership, so it was already or will be searched
+				}
+				found.Insert(groupEntry.DN)
+				nextMemberDNs = append(nextMemberDNs, groupEntry.DN)
+			}
+		}
+		memberDNs = nextMemberDNs
+	}
+
+	return groupEntries, nil
+}
+
 func (p *Provider) validateConfig() error {
 	if p.c.UserSearch.UsernameAttribute == distinguishedNameAttributeName && len(p.c.UserSearch.Filter) == 0 {
 		// LDAP search filters do not allow searching by DN, so we would have no reasonable default for Filter.
@@ -514,11 +877,11 @@ func (p *Provider) SearchForDefaultNamingContext(ctx context.Context) (string, e
 	conn, err := p.dial(ctx)
 	if err != nil {
 		p.traceSearchBaseDiscoveryFailure(t, err)
-		return "", fmt.Errorf(`error dialing host %q: %w`, p.c.Host, err)
+		return "", err
 	}
 	defer conn.Close()
 
-	err = conn.Bind(p.c.BindUsername, p.c.BindPassword)
+	err = p.bind(conn)
 	if err != nil {
 		p.traceSearchBaseDiscoveryFailure(t, err)
 		return "", fmt.Errorf(`error binding as %q before querying for defaultNamingContext: %w`, p.c.BindUsername, err)
@@ -540,7 +903,8 @@ func (p *Provider) SearchForDefaultNamingContext(ctx context.Context) (string, e
 	return searchBase, nil
 }
 
-func (p *Provider) searchAndBindUser(conn Conn, username string, bindFunc func(conn Conn, foundUserDN string) error) (*authenticators.Response, error) {
+// searchForUser returns the entry of the user with the given username, or nil when there is no such user.
+func (p *Provider) searchForUser(conn Conn, username string) (*ldap.Entry, error) {
 	searchResult, err := conn.Search(p.userSearchRequest(username))
 	if err != nil {
 		plog.All(`error searching for user`,
@@ -573,6 +937,18 @@ func (p *Provider) searchAndBindUser(conn Conn, username string, bindFunc func(c
 	if len(userEntry.DN) == 0 {
 		return nil, fmt.Errorf(`searching for user %q resulted in search result without DN`, username)
 	}
+	return userEntry, nil
+}
+
+func (p *Provider) searchAndBindUser(conn Conn, username string, bindFunc func(conn Conn, foundUserDN string) ([]ldap.Control, error)) (*authenticators.Response, error) {
+	userEntry, err := p.searchForUser(conn, username)
+	if err != nil || userEntry == nil {
+		return nil, err
+	}
+
+	if err := p.searchForConstructedAccountStatusAttributes(conn, userEntry); err != nil {
+		return nil, err
+	}
 
 	mappedUsername, err := p.getSearchResultAttributeValue(p.c.UserSearch.UsernameAttribute, userEntry, username)
 	if err != nil {
@@ -601,10 +977,13 @@ func (p *Provider) searchAndBindUser(conn Conn, username string, bindFunc func(c
 	}
 
 	// Caution: Note that any other LDAP commands after this bind will be run as this user instead of as the configured BindUsername!
-	err = bindFunc(conn, userEntry.DN)
+	bindResponseControls, err := bindFunc(conn, userEntry.DN)
 	if err != nil {
 		plog.DebugErr("error binding for user (if this is not the expected dn for this username, please check the user search configuration)",
 			err, "upstreamName", p.GetName(), "username", username, "dn", userEntry.DN)
+		if isPasswordChangeRequiredBindError(err) {
+			return nil, fmt.Errorf(`user %q must change their password: %w`, username, authenticators.ErrPasswordChangeRequired)
+		}
 		ldapErr := &ldap.Error{}
 		if errors.As(err, &ldapErr) && ldapErr.ResultCode == ldap.LDAPResultInvalidCredentials {
 			return nil, nil
@@ -617,6 +996,27 @@ func (p *Provider) searchAndBindUser(conn Conn, username string, bindFunc func(c
 		return nil, nil
 	}
 
+	// Only check the account status after the password was verified, to avoid revealing it to anyone else.
+	warnings, err := p.checkAccountStatus(userEntry)
+	if err == nil {
+		var passwordPolicyWarnings []string
+		passwordPolicyWarnings, err = checkPasswordPolicyControl(bindResponseControls)
+		warnings = append(warnings, passwordPolicyWarnings...)
+	}
+	if errors.Is(err, authenticators.ErrPasswordChangeRequired) {
+		return nil, fmt.Errorf(`user %q must change their password: %w`, username, err)
+	}
+	if err != nil {
+		plog.Debug("user is not allowed to log in because of their account status",
+			"upstreamName", p.GetName(), "username", username, "dn", userEntry.DN, "reason", err.Error())
+		return nil, nil
+	}
+
+	// Logins always search for the user's groups, but the result may be reused by the following refreshes.
+	p.groupCache().put(p, userEntry.DN, mappedGroupNames)
+
+	additionalClaims := p.additionalClaims(userEntry)
+
 	response := &authenticators.Response{
 		User: &user.DefaultInfo{
 			Name:   mappedUsername,
@@ -625,6 +1025,8 @@ func (p *Provider) searchAndBindUser(conn Conn, username string, bindFunc func(c
 		},
 		DN:                     userEntry.DN,
 		ExtraRefreshAttributes: mappedRefreshAttributes,
+		AdditionalClaims:       additionalClaims,
+		Warnings:               warnings,
 	}
 
 	return response, nil
@@ -700,6 +1102,13 @@ func (p *Provider) userSearchRequestedAttributes() []string {
 	for k := range p.c.RefreshAttributeChecks {
 		attributes = append(attributes, k)
 	}
+	attributes = append(attributes, p.c.AccountStatus.attributes()...)
+	for _, claim := range sets.StringKeySet(p.c.UserSearch.AdditionalClaimAttributes).List() {
+		attribute := p.c.UserSearch.AdditionalClaimAttributes[claim]
+		if attribute != distinguishedNameAttributeName {
+			attributes = append(attributes, attribute)
+		}
+	}
 	return attributes
 }
 
@@ -286,6 +286,40 @@ func TestEndUserAuthentication(t *testing.T) {
 				info.Groups = []string{}
 			}),
 		},
+		{
+			name:     "when additional claims are configured then they are read from the user's entry",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.UserSearch.AdditionalClaimAttributes = map[string]string{"email": "mail", "name": "displayName"}
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(func(r *ldap.SearchRequest) {
+					r.Attributes = append(r.Attributes, "mail", "displayName")
+				})).Return(&ldap.SearchResult{
+					Entries: []*ldap.Entry{
+						{
+							DN: testUserSearchResultDNValue,
+							Attributes: []*ldap.EntryAttribute{
+								ldap.NewEntryAttribute(testUserSearchUsernameAttribute, []string{testUserSearchResultUsernameAttributeValue}),
+								ldap.NewEntryAttribute(testUserSearchUIDAttribute, []string{testUserSearchResultUIDAttributeValue}),
+								ldap.NewEntryAttribute("mail", []string{"some-email@example.com"}),
+							},
+						},
+					},
+				}, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).
+					Return(exampleGroupSearchResult, nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
+			},
+			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				r.AdditionalClaims = map[string]string{"email": "some-email@example.com"}
+			}),
+		},
 		{
 			name:     "when the UsernameAttribute is dn and there is a user search filter provided",
 			username: testUpstreamUsername,
@@ -585,6 +619,76 @@ func TestEndUserAuthentication(t *testing.T) {
 				r.DN = testUserDNWithSpecialChars
 			}),
 		},
+		{
+			name:     "nested groups are searched up to the maximum depth and each group is only searched once",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.NestedGroupsMaxDepth = 2
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				groupEntry := func(dn, name string) *ldap.Entry {
+					return &ldap.Entry{DN: dn, Attributes: []*ldap.EntryAttribute{
+						ldap.NewEntryAttribute(testGroupSearchGroupNameAttribute, []string{name}),
+					}}
+				}
+				expectedNestedGroupSearch := func(groupDN string) *ldap.SearchRequest {
+					return expectedGroupSearch(func(r *ldap.SearchRequest) {
+						r.Filter = fmt.Sprintf("(some-group-filter=%s-and-more-filter=%s)", groupDN, groupDN)
+					})
+				}
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(nil)).Return(exampleUserSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).
+					Return(&ldap.SearchResult{Entries: []*ldap.Entry{
+						groupEntry("group-dn1", "group1"),
+						groupEntry("group-dn2", "group2"),
+					}}, nil).Times(1)
+				// First level of nesting.
+				conn.EXPECT().SearchWithPaging(expectedNestedGroupSearch("group-dn1"), expectedGroupSearchPageSize).
+					Return(&ldap.SearchResult{Entries: []*ldap.Entry{
+						groupEntry("group-dn3", "group3"),
+					}}, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedNestedGroupSearch("group-dn2"), expectedGroupSearchPageSize).
+					Return(&ldap.SearchResult{Entries: []*ldap.Entry{
+						groupEntry("group-dn1", "group1"), // already found, so it is not searched again
+						groupEntry("group-dn3", "group3"), // found twice in this level, but only searched once
+					}}, nil).Times(1)
+				// Second level of nesting.
+				conn.EXPECT().SearchWithPaging(expectedNestedGroupSearch("group-dn3"), expectedGroupSearchPageSize).
+					Return(&ldap.SearchResult{Entries: []*ldap.Entry{
+						groupEntry("group-dn2", "group2"), // a cycle back to a group that was already found
+						groupEntry("group-dn4", "group4"), // too deep to be searched
+					}}, nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
+			},
+			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				r.User.(*user.DefaultInfo).Groups = []string{"group1", "group2", "group3", "group4"}
+			}),
+		},
+		{
+			name:     "when searching for nested groups returns an error",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.NestedGroupsMaxDepth = 10
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(nil)).Return(exampleUserSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).
+					Return(exampleGroupSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(func(r *ldap.SearchRequest) {
+					r.Filter = fmt.Sprintf("(some-group-filter=%s-and-more-filter=%s)", testGroupSearchResultDNValue1, testGroupSearchResultDNValue1)
+				}), expectedGroupSearchPageSize).Return(nil, errors.New("some group search error")).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantError: fmt.Sprintf(`error searching for nested group memberships of group with DN "%s" for user with DN "%s": some group search error`,
+				testGroupSearchResultDNValue1, testUserSearchResultDNValue),
+		},
 		{
 			name:           "group names are sorted to make the result more stable/predictable",
 			username:       testUpstreamUsername,
@@ -1329,13 +1433,14 @@ func TestUpstreamRefresh(t *testing.T) {
 	}
 
 	tests := []struct {
-		name           string
-		providerConfig *ProviderConfig
-		setupMocks     func(conn *mockldapconn.MockConn)
-		refreshUserDN  string
-		dialError      error
-		wantErr        string
-		wantGroups     []string
+		name                 string
+		providerConfig       *ProviderConfig
+		setupMocks           func(conn *mockldapconn.MockConn)
+		refreshUserDN        string
+		dialError            error
+		wantErr              string
+		wantGroups           []string
+		wantAdditionalClaims map[string]string
 	}{
 		{
 			name: "happy path without group search where searching the dn returns a single entry",
@@ -1360,6 +1465,66 @@ func TestUpstreamRefresh(t *testing.T) {
 			},
 			wantGroups: []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
 		},
+		{
+			name: "happy path where additional claims are read from the user's entry",
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.UserSearch.AdditionalClaimAttributes = map[string]string{
+					"email":       "mail",
+					"name":        "displayName",
+					"employee_id": "employeeNumber",
+					"dn":          "dn",
+				}
+			}),
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(func(r *ldap.SearchRequest) {
+					// The attributes of the additional claims are sorted by claim name.
+					r.Attributes = append(r.Attributes, "mail", "employeeNumber", "displayName")
+				})).Return(&ldap.SearchResult{
+					Entries: []*ldap.Entry{
+						{
+							DN: testUserSearchResultDNValue,
+							Attributes: append(happyPathUserSearchResult.Entries[0].Attributes,
+								ldap.NewEntryAttribute("mail", []string{"some-email@example.com", "some-other-email@example.com"}),
+								ldap.NewEntryAttribute("displayName", []string{"Some Display Name"}),
+							),
+						},
+					},
+				}, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).Return(happyPathGroupSearchResult, nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantGroups: []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantAdditionalClaims: map[string]string{
+				"email": "some-email@example.com",
+				"name":  "Some Display Name",
+				"dn":    testUserSearchResultDNValue,
+			},
+		},
+		{
+			name: "happy path where nested group search returns groups",
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.NestedGroupsMaxDepth = 1
+			}),
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(nil)).Return(happyPathUserSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).Return(happyPathGroupSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(func(r *ldap.SearchRequest) {
+					r.Filter = fmt.Sprintf("(some-group-filter=%s-and-more-filter=%s)", testGroupSearchResultDNValue1, testGroupSearchResultDNValue1)
+				}), expectedGroupSearchPageSize).Return(&ldap.SearchResult{Entries: []*ldap.Entry{{
+					DN: "some-upstream-parent-group-dn",
+					Attributes: []*ldap.EntryAttribute{
+						ldap.NewEntryAttribute(testGroupSearchGroupNameAttribute, []string{"some-upstream-parent-group-name"}),
+					},
+				}}}, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(func(r *ldap.SearchRequest) {
+					r.Filter = fmt.Sprintf("(some-group-filter=%s-and-more-filter=%s)", testGroupSearchResultDNValue2, testGroupSearchResultDNValue2)
+				}), expectedGroupSearchPageSize).Return(&ldap.SearchResult{}, nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantGroups: []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2, "some-upstream-parent-group-name"},
+		},
 		{
 			name:           "happy path when the user DN has special LDAP search filter characters then they must be properly escaped in the custom group search filter",
 			providerConfig: providerConfig(nil),
@@ -1772,7 +1937,7 @@ func TestUpstreamRefresh(t *testing.T) {
 			initialPwdLastSetEncoded := base64.RawURLEncoding.EncodeToString([]byte("132801740800000000"))
 			ldapProvider := New(*tt.providerConfig)
 			subject := "ldaps://ldap.example.com:8443?base=some-upstream-user-base-dn&sub=c29tZS11cHN0cmVhbS11aWQtdmFsdWU"
-			groups, err := ldapProvider.PerformRefresh(context.Background(), provider.StoredRefreshAttributes{
+			groups, additionalClaims, warnings, err := ldapProvider.PerformRefresh(context.Background(), provider.StoredRefreshAttributes{
 				Username:             testUserSearchResultUsernameAttributeValue,
 				Subject:              subject,
 				DN:                   tt.refreshUserDN,
@@ -1786,6 +1951,8 @@ func TestUpstreamRefresh(t *testing.T) {
 			}
 			require.Equal(t, true, dialWasAttempted)
 			require.Equal(t, tt.wantGroups, groups)
+			require.Equal(t, tt.wantAdditionalClaims, additionalClaims)
+			require.Empty(t, warnings)
 		})
 	}
 }
@@ -1914,6 +2081,68 @@ func TestGetConfig(t *testing.T) {
 	require.Equal(t, "original-provider-name", p.c.Name)
 }
 
+func TestSearchObserver(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+	conn := mockldapconn.NewMockConn(ctrl)
+
+	userSearchResult := &ldap.SearchResult{
+		Entries: []*ldap.Entry{
+			{
+				DN: testUserSearchResultDNValue,
+				Attributes: []*ldap.EntryAttribute{
+					ldap.NewEntryAttribute(testUserSearchUsernameAttribute, []string{testUserSearchResultUsernameAttributeValue}),
+					ldap.NewEntryAttribute(testUserSearchUIDAttribute, []string{testUserSearchResultUIDAttributeValue}),
+				},
+			},
+		},
+	}
+	groupSearchErr := errors.New("some group search error")
+	gomock.InOrder(
+		conn.EXPECT().Bind(testBindUsername, testBindPassword),
+		conn.EXPECT().Search(gomock.Any()).Return(userSearchResult, nil),
+		conn.EXPECT().SearchWithPaging(gomock.Any(), gomock.Any()).Return(nil, groupSearchErr),
+		conn.EXPECT().Close(),
+	)
+
+	type observedSearch struct {
+		baseDN string
+		result *ldap.SearchResult
+		err    error
+	}
+	var observed []observedSearch
+	p := New(ProviderConfig{
+		Host:               testHost,
+		ConnectionProtocol: TLS,
+		BindUsername:       testBindUsername,
+		BindPassword:       testBindPassword,
+		UserSearch: UserSearchConfig{
+			Base:              testUserSearchBase,
+			Filter:            testUserSearchFilter,
+			UsernameAttribute: testUserSearchUsernameAttribute,
+			UIDAttribute:      testUserSearchUIDAttribute,
+		},
+		GroupSearch: GroupSearchConfig{
+			Base:               testGroupSearchBase,
+			Filter:             testGroupSearchFilter,
+			GroupNameAttribute: testGroupSearchGroupNameAttribute,
+		},
+		Dialer: LDAPDialerFunc(func(ctx context.Context, addr endpointaddr.HostPort) (Conn, error) {
+			return conn, nil
+		}),
+		SearchObserver: func(request *ldap.SearchRequest, result *ldap.SearchResult, err error) {
+			observed = append(observed, observedSearch{baseDN: request.BaseDN, result: result, err: err})
+		},
+	})
+
+	_, _, err := p.DryRunAuthenticateUser(context.Background(), testUpstreamUsername)
+	require.ErrorIs(t, err, groupSearchErr)
+	require.Equal(t, []observedSearch{
+		{baseDN: testUserSearchBase, result: userSearchResult},
+		{baseDN: testGroupSearchBase, err: groupSearchErr},
+	}, observed)
+}
+
 func TestGetURL(t *testing.T) {
 	require.Equal(t,
 		"ldaps://ldap.example.com:1234?base=ou%3Dusers%2Cdc%3Dpinniped%2Cdc%3Ddev",
@@ -2062,7 +2291,7 @@ func TestRealTLSDialing(t *testing.T) {
 				ConnectionProtocol: tt.connProto,
 				Dialer:             nil, // this test is for the default (production) TLS dialer
 			})
-			conn, err := provider.dial(tt.context)
+			conn, err := provider.dialHost(tt.context, tt.host)
 			if conn != nil {
 				defer conn.Close()
 			}
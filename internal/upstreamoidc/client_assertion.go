@@ -0,0 +1,169 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec // the x5t header is defined to be a SHA-1 thumbprint
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+	"k8s.io/client-go/util/keyutil"
+
+	"go.pinniped.dev/internal/constable"
+)
+
+const (
+	// clientAssertionType is the client_assertion_type of a JWT client assertion, see
+	// https://datatracker.ietf.org/doc/html/rfc7523#section-2.2.
+	clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	// clientAssertionLifetime is how long a client assertion may be used. It is short because a new
+	// client assertion is signed for every request.
+	clientAssertionLifetime = 5 * time.Minute
+)
+
+// ClientAssertionSigner signs the client assertions which authenticate the Supervisor as an OIDC client using the
+// private_key_jwt client authentication method, see
+// https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication.
+type ClientAssertionSigner struct {
+	signer jose.Signer
+}
+
+// NewClientAssertionSigner returns a ClientAssertionSigner which signs using the given PEM-encoded RSA or ECDSA private
+// key. The optional keyID is used as the "kid" header of the client assertions. The optional PEM-encoded certificate
+// must be for the private key, and its SHA-1 thumbprint is used as the "x5t" header of the client assertions.
+func NewClientAssertionSigner(privateKeyPEM []byte, keyID string, certificatePEM []byte) (*ClientAssertionSigner, error) {
+	key, err := keyutil.ParsePrivateKeyPEM(privateKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key: %w", err)
+	}
+
+	var algorithm jose.SignatureAlgorithm
+	var publicKey interface{ Equal(x crypto.PublicKey) bool }
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		algorithm, publicKey = jose.RS256, &k.PublicKey
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			algorithm = jose.ES256
+		case elliptic.P384():
+			algorithm = jose.ES384
+		case elliptic.P521():
+			algorithm = jose.ES512
+		default:
+			return nil, fmt.Errorf("private key has unsupported curve %s", k.Curve.Params().Name)
+		}
+		publicKey = &k.PublicKey
+	default:
+		return nil, fmt.Errorf("private key has unsupported type %T, must be an RSA or ECDSA key", key)
+	}
+
+	options := (&jose.SignerOptions{}).WithType("JWT")
+	if keyID != "" {
+		options = options.WithHeader("kid", keyID)
+	}
+	if len(certificatePEM) > 0 {
+		block, _ := pem.Decode(certificatePEM)
+		if block == nil || block.Type != "CERTIFICATE" {
+			return nil, constable.Error("could not parse certificate: no PEM-encoded certificate found")
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse certificate: %w", err)
+		}
+		if !publicKey.Equal(certificate.PublicKey) {
+			return nil, constable.Error("certificate does not match private key")
+		}
+		thumbprint := sha1.Sum(certificate.Raw) //nolint:gosec // the x5t header is defined to be a SHA-1 thumbprint
+		options = options.WithHeader("x5t", base64.RawURLEncoding.EncodeToString(thumbprint[:]))
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: algorithm, Key: key}, options)
+	if err != nil {
+		return nil, fmt.Errorf("could not create signer: %w", err)
+	}
+	return &ClientAssertionSigner{signer: signer}, nil
+}
+
+// sign returns a new client assertion which authenticates the given client to the given audience, which is the
+// token endpoint of the provider.
+func (s *ClientAssertionSigner) sign(clientID, audience string, now time.Time) (string, error) {
+	var jti [16]byte
+	if _, err := io.ReadFull(rand.Reader, jti[:]); err != nil {
+		return "", err
+	}
+	return josejwt.Signed(s.signer).Claims(josejwt.Claims{
+		Issuer:   clientID,
+		Subject:  clientID,
+		Audience: josejwt.Audience{audience},
+		ID:       hex.EncodeToString(jti[:]),
+		IssuedAt: josejwt.NewNumericDate(now),
+		Expiry:   josejwt.NewNumericDate(now.Add(clientAssertionLifetime)),
+	}).CompactSerialize()
+}
+
+// addTo adds a new client assertion for the given client and audience to the given request params.
+func (s *ClientAssertionSigner) addTo(params url.Values, clientID, audience string) error {
+	assertion, err := s.sign(clientID, audience, time.Now())
+	if err != nil {
+		return fmt.Errorf("could not sign client assertion: %w", err)
+	}
+	params.Set("client_assertion_type", clientAssertionType)
+	params.Set("client_assertion", assertion)
+	return nil
+}
+
+// clientAssertionRoundTripper adds a client assertion to the requests which the oauth2 library makes to the token
+// endpoint, since the oauth2 library does not support the private_key_jwt client authentication method itself.
+type clientAssertionRoundTripper struct {
+	base     http.RoundTripper
+	signer   *ClientAssertionSigner
+	clientID string
+	tokenURL string
+}
+
+func (rt *clientAssertionRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || req.URL.String() != rt.tokenURL || req.Body == nil {
+		return rt.base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("could not read token request body: %w", err)
+	}
+	params, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse token request body: %w", err)
+	}
+	if err := rt.signer.addTo(params, rt.clientID, rt.tokenURL); err != nil {
+		return nil, err
+	}
+
+	// A RoundTripper must not modify the original request, so send a copy with the new body.
+	encoded := params.Encode()
+	newReq := req.Clone(req.Context())
+	newReq.Body = io.NopCloser(strings.NewReader(encoded))
+	newReq.ContentLength = int64(len(encoded))
+	newReq.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(encoded)), nil
+	}
+	return rt.base.RoundTrip(newReq)
+}
@@ -0,0 +1,224 @@
+// Copyright 2022 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec // the x5t header is defined to be a SHA-1 thumbprint
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+	"k8s.io/client-go/util/keyutil"
+
+	"go.pinniped.dev/internal/certauthority"
+)
+
+func TestNewClientAssertionSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p224Key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ca, err := certauthority.New("test-ca", time.Hour)
+	require.NoError(t, err)
+	certPEM, certKeyPEM, err := ca.IssueClientCertPEM("test-client", nil, time.Hour)
+	require.NoError(t, err)
+	certBlock, _ := pem.Decode(certPEM)
+	certThumbprint := sha1.Sum(certBlock.Bytes) //nolint:gosec // the x5t header is defined to be a SHA-1 thumbprint
+
+	tests := []struct {
+		name           string
+		privateKeyPEM  []byte
+		keyID          string
+		certificatePEM []byte
+		wantAlgorithm  jose.SignatureAlgorithm
+		wantHeaders    map[jose.HeaderKey]interface{}
+		wantErr        string
+	}{
+		{
+			name:          "RSA key in PKCS1 format",
+			privateKeyPEM: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			wantAlgorithm: jose.RS256,
+		},
+		{
+			name:          "ECDSA key in PKCS8 format with a key ID",
+			privateKeyPEM: pkcs8PEM(t, p384Key),
+			keyID:         "some-key-id",
+			wantAlgorithm: jose.ES384,
+			wantHeaders:   map[jose.HeaderKey]interface{}{"kid": "some-key-id"},
+		},
+		{
+			name:           "key with its certificate",
+			privateKeyPEM:  certKeyPEM,
+			certificatePEM: certPEM,
+			wantAlgorithm:  jose.ES256,
+			wantHeaders:    map[jose.HeaderKey]interface{}{"x5t": base64.RawURLEncoding.EncodeToString(certThumbprint[:])},
+		},
+		{
+			name:          "not a key",
+			privateKeyPEM: []byte("not a key"),
+			wantErr:       "could not parse private key: data does not contain a valid RSA or ECDSA private key",
+		},
+		{
+			name:          "unsupported key type",
+			privateKeyPEM: pkcs8PEM(t, ed25519Key),
+			wantErr:       "private key has unsupported type ed25519.PrivateKey, must be an RSA or ECDSA key",
+		},
+		{
+			name:          "unsupported curve",
+			privateKeyPEM: ecPEM(t, p224Key),
+			wantErr:       "private key has unsupported curve P-224",
+		},
+		{
+			name:           "certificate is not PEM",
+			privateKeyPEM:  certKeyPEM,
+			certificatePEM: []byte("not a certificate"),
+			wantErr:        "could not parse certificate: no PEM-encoded certificate found",
+		},
+		{
+			name:           "certificate is for another key",
+			privateKeyPEM:  pkcs8PEM(t, p384Key),
+			certificatePEM: certPEM,
+			wantErr:        "certificate does not match private key",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewClientAssertionSigner(tt.privateKeyPEM, tt.keyID, tt.certificatePEM)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, signer)
+				return
+			}
+			require.NoError(t, err)
+
+			now := time.Now()
+			assertion, err := signer.sign("some-client-id", "https://some-issuer.com/token", now)
+			require.NoError(t, err)
+
+			token, err := josejwt.ParseSigned(assertion)
+			require.NoError(t, err)
+			require.Len(t, token.Headers, 1)
+			require.Equal(t, string(tt.wantAlgorithm), token.Headers[0].Algorithm)
+			require.Equal(t, "JWT", token.Headers[0].ExtraHeaders[jose.HeaderType])
+			for k, v := range tt.wantHeaders {
+				if k == "kid" {
+					require.Equal(t, v, token.Headers[0].KeyID)
+					continue
+				}
+				require.Equal(t, v, token.Headers[0].ExtraHeaders[k])
+			}
+
+			var claims josejwt.Claims
+			require.NoError(t, token.Claims(publicKeyOf(t, tt.privateKeyPEM), &claims))
+			require.NoError(t, claims.ValidateWithLeeway(josejwt.Expected{
+				Issuer:   "some-client-id",
+				Subject:  "some-client-id",
+				Audience: josejwt.Audience{"https://some-issuer.com/token"},
+				Time:     now,
+			}, 0))
+			require.Len(t, claims.ID, 32)
+			require.Equal(t, now.Add(5*time.Minute).Unix(), claims.Expiry.Time().Unix())
+
+			// Each client assertion is unique.
+			anotherAssertion, err := signer.sign("some-client-id", "https://some-issuer.com/token", now)
+			require.NoError(t, err)
+			require.NotEqual(t, assertion, anotherAssertion)
+		})
+	}
+}
+
+func TestClientAssertionRoundTripper(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := NewClientAssertionSigner(pkcs8PEM(t, key), "", nil)
+	require.NoError(t, err)
+
+	var gotForm url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		gotForm = r.Form
+	}))
+	t.Cleanup(server.Close)
+	tokenURL := server.URL + "/token"
+
+	client := &http.Client{Transport: &clientAssertionRoundTripper{
+		base:     http.DefaultTransport,
+		signer:   signer,
+		clientID: "some-client-id",
+		tokenURL: tokenURL,
+	}}
+
+	body := "grant_type=refresh_token&client_id=some-client-id"
+	req, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	require.Equal(t, "refresh_token", gotForm.Get("grant_type"))
+	require.Equal(t, "some-client-id", gotForm.Get("client_id"))
+	require.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", gotForm.Get("client_assertion_type"))
+	token, err := josejwt.ParseSigned(gotForm.Get("client_assertion"))
+	require.NoError(t, err)
+	var claims josejwt.Claims
+	require.NoError(t, token.Claims(&key.PublicKey, &claims))
+	require.Equal(t, josejwt.Audience{tokenURL}, claims.Audience)
+
+	// The original request was not modified.
+	originalBody, err := req.GetBody()
+	require.NoError(t, err)
+	originalBodyBytes, err := io.ReadAll(originalBody)
+	require.NoError(t, err)
+	require.Equal(t, body, string(originalBodyBytes))
+
+	// Requests to other endpoints do not get a client assertion.
+	resp, err = client.Post(server.URL+"/other", "application/x-www-form-urlencoded", strings.NewReader(body))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Empty(t, gotForm.Get("client_assertion"))
+}
+
+func pkcs8PEM(t *testing.T, key crypto.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func ecPEM(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func publicKeyOf(t *testing.T, privateKeyPEM []byte) crypto.PublicKey {
+	t.Helper()
+	key, err := keyutil.ParsePrivateKeyPEM(privateKeyPEM)
+	require.NoError(t, err)
+	return key.(crypto.Signer).Public()
+}
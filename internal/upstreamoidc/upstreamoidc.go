@@ -43,7 +43,8 @@ type ProviderConfig struct {
 	Client                   *http.Client
 	AllowPasswordGrant       bool
 	AdditionalAuthcodeParams map[string]string
-	RevocationURL            *url.URL // will commonly be nil: many providers do not offer this
+	RevocationURL            *url.URL               // will commonly be nil: many providers do not offer this
+	ClientAssertionSigner    *ClientAssertionSigner // nil unless the client authenticates using private_key_jwt
 	Provider                 interface {
 		Verifier(*coreosoidc.Config) *coreosoidc.IDTokenVerifier
 		Claims(v interface{}) error
@@ -74,6 +75,26 @@ func (p *ProviderConfig) HasUserInfoURL() bool {
 	return len(providerJSON.UserInfoURL) > 0
 }
 
+func (p *ProviderConfig) GetEndSessionURL() *url.URL {
+	providerJSON := &struct {
+		// "end_session_endpoint" is specified by https://openid.net/specs/openid-connect-rpinitiated-1_0.html#OPMetadata
+		EndSessionURL string `json:"end_session_endpoint"`
+	}{}
+	if err := p.Provider.Claims(providerJSON); err != nil {
+		// This should never happen in practice because we should have already successfully
+		// parsed these claims when p.Provider was created.
+		return nil
+	}
+	if providerJSON.EndSessionURL == "" {
+		return nil
+	}
+	endSessionURL, err := url.Parse(providerJSON.EndSessionURL)
+	if err != nil || endSessionURL.Scheme != "https" {
+		return nil
+	}
+	return endSessionURL
+}
+
 func (p *ProviderConfig) GetAdditionalAuthcodeParams() map[string]string {
 	return p.AdditionalAuthcodeParams
 }
@@ -114,8 +135,8 @@ func (p *ProviderConfig) PasswordCredentialsGrantAndValidateTokens(ctx context.C
 	}
 
 	// Note that this implicitly uses the scopes from p.Config.Scopes.
-	tok, err := p.Config.PasswordCredentialsToken(
-		coreosoidc.ClientContext(ctx, p.Client),
+	tok, err := p.tokenEndpointConfig().PasswordCredentialsToken(
+		p.tokenEndpointContext(ctx),
 		username,
 		password,
 	)
@@ -130,8 +151,8 @@ func (p *ProviderConfig) PasswordCredentialsGrantAndValidateTokens(ctx context.C
 }
 
 func (p *ProviderConfig) ExchangeAuthcodeAndValidateTokens(ctx context.Context, authcode string, pkceCodeVerifier pkce.Code, expectedIDTokenNonce nonce.Nonce, redirectURI string) (*oidctypes.Token, error) {
-	tok, err := p.Config.Exchange(
-		coreosoidc.ClientContext(ctx, p.Client),
+	tok, err := p.tokenEndpointConfig().Exchange(
+		p.tokenEndpointContext(ctx),
 		authcode,
 		pkceCodeVerifier.Verifier(),
 		oauth2.SetAuthURLParam("redirect_uri", redirectURI),
@@ -145,10 +166,46 @@ func (p *ProviderConfig) ExchangeAuthcodeAndValidateTokens(ctx context.Context,
 
 func (p *ProviderConfig) PerformRefresh(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
 	// Use the provided HTTP client to benefit from its CA, proxy, and other settings.
-	httpClientContext := coreosoidc.ClientContext(ctx, p.Client)
+	httpClientContext := p.tokenEndpointContext(ctx)
 	// Create a TokenSource without an access token, so it thinks that a refresh is immediately required.
 	// Then ask it for the tokens to cause it to perform the refresh and return the results.
-	return p.Config.TokenSource(httpClientContext, &oauth2.Token{RefreshToken: refreshToken}).Token()
+	return p.tokenEndpointConfig().TokenSource(httpClientContext, &oauth2.Token{RefreshToken: refreshToken}).Token()
+}
+
+// tokenEndpointConfig returns the oauth2.Config to use for requests to the token endpoint. When the client
+// authenticates using private_key_jwt, the client_id must be sent in the request params, and there is no client secret.
+func (p *ProviderConfig) tokenEndpointConfig() *oauth2.Config {
+	if p.ClientAssertionSigner == nil {
+		return p.Config
+	}
+	config := *p.Config
+	config.ClientSecret = ""
+	config.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+	return &config
+}
+
+// tokenEndpointContext returns a context which causes the oauth2 library to use the provided HTTP client for requests
+// to the token endpoint. When the client authenticates using private_key_jwt, the HTTP client is wrapped to add a
+// client assertion to each of those requests.
+func (p *ProviderConfig) tokenEndpointContext(ctx context.Context) context.Context {
+	if p.ClientAssertionSigner == nil {
+		return coreosoidc.ClientContext(ctx, p.Client)
+	}
+	var client http.Client
+	if p.Client != nil {
+		client = *p.Client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = &clientAssertionRoundTripper{
+		base:     base,
+		signer:   p.ClientAssertionSigner,
+		clientID: p.Config.ClientID,
+		tokenURL: p.Config.Endpoint.TokenURL,
+	}
+	return coreosoidc.ClientContext(ctx, &client)
 }
 
 // RevokeToken will attempt to revoke the given token, if the provider has a revocation endpoint.
@@ -165,7 +222,7 @@ func (p *ProviderConfig) RevokeToken(ctx context.Context, token string, tokenTyp
 	}
 	// First try using client auth in the request params.
 	tryAnotherClientAuthMethod, err := p.tryRevokeToken(ctx, token, tokenType, false)
-	if tryAnotherClientAuthMethod {
+	if tryAnotherClientAuthMethod && p.ClientAssertionSigner == nil {
 		// Try again using basic auth this time. Overwrite the first client auth error,
 		// which isn't useful anymore when retrying.
 		_, err = p.tryRevokeToken(ctx, token, tokenType, true)
@@ -174,7 +231,8 @@ func (p *ProviderConfig) RevokeToken(ctx context.Context, token string, tokenTyp
 }
 
 // tryRevokeToken will call the revocation endpoint using either basic auth or by including
-// client auth in the request params. It will return an error when the request failed. If the
+// client auth in the request params. A client assertion is always sent in the request params, since
+// there is no way to send it using basic auth. It will return an error when the request failed. If the
 // request failed for a reason that might be due to bad client auth, then it will return true
 // for the tryAnotherClientAuthMethod return value, indicating that it might be worth trying
 // again using the other client auth method.
@@ -195,7 +253,17 @@ func (p *ProviderConfig) tryRevokeToken(
 		"token":           []string{token},
 		"token_type_hint": []string{string(tokenType)},
 	}
-	if !useBasicAuth {
+	switch {
+	case p.ClientAssertionSigner != nil:
+		// Like the token endpoint, the revocation endpoint is an endpoint of the authorization server, so the
+		// token endpoint URL is used as the audience of the client assertion.
+		// See https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication.
+		params["client_id"] = []string{clientID}
+		if err := p.ClientAssertionSigner.addTo(params, clientID, p.Config.Endpoint.TokenURL); err != nil {
+			return false, err
+		}
+		useBasicAuth = false
+	case !useBasicAuth:
 		params["client_id"] = []string{clientID}
 		params["client_secret"] = []string{clientSecret}
 	}
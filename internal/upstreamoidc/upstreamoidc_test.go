@@ -5,7 +5,12 @@ package upstreamoidc
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"net/http"
@@ -21,6 +26,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
 	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"go.pinniped.dev/internal/mocks/mockkeyset"
@@ -68,6 +74,16 @@ func TestProviderConfig(t *testing.T) {
 			rawClaims: []byte(`{`),
 		}
 		require.False(t, p.HasUserInfoURL())
+
+		require.Nil(t, p.GetEndSessionURL())
+		p.Provider = &mockProvider{
+			rawClaims: []byte(`{"end_session_endpoint": "https://example.com/logout"}`),
+		}
+		require.Equal(t, "https://example.com/logout", p.GetEndSessionURL().String())
+		p.Provider = &mockProvider{
+			rawClaims: []byte(`{"end_session_endpoint": "http://example.com/logout"}`),
+		}
+		require.Nil(t, p.GetEndSessionURL())
 	})
 
 	const (
@@ -720,6 +736,87 @@ func TestProviderConfig(t *testing.T) {
 		}
 	})
 
+	t.Run("private_key_jwt client authentication", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+		signer, err := NewClientAssertionSigner(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), "test-key-id", nil)
+		require.NoError(t, err)
+
+		var tokenURL string
+		var gotGrantTypes []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPost, r.Method)
+			require.NoError(t, r.ParseForm())
+			_, _, hasBasicAuth := r.BasicAuth()
+			require.False(t, hasBasicAuth, "request should not have had basic auth")
+			require.Empty(t, r.Form.Get("client_secret"))
+			require.Equal(t, "test-client-id", r.Form.Get("client_id"))
+			require.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", r.Form.Get("client_assertion_type"))
+
+			// The client assertion is always for the token endpoint, even when it authenticates a revocation request.
+			assertion, err := josejwt.ParseSigned(r.Form.Get("client_assertion"))
+			require.NoError(t, err)
+			require.Equal(t, "test-key-id", assertion.Headers[0].KeyID)
+			var claims josejwt.Claims
+			require.NoError(t, assertion.Claims(&key.PublicKey, &claims))
+			require.NoError(t, claims.Validate(josejwt.Expected{
+				Issuer:   "test-client-id",
+				Subject:  "test-client-id",
+				Audience: josejwt.Audience{tokenURL},
+				Time:     time.Now(),
+			}))
+
+			if r.URL.Path == "/revoke" {
+				require.Equal(t, "test-upstream-token", r.Form.Get("token"))
+				gotGrantTypes = append(gotGrantTypes, "revoke")
+				return
+			}
+			gotGrantTypes = append(gotGrantTypes, r.Form.Get("grant_type"))
+			var response struct {
+				oauth2.Token
+				IDToken string `json:"id_token,omitempty"`
+			}
+			response.AccessToken = "test-access-token"
+			response.RefreshToken = "test-refresh-token"
+			response.IDToken = validIDToken
+			w.Header().Set("content-type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(&response))
+		}))
+		t.Cleanup(server.Close)
+		tokenURL = server.URL + "/token"
+		revocationURL, err := url.Parse(server.URL + "/revoke")
+		require.NoError(t, err)
+
+		p := ProviderConfig{
+			Name: "test-name",
+			Config: &oauth2.Config{
+				ClientID: "test-client-id",
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  "https://example.com",
+					TokenURL: tokenURL,
+				},
+				Scopes: []string{"scope1", "scope2"},
+			},
+			Provider:              &mockProvider{},
+			Client:                http.DefaultClient,
+			RevocationURL:         revocationURL,
+			AllowPasswordGrant:    true,
+			ClientAssertionSigner: signer,
+		}
+
+		_, err = p.ExchangeAuthcodeAndValidateTokens(context.Background(), "test-authcode", "test-pkce", "", "https://example.com/callback")
+		require.NoError(t, err)
+		_, err = p.PasswordCredentialsGrantAndValidateTokens(context.Background(), "test-username", "test-password")
+		require.NoError(t, err)
+		_, err = p.PerformRefresh(context.Background(), "test-initial-refresh-token")
+		require.NoError(t, err)
+		require.NoError(t, p.RevokeToken(context.Background(), "test-upstream-token", provider.RefreshTokenType))
+
+		require.Equal(t, []string{"authorization_code", "password", "refresh_token", "revoke"}, gotGrantTypes)
+	})
+
 	t.Run("ValidateTokenAndMergeWithUserInfo", func(t *testing.T) {
 		expiryTime := time.Now().Add(42 * time.Second)
 		testTokenWithoutIDToken := &oauth2.Token{
@@ -289,7 +289,7 @@ func Login(issuer string, clientID string, opts ...Option) (*oidctypes.Token, er
 			return provider.Verifier(&oidc.Config{ClientID: audience}).Verify(ctx, token)
 		},
 		promptForValue:  promptForValue,
-		promptForSecret: promptForSecret,
+		promptForSecret: PromptForSecret,
 	}
 	for _, opt := range opts {
 		if err := opt(&h); err != nil {
@@ -675,7 +675,9 @@ func promptForValue(ctx context.Context, promptLabel string) (string, error) {
 	}
 }
 
-func promptForSecret(promptLabel string) (string, error) {
+// PromptForSecret prints the prompt label to stderr and reads a secret, such as a password, from stdin without
+// echoing it. It returns an error when stdin is not connected to a terminal.
+func PromptForSecret(promptLabel string) (string, error) {
 	if !term.IsTerminal(stdin()) {
 		return "", errors.New("stdin is not connected to a terminal")
 	}
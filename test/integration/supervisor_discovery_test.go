@@ -502,16 +502,27 @@ func requireWellKnownEndpointIsWorking(t *testing.T, supervisorScheme, superviso
       "token_endpoint": "%s/oauth2/token",
       "token_endpoint_auth_methods_supported": ["client_secret_basic"],
       "jwks_uri": "%s/jwks.json",
-      "scopes_supported": ["openid", "offline"],
+      "userinfo_endpoint": "%s/oauth2/userinfo",
+      "introspection_endpoint": "%s/oauth2/introspect",
+      "introspection_endpoint_auth_methods_supported": ["client_secret_basic"],
+      "end_session_endpoint": "%s/oauth2/logout",
+      "frontchannel_logout_supported": true,
+      "frontchannel_logout_session_supported": true,
+      "backchannel_logout_supported": true,
+      "backchannel_logout_session_supported": true,
+      "require_pushed_authorization_requests": false,
+      "request_parameter_supported": false,
+      "request_uri_parameter_supported": false,
+      "scopes_supported": ["openid", "offline", "profile", "email"],
       "response_types_supported": ["code"],
       "response_modes_supported": ["query", "form_post"],
       "code_challenge_methods_supported": ["S256"],
-      "claims_supported": ["groups"],
+      "claims_supported": ["groups", "email", "name"],
       "discovery.supervisor.pinniped.dev/v1alpha1": {"pinniped_identity_providers_endpoint": "%s/v1alpha1/pinniped_identity_providers"},
       "subject_types_supported": ["public"],
       "id_token_signing_alg_values_supported": ["ES256"]
     }`)
-	expectedJSON := fmt.Sprintf(expectedResultTemplate, issuerName, issuerName, issuerName, issuerName, issuerName)
+	expectedJSON := fmt.Sprintf(expectedResultTemplate, issuerName, issuerName, issuerName, issuerName, issuerName, issuerName, issuerName, issuerName)
 
 	require.Equal(t, "application/json", response.Header.Get("content-type"))
 	require.JSONEq(t, expectedJSON, responseBody)